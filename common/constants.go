@@ -33,6 +33,10 @@ var DefaultCollapseSidebar = false // default value of collapse sidebar
 var SessionSecret = uuid.New().String()
 var CryptoSecret = uuid.New().String()
 
+// CryptoSecretConfigured 是否通过 CRYPTO_SECRET 或 SESSION_SECRET 配置了固定的密钥，
+// 未配置时 CryptoSecret 为每次启动随机生成，不能用于加密需要持久保存的数据
+var CryptoSecretConfigured = false
+
 var OptionMap map[string]string
 var OptionMapRWMutex sync.RWMutex
 
@@ -117,8 +121,21 @@ var SyncFrequency int // unit is second
 var BatchUpdateEnabled = false
 var BatchUpdateInterval int
 
+// 用户余额扣减走 Redis 原子计数器，定时写回数据库，需启用 Redis
+var RedisQuotaEnabled = false
+var RedisQuotaSyncInterval int // unit is second
+
+// 消费日志异步批量写入，缓冲区写满时回退为同步写入
+var LogBatchWriteEnabled = false
+var LogBatchSize int
+var LogBatchInterval int // unit is second
+var LogBatchBufferSize int
+
 var RelayTimeout int // unit is second
 
+// RelayProxy 请求上游的全局代理，渠道未单独配置代理时使用
+var RelayProxy string
+
 var GeminiSafetySetting string
 
 // https://docs.cohere.com/docs/safety-modes Type; NONE/CONTEXTUAL/STRICT
@@ -126,6 +143,10 @@ var CohereSafetySetting string
 
 const (
 	RequestIdKey = "X-Oneapi-Request-Id"
+	// RequestIdHeader 客户端可通过该请求头传入自己的请求 ID，网关也用它向上游传递网关生成的请求 ID
+	RequestIdHeader = "X-Request-Id"
+	// ClientRequestIdKey 客户端传入的请求 ID 在上下文中的键，与网关请求 ID 分开保存
+	ClientRequestIdKey = "client_request_id"
 )
 
 const (
@@ -240,6 +261,8 @@ const (
 	ChannelTypeBaiduV2        = 46
 	ChannelTypeXinference     = 47
 	ChannelTypeXai            = 48
+	ChannelTypeKling          = 49
+	ChannelTypeRunway         = 50
 	ChannelTypeDummy          // this one is only for count, do not add any channel after this
 
 )
@@ -294,4 +317,6 @@ var ChannelBaseURLs = []string{
 	"https://qianfan.baidubce.com",              //46
 	"",                                          //47
 	"https://api.x.ai",                          //48
+	"https://api.klingai.com",                   //49
+	"https://api.dev.runwayml.com",              //50
 }
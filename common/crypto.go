@@ -1,12 +1,21 @@
 package common
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 )
 
+// 加密后的字符串前缀，用于区分历史明文数据
+const encryptedPrefix = "enc:"
+
 func GenerateHMACWithKey(key []byte, data string) string {
 	h := hmac.New(sha256.New, key)
 	h.Write([]byte(data))
@@ -29,3 +38,63 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func newSecretCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(CryptoSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func IsEncryptedString(s string) bool {
+	return strings.HasPrefix(s, encryptedPrefix)
+}
+
+// ErrCryptoSecretNotConfigured 未配置固定密钥时拒绝加密，否则重启后将无法解密
+var ErrCryptoSecretNotConfigured = errors.New("未设置 CRYPTO_SECRET 或 SESSION_SECRET 环境变量，无法加密保存")
+
+// EncryptString 使用由 CRYPTO_SECRET 派生的密钥进行 AES-GCM 加密，已加密的内容原样返回；
+// 未配置 CRYPTO_SECRET 或 SESSION_SECRET 时返回 ErrCryptoSecretNotConfigured
+func EncryptString(plaintext string) (string, error) {
+	if plaintext == "" || IsEncryptedString(plaintext) {
+		return plaintext, nil
+	}
+	if !CryptoSecretConfigured {
+		return "", ErrCryptoSecretNotConfigured
+	}
+	aead, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString 解密 EncryptString 的结果，未加密的内容原样返回；更换 CRYPTO_SECRET 后无法解密
+func DecryptString(s string) (string, error) {
+	if !IsEncryptedString(s) {
+		return s, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted data")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
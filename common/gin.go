@@ -25,11 +25,15 @@ func GetRequestBody(c *gin.Context) ([]byte, error) {
 }
 
 func UnmarshalBodyReusable(c *gin.Context, v any) error {
+	contentType := c.Request.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		// multipart 请求体不整体读入内存，表单由 ParseMultipartForm 解析并缓存
+		return nil
+	}
 	requestBody, err := GetRequestBody(c)
 	if err != nil {
 		return err
 	}
-	contentType := c.Request.Header.Get("Content-Type")
 	if strings.HasPrefix(contentType, "application/json") {
 		err = json.Unmarshal(requestBody, &v)
 	} else {
@@ -5,10 +5,20 @@ import (
 	"fmt"
 	"github.com/bytedance/gopkg/util/gopool"
 	"math"
+	"sync/atomic"
 )
 
 var relayGoPool gopool.Pool
 
+var (
+	// 转发协程池中正在执行与等待执行的任务数
+	relayPoolRunning atomic.Int32
+	relayPoolPending atomic.Int32
+
+	relayPoolCap      atomic.Int32
+	backgroundPoolCap atomic.Int32
+)
+
 func init() {
 	relayGoPool = gopool.NewPool("gopool.RelayPool", math.MaxInt32, gopool.NewConfig())
 	relayGoPool.SetPanicHandler(func(ctx context.Context, i interface{}) {
@@ -20,5 +30,50 @@ func init() {
 }
 
 func RelayCtxGo(ctx context.Context, f func()) {
-	relayGoPool.CtxGo(ctx, f)
+	relayPoolPending.Add(1)
+	relayGoPool.CtxGo(ctx, func() {
+		relayPoolPending.Add(-1)
+		relayPoolRunning.Add(1)
+		defer relayPoolRunning.Add(-1)
+		f()
+	})
+}
+
+func poolCap(size int) int32 {
+	if size <= 0 || size > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(size)
+}
+
+// gopool.Go 使用的默认协程池的协程上限
+const defaultBackgroundPoolCap = 10000
+
+// SetGoPoolSize 设置转发协程池与后台协程池（gopool.Go）的协程上限，转发协程池 size 不大于 0 时不限制，
+// 后台协程池使用默认的 10000；配置未变化时不做处理
+func SetGoPoolSize(relaySize int, backgroundSize int) {
+	if c := poolCap(relaySize); relayPoolCap.Swap(c) != c {
+		relayGoPool.SetCap(c)
+	}
+	if backgroundSize <= 0 {
+		backgroundSize = defaultBackgroundPoolCap
+	}
+	if c := poolCap(backgroundSize); backgroundPoolCap.Swap(c) != c {
+		gopool.SetCap(c)
+	}
+}
+
+// RelayPoolStats 返回转发协程池正在执行与排队等待的任务数
+func RelayPoolStats() (running int, pending int) {
+	return int(relayPoolRunning.Load()), int(relayPoolPending.Load())
+}
+
+// RelayPoolSaturated 协程全部占满且排队任务达到 queueLength 时返回 true，未限制协程数时始终返回 false
+func RelayPoolSaturated(queueLength int) bool {
+	c := relayPoolCap.Load()
+	if c == 0 || c == math.MaxInt32 {
+		return false
+	}
+	running, pending := RelayPoolStats()
+	return running >= int(c) && pending >= queueLength
 }
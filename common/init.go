@@ -49,8 +49,13 @@ func LoadEnv() {
 	}
 	if os.Getenv("CRYPTO_SECRET") != "" {
 		CryptoSecret = os.Getenv("CRYPTO_SECRET")
+		CryptoSecretConfigured = true
 	} else {
 		CryptoSecret = SessionSecret
+		CryptoSecretConfigured = os.Getenv("SESSION_SECRET") != ""
+	}
+	if !CryptoSecretConfigured {
+		log.Println("WARNING: neither CRYPTO_SECRET nor SESSION_SECRET is set, encrypting channel secrets is disabled.")
 	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
@@ -73,6 +78,7 @@ func LoadEnv() {
 	DebugEnabled = os.Getenv("DEBUG") == "true"
 	MemoryCacheEnabled = os.Getenv("MEMORY_CACHE_ENABLED") == "true"
 	IsMasterNode = os.Getenv("NODE_TYPE") != "slave"
+	InitLogLevel()
 
 	// Parse requestInterval and set RequestInterval
 	requestInterval, _ = strconv.Atoi(os.Getenv("POLLING_INTERVAL"))
@@ -81,7 +87,14 @@ func LoadEnv() {
 	// Initialize variables with GetEnvOrDefault
 	SyncFrequency = GetEnvOrDefault("SYNC_FREQUENCY", 60)
 	BatchUpdateInterval = GetEnvOrDefault("BATCH_UPDATE_INTERVAL", 5)
+	RedisQuotaEnabled = GetEnvOrDefaultBool("REDIS_QUOTA_ENABLED", false)
+	RedisQuotaSyncInterval = GetEnvOrDefault("REDIS_QUOTA_SYNC_INTERVAL", 10)
+	LogBatchWriteEnabled = GetEnvOrDefaultBool("LOG_BATCH_WRITE_ENABLED", false)
+	LogBatchSize = GetEnvOrDefault("LOG_BATCH_SIZE", 200)
+	LogBatchInterval = GetEnvOrDefault("LOG_BATCH_INTERVAL", 1)
+	LogBatchBufferSize = GetEnvOrDefault("LOG_BATCH_BUFFER_SIZE", 10000)
 	RelayTimeout = GetEnvOrDefault("RELAY_TIMEOUT", 0)
+	RelayProxy = GetEnvOrDefaultString("RELAY_PROXY", "")
 
 	// Initialize string variables with GetEnvOrDefaultString
 	GeminiSafetySetting = GetEnvOrDefaultString("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SplitIPEntries 按换行、逗号或空白拆分 IP 列表
+func SplitIPEntries(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// ParseIPNets 解析 IP 与 CIDR 列表，单个 IP 视为 /32 或 /128
+func ParseIPNets(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range SplitIPEntries(s) {
+		ipNet, err := ParseIPNet(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func ParseIPNet(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", entry)
+		}
+		return ipNet, nil
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP: %s", entry)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// IPInNets 判断 ip 是否属于任一网段，ip 无法解析时返回 false
+func IPInNets(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
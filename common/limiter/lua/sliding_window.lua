@@ -0,0 +1,36 @@
+-- 滑动窗口限流器，窗口内每条记录的成员格式为 "<唯一标识>:<消耗量>"
+-- KEYS[1]: 限流器唯一标识
+-- ARGV[1]: 当前时间（毫秒）
+-- ARGV[2]: 窗口长度（毫秒）
+-- ARGV[3]: 窗口内允许的最大消耗量，0 表示只记录不检查
+-- ARGV[4]: 本次消耗量
+-- ARGV[5]: 本次记录的唯一标识
+
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+local entries = redis.call('ZRANGE', key, 0, -1, 'WITHSCORES')
+local used = 0
+local oldest = now
+for i = 1, #entries, 2 do
+    local c = tonumber(string.match(entries[i], ':(%d+)$')) or 1
+    used = used + c
+    if i == 1 then
+        oldest = tonumber(entries[i + 1])
+    end
+end
+
+if limit > 0 and used + cost > limit then
+    return {0, used, oldest}
+end
+
+if cost > 0 then
+    redis.call('ZADD', key, now, ARGV[5] .. ':' .. cost)
+    redis.call('PEXPIRE', key, window)
+end
+return {1, used + cost, oldest}
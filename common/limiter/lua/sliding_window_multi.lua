@@ -0,0 +1,49 @@
+-- 多个滑动窗口的原子检查与记录：全部窗口都未超限时才在每个窗口记录本次消耗，成员格式同 sliding_window.lua
+-- KEYS: 各限流器唯一标识
+-- ARGV[1]: 当前时间（毫秒）
+-- ARGV[2]: 窗口长度（毫秒）
+-- ARGV[3]: 本次记录的唯一标识
+-- ARGV[4 + 2*(i-1)]: 第 i 个窗口允许的最大消耗量，0 表示只记录不检查
+-- ARGV[5 + 2*(i-1)]: 第 i 个窗口的本次消耗量
+-- 返回 {被拒绝的窗口序号（从 1 开始，0 表示全部通过）, used1, oldest1, used2, oldest2, ...}
+
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local result = {0}
+local denied = 0
+for i, key in ipairs(KEYS) do
+    local limit = tonumber(ARGV[4 + 2 * (i - 1)])
+    local cost = tonumber(ARGV[5 + 2 * (i - 1)])
+    redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+    local entries = redis.call('ZRANGE', key, 0, -1, 'WITHSCORES')
+    local used = 0
+    local oldest = now
+    for j = 1, #entries, 2 do
+        local c = tonumber(string.match(entries[j], ':(%d+)$')) or 1
+        used = used + c
+        if j == 1 then
+            oldest = tonumber(entries[j + 1])
+        end
+    end
+    if denied == 0 and limit > 0 and used + cost > limit then
+        denied = i
+    end
+    result[#result + 1] = used
+    result[#result + 1] = oldest
+end
+
+result[1] = denied
+if denied ~= 0 then
+    return result
+end
+for i, key in ipairs(KEYS) do
+    local cost = tonumber(ARGV[5 + 2 * (i - 1)])
+    if cost > 0 then
+        redis.call('ZADD', key, now, member .. ':' .. cost)
+        redis.call('PEXPIRE', key, window)
+    end
+    result[2 * i] = result[2 * i] + cost
+end
+return result
@@ -0,0 +1,141 @@
+package limiter
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrQueueFull = errors.New("wait queue is full")
+
+type semaphoreWaiter struct {
+	priority int
+	seq      uint64
+	index    int
+	granted  bool
+	ready    chan struct{}
+}
+
+type waiterHeap []*semaphoreWaiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+// 优先级高的先出队，同优先级按到达顺序出队
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*semaphoreWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
+
+// PrioritySemaphore 带优先级等待队列的信号量，limit <= 0 表示不限制
+type PrioritySemaphore struct {
+	mu      sync.Mutex
+	limit   int
+	inUse   int
+	seq     uint64
+	waiters waiterHeap
+}
+
+func NewPrioritySemaphore(limit int) *PrioritySemaphore {
+	return &PrioritySemaphore{limit: limit}
+}
+
+// SetLimit 调整并发上限，调大时立即唤醒等待者
+func (s *PrioritySemaphore) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit == limit {
+		return
+	}
+	s.limit = limit
+	s.dispatch()
+}
+
+func (s *PrioritySemaphore) available() bool {
+	return s.limit <= 0 || s.inUse < s.limit
+}
+
+// dispatch 按优先级唤醒等待者，调用方需持有锁
+func (s *PrioritySemaphore) dispatch() {
+	for s.waiters.Len() > 0 && s.available() {
+		w := heap.Pop(&s.waiters).(*semaphoreWaiter)
+		w.granted = true
+		s.inUse++
+		close(w.ready)
+	}
+}
+
+// Acquire 获取一个并发名额，名额不足时按优先级排队等待，直到 ctx 结束；
+// maxQueue > 0 时等待队列已满直接返回 ErrQueueFull
+func (s *PrioritySemaphore) Acquire(ctx context.Context, priority int, maxQueue int) error {
+	s.mu.Lock()
+	if s.available() && s.waiters.Len() == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		return nil
+	}
+	if maxQueue > 0 && s.waiters.Len() >= maxQueue {
+		s.mu.Unlock()
+		return ErrQueueFull
+	}
+	s.seq++
+	w := &semaphoreWaiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if w.granted {
+			// 超时的同时已获得名额，归还给下一个等待者
+			s.inUse--
+			s.dispatch()
+		} else {
+			heap.Remove(&s.waiters, w.index)
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *PrioritySemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inUse > 0 {
+		s.inUse--
+	}
+	s.dispatch()
+}
+
+// Stats 返回当前占用数与排队数
+func (s *PrioritySemaphore) Stats() (inUse int, waiting int, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inUse, s.waiters.Len(), s.limit
+}
@@ -0,0 +1,194 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math/rand"
+	"one-api/common"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/sliding_window.lua
+var slidingWindowScriptSource string
+
+var slidingWindowScript = redis.NewScript(slidingWindowScriptSource)
+
+//go:embed lua/sliding_window_multi.lua
+var slidingWindowMultiScriptSource string
+
+var slidingWindowMultiScript = redis.NewScript(slidingWindowMultiScriptSource)
+
+// WindowRequest 多窗口原子检查中的单个窗口，Limit 为 0 时只记录不检查
+type WindowRequest struct {
+	Key   string
+	Limit int64
+	Cost  int64
+}
+
+// WindowResult 滑动窗口检查结果
+type WindowResult struct {
+	Allowed bool
+	// Used 窗口内已消耗量（允许时包含本次消耗）
+	Used int64
+	// ResetAt 窗口内最早一条记录过期的时间，即额度开始恢复的时间
+	ResetAt time.Time
+}
+
+type windowEntry struct {
+	at   time.Time
+	cost int64
+}
+
+type memoryWindow struct {
+	entries []windowEntry
+}
+
+var memoryWindows = make(map[string]*memoryWindow)
+var memoryWindowsLock sync.Mutex
+var memoryWindowsSweepOnce sync.Once
+
+func sweepMemoryWindows(window time.Duration) {
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now()
+		memoryWindowsLock.Lock()
+		for key, w := range memoryWindows {
+			if len(w.entries) == 0 || now.Sub(w.entries[len(w.entries)-1].at) > window {
+				delete(memoryWindows, key)
+			}
+		}
+		memoryWindowsLock.Unlock()
+	}
+}
+
+// memoryWindowUsage 清理过期记录并返回窗口内的消耗量与额度开始恢复的时间，调用方需持有锁
+func memoryWindowUsage(key string, window time.Duration, now time.Time) (*memoryWindow, int64, time.Time) {
+	w, ok := memoryWindows[key]
+	if !ok {
+		w = &memoryWindow{}
+		memoryWindows[key] = w
+	}
+	start := 0
+	for start < len(w.entries) && now.Sub(w.entries[start].at) >= window {
+		start++
+	}
+	w.entries = w.entries[start:]
+	var used int64
+	for _, entry := range w.entries {
+		used += entry.cost
+	}
+	resetAt := now.Add(window)
+	if len(w.entries) > 0 {
+		resetAt = w.entries[0].at.Add(window)
+	}
+	return w, used, resetAt
+}
+
+func memorySlidingWindowMulti(requests []WindowRequest, window time.Duration) []WindowResult {
+	memoryWindowsSweepOnce.Do(func() {
+		go sweepMemoryWindows(window)
+	})
+	now := time.Now()
+	memoryWindowsLock.Lock()
+	defer memoryWindowsLock.Unlock()
+	results := make([]WindowResult, len(requests))
+	windows := make([]*memoryWindow, len(requests))
+	allowed := true
+	for i, request := range requests {
+		w, used, resetAt := memoryWindowUsage(request.Key, window, now)
+		windows[i] = w
+		results[i] = WindowResult{Allowed: true, Used: used, ResetAt: resetAt}
+		if allowed && request.Limit > 0 && used+request.Cost > request.Limit {
+			results[i].Allowed = false
+			allowed = false
+		}
+	}
+	if !allowed {
+		return results
+	}
+	for i, request := range requests {
+		if request.Cost > 0 {
+			windows[i].entries = append(windows[i].entries, windowEntry{at: now, cost: request.Cost})
+		}
+		results[i].Used += request.Cost
+	}
+	return results
+}
+
+func memorySlidingWindow(key string, limit int64, window time.Duration, cost int64) WindowResult {
+	memoryWindowsSweepOnce.Do(func() {
+		go sweepMemoryWindows(window)
+	})
+	now := time.Now()
+	memoryWindowsLock.Lock()
+	defer memoryWindowsLock.Unlock()
+	w, used, resetAt := memoryWindowUsage(key, window, now)
+	if limit > 0 && used+cost > limit {
+		return WindowResult{Allowed: false, Used: used, ResetAt: resetAt}
+	}
+	if cost > 0 {
+		w.entries = append(w.entries, windowEntry{at: now, cost: cost})
+	}
+	return WindowResult{Allowed: true, Used: used + cost, ResetAt: resetAt}
+}
+
+// SlidingWindowAllow 检查 key 在窗口内的消耗量加上 cost 是否超过 limit，未超过时记录本次消耗；
+// limit 为 0 时只记录不检查。启用 Redis 时多个节点共享窗口
+func SlidingWindowAllow(ctx context.Context, key string, limit int64, window time.Duration, cost int64) (WindowResult, error) {
+	if !common.RedisEnabled {
+		return memorySlidingWindow(key, limit, window, cost), nil
+	}
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+	values, err := slidingWindowScript.Run(ctx, common.RDB, []string{key},
+		now, window.Milliseconds(), limit, cost, member).Int64Slice()
+	if err != nil {
+		return WindowResult{}, fmt.Errorf("sliding window rate limit failed: %w", err)
+	}
+	if len(values) != 3 {
+		return WindowResult{}, fmt.Errorf("sliding window rate limit failed: unexpected result %v", values)
+	}
+	return WindowResult{
+		Allowed: values[0] == 1,
+		Used:    values[1],
+		ResetAt: time.UnixMilli(values[2]).Add(window),
+	}, nil
+}
+
+// SlidingWindowAllowMulti 原子地检查多个窗口，全部未超限时才在每个窗口记录本次消耗；
+// 任一窗口超限时不记录任何消耗，该窗口的结果 Allowed 为 false。启用 Redis 时通过单个脚本完成
+func SlidingWindowAllowMulti(ctx context.Context, requests []WindowRequest, window time.Duration) ([]WindowResult, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+	if !common.RedisEnabled {
+		return memorySlidingWindowMulti(requests, window), nil
+	}
+	now := time.Now().UnixMilli()
+	keys := make([]string, len(requests))
+	args := []interface{}{now, window.Milliseconds(), fmt.Sprintf("%d-%d", now, rand.Int63())}
+	for i, request := range requests {
+		keys[i] = request.Key
+		args = append(args, request.Limit, request.Cost)
+	}
+	values, err := slidingWindowMultiScript.Run(ctx, common.RDB, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("sliding window rate limit failed: %w", err)
+	}
+	if len(values) != 1+2*len(requests) {
+		return nil, fmt.Errorf("sliding window rate limit failed: unexpected result %v", values)
+	}
+	results := make([]WindowResult, len(requests))
+	for i := range requests {
+		results[i] = WindowResult{
+			Allowed: values[0] != int64(i+1),
+			Used:    values[1+2*i],
+			ResetAt: time.UnixMilli(values[2+2*i]).Add(window),
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,199 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type LogLevel int32
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// tag 文本格式下的级别前缀，与历史日志保持一致
+func (l LogLevel) tag() string {
+	switch l {
+	case LogLevelDebug:
+		return loggerDebug
+	case LogLevelInfo:
+		return loggerINFO
+	case LogLevelWarn:
+		return loggerWarn
+	default:
+		return loggerError
+	}
+}
+
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "info", "":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error", "err":
+		return LogLevelError, nil
+	}
+	return LogLevelInfo, fmt.Errorf("unknown log level: %s", s)
+}
+
+var logLevel atomic.Int32
+var logJSONFormat atomic.Bool
+var moduleLogLevels sync.Map // module -> LogLevel
+var moduleLogLevelsLock sync.Mutex
+
+func init() {
+	logLevel.Store(int32(LogLevelInfo))
+}
+
+// InitLogLevel 从 LOG_LEVEL、LOG_FORMAT、LOG_MODULE_LEVELS 读取初始配置，DEBUG=true 时默认级别为 debug
+func InitLogLevel() {
+	level := GetEnvOrDefaultString("LOG_LEVEL", "")
+	if level == "" && DebugEnabled {
+		level = "debug"
+	}
+	if err := SetLogLevel(level); err != nil {
+		SysError(err.Error())
+	}
+	if err := SetLogFormat(GetEnvOrDefaultString("LOG_FORMAT", "text")); err != nil {
+		SysError(err.Error())
+	}
+	if err := SetModuleLogLevels(GetEnvOrDefaultString("LOG_MODULE_LEVELS", "")); err != nil {
+		SysError(err.Error())
+	}
+}
+
+func SetLogLevel(s string) error {
+	level, err := ParseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	logLevel.Store(int32(level))
+	return nil
+}
+
+func GetLogLevel() string {
+	return LogLevel(logLevel.Load()).String()
+}
+
+// SetLogFormat 支持 text 与 json 两种输出格式
+func SetLogFormat(format string) error {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "json":
+		logJSONFormat.Store(true)
+	case "text", "":
+		logJSONFormat.Store(false)
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+	return nil
+}
+
+func GetLogFormat() string {
+	if logJSONFormat.Load() {
+		return "json"
+	}
+	return "text"
+}
+
+// SetModuleLogLevels 设置按模块覆盖的日志级别，格式如 dify=debug,relay=warn，为空时清除所有覆盖
+func SetModuleLogLevels(s string) error {
+	levels := make(map[string]LogLevel)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		module, levelStr, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(module) == "" {
+			return fmt.Errorf("invalid module log level: %s", item)
+		}
+		level, err := ParseLogLevel(levelStr)
+		if err != nil {
+			return err
+		}
+		levels[strings.TrimSpace(module)] = level
+	}
+	moduleLogLevelsLock.Lock()
+	defer moduleLogLevelsLock.Unlock()
+	moduleLogLevels.Range(func(key, _ any) bool {
+		moduleLogLevels.Delete(key)
+		return true
+	})
+	for module, level := range levels {
+		moduleLogLevels.Store(module, level)
+	}
+	return nil
+}
+
+func GetModuleLogLevels() string {
+	var items []string
+	moduleLogLevels.Range(func(key, value any) bool {
+		items = append(items, fmt.Sprintf("%v=%s", key, value.(LogLevel)))
+		return true
+	})
+	return strings.Join(items, ",")
+}
+
+// Logger 按模块输出的结构化日志，fields 为交替的键值对
+type Logger struct {
+	module string
+}
+
+var defaultLogger = NewLogger("")
+
+func NewLogger(module string) *Logger {
+	return &Logger{module: module}
+}
+
+// Enabled 用于在拼接较大的日志内容（如请求体）前判断是否需要输出
+func (l *Logger) Enabled(level LogLevel) bool {
+	if v, ok := moduleLogLevels.Load(l.module); ok {
+		return level >= v.(LogLevel)
+	}
+	return level >= LogLevel(logLevel.Load())
+}
+
+func (l *Logger) log(ctx context.Context, level LogLevel, msg string, fields []any) {
+	if !l.Enabled(level) {
+		return
+	}
+	logHelper(ctx, level, l.module, msg, fields)
+}
+
+func (l *Logger) Debug(ctx context.Context, msg string, fields ...any) {
+	l.log(ctx, LogLevelDebug, msg, fields)
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, fields ...any) {
+	l.log(ctx, LogLevelInfo, msg, fields)
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, fields ...any) {
+	l.log(ctx, LogLevelWarn, msg, fields)
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, fields ...any) {
+	l.log(ctx, LogLevelError, msg, fields)
+}
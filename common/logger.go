@@ -10,11 +10,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 const (
+	loggerDebug = "DEBUG"
 	loggerINFO  = "INFO"
 	loggerWarn  = "WARN"
 	loggerError = "ERR"
@@ -47,36 +49,77 @@ func SetupLogger() {
 	}
 }
 
+// sysLogger 对应原有的 SysLog/SysError 输出，模块名为 sys
+var sysLogger = NewLogger("sys")
+
 func SysLog(s string) {
-	t := time.Now()
-	_, _ = fmt.Fprintf(gin.DefaultWriter, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
+	sysLogger.Info(nil, s)
 }
 
 func SysError(s string) {
-	t := time.Now()
-	_, _ = fmt.Fprintf(gin.DefaultErrorWriter, "[SYS] %v | %s \n", t.Format("2006/01/02 - 15:04:05"), s)
+	sysLogger.Error(nil, s)
+}
+
+func LogDebug(ctx context.Context, msg string) {
+	defaultLogger.Debug(ctx, msg)
 }
 
 func LogInfo(ctx context.Context, msg string) {
-	logHelper(ctx, loggerINFO, msg)
+	defaultLogger.Info(ctx, msg)
 }
 
 func LogWarn(ctx context.Context, msg string) {
-	logHelper(ctx, loggerWarn, msg)
+	defaultLogger.Warn(ctx, msg)
 }
 
 func LogError(ctx context.Context, msg string) {
-	logHelper(ctx, loggerError, msg)
+	defaultLogger.Error(ctx, msg)
 }
 
-func logHelper(ctx context.Context, level string, msg string) {
+func logHelper(ctx context.Context, level LogLevel, module string, msg string, fields []any) {
 	writer := gin.DefaultErrorWriter
-	if level == loggerINFO {
+	if level < LogLevelWarn {
 		writer = gin.DefaultWriter
 	}
-	id := ctx.Value(RequestIdKey)
+	var id any
+	if ctx != nil {
+		id = ctx.Value(RequestIdKey)
+	}
 	now := time.Now()
-	_, _ = fmt.Fprintf(writer, "[%s] %v | %s | %s \n", level, now.Format("2006/01/02 - 15:04:05"), id, msg)
+	if logJSONFormat.Load() {
+		entry := make(map[string]any, len(fields)/2+5)
+		for i := 0; i+1 < len(fields); i += 2 {
+			entry[fmt.Sprint(fields[i])] = fields[i+1]
+		}
+		entry["time"] = now.Format(time.RFC3339Nano)
+		entry["level"] = level.String()
+		entry["msg"] = msg
+		if module != "" {
+			entry["module"] = module
+		}
+		if id != nil {
+			entry["request_id"] = id
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			data, _ = json.Marshal(map[string]any{"time": entry["time"], "level": entry["level"], "msg": msg})
+		}
+		_, _ = writer.Write(append(data, '\n'))
+	} else {
+		var sb strings.Builder
+		if module != "" && module != "sys" {
+			sb.WriteString("[" + module + "] ")
+		}
+		sb.WriteString(msg)
+		for i := 0; i+1 < len(fields); i += 2 {
+			sb.WriteString(fmt.Sprintf(" %v=%v", fields[i], fields[i+1]))
+		}
+		if module == "sys" {
+			_, _ = fmt.Fprintf(writer, "[SYS] %v | %s \n", now.Format("2006/01/02 - 15:04:05"), sb.String())
+		} else {
+			_, _ = fmt.Fprintf(writer, "[%s] %v | %v | %s \n", level.tag(), now.Format("2006/01/02 - 15:04:05"), id, sb.String())
+		}
+	}
 	logCount++ // we don't need accurate count, so no lock here
 	if logCount > maxLogCount && !setupLogWorking {
 		logCount = 0
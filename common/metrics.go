@@ -0,0 +1,81 @@
+package common
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "one_api"
+
+var (
+	relayRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "relay_requests_total",
+		Help:      "Relay requests by final result, after retries.",
+	}, []string{"channel", "model", "group", "relay_mode", "result"})
+	relayErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "relay_errors_total",
+		Help:      "Relay attempt errors by status code, including retried attempts.",
+	}, []string{"channel", "model", "group", "relay_mode", "status_code"})
+	relayTokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "relay_tokens_total",
+		Help:      "Billed tokens by type.",
+	}, []string{"channel", "model", "group", "type"})
+	relayQuotaTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "relay_quota_consumed_total",
+		Help:      "Quota consumed by relay requests.",
+	}, []string{"channel", "model", "group"})
+	upstreamLatencySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upstream_latency_seconds",
+		Help:      "Duration of a relay attempt against an upstream channel.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"channel", "model", "relay_mode"})
+	streamTTFBSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "stream_ttfb_seconds",
+		Help:      "Time to first byte of streaming responses.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30},
+	}, []string{"channel", "model"})
+)
+
+func init() {
+	prometheus.MustRegister(relayRequestsTotal, relayErrorsTotal, relayTokensTotal, relayQuotaTotal,
+		upstreamLatencySeconds, streamTTFBSeconds)
+}
+
+// MetricRelayRequest 记录一次请求的最终结果（重试结束后）
+func MetricRelayRequest(channelId int, modelName string, group string, relayMode string, success bool) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+	relayRequestsTotal.WithLabelValues(strconv.Itoa(channelId), modelName, group, relayMode, result).Inc()
+}
+
+// MetricRelayAttempt 记录一次对上游渠道的尝试，statusCode 为 0 表示成功
+func MetricRelayAttempt(channelId int, modelName string, group string, relayMode string, duration time.Duration, statusCode int) {
+	channel := strconv.Itoa(channelId)
+	upstreamLatencySeconds.WithLabelValues(channel, modelName, relayMode).Observe(duration.Seconds())
+	if statusCode != 0 {
+		relayErrorsTotal.WithLabelValues(channel, modelName, group, relayMode, strconv.Itoa(statusCode)).Inc()
+	}
+}
+
+// MetricConsume 记录计费的 token 与额度
+func MetricConsume(channelId int, modelName string, group string, promptTokens int, completionTokens int, quota int) {
+	channel := strconv.Itoa(channelId)
+	relayTokensTotal.WithLabelValues(channel, modelName, group, "prompt").Add(float64(promptTokens))
+	relayTokensTotal.WithLabelValues(channel, modelName, group, "completion").Add(float64(completionTokens))
+	relayQuotaTotal.WithLabelValues(channel, modelName, group).Add(float64(quota))
+}
+
+// MetricStreamTTFB 记录流式响应的首字时间
+func MetricStreamTTFB(channelId int, modelName string, ttfb time.Duration) {
+	streamTTFBSeconds.WithLabelValues(strconv.Itoa(channelId), modelName).Observe(ttfb.Seconds())
+}
@@ -0,0 +1,408 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	pdfPageWidth    = 595 // A4
+	pdfPageHeight   = 842
+	pdfMargin       = 40
+	pdfFontSize     = 8
+	pdfLineHeight   = 11
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+	// pdfCellWidth 每个半角字符占用的宽度（千分之一字号），与 Courier 一致，全角字符占两格
+	pdfCellWidth = 600
+)
+
+// PDF_FONT_PATH 指定导出 PDF 时嵌入的 TrueType/OpenType 字体文件（如 NotoSansSC-Regular.ttf），
+// 用于显示中文等非 ASCII 字符；未配置时使用阅读器内置的 Adobe 宋体 STSong-Light，不嵌入字体文件
+var pdfFontPath = GetEnvOrDefaultString("PDF_FONT_PATH", "")
+
+type pdfEmbeddedFont struct {
+	data       []byte
+	font       *sfnt.Font
+	openType   bool // CFF 轮廓的 OpenType 字体
+	unitsPerEm int
+}
+
+var pdfFont *pdfEmbeddedFont
+var pdfFontErr error
+var pdfFontOnce sync.Once
+
+func loadPDFFont() (*pdfEmbeddedFont, error) {
+	pdfFontOnce.Do(func() {
+		if pdfFontPath == "" {
+			return
+		}
+		data, err := os.ReadFile(pdfFontPath)
+		if err != nil {
+			pdfFontErr = err
+			return
+		}
+		if bytes.HasPrefix(data, []byte("ttcf")) {
+			pdfFontErr = errors.New("PDF_FONT_PATH 不支持字体集合（.ttc），请使用 .ttf 或 .otf 字体")
+			return
+		}
+		f, err := sfnt.Parse(data)
+		if err != nil {
+			pdfFontErr = err
+			return
+		}
+		pdfFont = &pdfEmbeddedFont{
+			data:       data,
+			font:       f,
+			openType:   bytes.HasPrefix(data, []byte("OTTO")),
+			unitsPerEm: int(f.UnitsPerEm()),
+		}
+	})
+	return pdfFont, pdfFontErr
+}
+
+// isWideRune 东亚全角字符在等宽排版中占两格
+func isWideRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF01 && r <= 0xFF60) || (r >= 0xFFE0 && r <= 0xFFE6)
+}
+
+// TextDisplayWidth 返回文本在等宽排版中占用的格数，全角字符占两格
+func TextDisplayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if isWideRune(r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// TruncateDisplayWidth 按显示宽度截断文本，超出时以 ... 结尾
+func TruncateDisplayWidth(s string, width int) string {
+	if TextDisplayWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := 1
+		if isWideRune(r) {
+			w = 2
+		}
+		if used+w > width-3 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String() + "..."
+}
+
+// PadDisplayWidth 在文本右侧补空格至指定显示宽度
+func PadDisplayWidth(s string, width int) string {
+	if padding := width - TextDisplayWidth(s); padding > 0 {
+		return s + strings.Repeat(" ", padding)
+	}
+	return s
+}
+
+// pdfEscape 转义 PDF 字符串中的特殊字符，内置字体仅支持 ASCII，其余字符替换为 ?
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isASCIIText(lines []string) bool {
+	for _, line := range lines {
+		for i := 0; i < len(line); i++ {
+			if line[i] > 126 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pdfTextEncoder 将一行文本编码为内容流中的文本绘制指令
+type pdfTextEncoder interface {
+	encodeLine(line string) string
+}
+
+type courierEncoder struct{}
+
+func (courierEncoder) encodeLine(line string) string {
+	return fmt.Sprintf("(%s) Tj", pdfEscape(line))
+}
+
+// stSongEncoder 使用 UniGB-UCS2-H 编码，按 UCS-2 写入字符，超出基本平面的字符替换为 ?
+type stSongEncoder struct{}
+
+func (stSongEncoder) encodeLine(line string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range line {
+		if r < 32 || r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteString("> Tj")
+	return b.String()
+}
+
+// embeddedFontEncoder 使用 Identity-H 编码写入字形编号，并用 TJ 调整每个字形的间距，保持等宽对齐
+type embeddedFontEncoder struct {
+	font   *pdfEmbeddedFont
+	buf    sfnt.Buffer
+	widths map[sfnt.GlyphIndex]int
+	runes  map[sfnt.GlyphIndex]rune
+}
+
+func (e *embeddedFontEncoder) glyph(r rune) (sfnt.GlyphIndex, int) {
+	gid, err := e.font.font.GlyphIndex(&e.buf, r)
+	if err != nil || gid == 0 {
+		if r != '?' {
+			return e.glyph('?')
+		}
+		return 0, pdfCellWidth
+	}
+	if width, ok := e.widths[gid]; ok {
+		return gid, width
+	}
+	width := pdfCellWidth
+	advance, err := e.font.font.GlyphAdvance(&e.buf, gid, fixed.I(e.font.unitsPerEm), 0)
+	if err == nil && e.font.unitsPerEm > 0 {
+		width = advance.Round() * 1000 / e.font.unitsPerEm
+	}
+	e.widths[gid] = width
+	e.runes[gid] = r
+	return gid, width
+}
+
+func (e *embeddedFontEncoder) encodeLine(line string) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for _, r := range line {
+		if r < 32 {
+			r = '?'
+		}
+		gid, width := e.glyph(r)
+		target := pdfCellWidth
+		if isWideRune(r) {
+			target = 2 * pdfCellWidth
+		}
+		fmt.Fprintf(&b, "<%04X>", uint16(gid))
+		if width != target {
+			fmt.Fprintf(&b, " %d ", width-target)
+		}
+	}
+	b.WriteString("] TJ")
+	return b.String()
+}
+
+// toUnicodeCMap 生成字形编号到 Unicode 的映射，便于从 PDF 中复制文本
+func (e *embeddedFontEncoder) toUnicodeCMap() string {
+	gids := make([]int, 0, len(e.runes))
+	for gid := range e.runes {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := min(start+100, len(gids))
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			r := e.runes[sfnt.GlyphIndex(gid)]
+			var utf16 strings.Builder
+			for _, unit := range encodeUTF16(r) {
+				fmt.Fprintf(&utf16, "%04X", unit)
+			}
+			fmt.Fprintf(&b, "<%04X> <%s>\n", gid, utf16.String())
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return b.String()
+}
+
+func encodeUTF16(r rune) []uint16 {
+	if r < 0x10000 {
+		return []uint16{uint16(r)}
+	}
+	r -= 0x10000
+	return []uint16{uint16(0xD800 + (r >> 10)), uint16(0xDC00 + (r & 0x3FF))}
+}
+
+func (e *embeddedFontEncoder) widthArray() string {
+	gids := make([]int, 0, len(e.widths))
+	for gid := range e.widths {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	var b strings.Builder
+	b.WriteByte('[')
+	for _, gid := range gids {
+		fmt.Fprintf(&b, " %d [%d]", gid, e.widths[sfnt.GlyphIndex(gid)])
+	}
+	b.WriteString(" ]")
+	return b.String()
+}
+
+func zlibCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTextPDF 将文本行按等宽排版输出为 PDF 文档，用于导出账单等表格类报表。
+// 纯 ASCII 文本使用内置 Courier 字体；包含其他字符时使用 PDF_FONT_PATH 指定的字体（嵌入 PDF），
+// 未配置时使用阅读器内置的中文字体
+func WriteTextPDF(w io.Writer, lines []string) error {
+	var pages [][]string
+	for start := 0; start < len(lines) || start == 0; start += pdfLinesPerPage {
+		end := min(start+pdfLinesPerPage, len(lines))
+		pages = append(pages, lines[start:end])
+		if end == len(lines) {
+			break
+		}
+	}
+
+	var encoder pdfTextEncoder = courierEncoder{}
+	var embedded *embeddedFontEncoder
+	if !isASCIIText(lines) {
+		font, err := loadPDFFont()
+		if err != nil {
+			return fmt.Errorf("failed to load pdf font: %w", err)
+		}
+		if font != nil {
+			embedded = &embeddedFontEncoder{font: font, widths: make(map[sfnt.GlyphIndex]int), runes: make(map[sfnt.GlyphIndex]rune)}
+			encoder = embedded
+		} else {
+			encoder = stSongEncoder{}
+		}
+	}
+	// 先生成内容流，嵌入字体的宽度表与 ToUnicode 只包含用到的字形
+	contents := make([]string, len(pages))
+	for i, pageLines := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range pageLines {
+			content.WriteString(encoder.encodeLine(line))
+			content.WriteString(" T*\n")
+		}
+		content.WriteString("ET")
+		contents[i] = content.String()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	if embedded != nil && embedded.font.openType {
+		// 嵌入 OpenType 字体文件需要 PDF 1.6
+		buf.WriteString("%PDF-1.6\n")
+	} else {
+		buf.WriteString("%PDF-1.4\n")
+	}
+	// 对象 1: Catalog，2: Pages，3: Font，之后每页占用 Page 与内容流两个对象，字体的其余对象位于最后
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+i*2))
+	}
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	fontObject := 4 + len(pages)*2
+	switch encoder.(type) {
+	case courierEncoder:
+		writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+	case stSongEncoder:
+		writeObject(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light-UniGB-UCS2-H /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", fontObject))
+	default:
+		writeObject(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /EmbeddedFont /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			fontObject, fontObject+3))
+	}
+	for i, content := range contents {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+i*2))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	switch encoder.(type) {
+	case stSongEncoder:
+		writeObject(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R /DW %d /W [1 95 %d] >>",
+			fontObject+1, 2*pdfCellWidth, pdfCellWidth))
+		writeObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	case *embeddedFontEncoder:
+		subtype, fontFileKey, fontFileSubtype := "CIDFontType2", "FontFile2", ""
+		if embedded.font.openType {
+			subtype, fontFileKey, fontFileSubtype = "CIDFontType0", "FontFile3", " /Subtype /OpenType"
+		}
+		cidToGid := ""
+		if !embedded.font.openType {
+			cidToGid = " /CIDToGIDMap /Identity"
+		}
+		writeObject(fmt.Sprintf("<< /Type /Font /Subtype /%s /BaseFont /EmbeddedFont "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW %d /W %s%s >>",
+			subtype, fontObject+1, pdfCellWidth, embedded.widthArray(), cidToGid))
+		writeObject(fmt.Sprintf("<< /Type /FontDescriptor /FontName /EmbeddedFont /Flags 4 /FontBBox [-1000 -300 2000 1000] "+
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 700 /StemV 80 /%s %d 0 R >>", fontFileKey, fontObject+2))
+		fontData, err := zlibCompress(embedded.font.data)
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n<< /Length %d /Length1 %d /Filter /FlateDecode%s >>\nstream\n",
+			len(offsets), len(fontData), len(embedded.font.data), fontFileSubtype)
+		buf.Write(fontData)
+		buf.WriteString("\nendstream\nendobj\n")
+		toUnicode := embedded.toUnicodeCMap()
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode), toUnicode))
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
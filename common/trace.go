@@ -0,0 +1,79 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "one-api"
+
+// TracingEnabled 配置了 OTLP 上报地址时为 true，未启用时所有 span 均为 noop
+var TracingEnabled bool
+
+// InitTracer 设置 OTEL_EXPORTER_OTLP_ENDPOINT 或 OTEL_EXPORTER_OTLP_TRACES_ENDPOINT 后启用链路追踪，
+// 通过 OTLP/HTTP 上报到 Jaeger/Tempo 等后端，采样率等其余参数沿用 OTEL_* 标准环境变量
+func InitTracer() (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithAttributes(
+			attribute.String("service.name", GetEnvOrDefaultString("OTEL_SERVICE_NAME", "one-api")),
+			attribute.String("service.version", Version),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	TracingEnabled = true
+	SysLog("tracing enabled, exporting spans via OTLP/HTTP")
+	return provider.Shutdown, nil
+}
+
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// StartGinSpan 以当前请求上下文为父开启子 span，并在 span 存续期间替换 c.Request 的上下文，
+// 使下游的上游请求、数据库写入等可以挂在该 span 下；返回的 end 会结束 span 并恢复原上下文
+func StartGinSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	parent := c.Request.Context()
+	ctx, span := StartSpan(parent, name, trace.WithAttributes(attrs...))
+	c.Request = c.Request.WithContext(ctx)
+	return span, func() {
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// InjectTraceHeaders 向上游请求头写入 traceparent
+func InjectTraceHeaders(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// DetachedTraceContext 仅保留 ctx 中的 span，不继承取消与超时，用于请求结束后仍需完成的写入
+func DetachedTraceContext(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+type ttlCacheItem[V any] struct {
+	value     V
+	size      int64
+	expiresAt time.Time
+}
+
+// TTLCache 带过期时间与条数上限的内存缓存，写满时先清理过期条目，仍然写满则随机淘汰一条；
+// 通过 SetSized 写入的条目还会计入总大小，超过总大小上限时同样淘汰
+type TTLCache[V any] struct {
+	mu        sync.Mutex
+	items     map[string]ttlCacheItem[V]
+	totalSize int64
+}
+
+func NewTTLCache[V any]() *TTLCache[V] {
+	return &TTLCache[V]{items: make(map[string]ttlCacheItem[V])}
+}
+
+func (c *TTLCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if time.Now().After(item.expiresAt) {
+		c.delete(key)
+		var zero V
+		return zero, false
+	}
+	return item.value, true
+}
+
+func (c *TTLCache[V]) Set(key string, value V, ttl time.Duration, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, 0, ttl, maxEntries, 0)
+}
+
+// SetSized 写入大小为 size 的条目，缓存中所有条目的总大小不超过 maxSize，size 超过 maxSize 时不写入；
+// maxSize 为 0 表示不限制总大小
+func (c *TTLCache[V]) SetSized(key string, value V, size int64, ttl time.Duration, maxEntries int, maxSize int64) bool {
+	if maxSize > 0 && size > maxSize {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, size, ttl, maxEntries, maxSize)
+	return true
+}
+
+// Add 仅在 key 不存在或已过期时写入，返回是否写入成功
+func (c *TTLCache[V]) Add(key string, value V, ttl time.Duration, maxEntries int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, ok := c.items[key]; ok && !time.Now().After(item.expiresAt) {
+		return false
+	}
+	c.set(key, value, 0, ttl, maxEntries, 0)
+	return true
+}
+
+func (c *TTLCache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(key)
+}
+
+func (c *TTLCache[V]) delete(key string) {
+	if item, ok := c.items[key]; ok {
+		c.totalSize -= item.size
+		delete(c.items, key)
+	}
+}
+
+func (c *TTLCache[V]) set(key string, value V, size int64, ttl time.Duration, maxEntries int, maxSize int64) {
+	c.delete(key)
+	full := func() bool {
+		return (maxEntries > 0 && len(c.items) >= maxEntries) || (maxSize > 0 && c.totalSize+size > maxSize)
+	}
+	if full() {
+		now := time.Now()
+		for k, item := range c.items {
+			if now.After(item.expiresAt) {
+				c.delete(k)
+			}
+		}
+		for k := range c.items {
+			if !full() {
+				break
+			}
+			c.delete(k)
+		}
+	}
+	c.items[key] = ttlCacheItem[V]{value: value, size: size, expiresAt: time.Now().Add(ttl)}
+	c.totalSize += size
+}
+
+func (c *TTLCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
@@ -4,4 +4,26 @@ var (
 	ForceFormat                     = "force_format"        // ForceFormat 强制格式化为OpenAI格式
 	ChanelSettingProxy              = "proxy"               // Proxy 代理
 	ChannelSettingThinkingToContent = "thinking_to_content" // ThinkingToContent
+	ChannelSettingDifyDebug         = "dify_debug"          // DifyDebug 是否将 Dify 工作流/节点事件输出为推理内容
+	ChannelSettingConnectTimeout    = "connect_timeout"     // ConnectTimeout 建立连接超时（秒）
+	ChannelSettingFirstByteTimeout  = "first_byte_timeout"  // FirstByteTimeout 等待响应头超时（秒）
+	ChannelSettingFirstTokenTimeout = "first_token_timeout" // FirstTokenTimeout 流式请求等待首个数据块超时（秒），超时切换渠道重试
+	ChannelSettingTotalTimeout      = "total_timeout"       // TotalTimeout 整个请求（含读取响应体）超时（秒）
+	ChannelSettingMaxRetries        = "max_retries"         // MaxRetries 该渠道请求失败后的最大重试次数，覆盖全局重试次数
+	ChannelSettingModelPolicies     = "model_policies"      // ModelPolicies 按模型覆盖上述超时与重试配置，如 {"o1": {"total_timeout": 600}}
+	ChannelSettingMaxConcurrency    = "max_concurrency"     // MaxConcurrency 渠道最大并发请求数，超出时按分组优先级排队
+	ChannelSettingTLSClientCert     = "tls_client_cert"     // TLSClientCert 上游要求 mTLS 时使用的客户端证书（PEM）
+	ChannelSettingTLSClientKey      = "tls_client_key"      // TLSClientKey 客户端证书私钥（PEM），保存时加密
+	ChannelSettingTLSCACert         = "tls_ca_cert"         // TLSCACert 校验上游证书的自定义 CA（PEM，可包含多个），与系统 CA 一同使用
+	// ChannelSettingHeaders 注入上游请求的请求头，如 {"anthropic-beta": "...", "X-User": "{{user_id}}"}，值为空时删除该请求头
+	ChannelSettingHeaders = "headers"
+	// ChannelSettingReasoningNormalize o 系列模型参数规范化，false 关闭，或按项关闭如 {"developer_role": false}
+	ChannelSettingReasoningNormalize = "reasoning_normalize"
+	// ChannelSettingStructuredOutput json_schema 结构化输出的上游格式，guided_json 适用于不支持 response_format 的 vLLM
+	ChannelSettingStructuredOutput = "structured_output"
+	// ChannelSettingHTTPClient 渠道的连接池配置，如 {"dedicated": true, "max_idle_conns": 200, "http2": false, "tls_session_cache": true}，
+	// 另支持 keep_alive（false 时每次请求新建连接）与 idle_conn_timeout（秒）
+	ChannelSettingHTTPClient = "http_client"
+	// ChannelSettingRoutingTags 渠道的路由标签，如 ["us", "cheap"]，渠道的标签（tag）同样参与匹配
+	ChannelSettingRoutingTags = "routing_tags"
 )
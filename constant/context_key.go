@@ -1,10 +1,27 @@
 package constant
 
 const (
-	ContextKeyRequestStartTime = "request_start_time"
-	ContextKeyUserSetting      = "user_setting"
-	ContextKeyUserQuota        = "user_quota"
-	ContextKeyUserStatus       = "user_status"
-	ContextKeyUserEmail        = "user_email"
-	ContextKeyUserGroup        = "user_group"
+	ContextKeyRequestStartTime   = "request_start_time"
+	ContextKeyUserSetting        = "user_setting"
+	ContextKeyUserQuota          = "user_quota"
+	ContextKeyUserStatus         = "user_status"
+	ContextKeyUserEmail          = "user_email"
+	ContextKeyUserGroup          = "user_group"
+	ContextKeyTokenSetting       = "token_setting"
+	ContextKeyHedgeLost          = "hedge_lost"
+	ContextKeyStickyRoutingKey   = "sticky_routing_key"
+	ContextKeyRateLimitChecked   = "rate_limit_checked"
+	ContextKeySemanticVector     = "semantic_cache_vector"
+	ContextKeyStreamOutputFilter = "stream_output_filter"
+	ContextKeyModerationResult   = "moderation_result"
+	ContextKeyPIIRedactor        = "pii_redactor"
+	ContextKeyRequestedModel     = "requested_model"
+	ContextKeyFallbackTried      = "fallback_tried_models"
+	ContextKeyContextTruncated   = "context_truncated"
+	ContextKeyChannelTagPolicy   = "channel_tag_policy"
+	ContextKeyOrganizationId     = "organization_id"
+	ContextKeyUpstreamLatency    = "upstream_latency"
+	ContextKeyConsumedQuota      = "consumed_quota"
+	ContextKeyCachedContent      = "cached_content"
+	ContextKeyBatchRequest       = "batch_request"
 )
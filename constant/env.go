@@ -17,6 +17,8 @@ var NotifyLimitCount int
 var NotificationLimitDurationMinute int
 var GenerateDefaultToken bool
 var ErrorLogEnabled bool
+var MetricsEnabled bool
+var MetricsToken string
 
 //var GeminiModelMap = map[string]string{
 //	"gemini-1.0-pro": "v1",
@@ -39,6 +41,9 @@ func InitEnv() {
 	GenerateDefaultToken = common.GetEnvOrDefaultBool("GENERATE_DEFAULT_TOKEN", false)
 	// 是否启用错误日志
 	ErrorLogEnabled = common.GetEnvOrDefaultBool("ERROR_LOG_ENABLED", false)
+	// Prometheus 指标接口，仅在设置 METRICS_TOKEN 后开放，访问时需携带 Bearer Token
+	MetricsEnabled = common.GetEnvOrDefaultBool("METRICS_ENABLED", true)
+	MetricsToken = common.GetEnvOrDefaultString("METRICS_TOKEN", "")
 
 	//modelVersionMapStr := strings.TrimSpace(os.Getenv("GEMINI_MODEL_MAP"))
 	//if modelVersionMapStr == "" {
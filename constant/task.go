@@ -5,6 +5,12 @@ type TaskPlatform string
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+
+	// 通用异步任务（/v1/tasks、/v1/video/generations）使用的上游协议
+	TaskPlatformGeneric TaskPlatform = "generic"
+	TaskPlatformSora    TaskPlatform = "sora"
+	TaskPlatformKling   TaskPlatform = "kling"
+	TaskPlatformRunway  TaskPlatform = "runway"
 )
 
 const (
@@ -0,0 +1,25 @@
+package constant
+
+var (
+	TokenSettingDifyDebug    = "dify_debug"     // DifyDebug 覆盖渠道的 Dify 调试输出设置，由管理员设置
+	TokenSettingHedgeEnabled = "hedge_enabled"  // 是否开启对冲请求，由管理员设置
+	TokenSettingHedgeDelayMs = "hedge_delay_ms" // 首字节超过该时间未返回时向第二个渠道发起对冲请求，由管理员设置
+	TokenSettingRPM          = "rpm"            // RPM 每分钟最大请求数
+	TokenSettingTPM          = "tpm"            // TPM 每分钟最大 token 数
+	// TokenSettingModelQuotaLimits 按模型限制额度，如 [{"model": "gpt-4*", "period": "day", "quota": 500000}]，由管理员设置
+	TokenSettingModelQuotaLimits = "model_quota_limits"
+	TokenSettingResponseCache    = "response_cache"  // 是否对完全相同的非流式请求使用响应缓存
+	TokenSettingSemanticCache    = "semantic_cache"  // 是否对语义相近的非流式对话请求使用语义缓存，由管理员设置
+	TokenSettingPromptTemplate   = "prompt_template" // 注入的提示词模板名，在分组模板之后应用
+	// TokenSettingAllowedEndpoints 允许调用的接口类型，如 ["chat", "embeddings"]，也可填写具体的 relay mode 名称
+	TokenSettingAllowedEndpoints = "allowed_endpoints"
+	TokenSettingAllowedMethods   = "allowed_methods" // 允许的 HTTP 方法，如 ["POST"]
+	// TokenSettingHMACSecret 设置后请求必须携带 X-Signature 等签名头，仅凭令牌无法调用
+	TokenSettingHMACSecret = "hmac_secret"
+	// TokenSettingContextTruncation 超出模型上下文时自动截断最早的非系统消息，而不是直接拒绝
+	TokenSettingContextTruncation = "context_truncation"
+	// TokenSettingRequiredChannelTags 只使用同时具有这些路由标签的渠道，与分组配置的必需标签合并
+	TokenSettingRequiredChannelTags = "required_channel_tags"
+	// TokenSettingPreferredChannelTags 优先使用具有其中任一路由标签的渠道，无可用渠道时再使用其余渠道，覆盖分组配置
+	TokenSettingPreferredChannelTags = "preferred_channel_tags"
+)
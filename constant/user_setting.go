@@ -7,6 +7,11 @@ var (
 	UserSettingWebhookSecret         = "webhook_secret"                 // WebhookSecret webhook密钥
 	UserSettingNotificationEmail     = "notification_email"             // NotificationEmail 通知邮箱地址
 	UserAcceptUnsetRatioModel        = "accept_unset_model_ratio_model" // AcceptUnsetRatioModel 是否接受未设置价格的模型
+	UserSettingRPM                   = "rpm"                            // RPM 每分钟最大请求数，由管理员设置
+	UserSettingTPM                   = "tpm"                            // TPM 每分钟最大 token 数，由管理员设置
+	UserSettingModelQuotaLimits      = "model_quota_limits"             // ModelQuotaLimits 按模型限制的每日/每月额度，由管理员设置
+	UserSettingDisplayCurrency       = "display_currency"               // DisplayCurrency 金额展示币种
+	UserSettingCreditLimit           = "credit_limit"                   // CreditLimit 后付费用户允许透支的额度，由管理员设置
 )
 
 var (
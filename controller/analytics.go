@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseCostFilter(c *gin.Context) model.CostFilter {
+	filter := model.CostFilter{}
+	filter.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	filter.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if filter.EndTimestamp == 0 {
+		filter.EndTimestamp = time.Now().Unix()
+	}
+	if filter.StartTimestamp == 0 {
+		filter.StartTimestamp = filter.EndTimestamp - 7*24*3600
+	}
+	filter.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	filter.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	filter.ChannelId, _ = strconv.Atoi(c.Query("channel_id"))
+	filter.ModelName = c.Query("model_name")
+	return filter
+}
+
+func respondCostBreakdown(c *gin.Context, filter model.CostFilter) {
+	groupBy := c.DefaultQuery("group_by", model.CostGroupByModel)
+	items, err := model.GetCostBreakdown(groupBy, filter)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    items,
+	})
+}
+
+// GetCostBreakdown 按模型、渠道、令牌、用户或天汇总费用，数据来自按小时预聚合的统计表
+func GetCostBreakdown(c *gin.Context) {
+	respondCostBreakdown(c, parseCostFilter(c))
+}
+
+// GetSelfCostBreakdown 普通用户只能查询自己的费用，且不能按渠道或用户分组
+func GetSelfCostBreakdown(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", model.CostGroupByModel)
+	if groupBy == model.CostGroupByChannel || groupBy == model.CostGroupByUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的分组维度：" + groupBy,
+		})
+		return
+	}
+	filter := parseCostFilter(c)
+	filter.UserId = c.GetInt("id")
+	filter.ChannelId = 0
+	respondCostBreakdown(c, filter)
+}
+
+// parseUsageSeriesFilter 未指定结束时间时按分钟取整，使相近时间的重复查询命中缓存
+func parseUsageSeriesFilter(c *gin.Context) model.CostFilter {
+	filter := parseCostFilter(c)
+	if c.Query("end_timestamp") == "" {
+		filter.EndTimestamp = filter.EndTimestamp - filter.EndTimestamp%60 + 59
+		if c.Query("start_timestamp") == "" {
+			filter.StartTimestamp = filter.EndTimestamp - 7*24*3600
+		}
+	}
+	return filter
+}
+
+func respondUsageSeries(c *gin.Context, filter model.CostFilter) {
+	interval := c.DefaultQuery("interval", model.UsageSeriesIntervalHour)
+	points, err := model.GetUsageSeries(interval, c.Query("group_by"), filter)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    points,
+	})
+}
+
+// GetUsageSeries 按小时或天返回 token 与额度的时间序列，可按模型、渠道、令牌或用户分组
+func GetUsageSeries(c *gin.Context) {
+	respondUsageSeries(c, parseUsageSeriesFilter(c))
+}
+
+// GetSelfUsageSeries 普通用户只能查询自己的时间序列，且不能按渠道或用户分组
+func GetSelfUsageSeries(c *gin.Context) {
+	groupBy := c.Query("group_by")
+	if groupBy == model.CostGroupByChannel || groupBy == model.CostGroupByUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的分组维度：" + groupBy,
+		})
+		return
+	}
+	filter := parseUsageSeriesFilter(c)
+	filter.UserId = c.GetInt("id")
+	filter.ChannelId = 0
+	respondUsageSeries(c, filter)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每轮最多轮询的任务数
+const asyncTaskPollBatchSize = 200
+
+// 通用异步任务在监控指标中的 relay mode 标签
+const asyncTaskRelayModeName = "async_task"
+
+func asyncTaskError(c *gin.Context, taskErr *dto.TaskError) {
+	taskErr.Message = common.MessageWithRequestId(taskErr.Message, c.GetString(common.RequestIdKey))
+	c.JSON(taskErr.StatusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: taskErr.Message,
+			Type:    "new_api_error",
+			Code:    taskErr.Code,
+		},
+	})
+}
+
+// metricAsyncTaskSubmit 记录异步任务提交的上游尝试与请求结果，提交不重试，两者各记一次
+func metricAsyncTaskSubmit(c *gin.Context, relayModeName string, startTime time.Time, taskErr *dto.TaskError) {
+	statusCode := 0
+	if taskErr != nil {
+		statusCode = taskErr.StatusCode
+	}
+	channelId := c.GetInt("channel_id")
+	modelName := c.GetString("original_model")
+	group := c.GetString("group")
+	common.MetricRelayAttempt(channelId, modelName, group, relayModeName, time.Since(startTime), statusCode)
+	common.MetricRelayRequest(channelId, modelName, group, relayModeName, taskErr == nil)
+}
+
+// SubmitAsyncTask 提交通用异步任务，返回网关任务 ID，之后通过 GET /v1/tasks/:id 或 webhook 获取结果
+func SubmitAsyncTask(c *gin.Context) {
+	startTime := time.Now()
+	task, taskErr := relay.AsyncTaskSubmit(c)
+	metricAsyncTaskSubmit(c, asyncTaskRelayModeName, startTime, taskErr)
+	if taskErr != nil {
+		if !taskErr.LocalError {
+			channelId := c.GetInt("channel_id")
+			common.LogError(c, fmt.Sprintf("async task submit error (channel #%d, status code %d): %s", channelId, taskErr.StatusCode, taskErr.Message))
+		}
+		asyncTaskError(c, taskErr)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// GetAsyncTask 查询当前用户的异步任务
+func GetAsyncTask(c *gin.Context) {
+	task, err := model.GetAsyncTaskByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		asyncTaskError(c, service.TaskErrorWrapperLocal(fmt.Errorf("任务 %s 不存在", c.Param("id")), "task_not_exist", http.StatusNotFound))
+		return
+	}
+	c.JSON(http.StatusOK, service.AsyncTaskToDto(task))
+}
+
+// ListAsyncTasks 列出当前用户的异步任务，可按状态筛选
+func ListAsyncTasks(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	tasks, total, err := model.GetUserAsyncTasks(c.GetInt("id"), c.Query("status"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		asyncTaskError(c, service.TaskErrorWrapperLocal(err, "get_tasks_failed", http.StatusInternalServerError))
+		return
+	}
+	items := make([]*dto.AsyncTaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		items = append(items, service.AsyncTaskToDto(task))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   items,
+		"total":  total,
+	})
+}
+
+// GetAllAsyncTasks 管理员查看全部异步任务，可按用户、渠道与状态筛选
+func GetAllAsyncTasks(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	tasks, total, err := model.GetAllAsyncTasks(userId, channelId, c.Query("status"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items":     tasks,
+			"total":     total,
+			"page":      p,
+			"page_size": pageSize,
+		},
+	})
+}
+
+func pollAsyncTask(task *model.AsyncTask) {
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		task.Status = model.AsyncTaskStatusFailed
+		task.FailReason = fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", task.ChannelId)
+		service.SettleAsyncTask(task)
+		return
+	}
+	baseUrl := channel.GetBaseURL()
+	if baseUrl == "" {
+		baseUrl = common.ChannelBaseURLs[channel.Type]
+	}
+	upstream, err := relay.GetAsyncTaskAdaptor(task.Platform).FetchAsyncTask(baseUrl, channel.Key, task.UpstreamTaskId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to fetch async task %s: %s", task.TaskId, err.Error()))
+		// 查询失败时保持原状态，按轮询间隔重试，超时后判定失败
+		upstream = &dto.AsyncTaskResponse{Status: task.Status, Progress: task.Progress}
+	}
+	service.UpdateAsyncTask(task, upstream)
+}
+
+// AutomaticallyPollAsyncTasks 定期轮询未结束的异步任务，仅在主节点运行
+func AutomaticallyPollAsyncTasks() {
+	for {
+		time.Sleep(5 * time.Second)
+		tasks, err := model.GetDueAsyncTasks(asyncTaskPollBatchSize)
+		if err != nil {
+			common.SysError("failed to get async tasks: " + err.Error())
+			continue
+		}
+		for _, task := range tasks {
+			pollAsyncTask(task)
+		}
+	}
+}
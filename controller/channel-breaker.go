@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 熔断探测任务的检查间隔
+const channelCircuitProbeInterval = 5 * time.Second
+
+// 同时进行的熔断探测数量，单个渠道探测超时不会阻塞其他渠道的探测
+const channelCircuitProbeConcurrency = 4
+
+func probeChannelCircuit(channelId int) {
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		// 渠道已删除
+		model.ResetChannelCircuit(channelId)
+		return
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		// 渠道已被禁用，交由禁用/启用逻辑处理
+		model.ResetChannelCircuit(channelId)
+		return
+	}
+	tik := time.Now()
+	err, openaiErr := testChannel(channel, "")
+	milliseconds := time.Since(tik).Milliseconds()
+	if err == nil && openaiErr == nil {
+		model.ReportChannelCircuitProbe(channelId, true)
+		channel.UpdateResponseTime(milliseconds)
+		common.SysLog(fmt.Sprintf("渠道「%s」（#%d）探测成功，已恢复", channel.Name, channelId))
+		return
+	}
+	model.ReportChannelCircuitProbe(channelId, false)
+	common.SysError(fmt.Sprintf("渠道「%s」（#%d）探测失败: %v", channel.Name, channelId, err))
+}
+
+// AutomaticallyProbeChannelCircuits 定期探测已熔断的渠道，恢复健康的渠道
+func AutomaticallyProbeChannelCircuits() {
+	slots := make(chan struct{}, channelCircuitProbeConcurrency)
+	for {
+		time.Sleep(channelCircuitProbeInterval)
+		for _, channelId := range model.AcquireChannelCircuitProbes(channelCircuitProbeConcurrency - len(slots)) {
+			slots <- struct{}{}
+			channelId := channelId
+			gopool.Go(func() {
+				defer func() { <-slots }()
+				probeChannelCircuit(channelId)
+			})
+		}
+	}
+}
+
+func GetChannelCircuits(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetChannelCircuitStatuses(),
+	})
+}
+
+func ResetChannelCircuit(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.ResetChannelCircuit(id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isHealthCheckSupported(channel *model.Channel) bool {
+	switch channel.Type {
+	case common.ChannelTypeMidjourney, common.ChannelTypeMidjourneyPlus, common.ChannelTypeSunoAPI:
+		return false
+	}
+	return true
+}
+
+func healthCheckChannel(channel *model.Channel) {
+	testModel := getChannelTestModel(channel)
+	tik := time.Now()
+	err, openaiErr := doTestChannel(channel, testModel, false)
+	latency := time.Since(tik)
+	success := err == nil && openaiErr == nil
+
+	// 探测结果同样参与延迟统计与熔断判断
+	model.RecordChannelModelLatency(channel.Id, testModel, latency, !success)
+	if model.RecordChannelResult(channel.Id, !success) {
+		common.SysError(fmt.Sprintf("渠道「%s」（#%d）健康检查错误率过高，已熔断", channel.Name, channel.Id))
+	}
+	if success {
+		channel.UpdateResponseTime(latency.Milliseconds())
+	}
+
+	check := &model.ChannelHealthCheck{
+		ChannelId: channel.Id,
+		Model:     testModel,
+		Success:   success,
+		LatencyMs: latency.Milliseconds(),
+	}
+	if err != nil {
+		check.Message = err.Error()
+	}
+	if recordErr := model.RecordChannelHealthCheck(check); recordErr != nil {
+		common.SysError(fmt.Sprintf("failed to record health check of channel #%d: %s", channel.Id, recordErr.Error()))
+	}
+}
+
+func runChannelHealthChecks() {
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		common.SysError("failed to get channels for health check: " + err.Error())
+		return
+	}
+	for _, channel := range channels {
+		if channel.Status != common.ChannelStatusEnabled || !isHealthCheckSupported(channel) {
+			continue
+		}
+		healthCheckChannel(channel)
+		time.Sleep(common.RequestInterval)
+	}
+	retentionDays := operation_setting.GetHealthCheckSetting().RetentionDays
+	if retentionDays > 0 {
+		before := time.Now().AddDate(0, 0, -retentionDays).Unix()
+		if _, err := model.DeleteChannelHealthChecksBefore(before); err != nil {
+			common.SysError("failed to clean channel health checks: " + err.Error())
+		}
+	}
+}
+
+// AutomaticallyHealthCheckChannels 定期向所有已启用渠道发送探测请求，记录可用性与延迟
+func AutomaticallyHealthCheckChannels() {
+	for {
+		setting := operation_setting.GetHealthCheckSetting()
+		interval := setting.IntervalSeconds
+		if interval < 10 {
+			interval = 10
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+		if !operation_setting.GetHealthCheckSetting().Enabled {
+			continue
+		}
+		common.SysLog("health checking all channels")
+		runChannelHealthChecks()
+		common.SysLog("channel health check finished")
+	}
+}
+
+type channelProbeRequest struct {
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	Stream    bool   `json:"stream"`
+	MaxTokens int    `json:"max_tokens"`
+}
+
+// ProbeChannel 通过指定渠道发送一次真实的对话请求，返回延迟、状态码与首个数据块，结果写入渠道的健康检查记录
+func ProbeChannel(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var req channelProbeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !isHealthCheckSupported(channel) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该渠道类型不支持探测",
+		})
+		return
+	}
+	testModel := req.Model
+	if testModel == "" {
+		testModel = getChannelTestModel(channel)
+	}
+	options := channelTestOptions{
+		Prompt:    req.Prompt,
+		Stream:    req.Stream,
+		MaxTokens: req.MaxTokens,
+	}
+	var result channelTestResult
+	tik := time.Now()
+	err, _ = runChannelTest(channel, testModel, options, &result)
+	latency := time.Since(tik)
+	success := err == nil
+	if success {
+		channel.UpdateResponseTime(latency.Milliseconds())
+	}
+
+	check := &model.ChannelHealthCheck{
+		ChannelId: channel.Id,
+		Model:     testModel,
+		Success:   success,
+		LatencyMs: latency.Milliseconds(),
+	}
+	message := ""
+	if err != nil {
+		message = err.Error()
+		check.Message = message
+	}
+	if recordErr := model.RecordChannelHealthCheck(check); recordErr != nil {
+		common.SysError(fmt.Sprintf("failed to record probe of channel #%d: %s", channel.Id, recordErr.Error()))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"model":                  testModel,
+			"stream":                 req.Stream,
+			"available":              success,
+			"message":                message,
+			"status_code":            result.StatusCode,
+			"latency_ms":             latency.Milliseconds(),
+			"first_chunk_latency_ms": result.FirstChunkLatencyMs,
+			"first_chunk":            result.FirstChunk,
+		},
+	})
+}
+
+func GetChannelHealth(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	if startTimestamp == 0 {
+		startTimestamp = time.Now().Add(-24 * time.Hour).Unix()
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	checks, err := model.GetChannelHealthChecks(channelId, startTimestamp, limit)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	summaries, err := model.GetChannelHealthSummaries(channelId, startTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var summary *model.ChannelHealthSummary
+	if len(summaries) > 0 {
+		summary = summaries[0]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"summary": summary,
+			"checks":  checks,
+		},
+	})
+}
+
+func GetChannelHealthSummaries(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	if startTimestamp == 0 {
+		startTimestamp = time.Now().Add(-24 * time.Hour).Unix()
+	}
+	summaries, err := model.GetChannelHealthSummaries(0, startTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    summaries,
+	})
+}
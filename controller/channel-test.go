@@ -30,6 +30,109 @@ import (
 )
 
 func testChannel(channel *model.Channel, testModel string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
+	return doTestChannel(channel, testModel, true)
+}
+
+// getChannelTestModel 未指定测试模型时，依次使用渠道配置的测试模型、渠道的第一个模型
+func getChannelTestModel(channel *model.Channel) string {
+	if channel.TestModel != nil && *channel.TestModel != "" {
+		return *channel.TestModel
+	}
+	if len(channel.GetModels()) > 0 {
+		return channel.GetModels()[0]
+	}
+	return "gpt-4o-mini"
+}
+
+// doTestChannel recordLog 为 false 时不记录消费日志，用于定时健康检查等高频探测
+func doTestChannel(channel *model.Channel, testModel string, recordLog bool) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
+	return runChannelTest(channel, testModel, channelTestOptions{RecordLog: recordLog}, nil)
+}
+
+// channelTestOptions 渠道测试的请求配置，零值时使用默认的测试请求
+type channelTestOptions struct {
+	RecordLog bool
+	Prompt    string
+	Stream    bool
+	MaxTokens int
+}
+
+// channelTestResult 渠道测试的响应信息，FirstChunk 为流式响应的首个数据块或非流式响应体
+type channelTestResult struct {
+	StatusCode          int
+	FirstChunk          string
+	FirstChunkLatencyMs int64
+}
+
+// 返回给管理端的首个数据块的最大长度
+const channelTestFirstChunkLimit = 4096
+
+// channelTestRecorder 记录首次写入响应的时间，用于统计首个数据块的延迟
+type channelTestRecorder struct {
+	*httptest.ResponseRecorder
+	firstWrite time.Time
+}
+
+func (r *channelTestRecorder) Write(data []byte) (int, error) {
+	if r.firstWrite.IsZero() {
+		r.firstWrite = time.Now()
+	}
+	return r.ResponseRecorder.Write(data)
+}
+
+func (r *channelTestRecorder) WriteString(s string) (int, error) {
+	if r.firstWrite.IsZero() {
+		r.firstWrite = time.Now()
+	}
+	return r.ResponseRecorder.WriteString(s)
+}
+
+// firstResponseChunk 流式响应取第一个 data 分块，跳过保活注释
+func firstResponseChunk(body []byte, stream bool) string {
+	chunk := string(body)
+	if stream {
+		chunk = ""
+		for _, line := range strings.Split(string(body), "\n") {
+			line = strings.TrimSpace(line)
+			if data, ok := strings.CutPrefix(line, "data:"); ok {
+				chunk = strings.TrimSpace(data)
+				break
+			}
+		}
+	}
+	if len(chunk) > channelTestFirstChunkLimit {
+		chunk = chunk[:channelTestFirstChunkLimit]
+	}
+	return chunk
+}
+
+// applyChannelTestOptions 使用自定义的提示词、最大输出 token 与流式配置覆盖默认测试请求，Embedding 请求不受影响
+func applyChannelTestOptions(request *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo, options channelTestOptions) {
+	if len(request.Messages) == 0 {
+		return
+	}
+	if options.Prompt != "" {
+		content, _ := json.Marshal(options.Prompt)
+		request.Messages[0].Content = content
+	}
+	if options.MaxTokens > 0 {
+		if request.MaxCompletionTokens > 0 {
+			request.MaxCompletionTokens = uint(options.MaxTokens)
+		} else {
+			request.MaxTokens = uint(options.MaxTokens)
+		}
+	}
+	if options.Stream {
+		request.Stream = true
+		info.IsStream = true
+		if info.SupportStreamOptions {
+			request.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+		}
+	}
+}
+
+// runChannelTest 向渠道发送测试请求，result 不为 nil 时写入状态码与首个数据块
+func runChannelTest(channel *model.Channel, testModel string, options channelTestOptions, result *channelTestResult) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
 	tik := time.Now()
 	if channel.Type == common.ChannelTypeMidjourney {
 		return errors.New("midjourney channel test is not supported"), nil
@@ -40,7 +143,7 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	if channel.Type == common.ChannelTypeSunoAPI {
 		return errors.New("suno channel test is not supported"), nil
 	}
-	w := httptest.NewRecorder()
+	w := &channelTestRecorder{ResponseRecorder: httptest.NewRecorder()}
 	c, _ := gin.CreateTestContext(w)
 
 	requestPath := "/v1/chat/completions"
@@ -62,15 +165,7 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	}
 
 	if testModel == "" {
-		if channel.TestModel != nil && *channel.TestModel != "" {
-			testModel = *channel.TestModel
-		} else {
-			if len(channel.GetModels()) > 0 {
-				testModel = channel.GetModels()[0]
-			} else {
-				testModel = "gpt-4o-mini"
-			}
-		}
+		testModel = getChannelTestModel(channel)
 	}
 
 	cache, err := model.GetUserCache(1)
@@ -103,6 +198,7 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	}
 
 	request := buildTestRequest(testModel)
+	applyChannelTestOptions(request, info, options)
 	// 创建一个用于日志的 info 副本，移除 ApiKey
 	logInfo := *info
 	logInfo.ApiKey = ""
@@ -132,12 +228,21 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	var httpResp *http.Response
 	if resp != nil {
 		httpResp = resp.(*http.Response)
+		if result != nil {
+			result.StatusCode = httpResp.StatusCode
+		}
 		if httpResp.StatusCode != http.StatusOK {
 			err := service.RelayErrorHandler(httpResp, true)
 			return fmt.Errorf("status code %d: %s", httpResp.StatusCode, err.Error.Message), err
 		}
 	}
 	usageA, respErr := adaptor.DoResponse(c, httpResp, info)
+	if result != nil {
+		if !w.firstWrite.IsZero() {
+			result.FirstChunkLatencyMs = w.firstWrite.Sub(tik).Milliseconds()
+		}
+		result.FirstChunk = firstResponseChunk(w.Body.Bytes(), info.IsStream)
+	}
 	if respErr != nil {
 		return fmt.Errorf("%s", respErr.Error.Message), respErr
 	}
@@ -145,12 +250,14 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 		return errors.New("usage is nil"), nil
 	}
 	usage := usageA.(*dto.Usage)
-	result := w.Result()
-	respBody, err := io.ReadAll(result.Body)
+	respBody, err := io.ReadAll(w.Result().Body)
 	if err != nil {
 		return err, nil
 	}
 	info.PromptTokens = usage.PromptTokens
+	if !options.RecordLog {
+		return nil, nil
+	}
 
 	quota := 0
 	if !priceData.UsePrice {
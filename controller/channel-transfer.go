@@ -0,0 +1,440 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 导出时不包含密钥，导入前需要补充
+	channelExportSecretsNone = "none"
+	// 导出加密后的密钥，导入的实例需要使用相同的 CRYPTO_SECRET
+	channelExportSecretsEncrypted = "encrypted"
+)
+
+// channelTransfer 渠道导入导出的格式，不包含 id、余额、已用额度、响应时间等运行时数据
+type channelTransfer struct {
+	Name               string `json:"name"`
+	Type               int    `json:"type"`
+	Key                string `json:"key"`
+	Status             int    `json:"status"`
+	BaseURL            string `json:"base_url"`
+	Models             string `json:"models"`
+	Group              string `json:"group"`
+	Tag                string `json:"tag"`
+	Priority           int64  `json:"priority"`
+	Weight             uint   `json:"weight"`
+	AutoBan            int    `json:"auto_ban"`
+	TestModel          string `json:"test_model"`
+	OpenAIOrganization string `json:"openai_organization"`
+	ModelMapping       string `json:"model_mapping"`
+	StatusCodeMapping  string `json:"status_code_mapping"`
+	Other              string `json:"other"`
+	Setting            string `json:"setting"`
+	ParamOverride      string `json:"param_override"`
+}
+
+// CSV 的列与 channelTransfer 的 json 字段一一对应
+var channelTransferColumns = []string{
+	"name", "type", "key", "status", "base_url", "models", "group", "tag", "priority", "weight", "auto_ban",
+	"test_model", "openai_organization", "model_mapping", "status_code_mapping", "other", "setting", "param_override",
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func newChannelTransfer(channel *model.Channel, secrets string) (*channelTransfer, error) {
+	transfer := &channelTransfer{
+		Name:               channel.Name,
+		Type:               channel.Type,
+		Status:             channel.Status,
+		BaseURL:            channel.GetBaseURL(),
+		Models:             channel.Models,
+		Group:              channel.Group,
+		Tag:                channel.GetTag(),
+		Priority:           channel.GetPriority(),
+		Weight:             uint(channel.GetWeight()),
+		AutoBan:            1,
+		TestModel:          stringValue(channel.TestModel),
+		OpenAIOrganization: stringValue(channel.OpenAIOrganization),
+		ModelMapping:       channel.GetModelMapping(),
+		StatusCodeMapping:  channel.GetStatusCodeMapping(),
+		Other:              channel.Other,
+		ParamOverride:      stringValue(channel.ParamOverride),
+	}
+	if !channel.GetAutoBan() {
+		transfer.AutoBan = 0
+	}
+	setting := channel.GetSetting()
+	if secrets == channelExportSecretsEncrypted {
+		key, err := common.EncryptString(channel.Key)
+		if err != nil {
+			return nil, err
+		}
+		transfer.Key = key
+	} else {
+		// mTLS 私钥同样属于密钥
+		delete(setting, constant.ChannelSettingTLSClientKey)
+	}
+	if len(setting) > 0 {
+		settingBytes, err := json.Marshal(setting)
+		if err != nil {
+			return nil, err
+		}
+		transfer.Setting = string(settingBytes)
+	}
+	return transfer, nil
+}
+
+func (t *channelTransfer) record() []string {
+	return []string{
+		t.Name, strconv.Itoa(t.Type), t.Key, strconv.Itoa(t.Status), t.BaseURL, t.Models, t.Group, t.Tag,
+		strconv.FormatInt(t.Priority, 10), strconv.FormatUint(uint64(t.Weight), 10), strconv.Itoa(t.AutoBan),
+		t.TestModel, t.OpenAIOrganization, t.ModelMapping, t.StatusCodeMapping, t.Other, t.Setting, t.ParamOverride,
+	}
+}
+
+// parseChannelTransferRecord 按表头解析一行 CSV，缺少的列使用零值
+func parseChannelTransferRecord(header map[string]int, record []string) (*channelTransfer, error) {
+	get := func(column string) string {
+		if i, ok := header[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	getInt := func(column string) (int64, error) {
+		value := get(column)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s 不是有效的整数：%s", column, value)
+		}
+		return n, nil
+	}
+	transfer := &channelTransfer{
+		Name:               get("name"),
+		Key:                get("key"),
+		BaseURL:            get("base_url"),
+		Models:             get("models"),
+		Group:              get("group"),
+		Tag:                get("tag"),
+		TestModel:          get("test_model"),
+		OpenAIOrganization: get("openai_organization"),
+		ModelMapping:       get("model_mapping"),
+		StatusCodeMapping:  get("status_code_mapping"),
+		Other:              get("other"),
+		Setting:            get("setting"),
+		ParamOverride:      get("param_override"),
+	}
+	var values [5]int64
+	for i, column := range []string{"type", "status", "priority", "weight", "auto_ban"} {
+		n, err := getInt(column)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = n
+	}
+	transfer.Type = int(values[0])
+	transfer.Status = int(values[1])
+	transfer.Priority = values[2]
+	transfer.Weight = uint(max(values[3], 0))
+	transfer.AutoBan = int(values[4])
+	if _, ok := header["auto_ban"]; !ok {
+		transfer.AutoBan = 1
+	}
+	return transfer, nil
+}
+
+func validJsonField(name string, value string) error {
+	if value != "" && !common.IsJsonStr(value) {
+		return fmt.Errorf("%s 不是有效的 JSON", name)
+	}
+	return nil
+}
+
+// toChannel 校验导入的渠道并转换为待插入的渠道，加密的密钥解密后保存
+func (t *channelTransfer) toChannel() (*model.Channel, error) {
+	if t.Name == "" {
+		return nil, errors.New("名称不能为空")
+	}
+	if t.Type <= common.ChannelTypeUnknown || t.Type >= common.ChannelTypeDummy {
+		return nil, fmt.Errorf("无效的渠道类型：%d", t.Type)
+	}
+	if t.Key == "" {
+		return nil, errors.New("密钥不能为空")
+	}
+	key, err := common.DecryptString(t.Key)
+	if err != nil {
+		return nil, errors.New("无法解密密钥，请确认两个实例的 CRYPTO_SECRET 一致")
+	}
+	if t.Models == "" {
+		return nil, errors.New("模型不能为空")
+	}
+	for _, modelName := range strings.Split(t.Models, ",") {
+		if len(modelName) > 255 {
+			return nil, fmt.Errorf("模型名称过长: %s", modelName)
+		}
+	}
+	for name, value := range map[string]string{
+		"model_mapping":       t.ModelMapping,
+		"status_code_mapping": t.StatusCodeMapping,
+		"setting":             t.Setting,
+		"param_override":      t.ParamOverride,
+	} {
+		if err := validJsonField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if t.ModelMapping != "" {
+		if _, err := helper.ParseModelMapping(t.ModelMapping); err != nil {
+			return nil, err
+		}
+	}
+	if t.Type == common.ChannelTypeVertexAi {
+		if t.Other == "" {
+			return nil, errors.New("部署地区不能为空")
+		}
+		if common.IsJsonStr(t.Other) && common.StrToMap(t.Other)["default"] == nil {
+			return nil, errors.New("部署地区必须包含default字段")
+		}
+	}
+
+	status := t.Status
+	if status == 0 {
+		status = common.ChannelStatusEnabled
+	}
+	group := t.Group
+	if group == "" {
+		group = "default"
+	}
+	channel := &model.Channel{
+		Type:              t.Type,
+		Key:               key,
+		Status:            status,
+		Name:              t.Name,
+		Weight:            common.GetPointer(t.Weight),
+		CreatedTime:       common.GetTimestamp(),
+		BaseURL:           common.GetPointer(t.BaseURL),
+		Other:             t.Other,
+		Models:            t.Models,
+		Group:             group,
+		ModelMapping:      common.GetPointer(t.ModelMapping),
+		StatusCodeMapping: common.GetPointer(t.StatusCodeMapping),
+		Priority:          common.GetPointer(t.Priority),
+		AutoBan:           common.GetPointer(t.AutoBan),
+	}
+	if t.Tag != "" {
+		channel.Tag = common.GetPointer(t.Tag)
+	}
+	if t.TestModel != "" {
+		channel.TestModel = common.GetPointer(t.TestModel)
+	}
+	if t.OpenAIOrganization != "" {
+		channel.OpenAIOrganization = common.GetPointer(t.OpenAIOrganization)
+	}
+	if t.Setting != "" {
+		channel.Setting = common.GetPointer(t.Setting)
+	}
+	if t.ParamOverride != "" {
+		channel.ParamOverride = common.GetPointer(t.ParamOverride)
+	}
+	if err := channel.PrepareTLSSetting(); err != nil {
+		return nil, err
+	}
+	if err := relaycommon.ValidateParamOverride(channel.GetParamOverride()); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// ExportChannels 导出所有渠道，format 为 json（默认）或 csv；secrets 为 none（默认，不含密钥）或 encrypted（加密后的密钥，仅超级管理员且配置了 CRYPTO_SECRET 时可用）
+func ExportChannels(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	secrets := c.DefaultQuery("secrets", channelExportSecretsNone)
+	if secrets != channelExportSecretsNone && secrets != channelExportSecretsEncrypted {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "secrets 只能为 none 或 encrypted",
+		})
+		return
+	}
+	if secrets == channelExportSecretsEncrypted && c.GetInt("role") < common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅超级管理员可以导出渠道密钥",
+		})
+		return
+	}
+	if secrets == channelExportSecretsEncrypted && !common.CryptoSecretConfigured {
+		// 未配置固定密钥时加密使用的是进程随机密钥，导出的密钥在重启后与其他实例上都无法解密
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": common.ErrCryptoSecretNotConfigured.Error(),
+		})
+		return
+	}
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	transfers := make([]*channelTransfer, 0, len(channels))
+	for _, channel := range channels {
+		transfer, err := newChannelTransfer(channel, secrets)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("导出渠道 #%d 失败：%s", channel.Id, err.Error()),
+			})
+			return
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	filename := "channels-" + time.Now().Format("20060102150405")
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		writer := csv.NewWriter(c.Writer)
+		_ = writer.Write(channelTransferColumns)
+		for _, transfer := range transfers {
+			_ = writer.Write(transfer.record())
+		}
+		writer.Flush()
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, transfers)
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "format 只能为 json 或 csv",
+		})
+	}
+}
+
+func readChannelTransfers(c *gin.Context) ([]*channelTransfer, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(c.ContentType(), "text/csv") {
+		var transfers []*channelTransfer
+		if err := common.DecodeJson(body, &transfers); err != nil {
+			return nil, fmt.Errorf("无法解析 JSON：%s", err.Error())
+		}
+		return transfers, nil
+	}
+	reader := csv.NewReader(strings.NewReader(string(body)))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("无法解析 CSV：%s", err.Error())
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := make(map[string]int, len(records[0]))
+	for i, column := range records[0] {
+		header[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	transfers := make([]*channelTransfer, 0, len(records)-1)
+	for i, record := range records[1:] {
+		transfer, err := parseChannelTransferRecord(header, record)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行：%s", i+2, err.Error())
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, nil
+}
+
+type channelImportError struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// ImportChannels 导入 ExportChannels 导出的 JSON（默认）或 CSV（Content-Type: text/csv），
+// dry_run=true 时只校验不写入；任意渠道校验失败时不导入任何渠道
+func ImportChannels(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	transfers, err := readChannelTransfers(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	channels := make([]model.Channel, 0, len(transfers))
+	importErrors := make([]channelImportError, 0)
+	for i, transfer := range transfers {
+		if transfer == nil {
+			importErrors = append(importErrors, channelImportError{Index: i, Message: "渠道不能为空"})
+			continue
+		}
+		channel, err := transfer.toChannel()
+		if err != nil {
+			importErrors = append(importErrors, channelImportError{Index: i, Name: transfer.Name, Message: err.Error()})
+			continue
+		}
+		channels = append(channels, *channel)
+	}
+	data := gin.H{
+		"total":    len(transfers),
+		"valid":    len(channels),
+		"imported": 0,
+		"dry_run":  dryRun,
+		"errors":   importErrors,
+	}
+	if dryRun || len(importErrors) > 0 || len(channels) == 0 {
+		message := ""
+		if !dryRun && len(importErrors) > 0 {
+			message = "存在校验失败的渠道，未导入任何渠道"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": dryRun || len(importErrors) == 0,
+			"message": message,
+			"data":    data,
+		})
+		return
+	}
+	if err := model.BatchInsertChannels(channels); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    data,
+		})
+		return
+	}
+	data["imported"] = len(channels)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
@@ -6,6 +6,9 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
 	"strconv"
 	"strings"
 
@@ -250,6 +253,29 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	if err := channel.PrepareTLSSetting(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := relaycommon.ValidateParamOverride(channel.GetParamOverride()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if modelMapping := channel.GetModelMapping(); modelMapping != "" {
+		if _, err := helper.ParseModelMapping(modelMapping); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	channel.CreatedTime = common.GetTimestamp()
 	keys := strings.Split(channel.Key, "\n")
 	if channel.Type == common.ChannelTypeVertexAi {
@@ -320,6 +346,7 @@ func DeleteChannel(c *gin.Context) {
 		})
 		return
 	}
+	service.InvalidateChannelHttpClient(id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -459,6 +486,9 @@ func DeleteChannelBatch(c *gin.Context) {
 		})
 		return
 	}
+	for _, id := range channelBatch.Ids {
+		service.InvalidateChannelHttpClient(id)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -498,6 +528,29 @@ func UpdateChannel(c *gin.Context) {
 			}
 		}
 	}
+	if err := channel.PrepareTLSSetting(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := relaycommon.ValidateParamOverride(channel.GetParamOverride()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if modelMapping := channel.GetModelMapping(); modelMapping != "" {
+		if _, err := helper.ParseModelMapping(modelMapping); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	err = channel.Update()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -506,6 +559,7 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	service.InvalidateChannelHttpClient(channel.Id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -620,3 +674,23 @@ func BatchSetChannelTag(c *gin.Context) {
 	})
 	return
 }
+
+func GetChannelModelStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetChannelModelStats(),
+	})
+}
+
+func GetConcurrencyStats(c *gin.Context) {
+	global, channels := service.GetConcurrencyStats()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"global":   global,
+			"channels": channels,
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/relay/channel/gemini"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func cachedContentError(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, c.GetString(common.RequestIdKey))
+	c.JSON(openaiErr.StatusCode, gin.H{
+		"error": openaiErr.Error,
+	})
+}
+
+// CreateGeminiCachedContent 创建 Gemini 上下文缓存，请求体为 Gemini cachedContents 格式，model 使用网关的模型名，
+// 返回上游的 cachedContent 对象，对话请求通过 cached_content 字段引用其 name
+func CreateGeminiCachedContent(c *gin.Context) {
+	responseBody, openaiErr := relay.GeminiCachedContentCreate(c)
+	if openaiErr != nil {
+		if !openaiErr.LocalError {
+			common.LogError(c, fmt.Sprintf("create cached content error (channel #%d, status code %d): %s", c.GetInt("channel_id"), openaiErr.StatusCode, openaiErr.Error.Message))
+		}
+		cachedContentError(c, openaiErr)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", responseBody)
+}
+
+// ListGeminiCachedContents 列出当前用户未过期的上下文缓存
+func ListGeminiCachedContents(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	caches, total, err := model.GetUserGeminiCachedContents(c.GetInt("id"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		cachedContentError(c, service.OpenAIErrorWrapperLocal(err, "get_cached_contents_failed", http.StatusInternalServerError))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   caches,
+		"total":  total,
+	})
+}
+
+// DeleteGeminiCachedContent 删除当前用户的上下文缓存，同时删除渠道上的缓存
+func DeleteGeminiCachedContent(c *gin.Context) {
+	name := dto.GeminiCachedContentName(c.Param("id"))
+	cache, err := model.GetGeminiCachedContent(c.GetInt("id"), name)
+	if err != nil {
+		cachedContentError(c, service.OpenAIErrorWrapperLocal(fmt.Errorf("上下文缓存 %s 不存在", name), "cached_content_not_found", http.StatusNotFound))
+		return
+	}
+	// 缓存已过期时上游已自动删除
+	if channel, err := model.GetChannelById(cache.ChannelId, true); err == nil && !cache.IsExpired() {
+		baseUrl := channel.GetBaseURL()
+		if baseUrl == "" {
+			baseUrl = common.ChannelBaseURLs[channel.Type]
+		}
+		if err := gemini.DeleteCachedContent(baseUrl, channel.Key, cache.Name); err != nil {
+			cachedContentError(c, service.OpenAIErrorWrapper(err, "delete_cached_content_failed", http.StatusInternalServerError))
+			return
+		}
+	}
+	if err := cache.Delete(); err != nil {
+		cachedContentError(c, service.OpenAIErrorWrapperLocal(err, "delete_cached_content_failed", http.StatusInternalServerError))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      cache.Name,
+		"object":  "cached_content",
+		"deleted": true,
+	})
+}
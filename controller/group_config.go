@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 避免并发修改分组配置时互相覆盖
+var groupConfigLock sync.Mutex
+
+type groupConfig struct {
+	Name  string  `json:"name"`
+	Ratio float64 `json:"ratio"`
+	// Usable 是否出现在用户可选分组中，Desc 为展示给用户的描述
+	Usable bool   `json:"usable"`
+	Desc   string `json:"desc"`
+	// AllowedModels 允许使用的模型，为空表示不限制
+	AllowedModels []string `json:"allowed_models"`
+}
+
+type groupConfigRequest struct {
+	Ratio  *float64 `json:"ratio"`
+	Usable *bool    `json:"usable"`
+	Desc   *string  `json:"desc"`
+	// AllowedModels 传空数组时取消模型限制，不传时保持不变
+	AllowedModels *[]string `json:"allowed_models"`
+}
+
+func getGroupConfigs() map[string]groupConfig {
+	usableGroups := setting.GetUserUsableGroupsCopy()
+	allowedModels := operation_setting.GetGroupModelSetting().AllowedModels
+	configs := make(map[string]groupConfig)
+	for name, ratio := range setting.GetGroupRatioCopy() {
+		desc, usable := usableGroups[name]
+		configs[name] = groupConfig{
+			Name:          name,
+			Ratio:         ratio,
+			Usable:        usable,
+			Desc:          desc,
+			AllowedModels: allowedModels[name],
+		}
+	}
+	return configs
+}
+
+func groupConfigError(c *gin.Context, message string) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": message,
+	})
+}
+
+// saveGroupConfigs 依次写入分组倍率、用户可选分组与允许模型，写入数据库并立即更新内存，其他节点通过选项同步生效
+func saveGroupConfigs(configs map[string]groupConfig) error {
+	groupRatio := make(map[string]float64, len(configs))
+	usableGroups := make(map[string]string)
+	allowedModels := make(map[string][]string)
+	for name, config := range configs {
+		groupRatio[name] = config.Ratio
+		if config.Usable {
+			usableGroups[name] = config.Desc
+		}
+		if len(config.AllowedModels) > 0 {
+			allowedModels[name] = config.AllowedModels
+		}
+	}
+	options := []struct {
+		key   string
+		value any
+	}{
+		{"GroupRatio", groupRatio},
+		{"UserUsableGroups", usableGroups},
+		{"group_model_setting.allowed_models", allowedModels},
+	}
+	for _, option := range options {
+		jsonBytes, err := json.Marshal(option.value)
+		if err != nil {
+			return err
+		}
+		if err := model.UpdateOption(option.key, string(jsonBytes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListGroupConfigs 列出全部分组及其倍率、是否可选与允许模型
+func ListGroupConfigs(c *gin.Context) {
+	configs := getGroupConfigs()
+	groups := make([]groupConfig, 0, len(configs))
+	for _, config := range configs {
+		groups = append(groups, config)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    groups,
+	})
+}
+
+// GetGroupConfig 获取单个分组的配置
+func GetGroupConfig(c *gin.Context) {
+	name := c.Param("name")
+	config, ok := getGroupConfigs()[name]
+	if !ok {
+		groupConfigError(c, fmt.Sprintf("分组 %s 不存在", name))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    config,
+	})
+}
+
+// SaveGroupConfig 新建或修改分组，未传的字段保持不变；新建分组时 ratio 默认为 1
+func SaveGroupConfig(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("name"))
+	if name == "" || len(name) > 64 {
+		groupConfigError(c, "无效的分组名称")
+		return
+	}
+	var req groupConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		groupConfigError(c, "无效的参数")
+		return
+	}
+	if req.Ratio != nil && (math.IsNaN(*req.Ratio) || math.IsInf(*req.Ratio, 0) || *req.Ratio < 0) {
+		groupConfigError(c, fmt.Sprintf("分组倍率无效：%v", *req.Ratio))
+		return
+	}
+	if req.AllowedModels != nil {
+		for _, modelName := range *req.AllowedModels {
+			if strings.TrimSpace(modelName) == "" {
+				groupConfigError(c, "允许的模型不能为空")
+				return
+			}
+		}
+	}
+
+	groupConfigLock.Lock()
+	defer groupConfigLock.Unlock()
+	configs := getGroupConfigs()
+	config, exists := configs[name]
+	if !exists {
+		config = groupConfig{Name: name, Ratio: 1}
+	}
+	if req.Ratio != nil {
+		config.Ratio = *req.Ratio
+	}
+	if req.Usable != nil {
+		config.Usable = *req.Usable
+	}
+	if req.Desc != nil {
+		config.Desc = *req.Desc
+	}
+	if config.Usable && config.Desc == "" {
+		config.Desc = name
+	}
+	if req.AllowedModels != nil {
+		config.AllowedModels = *req.AllowedModels
+	}
+	configs[name] = config
+	if err := saveGroupConfigs(configs); err != nil {
+		groupConfigError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    config,
+	})
+}
+
+// DeleteGroupConfig 删除分组，仍有用户、令牌或渠道使用该分组时拒绝删除
+func DeleteGroupConfig(c *gin.Context) {
+	name := c.Param("name")
+	if name == "default" {
+		groupConfigError(c, "不能删除默认分组")
+		return
+	}
+	if reason, err := groupInUseReason(name); err != nil {
+		groupConfigError(c, err.Error())
+		return
+	} else if reason != "" {
+		groupConfigError(c, fmt.Sprintf("分组 %s 仍被 %s 使用，请先迁移后再删除", name, reason))
+		return
+	}
+	groupConfigLock.Lock()
+	defer groupConfigLock.Unlock()
+	configs := getGroupConfigs()
+	if _, ok := configs[name]; !ok {
+		groupConfigError(c, fmt.Sprintf("分组 %s 不存在", name))
+		return
+	}
+	delete(configs, name)
+	if err := saveGroupConfigs(configs); err != nil {
+		groupConfigError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// groupInUseReason 返回仍在使用该分组的对象说明，未被使用时返回空字符串
+func groupInUseReason(name string) (string, error) {
+	users, err := model.CountGroupUsers(name)
+	if err != nil {
+		return "", err
+	}
+	tokens, err := model.CountGroupTokens(name)
+	if err != nil {
+		return "", err
+	}
+	channels, err := model.CountGroupChannels(name)
+	if err != nil {
+		return "", err
+	}
+	var reasons []string
+	if users > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d 个用户", users))
+	}
+	if tokens > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d 个令牌", tokens))
+	}
+	if channels > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d 个渠道", channels))
+	}
+	return strings.Join(reasons, "、"), nil
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+type ldapUser struct {
+	DN          string
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+// ldapConnect 建立连接并以服务账号绑定，未配置服务账号时使用匿名查询
+func ldapConnect() (*ldap.Conn, error) {
+	settings := system_setting.GetLDAPSettings()
+	timeout := time.Duration(settings.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify}
+	conn, err := ldap.DialURL(settings.Url, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if settings.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if settings.BindDN != "" {
+		if err = conn.Bind(settings.BindDN, settings.BindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// ldapSearchUser 按用户名查找唯一的目录条目，未找到时返回 nil
+func ldapSearchUser(conn *ldap.Conn, username string) (*ldapUser, error) {
+	settings := system_setting.GetLDAPSettings()
+	request := ldap.NewSearchRequest(settings.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(settings.UserFilter, ldap.EscapeFilter(username)),
+		[]string{settings.UsernameAttribute, settings.EmailAttribute, settings.DisplayNameAttribute, settings.GroupAttribute}, nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, nil
+	}
+	if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("LDAP 中存在多个用户名为 %s 的用户", username)
+	}
+	entry := result.Entries[0]
+	user := &ldapUser{
+		DN:          entry.DN,
+		Username:    entry.GetAttributeValue(settings.UsernameAttribute),
+		Email:       entry.GetAttributeValue(settings.EmailAttribute),
+		DisplayName: entry.GetAttributeValue(settings.DisplayNameAttribute),
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+	for _, value := range entry.GetAttributeValues(settings.GroupAttribute) {
+		user.Groups = append(user.Groups, value)
+		// memberOf 等属性的值为分组 DN，同时允许按 CN 配置映射
+		if dn, err := ldap.ParseDN(value); err == nil && len(dn.RDNs) > 0 {
+			for _, attr := range dn.RDNs[0].Attributes {
+				if strings.EqualFold(attr.Type, "cn") {
+					user.Groups = append(user.Groups, attr.Value)
+				}
+			}
+		}
+	}
+	return user, nil
+}
+
+// ldapAuthenticate 查找用户后以其 DN 和密码绑定校验
+func ldapAuthenticate(username string, password string) (*ldapUser, error) {
+	if username == "" || password == "" {
+		// 空密码会被视为匿名绑定而“成功”，必须拒绝
+		return nil, errors.New("用户名或密码为空")
+	}
+	conn, err := ldapConnect()
+	if err != nil {
+		common.SysError("failed to connect to ldap: " + err.Error())
+		return nil, errors.New("无法连接至 LDAP 服务器，请稍后重试！")
+	}
+	defer conn.Close()
+	user, err := ldapSearchUser(conn, username)
+	if err != nil {
+		common.SysError("failed to search ldap user: " + err.Error())
+		return nil, errors.New("LDAP 查询用户失败")
+	}
+	if user == nil {
+		return nil, errors.New("用户名或密码错误，或用户已被封禁")
+	}
+	if err = conn.Bind(user.DN, password); err != nil {
+		return nil, errors.New("用户名或密码错误，或用户已被封禁")
+	}
+	return user, nil
+}
+
+// loginWithLdap 校验 LDAP 账户并返回对应的本地用户，开启自动注册时首次登录会创建用户
+func loginWithLdap(username string, password string) (*model.User, error) {
+	settings := system_setting.GetLDAPSettings()
+	entry, err := ldapAuthenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+	role, group := resolveSSOMapping(entry.Groups, settings.RoleMapping, settings.GroupMapping)
+	user := model.User{
+		LdapId: entry.Username,
+	}
+	if model.IsLdapIdAlreadyTaken(user.LdapId) {
+		if err = user.FillUserByLdapId(); err != nil {
+			return nil, err
+		}
+		if applySSOMapping(&user, role, group) {
+			if err = user.UpdateSyncedFields(); err != nil {
+				common.SysError("failed to sync ldap user role and group: " + err.Error())
+			}
+		}
+	} else {
+		if !settings.AutoRegister {
+			return nil, errors.New("该 LDAP 账户未关联本地用户，请联系管理员")
+		}
+		user.Username = entry.Username
+		if exist, err := model.CheckUserExistOrDeleted(user.Username, ""); err != nil || exist {
+			user.Username = "ldap_" + strconv.Itoa(model.GetMaxUserId()+1)
+		}
+		user.Email = entry.Email
+		user.DisplayName = entry.DisplayName
+		if user.DisplayName == "" {
+			user.DisplayName = "LDAP User"
+		}
+		applySSOMapping(&user, role, group)
+		if err = user.Insert(0); err != nil {
+			return nil, err
+		}
+	}
+	if user.Status != common.UserStatusEnabled {
+		return nil, errors.New("用户已被封禁")
+	}
+	return &user, nil
+}
+
+// syncLdapUsers 同步所有关联了 LDAP 的用户，目录中已不存在的用户会被禁用
+func syncLdapUsers() error {
+	settings := system_setting.GetLDAPSettings()
+	users, err := model.GetLdapUsers()
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	conn, err := ldapConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	disabled, updated := 0, 0
+	for _, user := range users {
+		entry, err := ldapSearchUser(conn, user.LdapId)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to sync ldap user %s: %s", user.LdapId, err.Error()))
+			continue
+		}
+		if entry == nil {
+			if user.Status == common.UserStatusEnabled && user.Role != common.RoleRootUser {
+				user.Status = common.UserStatusDisabled
+				if err = user.UpdateSyncedFields(); err == nil {
+					disabled++
+				}
+			}
+			continue
+		}
+		// 被移出管理员分组的用户会降级为普通用户
+		role, group := resolveSSOMapping(entry.Groups, settings.RoleMapping, settings.GroupMapping)
+		changed := applySSOMapping(user, role, group)
+		if entry.Email != "" && entry.Email != user.Email {
+			user.Email = entry.Email
+			changed = true
+		}
+		if entry.DisplayName != "" && entry.DisplayName != user.DisplayName {
+			user.DisplayName = entry.DisplayName
+			changed = true
+		}
+		if changed {
+			if err = user.UpdateSyncedFields(); err == nil {
+				updated++
+			}
+		}
+	}
+	if disabled > 0 || updated > 0 {
+		common.SysLog(fmt.Sprintf("ldap sync finished, %d users updated, %d users disabled", updated, disabled))
+	}
+	return nil
+}
+
+// AutomaticallySyncLdapUsers 按配置的间隔同步 LDAP 用户
+func AutomaticallySyncLdapUsers() {
+	for {
+		settings := system_setting.GetLDAPSettings()
+		if !settings.Enabled || settings.SyncIntervalMinutes <= 0 {
+			time.Sleep(time.Minute)
+			continue
+		}
+		time.Sleep(time.Duration(settings.SyncIntervalMinutes) * time.Minute)
+		if err := syncLdapUsers(); err != nil {
+			common.SysError("failed to sync ldap users: " + err.Error())
+		}
+	}
+}
@@ -59,6 +59,8 @@ func GetStatus(c *gin.Context) {
 			"docs_link":                   operation_setting.GetGeneralSetting().DocsLink,
 			"quota_per_unit":              common.QuotaPerUnit,
 			"display_in_currency":         common.DisplayInCurrencyEnabled,
+			"exchange_rates":              operation_setting.GetExchangeRates(),
+			"default_display_currency":    operation_setting.GetCurrencySetting().DefaultDisplayCurrency,
 			"enable_batch_update":         common.BatchUpdateEnabled,
 			"enable_drawing":              common.DrawingEnabled,
 			"enable_task":                 common.TaskEnabled,
@@ -15,6 +15,7 @@ import (
 	"one-api/relay/channel/moonshot"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
+	"strings"
 )
 
 // https://platform.openai.com/docs/api-reference/models/list
@@ -149,7 +150,12 @@ func ListModels(c *gin.Context) {
 		} else {
 			tokenModelLimit = map[string]bool{}
 		}
+		hasPattern := false
 		for allowModel, _ := range tokenModelLimit {
+			if strings.HasSuffix(allowModel, "*") {
+				hasPattern = true
+				continue
+			}
 			if _, ok := openAIModelsMap[allowModel]; ok {
 				userOpenAiModels = append(userOpenAiModels, openAIModelsMap[allowModel])
 			} else {
@@ -164,6 +170,31 @@ func ListModels(c *gin.Context) {
 				})
 			}
 		}
+		// 前缀匹配的限制项展开为令牌分组内匹配的模型
+		if hasPattern {
+			group := c.GetString("token_group")
+			if group == "" {
+				group, _ = model.GetUserGroup(c.GetInt("id"), true)
+			}
+			for _, s := range model.GetGroupModels(group) {
+				if tokenModelLimit[s] || !model.ModelLimitsAllow(tokenModelLimit, s) {
+					continue
+				}
+				if _, ok := openAIModelsMap[s]; ok {
+					userOpenAiModels = append(userOpenAiModels, openAIModelsMap[s])
+				} else {
+					userOpenAiModels = append(userOpenAiModels, dto.OpenAIModels{
+						Id:         s,
+						Object:     "model",
+						Created:    1626777600,
+						OwnedBy:    "custom",
+						Permission: permission,
+						Root:       s,
+						Parent:     nil,
+					})
+				}
+			}
+		}
 	} else {
 		userId := c.GetInt("id")
 		userGroup, err := model.GetUserGroup(userId, true)
@@ -1,9 +1,11 @@
 package controller
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"one-api/common"
@@ -28,11 +30,53 @@ type OidcResponse struct {
 }
 
 type OidcUser struct {
-	OpenID            string `json:"sub"`
-	Email             string `json:"email"`
-	Name              string `json:"name"`
-	PreferredUsername string `json:"preferred_username"`
-	Picture           string `json:"picture"`
+	OpenID            string   `json:"sub"`
+	Email             string   `json:"email"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+	Groups            []string `json:"-"`
+}
+
+// getOidcGroups 从用户信息中读取分组字段，取不到时再从 ID Token 中读取；
+// ID Token 直接由 Token 端点返回，这里只解析其中的声明，不再校验签名
+func getOidcGroups(userInfo map[string]any, idToken string) []string {
+	claim := system_setting.GetOIDCSettings().GroupsClaim
+	if claim == "" {
+		return nil
+	}
+	if groups := parseOidcGroupsClaim(userInfo[claim]); len(groups) > 0 {
+		return groups
+	}
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims map[string]any
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return parseOidcGroupsClaim(claims[claim])
+}
+
+func parseOidcGroupsClaim(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(strings.ReplaceAll(v, ",", " "))
+	case []any:
+		groups := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
 }
 
 func getOidcUserInfoByCode(code string) (*OidcUser, error) {
@@ -89,11 +133,18 @@ func getOidcUserInfoByCode(code string) (*OidcUser, error) {
 		return nil, errors.New("OIDC 获取用户信息失败！请检查设置！")
 	}
 
+	body, err := io.ReadAll(res2.Body)
+	if err != nil {
+		return nil, err
+	}
 	var oidcUser OidcUser
-	err = json.NewDecoder(res2.Body).Decode(&oidcUser)
+	err = json.Unmarshal(body, &oidcUser)
 	if err != nil {
 		return nil, err
 	}
+	var userInfo map[string]any
+	_ = json.Unmarshal(body, &userInfo)
+	oidcUser.Groups = getOidcGroups(userInfo, oidcResponse.IDToken)
 	if oidcUser.OpenID == "" || oidcUser.Email == "" {
 		common.SysError("OIDC 获取用户信息为空！请检查设置！")
 		return nil, errors.New("OIDC 获取用户信息为空！请检查设置！")
@@ -132,6 +183,8 @@ func OidcAuth(c *gin.Context) {
 		})
 		return
 	}
+	oidcSettings := system_setting.GetOIDCSettings()
+	role, group := resolveSSOMapping(oidcUser.Groups, oidcSettings.RoleMapping, oidcSettings.GroupMapping)
 	user := model.User{
 		OidcId: oidcUser.OpenID,
 	}
@@ -144,6 +197,15 @@ func OidcAuth(c *gin.Context) {
 			})
 			return
 		}
+		// 角色每次登录都按映射同步，分组仅在开启 SyncOnLogin 时同步
+		if !oidcSettings.SyncOnLogin {
+			group = ""
+		}
+		if applySSOMapping(&user, role, group) {
+			if err := user.UpdateSyncedFields(); err != nil {
+				common.SysError("failed to sync oidc user role and group: " + err.Error())
+			}
+		}
 	} else {
 		if common.RegisterEnabled {
 			user.Email = oidcUser.Email
@@ -157,6 +219,7 @@ func OidcAuth(c *gin.Context) {
 			} else {
 				user.DisplayName = "OIDC User"
 			}
+			applySSOMapping(&user, role, group)
 			err := user.Insert(0)
 			if err != nil {
 				c.JSON(http.StatusOK, gin.H{
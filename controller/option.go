@@ -6,6 +6,7 @@ import (
 	"one-api/common"
 	"one-api/model"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/system_setting"
 	"strings"
 
@@ -101,6 +102,14 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "model_fallback_setting.chains":
+		if err = operation_setting.CheckModelFallbackChains(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "GroupRatio":
 		err = setting.CheckGroupRatio(option.Value)
 		if err != nil {
@@ -0,0 +1,359 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizationQuotaRequest struct {
+	// Quota 大于 0 为增加额度，小于 0 为扣除额度
+	Quota  int    `json:"quota"`
+	Reason string `json:"reason"`
+}
+
+type organizationMemberRequest struct {
+	UserId int `json:"user_id"`
+	Role   int `json:"role"`
+}
+
+func organizationError(c *gin.Context, message string) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": message,
+	})
+}
+
+// organizationUsageFilter 将用量查询限定为组织令牌，组织还没有令牌时使用不存在的令牌 id，避免查出全部用量
+func organizationUsageFilter(orgId int, filter model.CostFilter) (model.CostFilter, error) {
+	tokenIds, err := model.GetOrganizationTokenIds(orgId)
+	if err != nil {
+		return filter, err
+	}
+	if len(tokenIds) == 0 {
+		tokenIds = []int{-1}
+	}
+	filter.TokenIds = tokenIds
+	filter.ChannelId = 0
+	return filter, nil
+}
+
+// getSelfOrganizationAdmin 返回当前用户管理的组织 id，不是组织管理员时返回错误
+func getSelfOrganizationAdmin(c *gin.Context) (int, bool) {
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		organizationError(c, err.Error())
+		return 0, false
+	}
+	if user.OrganizationId == 0 || user.OrganizationRole != model.OrganizationRoleAdmin {
+		organizationError(c, "仅组织管理员可以查看")
+		return 0, false
+	}
+	return user.OrganizationId, true
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	orgs, total, err := model.GetAllOrganizations((p-1)*pageSize, pageSize)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items":     orgs,
+			"total":     total,
+			"page":      p,
+			"page_size": pageSize,
+		},
+	})
+}
+
+func GetOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    org,
+	})
+}
+
+// AddOrganization 新建组织，初始额度通过额度调整接口发放
+func AddOrganization(c *gin.Context) {
+	org := model.Organization{}
+	if err := c.ShouldBindJSON(&org); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	org.Id = 0
+	org.Quota = 0
+	org.UsedQuota = 0
+	if org.Status == 0 {
+		org.Status = model.OrganizationStatusEnabled
+	}
+	if err := org.Validate(); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	if err := org.Insert(); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    org,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	org := model.Organization{}
+	if err := c.ShouldBindJSON(&org); err != nil || org.Id == 0 {
+		organizationError(c, "无效的参数")
+		return
+	}
+	if err := org.Validate(); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	if err := org.Update(); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    org,
+	})
+}
+
+// DeleteOrganization 删除组织，成员退出组织，组织令牌被禁用
+func DeleteOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteOrganizationById(id); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// AdjustOrganizationQuota 为组织额度池增加或扣除额度，原因代码记录在管理日志中
+func AdjustOrganizationQuota(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		organizationError(c, "无效的参数")
+		return
+	}
+	if err := model.ValidateQuotaAdjustReason(req.Reason); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	if err := model.AdjustOrganizationQuota(id, req.Quota); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	action := "增加"
+	quota := req.Quota
+	if quota < 0 {
+		action = "扣除"
+		quota = -quota
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage,
+		fmt.Sprintf("管理员为组织 #%d %s额度 %s，原因：%s", id, action, common.LogQuota(quota), req.Reason))
+	org, err := model.GetOrganizationById(id)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    org,
+	})
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	members, err := model.GetOrganizationMembers(id)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+}
+
+// AddOrganizationMember 将用户加入组织，用户已是成员时修改其组织角色
+func AddOrganizationMember(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId == 0 {
+		organizationError(c, "无效的参数")
+		return
+	}
+	if req.Role == 0 {
+		req.Role = model.OrganizationRoleMember
+	}
+	if err := model.AddOrganizationMember(id, req.UserId, req.Role); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RemoveOrganizationMember 将用户移出组织，其名下的组织令牌被禁用
+func RemoveOrganizationMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	if err := model.RemoveOrganizationMember(id, userId); err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetOrganizationUsage 汇总组织令牌的用量，可按模型、令牌、用户或天分组
+func GetOrganizationUsage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	filter, err := organizationUsageFilter(id, parseCostFilter(c))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	respondCostBreakdown(c, filter)
+}
+
+// GetSelfOrganization 返回当前用户所属组织及其组织角色，未加入组织时 data 为空
+func GetSelfOrganization(c *gin.Context) {
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	if user.OrganizationId == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    nil,
+		})
+		return
+	}
+	org, err := model.GetOrganizationById(user.OrganizationId)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"organization": org,
+			"role":         user.OrganizationRole,
+		},
+	})
+}
+
+// GetSelfOrganizationMembers 组织管理员查看组织成员
+func GetSelfOrganizationMembers(c *gin.Context) {
+	orgId, ok := getSelfOrganizationAdmin(c)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(orgId)
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+}
+
+// GetSelfOrganizationUsage 组织管理员查看组织的汇总用量，不能按渠道分组
+func GetSelfOrganizationUsage(c *gin.Context) {
+	if c.DefaultQuery("group_by", model.CostGroupByModel) == model.CostGroupByChannel {
+		organizationError(c, "不支持的分组维度："+model.CostGroupByChannel)
+		return
+	}
+	orgId, ok := getSelfOrganizationAdmin(c)
+	if !ok {
+		return
+	}
+	filter, err := organizationUsageFilter(orgId, parseCostFilter(c))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	respondCostBreakdown(c, filter)
+}
+
+// GetSelfOrganizationSeries 组织管理员查看组织用量的时间序列，不能按渠道分组
+func GetSelfOrganizationSeries(c *gin.Context) {
+	if c.Query("group_by") == model.CostGroupByChannel {
+		organizationError(c, "不支持的分组维度："+model.CostGroupByChannel)
+		return
+	}
+	orgId, ok := getSelfOrganizationAdmin(c)
+	if !ok {
+		return
+	}
+	filter, err := organizationUsageFilter(orgId, parseUsageSeriesFilter(c))
+	if err != nil {
+		organizationError(c, err.Error())
+		return
+	}
+	respondUsageSeries(c, filter)
+}
@@ -9,7 +9,6 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/middleware"
-	"one-api/model"
 	"one-api/service"
 	"one-api/setting"
 	"time"
@@ -20,6 +19,7 @@ func Playground(c *gin.Context) {
 
 	defer func() {
 		if openaiErr != nil {
+			openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, c.GetString(common.RequestIdKey))
 			c.JSON(openaiErr.StatusCode, gin.H{
 				"error": openaiErr.Error,
 			})
@@ -57,7 +57,7 @@ func Playground(c *gin.Context) {
 		c.Set("group", group)
 	}
 	c.Set("token_name", "playground-"+group)
-	channel, err := model.CacheGetRandomSatisfiedChannel(group, playgroundRequest.Model, 0)
+	channel, err := middleware.CacheGetRandomSatisfiedChannel(c, group, playgroundRequest.Model, 0, nil)
 	if err != nil {
 		message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, playgroundRequest.Model)
 		openaiErr = service.OpenAIErrorWrapperLocal(errors.New(message), "get_playground_channel_failed", http.StatusInternalServerError)
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pricingConfig 一类倍率或价格配置对应的选项与当前值
+type pricingConfig struct {
+	option string
+	get    func() string
+}
+
+var pricingConfigs = map[string]pricingConfig{
+	"model_ratio":        {option: "ModelRatio", get: operation_setting.ModelRatio2JSONString},
+	"completion_ratio":   {option: "CompletionRatio", get: operation_setting.CompletionRatio2JSONString},
+	"cache_ratio":        {option: "CacheRatio", get: operation_setting.CacheRatio2JSONString},
+	"create_cache_ratio": {option: "CreateCacheRatio", get: operation_setting.CreateCacheRatio2JSONString},
+	"model_price":        {option: "ModelPrice", get: operation_setting.ModelPrice2JSONString},
+}
+
+// 避免并发修改同一类配置时互相覆盖
+var pricingConfigLock sync.Mutex
+
+type pricingConfigRequest struct {
+	// Values 需要新增或修改的模型及其倍率（或价格）
+	Values map[string]float64 `json:"values"`
+	// Models 需要删除的模型
+	Models []string `json:"models"`
+}
+
+func getPricingConfig(c *gin.Context) (pricingConfig, map[string]float64, bool) {
+	kind := c.Param("kind")
+	config, ok := pricingConfigs[kind]
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("未知的配置类型：%s", kind),
+		})
+		return config, nil, false
+	}
+	values := make(map[string]float64)
+	if err := json.Unmarshal([]byte(config.get()), &values); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return config, nil, false
+	}
+	return config, values, true
+}
+
+func validatePricingValues(values map[string]float64) error {
+	for modelName, value := range values {
+		if modelName == "" || len(modelName) > 255 {
+			return fmt.Errorf("无效的模型名称：%q", modelName)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+			return fmt.Errorf("模型 %s 的值无效：%v", modelName, value)
+		}
+	}
+	return nil
+}
+
+// savePricingConfig 写入数据库并立即更新内存中的倍率，其他节点通过选项同步生效
+func savePricingConfig(c *gin.Context, config pricingConfig, values map[string]float64) {
+	jsonBytes, err := json.Marshal(values)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := model.UpdateOption(config.option, string(jsonBytes)); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.InvalidatePricing()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    values,
+	})
+}
+
+// ListPricingConfigTypes 列出可管理的倍率与价格配置类型
+func ListPricingConfigTypes(c *gin.Context) {
+	kinds := make([]string, 0, len(pricingConfigs))
+	for kind := range pricingConfigs {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    kinds,
+	})
+}
+
+// GetPricingConfig 获取一类配置的全部值，指定 model 时只返回该模型
+func GetPricingConfig(c *gin.Context) {
+	_, values, ok := getPricingConfig(c)
+	if !ok {
+		return
+	}
+	if modelName := c.Query("model"); modelName != "" {
+		value, exists := values[modelName]
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("模型 %s 未配置", modelName),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    gin.H{modelName: value},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    values,
+	})
+}
+
+// UpsertPricingConfig 新增或修改 values 中的模型，其余模型保持不变
+func UpsertPricingConfig(c *gin.Context) {
+	var req pricingConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Values) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if err := validatePricingValues(req.Values); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pricingConfigLock.Lock()
+	defer pricingConfigLock.Unlock()
+	config, values, ok := getPricingConfig(c)
+	if !ok {
+		return
+	}
+	for modelName, value := range req.Values {
+		values[modelName] = value
+	}
+	savePricingConfig(c, config, values)
+}
+
+// ReplacePricingConfig 使用 values 整体替换一类配置
+func ReplacePricingConfig(c *gin.Context) {
+	var req pricingConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Values == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if err := validatePricingValues(req.Values); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pricingConfigLock.Lock()
+	defer pricingConfigLock.Unlock()
+	config, _, ok := getPricingConfig(c)
+	if !ok {
+		return
+	}
+	savePricingConfig(c, config, req.Values)
+}
+
+// DeletePricingConfig 删除 models 中的模型，删除后按默认倍率（或未设置价格）计费
+func DeletePricingConfig(c *gin.Context) {
+	var req pricingConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Models) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	pricingConfigLock.Lock()
+	defer pricingConfigLock.Unlock()
+	config, values, ok := getPricingConfig(c)
+	if !ok {
+		return
+	}
+	for _, modelName := range req.Models {
+		delete(values, modelName)
+	}
+	savePricingConfig(c, config, values)
+}
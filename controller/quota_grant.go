@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次批量调整的用户数上限
+const maxBatchQuotaUsers = 1000
+
+type BatchQuotaRequest struct {
+	UserIds []int `json:"user_ids"`
+	// Quota 大于 0 为增加额度，小于 0 为扣除额度
+	Quota  int    `json:"quota"`
+	Reason string `json:"reason"`
+}
+
+// BatchAdjustUserQuota 为一批用户增加或扣除额度，逐个处理并返回每个用户的结果，原因代码记录在管理日志中
+func BatchAdjustUserQuota(c *gin.Context) {
+	var req BatchQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UserIds) == 0 || req.Quota == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if len(req.UserIds) > maxBatchQuotaUsers {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "单次最多调整 " + strconv.Itoa(maxBatchQuotaUsers) + " 个用户",
+		})
+		return
+	}
+	if err := model.ValidateQuotaAdjustReason(req.Reason); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	operatorId := c.GetInt("id")
+	results := make([]model.QuotaAdjustResult, 0, len(req.UserIds))
+	succeeded := 0
+	seen := make(map[int]bool, len(req.UserIds))
+	for _, userId := range req.UserIds {
+		if seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		result := model.QuotaAdjustResult{UserId: userId}
+		user, err := model.GetUserById(userId, false)
+		if err != nil {
+			result.Message = err.Error()
+		} else if myRole <= user.Role && myRole != common.RoleRootUser {
+			result.Message = "无权调整同权限等级或更高权限等级用户的额度"
+		} else if err := model.AdjustUserQuota(userId, req.Quota, req.Reason, operatorId); err != nil {
+			result.Message = err.Error()
+		} else {
+			result.Success = true
+			succeeded++
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+			"results":   results,
+		},
+	})
+}
+
+func GetAllQuotaGrantSchedules(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	schedules, total, err := model.GetAllQuotaGrantSchedules((p-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items":     schedules,
+			"total":     total,
+			"page":      p,
+			"page_size": pageSize,
+		},
+	})
+}
+
+func GetQuotaGrantSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	schedule, err := model.GetQuotaGrantScheduleById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    schedule,
+	})
+}
+
+func AddQuotaGrantSchedule(c *gin.Context) {
+	schedule := model.QuotaGrantSchedule{}
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	schedule.Id = 0
+	schedule.LastRunTime = 0
+	if schedule.Status == 0 {
+		schedule.Status = model.QuotaGrantScheduleStatusEnabled
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	schedule.OperatorId = c.GetInt("id")
+	schedule.OperatorRole = c.GetInt("role")
+	if err := schedule.CheckOperatorRole(schedule.OperatorRole); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := schedule.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    schedule,
+	})
+}
+
+func UpdateQuotaGrantSchedule(c *gin.Context) {
+	schedule := model.QuotaGrantSchedule{}
+	if err := c.ShouldBindJSON(&schedule); err != nil || schedule.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	schedule.OperatorId = c.GetInt("id")
+	schedule.OperatorRole = c.GetInt("role")
+	if err := schedule.CheckOperatorRole(schedule.OperatorRole); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := schedule.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    schedule,
+	})
+}
+
+func DeleteQuotaGrantSchedule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteQuotaGrantScheduleById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RunQuotaGrantSchedule 立即执行一次发放计划，不影响下一次定期发放的时间
+func RunQuotaGrantSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	schedule, err := model.GetQuotaGrantScheduleById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := schedule.CheckOperatorRole(c.GetInt("role")); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	granted, err := model.RunQuotaGrantSchedule(schedule)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"granted": granted,
+		},
+	})
+}
@@ -126,6 +126,15 @@ func AddRedemption(c *gin.Context) {
 		})
 		return
 	}
+	if redemption.PackageId != 0 {
+		if _, err := model.GetPackageById(redemption.PackageId); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "套餐不存在",
+			})
+			return
+		}
+	}
 	var keys []string
 	for i := 0; i < redemption.Count; i++ {
 		key := common.GetUUID()
@@ -135,6 +144,7 @@ func AddRedemption(c *gin.Context) {
 			Key:         key,
 			CreatedTime: common.GetTimestamp(),
 			Quota:       redemption.Quota,
+			PackageId:   redemption.PackageId,
 		}
 		err = cleanRedemption.Insert()
 		if err != nil {
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	constant2 "one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未配置 hedge_delay_ms 时的默认对冲等待时间
+const defaultHedgeDelay = 1000 * time.Millisecond
+
+type hedgeAttempt struct {
+	index   int
+	channel *model.Channel
+	ctx     *gin.Context
+	writer  *helper.HedgeWriter
+	cancel  context.CancelFunc
+	err     *dto.OpenAIErrorWithStatusCode
+}
+
+// getHedgeDelay 令牌开启对冲模式时返回对冲等待时间，仅对文本对话请求生效
+func getHedgeDelay(c *gin.Context, relayMode int) (time.Duration, bool) {
+	if relayMode != relayconstant.RelayModeChatCompletions && relayMode != relayconstant.RelayModeCompletions {
+		return 0, false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return 0, false
+	}
+	tokenSetting := c.GetStringMap(constant2.ContextKeyTokenSetting)
+	if enabled, _ := tokenSetting[constant2.TokenSettingHedgeEnabled].(bool); !enabled {
+		return 0, false
+	}
+	delay := defaultHedgeDelay
+	if delayMs, ok := tokenSetting[constant2.TokenSettingHedgeDelayMs].(float64); ok && delayMs > 0 {
+		delay = time.Duration(delayMs) * time.Millisecond
+	}
+	return delay, true
+}
+
+func newHedgeAttempt(c *gin.Context, race *helper.HedgeRace, index int, channel *model.Channel) *hedgeAttempt {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	cp := c.Copy()
+	cp.Request = c.Request.Clone(ctx)
+	writer := helper.NewHedgeWriter(race, index)
+	cp.Writer = writer
+	cp.Set(constant2.ContextKeyHedgeLost, writer.Lost())
+	if index > 0 {
+		// 对冲副本不重复计入 RPM/TPM
+		cp.Set(constant2.ContextKeyRateLimitChecked, true)
+	}
+	return &hedgeAttempt{
+		index:   index,
+		channel: channel,
+		ctx:     cp,
+		writer:  writer,
+		cancel:  cancel,
+	}
+}
+
+func (a *hedgeAttempt) run(relayMode int, done chan<- *hedgeAttempt) {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysError(fmt.Sprintf("hedged request panic: %v", r))
+			a.err = service.OpenAIErrorWrapperLocal(fmt.Errorf("panic: %v", r), "hedge_panic", http.StatusInternalServerError)
+		}
+		done <- a
+	}()
+	a.err = relayRequest(a.ctx, relayMode, a.channel)
+}
+
+// abandon 取消落败副本的上游请求，并标记其不计费
+func (a *hedgeAttempt) abandon() {
+	a.writer.Lost().Store(true)
+	a.cancel()
+}
+
+// hedgeRelayRequest 向首个渠道发起请求，若超过 delay 仍未返回首字节，则向另一个渠道发起相同请求，
+// 使用先返回首字节的结果并取消另一个请求，只有获胜的请求会计费
+func hedgeRelayRequest(c *gin.Context, relayMode int, channel *model.Channel, group, originalModel string, delay time.Duration) (*model.Channel, *dto.OpenAIErrorWithStatusCode) {
+	race := helper.NewHedgeRace(c.Writer)
+	done := make(chan *hedgeAttempt, 2)
+	attempts := []*hedgeAttempt{newHedgeAttempt(c, race, 0, channel)}
+	addUsedChannel(c, channel.Id)
+	go attempts[0].run(relayMode, done)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	timerC := timer.C
+	wonC := race.Won()
+
+	var failed []*hedgeAttempt
+	for pending > 0 {
+		select {
+		case a := <-done:
+			pending--
+			a.cancel()
+			winner := race.Winner()
+			if winner == a.index || (winner == -1 && a.err == nil) {
+				for _, other := range attempts {
+					if other != a {
+						other.abandon()
+					}
+				}
+				reportHedgeFailures(failed)
+				return a.channel, a.err
+			}
+			// 被取消的落败副本不计入渠道错误
+			if !a.writer.Lost().Load() {
+				failed = append(failed, a)
+			}
+		case <-wonC:
+			wonC = nil
+			timerC = nil
+			for _, a := range attempts {
+				if a.index != race.Winner() {
+					a.abandon()
+				}
+			}
+		case <-timerC:
+			timerC = nil
+			if race.Winner() != -1 || pending == 0 {
+				continue
+			}
+			hedgeChannel, err := middleware.CacheGetRandomSatisfiedChannel(c, group, originalModel, 0, map[int]bool{channel.Id: true})
+			if err != nil {
+				common.LogWarn(c, fmt.Sprintf("no channel available for hedged request: %s", err.Error()))
+				continue
+			}
+			hedge := newHedgeAttempt(c, race, len(attempts), hedgeChannel)
+			middleware.SetupContextForSelectedChannel(hedge.ctx, hedgeChannel, originalModel)
+			attempts = append(attempts, hedge)
+			addUsedChannel(c, hedgeChannel.Id)
+			common.LogInfo(c, fmt.Sprintf("first byte not received in %s, hedging with channel #%d", delay, hedgeChannel.Id))
+			go hedge.run(relayMode, done)
+			pending++
+		}
+	}
+	// 所有副本均在输出前失败，交由外层重试逻辑处理
+	last := failed[len(failed)-1]
+	reportHedgeFailures(failed[:len(failed)-1])
+	return last.channel, last.err
+}
+
+// reportHedgeFailures 处理未返回给外层的失败副本的渠道错误
+func reportHedgeFailures(failed []*hedgeAttempt) {
+	for _, a := range failed {
+		go processChannelError(a.ctx, a.channel.Id, a.channel.Type, a.channel.Name, a.channel.GetAutoBan(), a.err)
+	}
+}
@@ -13,11 +13,15 @@ import (
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
+	relaycommon "one-api/relay/common"
 	"one-api/relay/constant"
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -25,7 +29,7 @@ import (
 
 func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	var err *dto.OpenAIErrorWithStatusCode
-	common.LogInfo(c, fmt.Sprintf("relayHandler relayMode: %d", relayMode))
+	common.LogDebug(c, fmt.Sprintf("relayHandler relayMode: %d", relayMode))
 	switch relayMode {
 	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
 		err = relay.ImageHelper(c)
@@ -74,25 +78,55 @@ func Relay(c *gin.Context) {
 	originalModel := c.GetString("original_model")
 	var openaiErr *dto.OpenAIErrorWithStatusCode
 
-	common.LogInfo(c, fmt.Sprintf("Relay relayMode: %d", relayMode))
-	for i := 0; i <= common.RetryTimes; i++ {
+	common.LogDebug(c, fmt.Sprintf("Relay relayMode: %d", relayMode))
+	release, err := service.AcquireGlobalSlot(c)
+	if err != nil {
+		openaiErr = service.OpenAIErrorWrapperLocal(err, "global_concurrency_limited", http.StatusTooManyRequests)
+		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+	defer release()
+	retryTimes := getRetryTimes(c, originalModel)
+	for i := 0; i <= retryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
 			common.LogError(c, err.Error())
-			openaiErr = service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			if openaiErr == nil {
+				// 重试时没有可用渠道，保留上一次渠道的错误返回给客户端
+				openaiErr = service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			}
 			break
 		}
 
-		openaiErr = relayRequest(c, relayMode, channel)
-		common.LogInfo(c, fmt.Sprintf("Relay openaiErr: %v", openaiErr))
+		startTime := time.Now()
+		if delay, ok := getHedgeDelay(c, relayMode); ok && i == 0 {
+			channel, openaiErr = hedgeRelayRequest(c, relayMode, channel, group, originalModel, delay)
+		} else {
+			openaiErr = relayRequest(c, relayMode, channel)
+		}
+		model.RecordChannelModelLatency(channel.Id, originalModel, time.Since(startTime), service.IsChannelFailure(openaiErr))
+		common.LogDebug(c, fmt.Sprintf("Relay openaiErr: %v", openaiErr))
+		metricRelayAttempt(c, channel.Id, originalModel, relayMode, startTime, openaiErr)
 
 		if openaiErr == nil {
+			model.RecordChannelResult(channel.Id, false)
+			updateStickyChannel(c, channel.Id)
+			common.MetricRelayRequest(channel.Id, originalModel, group, constant.RelayModeName(relayMode), true)
 			return // 成功处理请求，直接返回
 		}
 
 		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
 
-		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
+		if fallbackModel, ok := switchFallbackModel(c, group, originalModel, openaiErr); ok {
+			originalModel = fallbackModel
+			retryTimes = getRetryTimes(c, originalModel)
+			i = -1 // 降级模型的渠道已写入上下文，从第 0 次开始重新计算重试
+			continue
+		}
+		if !shouldRetry(c, openaiErr, retryTimes-i) {
 			break
 		}
 	}
@@ -103,11 +137,16 @@ func Relay(c *gin.Context) {
 	}
 
 	if openaiErr != nil {
+		common.MetricRelayRequest(c.GetInt("channel_id"), originalModel, group, constant.RelayModeName(relayMode), false)
 		if openaiErr.StatusCode == http.StatusTooManyRequests {
 			common.LogError(c, fmt.Sprintf("origin 429 error: %s", openaiErr.Error.Message))
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
 		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		if helper.IsEventStreamStarted(c) {
+			_ = helper.ObjectData(c, gin.H{"error": openaiErr.Error})
+			return
+		}
 		c.JSON(openaiErr.StatusCode, gin.H{
 			"error": openaiErr.Error,
 		})
@@ -140,23 +179,39 @@ func WssRelay(c *gin.Context) {
 	originalModel := c.GetString("original_model")
 	var openaiErr *dto.OpenAIErrorWithStatusCode
 
-	for i := 0; i <= common.RetryTimes; i++ {
+	release, err := service.AcquireGlobalSlot(c)
+	if err != nil {
+		openaiErr = service.OpenAIErrorWrapperLocal(err, "global_concurrency_limited", http.StatusTooManyRequests)
+		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		helper.WssError(c, ws, openaiErr.Error)
+		return
+	}
+	defer release()
+	retryTimes := getRetryTimes(c, originalModel)
+	for i := 0; i <= retryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
 			common.LogError(c, err.Error())
-			openaiErr = service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			if openaiErr == nil {
+				openaiErr = service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			}
 			break
 		}
 
+		startTime := time.Now()
 		openaiErr = wssRequest(c, ws, relayMode, channel)
+		metricRelayAttempt(c, channel.Id, originalModel, relayMode, startTime, openaiErr)
 
 		if openaiErr == nil {
+			model.RecordChannelResult(channel.Id, false)
+			updateStickyChannel(c, channel.Id)
+			common.MetricRelayRequest(channel.Id, originalModel, group, constant.RelayModeName(relayMode), true)
 			return // 成功处理请求，直接返回
 		}
 
 		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
 
-		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
+		if !shouldRetry(c, openaiErr, retryTimes-i) {
 			break
 		}
 	}
@@ -167,6 +222,7 @@ func WssRelay(c *gin.Context) {
 	}
 
 	if openaiErr != nil {
+		common.MetricRelayRequest(c.GetInt("channel_id"), originalModel, group, constant.RelayModeName(relayMode), false)
 		if openaiErr.StatusCode == http.StatusTooManyRequests {
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
@@ -182,25 +238,49 @@ func RelayClaude(c *gin.Context) {
 	originalModel := c.GetString("original_model")
 	var claudeErr *dto.ClaudeErrorWithStatusCode
 
-	for i := 0; i <= common.RetryTimes; i++ {
+	release, err := service.AcquireGlobalSlot(c)
+	if err != nil {
+		claudeErr = service.ClaudeErrorWrapperLocal(err, "global_concurrency_limited", http.StatusTooManyRequests)
+		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
+		c.JSON(claudeErr.StatusCode, gin.H{
+			"type":  "error",
+			"error": claudeErr.Error,
+		})
+		return
+	}
+	defer release()
+	retryTimes := getRetryTimes(c, originalModel)
+	for i := 0; i <= retryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
 			common.LogError(c, err.Error())
-			claudeErr = service.ClaudeErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			if claudeErr == nil {
+				claudeErr = service.ClaudeErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+			}
 			break
 		}
 
+		startTime := time.Now()
 		claudeErr = claudeRequest(c, channel)
+		var openaiErr *dto.OpenAIErrorWithStatusCode
+		if claudeErr != nil {
+			openaiErr = service.ClaudeErrorToOpenAIError(claudeErr)
+		}
+		model.RecordChannelModelLatency(channel.Id, originalModel, time.Since(startTime), service.IsChannelFailure(openaiErr))
 
 		if claudeErr == nil {
+			metricRelayAttempt(c, channel.Id, originalModel, constant.RelayModeChatCompletions, startTime, nil)
+			model.RecordChannelResult(channel.Id, false)
+			updateStickyChannel(c, channel.Id)
+			common.MetricRelayRequest(channel.Id, originalModel, group, constant.RelayModeName(constant.RelayModeChatCompletions), true)
 			return // 成功处理请求，直接返回
 		}
 
-		openaiErr := service.ClaudeErrorToOpenAIError(claudeErr)
+		metricRelayAttempt(c, channel.Id, originalModel, constant.RelayModeChatCompletions, startTime, openaiErr)
 
 		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
 
-		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
+		if !shouldRetry(c, openaiErr, retryTimes-i) {
 			break
 		}
 	}
@@ -211,16 +291,52 @@ func RelayClaude(c *gin.Context) {
 	}
 
 	if claudeErr != nil {
+		common.MetricRelayRequest(c.GetInt("channel_id"), originalModel, group, constant.RelayModeName(constant.RelayModeChatCompletions), false)
 		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
+		if helper.IsEventStreamStarted(c) {
+			_ = helper.ClaudeData(c, dto.ClaudeResponse{Type: "error", Error: &claudeErr.Error})
+			return
+		}
+		c.JSON(claudeErr.StatusCode, gin.H{
+			"type":  "error",
+			"error": claudeErr.Error,
+		})
+	}
+}
+
+// ClaudeCountTokens Anthropic count_tokens 接口，供 Anthropic SDK 在请求前计算 token 预算
+func ClaudeCountTokens(c *gin.Context) {
+	response, claudeErr := relay.ClaudeCountTokens(c)
+	if claudeErr != nil {
+		if !claudeErr.LocalError {
+			common.LogError(c, fmt.Sprintf("count tokens error (channel #%d, status code %d): %s", c.GetInt("channel_id"), claudeErr.StatusCode, claudeErr.Error.Message))
+		}
+		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, c.GetString(common.RequestIdKey))
 		c.JSON(claudeErr.StatusCode, gin.H{
 			"type":  "error",
 			"error": claudeErr.Error,
 		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// metricRelayAttempt 记录单次渠道尝试的耗时与错误状态码
+func metricRelayAttempt(c *gin.Context, channelId int, modelName string, relayMode int, startTime time.Time, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	statusCode := 0
+	if openaiErr != nil {
+		statusCode = openaiErr.StatusCode
 	}
+	common.MetricRelayAttempt(channelId, modelName, c.GetString("group"), constant.RelayModeName(relayMode), time.Since(startTime), statusCode)
 }
 
 func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
+	release, err := service.AcquireChannelSlot(c, channel.Id)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "channel_concurrency_limited", http.StatusTooManyRequests)
+	}
+	defer release()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relayHandler(c, relayMode)
@@ -228,6 +344,11 @@ func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *dto.Op
 
 func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
+	release, err := service.AcquireChannelSlot(c, channel.Id)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "channel_concurrency_limited", http.StatusTooManyRequests)
+	}
+	defer release()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relay.WssHelper(c, ws)
@@ -235,6 +356,11 @@ func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *mode
 
 func claudeRequest(c *gin.Context, channel *model.Channel) *dto.ClaudeErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
+	release, err := service.AcquireChannelSlot(c, channel.Id)
+	if err != nil {
+		return service.ClaudeErrorWrapper(err, "channel_concurrency_limited", http.StatusTooManyRequests)
+	}
+	defer release()
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relay.ClaudeHelper(c)
@@ -246,6 +372,42 @@ func addUsedChannel(c *gin.Context, channelId int) {
 	c.Set("use_channel", useChannel)
 }
 
+// updateStickyChannel 将会话绑定到本次成功的渠道（包括重试切换后的渠道）
+func updateStickyChannel(c *gin.Context, channelId int) {
+	if stickyKey := c.GetString(constant2.ContextKeyStickyRoutingKey); stickyKey != "" {
+		model.SetStickyChannelId(stickyKey, channelId)
+	}
+}
+
+// getRetryTimes 返回本次请求的重试次数，首个渠道配置了 max_retries 时覆盖全局重试次数
+func getRetryTimes(c *gin.Context, originalModel string) int {
+	if c.GetString(constant2.ContextKeyCachedContent) != "" {
+		// 上下文缓存只存在于创建它的渠道，换渠道重试必然失败
+		return 0
+	}
+	channelSetting := c.GetStringMap("channel_setting")
+	policy := relaycommon.GetChannelPolicy(channelSetting, originalModel)
+	if policy.MaxRetries >= 0 {
+		return policy.MaxRetries
+	}
+	return common.RetryTimes
+}
+
+// getRetryExcludeChannelIds 返回重试时需要排除的渠道，即本次请求已经尝试过的渠道
+func getRetryExcludeChannelIds(c *gin.Context) map[int]bool {
+	if !operation_setting.GetRetrySetting().ExcludeFailedChannel {
+		return nil
+	}
+	useChannel := c.GetStringSlice("use_channel")
+	ids := make(map[int]bool, len(useChannel))
+	for _, idStr := range useChannel {
+		if id, err := strconv.Atoi(idStr); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
 func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*model.Channel, error) {
 	if retryCount == 0 {
 		autoBan := c.GetBool("auto_ban")
@@ -260,7 +422,12 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 			AutoBan: &autoBanInt,
 		}, nil
 	}
-	channel, err := model.CacheGetRandomSatisfiedChannel(group, originalModel, retryCount)
+	excludeChannelIds := getRetryExcludeChannelIds(c)
+	channel, err := middleware.CacheGetRandomSatisfiedChannel(c, group, originalModel, retryCount, excludeChannelIds)
+	if err != nil && len(excludeChannelIds) > 0 {
+		// 当前优先级的渠道均已失败，尝试从所有渠道中选择
+		channel, err = middleware.CacheGetRandomSatisfiedChannel(c, group, originalModel, 0, excludeChannelIds)
+	}
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("获取重试渠道失败: %s", err.Error()))
 	}
@@ -268,6 +435,22 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 	return channel, nil
 }
 
+// switchFallbackModel 上游返回 model_not_found 时切换到降级链中的下一个模型
+func switchFallbackModel(c *gin.Context, group string, originalModel string, openaiErr *dto.OpenAIErrorWithStatusCode) (string, bool) {
+	if code, _ := openaiErr.Error.Code.(string); code != "model_not_found" {
+		return "", false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok || c.Writer.Written() || c.GetString(constant2.ContextKeyCachedContent) != "" {
+		return "", false
+	}
+	_, fallbackModel := middleware.SelectFallbackChannel(c, group, originalModel)
+	if fallbackModel == "" {
+		return "", false
+	}
+	common.LogInfo(c, fmt.Sprintf("模型 %s 上游不存在，降级为 %s", originalModel, fallbackModel))
+	return fallbackModel, true
+}
+
 func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retryTimes int) bool {
 	if openaiErr == nil {
 		return false
@@ -281,6 +464,18 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	if c.GetString(constant2.ContextKeyCachedContent) != "" {
+		// 上下文缓存只存在于创建它的渠道，换渠道重试必然失败
+		return false
+	}
+	if c.Writer.Written() {
+		// 已经向客户端输出了响应内容，无法再切换渠道
+		return false
+	}
+	if code, _ := openaiErr.Error.Code.(string); code == "first_token_timeout" || code == "stream_failed_before_content" {
+		// 尚未向客户端输出，上游请求已被取消，直接切换渠道
+		return true
+	}
 	if openaiErr.StatusCode == http.StatusTooManyRequests {
 		return true
 	}
@@ -288,9 +483,9 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 		return true
 	}
 	if openaiErr.StatusCode/100 == 5 {
-		// 超时不重试
+		// 超时默认不重试
 		if openaiErr.StatusCode == 504 || openaiErr.StatusCode == 524 {
-			return false
+			return operation_setting.GetRetrySetting().TimeoutRetryEnabled
 		}
 		return true
 	}
@@ -318,11 +513,25 @@ func processChannelError(c *gin.Context, channelId int, channelType int, channel
 	if service.ShouldDisableChannel(channelType, err) && autoBan {
 		service.DisableChannel(channelId, channelName, err.Error.Message)
 	}
+	if service.IsChannelFailure(err) {
+		service.RecordChannelFailureEvent(channelId, channelName, err)
+	}
+	if model.RecordChannelResult(channelId, service.IsChannelFailure(err)) {
+		common.SysError(fmt.Sprintf("渠道「%s」（#%d）错误率过高，已熔断", channelName, channelId))
+		service.EmitWebhookEvent(dto.WebhookEventChannelCircuitOpen, map[string]interface{}{
+			"channel_id":   channelId,
+			"channel_name": channelName,
+			"last_error":   err.Error.Message,
+		})
+	}
 }
 
 func RelayMidjourney(c *gin.Context) {
 	relayMode := c.GetInt("relay_mode")
 	var err *dto.MidjourneyResponse
+	// 回调与任务查询不请求上游，不计入监控指标
+	upstream := false
+	startTime := time.Now()
 	switch relayMode {
 	case relayconstant.RelayModeMidjourneyNotify:
 		err = relay.RelayMidjourneyNotify(c)
@@ -331,31 +540,41 @@ func RelayMidjourney(c *gin.Context) {
 	case relayconstant.RelayModeMidjourneyTaskImageSeed:
 		err = relay.RelayMidjourneyTaskImageSeed(c)
 	case relayconstant.RelayModeSwapFace:
+		upstream = true
 		err = relay.RelaySwapFace(c)
 	default:
+		upstream = true
 		err = relay.RelayMidjourneySubmit(c, relayMode)
 	}
 	//err = relayMidjourneySubmit(c, relayMode)
 	log.Println(err)
+	statusCode := 0
 	if err != nil {
-		statusCode := http.StatusBadRequest
+		statusCode = http.StatusBadRequest
 		if err.Code == 30 {
 			err.Result = "当前分组负载已饱和，请稍后再试，或升级账户以提升服务质量。"
 			statusCode = http.StatusTooManyRequests
 		}
 		c.JSON(statusCode, gin.H{
-			"description": fmt.Sprintf("%s %s", err.Description, err.Result),
+			"description": common.MessageWithRequestId(fmt.Sprintf("%s %s", err.Description, err.Result), c.GetString(common.RequestIdKey)),
 			"type":        "upstream_error",
 			"code":        err.Code,
 		})
 		channelId := c.GetInt("channel_id")
 		common.LogError(c, fmt.Sprintf("relay error (channel #%d, status code %d): %s", channelId, statusCode, fmt.Sprintf("%s %s", err.Description, err.Result)))
 	}
+	if upstream {
+		channelId := c.GetInt("channel_id")
+		modelName := c.GetString("original_model")
+		group := c.GetString("group")
+		common.MetricRelayAttempt(channelId, modelName, group, constant.RelayModeName(relayMode), time.Since(startTime), statusCode)
+		common.MetricRelayRequest(channelId, modelName, group, constant.RelayModeName(relayMode), err == nil)
+	}
 }
 
 func RelayNotImplemented(c *gin.Context) {
 	err := dto.OpenAIError{
-		Message: "API not implemented",
+		Message: common.MessageWithRequestId("API not implemented", c.GetString(common.RequestIdKey)),
 		Type:    "new_api_error",
 		Param:   "",
 		Code:    "api_not_implemented",
@@ -367,7 +586,7 @@ func RelayNotImplemented(c *gin.Context) {
 
 func RelayNotFound(c *gin.Context) {
 	err := dto.OpenAIError{
-		Message: fmt.Sprintf("Invalid URL (%s %s)", c.Request.Method, c.Request.URL.Path),
+		Message: common.MessageWithRequestId(fmt.Sprintf("Invalid URL (%s %s)", c.Request.Method, c.Request.URL.Path), c.GetString(common.RequestIdKey)),
 		Type:    "invalid_request_error",
 		Param:   "",
 		Code:    "",
@@ -378,18 +597,20 @@ func RelayNotFound(c *gin.Context) {
 }
 
 func RelayTask(c *gin.Context) {
-	retryTimes := common.RetryTimes
 	channelId := c.GetInt("channel_id")
 	relayMode := c.GetInt("relay_mode")
 	group := c.GetString("group")
 	originalModel := c.GetString("original_model")
+	retryTimes := getRetryTimes(c, originalModel)
 	c.Set("use_channel", []string{fmt.Sprintf("%d", channelId)})
+	startTime := time.Now()
 	taskErr := taskRelayHandler(c, relayMode)
+	metricTaskRelayAttempt(c, channelId, originalModel, relayMode, startTime, taskErr)
 	if taskErr == nil {
 		retryTimes = 0
 	}
 	for i := 0; shouldRetryTaskRelay(c, channelId, taskErr, retryTimes) && i < retryTimes; i++ {
-		channel, err := model.CacheGetRandomSatisfiedChannel(group, originalModel, i)
+		channel, err := middleware.CacheGetRandomSatisfiedChannel(c, group, originalModel, i, getRetryExcludeChannelIds(c))
 		if err != nil {
 			common.LogError(c, fmt.Sprintf("CacheGetRandomSatisfiedChannel failed: %s", err.Error()))
 			break
@@ -403,21 +624,43 @@ func RelayTask(c *gin.Context) {
 
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		startTime = time.Now()
 		taskErr = taskRelayHandler(c, relayMode)
+		metricTaskRelayAttempt(c, channelId, originalModel, relayMode, startTime, taskErr)
 	}
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
 		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
 		common.LogInfo(c, retryLogStr)
 	}
+	if !isTaskFetchMode(relayMode) {
+		common.MetricRelayRequest(c.GetInt("channel_id"), originalModel, group, constant.RelayModeName(relayMode), taskErr == nil)
+	}
 	if taskErr != nil {
 		if taskErr.StatusCode == http.StatusTooManyRequests {
 			taskErr.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
+		taskErr.Message = common.MessageWithRequestId(taskErr.Message, c.GetString(common.RequestIdKey))
 		c.JSON(taskErr.StatusCode, taskErr)
 	}
 }
 
+// isTaskFetchMode 任务查询只读取本地记录，不计入监控指标
+func isTaskFetchMode(relayMode int) bool {
+	return relayMode == relayconstant.RelayModeSunoFetch || relayMode == relayconstant.RelayModeSunoFetchByID
+}
+
+func metricTaskRelayAttempt(c *gin.Context, channelId int, modelName string, relayMode int, startTime time.Time, taskErr *dto.TaskError) {
+	if isTaskFetchMode(relayMode) {
+		return
+	}
+	statusCode := 0
+	if taskErr != nil {
+		statusCode = taskErr.StatusCode
+	}
+	common.MetricRelayAttempt(channelId, modelName, c.GetString("group"), constant.RelayModeName(relayMode), time.Since(startTime), statusCode)
+}
+
 func taskRelayHandler(c *gin.Context, relayMode int) *dto.TaskError {
 	var err *dto.TaskError
 	switch relayMode {
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRequestCaptures 按 request_id 查询抓取到的请求/响应体
+func GetRequestCaptures(c *gin.Context) {
+	requestId := c.Param("request_id")
+	if requestId == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "request_id 不能为空",
+		})
+		return
+	}
+	captures, err := model.GetRequestCaptures(requestId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    captures,
+	})
+}
+
+// AutomaticallyCleanRequestCaptures 每小时清理超过保留时长的抓取记录
+func AutomaticallyCleanRequestCaptures() {
+	for {
+		time.Sleep(time.Hour)
+		retentionHours := operation_setting.GetBodyCaptureSetting().RetentionHours
+		if retentionHours <= 0 {
+			continue
+		}
+		before := time.Now().Add(-time.Duration(retentionHours) * time.Hour).Unix()
+		count, err := model.DeleteRequestCapturesBefore(before)
+		if err != nil {
+			common.SysError("failed to clean request captures: " + err.Error())
+			continue
+		}
+		if count > 0 {
+			common.SysLog(fmt.Sprintf("cleaned %d request captures", count))
+		}
+	}
+}
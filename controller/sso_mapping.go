@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"strings"
+)
+
+var ssoRoleNames = map[string]int{
+	"common": common.RoleCommonUser,
+	"user":   common.RoleCommonUser,
+	"admin":  common.RoleAdminUser,
+}
+
+// resolveSSOMapping 根据外部身份源的分组计算系统角色与用户分组；配置了角色映射但未匹配时返回普通用户，
+// 使被移出管理员分组的用户在同步时降级，未配置角色映射时返回 0，分组未匹配时返回空字符串；
+// 映射最多授予管理员角色，超级管理员只能在系统内设置
+func resolveSSOMapping(groups []string, roleMapping map[string]string, groupMapping map[string]string) (int, string) {
+	role := 0
+	if len(roleMapping) > 0 {
+		role = common.RoleCommonUser
+	}
+	userGroup := ""
+	for _, group := range groups {
+		if name, ok := roleMapping[group]; ok {
+			if r := ssoRoleNames[strings.ToLower(name)]; r > role {
+				role = r
+			}
+		}
+		if userGroup == "" {
+			userGroup = groupMapping[group]
+		}
+	}
+	return role, userGroup
+}
+
+// applySSOMapping 将映射结果写入 user，角色可升可降，返回是否有变化；超级管理员的角色不会被修改
+func applySSOMapping(user *model.User, role int, group string) bool {
+	changed := false
+	if role != 0 && user.Role != role && user.Role != common.RoleRootUser {
+		user.Role = role
+		changed = true
+	}
+	if group != "" && user.Group != group {
+		user.Group = group
+		changed = true
+	}
+	return changed
+}
@@ -1,10 +1,14 @@
 package controller
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
 	"strconv"
 )
 
@@ -106,6 +110,48 @@ func GetTokenStatus(c *gin.Context) {
 	})
 }
 
+// adminTokenSettingKeys 只能由管理员设置的令牌配置项，用户创建或修改令牌时不能修改：
+// 对冲请求与语义缓存会产生由网关承担的额外上游调用，Dify 调试输出会暴露渠道工作流的内部信息
+var adminTokenSettingKeys = []string{
+	constant.TokenSettingModelQuotaLimits,
+	constant.TokenSettingHedgeEnabled,
+	constant.TokenSettingHedgeDelayMs,
+	constant.TokenSettingSemanticCache,
+	constant.TokenSettingDifyDebug,
+}
+
+// validateTokenSetting 校验用户提交的令牌配置项
+func validateTokenSetting(token *model.Token) error {
+	if name, ok := token.GetSetting()[constant.TokenSettingPromptTemplate].(string); ok && name != "" {
+		if !operation_setting.HasPromptTemplate(name) {
+			return fmt.Errorf("提示词模板不存在：%s", name)
+		}
+	}
+	return nil
+}
+
+// keepAdminTokenSettings 用 origin 中的管理员配置项覆盖用户提交的令牌配置，origin 为 nil（新建令牌）时移除这些配置项
+func keepAdminTokenSettings(token *model.Token, origin *model.Token) {
+	setting := token.GetSetting()
+	var originSetting map[string]interface{}
+	if origin != nil {
+		originSetting = origin.GetSetting()
+	}
+	changed := false
+	for _, key := range adminTokenSettingKeys {
+		if v, ok := originSetting[key]; ok {
+			setting[key] = v
+			changed = true
+		} else if _, ok := setting[key]; ok {
+			delete(setting, key)
+			changed = true
+		}
+	}
+	if changed {
+		token.SetSetting(setting)
+	}
+}
+
 func AddToken(c *gin.Context) {
 	token := model.Token{}
 	err := c.ShouldBindJSON(&token)
@@ -123,6 +169,32 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := token.ValidateAllowIps(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "IP 白名单格式错误：" + err.Error(),
+		})
+		return
+	}
+	if token.OrganizationId != 0 {
+		// 只有组织成员可以创建组织令牌
+		user, err := model.GetUserById(c.GetInt("id"), false)
+		if err != nil || user.OrganizationId != token.OrganizationId {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不是该组织的成员",
+			})
+			return
+		}
+	}
+	if err := validateTokenSetting(&token); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	keepAdminTokenSettings(&token, nil)
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -145,6 +217,8 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		Setting:            token.Setting,
+		OrganizationId:     token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -179,6 +253,62 @@ func DeleteToken(c *gin.Context) {
 	return
 }
 
+// 轮换后旧密钥的最长宽限期
+const maxTokenRotationGraceSeconds = 7 * 24 * 3600
+
+type rotateTokenRequest struct {
+	GraceSeconds int64  `json:"grace_seconds"`
+	ExpiredTime  *int64 `json:"expired_time"`
+}
+
+// RotateToken 轮换令牌密钥，新密钥仅在本次响应中完整返回，旧密钥在宽限期内仍可使用
+func RotateToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId := c.GetInt("id")
+	req := rotateTokenRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if req.GraceSeconds < 0 || req.GraceSeconds > maxTokenRotationGraceSeconds {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("宽限期需在 0 到 %d 秒之间", maxTokenRotationGraceSeconds),
+		})
+		return
+	}
+	if req.ExpiredTime != nil && *req.ExpiredTime != -1 && *req.ExpiredTime <= common.GetTimestamp() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "过期时间需晚于当前时间，或设置为 -1 表示永不过期",
+		})
+		return
+	}
+	token, err := model.RotateToken(id, userId, req.GraceSeconds, req.ExpiredTime)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"id":                    token.Id,
+			"key":                   token.Key,
+			"expired_time":          token.ExpiredTime,
+			"prev_key_expired_time": token.PrevKeyExpiredTime,
+		},
+	})
+}
+
 func UpdateToken(c *gin.Context) {
 	userId := c.GetInt("id")
 	statusOnly := c.Query("status_only")
@@ -198,6 +328,22 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := token.ValidateAllowIps(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "IP 白名单格式错误：" + err.Error(),
+		})
+		return
+	}
+	if statusOnly == "" {
+		if err := validateTokenSetting(&token); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -221,6 +367,17 @@ func UpdateToken(c *gin.Context) {
 			})
 			return
 		}
+		if cleanToken.Status != common.TokenStatusEnabled && cleanToken.OrganizationId != 0 {
+			// 退出组织后被禁用的组织令牌不能再启用
+			user, err := model.GetUserById(userId, false)
+			if err != nil || user.OrganizationId != cleanToken.OrganizationId {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "已不是该令牌所属组织的成员，无法启用",
+				})
+				return
+			}
+		}
 	}
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
@@ -234,6 +391,8 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		keepAdminTokenSettings(&token, cleanToken)
+		cleanToken.Setting = token.Setting
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -250,3 +409,160 @@ func UpdateToken(c *gin.Context) {
 	})
 	return
 }
+
+type UpdateTokenModelQuotaLimitsRequest struct {
+	Id     int                       `json:"id"`
+	Limits []service.ModelQuotaLimit `json:"limits"`
+}
+
+// UpdateTokenModelQuotaLimits 由管理员设置令牌按模型的每日/每月额度上限，limits 为空表示不限制
+func UpdateTokenModelQuotaLimits(c *gin.Context) {
+	var req UpdateTokenModelQuotaLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if _, err := service.ParseModelQuotaLimits(req.Limits); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	token, err := model.GetTokenById(req.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user, err := model.GetUserById(token.UserId, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	setting := token.GetSetting()
+	if len(req.Limits) == 0 {
+		delete(setting, constant.TokenSettingModelQuotaLimits)
+	} else {
+		setting[constant.TokenSettingModelQuotaLimits] = req.Limits
+	}
+	token.SetSetting(setting)
+	if err := token.UpdateSetting(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UpdateTokenAdminSettingRequest struct {
+	Id int `json:"id"`
+	// Setting 要修改的管理员配置项，值为 null 表示移除该配置项
+	Setting map[string]interface{} `json:"setting"`
+}
+
+// validateAdminTokenSetting 校验管理员配置项的取值，按模型额度上限通过 UpdateTokenModelQuotaLimits 设置
+func validateAdminTokenSetting(key string, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch key {
+	case constant.TokenSettingHedgeEnabled, constant.TokenSettingSemanticCache, constant.TokenSettingDifyDebug:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s 必须为布尔值", key)
+		}
+	case constant.TokenSettingHedgeDelayMs:
+		if delay, ok := value.(float64); !ok || delay <= 0 {
+			return fmt.Errorf("%s 必须为正数", key)
+		}
+	default:
+		return fmt.Errorf("不支持通过该接口设置 %s", key)
+	}
+	return nil
+}
+
+// UpdateTokenAdminSetting 由管理员设置令牌的对冲请求、语义缓存与 Dify 调试等只能由管理员修改的配置项
+func UpdateTokenAdminSetting(c *gin.Context) {
+	var req UpdateTokenAdminSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 || len(req.Setting) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	for key, value := range req.Setting {
+		if err := validateAdminTokenSetting(key, value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	token, err := model.GetTokenById(req.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user, err := model.GetUserById(token.UserId, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	setting := token.GetSetting()
+	for key, value := range req.Setting {
+		if value == nil {
+			delete(setting, key)
+		} else {
+			setting[key] = value
+		}
+	}
+	token.SetSetting(setting)
+	if err := token.UpdateSetting(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenizeRequest struct {
+	Model string `json:"model"`
+	// Input 为字符串或字符串数组，与 Messages 二选一
+	Input          any                   `json:"input,omitempty"`
+	Messages       []dto.Message         `json:"messages,omitempty"`
+	Tools          []dto.ToolCallRequest `json:"tools,omitempty"`
+	ToolChoice     any                   `json:"tool_choice,omitempty"`
+	ResponseFormat *dto.ResponseFormat   `json:"response_format,omitempty"`
+	ReturnTokenIds bool                  `json:"return_token_ids,omitempty"`
+}
+
+type TokenizeResponse struct {
+	Model     string  `json:"model"`
+	Tokenizer string  `json:"tokenizer"`
+	Tokens    int     `json:"tokens"`
+	TokenIds  [][]int `json:"token_ids,omitempty"`
+}
+
+func tokenizeError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": dto.OpenAIError{
+			Message: err.Error(),
+			Type:    "invalid_request_error",
+		},
+	})
+}
+
+func tokenizeInputs(input any) ([]string, error) {
+	switch v := input.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return nil, errors.New("input must be a string or an array of strings")
+			}
+			texts = append(texts, text)
+		}
+		return texts, nil
+	}
+	return nil, errors.New("input must be a string or an array of strings")
+}
+
+// Tokenize 使用网关的计数逻辑返回 token 数量，不请求上游，便于客户端预估提示词长度
+func Tokenize(c *gin.Context) {
+	var req TokenizeRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		tokenizeError(c, err)
+		return
+	}
+	if req.Model == "" {
+		tokenizeError(c, errors.New("model is required"))
+		return
+	}
+	resp := TokenizeResponse{
+		Model:     req.Model,
+		Tokenizer: service.GetTokenizerFamily(req.Model),
+	}
+	if len(req.Messages) > 0 {
+		// 计数接口不下载客户端提供的远程图片，避免被用于向任意地址发起请求
+		info := &relaycommon.RelayInfo{OriginModelName: req.Model, UpstreamModelName: req.Model, SkipRemoteMedia: true}
+		tokens, err := service.CountTokenChatRequest(info, dto.GeneralOpenAIRequest{
+			Model:          req.Model,
+			Messages:       req.Messages,
+			Tools:          req.Tools,
+			ToolChoice:     req.ToolChoice,
+			ResponseFormat: req.ResponseFormat,
+		})
+		if err != nil {
+			tokenizeError(c, err)
+			return
+		}
+		resp.Tokens = tokens
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if req.Input == nil {
+		tokenizeError(c, errors.New("input or messages is required"))
+		return
+	}
+	texts, err := tokenizeInputs(req.Input)
+	if err != nil {
+		tokenizeError(c, err)
+		return
+	}
+	for _, text := range texts {
+		count, ids := service.TokenizeText(text, req.Model, req.ReturnTokenIds)
+		resp.Tokens += count
+		if ids != nil {
+			resp.TokenIds = append(resp.TokenIds, ids)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AutomaticallyExportUsage 每天在配置的时刻导出前一天的用量数据
+func AutomaticallyExportUsage() {
+	for {
+		now := time.Now()
+		hour := operation_setting.GetUsageExportSetting().RunAtHour
+		if hour < 0 || hour > 23 {
+			hour = 2
+		}
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.Local)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(next.Sub(now))
+		if !operation_setting.GetUsageExportSetting().Enabled {
+			continue
+		}
+		day := time.Now().AddDate(0, 0, -1)
+		common.SysLog("exporting usage of " + day.Format("2006-01-02"))
+		if err := service.ExportUsageForDay(context.Background(), day); err != nil {
+			common.SysError("failed to export usage: " + err.Error())
+		}
+	}
+}
+
+// ExportUsage 手动导出指定日期（date=2006-01-02，默认昨天）的用量数据，用于补导
+func ExportUsage(c *gin.Context) {
+	day := time.Now().AddDate(0, 0, -1)
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("日期格式错误：%s", err.Error()),
+			})
+			return
+		}
+		day = parsed
+	}
+	if err := service.ExportUsageForDay(c.Request.Context(), day); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
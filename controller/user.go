@@ -7,7 +7,10 @@ import (
 	"net/url"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"one-api/setting/system_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,7 +27,8 @@ type LoginRequest struct {
 }
 
 func Login(c *gin.Context) {
-	if !common.PasswordLoginEnabled {
+	ldapEnabled := system_setting.GetLDAPSettings().Enabled
+	if !common.PasswordLoginEnabled && !ldapEnabled {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "管理员关闭了密码登录",
 			"success": false,
@@ -53,7 +57,18 @@ func Login(c *gin.Context) {
 		Username: username,
 		Password: password,
 	}
-	err = user.ValidateAndFill()
+	if common.PasswordLoginEnabled {
+		err = user.ValidateAndFill()
+	}
+	// 本地账户校验失败或关闭了密码登录时尝试 LDAP 登录
+	if (!common.PasswordLoginEnabled || err != nil) && ldapEnabled {
+		ldapUser, ldapErr := loginWithLdap(username, password)
+		if ldapErr == nil {
+			setupLogin(ldapUser, c)
+			return
+		}
+		err = ldapErr
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": err.Error(),
@@ -943,6 +958,7 @@ type UpdateUserSettingRequest struct {
 	WebhookSecret              string  `json:"webhook_secret,omitempty"`
 	NotificationEmail          string  `json:"notification_email,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
+	DisplayCurrency            string  `json:"display_currency,omitempty"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1014,12 +1030,33 @@ func UpdateUserSetting(c *gin.Context) {
 		return
 	}
 
+	// 验证展示币种
+	if req.DisplayCurrency != "" {
+		if _, ok := operation_setting.GetExchangeRate(req.DisplayCurrency); !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的币种",
+			})
+			return
+		}
+	}
+
 	// 构建设置
 	settings := map[string]interface{}{
 		constant.UserSettingNotifyType:            req.QuotaWarningType,
 		constant.UserSettingQuotaWarningThreshold: req.QuotaWarningThreshold,
 		"accept_unset_model_ratio_model":          req.AcceptUnsetModelRatioModel,
 	}
+	if req.DisplayCurrency != "" {
+		settings[constant.UserSettingDisplayCurrency] = strings.ToUpper(req.DisplayCurrency)
+	}
+	// 保留由管理员设置的限流、按模型额度限制与后付费信用额度配置
+	originSettings := user.GetSetting()
+	for _, key := range []string{constant.UserSettingRPM, constant.UserSettingTPM, constant.UserSettingModelQuotaLimits, constant.UserSettingCreditLimit} {
+		if v, ok := originSettings[key]; ok {
+			settings[key] = v
+		}
+	}
 
 	// 如果是webhook类型,添加webhook相关设置
 	if req.QuotaWarningType == constant.NotifyTypeWebhook {
@@ -1049,3 +1086,188 @@ func UpdateUserSetting(c *gin.Context) {
 		"message": "设置已更新",
 	})
 }
+
+type UpdateUserRateLimitRequest struct {
+	Id  int `json:"id"`
+	RPM int `json:"rpm"`
+	TPM int `json:"tpm"`
+}
+
+// UpdateUserRateLimit 设置用户级别的 RPM/TPM 限制，0 表示不限制
+func UpdateUserRateLimit(c *gin.Context) {
+	var req UpdateUserRateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 || req.RPM < 0 || req.TPM < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	user, err := model.GetUserById(req.Id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	settings := user.GetSetting()
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	settings[constant.UserSettingRPM] = req.RPM
+	settings[constant.UserSettingTPM] = req.TPM
+	user.SetSetting(settings)
+	if err := user.Update(false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UpdateUserModelQuotaLimitsRequest struct {
+	Id     int                       `json:"id"`
+	Limits []service.ModelQuotaLimit `json:"limits"`
+}
+
+// UpdateUserModelQuotaLimits 设置用户按模型（或以 * 结尾的模型前缀）的每日/每月额度上限，limits 为空表示不限制
+func UpdateUserModelQuotaLimits(c *gin.Context) {
+	var req UpdateUserModelQuotaLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if _, err := service.ParseModelQuotaLimits(req.Limits); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user, err := model.GetUserById(req.Id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	settings := user.GetSetting()
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	if len(req.Limits) == 0 {
+		delete(settings, constant.UserSettingModelQuotaLimits)
+	} else {
+		settings[constant.UserSettingModelQuotaLimits] = req.Limits
+	}
+	user.SetSetting(settings)
+	if err := user.Update(false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UpdateUserCreditLimitRequest struct {
+	Id          int `json:"id"`
+	CreditLimit int `json:"credit_limit"`
+}
+
+// UpdateUserCreditLimit 将用户设为后付费并设置可透支的信用额度，0 表示恢复为预付费
+func UpdateUserCreditLimit(c *gin.Context) {
+	var req UpdateUserCreditLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 || req.CreditLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	user, err := model.GetUserById(req.Id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	settings := user.GetSetting()
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	if req.CreditLimit == 0 {
+		delete(settings, constant.UserSettingCreditLimit)
+	} else {
+		settings[constant.UserSettingCreditLimit] = req.CreditLimit
+	}
+	user.SetSetting(settings)
+	if err := user.Update(false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("管理员将信用额度设置为 %s", common.LogQuota(req.CreditLimit)))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetOutstandingAging 欠款账龄报表，列出余额为负的用户及其欠款按消费时间的分布
+func GetOutstandingAging(c *gin.Context) {
+	items, err := service.BuildOutstandingAging()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    items,
+	})
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitVideoGeneration 提交视频生成任务，返回网关任务 ID，之后通过 GET /v1/video/generations/:id 或 webhook 获取结果
+func SubmitVideoGeneration(c *gin.Context) {
+	startTime := time.Now()
+	task, taskErr := relay.VideoGenerationSubmit(c)
+	metricAsyncTaskSubmit(c, relayconstant.RelayModeName(relayconstant.RelayModeVideoGenerations), startTime, taskErr)
+	if taskErr != nil {
+		if !taskErr.LocalError {
+			channelId := c.GetInt("channel_id")
+			common.LogError(c, fmt.Sprintf("video generation submit error (channel #%d, status code %d): %s", channelId, taskErr.StatusCode, taskErr.Message))
+		}
+		asyncTaskError(c, taskErr)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+func getUserVideoTask(c *gin.Context) (*model.AsyncTask, *dto.TaskError) {
+	task, err := model.GetAsyncTaskByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil || task.Kind != model.AsyncTaskKindVideo {
+		return nil, service.TaskErrorWrapperLocal(fmt.Errorf("任务 %s 不存在", c.Param("id")), "task_not_exist", http.StatusNotFound)
+	}
+	return task, nil
+}
+
+// GetVideoGeneration 查询当前用户的视频生成任务
+func GetVideoGeneration(c *gin.Context) {
+	task, taskErr := getUserVideoTask(c)
+	if taskErr != nil {
+		asyncTaskError(c, taskErr)
+		return
+	}
+	response := service.AsyncTaskToDto(task)
+	response.Object = "video.generation"
+	c.JSON(http.StatusOK, response)
+}
+
+// GetVideoGenerationContent 下载生成的视频：上游返回公开地址时重定向，需要渠道密钥下载的上游（如 Sora）由网关代理
+func GetVideoGenerationContent(c *gin.Context) {
+	task, taskErr := getUserVideoTask(c)
+	if taskErr != nil {
+		asyncTaskError(c, taskErr)
+		return
+	}
+	if task.Status != model.AsyncTaskStatusSucceeded {
+		asyncTaskError(c, service.TaskErrorWrapperLocal(errors.New("视频尚未生成完成"), "task_not_finished", http.StatusBadRequest))
+		return
+	}
+	adaptor, ok := relay.GetVideoContentAdaptor(task.Platform)
+	if !ok {
+		var result dto.VideoGenerationResult
+		if err := json.Unmarshal([]byte(task.Result), &result); err != nil || len(result.Videos) == 0 || result.Videos[0].Url == "" {
+			asyncTaskError(c, service.TaskErrorWrapperLocal(errors.New("上游未返回视频地址"), "video_url_not_found", http.StatusNotFound))
+			return
+		}
+		c.Redirect(http.StatusFound, result.Videos[0].Url)
+		return
+	}
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		asyncTaskError(c, service.TaskErrorWrapperLocal(fmt.Errorf("获取渠道信息失败，渠道ID：%d", task.ChannelId), "get_channel_failed", http.StatusInternalServerError))
+		return
+	}
+	baseUrl := channel.GetBaseURL()
+	if baseUrl == "" {
+		baseUrl = common.ChannelBaseURLs[channel.Type]
+	}
+	resp, err := adaptor.FetchVideoContent(c.Request.Context(), baseUrl, channel.Key, task.UpstreamTaskId)
+	if err != nil {
+		asyncTaskError(c, service.TaskErrorWrapper(err, "fetch_video_content_failed", http.StatusInternalServerError))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		asyncTaskError(c, service.TaskErrorWrapper(fmt.Errorf("%s", string(body)), "fetch_video_content_failed", resp.StatusCode))
+		return
+	}
+	for _, key := range []string{"Content-Type", "Content-Length", "Content-Disposition"} {
+		if value := resp.Header.Get(key); value != "" {
+			c.Header(key, value)
+		}
+	}
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		common.LogError(c, "copy video content failed: "+err.Error())
+	}
+}
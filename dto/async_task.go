@@ -0,0 +1,31 @@
+package dto
+
+import "encoding/json"
+
+// AsyncTaskRequest 通用异步任务的提交请求，Input 原样转发给上游
+type AsyncTaskRequest struct {
+	Model string          `json:"model"`
+	Kind  string          `json:"kind,omitempty"` // 任务类型，如 video、music、batch，仅用于展示与筛选
+	Input json.RawMessage `json:"input"`
+	// Units 预估的计费单位数（如视频秒数、生成数量），按次价格乘以单位数预扣额度，默认 1
+	Units float64 `json:"units,omitempty"`
+	// WebhookUrl 任务结束时回调的地址，不转发给上游
+	WebhookUrl string `json:"webhook_url,omitempty"`
+}
+
+// AsyncTaskResponse 异步任务的状态，网关返回给客户端与通用适配器解析上游响应使用同一格式
+type AsyncTaskResponse struct {
+	Id         string          `json:"id"`
+	Object     string          `json:"object"`
+	Model      string          `json:"model,omitempty"`
+	Kind       string          `json:"kind,omitempty"`
+	Status     string          `json:"status"`
+	Progress   int             `json:"progress"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	FailReason string          `json:"fail_reason,omitempty"`
+	// Units 实际的计费单位数，上游返回时按实际用量结算
+	Units      float64 `json:"units,omitempty"`
+	Quota      int     `json:"quota,omitempty"`
+	CreatedAt  int64   `json:"created_at,omitempty"`
+	FinishedAt int64   `json:"finished_at,omitempty"`
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"strings"
 )
@@ -28,6 +29,7 @@ type GeneralOpenAIRequest struct {
 	MaxTokens           uint           `json:"max_tokens,omitempty"`
 	MaxCompletionTokens uint           `json:"max_completion_tokens,omitempty"`
 	ReasoningEffort     string         `json:"reasoning_effort,omitempty"`
+	ParallelToolCalls   *bool          `json:"parallel_tool_calls,omitempty"`
 	//Reasoning           json.RawMessage   `json:"reasoning,omitempty"`
 	Temperature      *float64          `json:"temperature,omitempty"`
 	TopP             float64           `json:"top_p,omitempty"`
@@ -46,13 +48,16 @@ type GeneralOpenAIRequest struct {
 	Tools            []ToolCallRequest `json:"tools,omitempty"`
 	ToolChoice       any               `json:"tool_choice,omitempty"`
 	User             string            `json:"user,omitempty"`
-	LogProbs         bool              `json:"logprobs,omitempty"`
+	LogProbs         any               `json:"logprobs,omitempty"` // chat 为 bool，completions 为返回的候选数量
 	TopLogProbs      int               `json:"top_logprobs,omitempty"`
 	Dimensions       int               `json:"dimensions,omitempty"`
 	Modalities       any               `json:"modalities,omitempty"`
 	Audio            any               `json:"audio,omitempty"`
 	EnableThinking   any               `json:"enable_thinking,omitempty"` // ali
+	GuidedJson       any               `json:"guided_json,omitempty"`     // vllm
 	ExtraBody        any               `json:"extra_body,omitempty"`
+	CachedContent    string            `json:"cached_content,omitempty"` // gemini，引用 /v1/cached_contents 创建的上下文缓存
+	ServiceTier      string            `json:"service_tier,omitempty"`
 }
 
 type ToolCallRequest struct {
@@ -76,6 +81,17 @@ func (r GeneralOpenAIRequest) GetMaxTokens() int {
 	return int(r.MaxTokens)
 }
 
+// GetLogProbs 返回是否请求 logprobs 以及每个位置返回的候选数量，兼容 chat 的 logprobs + top_logprobs 与 completions 的整数 logprobs
+func (r GeneralOpenAIRequest) GetLogProbs() (bool, int) {
+	switch v := r.LogProbs.(type) {
+	case bool:
+		return v, r.TopLogProbs
+	case float64:
+		return v > 0, int(v)
+	}
+	return false, 0
+}
+
 func (r GeneralOpenAIRequest) ParseInput() []string {
 	if r.Input == nil {
 		return nil
@@ -115,6 +131,8 @@ type MediaContent struct {
 	InputAudio any    `json:"input_audio,omitempty"`
 	File       any    `json:"file,omitempty"`
 	VideoUrl   any    `json:"video_url,omitempty"`
+	// Anthropic prompt caching 断点，转换为 Claude 请求时透传
+	CacheControl any `json:"cache_control,omitempty"`
 }
 
 func (m *MediaContent) GetImageMedia() *MessageImageUrl {
@@ -169,6 +187,7 @@ const (
 	ContentTypeInputAudio = "input_audio"
 	ContentTypeFile       = "file"
 	ContentTypeVideoUrl   = "video_url" // 阿里百炼视频识别
+	ContentTypeDocument   = "document"  // Anthropic 格式的文档块，解析后统一为 file
 )
 
 func (m *Message) GetPrefix() bool {
@@ -254,6 +273,39 @@ func (m *Message) IsStringContent() bool {
 	return false
 }
 
+// parseDocumentSource 将 Anthropic 的 document 块转换为 MessageFile：base64 与 text 来源转为 data URL，url 来源保留原地址
+func parseDocumentSource(contentItem map[string]interface{}) *MessageFile {
+	source, ok := contentItem["source"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	title, _ := contentItem["title"].(string)
+	sourceType, _ := source["type"].(string)
+	mediaType, _ := source["media_type"].(string)
+	switch sourceType {
+	case "base64":
+		if data, ok := source["data"].(string); ok {
+			return &MessageFile{FileName: title, FileData: "data:" + mediaType + ";base64," + data}
+		}
+	case "text":
+		if data, ok := source["data"].(string); ok {
+			if mediaType == "" {
+				mediaType = "text/plain"
+			}
+			return &MessageFile{FileName: title, FileData: "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString([]byte(data))}
+		}
+	case "url":
+		if url, ok := source["url"].(string); ok {
+			return &MessageFile{FileName: title, FileData: url}
+		}
+	case "file":
+		if fileId, ok := source["file_id"].(string); ok {
+			return &MessageFile{FileName: title, FileId: fileId}
+		}
+	}
+	return nil
+}
+
 func (m *Message) ParseContent() []MediaContent {
 	if m.parsedContent != nil {
 		return m.parsedContent
@@ -280,6 +332,7 @@ func (m *Message) ParseContent() []MediaContent {
 			if !ok {
 				continue
 			}
+			parsedCount := len(contentList)
 
 			switch contentType {
 			case ContentTypeText:
@@ -339,9 +392,9 @@ func (m *Message) ParseContent() []MediaContent {
 							},
 						})
 					} else {
-						fileName, ok1 := fileData["filename"].(string)
+						fileName, _ := fileData["filename"].(string)
 						fileDataStr, ok2 := fileData["file_data"].(string)
-						if ok1 && ok2 {
+						if ok2 {
 							contentList = append(contentList, MediaContent{
 								Type: ContentTypeFile,
 								File: &MessageFile{
@@ -352,6 +405,13 @@ func (m *Message) ParseContent() []MediaContent {
 						}
 					}
 				}
+			case ContentTypeDocument:
+				if file := parseDocumentSource(contentItem); file != nil {
+					contentList = append(contentList, MediaContent{
+						Type: ContentTypeFile,
+						File: file,
+					})
+				}
 			case ContentTypeVideoUrl:
 				if videoUrl, ok := contentItem["video_url"].(string); ok {
 					contentList = append(contentList, MediaContent{
@@ -362,6 +422,9 @@ func (m *Message) ParseContent() []MediaContent {
 					})
 				}
 			}
+			if cacheControl, ok := contentItem["cache_control"]; ok && len(contentList) > parsedCount {
+				contentList[len(contentList)-1].CacheControl = cacheControl
+			}
 		}
 	}
 
@@ -416,3 +479,11 @@ type ResponsesToolsCall struct {
 	Description string          `json:"description,omitempty"`
 	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
+
+// GeminiCachedContentName 将缓存 ID 规范为 Gemini 的资源名 cachedContents/{id}，兼容只传 ID 的写法
+func GeminiCachedContentName(id string) string {
+	if id == "" || strings.HasPrefix(id, "cachedContents/") {
+		return id
+	}
+	return "cachedContents/" + id
+}
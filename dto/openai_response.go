@@ -19,17 +19,49 @@ type TextResponse struct {
 type OpenAITextResponseChoice struct {
 	Index        int `json:"index"`
 	Message      `json:"message"`
+	Logprobs     any    `json:"logprobs,omitempty"`
 	FinishReason string `json:"finish_reason"`
 }
 
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+type LogprobContent struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// ChoiceLogprobs chat completions 的 logprobs 格式，用于将其他上游的格式转换为 OpenAI 格式
+type ChoiceLogprobs struct {
+	Content []LogprobContent `json:"content"`
+}
+
+// TokenBytes 返回 token 的 UTF-8 字节，与 OpenAI logprobs 中的 bytes 字段一致
+func TokenBytes(token string) []int {
+	bytes := make([]int, len(token))
+	for i := 0; i < len(token); i++ {
+		bytes[i] = int(token[i])
+	}
+	return bytes
+}
+
 type OpenAITextResponse struct {
-	Id      string                     `json:"id"`
-	Model   string                     `json:"model"`
-	Object  string                     `json:"object"`
-	Created int64                      `json:"created"`
-	Choices []OpenAITextResponseChoice `json:"choices"`
-	Error   *OpenAIError               `json:"error,omitempty"`
-	Usage   `json:"usage"`
+	Id                string                     `json:"id"`
+	Model             string                     `json:"model"`
+	Object            string                     `json:"object"`
+	Created           int64                      `json:"created"`
+	SystemFingerprint string                     `json:"system_fingerprint,omitempty"`
+	Choices           []OpenAITextResponseChoice `json:"choices"`
+	Error             *OpenAIError               `json:"error,omitempty"`
+	Usage             `json:"usage"`
+
+	// ServiceTier 上游实际使用的 service_tier，用于计费
+	ServiceTier string `json:"service_tier,omitempty"`
 }
 
 type OpenAIEmbeddingResponseItem struct {
@@ -114,6 +146,8 @@ type ChatCompletionsStreamResponse struct {
 	SystemFingerprint *string                               `json:"system_fingerprint"`
 	Choices           []ChatCompletionsStreamResponseChoice `json:"choices"`
 	Usage             *Usage                                `json:"usage"`
+
+	ServiceTier string `json:"service_tier,omitempty"`
 }
 
 func (c *ChatCompletionsStreamResponse) IsToolCall() bool {
@@ -178,6 +212,9 @@ type Usage struct {
 	InputTokens            int                `json:"input_tokens"`
 	OutputTokens           int                `json:"output_tokens"`
 	InputTokensDetails     *InputTokenDetails `json:"input_tokens_details"`
+
+	// NumSourcesUsed xAI Live Search 实际使用的搜索来源数量，按来源数计费
+	NumSourcesUsed int `json:"num_sources_used,omitempty"`
 }
 
 type InputTokenDetails struct {
@@ -217,6 +254,8 @@ type OpenAIResponsesResponse struct {
 	Usage              *Usage               `json:"usage"`
 	User               json.RawMessage      `json:"user"`
 	Metadata           json.RawMessage      `json:"metadata"`
+
+	ServiceTier string `json:"service_tier,omitempty"`
 }
 
 type IncompleteDetails struct {
@@ -238,14 +277,42 @@ type ResponsesOutputContent struct {
 }
 
 const (
-	BuildInToolWebSearchPreview = "web_search_preview"
-	BuildInToolFileSearch       = "file_search"
+	BuildInToolWebSearchPreview   = "web_search_preview"
+	BuildInToolFileSearch         = "file_search"
+	BuildInToolCodeInterpreter    = "code_interpreter"
+	BuildInToolComputerUsePreview = "computer_use_preview"
+	BuildInToolImageGeneration    = "image_generation"
+	// 各渠道的联网搜索（grounding）工具
+	BuildInToolGoogleSearch    = "google_search"
+	BuildInToolClaudeWebSearch = "claude_web_search"
+	BuildInToolXAILiveSearch   = "xai_live_search"
 )
 
+// BuildInWebSearchTools 联网搜索类工具，计费日志统一记录为 web_search 字段
+var BuildInWebSearchTools = map[string]bool{
+	BuildInToolWebSearchPreview: true,
+	BuildInToolGoogleSearch:     true,
+	BuildInToolClaudeWebSearch:  true,
+	BuildInToolXAILiveSearch:    true,
+}
+
 const (
-	BuildInCallWebSearchCall = "web_search_call"
+	BuildInCallWebSearchCall       = "web_search_call"
+	BuildInCallFileSearchCall      = "file_search_call"
+	BuildInCallCodeInterpreterCall = "code_interpreter_call"
+	BuildInCallComputerCall        = "computer_call"
+	BuildInCallImageGenerationCall = "image_generation_call"
 )
 
+// BuildInCallTools responses 输出项类型与内置工具的对应关系
+var BuildInCallTools = map[string]string{
+	BuildInCallWebSearchCall:       BuildInToolWebSearchPreview,
+	BuildInCallFileSearchCall:      BuildInToolFileSearch,
+	BuildInCallCodeInterpreterCall: BuildInToolCodeInterpreter,
+	BuildInCallComputerCall:        BuildInToolComputerUsePreview,
+	BuildInCallImageGenerationCall: BuildInToolImageGeneration,
+}
+
 const (
 	ResponsesOutputTypeItemAdded = "response.output_item.added"
 	ResponsesOutputTypeItemDone  = "response.output_item.done"
@@ -41,6 +41,9 @@ type RealtimeUsage struct {
 	OutputTokens       int                `json:"output_tokens"`
 	InputTokenDetails  InputTokenDetails  `json:"input_token_details"`
 	OutputTokenDetails OutputTokenDetails `json:"output_token_details"`
+	// 本地统计的音频时长（秒），上游未返回 token 明细时按分钟计费
+	InputAudioSeconds  float64 `json:"-"`
+	OutputAudioSeconds float64 `json:"-"`
 }
 
 type RealtimeSession struct {
@@ -0,0 +1,53 @@
+package dto
+
+// VideoGenerationRequest 视频生成请求，由网关转换为各上游（Sora、Kling、Runway 等）的格式
+type VideoGenerationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	// Image 首帧参考图的 URL 或 base64，提供时为图生视频
+	Image string `json:"image,omitempty"`
+	// Seconds 视频时长（秒），按秒计费，未指定时使用默认时长
+	Seconds int `json:"seconds,omitempty"`
+	// Resolution 分辨率，如 480p、720p、1080p，不同分辨率按配置的倍率计费
+	Resolution  string `json:"resolution,omitempty"`
+	AspectRatio string `json:"aspect_ratio,omitempty"`
+	// Extra 额外参数，原样合并到上游请求体，不能包含 VideoBillingExtraKeys 中影响计费的参数
+	Extra      map[string]interface{} `json:"extra,omitempty"`
+	WebhookUrl string                 `json:"webhook_url,omitempty"`
+}
+
+// VideoBillingExtraKeys 影响时长、分辨率或模型的上游参数，通过 Extra 覆盖会使实际生成的内容与计费不一致
+var VideoBillingExtraKeys = []string{
+	"model", "model_name", "seconds", "duration", "size", "resolution", "ratio", "width", "height", "mode", "n", "fps", "quality",
+}
+
+// Dimensions 按分辨率与画面比例计算视频宽高，画面比例仅支持 16:9、9:16 与 1:1，默认横屏；分辨率已在提交时转为小写
+func (r *VideoGenerationRequest) Dimensions() (width int, height int) {
+	switch r.Resolution {
+	case "480p":
+		width, height = 854, 480
+	case "1080p":
+		width, height = 1920, 1080
+	default:
+		width, height = 1280, 720
+	}
+	switch r.AspectRatio {
+	case "9:16":
+		width, height = height, width
+	case "1:1":
+		width = height
+	}
+	return width, height
+}
+
+// VideoGenerationResult 视频生成任务成功后的结果，保存在任务的 result 中
+type VideoGenerationResult struct {
+	Videos []VideoResultItem `json:"videos"`
+}
+
+type VideoResultItem struct {
+	// Url 上游返回的视频地址，为空时通过 GET /v1/video/generations/{id}/content 下载
+	Url     string  `json:"url,omitempty"`
+	Seconds float64 `json:"seconds,omitempty"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+// 运维 webhook 事件类型
+const (
+	WebhookEventChannelDisabled    = "channel.disabled"
+	WebhookEventChannelCircuitOpen = "channel.circuit_open"
+	WebhookEventChannelFailures    = "channel.request_failures"
+	WebhookEventUserQuotaLow       = "user.quota_low"
+	WebhookEventBudgetExceeded     = "budget.exceeded"
+	WebhookEventTest               = "webhook.test"
+)
+
+// WebhookEvent 发送给运维 webhook 的事件负载
+type WebhookEvent struct {
+	Id        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Timestamp int64                  `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
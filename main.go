@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,7 +16,10 @@ import (
 	"one-api/service"
 	"one-api/setting/operation_setting"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-contrib/sessions"
@@ -41,6 +46,13 @@ func main() {
 
 	common.SetupLogger()
 	common.SysLog("New API " + common.Version + " started")
+	shutdownTracer, err := common.InitTracer()
+	if err != nil {
+		common.FatalLog("failed to initialize tracer: " + err.Error())
+	}
+	defer func() {
+		_ = shutdownTracer(context.Background())
+	}()
 	if os.Getenv("GIN_MODE") != "debug" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -113,6 +125,17 @@ func main() {
 		}
 		go controller.AutomaticallyTestChannels(frequency)
 	}
+	// 渠道熔断状态保存在各节点内存中，每个节点独立探测
+	go controller.AutomaticallyProbeChannelCircuits()
+	if common.IsMasterNode {
+		// 健康检查会实际请求上游，多节点部署时仅由主节点执行
+		go controller.AutomaticallyHealthCheckChannels()
+		go model.AutomaticallyExpireUserPackages()
+		go model.AutomaticallyRunQuotaGrantSchedules()
+		go controller.AutomaticallyCleanRequestCaptures()
+		go controller.AutomaticallyExportUsage()
+		go controller.AutomaticallySyncLdapUsers()
+	}
 	if common.IsMasterNode && constant.UpdateTask {
 		gopool.Go(func() {
 			controller.UpdateMidjourneyTaskBulk()
@@ -120,12 +143,23 @@ func main() {
 		gopool.Go(func() {
 			controller.UpdateTaskBulk()
 		})
+		gopool.Go(func() {
+			controller.AutomaticallyPollAsyncTasks()
+		})
 	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
 		model.InitBatchUpdater()
 	}
+	if common.RedisQuotaEnabled && common.RedisEnabled {
+		common.SysLog("redis quota counter enabled with sync interval " + strconv.Itoa(common.RedisQuotaSyncInterval) + "s")
+		model.InitUserQuotaSyncer()
+	}
+	if common.LogBatchWriteEnabled {
+		common.SysLog(fmt.Sprintf("log batch write enabled with size %d and interval %ds", common.LogBatchSize, common.LogBatchInterval))
+		model.InitLogWriter()
+	}
 
 	if os.Getenv("ENABLE_PPROF") == "true" {
 		gopool.Go(func() {
@@ -166,8 +200,27 @@ func main() {
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
 	}
-	err = server.Run(":" + port)
-	if err != nil {
-		common.FatalLog("failed to start HTTP server: " + err.Error())
+	httpServer := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
 	}
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	// 收到退出信号后先停止接收请求，再落库缓冲中的日志
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	common.SysLog("shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(common.GetEnvOrDefault("SHUTDOWN_TIMEOUT", 30))*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		common.SysError("server forced to shutdown: " + err.Error())
+	}
+	model.StopLogWriter()
+	model.SyncUserQuotaCounters()
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/model"
 	"strconv"
 	"strings"
@@ -159,6 +160,11 @@ func WssAuth(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		// IP 检查在令牌鉴权中进行，所有使用令牌的接口都会生效
+		if message := checkIpDenyList(c); message != "" {
+			abortWithOpenAiMessage(c, http.StatusForbidden, message)
+			return
+		}
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -234,8 +240,26 @@ func TokenAuth() func(c *gin.Context) {
 		} else {
 			c.Set("token_model_limit_enabled", false)
 		}
-		c.Set("allow_ips", token.GetIpLimitsMap())
+		if message := checkTokenAllowIps(c, token.GetAllowIpNets()); message != "" {
+			abortWithOpenAiMessage(c, http.StatusForbidden, message)
+			return
+		}
 		c.Set("token_group", token.Group)
+		if token.OrganizationId != 0 {
+			if !model.IsOrganizationEnabled(token.OrganizationId) {
+				abortWithOpenAiMessage(c, http.StatusForbidden, "令牌所属组织已被禁用")
+				return
+			}
+			c.Set(constant.ContextKeyOrganizationId, token.OrganizationId)
+		}
+		tokenSetting := token.GetSetting()
+		if secret := getTokenHMACSecret(tokenSetting); secret != "" {
+			if err := verifyHMACSignature(c, token.Id, secret); err != nil {
+				abortWithOpenAiMessage(c, http.StatusUnauthorized, err.Error())
+				return
+			}
+		}
+		c.Set(constant.ContextKeyTokenSetting, tokenSetting)
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set("specific_channel_id", parts[1])
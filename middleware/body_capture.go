@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// captureWriter 在写出响应的同时保留前 limit 字节，流式响应同样适用
+type captureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *captureWriter) capture(data []byte) {
+	remain := w.limit - w.body.Len()
+	if remain <= 0 {
+		if len(data) > 0 {
+			w.truncated = true
+		}
+		return
+	}
+	if len(data) > remain {
+		data = data[:remain]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// decodeCapturedBody 响应经过 gzip 压缩（网关压缩或透传上游编码）时解压后再保存，截断的压缩数据尽量解出已有部分
+func decodeCapturedBody(body []byte, encoding string, limit int) ([]byte, bool) {
+	if !strings.EqualFold(strings.TrimSpace(encoding), "gzip") || len(body) == 0 {
+		return body, false
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return []byte("[gzip body could not be decoded]"), false
+	}
+	defer reader.Close()
+	var decoded bytes.Buffer
+	_, err = io.Copy(&decoded, io.LimitReader(reader, int64(limit)+1))
+	truncated := decoded.Len() > limit
+	if truncated {
+		decoded.Truncate(limit)
+	}
+	if err != nil && decoded.Len() == 0 {
+		return []byte("[gzip body could not be decoded]"), false
+	}
+	return decoded.Bytes(), truncated
+}
+
+// BodyCapture 开启报文抓取时记录脱敏后的请求体与响应体，需位于 TokenAuth 之后以便按用户过滤
+func BodyCapture() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !operation_setting.ShouldCaptureBody(c.GetInt("id")) {
+			c.Next()
+			return
+		}
+		// 限制原始响应的缓冲大小，脱敏后再按 MaxBodyBytes 截断
+		writer := &captureWriter{ResponseWriter: c.Writer, limit: operation_setting.GetBodyCaptureSetting().MaxBodyBytes}
+		c.Writer = writer
+
+		c.Next()
+
+		var requestBody []byte
+		if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+			requestBody = []byte("[multipart body omitted]")
+		} else {
+			requestBody, _ = common.GetRequestBody(c)
+		}
+		capture := &model.RequestCapture{
+			RequestId:  c.GetString(common.RequestIdKey),
+			UserId:     c.GetInt("id"),
+			ChannelId:  c.GetInt("channel_id"),
+			ModelName:  c.GetString("original_model"),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			StatusCode: writer.Status(),
+		}
+		responseBody, truncated := decodeCapturedBody(writer.body.Bytes(), writer.Header().Get("Content-Encoding"), writer.limit)
+		truncated = truncated || writer.truncated
+		gopool.Go(func() {
+			service.SaveRequestCapture(capture, requestBody, responseBody, truncated)
+		})
+	}
+}
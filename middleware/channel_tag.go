@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getSettingStringList(setting map[string]interface{}, key string) ([]string, bool) {
+	items, ok := setting[key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			list = append(list, s)
+		}
+	}
+	return list, true
+}
+
+// getChannelTagPolicy 合并分组与令牌的路由标签策略：必需标签取并集，令牌配置了优先标签时覆盖分组的优先标签
+func getChannelTagPolicy(c *gin.Context, group string) *model.ChannelTagPolicy {
+	if policy, ok := c.Get(constant.ContextKeyChannelTagPolicy); ok {
+		return policy.(*model.ChannelTagPolicy)
+	}
+	tagSetting := operation_setting.GetChannelTagSetting()
+	policy := &model.ChannelTagPolicy{
+		Required:  append([]string(nil), tagSetting.GroupRequiredTags[group]...),
+		Preferred: tagSetting.GroupPreferredTags[group],
+	}
+	if tokenSetting, ok := c.Value(constant.ContextKeyTokenSetting).(map[string]interface{}); ok {
+		if required, ok := getSettingStringList(tokenSetting, constant.TokenSettingRequiredChannelTags); ok {
+			policy.Required = append(policy.Required, required...)
+		}
+		if preferred, ok := getSettingStringList(tokenSetting, constant.TokenSettingPreferredChannelTags); ok {
+			policy.Preferred = preferred
+		}
+	}
+	c.Set(constant.ContextKeyChannelTagPolicy, policy)
+	return policy
+}
+
+// CacheGetRandomSatisfiedChannel 按请求的路由标签策略选择渠道，选择渠道的地方都应通过这里调用
+func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, modelName string, retry int, excludeChannelIds map[int]bool) (*model.Channel, error) {
+	return model.CacheGetRandomSatisfiedChannelWithTags(group, modelName, retry, excludeChannelIds, getChannelTagPolicy(c, group))
+}
@@ -11,6 +11,7 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 	"time"
@@ -24,21 +25,24 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		allowIpsMap := c.GetStringMap("allow_ips")
-		if len(allowIpsMap) != 0 {
-			clientIp := c.ClientIP()
-			if _, ok := allowIpsMap[clientIp]; !ok {
-				abortWithOpenAiMessage(c, http.StatusForbidden, "您的 IP 不在令牌允许访问的列表中")
-				return
-			}
-		}
 		var channel *model.Channel
 		channelId, ok := c.Get("specific_channel_id")
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			abortWithOpenAiMessage(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体过大，最大允许 %d 字节", maxBytesErr.Limit))
+			return
+		}
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusBadRequest, "Invalid request, "+err.Error())
 			return
 		}
+		if tokenSetting, ok := c.Value(constant.ContextKeyTokenSetting).(map[string]interface{}); ok {
+			if err := service.CheckTokenScope(tokenSetting, c.Request.Method, c.Request.URL.Path); err != nil {
+				abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+				return
+			}
+		}
 		userGroup := c.GetString(constant.ContextKeyUserGroup)
 		tokenGroup := c.GetString("token_group")
 		if tokenGroup != "" {
@@ -55,6 +59,10 @@ func Distribute() func(c *gin.Context) {
 			userGroup = tokenGroup
 		}
 		c.Set("group", userGroup)
+		if modelRequest.Model != "" && !operation_setting.IsGroupModelAllowed(userGroup, modelRequest.Model) {
+			abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("分组 %s 无权访问模型 %s", userGroup, modelRequest.Model))
+			return
+		}
 		if ok {
 			id, err := strconv.Atoi(channelId.(string))
 			if err != nil {
@@ -83,7 +91,7 @@ func Distribute() func(c *gin.Context) {
 					tokenModelLimit = map[string]bool{}
 				}
 				if tokenModelLimit != nil {
-					if _, ok := tokenModelLimit[modelRequest.Model]; !ok {
+					if !model.ModelLimitsAllow(tokenModelLimit, modelRequest.Model) {
 						abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌无权访问模型 "+modelRequest.Model)
 						return
 					}
@@ -95,7 +103,33 @@ func Distribute() func(c *gin.Context) {
 			}
 
 			if shouldSelectChannel {
-				channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, modelRequest.Model, 0)
+				// 引用 Gemini 上下文缓存的请求只能发往创建缓存的渠道
+				channel, err = getCachedContentChannel(c)
+				if err != nil {
+					abortWithOpenAiMessage(c, http.StatusBadRequest, err.Error())
+					return
+				}
+			}
+			if shouldSelectChannel && channel == nil {
+				if stickyKey := getStickyRoutingKey(c, userGroup, modelRequest.Model); stickyKey != "" {
+					c.Set(constant.ContextKeyStickyRoutingKey, stickyKey)
+					channel = model.GetStickyChannel(stickyKey, userGroup, modelRequest.Model)
+					if channel != nil && !getChannelTagPolicy(c, userGroup).Allows(channel) {
+						// 路由标签策略变更后不再使用原来绑定的渠道
+						channel = nil
+					}
+				}
+			}
+			if shouldSelectChannel && channel == nil {
+				channel, err = CacheGetRandomSatisfiedChannel(c, userGroup, modelRequest.Model, 0, nil)
+				if err != nil && channel == nil {
+					// 主模型无可用渠道时尝试降级模型
+					if fallbackChannel, fallbackModel := SelectFallbackChannel(c, userGroup, modelRequest.Model); fallbackChannel != nil {
+						common.LogInfo(c, fmt.Sprintf("模型 %s 无可用渠道，降级为 %s", modelRequest.Model, fallbackModel))
+						channel, err = fallbackChannel, nil
+						modelRequest.Model = fallbackModel
+					}
+				}
 				if err != nil {
 					message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", userGroup, modelRequest.Model)
 					// 如果错误，但是渠道不为空，说明是数据库一致性问题
@@ -123,6 +157,11 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	var modelRequest ModelRequest
 	shouldSelectChannel := true
 	var err error
+	if isMultipartRequest(c) {
+		if err = parseMultipartRequest(c); err != nil {
+			return nil, false, err
+		}
+	}
 	if strings.Contains(c.Request.URL.Path, "/mj/") {
 		relayMode := relayconstant.Path2RelayModeMidjourney(c.Request.URL.Path)
 		if relayMode == relayconstant.RelayModeMidjourneyTaskFetch ||
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	GatewayHeaderChannelId       = "X-Gateway-Channel-Id"
+	GatewayHeaderUpstreamLatency = "X-Gateway-Upstream-Latency"
+	GatewayHeaderCost            = "X-Gateway-Cost"
+)
+
+// gatewayHeaderWriter 缓存非流式响应，请求结束时带上最终的渠道、上游耗时与费用响应头再写出；
+// 流式响应（调用 Flush 或 SSE）在首次输出时写入渠道与耗时，费用在结束时通过 HTTP Trailer 返回
+type gatewayHeaderWriter struct {
+	gin.ResponseWriter
+	c           *gin.Context
+	status      int
+	buffer      bytes.Buffer
+	passthrough bool
+}
+
+func (w *gatewayHeaderWriter) setMetadataHeaders() {
+	header := w.ResponseWriter.Header()
+	if channelId := w.c.GetInt("channel_id"); channelId != 0 {
+		header.Set(GatewayHeaderChannelId, strconv.Itoa(channelId))
+	}
+	if latency, ok := w.c.Value(constant.ContextKeyUpstreamLatency).(time.Duration); ok {
+		header.Set(GatewayHeaderUpstreamLatency, strconv.FormatInt(latency.Milliseconds(), 10))
+	}
+}
+
+func (w *gatewayHeaderWriter) setCostHeader() {
+	if quota, ok := w.c.Value(constant.ContextKeyConsumedQuota).(int); ok {
+		w.ResponseWriter.Header().Set(GatewayHeaderCost, strconv.Itoa(quota))
+	}
+}
+
+func (w *gatewayHeaderWriter) isStream() bool {
+	return strings.HasPrefix(w.ResponseWriter.Header().Get("Content-Type"), "text/event-stream")
+}
+
+func (w *gatewayHeaderWriter) startPassthrough() {
+	w.passthrough = true
+	w.setMetadataHeaders()
+	// 流式响应尚未计费，费用作为 Trailer 在响应结束时返回
+	w.ResponseWriter.Header().Add("Trailer", GatewayHeaderCost)
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buffer.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+}
+
+func (w *gatewayHeaderWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *gatewayHeaderWriter) WriteHeaderNow() {
+	if !w.passthrough && w.isStream() {
+		w.startPassthrough()
+	}
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *gatewayHeaderWriter) Write(data []byte) (int, error) {
+	if !w.passthrough && w.isStream() {
+		w.startPassthrough()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buffer.Write(data)
+}
+
+func (w *gatewayHeaderWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gatewayHeaderWriter) Written() bool {
+	return w.buffer.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *gatewayHeaderWriter) Status() int {
+	if !w.passthrough && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *gatewayHeaderWriter) Flush() {
+	if !w.passthrough {
+		// 主动刷新说明是流式输出，不再缓存
+		w.startPassthrough()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish 请求结束时调用：非流式响应带上全部响应头写出，流式响应写入费用 Trailer
+func (w *gatewayHeaderWriter) finish() {
+	if w.passthrough {
+		w.setCostHeader()
+		return
+	}
+	if w.status == 0 && w.buffer.Len() == 0 {
+		return
+	}
+	w.setMetadataHeaders()
+	w.setCostHeader()
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buffer.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+	}
+}
+
+// isGatewayHeaderTrusted 仅对管理员或额外信任的令牌返回调试响应头，避免向普通用户暴露渠道信息
+func isGatewayHeaderTrusted(c *gin.Context) bool {
+	setting := operation_setting.GetGatewayHeaderSetting()
+	if !setting.Enabled {
+		return false
+	}
+	if operation_setting.IsTrustedGatewayHeaderToken(c.GetInt("token_id")) {
+		return true
+	}
+	return setting.AdminTokens && model.IsAdmin(c.GetInt("id"))
+}
+
+// GatewayHeaders 为可信令牌在响应中返回实际使用的渠道、上游耗时（毫秒）与消耗的额度，便于排查问题而无需查看日志
+func GatewayHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isGatewayHeaderTrusted(c) {
+			c.Next()
+			return
+		}
+		writer := &gatewayHeaderWriter{ResponseWriter: c.Writer, c: c}
+		c.Writer = writer
+		c.Next()
+		writer.finish()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cachedContentRequest struct {
+	CachedContent string `json:"cached_content"`
+}
+
+// getCachedContentChannel 请求引用了 Gemini 上下文缓存时返回创建该缓存的渠道，缓存只存在于该渠道；未引用时返回 nil
+func getCachedContentChannel(c *gin.Context) (*model.Channel, error) {
+	if c.Request.Method != http.MethodPost || !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return nil, nil
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil || !bytes.Contains(body, []byte(`"cached_content"`)) {
+		return nil, nil
+	}
+	var request cachedContentRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil || request.CachedContent == "" {
+		return nil, nil
+	}
+	cache, err := model.GetGeminiCachedContent(c.GetInt("id"), dto.GeminiCachedContentName(request.CachedContent))
+	if err != nil {
+		return nil, fmt.Errorf("上下文缓存 %s 不存在", request.CachedContent)
+	}
+	if cache.IsExpired() {
+		return nil, fmt.Errorf("上下文缓存 %s 已过期", request.CachedContent)
+	}
+	channel, err := model.CacheGetChannel(cache.ChannelId)
+	if err != nil || channel.Status != common.ChannelStatusEnabled {
+		return nil, fmt.Errorf("上下文缓存 %s 所属的渠道不可用", request.CachedContent)
+	}
+	c.Set(constant.ContextKeyCachedContent, cache.Name)
+	return channel, nil
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ipDenyListCache struct {
+	sync.Mutex
+	source string
+	nets   []*net.IPNet
+}
+
+// getIpDenyNets 配置未变化时复用已解析的网段，无效的条目会被忽略
+func getIpDenyNets() []*net.IPNet {
+	source := strings.Join(operation_setting.GetIpAccessSetting().DenyList, "\n")
+	ipDenyListCache.Lock()
+	defer ipDenyListCache.Unlock()
+	if source != ipDenyListCache.source {
+		ipDenyListCache.source = source
+		ipDenyListCache.nets = nil
+		for _, entry := range common.SplitIPEntries(source) {
+			ipNet, err := common.ParseIPNet(entry)
+			if err != nil {
+				common.SysError("invalid ip deny list entry: " + err.Error())
+				continue
+			}
+			ipDenyListCache.nets = append(ipDenyListCache.nets, ipNet)
+		}
+	}
+	return ipDenyListCache.nets
+}
+
+// checkIpDenyList 检查客户端 IP 是否命中全局黑名单，拒绝时返回提示信息
+func checkIpDenyList(c *gin.Context) string {
+	if denyNets := getIpDenyNets(); len(denyNets) != 0 && common.IPInNets(c.ClientIP(), denyNets) {
+		return "您的 IP 已被禁止访问"
+	}
+	return ""
+}
+
+// checkTokenAllowIps 检查客户端 IP 是否在令牌白名单内，白名单为空时不限制
+func checkTokenAllowIps(c *gin.Context, allowNets []*net.IPNet) string {
+	if len(allowNets) != 0 && !common.IPInNets(c.ClientIP(), allowNets) {
+		return "您的 IP 不在令牌允许访问的列表中"
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoadShedding 转发协程池饱和时拒绝新的转发请求并返回 503 与 Retry-After，避免排队任务无限增长；
+// 协程池大小在每次请求时按配置更新，修改配置后无需重启
+func LoadShedding() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setting := operation_setting.GetWorkerPoolSetting()
+		common.SetGoPoolSize(setting.RelayPoolSize, setting.BackgroundPoolSize)
+		if setting.RejectPolicy == operation_setting.WorkerPoolRejectPolicyReject && common.RelayPoolSaturated(setting.QueueLength) {
+			if setting.RetryAfterSeconds > 0 {
+				c.Header("Retry-After", strconv.Itoa(setting.RetryAfterSeconds))
+			}
+			abortWithOpenAiMessage(c, http.StatusServiceUnavailable, "服务器繁忙，请稍后重试")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenAllowsModel 降级模型同样需要满足令牌的模型限制，分组的允许模型在 SelectFallbackChannel 中检查
+func tokenAllowsModel(c *gin.Context, modelName string) bool {
+	if c.GetBool("token_model_limit_enabled") {
+		tokenModelLimit, _ := c.Value("token_model_limit").(map[string]bool)
+		if !model.ModelLimitsAllow(tokenModelLimit, modelName) {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectFallbackChannel 按降级链顺序选择第一个未尝试过且有可用渠道的模型并切换请求上下文，
+// 降级链始终以客户端请求的模型为准；已尝试的模型记录在上下文中，切换次数超过上限或无可用降级模型时返回 nil
+func SelectFallbackChannel(c *gin.Context, group string, currentModel string) (*model.Channel, string) {
+	requestedModel := c.GetString(constant.ContextKeyRequestedModel)
+	if requestedModel == "" {
+		requestedModel = currentModel
+	}
+	tried := c.GetStringSlice(constant.ContextKeyFallbackTried)
+	if len(tried) >= operation_setting.MaxModelFallbackSwitches {
+		return nil, ""
+	}
+	triedSet := map[string]bool{requestedModel: true, currentModel: true}
+	for _, modelName := range tried {
+		triedSet[modelName] = true
+	}
+	for _, fallback := range operation_setting.GetModelFallbacks(requestedModel) {
+		if triedSet[fallback] || !tokenAllowsModel(c, fallback) || !operation_setting.IsGroupModelAllowed(group, fallback) {
+			continue
+		}
+		channel, err := CacheGetRandomSatisfiedChannel(c, group, fallback, 0, nil)
+		if err != nil || channel == nil {
+			continue
+		}
+		c.Set(constant.ContextKeyRequestedModel, requestedModel)
+		c.Set(constant.ContextKeyFallbackTried, append(tried, fallback))
+		SetupContextForSelectedChannel(c, channel, fallback)
+		return channel, fallback
+	}
+	return nil, ""
+}
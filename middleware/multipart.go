@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isMultipartRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// parseMultipartRequest 解析 multipart 请求，超过 MaxMemoryBytes 的文件内容写入临时文件，不再整体读入内存；
+// 请求体超过对应类型的大小上限时返回 *http.MaxBytesError。解析结果缓存在 c.Request.MultipartForm 中，
+// 后续读取表单与切换渠道重试时复用，临时文件在请求结束后由 net/http 清理
+func parseMultipartRequest(c *gin.Context) error {
+	setting := operation_setting.GetMultipartSetting()
+	maxBytes := setting.MaxFileBytes
+	switch path := c.Request.URL.Path; {
+	case strings.HasPrefix(path, "/v1/audio/"):
+		maxBytes = setting.MaxAudioBytes
+	case strings.HasPrefix(path, "/v1/images/"):
+		maxBytes = setting.MaxImageBytes
+	}
+	if maxBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes))
+	}
+	maxMemory := int64(setting.MaxMemoryBytes)
+	if maxMemory <= 0 {
+		maxMemory = 32 << 20
+	}
+	return c.Request.ParseMultipartForm(maxMemory)
+}
@@ -6,6 +6,24 @@ import (
 	"one-api/common"
 )
 
+// 客户端传入的请求 ID 最大长度
+const maxClientRequestIdLength = 64
+
+// isValidClientRequestId 客户端请求 ID 只允许字母、数字与 -_.:，避免写入日志与响应头时注入内容
+func isValidClientRequestId(id string) bool {
+	if id == "" || len(id) > maxClientRequestIdLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestId 生成网关请求 ID，客户端通过 X-Request-Id 传入的合法 ID 单独保存并原样回显，便于与客户端日志关联；
+// 网关请求 ID 始终由网关生成，不受客户端控制
 func RequestId() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		id := common.GetTimeString() + common.GetRandomString(8)
@@ -13,6 +31,12 @@ func RequestId() func(c *gin.Context) {
 		ctx := context.WithValue(c.Request.Context(), common.RequestIdKey, id)
 		c.Request = c.Request.WithContext(ctx)
 		c.Header(common.RequestIdKey, id)
+		if clientId := c.GetHeader(common.RequestIdHeader); isValidClientRequestId(clientId) {
+			c.Set(common.ClientRequestIdKey, clientId)
+			c.Header(common.RequestIdHeader, clientId)
+		} else {
+			c.Header(common.RequestIdHeader, id)
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressResponseWriter 先缓存响应体，达到阈值后改为 gzip 输出；流式响应（调用 Flush 或 SSE）以及
+// 已带 Content-Encoding 的响应原样透传，未达到阈值的响应在请求结束时原样写出
+type compressResponseWriter struct {
+	gin.ResponseWriter
+	minBytes    int
+	status      int
+	buffer      bytes.Buffer
+	gzipWriter  *gzip.Writer
+	passthrough bool
+}
+
+func (w *compressResponseWriter) decided() bool {
+	return w.passthrough || w.gzipWriter != nil
+}
+
+func (w *compressResponseWriter) shouldPassthrough() bool {
+	header := w.ResponseWriter.Header()
+	return header.Get("Content-Encoding") != "" || strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *compressResponseWriter) writeStatus() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *compressResponseWriter) startPassthrough() {
+	w.passthrough = true
+	w.writeStatus()
+	if w.buffer.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+}
+
+func (w *compressResponseWriter) startGzip() {
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Encoding", "gzip")
+	header.Add("Vary", "Accept-Encoding")
+	header.Del("Content-Length")
+	w.writeStatus()
+	w.gzipWriter = gzip.NewWriter(w.ResponseWriter)
+	_, _ = w.gzipWriter.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.decided() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *compressResponseWriter) WriteHeaderNow() {
+	if !w.decided() && w.shouldPassthrough() {
+		w.startPassthrough()
+	}
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressResponseWriter) Write(data []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.gzipWriter != nil {
+		return w.gzipWriter.Write(data)
+	}
+	if w.shouldPassthrough() {
+		w.startPassthrough()
+		return w.ResponseWriter.Write(data)
+	}
+	n, err := w.buffer.Write(data)
+	if w.buffer.Len() >= w.minBytes {
+		w.startGzip()
+	}
+	return n, err
+}
+
+func (w *compressResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressResponseWriter) Written() bool {
+	return w.buffer.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressResponseWriter) Status() int {
+	if !w.decided() && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressResponseWriter) Flush() {
+	if !w.decided() {
+		// 主动刷新说明是流式输出，不再压缩
+		w.startPassthrough()
+	}
+	if w.gzipWriter != nil {
+		_ = w.gzipWriter.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish 请求结束时调用：结束 gzip 输出，或将未达到阈值的响应原样写出
+func (w *compressResponseWriter) finish() {
+	if w.gzipWriter != nil {
+		_ = w.gzipWriter.Close()
+		return
+	}
+	if !w.passthrough && (w.status != 0 || w.buffer.Len() > 0) {
+		w.startPassthrough()
+	}
+}
+
+// acceptsGzip 解析客户端的 Accept-Encoding，gzip 的 q 值为 0 时视为不支持
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		params = strings.TrimSpace(params)
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ResponseCompression 客户端支持 gzip 时压缩达到阈值的非流式响应，主要用于体积较大的 embedding / 批量结果
+func ResponseCompression() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setting := operation_setting.GetCompressionSetting()
+		if !setting.ResponseEnabled || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		writer := &compressResponseWriter{ResponseWriter: c.Writer, minBytes: max(setting.ResponseMinBytes, 1)}
+		c.Writer = writer
+		c.Next()
+		writer.finish()
+	}
+}
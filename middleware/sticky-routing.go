@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type stickyRoutingRequest struct {
+	User           string            `json:"user"`
+	PromptCacheKey string            `json:"prompt_cache_key"`
+	System         json.RawMessage   `json:"system"`
+	Instructions   string            `json:"instructions"`
+	Messages       []json.RawMessage `json:"messages"`
+}
+
+// getStickyRoutingKey 计算会话标识，依次使用 X-Session-Id 请求头、prompt_cache_key、user 字段，
+// 均未提供时使用系统提示词与首条消息的哈希，同一对话的后续请求会得到相同的标识
+func getStickyRoutingKey(c *gin.Context, group string, modelName string) string {
+	if !operation_setting.GetStickyRoutingSetting().Enabled {
+		return ""
+	}
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return ""
+	}
+	session := c.Request.Header.Get("X-Session-Id")
+	if session == "" {
+		var request stickyRoutingRequest
+		if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+			return ""
+		}
+		switch {
+		case request.PromptCacheKey != "":
+			session = "prompt_cache_key:" + request.PromptCacheKey
+		case request.User != "":
+			session = "user:" + request.User
+		case len(request.Messages) > 0:
+			prefix := string(request.System) + request.Instructions + string(request.Messages[0])
+			session = "prefix:" + prefix
+		default:
+			return ""
+		}
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s|%s", c.GetInt("id"), group, modelName, session)))
+	return hex.EncodeToString(sum[:])
+}
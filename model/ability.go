@@ -3,7 +3,9 @@ package model
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 	"one-api/common"
+	"one-api/setting/operation_setting"
 	"strings"
 
 	"github.com/samber/lo"
@@ -24,7 +26,21 @@ func GetGroupModels(group string) []string {
 	var models []string
 	// Find distinct models
 	DB.Table("abilities").Where(groupCol+" = ? and enabled = ?", group, true).Distinct("model").Pluck("model", &models)
-	return models
+	// 过滤分组不允许使用的模型
+	allowed := models[:0]
+	for _, modelName := range models {
+		if operation_setting.IsGroupModelAllowed(group, modelName) {
+			allowed = append(allowed, modelName)
+		}
+	}
+	return allowed
+}
+
+// CountGroupChannels 统计分组下的渠道数
+func CountGroupChannels(group string) (int64, error) {
+	var count int64
+	err := DB.Model(&Ability{}).Where(groupCol+" = ?", group).Distinct("channel_id").Count(&count).Error
+	return count, err
 }
 
 func GetEnabledModels() []string {
@@ -40,16 +56,20 @@ func GetAllEnableAbilities() []Ability {
 	return abilities
 }
 
-func getPriority(group string, model string, retry int) (int, error) {
+func getPriority(group string, model string, retry int, excludeChannelIds []int) (int, error) {
 	trueVal := "1"
 	if common.UsingPostgreSQL {
 		trueVal = "true"
 	}
 
 	var priorities []int
-	err := DB.Model(&Ability{}).
+	query := DB.Model(&Ability{}).
 		Select("DISTINCT(priority)").
-		Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model).
+		Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
+	if len(excludeChannelIds) > 0 {
+		query = query.Where("channel_id NOT IN ?", excludeChannelIds)
+	}
+	err := query.
 		Order("priority DESC").              // 按优先级降序排序
 		Pluck("priority", &priorities).Error // Pluck用于将查询的结果直接扫描到一个切片中
 
@@ -74,30 +94,52 @@ func getPriority(group string, model string, retry int) (int, error) {
 	return priorityToUse, nil
 }
 
-func getChannelQuery(group string, model string, retry int) *gorm.DB {
+func getChannelQuery(group string, model string, retry int, excludeChannelIds []int) *gorm.DB {
 	trueVal := "1"
 	if common.UsingPostgreSQL {
 		trueVal = "true"
 	}
 	maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
+	if len(excludeChannelIds) > 0 {
+		maxPrioritySubQuery = maxPrioritySubQuery.Where("channel_id NOT IN ?", excludeChannelIds)
+	}
 	channelQuery := DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = (?)", group, model, maxPrioritySubQuery)
 	if retry != 0 {
-		priority, err := getPriority(group, model, retry)
+		priority, err := getPriority(group, model, retry, excludeChannelIds)
 		if err != nil {
 			common.SysError(fmt.Sprintf("Get priority failed: %s", err.Error()))
 		} else {
 			channelQuery = DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = ?", group, model, priority)
 		}
 	}
+	if len(excludeChannelIds) > 0 {
+		channelQuery = channelQuery.Where("channel_id NOT IN ?", excludeChannelIds)
+	}
 
 	return channelQuery
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+func IsAbilityEnabled(group string, model string, channelId int) bool {
+	trueVal := "1"
+	if common.UsingPostgreSQL {
+		trueVal = "true"
+	}
+	var count int64
+	err := DB.Model(&Ability{}).
+		Where(groupCol+" = ? and model = ? and channel_id = ? and enabled = "+trueVal, group, model, channelId).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+func GetRandomSatisfiedChannel(group string, model string, retry int, excludeChannelIds map[int]bool) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
-	channelQuery := getChannelQuery(group, model, retry)
+	excludeIds := make([]int, 0, len(excludeChannelIds))
+	for id := range excludeChannelIds {
+		excludeIds = append(excludeIds, id)
+	}
+	channelQuery := getChannelQuery(group, model, retry, excludeIds)
 	if common.UsingSQLite || common.UsingPostgreSQL {
 		err = channelQuery.Order("weight DESC").Find(&abilities).Error
 	} else {
@@ -108,17 +150,23 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	}
 	channel := Channel{}
 	if len(abilities) > 0 {
-		// Randomly choose one
-		weightSum := uint(0)
-		for _, ability_ := range abilities {
-			weightSum += ability_.Weight + 10
+		channelIds := make([]int, len(abilities))
+		weights := make([]float64, len(abilities))
+		for i, ability_ := range abilities {
+			channelIds[i] = ability_.ChannelId
+			weights[i] = float64(ability_.Weight + 10)
+		}
+		weights = getLatencyAwareWeights(channelIds, model, weights)
+		weightSum := 0.0
+		for _, weight := range weights {
+			weightSum += weight
 		}
 		// Randomly choose one
-		weight := common.GetRandomInt(int(weightSum))
-		for _, ability_ := range abilities {
-			weight -= int(ability_.Weight) + 10
-			//log.Printf("weight: %d, ability weight: %d", weight, *ability_.Weight)
-			if weight <= 0 {
+		weight := rand.Float64() * weightSum
+		channel.Id = abilities[len(abilities)-1].ChannelId
+		for i, ability_ := range abilities {
+			weight -= weights[i]
+			if weight < 0 {
 				channel.Id = ability_.ChannelId
 				break
 			}
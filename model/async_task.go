@@ -0,0 +1,187 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/constant"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	AsyncTaskStatusQueued     = "queued"
+	AsyncTaskStatusInProgress = "in_progress"
+	AsyncTaskStatusSucceeded  = "succeeded"
+	AsyncTaskStatusFailed     = "failed"
+)
+
+// AsyncTaskKindVideo /v1/video/generations 提交的视频生成任务
+const AsyncTaskKindVideo = "video"
+
+// AsyncTask 通用异步任务（视频、音乐生成、批处理等），提交时按预估用量预扣额度，
+// 后台轮询上游直到任务结束，按实际用量结算并回调 WebhookUrl
+type AsyncTask struct {
+	Id             int64   `json:"id"`
+	TaskId         string  `json:"task_id" gorm:"type:varchar(64);uniqueIndex"` // 返回给客户端的任务 ID
+	UpstreamTaskId string  `json:"upstream_task_id" gorm:"type:varchar(128);index"`
+	Kind           string  `json:"kind" gorm:"type:varchar(32);index"`
+	ModelName      string  `json:"model_name" gorm:"index"`
+	UserId         int     `json:"user_id" gorm:"index"`
+	TokenId        int     `json:"token_id"`
+	OrganizationId int     `json:"organization_id" gorm:"default:0"`
+	ChannelId      int     `json:"channel_id" gorm:"index"`
+	Group          string  `json:"group" gorm:"type:varchar(64)"`
+	Status         string  `json:"status" gorm:"type:varchar(20);index"`
+	Progress       int     `json:"progress"`
+	ModelPrice     float64 `json:"model_price"`
+	GroupRatio     float64 `json:"group_ratio"`
+	Units          float64 `json:"units"`
+	HeldQuota      int     `json:"held_quota"` // 提交时预扣的额度
+	Quota          int     `json:"quota"`      // 结算后实际消耗的额度
+	Input          string  `json:"input" gorm:"type:text"`
+	Result         string  `json:"result" gorm:"type:text"`
+	FailReason     string  `json:"fail_reason"`
+	WebhookUrl     string  `json:"webhook_url" gorm:"type:varchar(512)"`
+	CreatedAt      int64   `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt      int64   `json:"updated_at" gorm:"bigint"`
+	FinishedAt     int64   `json:"finished_at" gorm:"bigint"`
+	NextPollAt     int64   `json:"next_poll_at" gorm:"bigint;index"`
+
+	// Platform 提交时使用的上游协议，轮询时据此选择适配器
+	Platform constant.TaskPlatform `json:"platform" gorm:"type:varchar(30);default:'generic'"`
+}
+
+func (task *AsyncTask) IsFinished() bool {
+	return task.Status == AsyncTaskStatusSucceeded || task.Status == AsyncTaskStatusFailed
+}
+
+func (task *AsyncTask) Insert() error {
+	now := common.GetTimestamp()
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	return DB.Create(task).Error
+}
+
+func GetAsyncTaskByTaskId(userId int, taskId string) (*AsyncTask, error) {
+	if taskId == "" {
+		return nil, errors.New("任务 ID 为空")
+	}
+	var task AsyncTask
+	err := DB.Where("user_id = ? and task_id = ?", userId, taskId).First(&task).Error
+	return &task, err
+}
+
+func GetUserAsyncTasks(userId int, status string, startIdx int, num int) (tasks []*AsyncTask, total int64, err error) {
+	tx := DB.Model(&AsyncTask{}).Where("user_id = ?", userId)
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
+	return tasks, total, err
+}
+
+func GetAllAsyncTasks(userId int, channelId int, status string, startIdx int, num int) (tasks []*AsyncTask, total int64, err error) {
+	tx := DB.Model(&AsyncTask{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
+	return tasks, total, err
+}
+
+// GetDueAsyncTasks 返回未结束且到达轮询时间的任务
+func GetDueAsyncTasks(limit int) ([]*AsyncTask, error) {
+	var tasks []*AsyncTask
+	err := DB.Where("status in ? and next_poll_at <= ?", []string{AsyncTaskStatusQueued, AsyncTaskStatusInProgress}, common.GetTimestamp()).
+		Order("next_poll_at").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// UpdateAsyncTaskProgress 更新未结束任务的状态与进度，并设置下一次轮询时间
+func UpdateAsyncTaskProgress(task *AsyncTask) error {
+	return DB.Model(&AsyncTask{}).Where("id = ? and status in ?", task.Id, []string{AsyncTaskStatusQueued, AsyncTaskStatusInProgress}).
+		Updates(map[string]interface{}{
+			"status":       task.Status,
+			"progress":     task.Progress,
+			"next_poll_at": task.NextPollAt,
+			"updated_at":   common.GetTimestamp(),
+		}).Error
+}
+
+// FinishAsyncTask 以条件更新将任务置为结束状态，返回 false 表示任务已被其他节点结束，调用方不应重复结算
+func FinishAsyncTask(task *AsyncTask) (bool, error) {
+	now := common.GetTimestamp()
+	task.FinishedAt = now
+	task.UpdatedAt = now
+	result := DB.Model(&AsyncTask{}).Where("id = ? and status in ?", task.Id, []string{AsyncTaskStatusQueued, AsyncTaskStatusInProgress}).
+		Updates(map[string]interface{}{
+			"status":      task.Status,
+			"progress":    task.Progress,
+			"units":       task.Units,
+			"quota":       task.Quota,
+			"result":      task.Result,
+			"fail_reason": task.FailReason,
+			"finished_at": now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateAsyncTaskUsedQuota 结算时按差额修正用户与渠道的已用额度，delta 为负表示退还
+func UpdateAsyncTaskUsedQuota(task *AsyncTask, delta int) {
+	updateUserUsedQuota(task.UserId, delta)
+	UpdateChannelUsedQuota(task.ChannelId, delta)
+}
+
+// RecordAsyncTaskConsumeLog 结算时补扣的额度记录为消费日志，结算在后台进行，没有请求上下文
+func RecordAsyncTaskConsumeLog(task *AsyncTask, quota int, content string, other map[string]interface{}) {
+	if !common.LogConsumeEnabled {
+		return
+	}
+	username, _ := GetUsernameById(task.UserId, false)
+	tokenName := ""
+	if token, err := GetTokenById(task.TokenId); err == nil {
+		tokenName = token.Name
+	}
+	log := &Log{
+		UserId:    task.UserId,
+		Username:  username,
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeConsume,
+		Content:   content,
+		TokenName: tokenName,
+		ModelName: task.ModelName,
+		Quota:     quota,
+		ChannelId: task.ChannelId,
+		TokenId:   task.TokenId,
+		Group:     task.Group,
+		Other:     common.MapToJsonStr(other),
+	}
+	if !enqueueLog(log) {
+		if err := LOG_DB.Create(log).Error; err != nil {
+			common.SysError("failed to record log: " + err.Error())
+		}
+	}
+	common.MetricConsume(task.ChannelId, task.ModelName, task.Group, 0, 0, quota)
+	gopool.Go(func() {
+		if common.DataExportEnabled {
+			LogQuotaData(task.UserId, username, task.ModelName, quota, common.GetTimestamp(), 0)
+		}
+		LogCostStat(task.UserId, username, task.TokenId, tokenName, task.ChannelId, task.ModelName, 0, 0, quota, common.GetTimestamp())
+	})
+}
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+const (
+	BudgetScopeUser  = "user"
+	BudgetScopeToken = "token"
+	BudgetScopeGroup = "group"
+
+	BudgetPeriodDay   = "day"
+	BudgetPeriodMonth = "month"
+
+	BudgetStatusEnabled  = 1
+	BudgetStatusDisabled = 2
+)
+
+// Budget 挂载在用户、令牌或分组上的周期预算，消耗达到 AlertThreshold 比例时发送提醒，
+// 开启 HardCap 时消耗达到 Quota 后拒绝后续请求
+type Budget struct {
+	Id             int     `json:"id"`
+	Name           string  `json:"name" gorm:"index"`
+	Scope          string  `json:"scope" gorm:"type:varchar(16);index:idx_budget_target,priority:1"`
+	TargetId       int     `json:"target_id" gorm:"index:idx_budget_target,priority:2"` // 用户或令牌 ID
+	Group          string  `json:"group" gorm:"type:varchar(64);default:''"`            // 分组预算对应的分组
+	Period         string  `json:"period" gorm:"type:varchar(16)"`
+	Quota          int     `json:"quota"`
+	AlertThreshold float64 `json:"alert_threshold"` // 0~1，0 表示不提醒
+	HardCap        bool    `json:"hard_cap"`
+	WebhookUrl     string  `json:"webhook_url" gorm:"default:''"` // 为空时按预算所属用户的通知设置发送
+	Status         int     `json:"status" gorm:"default:1"`
+	AlertedPeriod  int64   `json:"alerted_period" gorm:"bigint;default:0"` // 已发送提醒的周期起始时间
+	CreatedTime    int64   `json:"created_time" gorm:"bigint"`
+}
+
+var budgetCache []*Budget
+var budgetCacheTime time.Time
+var budgetCacheLock sync.RWMutex
+
+func (budget *Budget) Validate() error {
+	switch budget.Scope {
+	case BudgetScopeUser, BudgetScopeToken:
+		if budget.TargetId == 0 {
+			return errors.New("预算目标不能为空")
+		}
+	case BudgetScopeGroup:
+		if budget.Group == "" {
+			return errors.New("预算分组不能为空")
+		}
+	default:
+		return errors.New("无效的预算范围")
+	}
+	if budget.Period != BudgetPeriodDay && budget.Period != BudgetPeriodMonth {
+		return errors.New("无效的预算周期")
+	}
+	if budget.Quota <= 0 {
+		return errors.New("预算额度必须大于 0")
+	}
+	if budget.AlertThreshold < 0 || budget.AlertThreshold > 1 {
+		return errors.New("提醒阈值必须在 0 到 1 之间")
+	}
+	return nil
+}
+
+// PeriodRange 返回 now 所在预算周期的起止时间
+func (budget *Budget) PeriodRange(now time.Time) (time.Time, time.Time) {
+	if budget.Period == BudgetPeriodMonth {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+func GetAllBudgets(startIdx int, num int) (budgets []*Budget, total int64, err error) {
+	err = DB.Model(&Budget{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&budgets).Error
+	return budgets, total, err
+}
+
+func GetBudgetById(id int) (*Budget, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	budget := Budget{Id: id}
+	err := DB.First(&budget, "id = ?", id).Error
+	return &budget, err
+}
+
+func (budget *Budget) Insert() error {
+	budget.CreatedTime = common.GetTimestamp()
+	err := DB.Create(budget).Error
+	InvalidateBudgetCache()
+	return err
+}
+
+func (budget *Budget) Update() error {
+	err := DB.Model(budget).Select("name", "scope", "target_id", "group", "period", "quota",
+		"alert_threshold", "hard_cap", "webhook_url", "status").Updates(budget).Error
+	InvalidateBudgetCache()
+	return err
+}
+
+func DeleteBudgetById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Delete(&Budget{}, "id = ?", id).Error
+	InvalidateBudgetCache()
+	return err
+}
+
+// MarkBudgetAlerted 标记本周期已发送提醒，多节点同时触发时只有一个节点返回 true
+func MarkBudgetAlerted(id int, periodStart int64) (bool, error) {
+	result := DB.Model(&Budget{}).Where("id = ? and alerted_period <> ?", id, periodStart).
+		Update("alerted_period", periodStart)
+	return result.RowsAffected == 1, result.Error
+}
+
+func InvalidateBudgetCache() {
+	budgetCacheLock.Lock()
+	defer budgetCacheLock.Unlock()
+	budgetCacheTime = time.Time{}
+}
+
+func getEnabledBudgets() ([]*Budget, error) {
+	budgetCacheLock.RLock()
+	if time.Since(budgetCacheTime) < time.Duration(common.SyncFrequency)*time.Second {
+		budgets := budgetCache
+		budgetCacheLock.RUnlock()
+		return budgets, nil
+	}
+	budgetCacheLock.RUnlock()
+
+	var budgets []*Budget
+	if err := DB.Where("status = ?", BudgetStatusEnabled).Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	budgetCacheLock.Lock()
+	budgetCache = budgets
+	budgetCacheTime = time.Now()
+	budgetCacheLock.Unlock()
+	return budgets, nil
+}
+
+// GetApplicableBudgets 返回对本次请求的用户、令牌与分组生效的预算
+func GetApplicableBudgets(userId int, tokenId int, group string) ([]*Budget, error) {
+	budgets, err := getEnabledBudgets()
+	if err != nil {
+		return nil, err
+	}
+	var applicable []*Budget
+	for _, budget := range budgets {
+		switch budget.Scope {
+		case BudgetScopeUser:
+			if budget.TargetId == userId {
+				applicable = append(applicable, budget)
+			}
+		case BudgetScopeToken:
+			if budget.TargetId == tokenId {
+				applicable = append(applicable, budget)
+			}
+		case BudgetScopeGroup:
+			if budget.Group == group {
+				applicable = append(applicable, budget)
+			}
+		}
+	}
+	return applicable, nil
+}
+
+// SumBudgetConsumedQuota 根据消费日志统计预算自 startTimestamp 起的消耗
+func SumBudgetConsumedQuota(budget *Budget, startTimestamp int64) (int64, error) {
+	var quota int64
+	tx := LOG_DB.Table("logs").Select("coalesce(sum(quota), 0)").
+		Where("type = ? and created_at >= ?", LogTypeConsume, startTimestamp)
+	switch budget.Scope {
+	case BudgetScopeUser:
+		tx = tx.Where("user_id = ?", budget.TargetId)
+	case BudgetScopeToken:
+		tx = tx.Where("token_id = ?", budget.TargetId)
+	case BudgetScopeGroup:
+		tx = tx.Where(groupCol+" = ?", budget.Group)
+	}
+	err := tx.Scan(&quota).Error
+	return quota, err
+}
@@ -72,41 +72,71 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// normalizeSelectModel GPTs 模型统一按通配的能力选择渠道
+func normalizeSelectModel(model string) string {
 	if strings.HasPrefix(model, "gpt-4-gizmo") {
-		model = "gpt-4-gizmo-*"
+		return "gpt-4-gizmo-*"
 	}
 	if strings.HasPrefix(model, "gpt-4o-gizmo") {
-		model = "gpt-4o-gizmo-*"
+		return "gpt-4o-gizmo-*"
 	}
+	return model
+}
+
+// CacheGetRandomSatisfiedChannel 按优先级和权重随机选择渠道，excludeChannelIds 中的渠道（如本次请求已失败的渠道）不参与选择
+func CacheGetRandomSatisfiedChannel(group string, model string, retry int, excludeChannelIds map[int]bool) (*Channel, error) {
+	model = normalizeSelectModel(model)
 
+	// 跳过已熔断的渠道；若可选渠道均已熔断，则忽略熔断状态继续选择，避免整体不可用
+	if openChannelIds := GetOpenCircuitChannelIds(); len(openChannelIds) > 0 {
+		for id := range excludeChannelIds {
+			openChannelIds[id] = true
+		}
+		channel, err := cacheGetRandomSatisfiedChannel(group, model, retry, openChannelIds)
+		if err == nil {
+			return channel, nil
+		}
+	}
+	return cacheGetRandomSatisfiedChannel(group, model, retry, excludeChannelIds)
+}
+
+func cacheGetRandomSatisfiedChannel(group string, model string, retry int, excludeChannelIds map[int]bool) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model, retry)
+		return GetRandomSatisfiedChannel(group, model, retry, excludeChannelIds)
 	}
-	
+
 	channelSyncLock.RLock()
 	channels := group2model2channels[group][model]
 	channelSyncLock.RUnlock()
-	
+
+	if len(excludeChannelIds) > 0 {
+		filtered := make([]*Channel, 0, len(channels))
+		for _, channel := range channels {
+			if !excludeChannelIds[channel.Id] {
+				filtered = append(filtered, channel)
+			}
+		}
+		channels = filtered
+	}
+
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
 
-	uniquePriorities := make(map[int]bool)
+	// 缓存中的渠道在同步时已按优先级降序排列，这里按顺序取出去重后的优先级，无需每次请求排序
+	sortedUniquePriorities := make([]int64, 0, 4)
 	for _, channel := range channels {
-		uniquePriorities[int(channel.GetPriority())] = true
-	}
-	var sortedUniquePriorities []int
-	for priority := range uniquePriorities {
-		sortedUniquePriorities = append(sortedUniquePriorities, priority)
+		priority := channel.GetPriority()
+		if len(sortedUniquePriorities) == 0 || sortedUniquePriorities[len(sortedUniquePriorities)-1] != priority {
+			sortedUniquePriorities = append(sortedUniquePriorities, priority)
+		}
 	}
-	sort.Sort(sort.Reverse(sort.IntSlice(sortedUniquePriorities)))
 
-	if retry >= len(uniquePriorities) {
-		retry = len(uniquePriorities) - 1
+	if retry >= len(sortedUniquePriorities) {
+		retry = len(sortedUniquePriorities) - 1
 	}
-	targetPriority := int64(sortedUniquePriorities[retry])
+	targetPriority := sortedUniquePriorities[retry]
 
 	// get the priority for the given retry number
 	var targetChannels []*Channel
@@ -118,17 +148,24 @@ func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Cha
 
 	// 平滑系数
 	smoothingFactor := 10
+	channelIds := make([]int, len(targetChannels))
+	weights := make([]float64, len(targetChannels))
+	for i, channel := range targetChannels {
+		channelIds[i] = channel.Id
+		weights[i] = float64(channel.GetWeight() + smoothingFactor)
+	}
+	weights = getLatencyAwareWeights(channelIds, model, weights)
 	// Calculate the total weight of all channels up to endIdx
-	totalWeight := 0
-	for _, channel := range targetChannels {
-		totalWeight += channel.GetWeight() + smoothingFactor
+	totalWeight := 0.0
+	for _, weight := range weights {
+		totalWeight += weight
 	}
 	// Generate a random value in the range [0, totalWeight)
-	randomWeight := rand.Intn(totalWeight)
+	randomWeight := rand.Float64() * totalWeight
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight() + smoothingFactor
+	for i, channel := range targetChannels {
+		randomWeight -= weights[i]
 		if randomWeight < 0 {
 			return channel, nil
 		}
@@ -151,6 +188,21 @@ func CacheGetChannel(id int) (*Channel, error) {
 	return c, nil
 }
 
+// IsChannelSatisfied 渠道是否已启用且可用于该分组和模型
+func IsChannelSatisfied(group string, model string, channelId int) bool {
+	if !common.MemoryCacheEnabled {
+		return IsAbilityEnabled(group, model, channelId)
+	}
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	for _, channel := range group2model2channels[group][model] {
+		if channel.Id == channelId {
+			return true
+		}
+	}
+	return false
+}
+
 func CacheUpdateChannelStatus(id int, status int) {
 	if !common.MemoryCacheEnabled {
 		return
@@ -1,8 +1,13 @@
 package model
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"strings"
 	"sync"
 
@@ -523,6 +528,40 @@ func (channel *Channel) SetSetting(setting map[string]interface{}) {
 	channel.Setting = common.GetPointer[string](string(settingBytes))
 }
 
+// PrepareTLSSetting 校验渠道的 mTLS 配置，并在保存前加密客户端私钥
+func (channel *Channel) PrepareTLSSetting() error {
+	setting := channel.GetSetting()
+	cert, _ := setting[constant.ChannelSettingTLSClientCert].(string)
+	key, _ := setting[constant.ChannelSettingTLSClientKey].(string)
+	caCert, _ := setting[constant.ChannelSettingTLSCACert].(string)
+	if caCert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(caCert)) {
+		return errors.New("CA 证书格式无效")
+	}
+	if cert == "" && key == "" {
+		return nil
+	}
+	if cert == "" || key == "" {
+		return errors.New("客户端证书与私钥需同时配置")
+	}
+	plainKey, err := common.DecryptString(key)
+	if err != nil {
+		return errors.New("无法解密客户端私钥，请重新填写")
+	}
+	if _, err = tls.X509KeyPair([]byte(cert), []byte(plainKey)); err != nil {
+		return fmt.Errorf("客户端证书或私钥无效：%s", err.Error())
+	}
+	if common.IsEncryptedString(key) {
+		return nil
+	}
+	encrypted, err := common.EncryptString(plainKey)
+	if err != nil {
+		return err
+	}
+	setting[constant.ChannelSettingTLSClientKey] = encrypted
+	channel.SetSetting(setting)
+	return nil
+}
+
 func (channel *Channel) GetParamOverride() map[string]interface{} {
 	paramOverride := make(map[string]interface{})
 	if channel.ParamOverride != nil && *channel.ParamOverride != "" {
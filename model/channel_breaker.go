@@ -0,0 +1,239 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 渠道熔断器：按渠道统计滑动窗口内的错误率，错误率过高时将渠道移出选择范围，
+// 冷却后由后台探测任务检测渠道是否恢复。熔断状态仅保存在当前节点内存中。
+
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+)
+
+// 滑动窗口的分桶数量
+const circuitBucketCount = 10
+
+type circuitBucket struct {
+	start    int64
+	total    int
+	failures int
+}
+
+type channelCircuit struct {
+	state       string
+	buckets     [circuitBucketCount]circuitBucket
+	openedAt    time.Time
+	nextProbeAt time.Time
+	cooldown    time.Duration
+}
+
+type ChannelCircuitStatus struct {
+	ChannelId   int     `json:"channel_id"`
+	State       string  `json:"state"`
+	Total       int     `json:"total"`
+	Failures    int     `json:"failures"`
+	ErrorRate   float64 `json:"error_rate"`
+	OpenedAt    int64   `json:"opened_at"`
+	NextProbeAt int64   `json:"next_probe_at"`
+}
+
+var channelCircuits = make(map[int]*channelCircuit)
+var channelCircuitLock sync.Mutex
+
+func getCircuitBucketSpan() int64 {
+	windowSeconds := operation_setting.GetCircuitBreakerSetting().WindowSeconds
+	if windowSeconds < circuitBucketCount {
+		windowSeconds = circuitBucketCount
+	}
+	return int64(windowSeconds / circuitBucketCount)
+}
+
+func (cc *channelCircuit) record(now time.Time, failed bool) {
+	span := getCircuitBucketSpan()
+	start := now.Unix() / span * span
+	bucket := &cc.buckets[(start/span)%circuitBucketCount]
+	if bucket.start != start {
+		*bucket = circuitBucket{start: start}
+	}
+	bucket.total++
+	if failed {
+		bucket.failures++
+	}
+}
+
+func (cc *channelCircuit) stats(now time.Time) (total int, failures int) {
+	span := getCircuitBucketSpan()
+	windowStart := now.Unix() - span*circuitBucketCount
+	for _, bucket := range cc.buckets {
+		if bucket.start > windowStart {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+	return total, failures
+}
+
+func (cc *channelCircuit) open(now time.Time, cooldown time.Duration) {
+	cc.state = CircuitStateOpen
+	cc.openedAt = now
+	cc.cooldown = cooldown
+	cc.nextProbeAt = now.Add(cooldown)
+	cc.buckets = [circuitBucketCount]circuitBucket{}
+}
+
+// RecordChannelResult 记录一次渠道请求结果，返回本次是否触发熔断
+func RecordChannelResult(channelId int, failed bool) bool {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	if !setting.Enabled {
+		return false
+	}
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	cc, ok := channelCircuits[channelId]
+	if !ok {
+		if !failed {
+			return false
+		}
+		cc = &channelCircuit{state: CircuitStateClosed}
+		channelCircuits[channelId] = cc
+	}
+	if cc.state != CircuitStateClosed {
+		// 熔断期间仍在途的请求结果不再统计
+		return false
+	}
+	now := time.Now()
+	cc.record(now, failed)
+	total, failures := cc.stats(now)
+	if total < setting.MinRequests || total == 0 {
+		return false
+	}
+	if float64(failures)/float64(total) < setting.ErrorRateThreshold {
+		return false
+	}
+	cc.open(now, time.Duration(setting.CooldownSeconds)*time.Second)
+	return true
+}
+
+// IsChannelCircuitOpen 渠道是否处于熔断（含探测中）状态
+func IsChannelCircuitOpen(channelId int) bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return false
+	}
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	cc, ok := channelCircuits[channelId]
+	return ok && cc.state != CircuitStateClosed
+}
+
+// GetOpenCircuitChannelIds 返回当前不参与选择的渠道
+func GetOpenCircuitChannelIds() map[int]bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return nil
+	}
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	var ids map[int]bool
+	for id, cc := range channelCircuits {
+		if cc.state == CircuitStateClosed {
+			continue
+		}
+		if ids == nil {
+			ids = make(map[int]bool)
+		}
+		ids[id] = true
+	}
+	return ids
+}
+
+// AcquireChannelCircuitProbes 返回最多 limit 个到达探测时间的熔断渠道，并将其标记为探测中，避免重复探测；
+// 按到达探测时间先后选取，本轮未选中的渠道下一轮优先探测，各熔断渠道轮流获得探测机会
+func AcquireChannelCircuitProbes(limit int) []int {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled || limit <= 0 {
+		return nil
+	}
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	now := time.Now()
+	var ids []int
+	for id, cc := range channelCircuits {
+		if cc.state == CircuitStateOpen && !now.Before(cc.nextProbeAt) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := channelCircuits[ids[i]], channelCircuits[ids[j]]
+		if !a.nextProbeAt.Equal(b.nextProbeAt) {
+			return a.nextProbeAt.Before(b.nextProbeAt)
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		channelCircuits[id].state = CircuitStateHalfOpen
+	}
+	return ids
+}
+
+// ReportChannelCircuitProbe 记录探测结果：成功则恢复渠道，失败则退避后再次探测
+func ReportChannelCircuitProbe(channelId int, healthy bool) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	cc, ok := channelCircuits[channelId]
+	if !ok {
+		return
+	}
+	if healthy {
+		delete(channelCircuits, channelId)
+		return
+	}
+	cooldown := cc.cooldown * 2
+	maxCooldown := time.Duration(setting.MaxCooldownSeconds) * time.Second
+	if cooldown > maxCooldown {
+		cooldown = maxCooldown
+	}
+	if cooldown <= 0 {
+		cooldown = time.Duration(setting.CooldownSeconds) * time.Second
+	}
+	cc.open(time.Now(), cooldown)
+}
+
+// ResetChannelCircuit 手动恢复渠道，例如渠道被重新启用或删除时
+func ResetChannelCircuit(channelId int) {
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	delete(channelCircuits, channelId)
+}
+
+func GetChannelCircuitStatuses() []ChannelCircuitStatus {
+	channelCircuitLock.Lock()
+	defer channelCircuitLock.Unlock()
+	now := time.Now()
+	statuses := make([]ChannelCircuitStatus, 0, len(channelCircuits))
+	for id, cc := range channelCircuits {
+		total, failures := cc.stats(now)
+		status := ChannelCircuitStatus{
+			ChannelId: id,
+			State:     cc.state,
+			Total:     total,
+			Failures:  failures,
+		}
+		if total > 0 {
+			status.ErrorRate = float64(failures) / float64(total)
+		}
+		if cc.state != CircuitStateClosed {
+			status.OpenedAt = cc.openedAt.Unix()
+			status.NextProbeAt = cc.nextProbeAt.Unix()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+// ChannelHealthCheck 渠道健康检查记录
+type ChannelHealthCheck struct {
+	Id        int    `json:"id"`
+	ChannelId int    `json:"channel_id" gorm:"index:idx_channel_health_channel_time,priority:1"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index:idx_channel_health_channel_time,priority:2;index"`
+	Model     string `json:"model" gorm:"type:varchar(255)"`
+	Success   bool   `json:"success"`
+	LatencyMs int64  `json:"latency_ms"`
+	Message   string `json:"message" gorm:"type:text"`
+}
+
+type ChannelHealthSummary struct {
+	ChannelId    int     `json:"channel_id"`
+	Total        int64   `json:"total"`
+	Success      int64   `json:"success"`
+	Availability float64 `json:"availability"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+func RecordChannelHealthCheck(check *ChannelHealthCheck) error {
+	if check.CreatedAt == 0 {
+		check.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(check).Error
+}
+
+func GetChannelHealthChecks(channelId int, startTimestamp int64, limit int) (checks []*ChannelHealthCheck, err error) {
+	err = DB.Where("channel_id = ? and created_at >= ?", channelId, startTimestamp).
+		Order("id desc").Limit(limit).Find(&checks).Error
+	return checks, err
+}
+
+// GetChannelHealthSummaries 统计各渠道自 startTimestamp 起的可用率与平均延迟，channelId 为 0 时统计所有渠道
+func GetChannelHealthSummaries(channelId int, startTimestamp int64) (summaries []*ChannelHealthSummary, err error) {
+	trueVal := "1"
+	if common.UsingPostgreSQL {
+		trueVal = "true"
+	}
+	query := DB.Model(&ChannelHealthCheck{}).
+		Select("channel_id, count(*) as total, sum(case when success = "+trueVal+" then 1 else 0 end) as success, "+
+			"coalesce(avg(case when success = "+trueVal+" then latency_ms end), 0) as avg_latency_ms").
+		Where("created_at >= ?", startTimestamp)
+	if channelId != 0 {
+		query = query.Where("channel_id = ?", channelId)
+	}
+	err = query.Group("channel_id").Scan(&summaries).Error
+	for _, summary := range summaries {
+		if summary.Total > 0 {
+			summary.Availability = float64(summary.Success) / float64(summary.Total)
+		}
+	}
+	return summaries, err
+}
+
+func DeleteChannelHealthChecksBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&ChannelHealthCheck{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"fmt"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 按渠道+模型统计最近请求的耗时与错误率，用于延迟感知的负载均衡。
+// 同一模型在不同渠道上的输出长度分布相近，因此直接使用请求总耗时进行比较。
+
+// 每个渠道+模型保留的样本数量
+const channelStatsSampleSize = 100
+
+// p95 与错误率的最短重新计算间隔，避免每次请求都对样本排序
+const channelStatsRefreshInterval = time.Second
+
+type channelStatsSample struct {
+	latency time.Duration
+	failed  bool
+}
+
+type channelModelStats struct {
+	// mu 保护单个渠道+模型的样本，记录与读取不再争用全局锁
+	mu        sync.Mutex
+	channelId int
+	model     string
+	samples   [channelStatsSampleSize]channelStatsSample
+	next      int
+	count     int
+	p95       time.Duration
+	errorRate float64
+	// refreshedAt 上次计算 p95 与错误率的时间
+	refreshedAt time.Time
+}
+
+type ChannelModelStatsView struct {
+	ChannelId int     `json:"channel_id"`
+	Model     string  `json:"model"`
+	Samples   int     `json:"samples"`
+	P95Ms     int64   `json:"p95_ms"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// channelModelStatsLock 仅保护 map 本身，新增渠道+模型时才需要写锁
+var channelModelStatsMap = make(map[string]*channelModelStats)
+var channelModelStatsLock sync.RWMutex
+
+func channelModelStatsKey(channelId int, model string) string {
+	return fmt.Sprintf("%d:%s", channelId, model)
+}
+
+func (s *channelModelStats) refresh() {
+	latencies := make([]time.Duration, 0, s.count)
+	failures := 0
+	for i := 0; i < s.count; i++ {
+		if s.samples[i].failed {
+			failures++
+			continue
+		}
+		latencies = append(latencies, s.samples[i].latency)
+	}
+	s.refreshedAt = time.Now()
+	s.errorRate = float64(failures) / float64(s.count)
+	if len(latencies) == 0 {
+		s.p95 = 0
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.p95 = latencies[(len(latencies)*95-1)/100]
+}
+
+// RecordChannelModelLatency 记录一次渠道+模型的请求耗时及是否失败
+func RecordChannelModelLatency(channelId int, model string, latency time.Duration, failed bool) {
+	if !operation_setting.GetLoadBalanceSetting().LatencyAwareEnabled {
+		return
+	}
+	key := channelModelStatsKey(channelId, model)
+	channelModelStatsLock.RLock()
+	s, ok := channelModelStatsMap[key]
+	channelModelStatsLock.RUnlock()
+	if !ok {
+		channelModelStatsLock.Lock()
+		if s, ok = channelModelStatsMap[key]; !ok {
+			s = &channelModelStats{channelId: channelId, model: model}
+			channelModelStatsMap[key] = s
+		}
+		channelModelStatsLock.Unlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[s.next] = channelStatsSample{latency: latency, failed: failed}
+	s.next = (s.next + 1) % channelStatsSampleSize
+	if s.count < channelStatsSampleSize {
+		s.count++
+	}
+	if time.Since(s.refreshedAt) >= channelStatsRefreshInterval {
+		s.refresh()
+	}
+}
+
+// getLatencyAwareWeights 根据 p95 延迟和错误率调整渠道权重：
+// 权重乘以 最快渠道p95/本渠道p95 与 (1-错误率)，样本不足的渠道保持原权重
+func getLatencyAwareWeights(channelIds []int, model string, baseWeights []float64) []float64 {
+	setting := operation_setting.GetLoadBalanceSetting()
+	if !setting.LatencyAwareEnabled {
+		return baseWeights
+	}
+	type statsSnapshot struct {
+		p95       time.Duration
+		errorRate float64
+	}
+	stats := make([]*statsSnapshot, len(channelIds))
+	var bestP95 time.Duration
+	channelModelStatsLock.RLock()
+	for i, channelId := range channelIds {
+		s, ok := channelModelStatsMap[channelModelStatsKey(channelId, model)]
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		if s.count >= setting.MinSamples {
+			stats[i] = &statsSnapshot{p95: s.p95, errorRate: s.errorRate}
+		}
+		s.mu.Unlock()
+		if stats[i] != nil && stats[i].p95 > 0 && (bestP95 == 0 || stats[i].p95 < bestP95) {
+			bestP95 = stats[i].p95
+		}
+	}
+	channelModelStatsLock.RUnlock()
+	weights := make([]float64, len(channelIds))
+	for i := range channelIds {
+		weights[i] = baseWeights[i]
+		s := stats[i]
+		if s == nil {
+			continue
+		}
+		if s.p95 > 0 && bestP95 > 0 {
+			weights[i] *= float64(bestP95) / float64(s.p95)
+		}
+		// 保留少量流量，便于错误率恢复后重新评估
+		weights[i] *= max(1-s.errorRate, 0.05)
+	}
+	return weights
+}
+
+func GetChannelModelStats() []ChannelModelStatsView {
+	channelModelStatsLock.RLock()
+	defer channelModelStatsLock.RUnlock()
+	views := make([]ChannelModelStatsView, 0, len(channelModelStatsMap))
+	for _, s := range channelModelStatsMap {
+		s.mu.Lock()
+		views = append(views, ChannelModelStatsView{
+			ChannelId: s.channelId,
+			Model:     s.model,
+			Samples:   s.count,
+			P95Ms:     s.p95.Milliseconds(),
+			ErrorRate: s.errorRate,
+		})
+		s.mu.Unlock()
+	}
+	return views
+}
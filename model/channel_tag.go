@@ -0,0 +1,113 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/constant"
+)
+
+// ChannelTagPolicy 选择渠道时的路由标签策略：渠道必须具有 Required 中的全部标签，
+// 具有 Preferred 中任一标签的渠道优先，均不可用时再从其余满足 Required 的渠道中选择
+type ChannelTagPolicy struct {
+	Required  []string `json:"required,omitempty"`
+	Preferred []string `json:"preferred,omitempty"`
+}
+
+func (policy *ChannelTagPolicy) IsEmpty() bool {
+	return policy == nil || (len(policy.Required) == 0 && len(policy.Preferred) == 0)
+}
+
+// GetRoutingTags 渠道设置中的 routing_tags 与渠道标签（tag）
+func (channel *Channel) GetRoutingTags() []string {
+	var tags []string
+	if items, ok := channel.GetSetting()[constant.ChannelSettingRoutingTags].([]interface{}); ok {
+		for _, item := range items {
+			if tag, ok := item.(string); ok && tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if tag := channel.GetTag(); tag != "" {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Allows 渠道是否具有全部必需标签
+func (policy *ChannelTagPolicy) Allows(channel *Channel) bool {
+	if policy.IsEmpty() || len(policy.Required) == 0 {
+		return true
+	}
+	tags := channel.GetRoutingTags()
+	for _, required := range policy.Required {
+		if !common.StringsContains(tags, required) {
+			return false
+		}
+	}
+	return true
+}
+
+func (policy *ChannelTagPolicy) prefers(channel *Channel) bool {
+	tags := channel.GetRoutingTags()
+	for _, preferred := range policy.Preferred {
+		if common.StringsContains(tags, preferred) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSatisfiedChannels 返回可用于该分组和模型的全部已启用渠道
+func getSatisfiedChannels(group string, model string) ([]*Channel, error) {
+	if common.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		defer channelSyncLock.RUnlock()
+		return group2model2channels[group][model], nil
+	}
+	trueVal := "1"
+	if common.UsingPostgreSQL {
+		trueVal = "true"
+	}
+	var channelIds []int
+	err := DB.Model(&Ability{}).Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model).
+		Pluck("channel_id", &channelIds).Error
+	if err != nil || len(channelIds) == 0 {
+		return nil, err
+	}
+	var channels []*Channel
+	err = DB.Where("id in ?", channelIds).Find(&channels).Error
+	return channels, err
+}
+
+// CacheGetRandomSatisfiedChannelWithTags 在 CacheGetRandomSatisfiedChannel 的基础上按路由标签策略过滤渠道，
+// 不满足策略的渠道作为排除渠道处理，优先级、权重与熔断逻辑保持不变
+func CacheGetRandomSatisfiedChannelWithTags(group string, model string, retry int, excludeChannelIds map[int]bool, policy *ChannelTagPolicy) (*Channel, error) {
+	if policy.IsEmpty() {
+		return CacheGetRandomSatisfiedChannel(group, model, retry, excludeChannelIds)
+	}
+	channels, err := getSatisfiedChannels(group, normalizeSelectModel(model))
+	if err != nil {
+		return nil, err
+	}
+	requiredExcludes := make(map[int]bool, len(excludeChannelIds))
+	for id := range excludeChannelIds {
+		requiredExcludes[id] = true
+	}
+	preferredExcludes := make(map[int]bool, len(excludeChannelIds))
+	for id := range excludeChannelIds {
+		preferredExcludes[id] = true
+	}
+	for _, channel := range channels {
+		if !policy.Allows(channel) {
+			requiredExcludes[channel.Id] = true
+			preferredExcludes[channel.Id] = true
+		} else if len(policy.Preferred) > 0 && !policy.prefers(channel) {
+			preferredExcludes[channel.Id] = true
+		}
+	}
+	if len(policy.Preferred) > 0 {
+		if channel, err := CacheGetRandomSatisfiedChannel(group, model, retry, preferredExcludes); err == nil {
+			return channel, nil
+		}
+	}
+	return CacheGetRandomSatisfiedChannel(group, model, retry, requiredExcludes)
+}
@@ -0,0 +1,211 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CostStat 按小时预聚合的消费统计，维度为用户、令牌、渠道与模型，供费用分析接口查询，避免扫描消费日志
+type CostStat struct {
+	Id               int    `json:"id"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index:idx_cost_stat_dims,priority:1"`
+	UserId           int    `json:"user_id" gorm:"index:idx_cost_stat_dims,priority:2"`
+	Username         string `json:"username" gorm:"size:64;default:''"`
+	TokenId          int    `json:"token_id" gorm:"index:idx_cost_stat_dims,priority:3"`
+	TokenName        string `json:"token_name" gorm:"size:64;default:''"`
+	ChannelId        int    `json:"channel_id" gorm:"index:idx_cost_stat_dims,priority:4"`
+	ModelName        string `json:"model_name" gorm:"size:64;index:idx_cost_stat_dims,priority:5;default:''"`
+	Count            int    `json:"count" gorm:"default:0"`
+	PromptTokens     int    `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int    `json:"completion_tokens" gorm:"default:0"`
+	Quota            int    `json:"quota" gorm:"default:0"`
+}
+
+var costStatCache = make(map[string]*CostStat)
+var costStatCacheLock sync.Mutex
+
+func LogCostStat(userId int, username string, tokenId int, tokenName string, channelId int, modelName string,
+	promptTokens int, completionTokens int, quota int, createdAt int64) {
+	// 只精确到小时
+	createdAt = createdAt - (createdAt % 3600)
+	key := fmt.Sprintf("%d-%d-%d-%d-%s", createdAt, userId, tokenId, channelId, modelName)
+
+	costStatCacheLock.Lock()
+	defer costStatCacheLock.Unlock()
+	stat, ok := costStatCache[key]
+	if !ok {
+		stat = &CostStat{
+			CreatedAt: createdAt,
+			UserId:    userId,
+			Username:  username,
+			TokenId:   tokenId,
+			TokenName: tokenName,
+			ChannelId: channelId,
+			ModelName: modelName,
+		}
+		costStatCache[key] = stat
+	}
+	stat.Count += 1
+	stat.PromptTokens += promptTokens
+	stat.CompletionTokens += completionTokens
+	stat.Quota += quota
+}
+
+func SaveCostStatCache() {
+	costStatCacheLock.Lock()
+	stats := costStatCache
+	costStatCache = make(map[string]*CostStat)
+	costStatCacheLock.Unlock()
+
+	for _, stat := range stats {
+		result := DB.Model(&CostStat{}).Where("created_at = ? and user_id = ? and token_id = ? and channel_id = ? and model_name = ?",
+			stat.CreatedAt, stat.UserId, stat.TokenId, stat.ChannelId, stat.ModelName).Updates(map[string]interface{}{
+			"count":             gorm.Expr("count + ?", stat.Count),
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", stat.PromptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", stat.CompletionTokens),
+			"quota":             gorm.Expr("quota + ?", stat.Quota),
+		})
+		if result.Error == nil && result.RowsAffected == 0 {
+			result = DB.Create(stat)
+		}
+		if result.Error != nil {
+			common.SysError("failed to save cost stat: " + result.Error.Error())
+		}
+	}
+	if len(stats) > 0 {
+		common.SysLog(fmt.Sprintf("保存费用统计数据成功，共保存%d条数据", len(stats)))
+	}
+}
+
+const (
+	CostGroupByModel   = "model"
+	CostGroupByChannel = "channel"
+	CostGroupByToken   = "token"
+	CostGroupByUser    = "user"
+	CostGroupByDay     = "day"
+)
+
+type CostFilter struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	UserId         int
+	TokenId        int
+	ChannelId      int
+	ModelName      string
+	TokenIds       []int // 限定在这些令牌内，如组织的全部令牌
+}
+
+type CostBreakdownItem struct {
+	Key              string `json:"key"`
+	Name             string `json:"name,omitempty"`
+	Count            int64  `json:"count"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+// GetCostBreakdown 按指定维度汇总费用统计，day 维度按本地时区的自然日汇总
+func GetCostBreakdown(groupBy string, filter CostFilter) ([]*CostBreakdownItem, error) {
+	var column string
+	switch groupBy {
+	case CostGroupByModel:
+		column = "model_name"
+	case CostGroupByChannel:
+		column = "channel_id"
+	case CostGroupByToken:
+		column = "token_id"
+	case CostGroupByUser:
+		column = "user_id"
+	case CostGroupByDay:
+		column = "created_at"
+	default:
+		return nil, errors.New("不支持的分组维度：" + groupBy)
+	}
+
+	tx := DB.Model(&CostStat{})
+	countSelect := "sum(count)"
+	if common.UsingClickHouse {
+		// ClickHouse 直接聚合原始消费日志，day 维度先按小时分桶再在下方合并为自然日
+		tx = LOG_DB.Table("logs").Where("type = ?", LogTypeConsume)
+		countSelect = "count(*)"
+		if groupBy == CostGroupByDay {
+			column = "intDiv(created_at, 3600) * 3600"
+		}
+	}
+	tx = tx.Where("created_at >= ? and created_at <= ?", filter.StartTimestamp, filter.EndTimestamp)
+	if filter.UserId != 0 {
+		tx = tx.Where("user_id = ?", filter.UserId)
+	}
+	if filter.TokenId != 0 {
+		tx = tx.Where("token_id = ?", filter.TokenId)
+	}
+	if filter.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", filter.ChannelId)
+	}
+	if filter.ModelName != "" {
+		tx = tx.Where("model_name = ?", filter.ModelName)
+	}
+	if len(filter.TokenIds) > 0 {
+		tx = tx.Where("token_id in ?", filter.TokenIds)
+	}
+
+	var rows []struct {
+		StatKey          string
+		Name             string
+		Count            int64
+		PromptTokens     int64
+		CompletionTokens int64
+		Quota            int64
+	}
+	nameSelect := "'' as name"
+	switch groupBy {
+	case CostGroupByToken:
+		nameSelect = "max(token_name) as name"
+	case CostGroupByUser:
+		nameSelect = "max(username) as name"
+	}
+	err := tx.Select(column + " as stat_key, " + nameSelect + ", " + countSelect + " as count, sum(prompt_tokens) as prompt_tokens, " +
+		"sum(completion_tokens) as completion_tokens, sum(quota) as quota").
+		Group(column).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]*CostBreakdownItem)
+	var keys []string
+	for _, row := range rows {
+		key := row.StatKey
+		if groupBy == CostGroupByDay {
+			hour, _ := strconv.ParseInt(row.StatKey, 10, 64)
+			key = time.Unix(hour, 0).Format("2006-01-02")
+		}
+		item, ok := items[key]
+		if !ok {
+			item = &CostBreakdownItem{Key: key, Name: row.Name}
+			items[key] = item
+			keys = append(keys, key)
+		}
+		item.Count += row.Count
+		item.PromptTokens += row.PromptTokens
+		item.CompletionTokens += row.CompletionTokens
+		item.Quota += row.Quota
+	}
+
+	result := make([]*CostBreakdownItem, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, items[key])
+	}
+	if groupBy == CostGroupByDay {
+		sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	} else {
+		sort.Slice(result, func(i, j int) bool { return result[i].Quota > result[j].Quota })
+	}
+	return result, nil
+}
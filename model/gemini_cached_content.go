@@ -0,0 +1,54 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+)
+
+// GeminiCachedContent 通过网关创建的 Gemini 上下文缓存，缓存只存在于创建它的渠道，
+// 记录归属用户与渠道，引用缓存的对话请求固定发往该渠道
+type GeminiCachedContent struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(191);uniqueIndex"` // 上游资源名 cachedContents/{id}
+	UserId      int    `json:"user_id" gorm:"index"`
+	TokenId     int    `json:"token_id"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	ModelName   string `json:"model_name"`
+	DisplayName string `json:"display_name"`
+	TokenCount  int    `json:"token_count"`
+	ExpireTime  int64  `json:"expire_time" gorm:"bigint;index"` // 0 表示上游未返回过期时间
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+}
+
+func (cache *GeminiCachedContent) Insert() error {
+	cache.CreatedAt = common.GetTimestamp()
+	return DB.Create(cache).Error
+}
+
+func (cache *GeminiCachedContent) Delete() error {
+	return DB.Delete(cache).Error
+}
+
+func (cache *GeminiCachedContent) IsExpired() bool {
+	return cache.ExpireTime != 0 && cache.ExpireTime <= common.GetTimestamp()
+}
+
+// GetGeminiCachedContent 返回用户自己创建的缓存，不能引用其他用户的缓存
+func GetGeminiCachedContent(userId int, name string) (*GeminiCachedContent, error) {
+	if name == "" {
+		return nil, errors.New("缓存名称为空")
+	}
+	var cache GeminiCachedContent
+	err := DB.Where("user_id = ? and name = ?", userId, name).First(&cache).Error
+	return &cache, err
+}
+
+// GetUserGeminiCachedContents 返回用户未过期的缓存
+func GetUserGeminiCachedContents(userId int, startIdx int, num int) (caches []*GeminiCachedContent, total int64, err error) {
+	tx := DB.Model(&GeminiCachedContent{}).Where("user_id = ? and (expire_time = 0 or expire_time > ?)", userId, common.GetTimestamp())
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&caches).Error
+	return caches, total, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"os"
 	"strings"
 	"time"
@@ -90,11 +91,24 @@ func RecordLog(userId int, logType int, content string) {
 	}
 }
 
+// withModerationResult 将请求的内容审核结果一并记录到日志中
+func withModerationResult(c *gin.Context, other map[string]interface{}) map[string]interface{} {
+	result, ok := c.Get(constant.ContextKeyModerationResult)
+	if !ok {
+		return other
+	}
+	if other == nil {
+		other = make(map[string]interface{})
+	}
+	other["moderation"] = result
+	return other
+}
+
 func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string, tokenName string, content string, tokenId int, useTimeSeconds int,
 	isStream bool, group string, other map[string]interface{}) {
-	common.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
+	common.LogDebug(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
 	username := c.GetString("username")
-	otherStr := common.MapToJsonStr(other)
+	otherStr := common.MapToJsonStr(withModerationResult(c, other))
 	log := &Log{
 		UserId:           userId,
 		Username:         username,
@@ -113,7 +127,7 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		Group:            group,
 		Other:            otherStr,
 	}
-	err := LOG_DB.Create(log).Error
+	err := LOG_DB.WithContext(common.DetachedTraceContext(c.Request.Context())).Create(log).Error
 	if err != nil {
 		common.LogError(c, "failed to record log: "+err.Error())
 	}
@@ -122,12 +136,13 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 func RecordConsumeLog(c *gin.Context, userId int, channelId int, promptTokens int, completionTokens int,
 	modelName string, tokenName string, quota int, content string, tokenId int, userQuota int, useTimeSeconds int,
 	isStream bool, group string, other map[string]interface{}) {
-	common.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, 用户调用前余额=%d, channelId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s", userId, userQuota, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content))
+	c.Set(constant.ContextKeyConsumedQuota, quota)
+	common.LogDebug(c, fmt.Sprintf("record consume log: userId=%d, 用户调用前余额=%d, channelId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s", userId, userQuota, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content))
 	if !common.LogConsumeEnabled {
 		return
 	}
 	username := c.GetString("username")
-	otherStr := common.MapToJsonStr(other)
+	otherStr := common.MapToJsonStr(withModerationResult(c, other))
 	log := &Log{
 		UserId:           userId,
 		Username:         username,
@@ -146,15 +161,20 @@ func RecordConsumeLog(c *gin.Context, userId int, channelId int, promptTokens in
 		Group:            group,
 		Other:            otherStr,
 	}
-	err := LOG_DB.Create(log).Error
-	if err != nil {
-		common.LogError(c, "failed to record log: "+err.Error())
+	if !enqueueLog(log) {
+		err := LOG_DB.WithContext(common.DetachedTraceContext(c.Request.Context())).Create(log).Error
+		if err != nil {
+			common.LogError(c, "failed to record log: "+err.Error())
+		}
 	}
-	if common.DataExportEnabled {
-		gopool.Go(func() {
+	common.MetricConsume(channelId, modelName, group, promptTokens, completionTokens, quota)
+	gopool.Go(func() {
+		if common.DataExportEnabled {
 			LogQuotaData(userId, username, modelName, quota, common.GetTimestamp(), promptTokens+completionTokens)
-		})
-	}
+		}
+		// 费用统计不受数据看板开关影响
+		LogCostStat(userId, username, tokenId, tokenName, channelId, modelName, promptTokens, completionTokens, quota, common.GetTimestamp())
+	})
 }
 
 func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
@@ -349,6 +369,11 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 			return total, ctx.Err()
 		}
 
+		if common.UsingClickHouse {
+			// ClickHouse 的 DELETE 不支持 LIMIT，整月过期的分区直接删除，其余按天分批删除
+			return deleteOldClickHouseLog(ctx, targetTimestamp)
+		}
+
 		result := LOG_DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&Log{})
 		if nil != result.Error {
 			return total, result.Error
@@ -363,3 +388,60 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 
 	return total, nil
 }
+
+// SumUserConsumedQuota 统计用户在 [startTimestamp, endTimestamp) 内消耗的额度
+func SumUserConsumedQuota(userId int, startTimestamp int64, endTimestamp int64) (int64, error) {
+	var quota int64
+	err := LOG_DB.Table("logs").Select("coalesce(sum(quota), 0)").
+		Where("type = ? and user_id = ? and created_at >= ? and created_at < ?", LogTypeConsume, userId, startTimestamp, endTimestamp).
+		Scan(&quota).Error
+	return quota, err
+}
+
+// SumModelConsumedQuota 统计用户或令牌自 startTimestamp 起在指定模型上消耗的额度，modelPattern 以 * 结尾时按前缀匹配
+func SumModelConsumedQuota(userId int, tokenId int, modelPattern string, startTimestamp int64) (int64, error) {
+	var quota int64
+	tx := LOG_DB.Table("logs").Select("coalesce(sum(quota), 0)").
+		Where("type = ? and created_at >= ?", LogTypeConsume, startTimestamp)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if tokenId != 0 {
+		tx = tx.Where("token_id = ?", tokenId)
+	}
+	if strings.HasSuffix(modelPattern, "*") {
+		tx = tx.Where("model_name like ?", strings.TrimSuffix(modelPattern, "*")+"%")
+	} else {
+		tx = tx.Where("model_name = ?", modelPattern)
+	}
+	err := tx.Scan(&quota).Error
+	return quota, err
+}
+
+type StatementItem struct {
+	ModelName        string `json:"model_name"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+// StatementBucketSeconds 账单按时间分桶汇总的粒度，15 分钟可以对齐所有时区的整日边界
+const StatementBucketSeconds = 900
+
+type StatementBucketItem struct {
+	StatementItem
+	Bucket int64 `json:"bucket"`
+}
+
+// GetUserStatementBuckets 用一次查询按 15 分钟分桶和模型汇总用户在 [startTimestamp, endTimestamp) 内的消费日志，
+// 由调用方按本地日期合并分桶
+func GetUserStatementBuckets(userId int, startTimestamp int64, endTimestamp int64) (items []*StatementBucketItem, err error) {
+	bucketExpr := fmt.Sprintf("(created_at - (created_at %% %d))", StatementBucketSeconds)
+	err = LOG_DB.Table("logs").
+		Select(bucketExpr+" as bucket, model_name, count(*) as requests, coalesce(sum(prompt_tokens), 0) as prompt_tokens, "+
+			"coalesce(sum(completion_tokens), 0) as completion_tokens, coalesce(sum(quota), 0) as quota").
+		Where("user_id = ? and type = ? and created_at >= ? and created_at < ?", userId, LogTypeConsume, startTimestamp, endTimestamp).
+		Group(bucketExpr + ", model_name").Order("bucket").Scan(&items).Error
+	return items, err
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"one-api/common"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClickHouse 没有自增主键，日志 id 由毫秒时间戳与进程内序号拼接生成，仍保持大致递增以兼容按 id 分页与导出
+var clickHouseLogSeq atomic.Int64
+
+func init() {
+	clickHouseLogSeq.Store(rand.Int63n(1000000))
+}
+
+func nextClickHouseLogId() int {
+	seq := clickHouseLogSeq.Add(1) % 1000000
+	return int(time.Now().UnixMilli()*1000000 + seq)
+}
+
+func (log *Log) BeforeCreate(tx *gorm.DB) error {
+	if common.UsingClickHouse && log.Id == 0 {
+		log.Id = nextClickHouseLogId()
+	}
+	return nil
+}
+
+// migrateClickHouseLogDB 按月分区、按 (created_at, id) 排序建表，过期日志清理与时间范围查询均可命中分区
+func migrateClickHouseLogDB() error {
+	return LOG_DB.Exec(`CREATE TABLE IF NOT EXISTS logs (
+	id Int64,
+	user_id Int64,
+	created_at Int64,
+	type Int64,
+	content String,
+	username String DEFAULT '',
+	token_name String DEFAULT '',
+	model_name String DEFAULT '',
+	quota Int64 DEFAULT 0,
+	prompt_tokens Int64 DEFAULT 0,
+	completion_tokens Int64 DEFAULT 0,
+	use_time Int64 DEFAULT 0,
+	is_stream Bool DEFAULT false,
+	channel_id Int64,
+	token_id Int64 DEFAULT 0,
+	` + "`group`" + ` String,
+	other String
+) ENGINE = MergeTree()
+PARTITION BY toYYYYMM(toDateTime(created_at))
+ORDER BY (created_at, id)`).Error
+}
+
+// clickHouseLogDeleteChunk 未整体过期的分区按该时间跨度分批删除，避免一次提交覆盖整张表的 mutation
+const clickHouseLogDeleteChunk int64 = 24 * 3600
+
+// deleteOldClickHouseLog 删除 targetTimestamp 之前的日志：早于目标月份的分区整个删除，剩余部分按天删除
+func deleteOldClickHouseLog(ctx context.Context, targetTimestamp int64) (int64, error) {
+	var total int64
+	var targetPartition string
+	if err := LOG_DB.Raw("SELECT toString(toYYYYMM(toDateTime(?)))", targetTimestamp).Scan(&targetPartition).Error; err != nil {
+		return total, err
+	}
+	type partitionRows struct {
+		PartitionId string
+		TotalRows   int64
+	}
+	var partitions []partitionRows
+	err := LOG_DB.Raw(`SELECT partition_id, sum(rows) AS total_rows FROM system.parts
+WHERE database = currentDatabase() AND table = 'logs' AND active AND partition_id < ?
+GROUP BY partition_id ORDER BY partition_id`, targetPartition).Scan(&partitions).Error
+	if err != nil {
+		return total, err
+	}
+	for _, partition := range partitions {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		// 分区 ID 为 toYYYYMM 生成的数字，校验后再拼接到 DDL 中
+		if _, err := strconv.Atoi(partition.PartitionId); err != nil {
+			continue
+		}
+		if err := LOG_DB.Exec(fmt.Sprintf("ALTER TABLE logs DROP PARTITION ID '%s'", partition.PartitionId)).Error; err != nil {
+			return total, err
+		}
+		total += partition.TotalRows
+	}
+
+	var start int64
+	if err := LOG_DB.Model(&Log{}).Select("coalesce(min(created_at), 0)").Where("created_at < ?", targetTimestamp).Scan(&start).Error; err != nil {
+		return total, err
+	}
+	if start == 0 {
+		return total, nil
+	}
+	for ; start < targetTimestamp; start += clickHouseLogDeleteChunk {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		end := min(start+clickHouseLogDeleteChunk, targetTimestamp)
+		var count int64
+		if err := LOG_DB.Model(&Log{}).Where("created_at >= ? AND created_at < ?", start, end).Count(&count).Error; err != nil {
+			return total, err
+		}
+		if count == 0 {
+			continue
+		}
+		if err := LOG_DB.Where("created_at >= ? AND created_at < ?", start, end).Delete(&Log{}).Error; err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
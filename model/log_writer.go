@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+var logWriteChan chan *Log
+var logWriterDone chan struct{}
+var logWriterClosed bool
+var logWriterLock sync.RWMutex
+
+// InitLogWriter 启动消费日志的异步写入协程，按条数或时间间隔批量落库
+func InitLogWriter() {
+	batchSize := common.LogBatchSize
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	interval := common.LogBatchInterval
+	if interval <= 0 {
+		interval = 1
+	}
+	bufferSize := common.LogBatchBufferSize
+	if bufferSize < batchSize {
+		bufferSize = batchSize
+	}
+	logWriteChan = make(chan *Log, bufferSize)
+	logWriterDone = make(chan struct{})
+	go runLogWriter(batchSize, time.Duration(interval)*time.Second)
+}
+
+func runLogWriter(batchSize int, interval time.Duration) {
+	defer close(logWriterDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]*Log, 0, batchSize)
+	for {
+		select {
+		case log, ok := <-logWriteChan:
+			if !ok {
+				flushLogBatch(batch)
+				return
+			}
+			batch = append(batch, log)
+			if len(batch) >= batchSize {
+				flushLogBatch(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			flushLogBatch(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func flushLogBatch(batch []*Log) {
+	if len(batch) == 0 {
+		return
+	}
+	err := LOG_DB.CreateInBatches(batch, len(batch)).Error
+	if err == nil {
+		return
+	}
+	common.SysError(fmt.Sprintf("failed to flush %d logs, retrying: %s", len(batch), err.Error()))
+	// 批量写入失败时稍后重试一次，仍失败则逐条写入，只丢弃本身无法写入的日志
+	time.Sleep(time.Second)
+	if err = LOG_DB.CreateInBatches(batch, len(batch)).Error; err == nil {
+		return
+	}
+	dropped := 0
+	for _, log := range batch {
+		if err = LOG_DB.Create(log).Error; err != nil {
+			dropped++
+			common.SysError(fmt.Sprintf("failed to record log of user %d: %s", log.UserId, err.Error()))
+		}
+	}
+	if dropped > 0 {
+		common.SysError(fmt.Sprintf("dropped %d of %d logs after batch insert failed", dropped, len(batch)))
+	}
+}
+
+// enqueueLog 未启用批量写入、写入协程已停止或缓冲区已满时返回 false，由调用方同步写入
+func enqueueLog(log *Log) bool {
+	logWriterLock.RLock()
+	defer logWriterLock.RUnlock()
+	if logWriteChan == nil || logWriterClosed {
+		return false
+	}
+	select {
+	case logWriteChan <- log:
+		return true
+	default:
+		return false
+	}
+}
+
+// StopLogWriter 停止接收新日志并等待缓冲区中的日志全部落库，之后的日志改为同步写入
+func StopLogWriter() {
+	logWriterLock.Lock()
+	if logWriteChan == nil || logWriterClosed {
+		logWriterLock.Unlock()
+		return
+	}
+	logWriterClosed = true
+	close(logWriteChan)
+	logWriterLock.Unlock()
+	<-logWriterDone
+	common.SysLog("log writer drained")
+}
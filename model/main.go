@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"log"
 	"one-api/common"
 	"one-api/constant"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/clickhouse"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -89,6 +91,15 @@ func chooseDB(envName string) (*gorm.DB, error) {
 	}()
 	dsn := os.Getenv(envName)
 	if dsn != "" {
+		if strings.HasPrefix(dsn, "clickhouse://") {
+			// ClickHouse 仅用作日志库，不修改主库相关的数据库类型标记
+			if envName != "LOG_SQL_DSN" {
+				return nil, errors.New("ClickHouse is only supported for LOG_SQL_DSN")
+			}
+			common.SysLog("using ClickHouse as log database")
+			common.UsingClickHouse = true
+			return gorm.Open(clickhouse.Open(dsn), &gorm.Config{})
+		}
 		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
 			// Use PostgreSQL
 			common.SysLog("using PostgreSQL as database")
@@ -136,6 +147,11 @@ func InitDB() (err error) {
 		if common.DebugEnabled {
 			db = db.Debug()
 		}
+		if common.TracingEnabled {
+			if err := db.Use(&tracingPlugin{}); err != nil {
+				return err
+			}
+		}
 		DB = db
 		sqlDB, err := DB.DB()
 		if err != nil {
@@ -170,6 +186,11 @@ func InitLogDB() (err error) {
 		if common.DebugEnabled {
 			db = db.Debug()
 		}
+		if common.TracingEnabled {
+			if err := db.Use(&tracingPlugin{}); err != nil {
+				return err
+			}
+		}
 		LOG_DB = db
 		sqlDB, err := LOG_DB.DB()
 		if err != nil {
@@ -242,6 +263,50 @@ func migrateDB() error {
 	if err != nil {
 		return err
 	}
+	err = DB.AutoMigrate(&ChannelHealthCheck{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&CostStat{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&Budget{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&Package{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&UserPackage{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&RequestCapture{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&WebhookDeadLetter{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&QuotaGrantSchedule{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&Organization{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&AsyncTask{})
+	if err != nil {
+		return err
+	}
+	err = DB.AutoMigrate(&GeminiCachedContent{})
+	if err != nil {
+		return err
+	}
 	err = DB.AutoMigrate(&Setup{})
 	common.SysLog("database migrated")
 	//err = createRootAccountIfNeed()
@@ -250,9 +315,16 @@ func migrateDB() error {
 
 func migrateLOGDB() error {
 	var err error
+	if common.UsingClickHouse {
+		// 抓取记录等低频数据仍保存在主库
+		return migrateClickHouseLogDB()
+	}
 	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = LOG_DB.AutoMigrate(&RequestCapture{}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -95,6 +95,10 @@ func InitOptionMap() {
 	common.OptionMap["ModelRatio"] = operation_setting.ModelRatio2JSONString()
 	common.OptionMap["ModelPrice"] = operation_setting.ModelPrice2JSONString()
 	common.OptionMap["CacheRatio"] = operation_setting.CacheRatio2JSONString()
+	common.OptionMap["CreateCacheRatio"] = operation_setting.CreateCacheRatio2JSONString()
+	common.OptionMap["BatchRatio"] = operation_setting.BatchRatio2JSONString()
+	common.OptionMap["ExchangeRates"] = operation_setting.ExchangeRates2JSONString()
+	common.OptionMap["ModelPriceCurrencies"] = operation_setting.ModelPriceCurrencies2JSONString()
 	common.OptionMap["GroupRatio"] = setting.GroupRatio2JSONString()
 	common.OptionMap["UserUsableGroups"] = setting.UserUsableGroups2JSONString()
 	common.OptionMap["CompletionRatio"] = operation_setting.CompletionRatio2JSONString()
@@ -105,6 +109,9 @@ func InitOptionMap() {
 	common.OptionMap["RetryTimes"] = strconv.Itoa(common.RetryTimes)
 	common.OptionMap["DataExportInterval"] = strconv.Itoa(common.DataExportInterval)
 	common.OptionMap["DataExportDefaultTime"] = common.DataExportDefaultTime
+	common.OptionMap["LogLevel"] = common.GetLogLevel()
+	common.OptionMap["LogFormat"] = common.GetLogFormat()
+	common.OptionMap["LogModuleLevels"] = common.GetModuleLogLevels()
 	common.OptionMap["DefaultCollapseSidebar"] = strconv.FormatBool(common.DefaultCollapseSidebar)
 	common.OptionMap["MjNotifyEnabled"] = strconv.FormatBool(setting.MjNotifyEnabled)
 	common.OptionMap["MjAccountFilterEnabled"] = strconv.FormatBool(setting.MjAccountFilterEnabled)
@@ -116,6 +123,7 @@ func InitOptionMap() {
 	common.OptionMap["SelfUseModeEnabled"] = strconv.FormatBool(operation_setting.SelfUseModeEnabled)
 	common.OptionMap["ModelRequestRateLimitEnabled"] = strconv.FormatBool(setting.ModelRequestRateLimitEnabled)
 	common.OptionMap["CheckSensitiveOnPromptEnabled"] = strconv.FormatBool(setting.CheckSensitiveOnPromptEnabled)
+	common.OptionMap["CheckSensitiveOnCompletionEnabled"] = strconv.FormatBool(setting.CheckSensitiveOnCompletionEnabled)
 	common.OptionMap["StopOnSensitiveEnabled"] = strconv.FormatBool(setting.StopOnSensitiveEnabled)
 	common.OptionMap["SensitiveWords"] = setting.SensitiveWordsToString()
 	common.OptionMap["StreamCacheQueueLength"] = strconv.Itoa(setting.StreamCacheQueueLength)
@@ -168,8 +176,16 @@ func UpdateOption(key string, value string) error {
 func updateOptionMap(key string, value string) (err error) {
 	common.OptionMapRWMutex.Lock()
 	defer common.OptionMapRWMutex.Unlock()
+	if common.OptionMap[key] != value && (key == "SensitiveWords" || strings.HasPrefix(key, "sensitive_rule_setting.")) {
+		// 定期同步会重复写入相同的值，仅在内容变化时重建敏感词匹配器
+		defer operation_setting.NotifySensitiveRulesChanged()
+	}
 	common.OptionMap[key] = value
 
+	if key == "group_model_setting.allowed_models" {
+		// 整体替换，配置系统反序列化到已有 map 时会保留已删除的分组
+		return operation_setting.UpdateGroupAllowedModelsByJSONString(value)
+	}
 	// 检查是否是模型配置 - 使用更规范的方式处理
 	if handleConfigUpdate(key, value) {
 		return nil // 已由配置系统处理
@@ -250,6 +266,8 @@ func updateOptionMap(key string, value string) (err error) {
 			operation_setting.SelfUseModeEnabled = boolValue
 		case "CheckSensitiveOnPromptEnabled":
 			setting.CheckSensitiveOnPromptEnabled = boolValue
+		case "CheckSensitiveOnCompletionEnabled":
+			setting.CheckSensitiveOnCompletionEnabled = boolValue
 		case "ModelRequestRateLimitEnabled":
 			setting.ModelRequestRateLimitEnabled = boolValue
 		case "StopOnSensitiveEnabled":
@@ -344,6 +362,12 @@ func updateOptionMap(key string, value string) (err error) {
 		common.DataExportInterval, _ = strconv.Atoi(value)
 	case "DataExportDefaultTime":
 		common.DataExportDefaultTime = value
+	case "LogLevel":
+		err = common.SetLogLevel(value)
+	case "LogFormat":
+		err = common.SetLogFormat(value)
+	case "LogModuleLevels":
+		err = common.SetModuleLogLevels(value)
 	case "ModelRatio":
 		err = operation_setting.UpdateModelRatioByJSONString(value)
 	case "GroupRatio":
@@ -356,6 +380,14 @@ func updateOptionMap(key string, value string) (err error) {
 		err = operation_setting.UpdateModelPriceByJSONString(value)
 	case "CacheRatio":
 		err = operation_setting.UpdateCacheRatioByJSONString(value)
+	case "CreateCacheRatio":
+		err = operation_setting.UpdateCreateCacheRatioByJSONString(value)
+	case "BatchRatio":
+		err = operation_setting.UpdateBatchRatioByJSONString(value)
+	case "ExchangeRates":
+		err = operation_setting.UpdateExchangeRatesByJSONString(value)
+	case "ModelPriceCurrencies":
+		err = operation_setting.UpdateModelPriceCurrenciesByJSONString(value)
 	case "TopUpLink":
 		common.TopUpLink = value
 	//case "ChatLink":
@@ -0,0 +1,302 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+
+	OrganizationRoleMember = 1
+	OrganizationRoleAdmin  = 2
+)
+
+// Organization 用户之上的组织，成员使用组织令牌（Token.OrganizationId）时消耗组织的共享额度池，
+// 不消耗个人额度；组织管理员可查看组织令牌的汇总用量
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description string `json:"description"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	MemberLimit int    `json:"member_limit" gorm:"default:0"` // 0 表示不限制
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	MemberCount int64  `json:"member_count" gorm:"-:all"`
+}
+
+// OrganizationMember 组织成员信息，来自用户表
+type OrganizationMember struct {
+	Id               int    `json:"id"`
+	Username         string `json:"username"`
+	DisplayName      string `json:"display_name"`
+	Email            string `json:"email"`
+	OrganizationRole int    `json:"organization_role"`
+	UsedQuota        int    `json:"used_quota"`
+}
+
+var organizationStatusCache = make(map[int]int)
+var organizationStatusCacheTime time.Time
+var organizationStatusCacheLock sync.RWMutex
+
+func (org *Organization) Validate() error {
+	if org.Name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	if len(org.Name) > 64 {
+		return errors.New("组织名称过长")
+	}
+	if org.MemberLimit < 0 {
+		return errors.New("成员上限不能为负数")
+	}
+	return nil
+}
+
+func GetAllOrganizations(startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	err = DB.Model(&Organization{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, org := range orgs {
+		org.MemberCount, _ = CountOrganizationMembers(org.Id)
+	}
+	return orgs, total, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	org := Organization{Id: id}
+	if err := DB.First(&org, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	org.MemberCount, _ = CountOrganizationMembers(id)
+	return &org, nil
+}
+
+func (org *Organization) Insert() error {
+	org.CreatedTime = common.GetTimestamp()
+	err := DB.Create(org).Error
+	InvalidateOrganizationCache()
+	return err
+}
+
+// Update 额度通过 AdjustOrganizationQuota 调整，这里不修改
+func (org *Organization) Update() error {
+	err := DB.Model(org).Select("name", "description", "member_limit", "status").Updates(org).Error
+	InvalidateOrganizationCache()
+	return err
+}
+
+// DeleteOrganizationById 删除组织，成员退出组织，组织令牌被禁用
+func DeleteOrganizationById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("organization_id = ?", id).
+			Updates(map[string]interface{}{"organization_id": 0, "organization_role": 0}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ?", id).Update("status", common.TokenStatusDisabled).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, "id = ?", id).Error
+	})
+	if err != nil {
+		return err
+	}
+	InvalidateOrganizationCache()
+	invalidateOrganizationTokens(id, 0)
+	return nil
+}
+
+func InvalidateOrganizationCache() {
+	organizationStatusCacheLock.Lock()
+	defer organizationStatusCacheLock.Unlock()
+	organizationStatusCacheTime = time.Time{}
+}
+
+// IsOrganizationEnabled 组织是否存在且已启用，状态按 SyncFrequency 缓存
+func IsOrganizationEnabled(id int) bool {
+	organizationStatusCacheLock.RLock()
+	if time.Since(organizationStatusCacheTime) < time.Duration(common.SyncFrequency)*time.Second {
+		status := organizationStatusCache[id]
+		organizationStatusCacheLock.RUnlock()
+		return status == OrganizationStatusEnabled
+	}
+	organizationStatusCacheLock.RUnlock()
+
+	var orgs []*Organization
+	if err := DB.Select("id", "status").Find(&orgs).Error; err != nil {
+		common.SysError("failed to load organizations: " + err.Error())
+		return false
+	}
+	statuses := make(map[int]int, len(orgs))
+	for _, org := range orgs {
+		statuses[org.Id] = org.Status
+	}
+	organizationStatusCacheLock.Lock()
+	organizationStatusCache = statuses
+	organizationStatusCacheTime = time.Now()
+	organizationStatusCacheLock.Unlock()
+	return statuses[id] == OrganizationStatusEnabled
+}
+
+func CountOrganizationMembers(orgId int) (int64, error) {
+	var count int64
+	err := DB.Model(&User{}).Where("organization_id = ?", orgId).Count(&count).Error
+	return count, err
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	err := DB.Model(&User{}).Where("organization_id = ?", orgId).
+		Select("id", "username", "display_name", "email", "organization_role", "used_quota").
+		Order("id").Find(&members).Error
+	return members, err
+}
+
+// AddOrganizationMember 将用户加入组织或修改其组织角色，用户已属于其他组织时返回错误
+func AddOrganizationMember(orgId int, userId int, role int) error {
+	if role != OrganizationRoleMember && role != OrganizationRoleAdmin {
+		return errors.New("无效的组织角色")
+	}
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return err
+	}
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return err
+	}
+	if user.OrganizationId != 0 && user.OrganizationId != orgId {
+		return fmt.Errorf("用户已属于组织 #%d", user.OrganizationId)
+	}
+	if user.OrganizationId == 0 && org.MemberLimit > 0 && org.MemberCount >= int64(org.MemberLimit) {
+		return fmt.Errorf("组织成员已达上限 %d", org.MemberLimit)
+	}
+	return DB.Model(&User{}).Where("id = ?", userId).
+		Updates(map[string]interface{}{"organization_id": orgId, "organization_role": role}).Error
+}
+
+// RemoveOrganizationMember 将用户移出组织，并禁用其名下的组织令牌
+func RemoveOrganizationMember(orgId int, userId int) error {
+	result := DB.Model(&User{}).Where("id = ? and organization_id = ?", userId, orgId).
+		Updates(map[string]interface{}{"organization_id": 0, "organization_role": 0})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不是该组织的成员")
+	}
+	err := DB.Model(&Token{}).Where("user_id = ? and organization_id = ?", userId, orgId).
+		Update("status", common.TokenStatusDisabled).Error
+	if err != nil {
+		return err
+	}
+	invalidateOrganizationTokens(orgId, userId)
+	return nil
+}
+
+// invalidateOrganizationTokens 清除组织令牌的缓存，userId 为 0 时清除组织的全部令牌
+func invalidateOrganizationTokens(orgId int, userId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	tx := DB.Unscoped().Model(&Token{}).Where("organization_id = ?", orgId)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	var keys []string
+	if err := tx.Pluck("key", &keys).Error; err != nil {
+		common.SysError("failed to get organization tokens: " + err.Error())
+		return
+	}
+	for _, key := range keys {
+		if err := cacheDeleteToken(key); err != nil {
+			common.SysError("failed to delete token cache: " + err.Error())
+		}
+	}
+}
+
+// GetOrganizationTokenIds 返回组织的全部令牌（包括已删除的），用于统计组织用量
+func GetOrganizationTokenIds(orgId int) ([]int, error) {
+	var ids []int
+	err := DB.Unscoped().Model(&Token{}).Where("organization_id = ?", orgId).Pluck("id", &ids).Error
+	return ids, err
+}
+
+func GetOrganizationQuota(orgId int) (int, error) {
+	var quota int
+	err := DB.Model(&Organization{}).Where("id = ?", orgId).Select("quota").Find(&quota).Error
+	return quota, err
+}
+
+// AdjustOrganizationQuota 管理员为组织额度池增加或扣除额度，扣除时余额不足返回错误
+func AdjustOrganizationQuota(orgId int, delta int) error {
+	if delta == 0 {
+		return errors.New("调整额度不能为 0")
+	}
+	tx := DB.Model(&Organization{}).Where("id = ?", orgId)
+	if delta < 0 {
+		tx = tx.Where("quota >= ?", -delta)
+	}
+	result := tx.Update("quota", gorm.Expr("quota + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("组织不存在或额度不足")
+	}
+	return nil
+}
+
+func decreaseOrganizationQuota(orgId int, quota int) error {
+	return DB.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", quota),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	}).Error
+}
+
+// GetPayerQuota 组织令牌返回组织额度池的余额，否则返回用户额度
+func GetPayerQuota(userId int, orgId int) (int, error) {
+	if orgId != 0 {
+		return GetOrganizationQuota(orgId)
+	}
+	return GetUserQuota(userId, false)
+}
+
+// DecreasePayerQuota 组织令牌从组织额度池扣除，否则扣除用户额度
+func DecreasePayerQuota(userId int, orgId int, quota int) error {
+	if orgId == 0 {
+		return DecreaseUserQuota(userId, quota)
+	}
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return decreaseOrganizationQuota(orgId, quota)
+}
+
+// IncreasePayerQuota 退还额度，组织令牌退回组织额度池
+func IncreasePayerQuota(userId int, orgId int, quota int, db bool) error {
+	if orgId == 0 {
+		return IncreaseUserQuota(userId, quota, db)
+	}
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return decreaseOrganizationQuota(orgId, -quota)
+}
@@ -0,0 +1,418 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PackageStatusEnabled  = 1
+	PackageStatusDisabled = 2
+
+	UserPackageStatusActive  = 1
+	UserPackageStatusExpired = 2
+	UserPackageStatusUsedUp  = 3
+)
+
+// Package 可售卖的套餐，Quota 为通用额度，ModelAllowances 为仅可用于指定模型的赠送额度，
+// 模型名以 * 结尾时按前缀匹配，如 {"gpt-4o*": 500000}
+type Package struct {
+	Id              int     `json:"id"`
+	Name            string  `json:"name" gorm:"index"`
+	Description     string  `json:"description"`
+	Quota           int     `json:"quota"`
+	DurationDays    int     `json:"duration_days"`
+	ModelAllowances string  `json:"model_allowances" gorm:"type:text"`
+	Price           float64 `json:"price"`
+	Status          int     `json:"status" gorm:"default:1"`
+	CreatedTime     int64   `json:"created_time" gorm:"bigint"`
+}
+
+// UserPackage 用户已购买的套餐，通用额度在发放时计入用户额度，这里记录其中会过期的部分，
+// 消耗时优先扣减最早过期的套餐，过期后从用户额度中扣除剩余部分
+type UserPackage struct {
+	Id              int    `json:"id"`
+	UserId          int    `json:"user_id" gorm:"index"`
+	PackageId       int    `json:"package_id"`
+	Name            string `json:"name"`
+	Quota           int    `json:"quota"`
+	RemainQuota     int    `json:"remain_quota"`
+	ModelAllowances string `json:"model_allowances" gorm:"type:text"` // 剩余的模型赠送额度
+	ExpiredTime     int64  `json:"expired_time" gorm:"bigint;index"`
+	Status          int    `json:"status" gorm:"default:1;index"`
+	CreatedTime     int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (pkg *Package) GetModelAllowances() map[string]int {
+	return parseModelAllowances(pkg.ModelAllowances)
+}
+
+func parseModelAllowances(s string) map[string]int {
+	allowances := make(map[string]int)
+	if s == "" {
+		return allowances
+	}
+	if err := common.DecodeJsonStr(s, &allowances); err != nil {
+		common.SysError("failed to parse model allowances: " + err.Error())
+	}
+	return allowances
+}
+
+func (pkg *Package) Validate() error {
+	if pkg.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if pkg.DurationDays <= 0 {
+		return errors.New("套餐有效天数必须大于 0")
+	}
+	if pkg.Quota < 0 {
+		return errors.New("套餐额度不能为负数")
+	}
+	if pkg.ModelAllowances != "" {
+		var allowances map[string]int
+		if err := common.DecodeJsonStr(pkg.ModelAllowances, &allowances); err != nil {
+			return errors.New("模型赠送额度格式错误：" + err.Error())
+		}
+	}
+	return nil
+}
+
+func GetAllPackages(startIdx int, num int) (packages []*Package, total int64, err error) {
+	err = DB.Model(&Package{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&packages).Error
+	return packages, total, err
+}
+
+func GetPackageById(id int) (*Package, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	pkg := Package{Id: id}
+	err := DB.First(&pkg, "id = ?", id).Error
+	return &pkg, err
+}
+
+func (pkg *Package) Insert() error {
+	pkg.CreatedTime = common.GetTimestamp()
+	return DB.Create(pkg).Error
+}
+
+func (pkg *Package) Update() error {
+	return DB.Model(pkg).Select("name", "description", "quota", "duration_days", "model_allowances", "price", "status").Updates(pkg).Error
+}
+
+func DeletePackageById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&Package{}, "id = ?", id).Error
+}
+
+// grantUserPackage 在事务中为用户发放套餐，通用额度同时计入用户额度
+func grantUserPackage(tx *gorm.DB, userId int, pkg *Package) (*UserPackage, error) {
+	now := common.GetTimestamp()
+	userPackage := &UserPackage{
+		UserId:          userId,
+		PackageId:       pkg.Id,
+		Name:            pkg.Name,
+		Quota:           pkg.Quota,
+		RemainQuota:     pkg.Quota,
+		ModelAllowances: pkg.ModelAllowances,
+		ExpiredTime:     now + int64(pkg.DurationDays)*24*60*60,
+		Status:          UserPackageStatusActive,
+		CreatedTime:     now,
+	}
+	if err := tx.Create(userPackage).Error; err != nil {
+		return nil, err
+	}
+	if pkg.Quota > 0 {
+		if err := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", pkg.Quota)).Error; err != nil {
+			return nil, err
+		}
+	}
+	return userPackage, nil
+}
+
+// GrantUserPackage 为用户发放套餐
+func GrantUserPackage(userId int, pkg *Package) (*UserPackage, error) {
+	var userPackage *UserPackage
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		userPackage, err = grantUserPackage(tx, userId, pkg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	afterUserPackageGranted(userId, userPackage)
+	return userPackage, nil
+}
+
+func afterUserPackageGranted(userId int, userPackage *UserPackage) {
+	invalidateUserPackageCache(userId)
+	if userPackage.Quota > 0 {
+		if err := invalidateUserCache(userId); err != nil {
+			common.SysError("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	RecordLog(userId, LogTypeTopup, fmt.Sprintf("获得套餐「%s」，额度 %s，有效期至 %s", userPackage.Name,
+		common.LogQuota(userPackage.Quota), time.Unix(userPackage.ExpiredTime, 0).Format("2006-01-02 15:04:05")))
+}
+
+func GetUserPackages(userId int, activeOnly bool) (userPackages []*UserPackage, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if activeOnly {
+		tx = tx.Where("status = ? and expired_time > ?", UserPackageStatusActive, common.GetTimestamp())
+	}
+	err = tx.Order("expired_time asc").Find(&userPackages).Error
+	return userPackages, err
+}
+
+// 记录用户是否持有生效中的套餐，避免无套餐用户每次请求都查询数据库
+const userPackageCacheMaxEntries = 100000
+
+// 其他节点发放的套餐不会清除本节点的缓存，无套餐的结果最多缓存 1 分钟
+const userPackageNegativeCacheTTL = time.Minute
+
+var userPackageCache = common.NewTTLCache[bool]()
+
+func invalidateUserPackageCache(userId int) {
+	userPackageCache.Delete(strconv.Itoa(userId))
+}
+
+func HasActiveUserPackages(userId int) bool {
+	key := strconv.Itoa(userId)
+	if active, ok := userPackageCache.Get(key); ok {
+		return active
+	}
+	var count int64
+	err := DB.Model(&UserPackage{}).Where("user_id = ? and status = ? and expired_time > ?",
+		userId, UserPackageStatusActive, common.GetTimestamp()).Count(&count).Error
+	if err != nil {
+		common.SysError("failed to count user packages: " + err.Error())
+		return false
+	}
+	ttl := time.Duration(common.SyncFrequency) * time.Second
+	if count == 0 {
+		ttl = min(ttl, userPackageNegativeCacheTTL)
+	}
+	userPackageCache.Set(key, count > 0, ttl, userPackageCacheMaxEntries)
+	return count > 0
+}
+
+func matchAllowanceModel(pattern string, modelName string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(modelName, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == modelName
+}
+
+// sortedAllowanceKeys 精确匹配优先于前缀匹配，保证扣减顺序稳定
+func sortedAllowanceKeys(allowances map[string]int, modelName string) []string {
+	var keys []string
+	for key := range allowances {
+		if matchAllowanceModel(key, modelName) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if strings.HasSuffix(keys[i], "*") != strings.HasSuffix(keys[j], "*") {
+			return !strings.HasSuffix(keys[i], "*")
+		}
+		return len(keys[i]) > len(keys[j])
+	})
+	return keys
+}
+
+// lockActiveUserPackages 在事务中锁定用户生效中的套餐，并发请求的扣减按顺序执行，includeUsedUp 时包含已用尽的套餐
+func lockActiveUserPackages(tx *gorm.DB, userId int, includeUsedUp bool) ([]*UserPackage, error) {
+	statuses := []int{UserPackageStatusActive}
+	if includeUsedUp {
+		statuses = append(statuses, UserPackageStatusUsedUp)
+	}
+	var userPackages []*UserPackage
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? and status in ? and expired_time > ?", userId, statuses, common.GetTimestamp()).
+		Order("expired_time asc").Order("id asc").Find(&userPackages).Error
+	return userPackages, err
+}
+
+func saveUserPackages(tx *gorm.DB, changed map[int]*UserPackage) error {
+	for _, userPackage := range changed {
+		if userPackage.RemainQuota <= 0 && !hasAllowanceLeft(userPackage) {
+			userPackage.Status = UserPackageStatusUsedUp
+		} else {
+			userPackage.Status = UserPackageStatusActive
+		}
+		err := tx.Model(userPackage).Select("remain_quota", "model_allowances", "status").Updates(userPackage).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeUserPackages 按过期时间从早到晚扣减用户套餐：先扣减对应模型的赠送额度，再扣减套餐通用额度，
+// allowanceOnly 时只扣减模型赠送额度（用于预扣）。返回由模型赠送额度抵扣的部分，该部分不应计入用户额度的消耗
+func ConsumeUserPackages(userId int, modelName string, quota int, allowanceOnly bool) (allowanceCovered int, err error) {
+	if quota <= 0 || !HasActiveUserPackages(userId) {
+		return 0, nil
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		allowanceCovered = 0
+		userPackages, err := lockActiveUserPackages(tx, userId, false)
+		if err != nil {
+			return err
+		}
+		remaining := quota
+		changed := make(map[int]*UserPackage)
+		for _, userPackage := range userPackages {
+			if remaining <= 0 {
+				break
+			}
+			allowances := parseModelAllowances(userPackage.ModelAllowances)
+			for _, key := range sortedAllowanceKeys(allowances, modelName) {
+				if remaining <= 0 {
+					break
+				}
+				used := min(allowances[key], remaining)
+				if used <= 0 {
+					continue
+				}
+				allowances[key] -= used
+				remaining -= used
+				allowanceCovered += used
+				userPackage.ModelAllowances = encodeModelAllowances(allowances)
+				changed[userPackage.Id] = userPackage
+			}
+		}
+		for _, userPackage := range userPackages {
+			if allowanceOnly || remaining <= 0 {
+				break
+			}
+			used := min(userPackage.RemainQuota, remaining)
+			if used <= 0 {
+				continue
+			}
+			userPackage.RemainQuota -= used
+			remaining -= used
+			changed[userPackage.Id] = userPackage
+		}
+		return saveUserPackages(tx, changed)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return allowanceCovered, nil
+}
+
+// RefundUserPackageAllowance 将预扣后未使用的模型赠送额度退还到最早过期的、包含该模型赠送额度的套餐
+func RefundUserPackageAllowance(userId int, modelName string, quota int) error {
+	if quota <= 0 {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		userPackages, err := lockActiveUserPackages(tx, userId, true)
+		if err != nil {
+			return err
+		}
+		for _, userPackage := range userPackages {
+			allowances := parseModelAllowances(userPackage.ModelAllowances)
+			keys := sortedAllowanceKeys(allowances, modelName)
+			if len(keys) == 0 {
+				continue
+			}
+			allowances[keys[0]] += quota
+			userPackage.ModelAllowances = encodeModelAllowances(allowances)
+			return saveUserPackages(tx, map[int]*UserPackage{userPackage.Id: userPackage})
+		}
+		// 套餐已过期，未使用的赠送额度随套餐失效
+		return nil
+	})
+	if err == nil {
+		invalidateUserPackageCache(userId)
+	}
+	return err
+}
+
+func encodeModelAllowances(allowances map[string]int) string {
+	data, err := common.EncodeJson(allowances)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func hasAllowanceLeft(userPackage *UserPackage) bool {
+	for _, v := range parseModelAllowances(userPackage.ModelAllowances) {
+		if v > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpireUserPackages 将已过期的套餐标记为过期，并从用户额度中扣除其剩余的通用额度
+func ExpireUserPackages() (int, error) {
+	var userPackages []*UserPackage
+	err := DB.Where("status = ? and expired_time <= ?", UserPackageStatusActive, common.GetTimestamp()).
+		Limit(1000).Find(&userPackages).Error
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, userPackage := range userPackages {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&UserPackage{}).Where("id = ? and status = ?", userPackage.Id, UserPackageStatusActive).
+				Update("status", UserPackageStatusExpired)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			if userPackage.RemainQuota > 0 {
+				// 用户额度可能已被其他方式扣减，最多扣到 0
+				return tx.Model(&User{}).Where("id = ?", userPackage.UserId).
+					Update("quota", gorm.Expr("case when quota > ? then quota - ? else 0 end", userPackage.RemainQuota, userPackage.RemainQuota)).Error
+			}
+			return nil
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to expire user package #%d: %s", userPackage.Id, err.Error()))
+			continue
+		}
+		expired++
+		invalidateUserPackageCache(userPackage.UserId)
+		if userPackage.RemainQuota > 0 {
+			if err := invalidateUserCache(userPackage.UserId); err != nil {
+				common.SysError("failed to invalidate user cache: " + err.Error())
+			}
+			RecordLog(userPackage.UserId, LogTypeSystem, fmt.Sprintf("套餐「%s」已过期，扣除剩余额度 %s", userPackage.Name, common.LogQuota(userPackage.RemainQuota)))
+		}
+	}
+	return expired, nil
+}
+
+// AutomaticallyExpireUserPackages 定期处理过期套餐，仅在主节点运行
+func AutomaticallyExpireUserPackages() {
+	for {
+		time.Sleep(time.Minute)
+		expired, err := ExpireUserPackages()
+		if err != nil {
+			common.SysError("failed to expire user packages: " + err.Error())
+			continue
+		}
+		if expired > 0 {
+			common.SysLog(fmt.Sprintf("%d user packages expired", expired))
+		}
+	}
+}
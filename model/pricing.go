@@ -44,6 +44,13 @@ func GetPricing() []Pricing {
 	return pricingMap
 }
 
+// InvalidatePricing 倍率或价格修改后调用，下次获取定价时重新计算
+func InvalidatePricing() {
+	updatePricingLock.Lock()
+	defer updatePricingLock.Unlock()
+	lastGetPricingTime = time.Time{}
+}
+
 func updatePricing() {
 	//modelRatios := common.GetModelRatios()
 	enableAbilities := GetAllEnableAbilities()
@@ -0,0 +1,303 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	QuotaGrantScheduleStatusEnabled  = 1
+	QuotaGrantScheduleStatusDisabled = 2
+
+	QuotaGrantPeriodDaily   = "daily"
+	QuotaGrantPeriodWeekly  = "weekly"
+	QuotaGrantPeriodMonthly = "monthly"
+)
+
+// QuotaGrantSchedule 周期性发放额度的计划，如每月为免费用户补充额度；
+// 发放对象为 UserIds 中的用户，或 Group 分组下的全部启用用户
+type QuotaGrantSchedule struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"index"`
+	UserIds     string `json:"user_ids" gorm:"type:text"` // JSON 数组，如 [1,2,3]
+	Group       string `json:"group" gorm:"type:varchar(64)"`
+	Quota       int    `json:"quota"`
+	Reason      string `json:"reason" gorm:"type:varchar(64)"`
+	Period      string `json:"period" gorm:"type:varchar(16)"`
+	NextRunTime int64  `json:"next_run_time" gorm:"bigint;index"`
+	LastRunTime int64  `json:"last_run_time" gorm:"bigint"`
+	Status      int    `json:"status" gorm:"default:1;index"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	// 最近一次创建或修改计划的管理员，发放时只发给该管理员有权调整额度的用户
+	OperatorId   int `json:"operator_id"`
+	OperatorRole int `json:"operator_role" gorm:"default:10"`
+}
+
+// QuotaAdjustResult 批量调整额度时单个用户的处理结果
+type QuotaAdjustResult struct {
+	UserId  int    `json:"user_id"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+var quotaGrantPeriods = map[string]bool{
+	QuotaGrantPeriodDaily:   true,
+	QuotaGrantPeriodWeekly:  true,
+	QuotaGrantPeriodMonthly: true,
+}
+
+// ValidateQuotaAdjustReason 原因代码必填，便于按原因统计与审计
+func ValidateQuotaAdjustReason(reason string) error {
+	if reason == "" {
+		return errors.New("原因代码不能为空")
+	}
+	if len(reason) > 64 {
+		return errors.New("原因代码不能超过 64 个字符")
+	}
+	return nil
+}
+
+func (schedule *QuotaGrantSchedule) GetUserIds() []int {
+	var userIds []int
+	if schedule.UserIds == "" {
+		return userIds
+	}
+	if err := common.DecodeJsonStr(schedule.UserIds, &userIds); err != nil {
+		common.SysError(fmt.Sprintf("failed to parse user ids of quota grant schedule #%d: %s", schedule.Id, err.Error()))
+	}
+	return userIds
+}
+
+func (schedule *QuotaGrantSchedule) Validate() error {
+	if schedule.Name == "" {
+		return errors.New("计划名称不能为空")
+	}
+	if schedule.Quota <= 0 {
+		return errors.New("发放额度必须大于 0")
+	}
+	if !quotaGrantPeriods[schedule.Period] {
+		return fmt.Errorf("无效的发放周期：%s", schedule.Period)
+	}
+	if err := ValidateQuotaAdjustReason(schedule.Reason); err != nil {
+		return err
+	}
+	if schedule.UserIds != "" {
+		var userIds []int
+		if err := common.DecodeJsonStr(schedule.UserIds, &userIds); err != nil {
+			return errors.New("用户列表格式错误：" + err.Error())
+		}
+	}
+	if schedule.Group == "" && len(schedule.GetUserIds()) == 0 {
+		return errors.New("用户列表与分组不能同时为空")
+	}
+	if schedule.NextRunTime <= 0 {
+		return errors.New("首次发放时间不能为空")
+	}
+	return nil
+}
+
+// nextQuotaGrantTime 计算下一次发放时间，停机错过的周期不补发
+func nextQuotaGrantTime(period string, from int64, now int64) int64 {
+	next := time.Unix(from, 0)
+	for next.Unix() <= now {
+		switch period {
+		case QuotaGrantPeriodDaily:
+			next = next.AddDate(0, 0, 1)
+		case QuotaGrantPeriodWeekly:
+			next = next.AddDate(0, 0, 7)
+		default:
+			next = next.AddDate(0, 1, 0)
+		}
+	}
+	return next.Unix()
+}
+
+func GetAllQuotaGrantSchedules(startIdx int, num int) (schedules []*QuotaGrantSchedule, total int64, err error) {
+	err = DB.Model(&QuotaGrantSchedule{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&schedules).Error
+	return schedules, total, err
+}
+
+func GetQuotaGrantScheduleById(id int) (*QuotaGrantSchedule, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	schedule := QuotaGrantSchedule{Id: id}
+	err := DB.First(&schedule, "id = ?", id).Error
+	return &schedule, err
+}
+
+func (schedule *QuotaGrantSchedule) Insert() error {
+	schedule.CreatedTime = common.GetTimestamp()
+	return DB.Create(schedule).Error
+}
+
+func (schedule *QuotaGrantSchedule) Update() error {
+	return DB.Model(schedule).Select("name", "user_ids", "group", "quota", "reason", "period", "next_run_time", "status", "operator_id", "operator_role").Updates(schedule).Error
+}
+
+func DeleteQuotaGrantScheduleById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&QuotaGrantSchedule{}, "id = ?", id).Error
+}
+
+func recordQuotaAdjustLog(userId int, content string, reason string, delta int, operatorId int) {
+	username, _ := GetUsernameById(userId, false)
+	other := map[string]interface{}{
+		"reason": reason,
+		"quota":  delta,
+	}
+	if operatorId != 0 {
+		other["operator_id"] = operatorId
+	}
+	log := &Log{
+		UserId:    userId,
+		Username:  username,
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeManage,
+		Content:   content,
+		Other:     common.MapToJsonStr(other),
+	}
+	if err := LOG_DB.Create(log).Error; err != nil {
+		common.SysError("failed to record log: " + err.Error())
+	}
+}
+
+// AdjustUserQuota 为单个用户增加（delta > 0）或扣除（delta < 0）额度并记录原因；
+// 扣除时额度不足返回错误，不会扣成负数
+func AdjustUserQuota(userId int, delta int, reason string, operatorId int) error {
+	if delta == 0 {
+		return errors.New("调整额度不能为 0")
+	}
+	if delta > 0 {
+		if err := IncreaseUserQuota(userId, delta, true); err != nil {
+			return err
+		}
+		recordQuotaAdjustLog(userId, fmt.Sprintf("管理员增加额度 %s，原因：%s", common.LogQuota(delta), reason), reason, delta, operatorId)
+		return nil
+	}
+	// 条件更新避免并发扣减时扣成负数
+	result := DB.Model(&User{}).Where("id = ? and quota >= ?", userId, -delta).Update("quota", gorm.Expr("quota + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户额度不足")
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	invalidateUserQuotaCounter(userId)
+	recordQuotaAdjustLog(userId, fmt.Sprintf("管理员扣除额度 %s，原因：%s", common.LogQuota(-delta), reason), reason, delta, operatorId)
+	return nil
+}
+
+// quotaGrantUserQuery 发放对象的查询条件：UserIds 中的用户与 Group 分组下的启用用户
+func quotaGrantUserQuery(schedule *QuotaGrantSchedule) *gorm.DB {
+	query := DB.Model(&User{})
+	userIds := schedule.GetUserIds()
+	switch {
+	case len(userIds) > 0 && schedule.Group != "":
+		query = query.Where("(id IN ? OR ("+groupCol+" = ? AND status = ?))", userIds, schedule.Group, common.UserStatusEnabled)
+	case len(userIds) > 0:
+		query = query.Where("id IN ?", userIds)
+	default:
+		query = query.Where(groupCol+" = ? AND status = ?", schedule.Group, common.UserStatusEnabled)
+	}
+	return query
+}
+
+// CheckOperatorRole 发放对象中不能有与操作者同权限等级或更高权限等级的用户，超级管理员不受限制
+func (schedule *QuotaGrantSchedule) CheckOperatorRole(role int) error {
+	if role == common.RoleRootUser {
+		return nil
+	}
+	var deniedIds []int
+	if err := quotaGrantUserQuery(schedule).Where("role >= ?", role).Limit(1).Pluck("id", &deniedIds).Error; err != nil {
+		return err
+	}
+	if len(deniedIds) > 0 {
+		return fmt.Errorf("无权为同权限等级或更高权限等级的用户 #%d 发放额度", deniedIds[0])
+	}
+	return nil
+}
+
+// getQuotaGrantUserIds 返回发放对象，分组成员与角色在每次发放时重新查询，跳过计划操作者无权调整额度的用户
+func getQuotaGrantUserIds(schedule *QuotaGrantSchedule) ([]int, error) {
+	query := quotaGrantUserQuery(schedule)
+	if schedule.OperatorRole != common.RoleRootUser {
+		query = query.Where("role < ?", schedule.OperatorRole)
+	}
+	var userIds []int
+	err := query.Pluck("id", &userIds).Error
+	return userIds, err
+}
+
+// RunQuotaGrantSchedule 执行一次发放计划，返回成功发放的用户数
+func RunQuotaGrantSchedule(schedule *QuotaGrantSchedule) (int, error) {
+	userIds, err := getQuotaGrantUserIds(schedule)
+	if err != nil {
+		return 0, err
+	}
+	granted := 0
+	seen := make(map[int]bool, len(userIds))
+	for _, userId := range userIds {
+		if seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		if err := IncreaseUserQuota(userId, schedule.Quota, true); err != nil {
+			common.SysError(fmt.Sprintf("failed to grant quota to user #%d by schedule #%d: %s", userId, schedule.Id, err.Error()))
+			continue
+		}
+		recordQuotaAdjustLog(userId, fmt.Sprintf("定期发放「%s」增加额度 %s，原因：%s", schedule.Name, common.LogQuota(schedule.Quota), schedule.Reason), schedule.Reason, schedule.Quota, 0)
+		granted++
+	}
+	return granted, nil
+}
+
+// RunDueQuotaGrantSchedules 执行已到期的发放计划，先以条件更新推进下一次发放时间，避免重复发放
+func RunDueQuotaGrantSchedules() (int, error) {
+	now := common.GetTimestamp()
+	var schedules []*QuotaGrantSchedule
+	err := DB.Where("status = ? and next_run_time <= ?", QuotaGrantScheduleStatusEnabled, now).Limit(100).Find(&schedules).Error
+	if err != nil {
+		return 0, err
+	}
+	executed := 0
+	for _, schedule := range schedules {
+		next := nextQuotaGrantTime(schedule.Period, schedule.NextRunTime, now)
+		result := DB.Model(&QuotaGrantSchedule{}).Where("id = ? and next_run_time = ?", schedule.Id, schedule.NextRunTime).
+			Updates(map[string]interface{}{"next_run_time": next, "last_run_time": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		granted, err := RunQuotaGrantSchedule(schedule)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to run quota grant schedule #%d: %s", schedule.Id, err.Error()))
+			continue
+		}
+		common.SysLog(fmt.Sprintf("quota grant schedule #%d granted %d users", schedule.Id, granted))
+		executed++
+	}
+	return executed, nil
+}
+
+// AutomaticallyRunQuotaGrantSchedules 定期执行额度发放计划，仅在主节点运行
+func AutomaticallyRunQuotaGrantSchedules() {
+	for {
+		time.Sleep(time.Minute)
+		if _, err := RunDueQuotaGrantSchedules(); err != nil {
+			common.SysError("failed to run quota grant schedules: " + err.Error())
+		}
+	}
+}
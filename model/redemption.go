@@ -20,6 +20,7 @@ type Redemption struct {
 	RedeemedTime int64          `json:"redeemed_time" gorm:"bigint"`
 	Count        int            `json:"count" gorm:"-:all"` // only for api request
 	UsedUserId   int            `json:"used_user_id"`
+	PackageId    int            `json:"package_id" gorm:"default:0"` // 非 0 时兑换对应套餐而非直接充值额度
 	DeletedAt    gorm.DeletedAt `gorm:"index"`
 }
 
@@ -117,6 +118,7 @@ func Redeem(key string, userId int) (quota int, err error) {
 		return 0, errors.New("无效的 user id")
 	}
 	redemption := &Redemption{}
+	var userPackage *UserPackage
 
 	keyCol := "`key`"
 	if common.UsingPostgreSQL {
@@ -131,9 +133,22 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if redemption.Status != common.RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
-		if err != nil {
-			return err
+		if redemption.PackageId != 0 {
+			pkg := &Package{}
+			err = tx.First(pkg, "id = ? and status = ?", redemption.PackageId, PackageStatusEnabled).Error
+			if err != nil {
+				return errors.New("兑换码对应的套餐不存在或已下架")
+			}
+			userPackage, err = grantUserPackage(tx, userId, pkg)
+			if err != nil {
+				return err
+			}
+			redemption.Quota = pkg.Quota
+		} else {
+			err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+			if err != nil {
+				return err
+			}
 		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
@@ -144,6 +159,14 @@ func Redeem(key string, userId int) (quota int, err error) {
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
+	// 额度直接写入数据库，清除用户缓存与 Redis 额度计数器
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	if userPackage != nil {
+		afterUserPackageGranted(userId, userPackage)
+		return redemption.Quota, nil
+	}
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s，兑换码ID %d", common.LogQuota(redemption.Quota), redemption.Id))
 	return redemption.Quota, nil
 }
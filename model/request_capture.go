@@ -0,0 +1,49 @@
+package model
+
+import (
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// RequestCapture 调试用的请求/响应体抓取记录，按 request_id 查询，内容已脱敏并截断
+type RequestCapture struct {
+	Id           int    `json:"id"`
+	RequestId    string `json:"request_id" gorm:"type:varchar(64);index"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	ChannelId    int    `json:"channel_id"`
+	ModelName    string `json:"model_name" gorm:"type:varchar(255);default:''"`
+	Method       string `json:"method" gorm:"type:varchar(16)"`
+	Path         string `json:"path" gorm:"type:varchar(255)"`
+	StatusCode   int    `json:"status_code"`
+	RequestBody  string `json:"request_body" gorm:"type:text"`
+	ResponseBody string `json:"response_body" gorm:"type:text"`
+	Truncated    bool   `json:"truncated"`
+}
+
+// requestCaptureDB 日志库为 ClickHouse 时抓取记录写入主库，ClickHouse 没有自增主键
+func requestCaptureDB() *gorm.DB {
+	if common.UsingClickHouse {
+		return DB
+	}
+	return LOG_DB
+}
+
+func RecordRequestCapture(capture *RequestCapture) error {
+	if capture.CreatedAt == 0 {
+		capture.CreatedAt = common.GetTimestamp()
+	}
+	return requestCaptureDB().Create(capture).Error
+}
+
+// GetRequestCaptures 同一 request_id 在重试时可能对应多条记录
+func GetRequestCaptures(requestId string) (captures []*RequestCapture, err error) {
+	err = requestCaptureDB().Where("request_id = ?", requestId).Order("id asc").Find(&captures).Error
+	return captures, err
+}
+
+func DeleteRequestCapturesBefore(timestamp int64) (int64, error) {
+	result := requestCaptureDB().Where("created_at < ?", timestamp).Delete(&RequestCapture{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 会话粘性路由表：记录会话上次成功使用的渠道，启用 Redis 时在多个节点间共享
+
+type stickyEntry struct {
+	channelId int
+	expireAt  time.Time
+}
+
+// 内存粘性表超过该数量时清理过期记录
+const stickyTableSweepSize = 100000
+
+var stickyTable = make(map[string]stickyEntry)
+var stickyTableLock sync.Mutex
+
+func stickyRedisKey(key string) string {
+	return fmt.Sprintf("sticky:%s", key)
+}
+
+func getStickyTTL() time.Duration {
+	ttl := operation_setting.GetStickyRoutingSetting().TTLSeconds
+	if ttl <= 0 {
+		ttl = 3600
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// GetStickyChannelId 返回会话绑定的渠道，不存在或已过期时返回 0
+func GetStickyChannelId(key string) int {
+	if common.RedisEnabled {
+		val, err := common.RedisGet(stickyRedisKey(key))
+		if err != nil {
+			return 0
+		}
+		channelId, _ := strconv.Atoi(val)
+		return channelId
+	}
+	stickyTableLock.Lock()
+	defer stickyTableLock.Unlock()
+	entry, ok := stickyTable[key]
+	if !ok {
+		return 0
+	}
+	if time.Now().After(entry.expireAt) {
+		delete(stickyTable, key)
+		return 0
+	}
+	return entry.channelId
+}
+
+// SetStickyChannelId 绑定会话与渠道并续期
+func SetStickyChannelId(key string, channelId int) {
+	ttl := getStickyTTL()
+	if common.RedisEnabled {
+		if err := common.RedisSet(stickyRedisKey(key), strconv.Itoa(channelId), ttl); err != nil {
+			common.SysError("failed to set sticky channel: " + err.Error())
+		}
+		return
+	}
+	stickyTableLock.Lock()
+	defer stickyTableLock.Unlock()
+	now := time.Now()
+	if len(stickyTable) >= stickyTableSweepSize {
+		for k, entry := range stickyTable {
+			if now.After(entry.expireAt) {
+				delete(stickyTable, k)
+			}
+		}
+	}
+	stickyTable[key] = stickyEntry{channelId: channelId, expireAt: now.Add(ttl)}
+}
+
+// GetStickyChannel 返回会话绑定且仍可用于该分组和模型的渠道
+func GetStickyChannel(key string, group string, model string) *Channel {
+	channelId := GetStickyChannelId(key)
+	if channelId == 0 {
+		return nil
+	}
+	if IsChannelCircuitOpen(channelId) {
+		return nil
+	}
+	if !IsChannelSatisfied(group, model, channelId) {
+		return nil
+	}
+	channel, err := CacheGetChannel(channelId)
+	if err != nil || channel.Status != common.ChannelStatusEnabled {
+		return nil
+	}
+	return channel
+}
@@ -39,6 +39,9 @@ type Task struct {
 	Properties Properties            `json:"properties" gorm:"type:json"`
 
 	Data json.RawMessage `json:"data" gorm:"type:json"`
+
+	// OrganizationId 使用组织令牌提交的任务，失败补偿退回组织额度池
+	OrganizationId int `json:"organization_id" gorm:"default:0"`
 }
 
 func (t *Task) SetData(data any) {
@@ -79,12 +82,13 @@ type SyncTaskQueryParams struct {
 
 func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.TaskRelayInfo) *Task {
 	t := &Task{
-		UserId:     relayInfo.UserId,
-		SubmitTime: time.Now().Unix(),
-		Status:     TaskStatusNotStart,
-		Progress:   "0%",
-		ChannelId:  relayInfo.ChannelId,
-		Platform:   platform,
+		UserId:         relayInfo.UserId,
+		SubmitTime:     time.Now().Unix(),
+		Status:         TaskStatusNotStart,
+		Progress:       "0%",
+		ChannelId:      relayInfo.ChannelId,
+		Platform:       platform,
+		OrganizationId: relayInfo.OrganizationId,
 	}
 	return t
 }
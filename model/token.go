@@ -1,8 +1,10 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"one-api/common"
 	"strings"
 
@@ -26,33 +28,74 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
+	OrganizationId     int            `json:"organization_id" gorm:"default:0;index"` // 组织令牌，消耗组织额度池
+	Setting            string         `json:"setting" gorm:"type:text"`
+	PrevKey            string         `json:"-" gorm:"type:char(48);index;default:''"` // 轮换前的密钥，宽限期内仍可使用
+	PrevKeyExpiredTime int64          `json:"prev_key_expired_time" gorm:"bigint;default:0"`
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
 func (token *Token) Clean() {
 	token.Key = ""
+	token.PrevKey = ""
 }
 
-func (token *Token) GetIpLimitsMap() map[string]any {
-	// delete empty spaces
-	//split with \n
-	ipLimitsMap := make(map[string]any)
+// GetAllowIpNets 返回令牌允许访问的网段，支持单个 IP 与 CIDR，无效的条目会被忽略
+func (token *Token) GetAllowIpNets() []*net.IPNet {
 	if token.AllowIps == nil {
-		return ipLimitsMap
-	}
-	cleanIps := strings.ReplaceAll(*token.AllowIps, " ", "")
-	if cleanIps == "" {
-		return ipLimitsMap
-	}
-	ips := strings.Split(cleanIps, "\n")
-	for _, ip := range ips {
-		ip = strings.TrimSpace(ip)
-		ip = strings.ReplaceAll(ip, ",", "")
-		if common.IsIP(ip) {
-			ipLimitsMap[ip] = true
+		return nil
+	}
+	var nets []*net.IPNet
+	for _, entry := range common.SplitIPEntries(*token.AllowIps) {
+		if ipNet, err := common.ParseIPNet(entry); err == nil {
+			nets = append(nets, ipNet)
 		}
 	}
-	return ipLimitsMap
+	return nets
+}
+
+// ValidateAllowIps 保存令牌前检查 IP 白名单格式
+func (token *Token) ValidateAllowIps() error {
+	if token.AllowIps == nil {
+		return nil
+	}
+	_, err := common.ParseIPNets(*token.AllowIps)
+	return err
+}
+
+func (token *Token) GetSetting() map[string]interface{} {
+	setting := make(map[string]interface{})
+	if token.Setting != "" {
+		err := json.Unmarshal([]byte(token.Setting), &setting)
+		if err != nil {
+			common.SysError("failed to unmarshal token setting: " + err.Error())
+		}
+	}
+	return setting
+}
+
+func (token *Token) SetSetting(setting map[string]interface{}) {
+	settingBytes, err := json.Marshal(setting)
+	if err != nil {
+		common.SysError("failed to marshal token setting: " + err.Error())
+		return
+	}
+	token.Setting = string(settingBytes)
+}
+
+// UpdateSetting 只更新令牌配置
+func (token *Token) UpdateSetting() (err error) {
+	defer func() {
+		if shouldUpdateRedis(true, err) {
+			gopool.Go(func() {
+				err := cacheSetToken(*token)
+				if err != nil {
+					common.SysError("failed to update token cache: " + err.Error())
+				}
+			})
+		}
+	}()
+	return DB.Model(token).Update("setting", token.Setting).Error
 }
 
 func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
@@ -162,9 +205,54 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	}
 	fromDB = true
 	err = DB.Where(keyCol+" = ?", key).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 轮换后的宽限期内旧密钥仍然有效，返回的令牌使用新密钥，额度等操作均按新密钥进行
+		err = DB.Where("prev_key = ? AND prev_key_expired_time > ?", key, common.GetTimestamp()).First(&token).Error
+	}
 	return token, err
 }
 
+// RotateToken 为令牌生成新的密钥并保留 id、额度与限制，旧密钥在 graceSeconds 内仍可使用；
+// expiredTime 不为 nil 时同时更新令牌的过期时间
+func RotateToken(id int, userId int, graceSeconds int64, expiredTime *int64) (*Token, error) {
+	token, err := GetTokenByIds(id, userId)
+	if err != nil {
+		return nil, err
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	oldKey := token.Key
+	token.PrevKey = ""
+	token.PrevKeyExpiredTime = 0
+	if graceSeconds > 0 {
+		token.PrevKey = oldKey
+		token.PrevKeyExpiredTime = common.GetTimestamp() + graceSeconds
+	}
+	token.Key = key
+	fields := []string{"key", "prev_key", "prev_key_expired_time"}
+	if expiredTime != nil {
+		token.ExpiredTime = *expiredTime
+		fields = append(fields, "expired_time")
+		if token.Status == common.TokenStatusExpired && (token.ExpiredTime == -1 || token.ExpiredTime > common.GetTimestamp()) {
+			token.Status = common.TokenStatusEnabled
+			fields = append(fields, "status")
+		}
+	}
+	if err = DB.Model(token).Select(fields).Updates(token).Error; err != nil {
+		return nil, err
+	}
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			if err := cacheDeleteToken(oldKey); err != nil {
+				common.SysError("failed to delete token cache: " + err.Error())
+			}
+		})
+	}
+	return token, nil
+}
+
 func (token *Token) Insert() error {
 	var err error
 	err = DB.Create(token).Error
@@ -184,7 +272,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "setting").Updates(token).Error
 	return err
 }
 
@@ -238,6 +326,19 @@ func (token *Token) GetModelLimitsMap() map[string]bool {
 	return limitsMap
 }
 
+// ModelLimitsAllow 检查模型是否在令牌的模型限制内，以 * 结尾的限制项按前缀匹配，如 gpt-4o*
+func ModelLimitsAllow(limits map[string]bool, modelName string) bool {
+	if limits[modelName] {
+		return true
+	}
+	for limit := range limits {
+		if strings.HasSuffix(limit, "*") && strings.HasPrefix(modelName, strings.TrimSuffix(limit, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
 func DisableModelLimits(tokenId int) error {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
@@ -320,3 +421,10 @@ func decreaseTokenQuota(id int, quota int) (err error) {
 	).Error
 	return err
 }
+
+// CountGroupTokens 统计指定了该分组的令牌数
+func CountGroupTokens(group string) (int64, error) {
+	var count int64
+	err := DB.Model(&Token{}).Where(groupCol+" = ?", group).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,13 @@
+package model
+
+// GetConsumeLogsAfterId 按 id 游标分批读取时间范围内的消费日志，供离线导出使用，避免大偏移量分页
+func GetConsumeLogsAfterId(startTimestamp int64, endTimestamp int64, afterId int, limit int) (logs []*Log, err error) {
+	err = LOG_DB.Where("type = ? and created_at >= ? and created_at < ? and id > ?", LogTypeConsume, startTimestamp, endTimestamp, afterId).
+		Order("id asc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+func GetCostStatsInRange(startTimestamp int64, endTimestamp int64) (stats []*CostStat, err error) {
+	err = DB.Where("created_at >= ? and created_at < ?", startTimestamp, endTimestamp).Order("created_at asc, id asc").Find(&stats).Error
+	return stats, err
+}
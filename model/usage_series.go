@@ -0,0 +1,212 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	UsageSeriesIntervalHour = "hour"
+	UsageSeriesIntervalDay  = "day"
+)
+
+// 统计数据按 DataExportInterval 定期写入，短时间内重复查询直接使用缓存
+const usageSeriesCacheTTL = time.Minute
+
+// 内存缓存超过该数量时清理过期记录
+const usageSeriesCacheSweepSize = 1000
+
+// UsageSeriesPoint 时间序列中的一个点，Time 为小时或自然日的起始时间戳，Key 为分组维度的值，不分组时为空
+type UsageSeriesPoint struct {
+	Time             int64  `json:"time"`
+	Key              string `json:"key,omitempty"`
+	Name             string `json:"name,omitempty"`
+	Count            int64  `json:"count"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+type usageSeriesCacheEntry struct {
+	points   []*UsageSeriesPoint
+	expireAt time.Time
+}
+
+var usageSeriesCache = make(map[string]usageSeriesCacheEntry)
+var usageSeriesCacheLock sync.Mutex
+
+func usageSeriesCacheKey(interval string, groupBy string, filter CostFilter) string {
+	return fmt.Sprintf("usage_series:%s:%s:%d:%d:%d:%d:%d:%s:%v", interval, groupBy, filter.StartTimestamp, filter.EndTimestamp,
+		filter.UserId, filter.TokenId, filter.ChannelId, filter.ModelName, filter.TokenIds)
+}
+
+func getCachedUsageSeries(key string) ([]*UsageSeriesPoint, bool) {
+	if common.RedisEnabled {
+		val, err := common.RedisGet(key)
+		if err != nil {
+			return nil, false
+		}
+		var points []*UsageSeriesPoint
+		if err := common.DecodeJsonStr(val, &points); err != nil {
+			return nil, false
+		}
+		return points, true
+	}
+	usageSeriesCacheLock.Lock()
+	defer usageSeriesCacheLock.Unlock()
+	entry, ok := usageSeriesCache[key]
+	if !ok || time.Now().After(entry.expireAt) {
+		return nil, false
+	}
+	return entry.points, true
+}
+
+func setCachedUsageSeries(key string, points []*UsageSeriesPoint) {
+	if common.RedisEnabled {
+		data, err := common.EncodeJson(points)
+		if err != nil {
+			return
+		}
+		if err := common.RedisSet(key, string(data), usageSeriesCacheTTL); err != nil {
+			common.SysError("failed to cache usage series: " + err.Error())
+		}
+		return
+	}
+	usageSeriesCacheLock.Lock()
+	defer usageSeriesCacheLock.Unlock()
+	now := time.Now()
+	if len(usageSeriesCache) >= usageSeriesCacheSweepSize {
+		for k, entry := range usageSeriesCache {
+			if now.After(entry.expireAt) {
+				delete(usageSeriesCache, k)
+			}
+		}
+	}
+	usageSeriesCache[key] = usageSeriesCacheEntry{points: points, expireAt: now.Add(usageSeriesCacheTTL)}
+}
+
+func usageSeriesGroupColumn(groupBy string) (string, error) {
+	switch groupBy {
+	case "":
+		return "", nil
+	case CostGroupByModel:
+		return "model_name", nil
+	case CostGroupByChannel:
+		return "channel_id", nil
+	case CostGroupByToken:
+		return "token_id", nil
+	case CostGroupByUser:
+		return "user_id", nil
+	}
+	return "", errors.New("不支持的分组维度：" + groupBy)
+}
+
+// GetUsageSeries 返回按小时或按本地时区自然日汇总的用量时间序列，可按模型、渠道、令牌或用户分组，
+// 数据来自按小时预聚合的统计表，结果短时间缓存
+func GetUsageSeries(interval string, groupBy string, filter CostFilter) ([]*UsageSeriesPoint, error) {
+	if interval != UsageSeriesIntervalHour && interval != UsageSeriesIntervalDay {
+		return nil, errors.New("不支持的时间粒度：" + interval)
+	}
+	column, err := usageSeriesGroupColumn(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := usageSeriesCacheKey(interval, groupBy, filter)
+	if points, ok := getCachedUsageSeries(cacheKey); ok {
+		return points, nil
+	}
+
+	tx := DB.Model(&CostStat{})
+	timeColumn := "created_at"
+	countSelect := "sum(count)"
+	if common.UsingClickHouse {
+		// ClickHouse 直接聚合原始消费日志，先按小时分桶
+		tx = LOG_DB.Table("logs").Where("type = ?", LogTypeConsume)
+		timeColumn = "intDiv(created_at, 3600) * 3600"
+		countSelect = "count(*)"
+	}
+	tx = tx.Where("created_at >= ? and created_at <= ?", filter.StartTimestamp, filter.EndTimestamp)
+	if filter.UserId != 0 {
+		tx = tx.Where("user_id = ?", filter.UserId)
+	}
+	if filter.TokenId != 0 {
+		tx = tx.Where("token_id = ?", filter.TokenId)
+	}
+	if filter.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", filter.ChannelId)
+	}
+	if filter.ModelName != "" {
+		tx = tx.Where("model_name = ?", filter.ModelName)
+	}
+	if len(filter.TokenIds) > 0 {
+		tx = tx.Where("token_id in ?", filter.TokenIds)
+	}
+
+	keySelect := "'' as stat_key"
+	nameSelect := "'' as name"
+	group := timeColumn
+	if column != "" {
+		keySelect = column + " as stat_key"
+		group = timeColumn + ", " + column
+		switch groupBy {
+		case CostGroupByToken:
+			nameSelect = "max(token_name) as name"
+		case CostGroupByUser:
+			nameSelect = "max(username) as name"
+		}
+	}
+	var rows []struct {
+		Hour             int64
+		StatKey          string
+		Name             string
+		Count            int64
+		PromptTokens     int64
+		CompletionTokens int64
+		Quota            int64
+	}
+	err = tx.Select(timeColumn + " as hour, " + keySelect + ", " + nameSelect + ", " + countSelect + " as count, " +
+		"sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(quota) as quota").
+		Group(group).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	points := make(map[string]*UsageSeriesPoint)
+	for _, row := range rows {
+		bucket := row.Hour
+		if interval == UsageSeriesIntervalDay {
+			t := time.Unix(row.Hour, 0)
+			bucket = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local).Unix()
+		}
+		key := strconv.FormatInt(bucket, 10) + "-" + row.StatKey
+		point, ok := points[key]
+		if !ok {
+			point = &UsageSeriesPoint{Time: bucket, Key: row.StatKey, Name: row.Name}
+			points[key] = point
+		}
+		point.Count += row.Count
+		point.PromptTokens += row.PromptTokens
+		point.CompletionTokens += row.CompletionTokens
+		point.TotalTokens += row.PromptTokens + row.CompletionTokens
+		point.Quota += row.Quota
+	}
+
+	result := make([]*UsageSeriesPoint, 0, len(points))
+	for _, point := range points {
+		result = append(result, point)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Time != result[j].Time {
+			return result[i].Time < result[j].Time
+		}
+		return result[i].Key < result[j].Key
+	})
+	setCachedUsageSeries(cacheKey, result)
+	return result, nil
+}
@@ -32,6 +32,8 @@ func UpdateQuotaData() {
 			common.SysLog("正在更新数据看板数据...")
 			SaveQuotaDataCache()
 		}
+		// 费用分析与用量序列接口依赖该统计表，始终写入
+		SaveCostStatCache()
 		time.Sleep(time.Duration(common.DataExportInterval) * time.Minute)
 	}
 }
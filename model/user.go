@@ -25,6 +25,7 @@ type User struct {
 	Email            string         `json:"email" gorm:"index" validate:"max=50"`
 	GitHubId         string         `json:"github_id" gorm:"column:github_id;index"`
 	OidcId           string         `json:"oidc_id" gorm:"column:oidc_id;index"`
+	LdapId           string         `json:"ldap_id" gorm:"column:ldap_id;index"`
 	WeChatId         string         `json:"wechat_id" gorm:"column:wechat_id;index"`
 	TelegramId       string         `json:"telegram_id" gorm:"column:telegram_id;index"`
 	VerificationCode string         `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
@@ -41,6 +42,8 @@ type User struct {
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
 	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
+	OrganizationId   int            `json:"organization_id" gorm:"type:int;default:0;index"`
+	OrganizationRole int            `json:"organization_role" gorm:"type:int;default:0"` // 组织内角色，见 OrganizationRole*
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -147,6 +150,12 @@ func GetAllUsers(startIdx int, num int) (users []*User, total int64, err error)
 	return users, total, nil
 }
 
+// GetUsersWithOutstandingBalance 获取余额为负（后付费透支）的用户，按欠款从多到少排序
+func GetUsersWithOutstandingBalance() (users []*User, err error) {
+	err = DB.Where("quota < 0").Order("quota asc").Omit("password").Find(&users).Error
+	return users, err
+}
+
 func SearchUsers(keyword string, group string, startIdx int, num int) ([]*User, int64, error) {
 	var users []*User
 	var total int64
@@ -254,14 +263,12 @@ func HardDeleteUserById(id int) error {
 }
 
 func inviteUser(inviterId int) (err error) {
-	user, err := GetUserById(inviterId, true)
-	if err != nil {
-		return err
-	}
-	user.AffCount++
-	user.AffQuota += common.QuotaForInviter
-	user.AffHistoryQuota += common.QuotaForInviter
-	return DB.Save(user).Error
+	// 只更新邀请相关字段，避免整行保存覆盖期间发生的额度变化
+	return DB.Model(&User{}).Where("id = ?", inviterId).Updates(map[string]interface{}{
+		"aff_count":   gorm.Expr("aff_count + ?", 1),
+		"aff_quota":   gorm.Expr("aff_quota + ?", common.QuotaForInviter),
+		"aff_history": gorm.Expr("aff_history + ?", common.QuotaForInviter),
+	}).Error
 }
 
 func (user *User) TransferAffQuotaToQuota(quota int) error {
@@ -270,35 +277,22 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 		return fmt.Errorf("转移额度最小为%s！", common.LogQuota(int(common.QuotaPerUnit)))
 	}
 
-	// 开始数据库事务
-	tx := DB.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-	defer tx.Rollback() // 确保在函数退出时事务能回滚
-
-	// 加锁查询用户以确保数据一致性
-	err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, user.Id).Error
-	if err != nil {
-		return err
+	// 以条件更新原子地完成检查与转移，不读取后整行保存
+	result := DB.Model(&User{}).Where("id = ? and aff_quota >= ?", user.Id, quota).Updates(map[string]interface{}{
+		"aff_quota": gorm.Expr("aff_quota - ?", quota),
+		"quota":     gorm.Expr("quota + ?", quota),
+	})
+	if result.Error != nil {
+		return result.Error
 	}
-
-	// 再次检查用户的AffQuota是否足够
-	if user.AffQuota < quota {
+	if result.RowsAffected == 0 {
 		return errors.New("邀请额度不足！")
 	}
-
-	// 更新用户额度
-	user.AffQuota -= quota
-	user.Quota += quota
-
-	// 保存用户状态
-	if err := tx.Save(user).Error; err != nil {
-		return err
+	// 同时清除用户缓存与 Redis 额度计数器
+	if err := invalidateUserCache(user.Id); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
 	}
-
-	// 提交事务
-	return tx.Commit().Error
+	return nil
 }
 
 func (user *User) Insert(inviterId int) error {
@@ -351,6 +345,14 @@ func (user *User) Update(updatePassword bool) error {
 	return updateUserCache(*user)
 }
 
+// UpdateSyncedFields 只更新外部身份源同步的字段，避免用读取时的旧数据覆盖额度等字段
+func (user *User) UpdateSyncedFields() error {
+	if err := DB.Model(user).Select("role", "group", "display_name", "email", "status").Updates(user).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(user.Id)
+}
+
 func (user *User) Edit(updatePassword bool) error {
 	var err error
 	if updatePassword {
@@ -375,6 +377,7 @@ func (user *User) Edit(updatePassword bool) error {
 	if err = DB.Model(user).Updates(updates).Error; err != nil {
 		return err
 	}
+	invalidateUserQuotaCounter(user.Id)
 
 	// Update cache
 	return updateUserCache(*user)
@@ -451,6 +454,14 @@ func (user *User) FillUserByOidcId() error {
 	return nil
 }
 
+func (user *User) FillUserByLdapId() error {
+	if user.LdapId == "" {
+		return errors.New("ldap id 为空！")
+	}
+	DB.Where(User{LdapId: user.LdapId}).First(user)
+	return nil
+}
+
 func (user *User) FillUserByWeChatId() error {
 	if user.WeChatId == "" {
 		return errors.New("WeChat id 为空！")
@@ -486,6 +497,17 @@ func IsOidcIdAlreadyTaken(oidcId string) bool {
 	return DB.Where("oidc_id = ?", oidcId).Find(&User{}).RowsAffected == 1
 }
 
+func IsLdapIdAlreadyTaken(ldapId string) bool {
+	return DB.Where("ldap_id = ?", ldapId).Find(&User{}).RowsAffected == 1
+}
+
+// GetLdapUsers 返回所有绑定了 LDAP 账户的用户，用于定期同步
+func GetLdapUsers() ([]*User, error) {
+	var users []*User
+	err := DB.Where("ldap_id <> ''").Find(&users).Error
+	return users, err
+}
+
 func IsTelegramIdAlreadyTaken(telegramId string) bool {
 	return DB.Unscoped().Where("telegram_id = ?", telegramId).Find(&User{}).RowsAffected == 1
 }
@@ -569,6 +591,13 @@ func GetUserQuota(id int, fromDB bool) (quota int, err error) {
 			})
 		}
 	}()
+	if !fromDB && redisQuotaCounterEnabled() {
+		quota, err := redisGetUserQuota(id)
+		if err == nil {
+			return quota, nil
+		}
+		common.SysError("failed to get user quota counter: " + err.Error())
+	}
 	if !fromDB && common.RedisEnabled {
 		quota, err := getUserQuotaCache(id)
 		if err == nil {
@@ -662,11 +691,21 @@ func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 			common.SysError("failed to increase user quota: " + err.Error())
 		}
 	})
+	if !db && redisQuotaCounterEnabled() {
+		if err = redisDeltaUserQuota(id, quota); err == nil {
+			return nil
+		}
+		common.SysError("failed to increase user quota counter: " + err.Error())
+	}
 	if !db && common.BatchUpdateEnabled {
 		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
 		return nil
 	}
-	return increaseUserQuota(id, quota)
+	err = increaseUserQuota(id, quota)
+	if err == nil && db {
+		invalidateUserQuotaCounter(id)
+	}
+	return err
 }
 
 func increaseUserQuota(id int, quota int) (err error) {
@@ -687,6 +726,12 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 			common.SysError("failed to decrease user quota: " + err.Error())
 		}
 	})
+	if redisQuotaCounterEnabled() {
+		if err = redisDeltaUserQuota(id, -quota); err == nil {
+			return nil
+		}
+		common.SysError("failed to decrease user quota counter: " + err.Error())
+	}
 	if common.BatchUpdateEnabled {
 		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
 		return nil
@@ -818,3 +863,10 @@ func RootUserExists() bool {
 	}
 	return true
 }
+
+// CountGroupUsers 统计分组下的用户数
+func CountGroupUsers(group string) (int64, error) {
+	var count int64
+	err := DB.Model(&User{}).Where(groupCol+" = ?", group).Count(&count).Error
+	return count, err
+}
@@ -57,6 +57,7 @@ func invalidateUserCache(userId int) error {
 	if !common.RedisEnabled {
 		return nil
 	}
+	invalidateUserQuotaCounter(userId)
 	return common.RedisHDelObj(getUserCacheKey(userId))
 }
 
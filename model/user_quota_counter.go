@@ -0,0 +1,134 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+// 启用 Redis 额度计数后，用户余额的扣减与读取走 Redis 原子计数器，变化量累计在待同步哈希中，
+// 由后台定时与退出时写回数据库；计数器 = 数据库余额 + 待同步变化量，过期或被清除后按此重建，数据库仍为权威数据
+const (
+	userQuotaPendingKey     = "user_quota_pending"
+	userQuotaCounterTimeout = time.Hour
+)
+
+func getUserQuotaCounterKey(userId int) string {
+	return fmt.Sprintf("user_quota_counter:%d", userId)
+}
+
+// 先记录待同步变化量，计数器存在时再同步调整，避免计数器过期后从 0 开始累加
+var userQuotaDeltaScript = redis.NewScript(`
+redis.call('HINCRBY', KEYS[2], ARGV[1], ARGV[2])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+    return redis.call('INCRBY', KEYS[1], ARGV[2])
+end
+return false
+`)
+
+// 原子地取出并清除某个用户的待同步变化量
+var userQuotaTakePendingScript = redis.NewScript(`
+local delta = redis.call('HGET', KEYS[1], ARGV[1])
+if delta then
+    redis.call('HDEL', KEYS[1], ARGV[1])
+    return tonumber(delta)
+end
+return 0
+`)
+
+func redisQuotaCounterEnabled() bool {
+	return common.RedisQuotaEnabled && common.RedisEnabled
+}
+
+func redisDeltaUserQuota(userId int, delta int) error {
+	err := userQuotaDeltaScript.Run(context.Background(), common.RDB,
+		[]string{getUserQuotaCounterKey(userId), userQuotaPendingKey}, userId, delta).Err()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+func redisGetUserQuota(userId int) (int, error) {
+	ctx := context.Background()
+	key := getUserQuotaCounterKey(userId)
+	quota, err := common.RDB.Get(ctx, key).Int()
+	if err == nil {
+		return quota, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	err = DB.Model(&User{}).Where("id = ?", userId).Select("quota").Find(&quota).Error
+	if err != nil {
+		return 0, err
+	}
+	pending, err := common.RDB.HGet(ctx, userQuotaPendingKey, strconv.Itoa(userId)).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	quota += pending
+	// 并发重建时以先写入者为准
+	if err = common.RDB.SetNX(ctx, key, quota, userQuotaCounterTimeout).Err(); err != nil {
+		return 0, err
+	}
+	return common.RDB.Get(ctx, key).Int()
+}
+
+// invalidateUserQuotaCounter 数据库余额被直接修改后清除计数器，下次读取时重建
+func invalidateUserQuotaCounter(userId int) {
+	if !redisQuotaCounterEnabled() {
+		return
+	}
+	if err := common.RedisDel(getUserQuotaCounterKey(userId)); err != nil {
+		common.SysError("failed to invalidate user quota counter: " + err.Error())
+	}
+}
+
+// SyncUserQuotaCounters 将 Redis 中累计的余额变化量写回数据库，写入失败时把变化量放回待同步哈希
+func SyncUserQuotaCounters() {
+	if !redisQuotaCounterEnabled() {
+		return
+	}
+	ctx := context.Background()
+	ids, err := common.RDB.HKeys(ctx, userQuotaPendingKey).Result()
+	if err != nil {
+		common.SysError("failed to get pending user quota: " + err.Error())
+		return
+	}
+	for _, idStr := range ids {
+		userId, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		delta, err := userQuotaTakePendingScript.Run(ctx, common.RDB, []string{userQuotaPendingKey}, idStr).Int()
+		if err != nil {
+			common.SysError("failed to take pending user quota: " + err.Error())
+			continue
+		}
+		if delta == 0 {
+			continue
+		}
+		if err = increaseUserQuota(userId, delta); err != nil {
+			common.SysError(fmt.Sprintf("failed to sync user %d quota: %s", userId, err.Error()))
+			if err := common.RDB.HIncrBy(ctx, userQuotaPendingKey, idStr, int64(delta)).Err(); err != nil {
+				common.SysError(fmt.Sprintf("failed to restore user %d pending quota %d: %s", userId, delta, err.Error()))
+			}
+		}
+	}
+}
+
+func InitUserQuotaSyncer() {
+	gopool.Go(func() {
+		for {
+			time.Sleep(time.Duration(common.RedisQuotaSyncInterval) * time.Second)
+			SyncUserQuotaCounters()
+		}
+	})
+}
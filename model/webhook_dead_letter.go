@@ -0,0 +1,47 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+// WebhookDeadLetter 重试耗尽仍投递失败的 webhook 事件，可由管理员手动重发
+type WebhookDeadLetter struct {
+	Id        int    `json:"id"`
+	EventId   string `json:"event_id" gorm:"type:varchar(64);index"`
+	EventType string `json:"event_type" gorm:"type:varchar(64);index"`
+	Url       string `json:"url" gorm:"type:varchar(1024)"`
+	Payload   string `json:"payload" gorm:"type:text"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func RecordWebhookDeadLetter(deadLetter *WebhookDeadLetter) error {
+	if deadLetter.CreatedAt == 0 {
+		deadLetter.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(deadLetter).Error
+}
+
+func GetWebhookDeadLetters(startIdx int, num int) (deadLetters []*WebhookDeadLetter, total int64, err error) {
+	err = DB.Model(&WebhookDeadLetter{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&deadLetters).Error
+	return deadLetters, total, err
+}
+
+func GetWebhookDeadLetterById(id int) (*WebhookDeadLetter, error) {
+	deadLetter := &WebhookDeadLetter{}
+	err := DB.First(deadLetter, "id = ?", id).Error
+	return deadLetter, err
+}
+
+func DeleteWebhookDeadLetterById(id int) error {
+	return DB.Delete(&WebhookDeadLetter{}, "id = ?", id).Error
+}
+
+func (deadLetter *WebhookDeadLetter) Update() error {
+	return DB.Model(deadLetter).Select("attempts", "last_error").Updates(deadLetter).Error
+}
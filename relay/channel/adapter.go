@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"one-api/dto"
@@ -45,3 +46,15 @@ type TaskAdaptor interface {
 	// FetchTask
 	FetchTask(baseUrl, key string, body map[string]any) (*http.Response, error)
 }
+
+// AsyncTaskAdaptor 通用异步任务的上游适配器：提交任务得到上游任务 ID，之后由后台按上游任务 ID 轮询状态
+type AsyncTaskAdaptor interface {
+	SubmitAsyncTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.AsyncTaskRequest) (upstreamTaskId string, taskErr *dto.TaskError)
+	// FetchAsyncTask 返回的 Status 需归一化为 queued、in_progress、succeeded 或 failed
+	FetchAsyncTask(baseUrl string, key string, upstreamTaskId string) (*dto.AsyncTaskResponse, error)
+}
+
+// VideoContentAdaptor 视频文件需要携带渠道密钥才能下载的上游（如 Sora）额外实现，由网关代理下载
+type VideoContentAdaptor interface {
+	FetchVideoContent(ctx context.Context, baseUrl string, key string, upstreamTaskId string) (*http.Response, error)
+}
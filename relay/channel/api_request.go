@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -8,9 +9,17 @@ import (
 	"io"
 	"net/http"
 	common2 "one-api/common"
+	constant2 "one-api/constant"
+	"one-api/dto"
 	"one-api/relay/common"
 	"one-api/relay/constant"
+	"one-api/relay/helper"
 	"one-api/service"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Header) {
@@ -27,6 +36,27 @@ func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Hea
 	}
 }
 
+// applyChannelHeaders 在适配器设置请求头之后注入请求 ID 与渠道配置的请求头，支持 {{user_id}} 等模板变量；
+// 渠道将 X-Request-Id 配置为空值即可不向上游传递请求 ID
+func applyChannelHeaders(c *gin.Context, header *http.Header, info *common.RelayInfo) {
+	if requestId := c.GetString(common2.RequestIdKey); requestId != "" && header.Get(common2.RequestIdHeader) == "" {
+		header.Set(common2.RequestIdHeader, requestId)
+	}
+	headers, ok := info.ChannelSetting[constant2.ChannelSettingHeaders].(map[string]interface{})
+	if !ok || len(headers) == 0 {
+		return
+	}
+	variables := info.TemplateVariables(c)
+	for name, value := range headers {
+		v, _ := value.(string)
+		if v == "" {
+			header.Del(name)
+			continue
+		}
+		header.Set(name, common.RenderTemplate(v, variables))
+	}
+}
+
 func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	fullRequestURL, err := a.GetRequestURL(info)
 	if err != nil {
@@ -43,6 +73,7 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelHeaders(c, &req.Header, info)
 	resp, err := doRequest(c, req, info)
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
@@ -66,6 +97,7 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelHeaders(c, &req.Header, info)
 	resp, err := doRequest(c, req, info)
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
@@ -83,8 +115,13 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelHeaders(c, &targetHeader, info)
 	targetHeader.Set("Content-Type", c.Request.Header.Get("Content-Type"))
-	targetConn, _, err := websocket.DefaultDialer.Dial(fullRequestURL, targetHeader)
+	dialer, err := service.GetChannelWebsocketDialer(service.ChannelHttpClientOptions(info))
+	if err != nil {
+		return nil, fmt.Errorf("new websocket dialer failed: %w", err)
+	}
+	targetConn, _, err := dialer.Dial(fullRequestURL, targetHeader)
 	if err != nil {
 		return nil, fmt.Errorf("dial failed to %s: %w", fullRequestURL, err)
 	}
@@ -95,23 +132,36 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 }
 
 func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
-	var client *http.Client
-	var err error
-	if proxyURL, ok := info.ChannelSetting["proxy"]; ok {
-		client, err = service.NewProxyHttpClient(proxyURL.(string))
-		if err != nil {
-			return nil, fmt.Errorf("new proxy http client failed: %w", err)
-		}
-	} else {
-		client = service.GetHttpClient()
-	}
+	client, err := service.GetChannelHttpClient(service.ChannelHttpClientOptions(info))
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	ctx, span := common2.StartSpan(c.Request.Context(), "upstream "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.path", req.URL.Path),
+			attribute.Int("channel.id", info.ChannelId),
+		))
+	defer span.End()
+	// 仅透传链路头，不绑定客户端请求的上下文，避免改变上游请求的取消行为
+	common2.InjectTraceHeaders(ctx, req.Header)
+	service.SetUpstreamAcceptEncoding(req.Header)
+	stopKeepAlive := helper.StartResponseKeepAlive(c, info)
+	upstreamStart := time.Now()
 	resp, err := client.Do(req)
+	stopKeepAlive()
+	c.Set(constant2.ContextKeyUpstreamLatency, time.Since(upstreamStart))
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	service.DecompressUpstreamResponse(resp)
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
 	return resp, nil
@@ -134,9 +184,47 @@ func DoTaskApiRequest(a TaskAdaptor, c *gin.Context, info *common.TaskRelayInfo,
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelHeaders(c, &req.Header, info.RelayInfo)
 	resp, err := doRequest(c, req, info.RelayInfo)
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
 	return resp, nil
 }
+
+// DoAsyncTaskRequest 提交通用异步任务（含视频生成），附加渠道自定义请求头后发送，返回上游响应体；非 2xx 响应作为上游错误返回
+func DoAsyncTaskRequest(c *gin.Context, info *common.RelayInfo, req *http.Request) ([]byte, *dto.TaskError) {
+	applyChannelHeaders(c, &req.Header, info)
+	resp, err := doRequest(c, req, info)
+	if err != nil {
+		return nil, service.TaskErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, service.TaskErrorWrapper(fmt.Errorf("%s", string(responseBody)), "fail_to_submit_task", resp.StatusCode)
+	}
+	return responseBody, nil
+}
+
+// DoAsyncTaskFetch 查询上游异步任务状态，后台轮询没有请求上下文，单次查询最多等待 15 秒
+func DoAsyncTaskFetch(req *http.Request) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	resp, err := service.GetHttpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch task status code: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+	return responseBody, nil
+}
@@ -22,6 +22,7 @@ type Adaptor struct {
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	claude.ApplyPromptCache(request)
 	c.Set("request_model", request.Model)
 	c.Set("converted_request", request)
 	return request, nil
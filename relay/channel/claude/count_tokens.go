@@ -0,0 +1,49 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countTokensRequest count_tokens 接口只接受以下字段，max_tokens、stream 等采样参数需去掉
+type countTokensRequest struct {
+	Model      string              `json:"model"`
+	Messages   []dto.ClaudeMessage `json:"messages"`
+	System     any                 `json:"system,omitempty"`
+	Tools      any                 `json:"tools,omitempty"`
+	ToolChoice any                 `json:"tool_choice,omitempty"`
+	Thinking   *dto.Thinking       `json:"thinking,omitempty"`
+}
+
+// countTokensAdaptor 复用 Adaptor 的请求头，请求地址改为 /v1/messages/count_tokens
+type countTokensAdaptor struct {
+	Adaptor
+}
+
+func (a *countTokensAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return fmt.Sprintf("%s/v1/messages/count_tokens", info.BaseUrl), nil
+}
+
+// DoCountTokensRequest 调用 Anthropic 官方 count_tokens 接口
+// https://docs.anthropic.com/en/api/messages-count-tokens
+func DoCountTokensRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (*http.Response, error) {
+	body, err := json.Marshal(countTokensRequest{
+		Model:      info.UpstreamModelName,
+		Messages:   request.Messages,
+		System:     request.System,
+		Tools:      request.Tools,
+		ToolChoice: request.ToolChoice,
+		Thinking:   request.Thinking,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return channel.DoApiRequest(&countTokensAdaptor{}, c, info, bytes.NewReader(body))
+}
@@ -0,0 +1,59 @@
+package claude
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"one-api/dto"
+	"one-api/service"
+	"strings"
+)
+
+// documentOpenAI2Claude 将 OpenAI 的 file 内容块转换为 Claude 的 document 块：
+// PDF 使用 base64 来源，文本类文件使用 text 来源，远程地址使用 url 来源
+func documentOpenAI2Claude(file *dto.MessageFile) (*dto.ClaudeMediaMessage, error) {
+	if file == nil {
+		return nil, errors.New("file content is empty")
+	}
+	if file.FileId != "" {
+		return nil, errors.New("file_id is not supported in claude, please use file_data")
+	}
+	if service.IsRemoteFile(file.FileData) {
+		return &dto.ClaudeMediaMessage{
+			Type: "document",
+			Source: &dto.ClaudeMessageSource{
+				Type: "url",
+				Url:  file.FileData,
+			},
+		}, nil
+	}
+	mimeType, base64Data, err := service.DecodeFileData(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case mimeType == "application/pdf":
+		return &dto.ClaudeMediaMessage{
+			Type: "document",
+			Source: &dto.ClaudeMessageSource{
+				Type:      "base64",
+				MediaType: mimeType,
+				Data:      base64Data,
+			},
+		}, nil
+	case strings.HasPrefix(mimeType, "text/"):
+		text, err := base64.StdEncoding.DecodeString(base64Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 file data: %s", err.Error())
+		}
+		return &dto.ClaudeMediaMessage{
+			Type: "document",
+			Source: &dto.ClaudeMessageSource{
+				Type:      "text",
+				MediaType: "text/plain",
+				Data:      string(text),
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported file type in claude: %s", mimeType)
+}
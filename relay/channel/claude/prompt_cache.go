@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"strings"
+)
+
+var ephemeralCacheControl = map[string]string{"type": "ephemeral"}
+
+// cacheableSystemBlocks system 消息中的文本块带有 cache_control 时，保留分块与断点，否则返回 false 沿用拼接后的字符串
+func cacheableSystemBlocks(contents []dto.MediaContent) ([]dto.ClaudeMediaMessage, bool) {
+	blocks := make([]dto.ClaudeMediaMessage, 0, len(contents))
+	hasCacheControl := false
+	for _, content := range contents {
+		if content.Type != dto.ContentTypeText {
+			continue
+		}
+		blocks = append(blocks, dto.ClaudeMediaMessage{
+			Type:         "text",
+			Text:         &content.Text,
+			CacheControl: content.CacheControl,
+		})
+		if content.CacheControl != nil {
+			hasCacheControl = true
+		}
+	}
+	return blocks, hasCacheControl
+}
+
+func hasPromptCacheControl(request *dto.ClaudeRequest) bool {
+	for _, block := range request.ParseSystem() {
+		if block.CacheControl != nil {
+			return true
+		}
+	}
+	switch tools := request.Tools.(type) {
+	case []dto.Tool:
+		for _, tool := range tools {
+			if tool.CacheControl != nil {
+				return true
+			}
+		}
+	case []any:
+		for _, tool := range tools {
+			if toolMap, ok := tool.(map[string]any); ok && toolMap["cache_control"] != nil {
+				return true
+			}
+		}
+	}
+	for _, message := range request.Messages {
+		contents, _ := message.ParseContent()
+		for _, content := range contents {
+			if content.CacheControl != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stablePrefixText 返回 tools 与 system 的文本，用于估算可缓存前缀的 token 数
+func stablePrefixText(request *dto.ClaudeRequest) string {
+	var text strings.Builder
+	if request.Tools != nil {
+		toolsJson, _ := json.Marshal(request.Tools)
+		text.Write(toolsJson)
+	}
+	if request.IsStringSystem() {
+		text.WriteString(request.GetStringSystem())
+	} else {
+		for _, block := range request.ParseSystem() {
+			text.WriteString(block.GetText())
+		}
+	}
+	return text.String()
+}
+
+// ApplyPromptCache 开启自动缓存且请求未声明任何 cache_control 时，在 tools + system 这段稳定前缀达到阈值后插入缓存断点：
+// 有 system 时断点放在 system 的最后一块（前缀按 tools、system、messages 的顺序计算，同时覆盖 tools），否则放在最后一个工具
+func ApplyPromptCache(request *dto.ClaudeRequest) {
+	settings := model_setting.GetClaudeSettings()
+	if !settings.PromptCacheAutoEnabled || hasPromptCacheControl(request) {
+		return
+	}
+	prefix := stablePrefixText(request)
+	if prefix == "" {
+		return
+	}
+	tokens, err := service.CountTextToken(prefix, request.Model)
+	if err != nil || tokens < settings.PromptCacheMinTokens {
+		return
+	}
+	if request.IsStringSystem() && request.GetStringSystem() != "" {
+		request.System = []dto.ClaudeMediaMessage{{
+			Type:         "text",
+			Text:         common.GetPointer[string](request.GetStringSystem()),
+			CacheControl: ephemeralCacheControl,
+		}}
+		return
+	}
+	if blocks := request.ParseSystem(); len(blocks) > 0 {
+		blocks[len(blocks)-1].CacheControl = ephemeralCacheControl
+		request.System = blocks
+		return
+	}
+	switch tools := request.Tools.(type) {
+	case []dto.Tool:
+		if len(tools) > 0 {
+			tools[len(tools)-1].CacheControl = ephemeralCacheControl
+		}
+	case []any:
+		if len(tools) > 0 {
+			if toolMap, ok := tools[len(tools)-1].(map[string]any); ok {
+				toolMap["cache_control"] = ephemeralCacheControl
+			}
+		}
+	}
+}
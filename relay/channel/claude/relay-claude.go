@@ -16,6 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Claude 单张图片最大 5MB
+const claudeMaxImageSizeMB = 5
+
 func stopReasonClaude2OpenAI(reason string) string {
 	switch reason {
 	case "stop_sequence":
@@ -122,6 +125,15 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 		claudeRequest.Model = strings.TrimSuffix(textRequest.Model, "-thinking")
 	}
 
+	if len(claudeTools) > 0 {
+		claudeRequest.ToolChoice = toolChoiceOpenAI2Claude(textRequest.ToolChoice, textRequest.ParallelToolCalls)
+	}
+
+	if tool, ok := structuredOutputTool(textRequest, &claudeRequest); ok {
+		claudeRequest.Tools = []dto.Tool{tool}
+		claudeRequest.ToolChoice = map[string]any{"type": "tool", "name": structuredOutputToolName}
+	}
+
 	if textRequest.Stop != nil {
 		// stop maybe string/array string, convert to array string
 		switch textRequest.Stop.(type) {
@@ -184,6 +196,9 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 					}
 				}
 				claudeRequest.System = content
+				if systemBlocks, ok := cacheableSystemBlocks(contents); ok {
+					claudeRequest.System = systemBlocks
+				}
 			}
 		} else {
 			if isFirstMessage {
@@ -243,6 +258,12 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 					}
 					if mediaMessage.Type == "text" {
 						claudeMediaMessage.Text = common.GetPointer[string](mediaMessage.Text)
+					} else if mediaMessage.Type == dto.ContentTypeFile {
+						documentMessage, err := documentOpenAI2Claude(mediaMessage.GetFile())
+						if err != nil {
+							return nil, err
+						}
+						claudeMediaMessage = *documentMessage
 					} else {
 						imageUrl := mediaMessage.GetImageMedia()
 						claudeMediaMessage.Type = "image"
@@ -252,9 +273,9 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 						// 判断是否是url
 						if strings.HasPrefix(imageUrl.Url, "http") {
 							// 是url，获取图片的类型和base64编码的数据
-							fileData, err := service.GetFileBase64FromUrl(imageUrl.Url)
+							fileData, err := service.GetInlineImageFromUrl(imageUrl.Url, claudeMaxImageSizeMB)
 							if err != nil {
-								return nil, fmt.Errorf("get file base64 from url failed: %s", err.Error())
+								return nil, err
 							}
 							claudeMediaMessage.Source.MediaType = fileData.MimeType
 							claudeMediaMessage.Source.Data = fileData.Base64Data
@@ -267,6 +288,7 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 							claudeMediaMessage.Source.Data = base64String
 						}
 					}
+					claudeMediaMessage.CacheControl = mediaMessage.CacheControl
 					claudeMediaMessages = append(claudeMediaMessages, claudeMediaMessage)
 				}
 				if message.ToolCalls != nil {
@@ -291,6 +313,7 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 	}
 	claudeRequest.Prompt = ""
 	claudeRequest.Messages = claudeMessages
+	ApplyPromptCache(&claudeRequest)
 	return &claudeRequest, nil
 }
 
@@ -395,6 +418,7 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto
 	}
 	tools := make([]dto.ToolCallResponse, 0)
 	thinkingContent := ""
+	structuredOutput := false
 
 	if reqMode == RequestModeCompletion {
 		content, _ := json.Marshal(strings.TrimPrefix(claudeResponse.Completion, " "))
@@ -414,6 +438,11 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto
 			switch message.Type {
 			case "tool_use":
 				args, _ := json.Marshal(message.Input)
+				if message.Name == structuredOutputToolName {
+					responseText = string(args)
+					structuredOutput = true
+					continue
+				}
 				tools = append(tools, dto.ToolCallResponse{
 					ID:   message.Id,
 					Type: "function", // compatible with other OpenAI derivative applications
@@ -437,6 +466,9 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto
 		},
 		FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
 	}
+	if structuredOutput && choice.FinishReason == "tool_calls" {
+		choice.FinishReason = "stop"
+	}
 	choice.SetStringContent(responseText)
 	if len(responseThinking) > 0 {
 		choice.ReasoningContent = responseThinking
@@ -457,6 +489,12 @@ type ClaudeResponseInfo struct {
 	Model        string
 	ResponseText strings.Builder
 	Usage        *dto.Usage
+	// WebSearchRequests 服务端 web search 工具调用次数，message_delta 中为累计值
+	WebSearchRequests int
+	// StructuredOutputIndex 结构化输出工具所在的内容块序号，流式响应中据此将参数增量转换为文本
+	StructuredOutputIndex *int
+	// ToolCallIndexes 工具调用所在的内容块序号 -> OpenAI 工具调用序号
+	ToolCallIndexes map[int]int
 }
 
 func FormatClaudeResponseInfo(requestMode int, claudeResponse *dto.ClaudeResponse, oaiResponse *dto.ChatCompletionsStreamResponse, claudeInfo *ClaudeResponseInfo) bool {
@@ -468,12 +506,17 @@ func FormatClaudeResponseInfo(requestMode int, claudeResponse *dto.ClaudeRespons
 			claudeInfo.ResponseId = claudeResponse.Message.Id
 			claudeInfo.Model = claudeResponse.Message.Model
 			claudeInfo.Usage.PromptTokens = claudeResponse.Message.Usage.InputTokens
+			claudeInfo.Usage.PromptTokensDetails.CachedTokens = claudeResponse.Message.Usage.CacheReadInputTokens
+			claudeInfo.Usage.PromptTokensDetails.CachedCreationTokens = claudeResponse.Message.Usage.CacheCreationInputTokens
 		} else if claudeResponse.Type == "content_block_delta" {
 			if claudeResponse.Delta.Text != nil {
 				claudeInfo.ResponseText.WriteString(*claudeResponse.Delta.Text)
 			}
 		} else if claudeResponse.Type == "message_delta" {
 			claudeInfo.Usage.CompletionTokens = claudeResponse.Usage.OutputTokens
+			if claudeResponse.Usage.ServerToolUse != nil {
+				claudeInfo.WebSearchRequests = claudeResponse.Usage.ServerToolUse.WebSearchRequests
+			}
 			if claudeResponse.Usage.InputTokens > 0 {
 				claudeInfo.Usage.PromptTokens = claudeResponse.Usage.InputTokens
 			}
@@ -528,15 +571,23 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 				}
 				claudeInfo.Usage.CompletionTokens = claudeResponse.Usage.OutputTokens
 				claudeInfo.Usage.TotalTokens = claudeInfo.Usage.PromptTokens + claudeInfo.Usage.CompletionTokens
+				if claudeResponse.Usage.ServerToolUse != nil {
+					claudeInfo.WebSearchRequests = claudeResponse.Usage.ServerToolUse.WebSearchRequests
+				}
 			}
 		}
 		helper.ClaudeChunkData(c, claudeResponse, data)
 	} else if info.RelayFormat == relaycommon.RelayFormatOpenAI {
 		response := StreamResponseClaude2OpenAI(requestMode, &claudeResponse)
+		adaptStructuredOutputStream(claudeInfo, &claudeResponse, response)
+		adaptToolCallStream(claudeInfo, &claudeResponse, response)
 
 		if !FormatClaudeResponseInfo(requestMode, &claudeResponse, response, claudeInfo) {
 			return nil
 		}
+		if response != nil {
+			response.SetSystemFingerprint(info.SystemFingerprint())
+		}
 
 		err = helper.ObjectData(c, response)
 		if err != nil {
@@ -546,7 +597,17 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 	return nil
 }
 
+// toOpenAIUsage Claude 的 input_tokens 不含缓存读取与写入的 token，转换为 OpenAI 格式时计入 prompt_tokens，
+// 与 OpenAI 的 prompt_tokens 包含 cached_tokens 的语义保持一致，计费时再按缓存倍率与缓存写入倍率分别扣减
+func toOpenAIUsage(usage *dto.Usage) {
+	usage.PromptTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+}
+
 func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, requestMode int) {
+	if claudeInfo.WebSearchRequests > 0 {
+		info.AddBuiltInToolCall(dto.BuildInToolClaudeWebSearch, claudeInfo.WebSearchRequests)
+	}
 	if info.RelayFormat == relaycommon.RelayFormatClaude {
 		if requestMode == RequestModeCompletion {
 			claudeInfo.Usage, _ = service.ResponseText2Usage(claudeInfo.ResponseText.String(), info.UpstreamModelName, info.PromptTokens)
@@ -556,7 +617,8 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 				//usage.PromptTokens = info.PromptTokens
 			}
 			if claudeInfo.Usage.CompletionTokens == 0 {
-				claudeInfo.Usage, _ = service.ResponseText2Usage(claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
+				// 保留上游已返回的 prompt 与缓存明细，仅补齐 completion
+				claudeInfo.Usage = service.MergeUsageEstimate(claudeInfo.Usage, claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
 			}
 		}
 	} else if info.RelayFormat == relaycommon.RelayFormatOpenAI {
@@ -567,11 +629,14 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 				//上游出错
 			}
 			if claudeInfo.Usage.CompletionTokens == 0 {
-				claudeInfo.Usage, _ = service.ResponseText2Usage(claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
+				// 保留上游已返回的 prompt 与缓存明细，仅补齐 completion
+				claudeInfo.Usage = service.MergeUsageEstimate(claudeInfo.Usage, claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
 			}
+			toOpenAIUsage(claudeInfo.Usage)
 		}
 		if info.ShouldIncludeUsage {
 			response := helper.GenerateFinalUsageResponse(claudeInfo.ResponseId, claudeInfo.Created, info.UpstreamModelName, *claudeInfo.Usage)
+			response.SetSystemFingerprint(info.SystemFingerprint())
 			err := helper.ObjectData(c, response)
 			if err != nil {
 				common.SysError("send final response failed: " + err.Error())
@@ -597,6 +662,9 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		}
 		return true
 	})
+	if openaiErr := service.StreamFailoverError(info); openaiErr != nil {
+		return openaiErr, nil
+	}
 	if err != nil {
 		return err, nil
 	}
@@ -635,11 +703,16 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		claudeInfo.Usage.TotalTokens = claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens
 		claudeInfo.Usage.PromptTokensDetails.CachedTokens = claudeResponse.Usage.CacheReadInputTokens
 		claudeInfo.Usage.PromptTokensDetails.CachedCreationTokens = claudeResponse.Usage.CacheCreationInputTokens
+		if claudeResponse.Usage.ServerToolUse != nil && claudeResponse.Usage.ServerToolUse.WebSearchRequests > 0 {
+			info.AddBuiltInToolCall(dto.BuildInToolClaudeWebSearch, claudeResponse.Usage.ServerToolUse.WebSearchRequests)
+		}
 	}
 	var responseData []byte
 	switch info.RelayFormat {
 	case relaycommon.RelayFormatOpenAI:
 		openaiResponse := ResponseClaude2OpenAI(requestMode, &claudeResponse)
+		openaiResponse.SystemFingerprint = info.SystemFingerprint()
+		toOpenAIUsage(claudeInfo.Usage)
 		openaiResponse.Usage = *claudeInfo.Usage
 		responseData, err = json.Marshal(openaiResponse)
 		if err != nil {
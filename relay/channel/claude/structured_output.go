@@ -0,0 +1,63 @@
+package claude
+
+import (
+	"one-api/dto"
+)
+
+// Claude 不支持 response_format，json_schema 请求通过强制调用该工具获得结构化输出，响应中再还原为文本内容
+const structuredOutputToolName = "structured_output"
+
+// structuredOutputTool 仅在请求携带 json_schema 且未声明其他工具时生效；开启 thinking 时 Claude 不允许强制工具调用，保持原样
+func structuredOutputTool(textRequest dto.GeneralOpenAIRequest, claudeRequest *dto.ClaudeRequest) (dto.Tool, bool) {
+	if textRequest.ResponseFormat == nil || textRequest.ResponseFormat.Type != "json_schema" || textRequest.ResponseFormat.JsonSchema == nil {
+		return dto.Tool{}, false
+	}
+	if len(textRequest.Tools) > 0 || claudeRequest.Thinking != nil {
+		return dto.Tool{}, false
+	}
+	schema, ok := textRequest.ResponseFormat.JsonSchema.Schema.(map[string]interface{})
+	if !ok {
+		return dto.Tool{}, false
+	}
+	description := textRequest.ResponseFormat.JsonSchema.Description
+	if description == "" {
+		description = "Respond with a JSON object that matches the schema."
+	}
+	return dto.Tool{
+		Name:        structuredOutputToolName,
+		Description: description,
+		InputSchema: schema,
+	}, true
+}
+
+// adaptStructuredOutputStream 将结构化输出工具的参数增量转换为文本增量，并把 tool_calls 结束原因还原为 stop
+func adaptStructuredOutputStream(claudeInfo *ClaudeResponseInfo, claudeResponse *dto.ClaudeResponse, response *dto.ChatCompletionsStreamResponse) {
+	if response == nil || len(response.Choices) == 0 {
+		return
+	}
+	choice := &response.Choices[0]
+	switch claudeResponse.Type {
+	case "content_block_start":
+		if claudeResponse.ContentBlock != nil && claudeResponse.ContentBlock.Type == "tool_use" &&
+			claudeResponse.ContentBlock.Name == structuredOutputToolName {
+			index := claudeResponse.GetIndex()
+			claudeInfo.StructuredOutputIndex = &index
+			choice.Delta.ToolCalls = nil
+			choice.Delta.SetContentString("")
+		}
+	case "content_block_delta":
+		if claudeInfo.StructuredOutputIndex == nil || claudeResponse.GetIndex() != *claudeInfo.StructuredOutputIndex {
+			return
+		}
+		if claudeResponse.Delta != nil && claudeResponse.Delta.PartialJson != nil {
+			choice.Delta.ToolCalls = nil
+			choice.Delta.SetContentString(*claudeResponse.Delta.PartialJson)
+			claudeInfo.ResponseText.WriteString(*claudeResponse.Delta.PartialJson)
+		}
+	case "message_delta":
+		if claudeInfo.StructuredOutputIndex != nil && choice.FinishReason != nil && *choice.FinishReason == "tool_calls" {
+			finishReason := "stop"
+			choice.FinishReason = &finishReason
+		}
+	}
+}
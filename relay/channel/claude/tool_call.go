@@ -0,0 +1,61 @@
+package claude
+
+import (
+	"one-api/dto"
+)
+
+// toolChoiceOpenAI2Claude 转换 tool_choice：auto/required/none 分别对应 auto/any/none，指定函数对应 tool；
+// parallel_tool_calls 为 false 时设置 disable_parallel_tool_use
+func toolChoiceOpenAI2Claude(toolChoice any, parallelToolCalls *bool) any {
+	var claudeToolChoice map[string]any
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			claudeToolChoice = map[string]any{"type": "auto"}
+		case "required":
+			claudeToolChoice = map[string]any{"type": "any"}
+		case "none":
+			claudeToolChoice = map[string]any{"type": "none"}
+		}
+	case map[string]any:
+		if function, ok := v["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				claudeToolChoice = map[string]any{"type": "tool", "name": name}
+			}
+		}
+	}
+	if parallelToolCalls != nil && !*parallelToolCalls {
+		if claudeToolChoice == nil {
+			claudeToolChoice = map[string]any{"type": "auto"}
+		}
+		if claudeToolChoice["type"] != "none" {
+			claudeToolChoice["disable_parallel_tool_use"] = true
+		}
+	}
+	if claudeToolChoice == nil {
+		return nil
+	}
+	return claudeToolChoice
+}
+
+// adaptToolCallStream Claude 的 index 为内容块序号（包含 text、thinking 块），转换为 OpenAI 的工具调用序号
+func adaptToolCallStream(claudeInfo *ClaudeResponseInfo, claudeResponse *dto.ClaudeResponse, response *dto.ChatCompletionsStreamResponse) {
+	if response == nil || len(response.Choices) == 0 || len(response.Choices[0].Delta.ToolCalls) == 0 {
+		return
+	}
+	blockIndex := claudeResponse.GetIndex()
+	if claudeResponse.Type == "content_block_start" {
+		if claudeInfo.ToolCallIndexes == nil {
+			claudeInfo.ToolCallIndexes = make(map[int]int)
+		}
+		claudeInfo.ToolCallIndexes[blockIndex] = len(claudeInfo.ToolCallIndexes)
+	}
+	toolCallIndex, ok := claudeInfo.ToolCallIndexes[blockIndex]
+	if !ok {
+		return
+	}
+	for i := range response.Choices[0].Delta.ToolCalls {
+		response.Choices[0].Delta.ToolCalls[i].SetIndex(toolCallIndex)
+	}
+}
@@ -9,6 +9,7 @@ type CohereRequest struct {
 	Stream      bool          `json:"stream"`
 	MaxTokens   int           `json:"max_tokens"`
 	SafetyMode  string        `json:"safety_mode,omitempty"`
+	Seed        int64         `json:"seed,omitempty"`
 }
 
 type ChatHistory struct {
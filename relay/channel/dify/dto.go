@@ -42,4 +42,15 @@ type DifyChunkChatCompletionResponse struct {
 	Answer         string       `json:"answer"`
 	Data           DifyData     `json:"data"`
 	MetaData       DifyMetaData `json:"metadata"`
+	// agent_thought 事件字段
+	Id          string `json:"id"`
+	Position    int    `json:"position"`
+	Thought     string `json:"thought"`
+	Observation string `json:"observation"`
+	Tool        string `json:"tool"`
+	ToolInput   string `json:"tool_input"`
+	// error 事件字段
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
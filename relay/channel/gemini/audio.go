@@ -0,0 +1,44 @@
+package gemini
+
+import (
+	"one-api/dto"
+	"strings"
+)
+
+// inputAudioOpenAI2Gemini input_audio 的 data 为不带前缀的 base64，format 为 wav、mp3 等
+func inputAudioOpenAI2Gemini(inputAudio *dto.MessageInputAudio) *GeminiInlineData {
+	data := inputAudio.Data
+	if idx := strings.Index(data, ","); strings.HasPrefix(data, "data:") && idx != -1 {
+		data = data[idx+1:]
+	}
+	format := strings.ToLower(inputAudio.Format)
+	if format == "mp3" {
+		format = "mpeg"
+	}
+	return &GeminiInlineData{
+		MimeType: "audio/" + format,
+		Data:     data,
+	}
+}
+
+func modalityTokens(details []GeminiModalityTokenCount, modality string) int {
+	for _, detail := range details {
+		if detail.Modality == modality {
+			return detail.TokenCount
+		}
+	}
+	return 0
+}
+
+// applyGeminiModalityUsage 上游按模态返回 token 明细且包含音频时，拆分文本与音频 token，以便按音频倍率计费
+func applyGeminiModalityUsage(usage *dto.Usage, metadata GeminiUsageMetadata) {
+	inputAudioTokens := modalityTokens(metadata.PromptTokensDetails, "AUDIO")
+	outputAudioTokens := modalityTokens(metadata.CandidatesTokensDetails, "AUDIO")
+	if inputAudioTokens == 0 && outputAudioTokens == 0 {
+		return
+	}
+	usage.PromptTokensDetails.AudioTokens = inputAudioTokens
+	usage.PromptTokensDetails.TextTokens = usage.PromptTokens - inputAudioTokens
+	usage.CompletionTokenDetails.AudioTokens = outputAudioTokens
+	usage.CompletionTokenDetails.TextTokens = usage.CompletionTokens - outputAudioTokens
+}
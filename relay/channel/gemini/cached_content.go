@@ -0,0 +1,80 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedContents 仅在 v1beta 提供
+// https://ai.google.dev/api/caching
+const cachedContentVersion = "v1beta"
+
+type CachedContentResponse struct {
+	Name          string `json:"name"`
+	DisplayName   string `json:"displayName"`
+	ExpireTime    string `json:"expireTime"`
+	UsageMetadata struct {
+		TotalTokenCount int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// GetExpireTime 返回过期时间的 Unix 时间戳，上游未返回时为 0
+func (r *CachedContentResponse) GetExpireTime() int64 {
+	expireTime, err := time.Parse(time.RFC3339Nano, r.ExpireTime)
+	if err != nil {
+		return 0
+	}
+	return expireTime.Unix()
+}
+
+// cachedContentAdaptor 复用 Adaptor 的请求头，请求地址改为 cachedContents
+type cachedContentAdaptor struct {
+	Adaptor
+}
+
+func (a *cachedContentAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return fmt.Sprintf("%s/%s/cachedContents", info.BaseUrl, cachedContentVersion), nil
+}
+
+// CreateCachedContent 在渠道上创建上下文缓存，请求体按 Gemini 格式透传，model 替换为映射后的上游模型
+func CreateCachedContent(c *gin.Context, info *relaycommon.RelayInfo, request map[string]interface{}) (*http.Response, error) {
+	request["model"] = "models/" + info.UpstreamModelName
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return channel.DoApiRequest(&cachedContentAdaptor{}, c, info, bytes.NewReader(body))
+}
+
+// DeleteCachedContent 删除渠道上的上下文缓存，缓存已不存在时视为成功
+func DeleteCachedContent(baseUrl string, key string, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	requestUrl := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(baseUrl, "/"), cachedContentVersion, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, requestUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", key)
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("delete cached content status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
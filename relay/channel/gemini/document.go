@@ -0,0 +1,75 @@
+package gemini
+
+import (
+	"fmt"
+	"one-api/dto"
+	"one-api/service"
+	"strings"
+)
+
+// isGeminiFileUri Gemini 可直接引用的公开视频地址。Files API 上传的文件与 Vertex AI 的 gs:// 对象属于渠道所在项目，
+// 网关不记录其上传者，放行会让用户引用同一渠道下其他用户的文件，因此一律拒绝
+func isGeminiFileUri(uri string) bool {
+	return strings.HasPrefix(uri, "https://www.youtube.com/") || strings.HasPrefix(uri, "https://youtu.be/")
+}
+
+// isGeminiProjectFileUri 是否为渠道项目内的文件引用
+func isGeminiProjectFileUri(uri string) bool {
+	return strings.HasPrefix(uri, "files/") || strings.HasPrefix(uri, "gs://") ||
+		strings.HasPrefix(uri, "https://generativelanguage.googleapis.com/")
+}
+
+// fileOpenAI2Gemini 将 OpenAI 的 file 内容块转换为 Gemini 的 part：公开视频地址使用 fileData，
+// 其余远程地址下载后与 base64 数据一样使用 inlineData，渠道项目内的文件引用直接拒绝
+func fileOpenAI2Gemini(file *dto.MessageFile) (*GeminiPart, error) {
+	if file == nil {
+		return nil, fmt.Errorf("file content is empty")
+	}
+	if isGeminiProjectFileUri(file.FileId) || isGeminiProjectFileUri(file.FileData) {
+		return nil, fmt.Errorf("referencing gemini files api or gs:// objects is not allowed, please send the file data instead")
+	}
+	uri := file.FileId
+	if uri == "" && isGeminiFileUri(file.FileData) {
+		uri = file.FileData
+	}
+	if uri != "" {
+		if !isGeminiFileUri(uri) {
+			return nil, fmt.Errorf("file_id %s is not a gemini file uri", file.FileId)
+		}
+		return &GeminiPart{
+			FileData: &GeminiFileData{
+				MimeType: service.GetFileMimeType(file.FileName),
+				FileUri:  uri,
+			},
+		}, nil
+	}
+	if service.IsRemoteFile(file.FileData) {
+		fileData, err := service.GetFileBase64FromUrl(file.FileData)
+		if err != nil {
+			return nil, fmt.Errorf("get file data from url failed: %s", err.Error())
+		}
+		if fileData.Size > geminiMaxInlineDataSizeMB*1024*1024 {
+			return nil, fmt.Errorf("file %s exceeds the %dMB inline data limit of gemini", file.FileData, geminiMaxInlineDataSizeMB)
+		}
+		mimeType, _, _ := strings.Cut(fileData.MimeType, ";")
+		if mimeType == "" || mimeType == "application/octet-stream" {
+			mimeType = service.GetFileMimeType(file.FileName)
+		}
+		return &GeminiPart{
+			InlineData: &GeminiInlineData{
+				MimeType: mimeType,
+				Data:     fileData.Base64Data,
+			},
+		}, nil
+	}
+	mimeType, base64Data, err := service.DecodeFileData(file)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64 file data failed: %s", err.Error())
+	}
+	return &GeminiPart{
+		InlineData: &GeminiInlineData{
+			MimeType: mimeType,
+			Data:     base64Data,
+		},
+	}, nil
+}
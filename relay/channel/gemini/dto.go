@@ -5,7 +5,18 @@ type GeminiChatRequest struct {
 	SafetySettings     []GeminiChatSafetySettings `json:"safety_settings,omitempty"`
 	GenerationConfig   GeminiChatGenerationConfig `json:"generation_config,omitempty"`
 	Tools              []GeminiChatTool           `json:"tools,omitempty"`
+	ToolConfig         *GeminiToolConfig          `json:"tool_config,omitempty"`
 	SystemInstructions *GeminiChatContent         `json:"system_instruction,omitempty"`
+	CachedContent      string                     `json:"cached_content,omitempty"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
 }
 
 type GeminiThinkingConfig struct {
@@ -91,6 +102,8 @@ type GeminiChatGenerationConfig struct {
 	Seed               int64                 `json:"seed,omitempty"`
 	ResponseModalities []string              `json:"responseModalities,omitempty"`
 	ThinkingConfig     *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
+	ResponseLogprobs   bool                  `json:"responseLogprobs,omitempty"`
+	Logprobs           int                   `json:"logprobs,omitempty"`
 }
 
 type GeminiChatCandidate struct {
@@ -98,6 +111,24 @@ type GeminiChatCandidate struct {
 	FinishReason  *string                  `json:"finishReason"`
 	Index         int64                    `json:"index"`
 	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings"`
+	// GroundingMetadata 使用 Google 搜索 grounding 时返回
+	GroundingMetadata any `json:"groundingMetadata,omitempty"`
+	// LogprobsResult 请求 responseLogprobs 时返回
+	LogprobsResult *GeminiLogprobsResult `json:"logprobsResult,omitempty"`
+}
+
+type GeminiLogprobsCandidate struct {
+	Token          string  `json:"token"`
+	LogProbability float64 `json:"logProbability"`
+}
+
+type GeminiLogprobsTopCandidates struct {
+	Candidates []GeminiLogprobsCandidate `json:"candidates"`
+}
+
+type GeminiLogprobsResult struct {
+	TopCandidates    []GeminiLogprobsTopCandidates `json:"topCandidates"`
+	ChosenCandidates []GeminiLogprobsCandidate     `json:"chosenCandidates"`
 }
 
 type GeminiChatSafetyRating struct {
@@ -107,6 +138,8 @@ type GeminiChatSafetyRating struct {
 
 type GeminiChatPromptFeedback struct {
 	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings"`
+	// GroundingMetadata 使用 Google 搜索 grounding 时返回
+	GroundingMetadata any `json:"groundingMetadata,omitempty"`
 }
 
 type GeminiChatResponse struct {
@@ -115,11 +148,19 @@ type GeminiChatResponse struct {
 	UsageMetadata  GeminiUsageMetadata      `json:"usageMetadata"`
 }
 
+type GeminiModalityTokenCount struct {
+	Modality   string `json:"modality"`
+	TokenCount int    `json:"tokenCount"`
+}
+
 type GeminiUsageMetadata struct {
-	PromptTokenCount     int `json:"promptTokenCount"`
-	CandidatesTokenCount int `json:"candidatesTokenCount"`
-	TotalTokenCount      int `json:"totalTokenCount"`
-	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	PromptTokenCount        int                        `json:"promptTokenCount"`
+	CandidatesTokenCount    int                        `json:"candidatesTokenCount"`
+	TotalTokenCount         int                        `json:"totalTokenCount"`
+	ThoughtsTokenCount      int                        `json:"thoughtsTokenCount"`
+	PromptTokensDetails     []GeminiModalityTokenCount `json:"promptTokensDetails,omitempty"`
+	CandidatesTokensDetails []GeminiModalityTokenCount `json:"candidatesTokensDetails,omitempty"`
+	CachedContentTokenCount int                        `json:"cachedContentTokenCount,omitempty"`
 }
 
 // Imagen related structs
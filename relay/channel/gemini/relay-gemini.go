@@ -18,8 +18,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Gemini 内联数据（inlineData）的请求体上限为 20MB
+const geminiMaxInlineDataSizeMB = 20
+
 // Setting safety to the lowest possible values since Gemini is already powerless enough
 func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) (*GeminiChatRequest, error) {
+	info.EmulateParallelToolCalls(&textRequest)
 
 	geminiRequest := GeminiChatRequest{
 		Contents: make([]GeminiChatContent, 0, len(textRequest.Messages)),
@@ -31,6 +35,11 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon
 		},
 	}
 
+	if logProbs, topLogProbs := textRequest.GetLogProbs(); logProbs {
+		geminiRequest.GenerationConfig.ResponseLogprobs = true
+		geminiRequest.GenerationConfig.Logprobs = topLogProbs
+	}
+
 	if model_setting.IsGeminiModelSupportImagine(info.UpstreamModelName) {
 		geminiRequest.GenerationConfig.ResponseModalities = []string{
 			"TEXT",
@@ -108,6 +117,7 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon
 			geminiRequest.Tools = append(geminiRequest.Tools, GeminiChatTool{
 				FunctionDeclarations: functions,
 			})
+			geminiRequest.ToolConfig = toolChoiceOpenAI2Gemini(textRequest.ToolChoice)
 		}
 		// common.SysLog("tools: " + fmt.Sprintf("%+v", geminiRequest.Tools))
 		// json_data, _ := json.Marshal(geminiRequest.Tools)
@@ -209,9 +219,9 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon
 				// 判断是否是url
 				if strings.HasPrefix(part.GetImageMedia().Url, "http") {
 					// 是url，获取图片的类型和base64编码的数据
-					fileData, err := service.GetFileBase64FromUrl(part.GetImageMedia().Url)
+					fileData, err := service.GetInlineImageFromUrl(part.GetImageMedia().Url, geminiMaxInlineDataSizeMB)
 					if err != nil {
-						return nil, fmt.Errorf("get file base64 from url failed: %s", err.Error())
+						return nil, err
 					}
 					parts = append(parts, GeminiPart{
 						InlineData: &GeminiInlineData{
@@ -232,32 +242,17 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon
 					})
 				}
 			} else if part.Type == dto.ContentTypeFile {
-				if part.GetFile().FileId != "" {
-					return nil, fmt.Errorf("only base64 file is supported in gemini")
-				}
-				format, base64String, err := service.DecodeBase64FileData(part.GetFile().FileData)
+				filePart, err := fileOpenAI2Gemini(part.GetFile())
 				if err != nil {
-					return nil, fmt.Errorf("decode base64 file data failed: %s", err.Error())
+					return nil, err
 				}
-				parts = append(parts, GeminiPart{
-					InlineData: &GeminiInlineData{
-						MimeType: format,
-						Data:     base64String,
-					},
-				})
+				parts = append(parts, *filePart)
 			} else if part.Type == dto.ContentTypeInputAudio {
 				if part.GetInputAudio().Data == "" {
 					return nil, fmt.Errorf("only base64 audio is supported in gemini")
 				}
-				format, base64String, err := service.DecodeBase64FileData(part.GetInputAudio().Data)
-				if err != nil {
-					return nil, fmt.Errorf("decode base64 audio data failed: %s", err.Error())
-				}
 				parts = append(parts, GeminiPart{
-					InlineData: &GeminiInlineData{
-						MimeType: format,
-						Data:     base64String,
-					},
+					InlineData: inputAudioOpenAI2Gemini(part.GetInputAudio()),
 				})
 			}
 		}
@@ -280,6 +275,7 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon
 			},
 		}
 	}
+	geminiRequest.CachedContent = dto.GeminiCachedContentName(textRequest.CachedContent)
 
 	return &geminiRequest, nil
 }
@@ -490,6 +486,33 @@ func unescapeMapOrSlice(data interface{}) interface{} {
 	return data
 }
 
+// logprobsGemini2OpenAI 将 logprobsResult 转换为 OpenAI 格式，topCandidates 与 chosenCandidates 按位置一一对应
+func logprobsGemini2OpenAI(result *GeminiLogprobsResult) *dto.ChoiceLogprobs {
+	if result == nil || len(result.ChosenCandidates) == 0 {
+		return nil
+	}
+	logprobs := &dto.ChoiceLogprobs{Content: make([]dto.LogprobContent, 0, len(result.ChosenCandidates))}
+	for i, chosen := range result.ChosenCandidates {
+		content := dto.LogprobContent{
+			Token:       chosen.Token,
+			Logprob:     chosen.LogProbability,
+			Bytes:       dto.TokenBytes(chosen.Token),
+			TopLogprobs: make([]dto.TopLogprob, 0),
+		}
+		if i < len(result.TopCandidates) {
+			for _, candidate := range result.TopCandidates[i].Candidates {
+				content.TopLogprobs = append(content.TopLogprobs, dto.TopLogprob{
+					Token:   candidate.Token,
+					Logprob: candidate.LogProbability,
+					Bytes:   dto.TokenBytes(candidate.Token),
+				})
+			}
+		}
+		logprobs.Content = append(logprobs.Content, content)
+	}
+	return logprobs
+}
+
 func getResponseToolCall(item *GeminiPart) *dto.ToolCallResponse {
 	var argsBytes []byte
 	var err error
@@ -530,6 +553,9 @@ func responseGeminiChat2OpenAI(response *GeminiChatResponse) *dto.OpenAITextResp
 			},
 			FinishReason: constant.FinishReasonStop,
 		}
+		if logprobs := logprobsGemini2OpenAI(candidate.LogprobsResult); logprobs != nil {
+			choice.Logprobs = logprobs
+		}
 		if len(candidate.Content.Parts) > 0 {
 			var texts []string
 			var toolCalls []dto.ToolCallResponse
@@ -594,6 +620,10 @@ func streamResponseGeminiChat2OpenAI(geminiResponse *GeminiChatResponse) (*dto.C
 				Role: "assistant",
 			},
 		}
+		if logprobs := logprobsGemini2OpenAI(candidate.LogprobsResult); logprobs != nil {
+			var value any = logprobs
+			choice.Logprobs = &value
+		}
 		var texts []string
 		isTools := false
 		if candidate.FinishReason != nil {
@@ -645,12 +675,32 @@ func streamResponseGeminiChat2OpenAI(geminiResponse *GeminiChatResponse) (*dto.C
 	return &response, isStop, hasImage
 }
 
+// hasGrounding 响应使用了 Google 搜索 grounding，按每次请求计一次调用；
+// 未实际搜索时上游可能返回空的 groundingMetadata，需包含搜索查询或搜索结果才计费
+func hasGrounding(response *GeminiChatResponse) bool {
+	for _, candidate := range response.Candidates {
+		metadata, ok := candidate.GroundingMetadata.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"webSearchQueries", "groundingChunks", "retrievalQueries"} {
+			if values, ok := metadata[key].([]interface{}); ok && len(values) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
 	// responseText := ""
 	id := fmt.Sprintf("chatcmpl-%s", common.GetUUID())
 	createAt := common.GetTimestamp()
 	var usage = &dto.Usage{}
 	var imageCount int
+	var grounded bool
+	var toolCallCount int
+	var usageMetadata GeminiUsageMetadata
 
 	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
 		var geminiResponse GeminiChatResponse
@@ -661,13 +711,29 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 		}
 
 		response, isStop, hasImage := streamResponseGeminiChat2OpenAI(&geminiResponse)
+		// 每个分片内的工具调用序号从 0 开始，按整个流累计，避免客户端将不同分片的调用合并
+		for i := range response.Choices {
+			delta := &response.Choices[i].Delta
+			if info.SingleToolCall && len(delta.ToolCalls) > 0 {
+				delta.ToolCalls = delta.ToolCalls[:max(0, min(len(delta.ToolCalls), 1-toolCallCount))]
+			}
+			for j := range delta.ToolCalls {
+				delta.ToolCalls[j].SetIndex(toolCallCount)
+				toolCallCount++
+			}
+		}
 		if hasImage {
 			imageCount++
 		}
+		if hasGrounding(&geminiResponse) {
+			grounded = true
+		}
 		response.Id = id
 		response.Created = createAt
 		response.Model = info.UpstreamModelName
+		response.SetSystemFingerprint(info.SystemFingerprint())
 		if geminiResponse.UsageMetadata.TotalTokenCount != 0 {
+			usageMetadata = geminiResponse.UsageMetadata
 			usage.PromptTokens = geminiResponse.UsageMetadata.PromptTokenCount
 			usage.CompletionTokens = geminiResponse.UsageMetadata.CandidatesTokenCount
 			usage.CompletionTokenDetails.ReasoningTokens = geminiResponse.UsageMetadata.ThoughtsTokenCount
@@ -678,11 +744,19 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 			common.LogError(c, err.Error())
 		}
 		if isStop {
-			response := helper.GenerateStopResponse(id, createAt, info.UpstreamModelName, constant.FinishReasonStop)
+			finishReason := constant.FinishReasonStop
+			if toolCallCount > 0 {
+				finishReason = constant.FinishReasonToolCalls
+			}
+			response := helper.GenerateStopResponse(id, createAt, info.UpstreamModelName, finishReason)
+			response.SetSystemFingerprint(info.SystemFingerprint())
 			helper.ObjectData(c, response)
 		}
 		return true
 	})
+	if openaiErr := service.StreamFailoverError(info); openaiErr != nil {
+		return openaiErr, nil
+	}
 
 	var response *dto.ChatCompletionsStreamResponse
 
@@ -694,9 +768,15 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 
 	usage.PromptTokensDetails.TextTokens = usage.PromptTokens
 	usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+	usage.PromptTokensDetails.CachedTokens = usageMetadata.CachedContentTokenCount
+	applyGeminiModalityUsage(usage, usageMetadata)
+	if grounded {
+		info.AddBuiltInToolCall(dto.BuildInToolGoogleSearch, 1)
+	}
 
 	if info.ShouldIncludeUsage {
 		response = helper.GenerateFinalUsageResponse(id, createAt, info.UpstreamModelName, *usage)
+		response.SetSystemFingerprint(info.SystemFingerprint())
 		err := helper.ObjectData(c, response)
 		if err != nil {
 			common.SysError("send final response failed: " + err.Error())
@@ -733,7 +813,11 @@ func GeminiChatHandler(c *gin.Context, resp *http.Response, info *relaycommon.Re
 		}, nil
 	}
 	fullTextResponse := responseGeminiChat2OpenAI(&geminiResponse)
+	if info.SingleToolCall {
+		helper.KeepFirstToolCall(fullTextResponse)
+	}
 	fullTextResponse.Model = info.UpstreamModelName
+	fullTextResponse.SystemFingerprint = info.SystemFingerprint()
 	usage := dto.Usage{
 		PromptTokens:     geminiResponse.UsageMetadata.PromptTokenCount,
 		CompletionTokens: geminiResponse.UsageMetadata.CandidatesTokenCount,
@@ -742,6 +826,12 @@ func GeminiChatHandler(c *gin.Context, resp *http.Response, info *relaycommon.Re
 
 	usage.CompletionTokenDetails.ReasoningTokens = geminiResponse.UsageMetadata.ThoughtsTokenCount
 	usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+	// promptTokenCount 包含命中上下文缓存的部分，缓存 token 按缓存倍率计费
+	usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
+	applyGeminiModalityUsage(&usage, geminiResponse.UsageMetadata)
+	if hasGrounding(&geminiResponse) {
+		info.AddBuiltInToolCall(dto.BuildInToolGoogleSearch, 1)
+	}
 
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
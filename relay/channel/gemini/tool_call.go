@@ -0,0 +1,27 @@
+package gemini
+
+// toolChoiceOpenAI2Gemini 转换 tool_choice：auto/required/none 分别对应 AUTO/ANY/NONE，指定函数对应 ANY 并限定函数名
+func toolChoiceOpenAI2Gemini(toolChoice any) *GeminiToolConfig {
+	var config *GeminiFunctionCallingConfig
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			config = &GeminiFunctionCallingConfig{Mode: "AUTO"}
+		case "required":
+			config = &GeminiFunctionCallingConfig{Mode: "ANY"}
+		case "none":
+			config = &GeminiFunctionCallingConfig{Mode: "NONE"}
+		}
+	case map[string]any:
+		if function, ok := v["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				config = &GeminiFunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{name}}
+			}
+		}
+	}
+	if config == nil {
+		return nil
+	}
+	return &GeminiToolConfig{FunctionCallingConfig: config}
+}
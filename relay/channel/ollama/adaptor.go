@@ -54,6 +54,7 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if request == nil {
 		return nil, errors.New("request is nil")
 	}
+	info.EmulateParallelToolCalls(request)
 	return requestOpenAI2Ollama(*request)
 }
 
@@ -19,6 +19,8 @@ type OllamaRequest struct {
 	Suffix           any                   `json:"suffix,omitempty"`
 	StreamOptions    *dto.StreamOptions    `json:"stream_options,omitempty"`
 	Prompt           any                   `json:"prompt,omitempty"`
+	LogProbs         any                   `json:"logprobs,omitempty"`
+	TopLogProbs      int                   `json:"top_logprobs,omitempty"`
 }
 
 type Options struct {
@@ -22,7 +22,7 @@ func requestOpenAI2Ollama(request dto.GeneralOpenAIRequest) (*OllamaRequest, err
 					imageUrl := mediaMessage.GetImageMedia()
 					// check if not base64
 					if strings.HasPrefix(imageUrl.Url, "http") {
-						fileData, err := service.GetFileBase64FromUrl(imageUrl.Url)
+						fileData, err := service.GetInlineImageFromUrl(imageUrl.Url, 0)
 						if err != nil {
 							return nil, err
 						}
@@ -65,6 +65,8 @@ func requestOpenAI2Ollama(request dto.GeneralOpenAIRequest) (*OllamaRequest, err
 		Prompt:           request.Prompt,
 		StreamOptions:    request.StreamOptions,
 		Suffix:           request.Suffix,
+		LogProbs:         request.LogProbs,
+		TopLogProbs:      request.TopLogProbs,
 	}, nil
 }
 
@@ -25,8 +25,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"net/textproto"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -153,33 +151,8 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if info.ChannelType != common.ChannelTypeOpenAI && info.ChannelType != common.ChannelTypeAzure {
 		request.StreamOptions = nil
 	}
-	if strings.HasPrefix(request.Model, "o") {
-		if request.MaxCompletionTokens == 0 && request.MaxTokens != 0 {
-			request.MaxCompletionTokens = request.MaxTokens
-			request.MaxTokens = 0
-		}
-		request.Temperature = nil
-		if strings.HasSuffix(request.Model, "-high") {
-			request.ReasoningEffort = "high"
-			request.Model = strings.TrimSuffix(request.Model, "-high")
-		} else if strings.HasSuffix(request.Model, "-low") {
-			request.ReasoningEffort = "low"
-			request.Model = strings.TrimSuffix(request.Model, "-low")
-		} else if strings.HasSuffix(request.Model, "-medium") {
-			request.ReasoningEffort = "medium"
-			request.Model = strings.TrimSuffix(request.Model, "-medium")
-		}
-		info.ReasoningEffort = request.ReasoningEffort
-		info.UpstreamModelName = request.Model
-
-		// o系列模型developer适配（o1-mini除外）
-		if !strings.HasPrefix(request.Model, "o1-mini") {
-			//修改第一个Message的内容，将system改为developer
-			if len(request.Messages) > 0 && request.Messages[0].Role == "system" {
-				request.Messages[0].Role = "developer"
-			}
-		}
-	}
+	normalizeReasoningRequest(info, request)
+	convertStructuredOutput(info, request)
 
 	return request, nil
 }
@@ -201,163 +174,112 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 		}
 		return bytes.NewReader(jsonData), nil
 	} else {
-		var requestBody bytes.Buffer
-		writer := multipart.NewWriter(&requestBody)
-
-		writer.WriteField("model", request.Model)
-
-		// 获取所有表单字段
-		formData := c.Request.PostForm
-
-		// 遍历表单字段并打印输出
-		for key, values := range formData {
-			if key == "model" {
-				continue
-			}
-			for _, value := range values {
-				writer.WriteField(key, value)
-			}
-		}
-
 		// 添加文件字段
 		file, header, err := c.Request.FormFile("file")
 		if err != nil {
 			return nil, errors.New("file is required")
 		}
-		defer file.Close()
+		return streamMultipart(c, func(writer *multipart.Writer) error {
+			defer file.Close()
+			writer.WriteField("model", request.Model)
 
-		part, err := writer.CreateFormFile("file", header.Filename)
-		if err != nil {
-			return nil, errors.New("create form file failed")
-		}
-		if _, err := io.Copy(part, file); err != nil {
-			return nil, errors.New("copy file failed")
-		}
+			// 获取所有表单字段
+			formData := c.Request.PostForm
+
+			// 遍历表单字段并打印输出
+			for key, values := range formData {
+				if key == "model" {
+					continue
+				}
+				for _, value := range values {
+					writer.WriteField(key, value)
+				}
+			}
 
-		// 关闭 multipart 编写器以设置分界线
-		writer.Close()
-		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
-		return &requestBody, nil
+			part, err := writer.CreateFormFile("file", header.Filename)
+			if err != nil {
+				return errors.New("create form file failed")
+			}
+			if _, err := io.Copy(part, file); err != nil {
+				return errors.New("copy file failed")
+			}
+			return nil
+		}), nil
 	}
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
 	switch info.RelayMode {
 	case constant.RelayModeImagesEdits:
-
-		var requestBody bytes.Buffer
-		writer := multipart.NewWriter(&requestBody)
-
-		writer.WriteField("model", request.Model)
-		// 获取所有表单字段
-		formData := c.Request.PostForm
-		// 遍历表单字段并打印输出
-		for key, values := range formData {
-			if key == "model" {
-				continue
-			}
-			for _, value := range values {
-				writer.WriteField(key, value)
-			}
-		}
-
 		// Parse the multipart form to handle both single image and multiple images
 		if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB max memory
 			return nil, errors.New("failed to parse multipart form")
 		}
+		if c.Request.MultipartForm == nil || c.Request.MultipartForm.File == nil {
+			return nil, errors.New("no multipart form data found")
+		}
 
-		if c.Request.MultipartForm != nil && c.Request.MultipartForm.File != nil {
-			// Check if "image" field exists in any form, including array notation
-			var imageFiles []*multipart.FileHeader
-			var exists bool
-
-			// First check for standard "image" field
-			if imageFiles, exists = c.Request.MultipartForm.File["image"]; !exists || len(imageFiles) == 0 {
-				// If not found, check for "image[]" field
-				if imageFiles, exists = c.Request.MultipartForm.File["image[]"]; !exists || len(imageFiles) == 0 {
-					// If still not found, iterate through all fields to find any that start with "image["
-					foundArrayImages := false
-					for fieldName, files := range c.Request.MultipartForm.File {
-						if strings.HasPrefix(fieldName, "image[") && len(files) > 0 {
-							foundArrayImages = true
-							for _, file := range files {
-								imageFiles = append(imageFiles, file)
-							}
+		// Check if "image" field exists in any form, including array notation
+		var imageFiles []*multipart.FileHeader
+		var exists bool
+
+		// First check for standard "image" field
+		if imageFiles, exists = c.Request.MultipartForm.File["image"]; !exists || len(imageFiles) == 0 {
+			// If not found, check for "image[]" field
+			if imageFiles, exists = c.Request.MultipartForm.File["image[]"]; !exists || len(imageFiles) == 0 {
+				// If still not found, iterate through all fields to find any that start with "image["
+				foundArrayImages := false
+				for fieldName, files := range c.Request.MultipartForm.File {
+					if strings.HasPrefix(fieldName, "image[") && len(files) > 0 {
+						foundArrayImages = true
+						for _, file := range files {
+							imageFiles = append(imageFiles, file)
 						}
 					}
+				}
 
-					// If no image fields found at all
-					if !foundArrayImages && (len(imageFiles) == 0) {
-						return nil, errors.New("image is required")
-					}
+				// If no image fields found at all
+				if !foundArrayImages && (len(imageFiles) == 0) {
+					return nil, errors.New("image is required")
+				}
+			}
+		}
+		maskFiles := c.Request.MultipartForm.File["mask"]
+
+		return streamMultipart(c, func(writer *multipart.Writer) error {
+			writer.WriteField("model", request.Model)
+			// 获取所有表单字段
+			formData := c.Request.PostForm
+			// 遍历表单字段并打印输出
+			for key, values := range formData {
+				if key == "model" {
+					continue
+				}
+				for _, value := range values {
+					writer.WriteField(key, value)
 				}
 			}
 
 			// Process all image files
 			for i, fileHeader := range imageFiles {
-				file, err := fileHeader.Open()
-				if err != nil {
-					return nil, fmt.Errorf("failed to open image file %d: %w", i, err)
-				}
-				defer file.Close()
-
 				// If multiple images, use image[] as the field name
 				fieldName := "image"
 				if len(imageFiles) > 1 {
 					fieldName = "image[]"
 				}
-
-				// Determine MIME type based on file extension
-				mimeType := detectImageMimeType(fileHeader.Filename)
-
-				// Create a form file with the appropriate content type
-				h := make(textproto.MIMEHeader)
-				h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fieldName, fileHeader.Filename))
-				h.Set("Content-Type", mimeType)
-
-				part, err := writer.CreatePart(h)
-				if err != nil {
-					return nil, fmt.Errorf("create form part failed for image %d: %w", i, err)
-				}
-
-				if _, err := io.Copy(part, file); err != nil {
-					return nil, fmt.Errorf("copy file failed for image %d: %w", i, err)
+				if err := writeImageFormFile(writer, fieldName, fileHeader); err != nil {
+					return fmt.Errorf("write image %d failed: %w", i, err)
 				}
 			}
 
 			// Handle mask file if present
-			if maskFiles, exists := c.Request.MultipartForm.File["mask"]; exists && len(maskFiles) > 0 {
-				maskFile, err := maskFiles[0].Open()
-				if err != nil {
-					return nil, errors.New("failed to open mask file")
-				}
-				defer maskFile.Close()
-
-				// Determine MIME type for mask file
-				mimeType := detectImageMimeType(maskFiles[0].Filename)
-
-				// Create a form file with the appropriate content type
-				h := make(textproto.MIMEHeader)
-				h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="mask"; filename="%s"`, maskFiles[0].Filename))
-				h.Set("Content-Type", mimeType)
-
-				maskPart, err := writer.CreatePart(h)
-				if err != nil {
-					return nil, errors.New("create form file failed for mask")
-				}
-
-				if _, err := io.Copy(maskPart, maskFile); err != nil {
-					return nil, errors.New("copy mask file failed")
+			if len(maskFiles) > 0 {
+				if err := writeImageFormFile(writer, "mask", maskFiles[0]); err != nil {
+					return fmt.Errorf("write mask file failed: %w", err)
 				}
 			}
-		} else {
-			return nil, errors.New("no multipart form data found")
-		}
-
-		// 关闭 multipart 编写器以设置分界线
-		writer.Close()
-		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
-		return bytes.NewReader(requestBody.Bytes()), nil
+			return nil
+		}), nil
 
 	default:
 		return request, nil
@@ -31,6 +31,9 @@ func handleClaudeFormat(c *gin.Context, data string, info *relaycommon.RelayInfo
 		return err
 	}
 
+	if info.SingleToolCall && !helper.KeepFirstStreamToolCall(&streamResponse) {
+		return nil
+	}
 	if streamResponse.Usage != nil {
 		info.ClaudeConvertInfo.Usage = streamResponse.Usage
 	}
@@ -158,6 +161,39 @@ func handleLastResponse(lastStreamData string, responseId *string, createAt *int
 	return nil
 }
 
+// 最后一个数据块不含 usage 时向前查找的数据块数量
+const streamUsageLookback = 3
+
+// findStreamUsage 部分上游在 finish_reason 所在的数据块或其前几个数据块中返回 usage，而不是最后一个数据块
+func findStreamUsage(streamItems []string) *dto.Usage {
+	for i := len(streamItems) - 2; i >= 0 && i >= len(streamItems)-1-streamUsageLookback; i-- {
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal(common.StringToByteSlice(streamItems[i]), &streamResponse); err != nil {
+			continue
+		}
+		if service.ValidUsage(streamResponse.Usage) {
+			return streamResponse.Usage
+		}
+	}
+	return nil
+}
+
+// findStreamServiceTier 返回上游实际使用的 service_tier，OpenAI 在每个数据块中都会返回，从最后的数据块向前查找
+func findStreamServiceTier(streamItems []string) string {
+	for i := len(streamItems) - 1; i >= 0 && i >= len(streamItems)-1-streamUsageLookback; i-- {
+		var streamResponse struct {
+			ServiceTier string `json:"service_tier"`
+		}
+		if err := common.DecodeJsonStr(streamItems[i], &streamResponse); err != nil {
+			continue
+		}
+		if streamResponse.ServiceTier != "" {
+			return streamResponse.ServiceTier
+		}
+	}
+	return ""
+}
+
 func handleFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, lastStreamData string,
 	responseId string, createAt int64, model string, systemFingerprint string,
 	usage *dto.Usage, containStreamUsage bool) {
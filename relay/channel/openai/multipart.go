@@ -0,0 +1,52 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamMultipart 在后台生成 multipart 请求体并通过管道交给上游请求，文件内容边读边发，
+// 不在内存中拼出完整的请求体；请求结束时关闭管道，避免上游请求未发出时写入协程阻塞
+func streamMultipart(c *gin.Context, write func(writer *multipart.Writer) error) io.Reader {
+	reader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	stop := context.AfterFunc(c.Request.Context(), func() {
+		_ = reader.CloseWithError(context.Canceled)
+	})
+	go func() {
+		defer stop()
+		err := write(writer)
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pipeWriter.CloseWithError(err)
+	}()
+	return reader
+}
+
+// writeImageFormFile 按文件名推断 MIME 类型写入图片文件字段
+func writeImageFormFile(writer *multipart.Writer, fieldName string, fileHeader *multipart.FileHeader) error {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fieldName, fileHeader.Filename))
+	h.Set("Content-Type", detectImageMimeType(fileHeader.Filename))
+	part, err := writer.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("create form part failed: %w", err)
+	}
+	if _, err = io.Copy(part, file); err != nil {
+		return fmt.Errorf("copy file failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package openai
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canPassthroughStream 上游返回的 OpenAI SSE 无需任何转换（格式转换、敏感词与输出过滤、思考内容处理、单工具调用）时直接转发；
+// 强制开启 stream_options 而客户端未要求 usage 时，需要去掉上游的 usage 分块，不能直接转发
+func canPassthroughStream(c *gin.Context, info *relaycommon.RelayInfo, forceFormat bool, thinkToContent bool) bool {
+	if !operation_setting.GetGeneralSetting().StreamPassthroughEnabled {
+		return false
+	}
+	if info.RelayFormat != relaycommon.RelayFormatOpenAI {
+		return false
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions && info.RelayMode != relayconstant.RelayModeCompletions {
+		return false
+	}
+	if forceFormat || thinkToContent || info.SingleToolCall || helper.HasStreamOutputFilter(c) {
+		return false
+	}
+	if info.SupportStreamOptions && constant.ForceStreamOption && !info.ShouldIncludeUsage {
+		return false
+	}
+	return true
+}
+
+// findPassthroughUsage 从最后几个分块中查找 usage，上游通常只在最后一个分块（或 finish_reason 之后的分块）返回
+func findPassthroughUsage(streamItems []string) *dto.Usage {
+	for i := len(streamItems) - 1; i >= 0 && i >= len(streamItems)-1-streamUsageLookback; i-- {
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := common.DecodeJsonStr(streamItems[i], &streamResponse); err != nil {
+			continue
+		}
+		if service.ValidUsage(streamResponse.Usage) {
+			return streamResponse.Usage
+		}
+	}
+	return nil
+}
+
+// oaiStreamPassthroughHandler 按原始字节转发上游分块，不逐块解析 JSON，仅在结束时从末尾分块提取 usage；
+// 上游未返回 usage 或 completion_tokens 为 0 时才解析全部分块估算
+func oaiStreamPassthroughHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	var streamItems []string
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		info.SendResponseCount++
+		if err := helper.StringData(c, data); err != nil {
+			common.SysError("error sending stream data: " + err.Error())
+		}
+		streamItems = append(streamItems, data)
+		return true
+	})
+	if openaiErr := service.StreamFailoverError(info); openaiErr != nil {
+		return openaiErr, nil
+	}
+
+	var lastStreamData string
+	if len(streamItems) > 0 {
+		lastStreamData = streamItems[len(streamItems)-1]
+	}
+	info.ServiceTier = findStreamServiceTier(streamItems)
+	usage := findPassthroughUsage(streamItems)
+	containStreamUsage := usage != nil
+	if !containStreamUsage || usage.CompletionTokens == 0 {
+		var responseTextBuilder strings.Builder
+		var toolCount int
+		if err := processTokens(info.RelayMode, streamItems, &responseTextBuilder, &toolCount); err != nil {
+			common.SysError("error processing tokens: " + err.Error())
+		}
+		if !containStreamUsage {
+			usage, _ = service.ResponseText2Usage(responseTextBuilder.String(), info.UpstreamModelName, info.PromptTokens)
+			usage.CompletionTokens += toolCount * 7
+		} else {
+			usage = service.MergeUsageEstimate(usage, responseTextBuilder.String(), info.UpstreamModelName, info.PromptTokens)
+		}
+	}
+	if containStreamUsage && info.ChannelType == common.ChannelTypeDeepSeek && usage.PromptCacheHitTokens != 0 {
+		usage.PromptTokensDetails.CachedTokens = usage.PromptCacheHitTokens
+	}
+
+	model := info.UpstreamModelName
+	var responseId, systemFingerprint string
+	var createAt int64
+	if info.ShouldIncludeUsage && !containStreamUsage {
+		var lastStreamResponse dto.ChatCompletionsStreamResponse
+		if err := common.DecodeJsonStr(lastStreamData, &lastStreamResponse); err == nil {
+			responseId = lastStreamResponse.Id
+			createAt = lastStreamResponse.Created
+			systemFingerprint = lastStreamResponse.GetSystemFingerprint()
+			model = lastStreamResponse.Model
+		}
+	}
+	handleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, usage, containStreamUsage)
+
+	return nil, usage
+}
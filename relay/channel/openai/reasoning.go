@@ -0,0 +1,91 @@
+package openai
+
+import (
+	constant2 "one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"regexp"
+	"strings"
+)
+
+// o1/o3/o4 等推理模型，避免 omni-moderation 等以 o 开头的模型被误判
+var reasoningModelPattern = regexp.MustCompile(`^o\d`)
+
+var reasoningEffortSuffixes = []string{"high", "medium", "low"}
+
+// reasoningNormalizeOptions 推理模型参数规范化选项，默认全部开启
+type reasoningNormalizeOptions struct {
+	MaxCompletionTokens bool `json:"max_completion_tokens"` // max_tokens 转为 max_completion_tokens
+	DropSampling        bool `json:"drop_sampling"`         // 去掉不支持的 temperature、top_p
+	DeveloperRole       bool `json:"developer_role"`        // system 角色转为 developer（o1-mini 除外）
+	ReasoningEffort     bool `json:"reasoning_effort"`      // 解析模型名的 -high/-medium/-low 后缀为 reasoning_effort
+}
+
+// getReasoningNormalizeOptions 渠道设置 reasoning_normalize 为 false 时全部关闭，为对象时可按项关闭，如 {"developer_role": false}
+func getReasoningNormalizeOptions(info *relaycommon.RelayInfo) reasoningNormalizeOptions {
+	options := reasoningNormalizeOptions{
+		MaxCompletionTokens: true,
+		DropSampling:        true,
+		DeveloperRole:       true,
+		ReasoningEffort:     true,
+	}
+	switch v := info.ChannelSetting[constant2.ChannelSettingReasoningNormalize].(type) {
+	case bool:
+		if !v {
+			return reasoningNormalizeOptions{}
+		}
+	case map[string]interface{}:
+		fields := map[string]*bool{
+			"max_completion_tokens": &options.MaxCompletionTokens,
+			"drop_sampling":         &options.DropSampling,
+			"developer_role":        &options.DeveloperRole,
+			"reasoning_effort":      &options.ReasoningEffort,
+		}
+		for key, field := range fields {
+			if enabled, ok := v[key].(bool); ok {
+				*field = enabled
+			}
+		}
+	}
+	return options
+}
+
+func isReasoningModel(model string) bool {
+	return reasoningModelPattern.MatchString(model)
+}
+
+// normalizeReasoningRequest 将请求参数调整为 o 系列推理模型可接受的形式
+func normalizeReasoningRequest(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	if !isReasoningModel(request.Model) {
+		return
+	}
+	options := getReasoningNormalizeOptions(info)
+	if options.MaxCompletionTokens && request.MaxCompletionTokens == 0 && request.MaxTokens != 0 {
+		request.MaxCompletionTokens = request.MaxTokens
+		request.MaxTokens = 0
+	}
+	if options.DropSampling {
+		request.Temperature = nil
+		request.TopP = 0
+	}
+	if options.ReasoningEffort {
+		for _, effort := range reasoningEffortSuffixes {
+			if strings.HasSuffix(request.Model, "-"+effort) {
+				request.ReasoningEffort = effort
+				request.Model = strings.TrimSuffix(request.Model, "-"+effort)
+				break
+			}
+		}
+	}
+	info.ReasoningEffort = request.ReasoningEffort
+	info.UpstreamModelName = request.Model
+
+	// o系列模型developer适配（o1-mini除外）
+	if options.DeveloperRole && !strings.HasPrefix(request.Model, "o1-mini") {
+		for i := range request.Messages {
+			if request.Messages[i].Role == "system" {
+				request.Messages[i].Role = "developer"
+			}
+		}
+	}
+}
@@ -28,7 +28,7 @@ func sendStreamData(c *gin.Context, info *relaycommon.RelayInfo, data string, fo
 		return nil
 	}
 
-	if !forceFormat && !thinkToContent {
+	if !forceFormat && !thinkToContent && !info.SingleToolCall {
 		return helper.StringData(c, data)
 	}
 
@@ -37,6 +37,13 @@ func sendStreamData(c *gin.Context, info *relaycommon.RelayInfo, data string, fo
 		return err
 	}
 
+	if info.SingleToolCall && !helper.KeepFirstStreamToolCall(&lastStreamResponse) {
+		return nil
+	}
+	if !forceFormat && !thinkToContent {
+		return helper.ObjectData(c, lastStreamResponse)
+	}
+
 	if !thinkToContent {
 		return helper.ObjectData(c, lastStreamResponse)
 	}
@@ -131,6 +138,10 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		thinkToContent = think2Content
 	}
 
+	if canPassthroughStream(c, info, forceFormat, thinkToContent) {
+		return oaiStreamPassthroughHandler(c, resp, info)
+	}
+
 	var (
 		lastStreamData string
 	)
@@ -146,6 +157,9 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		streamItems = append(streamItems, data)
 		return true
 	})
+	if openaiErr := service.StreamFailoverError(info); openaiErr != nil {
+		return openaiErr, nil
+	}
 
 	shouldSendLastResp := true
 	var lastStreamResponse dto.ChatCompletionsStreamResponse
@@ -174,15 +188,24 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		//err = handleStreamFormat(c, info, lastStreamData, forceFormat, thinkToContent)
 	}
 
+	info.ServiceTier = findStreamServiceTier(streamItems)
+
 	// 处理token计算
 	if err := processTokens(info.RelayMode, streamItems, &responseTextBuilder, &toolCount); err != nil {
 		common.SysError("error processing tokens: " + err.Error())
 	}
 
+	if !containStreamUsage {
+		if streamUsage := findStreamUsage(streamItems); streamUsage != nil {
+			usage = streamUsage
+			containStreamUsage = true
+		}
+	}
 	if !containStreamUsage {
 		usage, _ = service.ResponseText2Usage(responseTextBuilder.String(), info.UpstreamModelName, info.PromptTokens)
 		usage.CompletionTokens += toolCount * 7
 	} else {
+		usage = service.MergeUsageEstimate(usage, responseTextBuilder.String(), info.UpstreamModelName, info.PromptTokens)
 		if info.ChannelType == common.ChannelTypeDeepSeek {
 			if usage.PromptCacheHitTokens != 0 {
 				usage.PromptTokensDetails.CachedTokens = usage.PromptCacheHitTokens
@@ -216,6 +239,15 @@ func OpenaiHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayI
 		}, nil
 	}
 
+	info.ServiceTier = simpleResponse.ServiceTier
+
+	if info.SingleToolCall && helper.KeepFirstToolCall(&simpleResponse) {
+		responseBody, err = json.Marshal(simpleResponse)
+		if err != nil {
+			return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+		}
+	}
+
 	switch info.RelayFormat {
 	case relaycommon.RelayFormatOpenAI:
 		break
@@ -244,19 +276,12 @@ func OpenaiHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayI
 		common.SysError("error copying response body: " + err.Error())
 	}
 	resp.Body.Close()
-	if simpleResponse.Usage.TotalTokens == 0 || (simpleResponse.Usage.PromptTokens == 0 && simpleResponse.Usage.CompletionTokens == 0) {
-		completionTokens := 0
-		for _, choice := range simpleResponse.Choices {
-			ctkm, _ := service.CountTextToken(choice.Message.StringContent()+choice.Message.ReasoningContent+choice.Message.Reasoning, info.UpstreamModelName)
-			completionTokens += ctkm
-		}
-		simpleResponse.Usage = dto.Usage{
-			PromptTokens:     info.PromptTokens,
-			CompletionTokens: completionTokens,
-			TotalTokens:      info.PromptTokens + completionTokens,
-		}
+	// 上游返回的 usage 优先，仅补齐缺失的字段（部分上游不返回 total_tokens）
+	var responseText strings.Builder
+	for _, choice := range simpleResponse.Choices {
+		responseText.WriteString(choice.Message.StringContent() + choice.Message.ReasoningContent + choice.Message.Reasoning)
 	}
-	return nil, &simpleResponse.Usage
+	return nil, service.MergeUsageEstimate(&simpleResponse.Usage, responseText.String(), info.UpstreamModelName, info.PromptTokens)
 }
 
 func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
@@ -430,7 +455,7 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*dto.Op
 					errChan <- fmt.Errorf("error counting text token: %v", err)
 					return
 				}
-				common.LogInfo(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
+				common.LogDebug(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
 				localUsage.TotalTokens += textToken + audioToken
 				localUsage.InputTokens += textToken + audioToken
 				localUsage.InputTokenDetails.TextTokens += textToken
@@ -503,7 +528,7 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*dto.Op
 							errChan <- fmt.Errorf("error counting text token: %v", err)
 							return
 						}
-						common.LogInfo(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
+						common.LogDebug(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
 						localUsage.TotalTokens += textToken + audioToken
 						info.IsFirstRequest = false
 						localUsage.InputTokens += textToken + audioToken
@@ -535,7 +560,7 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*dto.Op
 						errChan <- fmt.Errorf("error counting text token: %v", err)
 						return
 					}
-					common.LogInfo(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
+					common.LogDebug(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
 					localUsage.TotalTokens += textToken + audioToken
 					localUsage.OutputTokens += textToken + audioToken
 					localUsage.OutputTokenDetails.TextTokens += textToken
@@ -583,6 +608,8 @@ func preConsumeUsage(ctx *gin.Context, info *relaycommon.RelayInfo, usage *dto.R
 		return fmt.Errorf("invalid usage pointer")
 	}
 
+	// 本次计费覆盖自上次计费以来的音频时长
+	usage.InputAudioSeconds, usage.OutputAudioSeconds = info.TakeAudioSeconds()
 	totalUsage.TotalTokens += usage.TotalTokens
 	totalUsage.InputTokens += usage.InputTokens
 	totalUsage.OutputTokens += usage.OutputTokens
@@ -591,6 +618,8 @@ func preConsumeUsage(ctx *gin.Context, info *relaycommon.RelayInfo, usage *dto.R
 	totalUsage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
 	totalUsage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
 	totalUsage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	totalUsage.InputAudioSeconds += usage.InputAudioSeconds
+	totalUsage.OutputAudioSeconds += usage.OutputAudioSeconds
 	// clear usage
 	err := service.PreWssConsumeQuota(ctx, info, usage)
 	return err
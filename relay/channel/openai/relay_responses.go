@@ -62,9 +62,12 @@ func OaiResponsesHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	usage.PromptTokens = responsesResponse.Usage.InputTokens
 	usage.CompletionTokens = responsesResponse.Usage.OutputTokens
 	usage.TotalTokens = responsesResponse.Usage.TotalTokens
-	// 解析 Tools 用量
-	for _, tool := range responsesResponse.Tools {
-		info.ResponsesUsageInfo.BuiltInTools[tool.Type].CallCount++
+	info.ServiceTier = responsesResponse.ServiceTier
+	// 解析 Tools 用量，按输出中的工具调用项计数
+	for _, output := range responsesResponse.Output {
+		if toolName, ok := dto.BuildInCallTools[output.Type]; ok {
+			info.AddBuiltInToolCall(toolName, 1)
+		}
 	}
 	return nil, &usage
 }
@@ -89,31 +92,27 @@ func OaiResponsesStreamHandler(c *gin.Context, resp *http.Response, info *relayc
 				usage.PromptTokens = streamResponse.Response.Usage.InputTokens
 				usage.CompletionTokens = streamResponse.Response.Usage.OutputTokens
 				usage.TotalTokens = streamResponse.Response.Usage.TotalTokens
+				info.ServiceTier = streamResponse.Response.ServiceTier
 			case "response.output_text.delta":
 				// 处理输出文本
 				responseTextBuilder.WriteString(streamResponse.Delta)
 			case dto.ResponsesOutputTypeItemDone:
 				// 函数调用处理
 				if streamResponse.Item != nil {
-					switch streamResponse.Item.Type {
-					case dto.BuildInCallWebSearchCall:
-						info.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolWebSearchPreview].CallCount++
+					if toolName, ok := dto.BuildInCallTools[streamResponse.Item.Type]; ok {
+						info.AddBuiltInToolCall(toolName, 1)
 					}
 				}
 			}
 		}
 		return true
 	})
-
-	if usage.CompletionTokens == 0 {
-		// 计算输出文本的 token 数量
-		tempStr := responseTextBuilder.String()
-		if len(tempStr) > 0 {
-			// 非正常结束，使用输出文本的 token 数量
-			completionTokens, _ := service.CountTextToken(tempStr, info.UpstreamModelName)
-			usage.CompletionTokens = completionTokens
-		}
+	if openaiErr := service.StreamFailoverError(info); openaiErr != nil {
+		return openaiErr, nil
 	}
 
+	// 非正常结束时上游不返回 usage，使用预估的输入与输出文本的 token 数量
+	usage = service.MergeUsageEstimate(usage, responseTextBuilder.String(), info.UpstreamModelName, info.PromptTokens)
+
 	return nil, usage
 }
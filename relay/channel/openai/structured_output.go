@@ -0,0 +1,21 @@
+package openai
+
+import (
+	constant2 "one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+)
+
+const structuredOutputGuidedJson = "guided_json"
+
+// convertStructuredOutput 渠道设置 structured_output 为 guided_json 时，将 json_schema 转换为 vLLM 的 guided_json 参数
+func convertStructuredOutput(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	format, _ := info.ChannelSetting[constant2.ChannelSettingStructuredOutput].(string)
+	if format != structuredOutputGuidedJson || request.ResponseFormat == nil {
+		return
+	}
+	if request.ResponseFormat.Type == "json_schema" && request.ResponseFormat.JsonSchema != nil && request.ResponseFormat.JsonSchema.Schema != nil {
+		request.GuidedJson = request.ResponseFormat.JsonSchema.Schema
+		request.ResponseFormat = nil
+	}
+}
@@ -0,0 +1,89 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskAdaptor 通用异步任务协议：POST {base_url}/v1/tasks 提交，GET {base_url}/v1/tasks/{id} 查询，
+// 请求与响应格式与网关自身的 /v1/tasks 接口一致，可直接对接同类网关或实现了该协议的上游
+type TaskAdaptor struct{}
+
+type submitRequest struct {
+	Model string          `json:"model"`
+	Kind  string          `json:"kind,omitempty"`
+	Input json.RawMessage `json:"input"`
+	Units float64         `json:"units,omitempty"`
+}
+
+func (a *TaskAdaptor) SubmitAsyncTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.AsyncTaskRequest) (string, *dto.TaskError) {
+	body, err := json.Marshal(submitRequest{
+		Model: info.UpstreamModelName,
+		Kind:  request.Kind,
+		Input: request.Input,
+		Units: request.Units,
+	})
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(info.BaseUrl, "/")+"/v1/tasks", bytes.NewReader(body))
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	responseBody, taskErr := channel.DoAsyncTaskRequest(c, info, req)
+	if taskErr != nil {
+		return "", taskErr
+	}
+	var taskResponse dto.AsyncTaskResponse
+	if err := json.Unmarshal(responseBody, &taskResponse); err != nil {
+		return "", service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if taskResponse.Id == "" {
+		return "", service.TaskErrorWrapper(errors.New("上游未返回任务 ID"), "invalid_upstream_response", http.StatusInternalServerError)
+	}
+	return taskResponse.Id, nil
+}
+
+func (a *TaskAdaptor) FetchAsyncTask(baseUrl string, key string, upstreamTaskId string) (*dto.AsyncTaskResponse, error) {
+	requestUrl := strings.TrimSuffix(baseUrl, "/") + "/v1/tasks/" + url.PathEscape(upstreamTaskId)
+	req, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	responseBody, err := channel.DoAsyncTaskFetch(req)
+	if err != nil {
+		return nil, err
+	}
+	var taskResponse dto.AsyncTaskResponse
+	if err := json.Unmarshal(responseBody, &taskResponse); err != nil {
+		return nil, err
+	}
+	taskResponse.Status = normalizeStatus(taskResponse.Status)
+	return &taskResponse, nil
+}
+
+// normalizeStatus 兼容上游常见的任务状态写法
+func normalizeStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "succeeded", "success", "completed", "complete", "done", "finished":
+		return "succeeded"
+	case "failed", "failure", "error", "cancelled", "canceled", "expired":
+		return "failed"
+	case "in_progress", "running", "processing", "started":
+		return "in_progress"
+	}
+	return "queued"
+}
@@ -0,0 +1,165 @@
+package kling
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// TaskAdaptor 可灵视频生成：文生视频 POST /v1/videos/text2video，图生视频 POST /v1/videos/image2video，
+// 查询为 GET /v1/videos/{action}/{task_id}；渠道密钥格式为 AccessKey|SecretKey
+// https://app.klingai.com/cn/dev/document-api
+type TaskAdaptor struct{}
+
+type klingResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		TaskId        string `json:"task_id"`
+		TaskStatus    string `json:"task_status"`
+		TaskStatusMsg string `json:"task_status_msg"`
+		TaskResult    struct {
+			Videos []struct {
+				Url      string `json:"url"`
+				Duration string `json:"duration"`
+			} `json:"videos"`
+		} `json:"task_result"`
+	} `json:"data"`
+}
+
+// generateToken 使用 AccessKey 与 SecretKey 签发 30 分钟有效的 JWT
+func generateToken(key string) (string, error) {
+	parts := strings.Split(key, "|")
+	if len(parts) != 2 {
+		return "", errors.New("可灵渠道密钥格式应为 AccessKey|SecretKey")
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": parts[0],
+		"exp": now.Add(30 * time.Minute).Unix(),
+		"nbf": now.Add(-5 * time.Second).Unix(),
+	})
+	return token.SignedString([]byte(parts[1]))
+}
+
+func (a *TaskAdaptor) SubmitAsyncTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.AsyncTaskRequest) (string, *dto.TaskError) {
+	var videoRequest dto.VideoGenerationRequest
+	if err := json.Unmarshal(request.Input, &videoRequest); err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	action := "text2video"
+	// 可灵以 std、pro 模式区分 720p 与 1080p
+	mode := "std"
+	if videoRequest.Resolution == "1080p" {
+		mode = "pro"
+	}
+	body := map[string]interface{}{
+		"model_name": info.UpstreamModelName,
+		"prompt":     videoRequest.Prompt,
+		"duration":   strconv.Itoa(videoRequest.Seconds),
+		"mode":       mode,
+	}
+	if videoRequest.NegativePrompt != "" {
+		body["negative_prompt"] = videoRequest.NegativePrompt
+	}
+	if videoRequest.AspectRatio != "" {
+		body["aspect_ratio"] = videoRequest.AspectRatio
+	}
+	if videoRequest.Image != "" {
+		action = "image2video"
+		body["image"] = videoRequest.Image
+	}
+	for k, v := range videoRequest.Extra {
+		body[k] = v
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	token, err := generateToken(info.ApiKey)
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "invalid_channel_key", http.StatusInternalServerError)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(info.BaseUrl, "/")+"/v1/videos/"+action, bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	responseBody, taskErr := channel.DoAsyncTaskRequest(c, info, req)
+	if taskErr != nil {
+		return "", taskErr
+	}
+	var klingResp klingResponse
+	if err := json.Unmarshal(responseBody, &klingResp); err != nil {
+		return "", service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if klingResp.Code != 0 {
+		return "", service.TaskErrorWrapper(fmt.Errorf("%s", klingResp.Message), strconv.Itoa(klingResp.Code), http.StatusBadRequest)
+	}
+	if klingResp.Data.TaskId == "" {
+		return "", service.TaskErrorWrapper(errors.New("上游未返回任务 ID"), "invalid_upstream_response", http.StatusInternalServerError)
+	}
+	// 查询接口按文生、图生区分，上游任务 ID 记录为 {action}/{task_id}
+	return action + "/" + klingResp.Data.TaskId, nil
+}
+
+func (a *TaskAdaptor) FetchAsyncTask(baseUrl string, key string, upstreamTaskId string) (*dto.AsyncTaskResponse, error) {
+	action, taskId, ok := strings.Cut(upstreamTaskId, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid kling task id: %s", upstreamTaskId)
+	}
+	token, err := generateToken(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(baseUrl, "/")+"/v1/videos/"+action+"/"+url.PathEscape(taskId), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	responseBody, err := channel.DoAsyncTaskFetch(req)
+	if err != nil {
+		return nil, err
+	}
+	var klingResp klingResponse
+	if err := json.Unmarshal(responseBody, &klingResp); err != nil {
+		return nil, err
+	}
+	if klingResp.Code != 0 {
+		return nil, fmt.Errorf("fetch kling task failed: %d %s", klingResp.Code, klingResp.Message)
+	}
+	response := &dto.AsyncTaskResponse{Id: upstreamTaskId}
+	switch klingResp.Data.TaskStatus {
+	case "succeed":
+		response.Status = "succeeded"
+		result := dto.VideoGenerationResult{Videos: []dto.VideoResultItem{}}
+		for _, video := range klingResp.Data.TaskResult.Videos {
+			seconds, _ := strconv.ParseFloat(video.Duration, 64)
+			result.Videos = append(result.Videos, dto.VideoResultItem{Url: video.Url, Seconds: seconds})
+			response.Units += seconds
+		}
+		response.Result, _ = json.Marshal(result)
+	case "failed":
+		response.Status = "failed"
+		response.FailReason = klingResp.Data.TaskStatusMsg
+	case "processing":
+		response.Status = "in_progress"
+	default:
+		response.Status = "queued"
+	}
+	return response, nil
+}
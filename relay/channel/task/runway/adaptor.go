@@ -0,0 +1,118 @@
+package runway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion Runway 要求每个请求携带 X-Runway-Version
+const apiVersion = "2024-11-06"
+
+// TaskAdaptor Runway 视频生成：图生视频 POST /v1/image_to_video，文生视频 POST /v1/text_to_video，
+// 查询为 GET /v1/tasks/{id}
+// https://docs.dev.runwayml.com/api
+type TaskAdaptor struct{}
+
+type taskResponse struct {
+	Id       string   `json:"id"`
+	Status   string   `json:"status"`
+	Progress float64  `json:"progress"`
+	Output   []string `json:"output"`
+	Failure  string   `json:"failure"`
+}
+
+func (a *TaskAdaptor) SubmitAsyncTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.AsyncTaskRequest) (string, *dto.TaskError) {
+	var videoRequest dto.VideoGenerationRequest
+	if err := json.Unmarshal(request.Input, &videoRequest); err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	width, height := videoRequest.Dimensions()
+	endpoint := "/v1/text_to_video"
+	body := map[string]interface{}{
+		"model":      info.UpstreamModelName,
+		"promptText": videoRequest.Prompt,
+		"ratio":      fmt.Sprintf("%d:%d", width, height),
+		"duration":   videoRequest.Seconds,
+	}
+	if videoRequest.Image != "" {
+		endpoint = "/v1/image_to_video"
+		body["promptImage"] = videoRequest.Image
+	}
+	for k, v := range videoRequest.Extra {
+		body[k] = v
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(info.BaseUrl, "/")+endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	req.Header.Set("X-Runway-Version", apiVersion)
+	responseBody, taskErr := channel.DoAsyncTaskRequest(c, info, req)
+	if taskErr != nil {
+		return "", taskErr
+	}
+	var task taskResponse
+	if err := json.Unmarshal(responseBody, &task); err != nil {
+		return "", service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if task.Id == "" {
+		return "", service.TaskErrorWrapper(errors.New("上游未返回任务 ID"), "invalid_upstream_response", http.StatusInternalServerError)
+	}
+	return task.Id, nil
+}
+
+func (a *TaskAdaptor) FetchAsyncTask(baseUrl string, key string, upstreamTaskId string) (*dto.AsyncTaskResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(baseUrl, "/")+"/v1/tasks/"+url.PathEscape(upstreamTaskId), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("X-Runway-Version", apiVersion)
+	responseBody, err := channel.DoAsyncTaskFetch(req)
+	if err != nil {
+		return nil, err
+	}
+	var task taskResponse
+	if err := json.Unmarshal(responseBody, &task); err != nil {
+		return nil, err
+	}
+	response := &dto.AsyncTaskResponse{
+		Id:       task.Id,
+		Progress: int(math.Round(task.Progress * 100)),
+	}
+	switch task.Status {
+	case "SUCCEEDED":
+		// Runway 不返回实际时长，按提交时的时长结算
+		response.Status = "succeeded"
+		result := dto.VideoGenerationResult{Videos: []dto.VideoResultItem{}}
+		for _, output := range task.Output {
+			result.Videos = append(result.Videos, dto.VideoResultItem{Url: output})
+		}
+		response.Result, _ = json.Marshal(result)
+	case "FAILED", "CANCELLED":
+		response.Status = "failed"
+		response.FailReason = task.Failure
+	case "RUNNING":
+		response.Status = "in_progress"
+	default:
+		response.Status = "queued"
+	}
+	return response, nil
+}
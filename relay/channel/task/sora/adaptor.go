@@ -0,0 +1,126 @@
+package sora
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskAdaptor OpenAI Sora 视频生成：POST {base_url}/v1/videos 提交，GET {base_url}/v1/videos/{id} 查询，
+// 生成的视频需携带密钥通过 GET {base_url}/v1/videos/{id}/content 下载
+// https://platform.openai.com/docs/api-reference/videos
+type TaskAdaptor struct{}
+
+type videoResponse struct {
+	Id       string `json:"id"`
+	Status   string `json:"status"`
+	Progress int    `json:"progress"`
+	Seconds  string `json:"seconds"`
+	Size     string `json:"size"`
+	Error    *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *TaskAdaptor) SubmitAsyncTask(c *gin.Context, info *relaycommon.RelayInfo, request *dto.AsyncTaskRequest) (string, *dto.TaskError) {
+	var videoRequest dto.VideoGenerationRequest
+	if err := json.Unmarshal(request.Input, &videoRequest); err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	if videoRequest.Image != "" {
+		return "", service.TaskErrorWrapperLocal(errors.New("Sora 暂不支持参考图"), "invalid_request", http.StatusBadRequest)
+	}
+	width, height := videoRequest.Dimensions()
+	body := map[string]interface{}{
+		"model":   info.UpstreamModelName,
+		"prompt":  videoRequest.Prompt,
+		"seconds": strconv.Itoa(videoRequest.Seconds),
+		"size":    fmt.Sprintf("%dx%d", width, height),
+	}
+	for k, v := range videoRequest.Extra {
+		body[k] = v
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(info.BaseUrl, "/")+"/v1/videos", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	responseBody, taskErr := channel.DoAsyncTaskRequest(c, info, req)
+	if taskErr != nil {
+		return "", taskErr
+	}
+	var video videoResponse
+	if err := json.Unmarshal(responseBody, &video); err != nil {
+		return "", service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if video.Id == "" {
+		return "", service.TaskErrorWrapper(errors.New("上游未返回任务 ID"), "invalid_upstream_response", http.StatusInternalServerError)
+	}
+	return video.Id, nil
+}
+
+func (a *TaskAdaptor) FetchAsyncTask(baseUrl string, key string, upstreamTaskId string) (*dto.AsyncTaskResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(baseUrl, "/")+"/v1/videos/"+url.PathEscape(upstreamTaskId), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	responseBody, err := channel.DoAsyncTaskFetch(req)
+	if err != nil {
+		return nil, err
+	}
+	var video videoResponse
+	if err := json.Unmarshal(responseBody, &video); err != nil {
+		return nil, err
+	}
+	response := &dto.AsyncTaskResponse{
+		Id:       video.Id,
+		Progress: video.Progress,
+	}
+	switch video.Status {
+	case "completed":
+		response.Status = "succeeded"
+		seconds, _ := strconv.ParseFloat(video.Seconds, 64)
+		response.Units = seconds
+		response.Result, _ = json.Marshal(dto.VideoGenerationResult{
+			Videos: []dto.VideoResultItem{{Seconds: seconds}},
+		})
+	case "failed":
+		response.Status = "failed"
+		if video.Error != nil {
+			response.FailReason = video.Error.Message
+		}
+	case "in_progress":
+		response.Status = "in_progress"
+	default:
+		response.Status = "queued"
+	}
+	return response, nil
+}
+
+func (a *TaskAdaptor) FetchVideoContent(ctx context.Context, baseUrl string, key string, upstreamTaskId string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseUrl, "/")+"/v1/videos/"+url.PathEscape(upstreamTaskId)+"/content", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	return service.GetHttpClient().Do(req)
+}
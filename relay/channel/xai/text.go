@@ -56,6 +56,7 @@ func xAIStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 			usage.PromptTokens = xAIResp.Usage.PromptTokens
 			usage.TotalTokens = xAIResp.Usage.TotalTokens
 			usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+			usage.NumSourcesUsed = xAIResp.Usage.NumSourcesUsed
 		}
 
 		openaiResponse := streamResponseXAI2OpenAI(xAIResp, usage)
@@ -66,11 +67,17 @@ func xAIStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		}
 		return true
 	})
+	if openaiErr := service.StreamFailoverError(info); openaiErr != nil {
+		return openaiErr, nil
+	}
 
 	if !containStreamUsage {
 		usage, _ = service.ResponseText2Usage(responseTextBuilder.String(), info.UpstreamModelName, info.PromptTokens)
 		usage.CompletionTokens += toolCount * 7
 	}
+	if usage.NumSourcesUsed > 0 {
+		info.AddBuiltInToolCall(dto.BuildInToolXAILiveSearch, usage.NumSourcesUsed)
+	}
 
 	helper.Done(c)
 	err := resp.Body.Close()
@@ -91,6 +98,10 @@ func xAIHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo
 	}
 	response.Usage.CompletionTokens = response.Usage.TotalTokens - response.Usage.PromptTokens
 	response.Usage.CompletionTokenDetails.TextTokens = response.Usage.CompletionTokens - response.Usage.CompletionTokenDetails.ReasoningTokens
+	// Live Search 按实际使用的来源数计费
+	if response.Usage.NumSourcesUsed > 0 {
+		info.AddBuiltInToolCall(dto.BuildInToolXAILiveSearch, response.Usage.NumSourcesUsed)
+	}
 
 	// new body
 	encodeJson, err := common.EncodeJson(response)
@@ -60,6 +60,7 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if request.TopP >= 1 {
 		request.TopP = 0.99
 	}
+	info.EmulateParallelToolCalls(request)
 	return requestOpenAI2Zhipu(*request), nil
 }
 
@@ -10,10 +10,12 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/relay/channel/claude"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
 	"strings"
 )
 
@@ -46,12 +48,30 @@ func ClaudeHelper(c *gin.Context) (claudeError *dto.ClaudeErrorWithStatusCode) {
 		relayInfo.IsStream = true
 	}
 
+	// 强制系统提示词需在 token 计数之前应用，使其同样参与计费
+	service.EnforceGroupSystemPromptClaude(relayInfo.Group, textRequest)
+
+	// 脱敏在内容审核之前进行，审核服务同样不会收到原始个人信息
+	if operation_setting.ShouldRedactPII(relayInfo.Group) {
+		service.RedactPIIClaudeRequest(c, textRequest)
+	}
+	// 流式响应不支持还原占位符，仅非流式响应按配置还原
+	piiRedactor := service.GetPIIRedactor(c)
+	restorePII := piiRedactor != nil && operation_setting.GetPIIRedactionSetting().RestoreResponse
+
+	if operation_setting.ShouldModerate(relayInfo.Group) {
+		if openaiErr := moderateRequestText(c, claudeRequestText(textRequest), relayInfo); openaiErr != nil {
+			return service.OpenAIErrorToClaudeError(openaiErr)
+		}
+	}
+
 	err = helper.ModelMappedHelper(c, relayInfo)
 	if err != nil {
 		return service.ClaudeErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
 	}
 
 	textRequest.Model = relayInfo.UpstreamModelName
+	service.ClampMaxTokens(relayInfo.Group, relayInfo.OriginModelName, &textRequest.MaxTokens)
 
 	promptTokens, err := getClaudePromptTokens(textRequest, relayInfo)
 	// count messages token error 计算promptTokens错误
@@ -140,7 +160,14 @@ func ClaudeHelper(c *gin.Context) (claudeError *dto.ClaudeErrorWithStatusCode) {
 		}
 	}
 
+	var finishPIIRestore func()
+	if restorePII && !relayInfo.IsStream {
+		finishPIIRestore = startPIIRestore(c, piiRedactor)
+	}
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	if finishPIIRestore != nil {
+		finishPIIRestore()
+	}
 	//log.Printf("usage: %v", usage)
 	if openaiErr != nil {
 		// reset status code 重置状态码
@@ -151,6 +178,35 @@ func ClaudeHelper(c *gin.Context) (claudeError *dto.ClaudeErrorWithStatusCode) {
 	return nil
 }
 
+// claudeRequestText 拼接 Claude 请求中系统提示词与消息的文本内容
+func claudeRequestText(textRequest *dto.ClaudeRequest) string {
+	var builder strings.Builder
+	if textRequest.IsStringSystem() {
+		builder.WriteString(textRequest.GetStringSystem())
+		builder.WriteString("\n")
+	} else {
+		for _, block := range textRequest.ParseSystem() {
+			builder.WriteString(block.GetText())
+			builder.WriteString("\n")
+		}
+	}
+	for _, message := range textRequest.Messages {
+		if message.IsStringContent() {
+			builder.WriteString(message.GetStringContent())
+			builder.WriteString("\n")
+			continue
+		}
+		contents, _ := message.ParseContent()
+		for _, content := range contents {
+			if content.Type == "text" {
+				builder.WriteString(content.GetText())
+				builder.WriteString("\n")
+			}
+		}
+	}
+	return builder.String()
+}
+
 func getClaudePromptTokens(textRequest *dto.ClaudeRequest, info *relaycommon.RelayInfo) (int, error) {
 	var promptTokens int
 	var err error
@@ -161,3 +217,52 @@ func getClaudePromptTokens(textRequest *dto.ClaudeRequest, info *relaycommon.Rel
 	info.PromptTokens = promptTokens
 	return promptTokens, err
 }
+
+// ClaudeCountTokens 处理 Anthropic /v1/messages/count_tokens：Anthropic 渠道转发官方接口，
+// 其他渠道或上游不支持该接口时使用本地估算，不消耗额度
+func ClaudeCountTokens(c *gin.Context) (*dto.ClaudeCountTokensResponse, *dto.ClaudeErrorWithStatusCode) {
+	var request dto.ClaudeRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return nil, service.ClaudeErrorWrapperLocal(err, "invalid_claude_request", http.StatusBadRequest)
+	}
+	if len(request.Messages) == 0 {
+		return nil, service.ClaudeErrorWrapperLocal(errors.New("field messages is required"), "invalid_claude_request", http.StatusBadRequest)
+	}
+	if request.Model == "" {
+		return nil, service.ClaudeErrorWrapperLocal(errors.New("field model is required"), "invalid_claude_request", http.StatusBadRequest)
+	}
+
+	relayInfo := relaycommon.GenRelayInfoClaude(c)
+	if err := helper.ModelMappedHelper(c, relayInfo); err != nil {
+		return nil, service.ClaudeErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
+	}
+
+	if relayInfo.ChannelType == common.ChannelTypeAnthropic {
+		resp, err := claude.DoCountTokensRequest(c, relayInfo, &request)
+		if err != nil {
+			common.LogWarn(c, "count tokens request failed, fallback to local estimate: "+err.Error())
+		} else {
+			defer resp.Body.Close()
+			switch {
+			case resp.StatusCode == http.StatusOK:
+				var response dto.ClaudeCountTokensResponse
+				if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+					return nil, service.ClaudeErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+				}
+				return &response, nil
+			case resp.StatusCode == http.StatusBadRequest:
+				// 请求本身有误时返回上游错误，与直连 Anthropic 的行为一致
+				return nil, service.OpenAIErrorToClaudeError(service.RelayErrorHandler(resp, false))
+			default:
+				// 兼容 Anthropic 格式但未实现 count_tokens 的上游返回 404 等，回退到本地估算
+				common.LogWarn(c, fmt.Sprintf("count tokens returned status code %d, fallback to local estimate", resp.StatusCode))
+			}
+		}
+	}
+
+	tokens, err := service.CountTokenClaudeRequest(request, relayInfo.UpstreamModelName)
+	if err != nil {
+		return nil, service.ClaudeErrorWrapperLocal(err, "count_token_messages_failed", http.StatusBadRequest)
+	}
+	return &dto.ClaudeCountTokensResponse{InputTokens: tokens}, nil
+}
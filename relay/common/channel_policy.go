@@ -0,0 +1,56 @@
+package common
+
+import (
+	"one-api/constant"
+	"time"
+)
+
+// ChannelPolicy 渠道级别的超时与重试配置，未配置的项为零值（MaxRetries 为 -1），使用全局配置
+type ChannelPolicy struct {
+	ConnectTimeout    time.Duration
+	FirstByteTimeout  time.Duration
+	FirstTokenTimeout time.Duration // 流式请求等待首个数据块的超时，不影响 HTTP 客户端
+	TotalTimeout      time.Duration
+	MaxRetries        int
+}
+
+func (p ChannelPolicy) HasTimeout() bool {
+	return p.ConnectTimeout > 0 || p.FirstByteTimeout > 0 || p.TotalTimeout > 0
+}
+
+func applyChannelPolicy(policy *ChannelPolicy, setting map[string]interface{}) {
+	if v, ok := setting[constant.ChannelSettingConnectTimeout].(float64); ok && v > 0 {
+		policy.ConnectTimeout = time.Duration(v * float64(time.Second))
+	}
+	if v, ok := setting[constant.ChannelSettingFirstByteTimeout].(float64); ok && v > 0 {
+		policy.FirstByteTimeout = time.Duration(v * float64(time.Second))
+	}
+	if v, ok := setting[constant.ChannelSettingFirstTokenTimeout].(float64); ok && v > 0 {
+		policy.FirstTokenTimeout = time.Duration(v * float64(time.Second))
+	}
+	if v, ok := setting[constant.ChannelSettingTotalTimeout].(float64); ok && v > 0 {
+		policy.TotalTimeout = time.Duration(v * float64(time.Second))
+	}
+	if v, ok := setting[constant.ChannelSettingMaxRetries].(float64); ok && v >= 0 {
+		policy.MaxRetries = int(v)
+	}
+}
+
+// GetChannelPolicy 解析渠道设置中的超时与重试配置，model_policies 中对应模型的配置优先
+func GetChannelPolicy(channelSetting map[string]interface{}, modelName string) ChannelPolicy {
+	policy := ChannelPolicy{MaxRetries: -1}
+	if channelSetting == nil {
+		return policy
+	}
+	applyChannelPolicy(&policy, channelSetting)
+	if modelPolicies, ok := channelSetting[constant.ChannelSettingModelPolicies].(map[string]interface{}); ok {
+		if modelPolicy, ok := modelPolicies[modelName].(map[string]interface{}); ok {
+			applyChannelPolicy(&policy, modelPolicy)
+		}
+	}
+	return policy
+}
+
+func (info *RelayInfo) GetChannelPolicy() ChannelPolicy {
+	return GetChannelPolicy(info.ChannelSetting, info.OriginModelName)
+}
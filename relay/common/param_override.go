@@ -0,0 +1,190 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道参数覆盖中的 operations 键用于声明带条件的覆盖操作，其余键保持原有的直接赋值语义，例如：
+//
+//	{
+//	  "temperature": 0.2,
+//	  "operations": [
+//	    {"path": "logit_bias", "mode": "delete"},
+//	    {"path": "max_tokens", "value": 4096, "condition": "missing"},
+//	    {"path": "reasoning.effort", "value": "high", "models": ["o3*"]},
+//	    {"path": "metadata.user", "value": "{{user_id}}-{{token_name}}"}
+//	  ]
+//	}
+//
+// 字符串值（包括嵌套对象与数组中的字符串）可引用 TemplateVariables 中的变量
+const ParamOverrideOperationsKey = "operations"
+
+const (
+	ParamOverrideModeSet    = "set"
+	ParamOverrideModeDelete = "delete"
+)
+
+const (
+	ParamOverrideConditionMissing = "missing"
+	ParamOverrideConditionExists  = "exists"
+)
+
+// ParamOverrideOperation path 以 . 分隔表示嵌套字段；models 为空时对所有模型生效，支持 * 结尾的前缀匹配
+type ParamOverrideOperation struct {
+	Path      string      `json:"path"`
+	Mode      string      `json:"mode,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+	Condition string      `json:"condition,omitempty"`
+	Models    []string    `json:"models,omitempty"`
+}
+
+func parseParamOverrideOperations(value interface{}) ([]ParamOverrideOperation, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var operations []ParamOverrideOperation
+	if err = json.Unmarshal(data, &operations); err != nil {
+		return nil, fmt.Errorf("invalid param override operations: %w", err)
+	}
+	for i, operation := range operations {
+		if operation.Path == "" {
+			return nil, fmt.Errorf("param override operation %d: path is required", i)
+		}
+		switch operation.Mode {
+		case "", ParamOverrideModeSet, ParamOverrideModeDelete:
+		default:
+			return nil, fmt.Errorf("param override operation %d: unknown mode %s", i, operation.Mode)
+		}
+		switch operation.Condition {
+		case "", ParamOverrideConditionMissing, ParamOverrideConditionExists:
+		default:
+			return nil, fmt.Errorf("param override operation %d: unknown condition %s", i, operation.Condition)
+		}
+	}
+	return operations, nil
+}
+
+func matchParamOverrideModel(patterns []string, modelName string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(modelName, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupParamParent 返回 path 最后一级所在的对象，create 为 true 时补齐缺失的中间对象
+func lookupParamParent(reqMap map[string]interface{}, path string, create bool) (map[string]interface{}, string) {
+	keys := strings.Split(path, ".")
+	parent := reqMap
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := parent[key].(map[string]interface{})
+		if !ok {
+			if !create {
+				return nil, ""
+			}
+			child = make(map[string]interface{})
+			parent[key] = child
+		}
+		parent = child
+	}
+	return parent, keys[len(keys)-1]
+}
+
+// RenderParamOverrideValue 渲染值中的模板变量，返回新值，不修改渠道配置本身
+func RenderParamOverrideValue(value interface{}, variables map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return RenderTemplate(v, variables)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered[key] = RenderParamOverrideValue(item, variables)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered[i] = RenderParamOverrideValue(item, variables)
+		}
+		return rendered
+	}
+	return value
+}
+
+func applyParamOverrideOperation(reqMap map[string]interface{}, operation ParamOverrideOperation, variables map[string]string) {
+	parent, key := lookupParamParent(reqMap, operation.Path, false)
+	exists := false
+	if parent != nil {
+		_, exists = parent[key]
+	}
+	if operation.Condition == ParamOverrideConditionMissing && exists {
+		return
+	}
+	if operation.Condition == ParamOverrideConditionExists && !exists {
+		return
+	}
+	if operation.Mode == ParamOverrideModeDelete {
+		if exists {
+			delete(parent, key)
+		}
+		return
+	}
+	parent, key = lookupParamParent(reqMap, operation.Path, true)
+	parent[key] = RenderParamOverrideValue(operation.Value, variables)
+}
+
+// ValidateParamOverride 保存渠道时校验 operations 的格式，避免配置错误到请求时才暴露
+func ValidateParamOverride(paramOverride map[string]interface{}) error {
+	value, ok := paramOverride[ParamOverrideOperationsKey].([]interface{})
+	if !ok {
+		return nil
+	}
+	_, err := parseParamOverrideOperations(value)
+	return err
+}
+
+// ApplyParamOverride 将渠道参数覆盖应用到上游请求体：先按原有语义直接赋值，再按顺序执行 operations
+func ApplyParamOverride(c *gin.Context, info *RelayInfo, jsonData []byte) ([]byte, error) {
+	if len(info.ParamOverride) == 0 {
+		return jsonData, nil
+	}
+	var operations []ParamOverrideOperation
+	value, hasOperations := info.ParamOverride[ParamOverrideOperationsKey].([]interface{})
+	if hasOperations {
+		var err error
+		operations, err = parseParamOverrideOperations(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reqMap := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &reqMap); err != nil {
+		return nil, err
+	}
+	variables := info.TemplateVariables(c)
+	for key, value := range info.ParamOverride {
+		if key == ParamOverrideOperationsKey && hasOperations {
+			continue
+		}
+		reqMap[key] = RenderParamOverrideValue(value, variables)
+	}
+	for _, operation := range operations {
+		if matchParamOverrideModel(operation.Models, info.UpstreamModelName) {
+			applyParamOverrideOperation(reqMap, operation, variables)
+		}
+	}
+	return json.Marshal(reqMap)
+}
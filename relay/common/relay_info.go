@@ -1,11 +1,15 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
 	relayconstant "one-api/relay/constant"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -54,6 +58,38 @@ type ResponsesUsageInfo struct {
 	BuiltInTools map[string]*BuildInToolInfo
 }
 
+// AddAudioInputSeconds 累计输入音频时长，实时会话中由转发协程并发调用，按微秒原子累加
+func (info *RelayInfo) AddAudioInputSeconds(seconds float64) {
+	atomic.AddInt64(&info.audioInputMicros, int64(seconds*1e6))
+}
+
+// AddAudioOutputSeconds 累计输出音频时长
+func (info *RelayInfo) AddAudioOutputSeconds(seconds float64) {
+	atomic.AddInt64(&info.audioOutputMicros, int64(seconds*1e6))
+}
+
+// TakeAudioSeconds 取出自上次计费以来累计的音频时长并清零
+func (info *RelayInfo) TakeAudioSeconds() (float64, float64) {
+	input := atomic.SwapInt64(&info.audioInputMicros, 0)
+	output := atomic.SwapInt64(&info.audioOutputMicros, 0)
+	return float64(input) / 1e6, float64(output) / 1e6
+}
+
+// AddBuiltInToolCall 记录内置工具的调用次数，用于按次附加计费，非 responses 请求按需初始化
+func (info *RelayInfo) AddBuiltInToolCall(toolName string, count int) {
+	if info.ResponsesUsageInfo == nil {
+		info.ResponsesUsageInfo = &ResponsesUsageInfo{
+			BuiltInTools: make(map[string]*BuildInToolInfo),
+		}
+	}
+	tool, ok := info.ResponsesUsageInfo.BuiltInTools[toolName]
+	if !ok {
+		tool = &BuildInToolInfo{ToolName: toolName}
+		info.ResponsesUsageInfo.BuiltInTools[toolName] = tool
+	}
+	tool.CallCount += count
+}
+
 type RelayInfo struct {
 	ChannelType       int
 	ChannelId         int
@@ -62,6 +98,8 @@ type RelayInfo struct {
 	UserId            int
 	Group             string
 	TokenUnlimited    bool
+	OrganizationId    int  // 组织令牌所属的组织，消耗该组织的额度池
+	SkipRemoteMedia   bool // 计数时不下载远程图片，用于不请求上游的接口
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -90,10 +128,24 @@ type RelayInfo struct {
 	RealtimeTools        []dto.RealTimeTool
 	IsFirstRequest       bool
 	AudioUsage           bool
+	IsBatch              bool    // 批处理请求，按模型批处理倍率计费
+	BatchRatio           float64 // 实际生效的批处理倍率
+	PriceCurrency        string  // 按次计费时模型价格配置所用的币种
+	ServiceTier          string  // 上游响应中实际使用的 service_tier，按其价格倍率计费
+	audioInputMicros     int64   // 尚未计费的输入音频时长（微秒），通过 AddAudioInputSeconds 原子累加
+	audioOutputMicros    int64   // 尚未计费的输出音频时长（微秒）
+	StreamFailure        string  // 向客户端输出任何内容前上游出错的原因，非空时切换渠道重试
 	ReasoningEffort      string
+	SingleToolCall       bool // parallel_tool_calls 为 false 且上游不支持该参数时，由网关只保留第一个工具调用
+	ClientDisconnected   bool // 流式输出过程中客户端断开，上游已被取消，按已输出的内容计费
+	FirstTokenTimedOut   bool // 超过首个数据块超时仍未收到上游输出，上游已被取消
 	ChannelSetting       map[string]interface{}
 	ParamOverride        map[string]interface{}
 	UserSetting          map[string]interface{}
+	TokenSetting         map[string]interface{}
+	ModelQuotaReserved   int      // 按模型额度限制预留的额度，结算或失败时释放
+	ModelQuotaKeys       []string // 预留时所在周期的计数器，结算计入同一周期，避免跨周期的请求计入下一周期
+	PackageAllowanceHeld int      // 预扣费时从套餐模型赠送额度中预扣的部分，结算时多退少补
 	UserEmail            string
 	UserQuota            int
 	RelayFormat          string
@@ -173,6 +225,11 @@ func GenRelayInfoResponses(c *gin.Context, req *dto.OpenAIResponsesRequest) *Rel
 	return info
 }
 
+// IsBatchRequest 仅由服务端批处理入口或后台任务标记的请求按批处理价格计费，不信任客户端传入的请求头
+func IsBatchRequest(c *gin.Context) bool {
+	return c.GetBool(constant.ContextKeyBatchRequest)
+}
+
 func GenRelayInfo(c *gin.Context) *RelayInfo {
 	channelType := c.GetInt("channel_type")
 	channelId := c.GetInt("channel_id")
@@ -192,6 +249,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	info := &RelayInfo{
 		UserQuota:         c.GetInt(constant.ContextKeyUserQuota),
 		UserSetting:       c.GetStringMap(constant.ContextKeyUserSetting),
+		TokenSetting:      c.GetStringMap(constant.ContextKeyTokenSetting),
 		UserEmail:         c.GetString(constant.ContextKeyUserEmail),
 		isFirstResponse:   true,
 		RelayMode:         relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -204,6 +262,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		UserId:            userId,
 		Group:             group,
 		TokenUnlimited:    tokenUnlimited,
+		OrganizationId:    c.GetInt(constant.ContextKeyOrganizationId),
 		StartTime:         startTime,
 		FirstResponseTime: startTime.Add(-time.Second),
 		OriginModelName:   c.GetString("original_model"),
@@ -222,6 +281,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 			SendLastThinkingContent: false,
 		},
 	}
+	info.IsBatch = IsBatchRequest(c)
 	if strings.HasPrefix(c.Request.URL.Path, "/pg") {
 		info.IsPlayground = true
 		info.RequestURLPath = strings.TrimPrefix(info.RequestURLPath, "/pg")
@@ -258,6 +318,9 @@ func (info *RelayInfo) SetFirstResponseTime() {
 	if info.isFirstResponse {
 		info.FirstResponseTime = time.Now()
 		info.isFirstResponse = false
+		if info.IsStream {
+			common.MetricStreamTTFB(info.ChannelId, info.OriginModelName, info.FirstResponseTime.Sub(info.StartTime))
+		}
 	}
 }
 
@@ -279,3 +342,18 @@ func GenTaskRelayInfo(c *gin.Context) *TaskRelayInfo {
 	}
 	return info
 }
+
+// EmulateParallelToolCalls 用于不支持 parallel_tool_calls 的上游：移除该参数，为 false 时在响应中只保留第一个工具调用
+func (info *RelayInfo) EmulateParallelToolCalls(request *dto.GeneralOpenAIRequest) {
+	if request.ParallelToolCalls != nil && !*request.ParallelToolCalls && len(request.Tools) > 0 {
+		info.SingleToolCall = true
+	}
+	request.ParallelToolCalls = nil
+}
+
+// SystemFingerprint 为不返回 system_fingerprint 的上游生成稳定的指纹，渠道或实际模型变化时随之变化，
+// 便于对结果可复现性敏感的客户端感知后端变更
+func (info *RelayInfo) SystemFingerprint() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", info.ChannelId, info.UpstreamModelName)))
+	return "fp_" + hex.EncodeToString(sum[:])[:10]
+}
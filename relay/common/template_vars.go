@@ -0,0 +1,40 @@
+package common
+
+import (
+	"one-api/common"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TemplateVariables 渠道请求头、参数覆盖与提示词模板中可引用的请求上下文变量
+func (info *RelayInfo) TemplateVariables(c *gin.Context) map[string]string {
+	return map[string]string{
+		"user_id":        strconv.Itoa(info.UserId),
+		"username":       c.GetString("username"),
+		"user_email":     info.UserEmail,
+		"group":          info.Group,
+		"token_id":       strconv.Itoa(info.TokenId),
+		"token_name":     c.GetString("token_name"),
+		"channel_id":     strconv.Itoa(info.ChannelId),
+		"model":          info.OriginModelName,
+		"origin_model":   info.OriginModelName,
+		"upstream_model": info.UpstreamModelName,
+		"request_id":     c.GetString(common.RequestIdKey),
+		"date":           time.Now().Format("2006-01-02"),
+	}
+}
+
+// RenderTemplate 将 text 中的 {{name}} 替换为对应变量，未定义的变量保持原样
+func RenderTemplate(text string, variables map[string]string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	replacements := make([]string, 0, len(variables)*2)
+	for name, value := range variables {
+		replacements = append(replacements, "{{"+name+"}}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
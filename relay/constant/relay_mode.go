@@ -43,6 +43,8 @@ const (
 	RelayModeResponses
 
 	RelayModeRealtime
+
+	RelayModeVideoGenerations
 )
 
 func Path2RelayMode(path string) int {
@@ -75,10 +77,49 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeRerank
 	} else if strings.HasPrefix(path, "/v1/realtime") {
 		relayMode = RelayModeRealtime
+	} else if strings.HasPrefix(path, "/v1/video/generations") {
+		relayMode = RelayModeVideoGenerations
 	}
 	return relayMode
 }
 
+var relayModeNames = map[int]string{
+	RelayModeChatCompletions:    "chat_completions",
+	RelayModeCompletions:        "completions",
+	RelayModeEmbeddings:         "embeddings",
+	RelayModeModerations:        "moderations",
+	RelayModeImagesGenerations:  "images_generations",
+	RelayModeImagesEdits:        "images_edits",
+	RelayModeEdits:              "edits",
+	RelayModeAudioSpeech:        "audio_speech",
+	RelayModeAudioTranscription: "audio_transcription",
+	RelayModeAudioTranslation:   "audio_translation",
+	RelayModeRerank:             "rerank",
+	RelayModeResponses:          "responses",
+	RelayModeRealtime:           "realtime",
+	RelayModeVideoGenerations:   "video_generations",
+
+	RelayModeMidjourneyImagine:      "midjourney_imagine",
+	RelayModeMidjourneyDescribe:     "midjourney_describe",
+	RelayModeMidjourneyBlend:        "midjourney_blend",
+	RelayModeMidjourneyChange:       "midjourney_change",
+	RelayModeMidjourneySimpleChange: "midjourney_simple_change",
+	RelayModeMidjourneyAction:       "midjourney_action",
+	RelayModeMidjourneyModal:        "midjourney_modal",
+	RelayModeMidjourneyShorten:      "midjourney_shorten",
+	RelayModeMidjourneyUpload:       "midjourney_upload",
+	RelayModeSwapFace:               "midjourney_swap_face",
+	RelayModeSunoSubmit:             "suno_submit",
+}
+
+// RelayModeName 返回 relay mode 的名称，用于监控指标等标签
+func RelayModeName(relayMode int) string {
+	if name, ok := relayModeNames[relayMode]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 func Path2RelayModeMidjourney(path string) int {
 	relayMode := RelayModeUnknown
 	if strings.HasSuffix(path, "/mj/submit/action") {
@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/websocket"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 )
 
@@ -51,7 +52,83 @@ func ResponseChunkData(c *gin.Context, resp dto.ResponsesStreamResponse, data st
 	}
 }
 
+// StreamOutputFilter 在写出流式数据前对其进行检查或改写，Filter 返回实际需要写出的数据，
+// Stopped 为 true 时不再写出任何数据并尽快结束读取上游
+type StreamOutputFilter interface {
+	Filter(data string) []string
+	Stopped() bool
+}
+
+// streamOutputFilterChain 按添加顺序依次处理，前一个过滤器的输出作为后一个的输入
+type streamOutputFilterChain []StreamOutputFilter
+
+func (chain streamOutputFilterChain) Filter(data string) []string {
+	outputs := []string{data}
+	for _, filter := range chain {
+		var next []string
+		for _, output := range outputs {
+			next = append(next, filter.Filter(output)...)
+		}
+		outputs = next
+	}
+	return outputs
+}
+
+func (chain streamOutputFilterChain) Stopped() bool {
+	for _, filter := range chain {
+		if filter.Stopped() {
+			return true
+		}
+	}
+	return false
+}
+
+// AddStreamOutputFilter 为当前请求追加流式输出过滤器
+func AddStreamOutputFilter(c *gin.Context, filter StreamOutputFilter) {
+	switch existing := getStreamOutputFilter(c).(type) {
+	case nil:
+		c.Set(constant.ContextKeyStreamOutputFilter, streamOutputFilterChain{filter})
+	case streamOutputFilterChain:
+		c.Set(constant.ContextKeyStreamOutputFilter, append(existing, filter))
+	default:
+		c.Set(constant.ContextKeyStreamOutputFilter, streamOutputFilterChain{existing, filter})
+	}
+}
+
+// ResetStreamOutputFilters 清除当前请求的流式输出过滤器，重试前调用
+func ResetStreamOutputFilters(c *gin.Context) {
+	c.Set(constant.ContextKeyStreamOutputFilter, nil)
+}
+
+func getStreamOutputFilter(c *gin.Context) StreamOutputFilter {
+	filter, _ := c.Value(constant.ContextKeyStreamOutputFilter).(StreamOutputFilter)
+	return filter
+}
+
+// HasStreamOutputFilter 当前请求是否注册了流式输出过滤器
+func HasStreamOutputFilter(c *gin.Context) bool {
+	return getStreamOutputFilter(c) != nil
+}
+
+// IsStreamOutputStopped 输出过滤器已终止输出时返回 true
+func IsStreamOutputStopped(c *gin.Context) bool {
+	filter := getStreamOutputFilter(c)
+	return filter != nil && filter.Stopped()
+}
+
 func StringData(c *gin.Context, str string) error {
+	if filter := getStreamOutputFilter(c); filter != nil {
+		for _, data := range filter.Filter(str) {
+			if err := writeStringData(c, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return writeStringData(c, str)
+}
+
+func writeStringData(c *gin.Context, str string) error {
 	//str = strings.TrimPrefix(str, "data: ")
 	//str = strings.TrimSuffix(str, "\r")
 	c.Render(-1, common.CustomEvent{Data: "data: " + str})
@@ -64,7 +141,7 @@ func StringData(c *gin.Context, str string) error {
 }
 
 func PingData(c *gin.Context) error {
-	c.Writer.Write([]byte(": PING\n\n"))
+	c.Writer.Write([]byte(": ping\n\n"))
 	if flusher, ok := c.Writer.(http.Flusher); ok {
 		flusher.Flush()
 	} else {
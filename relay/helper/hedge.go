@@ -0,0 +1,167 @@
+package helper
+
+import (
+	"errors"
+	"net/http"
+	"one-api/constant"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrHedgeLost = errors.New("hedged request lost the race")
+
+// HedgeRace 协调同一请求的多个对冲副本，第一个向客户端写出内容的副本获胜，其余副本的输出被丢弃
+type HedgeRace struct {
+	writer gin.ResponseWriter
+	winner atomic.Int32
+	won    chan struct{}
+	once   sync.Once
+}
+
+func NewHedgeRace(writer gin.ResponseWriter) *HedgeRace {
+	race := &HedgeRace{
+		writer: writer,
+		won:    make(chan struct{}),
+	}
+	race.winner.Store(-1)
+	return race
+}
+
+// Won 有副本获胜时关闭
+func (r *HedgeRace) Won() <-chan struct{} {
+	return r.won
+}
+
+// Winner 返回获胜副本的序号，尚未决出时返回 -1
+func (r *HedgeRace) Winner() int {
+	return int(r.winner.Load())
+}
+
+func (r *HedgeRace) claim(index int) bool {
+	if r.winner.CompareAndSwap(-1, int32(index)) {
+		r.once.Do(func() { close(r.won) })
+		return true
+	}
+	return r.winner.Load() == int32(index)
+}
+
+// HedgeWriter 在副本获胜前缓存响应头，获胜后直接写入客户端连接
+type HedgeWriter struct {
+	gin.ResponseWriter
+	race   *HedgeRace
+	index  int
+	header http.Header
+	status int
+	// lost 落败后置为 true，供计费逻辑判断
+	lost *atomic.Bool
+}
+
+func NewHedgeWriter(race *HedgeRace, index int) *HedgeWriter {
+	return &HedgeWriter{
+		ResponseWriter: race.writer,
+		race:           race,
+		index:          index,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+		lost:           &atomic.Bool{},
+	}
+}
+
+// Lost 副本落败标记，写入 context 的 constant.ContextKeyHedgeLost
+func (w *HedgeWriter) Lost() *atomic.Bool {
+	return w.lost
+}
+
+func (w *HedgeWriter) isWinner() bool {
+	return w.race.Winner() == w.index
+}
+
+func (w *HedgeWriter) claim() bool {
+	if w.isWinner() {
+		return true
+	}
+	if !w.race.claim(w.index) {
+		w.lost.Store(true)
+		return false
+	}
+	dst := w.race.writer.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.race.writer.WriteHeader(w.status)
+	return true
+}
+
+func (w *HedgeWriter) Header() http.Header {
+	if w.isWinner() {
+		return w.race.writer.Header()
+	}
+	return w.header
+}
+
+func (w *HedgeWriter) WriteHeader(code int) {
+	if code <= 0 {
+		return
+	}
+	if w.isWinner() {
+		w.race.writer.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *HedgeWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.race.writer.WriteHeaderNow()
+	}
+}
+
+func (w *HedgeWriter) Write(data []byte) (int, error) {
+	if !w.claim() {
+		return 0, ErrHedgeLost
+	}
+	return w.race.writer.Write(data)
+}
+
+func (w *HedgeWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, ErrHedgeLost
+	}
+	return w.race.writer.WriteString(s)
+}
+
+func (w *HedgeWriter) Written() bool {
+	return w.isWinner() && w.race.writer.Written()
+}
+
+func (w *HedgeWriter) Status() int {
+	if w.isWinner() {
+		return w.race.writer.Status()
+	}
+	return w.status
+}
+
+func (w *HedgeWriter) Size() int {
+	if w.isWinner() {
+		return w.race.writer.Size()
+	}
+	return -1
+}
+
+func (w *HedgeWriter) Flush() {
+	if w.isWinner() {
+		w.race.writer.Flush()
+	}
+}
+
+// IsHedgeLost 当前请求是否为落败的对冲副本，落败副本不应计费
+func IsHedgeLost(c *gin.Context) bool {
+	if v, ok := c.Get(constant.ContextKeyHedgeLost); ok {
+		if lost, ok := v.(*atomic.Bool); ok {
+			return lost.Load()
+		}
+	}
+	return false
+}
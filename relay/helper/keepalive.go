@@ -0,0 +1,69 @@
+package helper
+
+import (
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PingInterval 返回流式保活的间隔，未开启时返回 false
+func PingInterval() (time.Duration, bool) {
+	generalSettings := operation_setting.GetGeneralSetting()
+	if !generalSettings.PingIntervalEnabled {
+		return 0, false
+	}
+	pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
+	if pingInterval <= 0 {
+		pingInterval = DefaultPingInterval
+	}
+	return pingInterval, true
+}
+
+// IsEventStreamStarted 已向客户端写出 SSE 响应头时返回 true，此时错误只能以流事件的形式返回
+func IsEventStreamStarted(c *gin.Context) bool {
+	return c.Writer.Written() && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream")
+}
+
+// StartResponseKeepAlive 流式请求在等待上游响应头期间按间隔向客户端发送 SSE 注释，避免中间代理因长时间无数据断开连接；
+// 返回的 stop 在拿到上游响应后调用，调用返回后不会再有写入。发送过保活的请求已写出响应头，失败后不再切换渠道重试，
+// 因此需要单独开启，且对冲请求不启用
+func StartResponseKeepAlive(c *gin.Context, info *relaycommon.RelayInfo) (stop func()) {
+	pingInterval, ok := PingInterval()
+	if !ok || !info.IsStream || !operation_setting.GetGeneralSetting().PingBeforeResponseEnabled {
+		return func() {}
+	}
+	if _, hedged := c.Writer.(*HedgeWriter); hedged {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !c.Writer.Written() {
+					SetEventStreamHeaders(c)
+				}
+				if err := PingData(c); err != nil {
+					common.LogError(c, "ping data error: "+err.Error())
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
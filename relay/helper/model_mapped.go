@@ -1,48 +1,156 @@
 package helper
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"one-api/relay/common"
+	"regexp"
+	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 以 regex: 开头的键按正则匹配，包含 * 的键按通配符匹配（每个 * 为一个分组），映射目标可用 $1 等引用分组
+const modelMappingRegexPrefix = "regex:"
+
+type modelMappingRule struct {
+	pattern *regexp.Regexp
+	target  string
+}
+
+// ModelMappingRules 精确匹配优先，其余通配符与正则规则按配置中出现的顺序依次匹配
+type ModelMappingRules struct {
+	exact map[string]string
+	rules []modelMappingRule
+}
+
+// maxModelMappingRulesCacheSize 解析结果缓存的最大条数，渠道映射配置修改后旧配置的缓存不再使用，超出时整体清空
+const maxModelMappingRulesCacheSize = 1024
+
+// maxModelMappingChainDepth 精确映射链的最大长度
+const maxModelMappingChainDepth = 16
+
+var modelMappingRulesCache = make(map[string]*ModelMappingRules) // mapping json -> *ModelMappingRules
+var modelMappingRulesCacheLock sync.RWMutex
+
+func wildcardToRegexp(pattern string) string {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return "^" + strings.Join(parts, "(.*)") + "$"
+}
+
+// ParseModelMapping 解析渠道的模型映射配置，使用 json.Decoder 逐个读取键以保留规则顺序
+func ParseModelMapping(modelMapping string) (*ModelMappingRules, error) {
+	modelMappingRulesCacheLock.RLock()
+	cached, ok := modelMappingRulesCache[modelMapping]
+	modelMappingRulesCacheLock.RUnlock()
+	if ok {
+		return cached, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(modelMapping)))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("model mapping must be a json object")
+	}
+	mappingRules := &ModelMappingRules{exact: make(map[string]string)}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		var target string
+		if err = decoder.Decode(&target); err != nil {
+			return nil, fmt.Errorf("invalid model mapping target for %s: %w", key, err)
+		}
+		switch {
+		case strings.HasPrefix(key, modelMappingRegexPrefix):
+			pattern, err := regexp.Compile(strings.TrimPrefix(key, modelMappingRegexPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid model mapping regex %s: %w", key, err)
+			}
+			mappingRules.rules = append(mappingRules.rules, modelMappingRule{pattern: pattern, target: target})
+		case strings.Contains(key, "*"):
+			pattern := regexp.MustCompile(wildcardToRegexp(key))
+			mappingRules.rules = append(mappingRules.rules, modelMappingRule{pattern: pattern, target: target})
+		default:
+			mappingRules.exact[key] = target
+		}
+	}
+	modelMappingRulesCacheLock.Lock()
+	if len(modelMappingRulesCache) >= maxModelMappingRulesCacheSize {
+		modelMappingRulesCache = make(map[string]*ModelMappingRules)
+	}
+	modelMappingRulesCache[modelMapping] = mappingRules
+	modelMappingRulesCacheLock.Unlock()
+	return mappingRules, nil
+}
+
+// Map 返回 modelName 映射后的模型，未命中任何规则时返回 false
+func (r *ModelMappingRules) Map(modelName string) (string, bool) {
+	target, ok, _ := r.match(modelName)
+	return target, ok
+}
+
+// match 与 Map 相同，额外返回命中的是否为精确映射
+func (r *ModelMappingRules) match(modelName string) (string, bool, bool) {
+	if target, ok := r.exact[modelName]; ok && target != "" {
+		return target, true, true
+	}
+	for _, rule := range r.rules {
+		match := rule.pattern.FindStringSubmatchIndex(modelName)
+		if match == nil || rule.target == "" {
+			continue
+		}
+		return string(rule.pattern.ExpandString(nil, rule.target, modelName, match)), true, false
+	}
+	return "", false, false
+}
+
 func ModelMappedHelper(c *gin.Context, info *common.RelayInfo) error {
 	// map model name
 	modelMapping := c.GetString("model_mapping")
 	if modelMapping != "" && modelMapping != "{}" {
-		modelMap := make(map[string]string)
-		err := json.Unmarshal([]byte(modelMapping), &modelMap)
+		modelMap, err := ParseModelMapping(modelMapping)
 		if err != nil {
 			return fmt.Errorf("unmarshal_model_mapping_failed")
 		}
 
-		// 支持链式模型重定向，最终使用链尾的模型
+		// 精确映射支持链式重定向，最终使用链尾的模型；通配符与正则规则只应用一次，
+		// 避免 "gpt-*": "gpt-$1-x" 这类目标仍能命中自身的规则无限展开
 		currentModel := info.OriginModelName
 		visitedModels := map[string]bool{
 			currentModel: true,
 		}
-		for {
-			if mappedModel, exists := modelMap[currentModel]; exists && mappedModel != "" {
-				// 模型重定向循环检测，避免无限循环
-				if visitedModels[mappedModel] {
-					if mappedModel == currentModel {
-						if currentModel == info.OriginModelName {
-							info.IsModelMapped = false
-							return nil
-						} else {
-							info.IsModelMapped = true
-							break
-						}
+		for depth := 0; ; depth++ {
+			if depth >= maxModelMappingChainDepth {
+				return errors.New("model_mapping_chain_too_long")
+			}
+			mappedModel, exists, exact := modelMap.match(currentModel)
+			if !exists {
+				break
+			}
+			// 模型重定向循环检测，避免无限循环
+			if visitedModels[mappedModel] {
+				if mappedModel == currentModel {
+					if currentModel == info.OriginModelName {
+						info.IsModelMapped = false
+						return nil
+					} else {
+						info.IsModelMapped = true
+						break
 					}
-					return errors.New("model_mapping_contains_cycle")
 				}
-				visitedModels[mappedModel] = true
-				currentModel = mappedModel
-				info.IsModelMapped = true
-			} else {
+				return errors.New("model_mapping_contains_cycle")
+			}
+			visitedModels[mappedModel] = true
+			currentModel = mappedModel
+			info.IsModelMapped = true
+			if !exact {
 				break
 			}
 		}
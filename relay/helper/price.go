@@ -19,6 +19,7 @@ type PriceData struct {
 	ImageRatio             float64
 	GroupRatio             float64
 	UsePrice               bool
+	PriceTier              int // 命中的阶梯价格阈值，0 表示未命中
 	ShouldPreConsumedQuota int
 }
 
@@ -28,6 +29,10 @@ func (p PriceData) ToSetting() string {
 
 func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int, maxTokens int) (PriceData, error) {
 	modelPrice, usePrice := operation_setting.GetModelPrice(info.OriginModelName, false)
+	if usePrice {
+		// 记录计价所用模型的价格币种，日志据此换算原始币种价格
+		info.PriceCurrency = operation_setting.GetModelPriceCurrency(info.OriginModelName)
+	}
 	groupRatio := setting.GetGroupRatio(info.Group)
 	var preConsumedQuota int
 	var modelRatio float64
@@ -35,11 +40,12 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var cacheRatio float64
 	var imageRatio float64
 	var cacheCreationRatio float64
+	var priceTier int
+	preConsumedTokens := common.PreConsumedQuota
+	if maxTokens != 0 {
+		preConsumedTokens = promptTokens + maxTokens
+	}
 	if !usePrice {
-		preConsumedTokens := common.PreConsumedQuota
-		if maxTokens != 0 {
-			preConsumedTokens = promptTokens + maxTokens
-		}
 		var success bool
 		modelRatio, success = operation_setting.GetModelRatio(info.OriginModelName)
 		if !success {
@@ -58,6 +64,26 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		cacheRatio, _ = operation_setting.GetCacheRatio(info.OriginModelName)
 		cacheCreationRatio, _ = operation_setting.GetCreateCacheRatio(info.OriginModelName)
 		imageRatio, _ = operation_setting.GetImageRatio(info.OriginModelName)
+		// 长上下文阶梯价格，按本次请求计算出的提示词 token 数选择
+		if tier, ok := operation_setting.GetPriceTier(info.OriginModelName, promptTokens); ok {
+			modelRatio = tier.ModelRatio
+			if tier.CompletionRatio > 0 {
+				completionRatio = tier.CompletionRatio
+			}
+			priceTier = tier.MinPromptTokens
+		}
+	}
+	// 批处理请求按模型批处理倍率折算模型倍率与价格
+	if info.IsBatch {
+		batchRatio, _ := operation_setting.GetBatchRatio(info.OriginModelName)
+		info.BatchRatio = batchRatio
+		if usePrice {
+			modelPrice *= batchRatio
+		} else {
+			modelRatio *= batchRatio
+		}
+	}
+	if !usePrice {
 		ratio := modelRatio * groupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
@@ -73,6 +99,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		CacheRatio:             cacheRatio,
 		ImageRatio:             imageRatio,
 		CacheCreationRatio:     cacheCreationRatio,
+		PriceTier:              priceTier,
 		ShouldPreConsumedQuota: preConsumedQuota,
 	}
 
@@ -0,0 +1,190 @@
+package helper
+
+import (
+	"bytes"
+	"net/http"
+	"one-api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamHoldWriter 在收到首个内容之前缓存流式输出（包括只含 role 的首个分块与保活），
+// 此时客户端尚未收到任何数据，上游出错可以丢弃缓存并切换渠道重试
+type StreamHoldWriter struct {
+	gin.ResponseWriter
+	header  http.Header // 开始缓存时的响应头，丢弃缓存时恢复
+	status  int
+	buffer  bytes.Buffer
+	holding bool
+}
+
+func NewStreamHoldWriter(writer gin.ResponseWriter) *StreamHoldWriter {
+	return &StreamHoldWriter{
+		ResponseWriter: writer,
+		header:         writer.Header().Clone(),
+		status:         http.StatusOK,
+		holding:        true,
+	}
+}
+
+func (w *StreamHoldWriter) Holding() bool {
+	return w.holding
+}
+
+// Release 将缓存的内容写给客户端，之后的写入直接透传
+func (w *StreamHoldWriter) Release() {
+	if !w.holding {
+		return
+	}
+	w.holding = false
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buffer.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Discard 丢弃缓存的内容并恢复响应头，客户端不会察觉本次上游请求
+func (w *StreamHoldWriter) Discard() {
+	w.holding = false
+	w.buffer.Reset()
+	header := w.ResponseWriter.Header()
+	for k := range header {
+		delete(header, k)
+	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+}
+
+func (w *StreamHoldWriter) WriteHeader(code int) {
+	if !w.holding {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *StreamHoldWriter) WriteHeaderNow() {
+	if !w.holding {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *StreamHoldWriter) Write(data []byte) (int, error) {
+	if !w.holding {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buffer.Write(data)
+}
+
+func (w *StreamHoldWriter) WriteString(s string) (int, error) {
+	if !w.holding {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.buffer.WriteString(s)
+}
+
+func (w *StreamHoldWriter) Written() bool {
+	return !w.holding && w.ResponseWriter.Written()
+}
+
+func (w *StreamHoldWriter) Status() int {
+	if !w.holding {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *StreamHoldWriter) Size() int {
+	if !w.holding {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *StreamHoldWriter) Flush() {
+	if !w.holding {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// isStreamErrorChunk 上游在流中返回的错误，如 {"error": {...}} 或 Claude 的 {"type": "error", ...}
+func isStreamErrorChunk(data string) bool {
+	var chunk map[string]any
+	if err := common.DecodeJsonStr(data, &chunk); err != nil {
+		return false
+	}
+	if chunk["error"] != nil {
+		return true
+	}
+	chunkType, _ := chunk["type"].(string)
+	return chunkType == "error"
+}
+
+// 值为非空字符串即视为已开始输出内容的字段，覆盖 OpenAI、Claude、Gemini 与 Responses 的流式格式
+var streamTextKeys = map[string]bool{
+	"content":           true,
+	"text":              true,
+	"reasoning_content": true,
+	"reasoning":         true,
+	"thinking":          true,
+	"partial_json":      true,
+	"arguments":         true,
+	"delta":             true,
+}
+
+// 出现即视为已开始输出内容的字段，工具调用的首个分块中参数可能为空
+var streamToolCallKeys = map[string]bool{
+	"tool_calls":   true,
+	"functionCall": true,
+}
+
+func isEmptyContainer(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func hasStreamContentValue(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if text, ok := item.(string); ok && streamTextKeys[key] && text != "" {
+				return true
+			}
+			if streamToolCallKeys[key] && !isEmptyContainer(item) {
+				return true
+			}
+			if hasStreamContentValue(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if hasStreamContentValue(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasStreamContent 上游分块中是否包含面向客户端的内容，只含 role、id、usage 等元数据的分块不算
+func hasStreamContent(data string) bool {
+	var chunk any
+	if err := common.DecodeJsonStr(data, &chunk); err != nil {
+		// 无法解析的分块会原样透传给客户端
+		return true
+	}
+	return hasStreamContentValue(chunk)
+}
@@ -3,6 +3,7 @@ package helper
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"github.com/bytedance/gopkg/util/gopool"
 	"io"
 	"net/http"
@@ -23,6 +24,14 @@ const (
 	DefaultPingInterval      = 10 * time.Second
 )
 
+// firstTokenTimeout 渠道设置优先，其次为全局的重试设置，0 表示不限制
+func firstTokenTimeout(info *relaycommon.RelayInfo) time.Duration {
+	if timeout := info.GetChannelPolicy().FirstTokenTimeout; timeout > 0 {
+		return timeout
+	}
+	return time.Duration(operation_setting.GetRetrySetting().FirstTokenTimeoutSeconds) * time.Second
+}
+
 func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, dataHandler func(data string) bool) {
 
 	if resp == nil || dataHandler == nil {
@@ -45,17 +54,23 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		writeMutex sync.Mutex // Mutex to protect concurrent writes
 	)
 
-	generalSettings := operation_setting.GetGeneralSetting()
-	pingEnabled := generalSettings.PingIntervalEnabled
-	pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
-	if pingInterval <= 0 {
-		pingInterval = DefaultPingInterval
-	}
-
+	pingInterval, pingEnabled := PingInterval()
 	if pingEnabled {
 		pingTicker = time.NewTicker(pingInterval)
 	}
 
+	// 首个数据块超时前未向客户端输出任何内容，超时后可切换渠道重试，因此在首个数据块到达前不发送保活
+	firstData := make(chan struct{})
+	var firstTokenTimer <-chan time.Time
+	if timeout := firstTokenTimeout(info); timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		firstTokenTimer = timer.C
+		if pingTicker != nil {
+			pingTicker.Stop()
+		}
+	}
+
 	defer func() {
 		ticker.Stop()
 		if pingTicker != nil {
@@ -65,6 +80,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 	}()
 	scanner.Buffer(make([]byte, InitialScannerBufferSize), MaxScannerBufferSize)
 	scanner.Split(bufio.ScanLines)
+	holdWriter := holdStreamOutput(c)
 	SetEventStreamHeaders(c)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -79,6 +95,14 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				select {
 				case <-pingTicker.C:
 					writeMutex.Lock() // Lock before writing
+					if ctx.Err() != nil {
+						writeMutex.Unlock()
+						return
+					}
+					if holdWriter != nil {
+						// 需要保活时结束缓存，此后上游出错不再切换渠道
+						holdWriter.Release()
+					}
 					err := PingData(c)
 					writeMutex.Unlock() // Unlock after writing
 					if err != nil {
@@ -99,7 +123,15 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		})
 	}
 
+	var (
+		scanDone    = make(chan struct{})
+		scanErr     error
+		streamError string
+		timedOut    bool
+	)
 	common.RelayCtxGo(ctx, func() {
+		defer close(scanDone)
+		receivedFirst := false
 		for scanner.Scan() {
 			ticker.Reset(streamingTimeout)
 			data := scanner.Text()
@@ -116,18 +148,36 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			data = data[5:]
 			data = strings.TrimLeft(data, " ")
 			data = strings.TrimSuffix(data, "\r")
+			if !receivedFirst {
+				receivedFirst = true
+				close(firstData)
+			}
 			if !strings.HasPrefix(data, "[DONE]") {
 				info.SetFirstResponseTime()
 				writeMutex.Lock() // Lock before writing
+				if holdWriter != nil && holdWriter.Holding() && isStreamErrorChunk(data) {
+					// 尚未输出任何内容时上游返回错误，不再转发，由调用方切换渠道
+					streamError = data
+					writeMutex.Unlock()
+					break
+				}
 				success := dataHandler(data)
+				if holdWriter != nil && holdWriter.Holding() && hasStreamContent(data) {
+					holdWriter.Release()
+				}
 				writeMutex.Unlock() // Unlock after writing
-				if !success {
+				if pingTicker != nil {
+					// 仅在距上次输出超过间隔时发送保活
+					pingTicker.Reset(pingInterval)
+				}
+				if !success || IsStreamOutputStopped(c) {
 					break
 				}
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
+			scanErr = err
 			if err != io.EOF {
 				common.LogError(c, "scanner error: "+err.Error())
 			}
@@ -136,13 +186,83 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		common.SafeSendBool(stopChan, true)
 	})
 
-	select {
-	case <-ticker.C:
-		// 超时处理逻辑
-		common.LogError(c, "streaming timeout")
-		common.SafeSendBool(stopChan, true)
-	case <-stopChan:
-		// 正常结束
-		common.LogInfo(c, "streaming finished")
+	var firstDataC <-chan struct{} = firstData
+	for waiting := true; waiting; {
+		select {
+		case <-firstDataC:
+			firstDataC, firstTokenTimer = nil, nil
+		case <-firstTokenTimer:
+			select {
+			case <-firstDataC:
+				// 首个数据块与超时同时到达，按已收到处理
+				firstDataC, firstTokenTimer = nil, nil
+				continue
+			default:
+			}
+			info.FirstTokenTimedOut = true
+			common.LogError(c, fmt.Sprintf("no data received within first token timeout %s", firstTokenTimeout(info)))
+			waiting = false
+		case <-ticker.C:
+			// 超时处理逻辑
+			common.LogError(c, "streaming timeout")
+			common.SafeSendBool(stopChan, true)
+			timedOut = true
+			waiting = false
+		case <-c.Request.Context().Done():
+			// 客户端断开连接，不再读取上游输出，已收到的内容照常计费
+			info.ClientDisconnected = true
+			common.LogWarn(c, "client disconnected, cancel upstream stream")
+			waiting = false
+		case <-stopChan:
+			// 正常结束
+			common.LogDebug(c, "streaming finished")
+			waiting = false
+		}
+	}
+	// 关闭上游响应体以中断上游生成，并等待读取协程退出，保证后续按已收到的内容计算用量时没有并发写入
+	_ = resp.Body.Close()
+	<-scanDone
+	if holdWriter != nil {
+		cancel()
+		writeMutex.Lock()
+		finishStreamHold(c, info, holdWriter, streamError, scanErr, timedOut)
+		writeMutex.Unlock()
+	}
+}
+
+// holdStreamOutput 开启零输出切换时缓存流式输出直到首个内容，已输出内容或对冲请求不启用
+func holdStreamOutput(c *gin.Context) *StreamHoldWriter {
+	if !operation_setting.GetRetrySetting().StreamFailoverEnabled || c.Writer.Written() {
+		return nil
+	}
+	if _, hedged := c.Writer.(*HedgeWriter); hedged {
+		return nil
+	}
+	holdWriter := NewStreamHoldWriter(c.Writer)
+	c.Writer = holdWriter
+	return holdWriter
+}
+
+// finishStreamHold 流结束时仍未输出任何内容：上游出错、中断或超时则丢弃缓存并记录原因，由调用方切换渠道；
+// 正常结束（如内容本身为空）则照常输出
+func finishStreamHold(c *gin.Context, info *relaycommon.RelayInfo, holdWriter *StreamHoldWriter, streamError string, scanErr error, timedOut bool) {
+	c.Writer = holdWriter.ResponseWriter
+	if !holdWriter.Holding() {
+		return
+	}
+	switch {
+	case info.FirstTokenTimedOut:
+		holdWriter.Discard()
+	case streamError != "":
+		info.StreamFailure = streamError
+		holdWriter.Discard()
+	case timedOut:
+		info.StreamFailure = "streaming timeout"
+		holdWriter.Discard()
+	case scanErr != nil && scanErr != io.EOF && !info.ClientDisconnected:
+		info.StreamFailure = "upstream stream interrupted: " + scanErr.Error()
+		holdWriter.Discard()
+	default:
+		holdWriter.Release()
 	}
 }
@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"encoding/json"
+	"one-api/dto"
+)
+
+// KeepFirstToolCall 只保留每个 choice 的第一个工具调用，返回是否有改动
+func KeepFirstToolCall(response *dto.OpenAITextResponse) bool {
+	changed := false
+	for i := range response.Choices {
+		var toolCalls []json.RawMessage
+		if err := json.Unmarshal(response.Choices[i].Message.ToolCalls, &toolCalls); err != nil || len(toolCalls) <= 1 {
+			continue
+		}
+		response.Choices[i].Message.SetToolCalls(toolCalls[:1])
+		changed = true
+	}
+	return changed
+}
+
+// KeepFirstStreamToolCall 丢弃流式分片中序号大于 0 的工具调用增量，分片因此不再包含任何内容时返回 false，调用方应跳过该分片
+func KeepFirstStreamToolCall(response *dto.ChatCompletionsStreamResponse) bool {
+	dropped := false
+	for i := range response.Choices {
+		delta := &response.Choices[i].Delta
+		if len(delta.ToolCalls) == 0 {
+			continue
+		}
+		toolCalls := delta.ToolCalls[:0]
+		for _, toolCall := range delta.ToolCalls {
+			if toolCall.Index != nil && *toolCall.Index > 0 {
+				dropped = true
+				continue
+			}
+			toolCalls = append(toolCalls, toolCall)
+		}
+		delta.ToolCalls = toolCalls
+	}
+	if !dropped || response.Usage != nil {
+		return true
+	}
+	for _, choice := range response.Choices {
+		if len(choice.Delta.ToolCalls) > 0 || choice.Delta.GetContentString() != "" ||
+			choice.Delta.GetReasoningContent() != "" || choice.FinishReason != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package relay
+
+import (
+	"bytes"
+	"net/http"
+	"one-api/service"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// piiRestoreWriter 缓冲非流式响应，结束后将占位符还原为原始内容再写出
+type piiRestoreWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *piiRestoreWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *piiRestoreWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Flush 缓冲期间不向客户端写出任何内容
+func (w *piiRestoreWriter) Flush() {}
+
+// startPIIRestore 替换 c.Writer，返回的 finish 恢复原 Writer 并写出还原后的响应
+func startPIIRestore(c *gin.Context, redactor *service.PIIRedactor) func() {
+	original := c.Writer
+	writer := &piiRestoreWriter{ResponseWriter: original}
+	c.Writer = writer
+	return func() {
+		c.Writer = original
+		body := writer.body.String()
+		if writer.Status() == http.StatusOK {
+			body = redactor.Restore(body, strings.Contains(original.Header().Get("Content-Type"), "json"))
+		}
+		if original.Header().Get("Content-Length") != "" {
+			original.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		_, _ = original.WriteString(body)
+	}
+}
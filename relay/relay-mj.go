@@ -170,7 +170,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	}
 	groupRatio := setting.GetGroupRatio(group)
 	ratio := modelPrice * groupRatio
-	userQuota, err := model.GetUserQuota(userId, false)
+	userQuota, err := service.GetPayerQuota(relayInfo)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -179,17 +179,25 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	}
 	quota := int(ratio * common.QuotaPerUnit)
 
-	if userQuota-quota < 0 {
+	if service.GetPayerAvailableQuota(relayInfo, userQuota)-quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
 		}
 	}
+	relayInfo.OriginModelName = modelName
+	if openaiErr := service.PreConsumeQuotaLimits(relayInfo, quota); openaiErr != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: openaiErr.Error.Message,
+		}
+	}
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	baseURL := c.GetString("base_url")
 	fullRequestURL := fmt.Sprintf("%s%s", baseURL, requestURL)
 	mjResp, _, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
+		service.ReleaseModelQuota(relayInfo)
 		return &mjResp.Response
 	}
 	defer func() {
@@ -198,6 +206,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 			if err != nil {
 				common.SysError("error consuming token remain quota: " + err.Error())
 			}
+			service.SettleQuotaLimits(relayInfo, quota)
 			//err = model.CacheUpdateUserQuota(userId)
 			if err != nil {
 				common.SysError("error update user quota cache: " + err.Error())
@@ -214,6 +223,8 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 				channelId := c.GetInt("channel_id")
 				model.UpdateChannelUsedQuota(channelId, quota)
 			}
+		} else {
+			service.ReleaseModelQuota(relayInfo)
 		}
 	}()
 	midjResponse := &mjResp.Response
@@ -236,6 +247,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       quota,
 	}
+	midjourneyTask.OrganizationId = relayInfo.OrganizationId
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "insert_midjourney_task_failed")
@@ -476,7 +488,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	}
 	groupRatio := setting.GetGroupRatio(group)
 	ratio := modelPrice * groupRatio
-	userQuota, err := model.GetUserQuota(userId, false)
+	userQuota, err := service.GetPayerQuota(relayInfo)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -485,15 +497,25 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	}
 	quota := int(ratio * common.QuotaPerUnit)
 
-	if consumeQuota && userQuota-quota < 0 {
+	if consumeQuota && service.GetPayerAvailableQuota(relayInfo, userQuota)-quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
 		}
 	}
+	if consumeQuota {
+		relayInfo.OriginModelName = modelName
+		if openaiErr := service.PreConsumeQuotaLimits(relayInfo, quota); openaiErr != nil {
+			return &dto.MidjourneyResponse{
+				Code:        4,
+				Description: openaiErr.Error.Message,
+			}
+		}
+	}
 
 	midjResponseWithStatus, responseBody, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
+		service.ReleaseModelQuota(relayInfo)
 		return &midjResponseWithStatus.Response
 	}
 	midjResponse := &midjResponseWithStatus.Response
@@ -504,6 +526,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 			if err != nil {
 				common.SysError("error consuming token remain quota: " + err.Error())
 			}
+			service.SettleQuotaLimits(relayInfo, quota)
 			if quota != 0 {
 				tokenName := c.GetString("token_name")
 				logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s，ID %s", modelPrice, groupRatio, midjRequest.Action, midjResponse.Result)
@@ -516,6 +539,8 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 				channelId := c.GetInt("channel_id")
 				model.UpdateChannelUsedQuota(channelId, quota)
 			}
+		} else {
+			service.ReleaseModelQuota(relayInfo)
 		}
 	}()
 
@@ -545,6 +570,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       quota,
 	}
+	midjourneyTask.OrganizationId = relayInfo.OrganizationId
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
 		channel, err := model.GetChannelById(midjourneyTask.ChannelId, true)
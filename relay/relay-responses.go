@@ -14,6 +14,7 @@ import (
 	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -36,16 +37,70 @@ func getAndValidateResponsesRequest(c *gin.Context) (*dto.OpenAIResponsesRequest
 }
 
 func checkInputSensitive(textRequest *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) ([]string, error) {
-	sensitiveWords, err := service.CheckSensitiveInput(textRequest.Input)
+	sensitiveWords, err := service.CheckSensitiveInput(info.Group, textRequest.Input)
 	return sensitiveWords, err
 }
 
 func getInputTokens(req *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) (int, error) {
 	inputTokens, err := service.CountTokenInput(req.Input, req.Model)
+	if err != nil {
+		return 0, err
+	}
+	// instructions、函数定义与 text.format 中的 JSON Schema 同样计入输入
+	schema := string(req.Instructions) + string(req.Text) + string(req.ToolChoice)
+	if len(req.Tools) > 0 {
+		tools, _ := json.Marshal(req.Tools)
+		schema += string(tools)
+	}
+	if schema != "" {
+		schemaTokens, err := service.CountTextToken(schema, req.Model)
+		if err != nil {
+			return 0, err
+		}
+		inputTokens += schemaTokens
+	}
 	info.PromptTokens = inputTokens
 	return inputTokens, err
 }
 
+// responsesRequestText 拼接 Responses 请求中 instructions 与 input 的文本内容
+func responsesRequestText(req *dto.OpenAIResponsesRequest) string {
+	var builder strings.Builder
+	var instructions string
+	if json.Unmarshal(req.Instructions, &instructions) == nil {
+		builder.WriteString(instructions)
+		builder.WriteString("\n")
+	}
+	var input string
+	if json.Unmarshal(req.Input, &input) == nil {
+		builder.WriteString(input)
+		return builder.String()
+	}
+	var items []struct {
+		Content json.RawMessage `json:"content"`
+	}
+	_ = json.Unmarshal(req.Input, &items)
+	for _, item := range items {
+		var content string
+		if json.Unmarshal(item.Content, &content) == nil {
+			builder.WriteString(content)
+			builder.WriteString("\n")
+			continue
+		}
+		var parts []struct {
+			Text string `json:"text"`
+		}
+		_ = json.Unmarshal(item.Content, &parts)
+		for _, part := range parts {
+			if part.Text != "" {
+				builder.WriteString(part.Text)
+				builder.WriteString("\n")
+			}
+		}
+	}
+	return builder.String()
+}
+
 func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	req, err := getAndValidateResponsesRequest(c)
 	if err != nil {
@@ -54,6 +109,14 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 	}
 
 	relayInfo := relaycommon.GenRelayInfoResponses(c, req)
+	// 强制系统提示词需在敏感词检查与 token 计数之前应用，修改后的请求不能再透传原始请求体
+	requestRewritten := service.EnforceGroupSystemPromptResponses(relayInfo.Group, req)
+	// 脱敏在敏感词与内容审核之前进行，流式响应不支持还原占位符，仅非流式响应按配置还原
+	if operation_setting.ShouldRedactPII(relayInfo.Group) && service.RedactPIIResponsesRequest(c, req) {
+		requestRewritten = true
+	}
+	piiRedactor := service.GetPIIRedactor(c)
+	restorePII := piiRedactor != nil && operation_setting.GetPIIRedactionSetting().RestoreResponse
 
 	if setting.ShouldCheckPromptSensitive() {
 		sensitiveWords, err := checkInputSensitive(req, relayInfo)
@@ -63,11 +126,18 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 		}
 	}
 
+	if operation_setting.ShouldModerate(relayInfo.Group) {
+		if openaiErr := moderateRequestText(c, responsesRequestText(req), relayInfo); openaiErr != nil {
+			return openaiErr
+		}
+	}
+
 	err = helper.ModelMappedHelper(c, relayInfo)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusBadRequest)
 	}
 	req.Model = relayInfo.UpstreamModelName
+	service.ClampMaxTokens(relayInfo.Group, relayInfo.OriginModelName, &req.MaxOutputTokens)
 	if value, exists := c.Get("prompt_tokens"); exists {
 		promptTokens := value.(int)
 		relayInfo.SetPromptTokens(promptTokens)
@@ -99,7 +169,7 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 	}
 	adaptor.Init(relayInfo)
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && !requestRewritten {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "get_request_body_error", http.StatusInternalServerError)
@@ -115,19 +185,9 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 			return service.OpenAIErrorWrapperLocal(err, "marshal_request_error", http.StatusInternalServerError)
 		}
 		// apply param override
-		if len(relayInfo.ParamOverride) > 0 {
-			reqMap := make(map[string]interface{})
-			err = json.Unmarshal(jsonData, &reqMap)
-			if err != nil {
-				return service.OpenAIErrorWrapperLocal(err, "param_override_unmarshal_failed", http.StatusInternalServerError)
-			}
-			for key, value := range relayInfo.ParamOverride {
-				reqMap[key] = value
-			}
-			jsonData, err = json.Marshal(reqMap)
-			if err != nil {
-				return service.OpenAIErrorWrapperLocal(err, "param_override_marshal_failed", http.StatusInternalServerError)
-			}
+		jsonData, err = relaycommon.ApplyParamOverride(c, relayInfo, jsonData)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "param_override_failed", http.StatusInternalServerError)
 		}
 
 		if common.DebugEnabled {
@@ -155,17 +215,20 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 		}
 	}
 
+	var finishPIIRestore func()
+	if restorePII && !relayInfo.IsStream {
+		finishPIIRestore = startPIIRestore(c, piiRedactor)
+	}
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	if finishPIIRestore != nil {
+		finishPIIRestore()
+	}
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
 	}
 
-	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
-		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
-	} else {
-		postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
-	}
+	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	return nil
 }
@@ -26,6 +26,8 @@ import (
 	"github.com/shopspring/decimal"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func getAndValidateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
@@ -70,77 +72,156 @@ func getAndValidateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo)
 	return textRequest, nil
 }
 
-func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
-	reqId := c.GetString("request_id")
-	common.LogInfo(c, fmt.Sprintf("[%s] TextHelper开始处理请求", reqId))
+var relayLogger = common.NewLogger("relay")
 
+func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	relayInfo := relaycommon.GenRelayInfo(c)
-	common.LogInfo(c, fmt.Sprintf("[%s] 生成中继信息: 用户ID=%d, 渠道ID=%d, 模型=%s, 中继模式=%d",
-		reqId, relayInfo.UserId, relayInfo.ChannelId, relayInfo.OriginModelName, relayInfo.RelayMode))
+	span, endSpan := common.StartGinSpan(c, "relay.TextHelper",
+		attribute.Int("channel.id", relayInfo.ChannelId),
+		attribute.String("model", relayInfo.OriginModelName),
+		attribute.Bool("stream", relayInfo.IsStream))
+	defer func() {
+		if openaiErr != nil {
+			span.SetStatus(codes.Error, openaiErr.Error.Message)
+		}
+		endSpan()
+	}()
+	relayLogger.Debug(c, "text relay started", "user_id", relayInfo.UserId, "channel_id", relayInfo.ChannelId,
+		"model", relayInfo.OriginModelName, "relay_mode", relayInfo.RelayMode)
 
 	// get & validate textRequest 获取并验证文本请求
 	textRequest, err := getAndValidateTextRequest(c, relayInfo)
 	if err != nil {
-		common.LogError(c, fmt.Sprintf("[%s] getAndValidateTextRequest failed: %s", reqId, err.Error()))
+		relayLogger.Error(c, "getAndValidateTextRequest failed", "error", err.Error())
 		return service.OpenAIErrorWrapperLocal(err, "invalid_text_request", http.StatusBadRequest)
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 文本请求验证成功，模型=%s, 流式=%v", reqId, textRequest.Model, relayInfo.IsStream))
+	if textRequest.CachedContent != "" {
+		// 标记后不再换渠道重试，避免重试到其他渠道时以缓存校验失败掩盖上游的原始错误
+		c.Set(constant.ContextKeyCachedContent, textRequest.CachedContent)
+		if err := validateGeminiCachedContent(relayInfo, textRequest.CachedContent); err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "invalid_cached_content", http.StatusBadRequest)
+		}
+	}
+
+	// requestRewritten 表示请求内容已被网关修改，此时即使开启透传也需发送修改后的请求
+	requestRewritten := false
+	if relayInfo.RelayMode == relayconstant.RelayModeChatCompletions {
+		// 先应用分组强制系统提示词，再拼接令牌或分组的提示词模板
+		requestRewritten = service.EnforceGroupSystemPrompt(relayInfo.Group, textRequest)
+		if service.ApplyPromptTemplate(c, relayInfo, textRequest) {
+			requestRewritten = true
+		}
+	}
+	// 脱敏在敏感词与内容审核之前进行，审核服务同样不会收到原始个人信息；
+	// 脱敏后的请求不能再透传原始请求体，因此透传渠道同样会发送脱敏后的内容
+	if operation_setting.ShouldRedactPII(relayInfo.Group) {
+		switch relayInfo.RelayMode {
+		case relayconstant.RelayModeChatCompletions:
+			if service.RedactPIIMessages(c, textRequest.Messages) {
+				requestRewritten = true
+			}
+		case relayconstant.RelayModeCompletions:
+			var redacted bool
+			if textRequest.Prompt, redacted = service.RedactPIIPrompt(c, textRequest.Prompt); redacted {
+				requestRewritten = true
+			}
+		}
+	}
+	piiRedactor := service.GetPIIRedactor(c)
+	restorePII := piiRedactor != nil && operation_setting.GetPIIRedactionSetting().RestoreResponse
 
 	if setting.ShouldCheckPromptSensitive() {
-		common.LogInfo(c, fmt.Sprintf("[%s] 开始检查敏感词", reqId))
 		words, err := checkRequestSensitive(textRequest, relayInfo)
 		if err != nil {
-			common.LogWarn(c, fmt.Sprintf("[%s] 用户敏感词检测: %s", reqId, strings.Join(words, ", ")))
+			relayLogger.Warn(c, "sensitive words detected", "words", strings.Join(words, ", "))
 			return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
 		}
-		common.LogInfo(c, fmt.Sprintf("[%s] 敏感词检查通过", reqId))
+	}
+
+	if operation_setting.ShouldModerate(relayInfo.Group) {
+		if openaiErr := checkRequestModeration(c, textRequest, relayInfo); openaiErr != nil {
+			return openaiErr
+		}
 	}
 
 	err = helper.ModelMappedHelper(c, relayInfo)
 	if err != nil {
-		common.LogError(c, fmt.Sprintf("[%s] 模型映射错误: %s", reqId, err.Error()))
+		relayLogger.Error(c, "model mapping failed", "error", err.Error())
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 模型映射完成: 源模型=%s, 上游模型=%s", reqId, relayInfo.OriginModelName, relayInfo.UpstreamModelName))
+	relayLogger.Debug(c, "model mapped", "origin_model", relayInfo.OriginModelName, "upstream_model", relayInfo.UpstreamModelName)
 
 	textRequest.Model = relayInfo.UpstreamModelName
 
+	if relayInfo.RelayMode == relayconstant.RelayModeChatCompletions {
+		if err = service.CheckModelCapability(textRequest, relayInfo.UpstreamModelName); err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "model_capability_unsupported", http.StatusBadRequest)
+		}
+	}
+	if relayInfo.RelayMode == relayconstant.RelayModeChatCompletions || relayInfo.RelayMode == relayconstant.RelayModeCompletions {
+		service.ClampMaxTokens(relayInfo.Group, relayInfo.OriginModelName, &textRequest.MaxTokens, &textRequest.MaxCompletionTokens)
+	}
+
+	responseCacheKey, cacheHit, openaiErr := lookupResponseCache(c, relayInfo, textRequest)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	if cacheHit {
+		relayLogger.Debug(c, "response cache hit", "model", relayInfo.OriginModelName)
+		return nil
+	}
+	semanticScope, semanticVector, cacheHit, openaiErr := lookupSemanticCache(c, relayInfo, textRequest)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	if cacheHit {
+		relayLogger.Debug(c, "semantic cache hit", "model", relayInfo.OriginModelName)
+		return nil
+	}
+
 	// 获取 promptTokens，如果上下文中已经存在，则直接使用
 	var promptTokens int
 	if value, exists := c.Get("prompt_tokens"); exists {
 		promptTokens = value.(int)
 		relayInfo.PromptTokens = promptTokens
-		common.LogInfo(c, fmt.Sprintf("[%s] 从上下文获取promptTokens=%d", reqId, promptTokens))
 	} else {
 		promptTokens, err = getPromptTokens(textRequest, relayInfo)
 		// count messages token error 计算promptTokens错误
 		if err != nil {
-			common.LogError(c, fmt.Sprintf("[%s] 计算token失败: %s", reqId, err.Error()))
+			relayLogger.Error(c, "count prompt tokens failed", "error", err.Error())
 			return service.OpenAIErrorWrapper(err, "count_token_messages_failed", http.StatusInternalServerError)
 		}
 		c.Set("prompt_tokens", promptTokens)
-		common.LogInfo(c, fmt.Sprintf("[%s] 计算获取promptTokens=%d", reqId, promptTokens))
+	}
+	if relayInfo.RelayMode == relayconstant.RelayModeChatCompletions {
+		// prompt_tokens 保留截断前的数量，重试时会对重新解析的请求再次截断
+		promptTokens, err = service.FitContextWindow(c, relayInfo, textRequest, promptTokens)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "context_length_exceeded", http.StatusBadRequest)
+		}
 	}
 
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, promptTokens, int(math.Max(float64(textRequest.MaxTokens), float64(textRequest.MaxCompletionTokens))))
 	if err != nil {
-		common.LogError(c, fmt.Sprintf("[%s] 模型价格计算错误: %s", reqId, err.Error()))
+		relayLogger.Error(c, "model price failed", "error", err.Error())
 		return service.OpenAIErrorWrapperLocal(err, "model_price_error", http.StatusInternalServerError)
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 价格计算完成: 模型价格=%f, 组倍率=%f, 使用价格=%v",
-		reqId, priceData.ModelPrice, priceData.GroupRatio, priceData.UsePrice))
+	relayLogger.Debug(c, "price resolved", "prompt_tokens", promptTokens, "model_price", priceData.ModelPrice,
+		"group_ratio", priceData.GroupRatio, "use_price", priceData.UsePrice)
 
 	// pre-consume quota 预消耗配额
+	_, endPreConsumeSpan := common.StartGinSpan(c, "quota.preConsume")
 	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
+	endPreConsumeSpan()
 	if openaiErr != nil {
-		common.LogError(c, fmt.Sprintf("[%s] 预消耗配额失败: %s", reqId, openaiErr.Error.Message))
+		relayLogger.Warn(c, "pre-consume quota failed", "error", openaiErr.Error.Message)
 		return openaiErr
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 预消耗配额完成: 预消耗=%d, 用户余额=%d", reqId, preConsumedQuota, userQuota))
+	relayLogger.Debug(c, "quota pre-consumed", "pre_consumed_quota", preConsumedQuota, "user_quota", userQuota)
 
 	defer func() {
 		if openaiErr != nil {
-			common.LogInfo(c, fmt.Sprintf("[%s] 请求失败，退还预消耗配额: %d", reqId, preConsumedQuota))
+			relayLogger.Debug(c, "request failed, returning pre-consumed quota", "pre_consumed_quota", preConsumedQuota)
 			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
 		}
 	}()
@@ -166,107 +247,130 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		relayInfo.ShouldIncludeUsage = true
 	}
 
+	helper.ResetStreamOutputFilters(c)
+	if relayInfo.IsStream && setting.ShouldCheckCompletionSensitive() {
+		if filter := service.NewStreamSensitiveFilter(relayInfo.Group); filter != nil {
+			helper.AddStreamOutputFilter(c, filter)
+		}
+	}
+	if relayInfo.IsStream && restorePII {
+		helper.AddStreamOutputFilter(c, service.NewPIIRestoreFilter(piiRedactor))
+	}
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
-		common.LogError(c, fmt.Sprintf("[%s] 无效的API类型: %d", reqId, relayInfo.ApiType))
+		relayLogger.Error(c, "invalid api type", "api_type", relayInfo.ApiType)
 		return service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), "invalid_api_type", http.StatusBadRequest)
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 获取适配器成功: API类型=%d", reqId, relayInfo.ApiType))
 
 	adaptor.Init(relayInfo)
 	var requestBody io.Reader
 
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && !requestRewritten {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
-			common.LogError(c, fmt.Sprintf("[%s] 获取请求体失败: %s", reqId, err.Error()))
+			relayLogger.Error(c, "get request body failed", "error", err.Error())
 			return service.OpenAIErrorWrapperLocal(err, "get_request_body_failed", http.StatusInternalServerError)
 		}
 		requestBody = bytes.NewBuffer(body)
-		common.LogInfo(c, fmt.Sprintf("[%s] 透传请求体模式", reqId))
 	} else {
 		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, relayInfo, textRequest)
 		if err != nil {
-			common.LogError(c, fmt.Sprintf("[%s] 转换请求失败: %s", reqId, err.Error()))
+			relayLogger.Error(c, "convert request failed", "error", err.Error())
+			if errors.Is(err, service.ErrInvalidImage) {
+				return service.OpenAIErrorWrapperLocal(err, "invalid_image_url", http.StatusBadRequest)
+			}
 			return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
 		}
-		common.LogInfo(c, fmt.Sprintf("[%s] 请求转换成功", reqId))
 
 		jsonData, err := json.Marshal(convertedRequest)
 		if err != nil {
-			common.LogError(c, fmt.Sprintf("[%s] JSON序列化失败: %s", reqId, err.Error()))
+			relayLogger.Error(c, "marshal request failed", "error", err.Error())
 			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
 		}
 
 		// apply param override
-		if len(relayInfo.ParamOverride) > 0 {
-			common.LogInfo(c, fmt.Sprintf("[%s] 应用参数覆盖，参数数量=%d", reqId, len(relayInfo.ParamOverride)))
-			reqMap := make(map[string]interface{})
-			err = json.Unmarshal(jsonData, &reqMap)
-			if err != nil {
-				common.LogError(c, fmt.Sprintf("[%s] 参数覆盖解析失败: %s", reqId, err.Error()))
-				return service.OpenAIErrorWrapperLocal(err, "param_override_unmarshal_failed", http.StatusInternalServerError)
-			}
-			for key, value := range relayInfo.ParamOverride {
-				reqMap[key] = value
-			}
-			jsonData, err = json.Marshal(reqMap)
-			if err != nil {
-				common.LogError(c, fmt.Sprintf("[%s] 参数覆盖序列化失败: %s", reqId, err.Error()))
-				return service.OpenAIErrorWrapperLocal(err, "param_override_marshal_failed", http.StatusInternalServerError)
-			}
+		jsonData, err = relaycommon.ApplyParamOverride(c, relayInfo, jsonData)
+		if err != nil {
+			relayLogger.Error(c, "param override failed", "error", err.Error())
+			return service.OpenAIErrorWrapperLocal(err, "param_override_failed", http.StatusInternalServerError)
+		}
+		if relayLogger.Enabled(common.LogLevelDebug) {
+			relayLogger.Debug(c, "upstream request body", "body", string(jsonData))
 		}
-		common.LogInfo(c, fmt.Sprintf("requestBody: %s", string(jsonData)))
 		requestBody = bytes.NewBuffer(jsonData)
 	}
 
-	common.LogInfo(c, fmt.Sprintf("[%s] 开始发送请求", reqId))
 	var httpResp *http.Response
+	_, endDoRequestSpan := common.StartGinSpan(c, "adaptor.DoRequest",
+		attribute.String("upstream.model", relayInfo.UpstreamModelName))
 	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
+	endDoRequestSpan()
 	if err != nil {
-		common.LogError(c, fmt.Sprintf("[%s] 请求失败: %s", reqId, err.Error()))
+		relayLogger.Error(c, "do request failed", "error", err.Error())
 		return service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 请求发送成功", reqId))
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
 
 	if resp != nil {
 		httpResp = resp.(*http.Response)
 		relayInfo.IsStream = relayInfo.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
-		common.LogInfo(c, fmt.Sprintf("[%s] 收到响应: 状态码=%d, 内容类型=%s, 流式=%v",
-			reqId, httpResp.StatusCode, httpResp.Header.Get("Content-Type"), relayInfo.IsStream))
+		relayLogger.Debug(c, "upstream responded", "status_code", httpResp.StatusCode,
+			"content_type", httpResp.Header.Get("Content-Type"), "stream", relayInfo.IsStream)
 
 		if httpResp.StatusCode != http.StatusOK {
 			openaiErr = service.RelayErrorHandler(httpResp, false)
 			// reset status code 重置状态码
 			service.ResetStatusCode(openaiErr, statusCodeMappingStr)
-			common.LogError(c, fmt.Sprintf("[%s] 响应状态码错误: %d, 错误=%s",
-				reqId, httpResp.StatusCode, openaiErr.Error.Message))
+			relayLogger.Warn(c, "upstream returned error", "status_code", httpResp.StatusCode, "error", openaiErr.Error.Message)
 			return openaiErr
 		}
 	}
 
-	common.LogInfo(c, fmt.Sprintf("[%s] 开始处理响应", reqId))
+	var finishResponseCapture func(*relaycommon.RelayInfo, *dto.Usage, bool) *service.ResponseCacheEntry
+	if (responseCacheKey != "" || semanticVector != nil) && !relayInfo.IsStream {
+		finishResponseCapture = startResponseCapture(c, operation_setting.GetResponseCacheSetting().MaxBodyBytes)
+	}
+	var finishPIIRestore func()
+	if restorePII && !relayInfo.IsStream {
+		finishPIIRestore = startPIIRestore(c, piiRedactor)
+	}
+	_, endDoResponseSpan := common.StartGinSpan(c, "adaptor.DoResponse")
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	endDoResponseSpan()
+	if finishPIIRestore != nil {
+		finishPIIRestore()
+	}
+	if finishResponseCapture != nil {
+		cachedUsage, _ := usage.(*dto.Usage)
+		if entry := finishResponseCapture(relayInfo, cachedUsage, openaiErr == nil && !helper.IsHedgeLost(c)); entry != nil {
+			if responseCacheKey != "" {
+				service.SetResponseCache(responseCacheKey, entry)
+			}
+			if semanticVector != nil {
+				service.SetSemanticCache(semanticScope, semanticVector, entry)
+			}
+		}
+	}
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
-		common.LogError(c, fmt.Sprintf("[%s] 处理响应失败: %s", reqId, openaiErr.Error.Message))
+		relayLogger.Warn(c, "handle response failed", "error", openaiErr.Error.Message)
 		return openaiErr
 	}
-	common.LogInfo(c, fmt.Sprintf("[%s] 响应处理成功", reqId))
 
-	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
-		common.LogInfo(c, fmt.Sprintf("[%s] 音频模型消费配额", reqId))
-		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
-	} else {
-		common.LogInfo(c, fmt.Sprintf("[%s] 消费配额: promptTokens=%d, completionTokens=%d",
-			reqId, usage.(*dto.Usage).PromptTokens, usage.(*dto.Usage).CompletionTokens))
-		postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
+	if helper.IsHedgeLost(c) {
+		// 对冲请求落败，由获胜的请求计费
+		return service.OpenAIErrorWrapperLocal(helper.ErrHedgeLost, "hedge_lost", http.StatusInternalServerError)
 	}
 
-	common.LogInfo(c, fmt.Sprintf("[%s] TextHelper处理完成", reqId))
+	_, endPostConsumeSpan := common.StartGinSpan(c, "quota.postConsume")
+	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
+	endPostConsumeSpan()
+
+	relayLogger.Debug(c, "text relay finished", "prompt_tokens", usage.(*dto.Usage).PromptTokens,
+		"completion_tokens", usage.(*dto.Usage).CompletionTokens)
 	return nil
 }
 
@@ -295,31 +399,101 @@ func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycom
 	var words []string
 	switch info.RelayMode {
 	case relayconstant.RelayModeChatCompletions:
-		words, err = service.CheckSensitiveMessages(textRequest.Messages)
+		words, err = service.CheckSensitiveMessages(info.Group, textRequest.Messages)
 	case relayconstant.RelayModeCompletions:
-		words, err = service.CheckSensitiveInput(textRequest.Prompt)
+		words, err = service.CheckSensitiveInput(info.Group, textRequest.Prompt)
 	case relayconstant.RelayModeModerations:
-		words, err = service.CheckSensitiveInput(textRequest.Input)
+		words, err = service.CheckSensitiveInput(info.Group, textRequest.Input)
 	case relayconstant.RelayModeEmbeddings:
-		words, err = service.CheckSensitiveInput(textRequest.Input)
+		words, err = service.CheckSensitiveInput(info.Group, textRequest.Input)
 	}
 	return words, err
 }
 
+// checkRequestModeration 通过外部审核服务检查请求内容，结果保存在上下文中随请求日志记录，重试时不再重复审核
+func checkRequestModeration(c *gin.Context, textRequest *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) *dto.OpenAIErrorWithStatusCode {
+	if _, ok := c.Get(constant.ContextKeyModerationResult); ok {
+		return nil
+	}
+	var text string
+	switch info.RelayMode {
+	case relayconstant.RelayModeChatCompletions:
+		var builder strings.Builder
+		for i := range textRequest.Messages {
+			builder.WriteString(textRequest.Messages[i].StringContent())
+			builder.WriteString("\n")
+		}
+		text = builder.String()
+	case relayconstant.RelayModeCompletions:
+		text = fmt.Sprintf("%v", textRequest.Prompt)
+	default:
+		return nil
+	}
+	return moderateRequestText(c, text, info)
+}
+
+// moderateRequestText 审核请求文本，审核服务的错误详情只记录日志，返回给客户端的是通用错误
+func moderateRequestText(c *gin.Context, text string, info *relaycommon.RelayInfo) *dto.OpenAIErrorWithStatusCode {
+	if _, ok := c.Get(constant.ContextKeyModerationResult); ok {
+		return nil
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	result, err := service.ModerateText(text, info.Group, info.OriginModelName)
+	if err != nil {
+		relayLogger.Error(c, "moderation failed", "provider", operation_setting.GetModerationSetting().Provider, "error", err.Error())
+		if operation_setting.GetModerationSetting().FailOpen {
+			return nil
+		}
+		return service.OpenAIErrorWrapperLocal(errors.New("content moderation is temporarily unavailable"), "moderation_failed", http.StatusServiceUnavailable)
+	}
+	c.Set(constant.ContextKeyModerationResult, result)
+	if result.Flagged {
+		relayLogger.Warn(c, "request flagged by moderation", "provider", result.Provider, "violations", strings.Join(result.Violations, ","))
+		return service.OpenAIErrorWrapperLocal(errors.New("request content flagged by moderation"), "content_moderation_flagged", http.StatusBadRequest)
+	}
+	return nil
+}
+
 // 预扣费并返回用户剩余配额
 func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *dto.OpenAIErrorWithStatusCode) {
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	// 重试时不重复计入 RPM/TPM
+	if !c.GetBool(constant.ContextKeyRateLimitChecked) {
+		if openaiErr := service.CheckTokenRateLimit(c, relayInfo); openaiErr != nil {
+			return 0, 0, openaiErr
+		}
+		c.Set(constant.ContextKeyRateLimitChecked, true)
+	}
+	// 检查预算并按模型额度限制以预估额度预留，后续检查失败时释放
+	if openaiErr := service.PreConsumeQuotaLimits(relayInfo, preConsumedQuota); openaiErr != nil {
+		return 0, 0, openaiErr
+	}
+	userQuota, err := service.GetPayerQuota(relayInfo)
 	if err != nil {
+		service.ReleaseModelQuota(relayInfo)
 		return 0, 0, service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
-	if userQuota <= 0 {
+	// 从套餐中该模型的赠送额度预扣，足够时无需再预扣用户额度，并发请求不会重复使用同一份赠送额度
+	if preConsumedQuota > 0 && service.HoldUserPackageAllowance(relayInfo, preConsumedQuota) >= preConsumedQuota {
+		relayInfo.UserQuota = userQuota
+		common.LogInfo(c, fmt.Sprintf("user %d package allowance for model %s is held, no need to pre-consume", relayInfo.UserId, relayInfo.OriginModelName))
+		return 0, userQuota, nil
+	}
+	// 后付费用户可透支至信用额度
+	availableQuota := service.GetPayerAvailableQuota(relayInfo, userQuota)
+	if availableQuota <= 0 {
+		service.ReleaseModelQuota(relayInfo)
+		service.ReleaseUserPackageAllowance(relayInfo)
 		return 0, 0, service.OpenAIErrorWrapperLocal(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
 	}
-	if userQuota-preConsumedQuota < 0 {
-		return 0, 0, service.OpenAIErrorWrapperLocal(fmt.Errorf("chat pre-consumed quota failed, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(preConsumedQuota)), "insufficient_user_quota", http.StatusForbidden)
+	if availableQuota-preConsumedQuota < 0 {
+		service.ReleaseModelQuota(relayInfo)
+		service.ReleaseUserPackageAllowance(relayInfo)
+		return 0, 0, service.OpenAIErrorWrapperLocal(fmt.Errorf("chat pre-consumed quota failed, user quota: %s, need quota: %s", common.FormatQuota(availableQuota), common.FormatQuota(preConsumedQuota)), "insufficient_user_quota", http.StatusForbidden)
 	}
 	relayInfo.UserQuota = userQuota
-	if userQuota > 100*preConsumedQuota {
+	if availableQuota > 100*preConsumedQuota {
 		// 用户额度充足，判断令牌额度是否充足
 		if !relayInfo.TokenUnlimited {
 			// 非无限令牌，判断令牌额度是否充足
@@ -340,10 +514,14 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	if preConsumedQuota > 0 {
 		err := service.PreConsumeTokenQuota(relayInfo, preConsumedQuota)
 		if err != nil {
+			service.ReleaseModelQuota(relayInfo)
+			service.ReleaseUserPackageAllowance(relayInfo)
 			return 0, 0, service.OpenAIErrorWrapperLocal(err, "pre_consume_token_quota_failed", http.StatusForbidden)
 		}
-		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
+		err = service.DecreasePayerQuota(relayInfo, preConsumedQuota)
 		if err != nil {
+			service.ReleaseModelQuota(relayInfo)
+			service.ReleaseUserPackageAllowance(relayInfo)
 			return 0, 0, service.OpenAIErrorWrapperLocal(err, "decrease_user_quota_failed", http.StatusInternalServerError)
 		}
 	}
@@ -351,6 +529,8 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 }
 
 func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, userQuota int, preConsumedQuota int) {
+	service.ReleaseModelQuota(relayInfo)
+	service.ReleaseUserPackageAllowance(relayInfo)
 	if preConsumedQuota != 0 {
 		gopool.Go(func() {
 			relayInfoCopy := *relayInfo
@@ -376,13 +556,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	cacheTokens := usage.PromptTokensDetails.CachedTokens
+	cacheCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
 	imageTokens := usage.PromptTokensDetails.ImageTokens
+	audioInputTokens := usage.PromptTokensDetails.AudioTokens
+	audioOutputTokens := usage.CompletionTokenDetails.AudioTokens
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
+	inputAudioSeconds, outputAudioSeconds := relayInfo.TakeAudioSeconds()
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := priceData.CompletionRatio
 	cacheRatio := priceData.CacheRatio
+	cacheCreationRatio := priceData.CacheCreationRatio
 	imageRatio := priceData.ImageRatio
 	modelRatio := priceData.ModelRatio
 	groupRatio := priceData.GroupRatio
@@ -391,10 +576,12 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	// Convert values to decimal for precise calculation
 	dPromptTokens := decimal.NewFromInt(int64(promptTokens))
 	dCacheTokens := decimal.NewFromInt(int64(cacheTokens))
+	dCacheCreationTokens := decimal.NewFromInt(int64(cacheCreationTokens))
 	dImageTokens := decimal.NewFromInt(int64(imageTokens))
 	dCompletionTokens := decimal.NewFromInt(int64(completionTokens))
 	dCompletionRatio := decimal.NewFromFloat(completionRatio)
 	dCacheRatio := decimal.NewFromFloat(cacheRatio)
+	dCacheCreationRatio := decimal.NewFromFloat(cacheCreationRatio)
 	dImageRatio := decimal.NewFromFloat(imageRatio)
 	dModelRatio := decimal.NewFromFloat(modelRatio)
 	dGroupRatio := decimal.NewFromFloat(groupRatio)
@@ -403,40 +590,24 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 
 	ratio := dModelRatio.Mul(dGroupRatio)
 
-	// openai web search 工具计费
-	var dWebSearchQuota decimal.Decimal
-	var webSearchPrice float64
-	if relayInfo.ResponsesUsageInfo != nil {
-		if webSearchTool, exists := relayInfo.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolWebSearchPreview]; exists && webSearchTool.CallCount > 0 {
-			// 计算 web search 调用的配额 (配额 = 价格 * 调用次数 / 1000 * 分组倍率)
-			webSearchPrice = operation_setting.GetWebSearchPricePerThousand(modelName, webSearchTool.SearchContextSize)
-			dWebSearchQuota = decimal.NewFromFloat(webSearchPrice).
-				Mul(decimal.NewFromInt(int64(webSearchTool.CallCount))).
-				Div(decimal.NewFromInt(1000)).Mul(dGroupRatio).Mul(dQuotaPerUnit)
-			extraContent += fmt.Sprintf("Web Search 调用 %d 次，上下文大小 %s，调用花费 $%s",
-				webSearchTool.CallCount, webSearchTool.SearchContextSize, dWebSearchQuota.String())
-		}
-	}
-	// file search tool 计费
-	var dFileSearchQuota decimal.Decimal
-	var fileSearchPrice float64
-	if relayInfo.ResponsesUsageInfo != nil {
-		if fileSearchTool, exists := relayInfo.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolFileSearch]; exists && fileSearchTool.CallCount > 0 {
-			fileSearchPrice = operation_setting.GetFileSearchPricePerThousand()
-			dFileSearchQuota = decimal.NewFromFloat(fileSearchPrice).
-				Mul(decimal.NewFromInt(int64(fileSearchTool.CallCount))).
-				Div(decimal.NewFromInt(1000)).Mul(dGroupRatio).Mul(dQuotaPerUnit)
-			extraContent += fmt.Sprintf("File Search 调用 %d 次，调用花费 $%s",
-				fileSearchTool.CallCount, dFileSearchQuota.String())
-		}
+	// 内置工具按次附加计费，如 web search、file search、code interpreter 及各渠道的联网搜索
+	dToolQuota, toolSurcharges := service.CalculateToolSurcharge(relayInfo, modelName, groupRatio)
+	if len(toolSurcharges) > 0 {
+		extraContent += service.ToolSurchargeContent(relayInfo, toolSurcharges)
 	}
 
+	audioRatio := operation_setting.GetAudioRatio(modelName)
+	audioCompletionRatio := operation_setting.GetAudioCompletionRatio(modelName)
+	minuteBilling := false
+
 	var quotaCalculateDecimal decimal.Decimal
 	if !priceData.UsePrice {
-		nonCachedTokens := dPromptTokens.Sub(dCacheTokens)
+		// 提示词 token 包含缓存读取与缓存写入部分，二者分别按缓存倍率与缓存写入倍率计费
+		nonCachedTokens := dPromptTokens.Sub(dCacheTokens).Sub(dCacheCreationTokens)
 		cachedTokensWithRatio := dCacheTokens.Mul(dCacheRatio)
+		cacheCreationTokensWithRatio := dCacheCreationTokens.Mul(dCacheCreationRatio)
 
-		promptQuota := nonCachedTokens.Add(cachedTokensWithRatio)
+		promptQuota := nonCachedTokens.Add(cachedTokensWithRatio).Add(cacheCreationTokensWithRatio)
 		if imageTokens > 0 {
 			nonImageTokens := dPromptTokens.Sub(dImageTokens)
 			imageTokensWithRatio := dImageTokens.Mul(dImageRatio)
@@ -445,17 +616,37 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 
 		completionQuota := dCompletionTokens.Mul(dCompletionRatio)
 
+		// 音频 token 从提示词与补全 token 中扣出，改按音频倍率与音频补全倍率计费
+		if audioInputTokens > 0 || audioOutputTokens > 0 {
+			dAudioInputTokens := decimal.NewFromInt(int64(audioInputTokens))
+			dAudioOutputTokens := decimal.NewFromInt(int64(audioOutputTokens))
+			dAudioRatio := decimal.NewFromFloat(audioRatio)
+			promptQuota = promptQuota.Sub(dAudioInputTokens).Add(dAudioInputTokens.Mul(dAudioRatio))
+			completionQuota = dCompletionTokens.Sub(dAudioOutputTokens).Mul(dCompletionRatio).
+				Add(dAudioOutputTokens.Mul(dAudioRatio).Mul(decimal.NewFromFloat(audioCompletionRatio)))
+		}
+
 		quotaCalculateDecimal = promptQuota.Add(completionQuota).Mul(ratio)
 
+		// 上游未返回 token 明细且本地统计到音频时长时，按分钟价格计费
+		if minuteQuota, ok := service.AudioMinuteQuota(modelName, usage, inputAudioSeconds, outputAudioSeconds, groupRatio); ok {
+			quotaCalculateDecimal = minuteQuota
+			minuteBilling = true
+		}
+
 		if !ratio.IsZero() && quotaCalculateDecimal.LessThanOrEqual(decimal.Zero) {
 			quotaCalculateDecimal = decimal.NewFromInt(1)
 		}
 	} else {
 		quotaCalculateDecimal = dModelPrice.Mul(dQuotaPerUnit).Mul(dGroupRatio)
 	}
-	// 添加 responses tools call 调用的配额
-	quotaCalculateDecimal = quotaCalculateDecimal.Add(dWebSearchQuota)
-	quotaCalculateDecimal = quotaCalculateDecimal.Add(dFileSearchQuota)
+	// 按上游实际使用的 service_tier 倍率计费，内置工具的附加费用不受影响
+	serviceTierMultiplier := operation_setting.GetServiceTierMultiplier(relayInfo.ServiceTier)
+	if serviceTierMultiplier != 1 {
+		quotaCalculateDecimal = quotaCalculateDecimal.Mul(decimal.NewFromFloat(serviceTierMultiplier))
+	}
+	// 添加内置工具调用的配额
+	quotaCalculateDecimal = quotaCalculateDecimal.Add(dToolQuota)
 
 	quota := int(quotaCalculateDecimal.Round(0).IntPart())
 	totalTokens := promptTokens + completionTokens
@@ -463,6 +654,13 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	var logContent string
 	if !priceData.UsePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，分组倍率 %.2f", modelRatio, completionRatio, groupRatio)
+		if audioInputTokens > 0 || audioOutputTokens > 0 {
+			logContent += fmt.Sprintf("，音频倍率 %.2f，音频补全倍率 %.2f", audioRatio, audioCompletionRatio)
+		}
+		if minuteBilling {
+			logContent = fmt.Sprintf("上游未返回 token 明细，按音频时长计费：输入 %.2f 分钟，输出 %.2f 分钟，分组倍率 %.2f",
+				inputAudioSeconds/60, outputAudioSeconds/60, groupRatio)
+		}
 	} else {
 		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
 	}
@@ -497,29 +695,46 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logModel = "gpt-4o-gizmo-*"
 		logContent += fmt.Sprintf("，模型 %s", modelName)
 	}
+	if serviceTierMultiplier != 1 {
+		logContent += fmt.Sprintf("，service_tier %s 倍率 %.2f", relayInfo.ServiceTier, serviceTierMultiplier)
+	}
 	if extraContent != "" {
 		logContent += ", " + extraContent
 	}
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice)
+	if reconciliation := service.ReconcilePromptUsage(ctx, relayInfo, usage); reconciliation != nil {
+		other["usage_reconciliation"] = reconciliation
+	}
+	if cacheCreationTokens > 0 {
+		other["cache_creation_tokens"] = cacheCreationTokens
+		other["cache_creation_ratio"] = cacheCreationRatio
+	}
+	if priceData.PriceTier > 0 {
+		other["price_tier"] = priceData.PriceTier
+	}
+	if relayInfo.ServiceTier != "" {
+		other["service_tier"] = relayInfo.ServiceTier
+		other["service_tier_multiplier"] = serviceTierMultiplier
+	}
 	if imageTokens != 0 {
 		other["image"] = true
 		other["image_ratio"] = imageRatio
 		other["image_output"] = imageTokens
 	}
-	if !dWebSearchQuota.IsZero() && relayInfo.ResponsesUsageInfo != nil {
-		if webSearchTool, exists := relayInfo.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolWebSearchPreview]; exists {
-			other["web_search"] = true
-			other["web_search_call_count"] = webSearchTool.CallCount
-			other["web_search_price"] = webSearchPrice
-		}
+	if audioInputTokens > 0 || audioOutputTokens > 0 {
+		other["audio"] = true
+		other["audio_input"] = audioInputTokens
+		other["audio_output"] = audioOutputTokens
+		other["audio_ratio"] = audioRatio
+		other["audio_completion_ratio"] = audioCompletionRatio
 	}
-	if !dFileSearchQuota.IsZero() && relayInfo.ResponsesUsageInfo != nil {
-		if fileSearchTool, exists := relayInfo.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolFileSearch]; exists {
-			other["file_search"] = true
-			other["file_search_call_count"] = fileSearchTool.CallCount
-			other["file_search_price"] = fileSearchPrice
-		}
+	if minuteBilling {
+		other["audio_minute_billing"] = true
+		other["audio_input_seconds"] = inputAudioSeconds
+		other["audio_output_seconds"] = outputAudioSeconds
 	}
+	service.SetToolSurchargeOtherInfo(other, toolSurcharges)
+	service.SettleConsumeQuota(relayInfo, completionTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, promptTokens, completionTokens, logModel,
 		tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, relayInfo.Group, other)
 }
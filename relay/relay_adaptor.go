@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"one-api/common"
 	commonconstant "one-api/constant"
 	"one-api/relay/channel"
 	"one-api/relay/channel/ali"
@@ -21,6 +22,10 @@ import (
 	"one-api/relay/channel/palm"
 	"one-api/relay/channel/perplexity"
 	"one-api/relay/channel/siliconflow"
+	"one-api/relay/channel/task/generic"
+	"one-api/relay/channel/task/kling"
+	"one-api/relay/channel/task/runway"
+	"one-api/relay/channel/task/sora"
 	"one-api/relay/channel/task/suno"
 	"one-api/relay/channel/tencent"
 	"one-api/relay/channel/vertex"
@@ -101,3 +106,35 @@ func GetTaskAdaptor(platform commonconstant.TaskPlatform) channel.TaskAdaptor {
 	}
 	return nil
 }
+
+// GetAsyncTaskAdaptor 返回任务记录的上游协议对应的异步任务适配器，未单独适配的上游使用通用协议
+func GetAsyncTaskAdaptor(platform commonconstant.TaskPlatform) channel.AsyncTaskAdaptor {
+	switch platform {
+	case commonconstant.TaskPlatformSora:
+		return &sora.TaskAdaptor{}
+	case commonconstant.TaskPlatformKling:
+		return &kling.TaskAdaptor{}
+	case commonconstant.TaskPlatformRunway:
+		return &runway.TaskAdaptor{}
+	}
+	return &generic.TaskAdaptor{}
+}
+
+// GetVideoTaskPlatform 返回视频生成请求在该渠道类型上使用的上游协议，OpenAI 渠道对接 Sora
+func GetVideoTaskPlatform(channelType int) commonconstant.TaskPlatform {
+	switch channelType {
+	case common.ChannelTypeOpenAI:
+		return commonconstant.TaskPlatformSora
+	case common.ChannelTypeKling:
+		return commonconstant.TaskPlatformKling
+	case common.ChannelTypeRunway:
+		return commonconstant.TaskPlatformRunway
+	}
+	return commonconstant.TaskPlatformGeneric
+}
+
+// GetVideoContentAdaptor 返回需要由网关代理下载视频的适配器，上游直接返回视频地址时返回 false
+func GetVideoContentAdaptor(platform commonconstant.TaskPlatform) (channel.VideoContentAdaptor, bool) {
+	adaptor, ok := GetAsyncTaskAdaptor(platform).(channel.VideoContentAdaptor)
+	return adaptor, ok
+}
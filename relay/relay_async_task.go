@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validateAsyncTaskRequest(request *dto.AsyncTaskRequest) error {
+	if request.Model == "" {
+		return errors.New("model 不能为空")
+	}
+	if len(request.Input) == 0 {
+		return errors.New("input 不能为空")
+	}
+	if len(request.Kind) > 32 {
+		return errors.New("kind 过长")
+	}
+	if request.Units < 0 {
+		return errors.New("units 不能为负数")
+	}
+	if request.WebhookUrl != "" {
+		if err := service.ValidateTaskWebhookUrl(request.WebhookUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AsyncTaskSubmit 提交通用异步任务：按次价格 × 预估单位数预扣额度，上游受理后返回网关任务 ID，
+// 之后由后台轮询上游状态，结束时按实际用量结算
+func AsyncTaskSubmit(c *gin.Context) (*dto.AsyncTaskResponse, *dto.TaskError) {
+	var request dto.AsyncTaskRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return nil, service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	if err := validateAsyncTaskRequest(&request); err != nil {
+		return nil, service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	if request.Units < service.AsyncTaskMinUnits {
+		request.Units = service.AsyncTaskMinUnits
+	}
+	return submitAsyncTask(c, relaycommon.GenRelayInfo(c), &request, constant.TaskPlatformGeneric, 1)
+}
+
+// submitAsyncTask 预扣额度并提交到上游，priceMultiplier 为按请求参数（如视频分辨率）附加的价格倍率，计入任务的单位价格
+func submitAsyncTask(c *gin.Context, relayInfo *relaycommon.RelayInfo, request *dto.AsyncTaskRequest, platform constant.TaskPlatform, priceMultiplier float64) (*dto.AsyncTaskResponse, *dto.TaskError) {
+	if err := helper.ModelMappedHelper(c, relayInfo); err != nil {
+		return nil, service.TaskErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
+	}
+	modelPrice, ok := operation_setting.GetModelPrice(relayInfo.OriginModelName, false)
+	if !ok {
+		return nil, service.TaskErrorWrapperLocal(fmt.Errorf("模型 %s 未配置按次价格", relayInfo.OriginModelName), "model_price_not_set", http.StatusBadRequest)
+	}
+	unitPrice := modelPrice * priceMultiplier
+	groupRatio := setting.GetGroupRatio(relayInfo.Group)
+	quota := service.AsyncTaskQuota(unitPrice, groupRatio, request.Units)
+
+	payerQuota, err := service.GetPayerQuota(relayInfo)
+	if err != nil {
+		return nil, service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if service.GetPayerAvailableQuota(relayInfo, payerQuota)-quota < 0 {
+		return nil, service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
+	}
+	if !relayInfo.TokenUnlimited && c.GetInt("token_quota") < quota {
+		return nil, service.TaskErrorWrapperLocal(errors.New("token quota is not enough"), "quota_not_enough", http.StatusForbidden)
+	}
+	if openaiErr := service.PreConsumeQuotaLimits(relayInfo, quota); openaiErr != nil {
+		return nil, service.OpenAIErrorToTaskError(openaiErr)
+	}
+
+	adaptor := GetAsyncTaskAdaptor(platform)
+	upstreamTaskId, taskErr := adaptor.SubmitAsyncTask(c, relayInfo, request)
+	if taskErr != nil {
+		service.ReleaseModelQuota(relayInfo)
+		return nil, taskErr
+	}
+
+	task := &model.AsyncTask{
+		TaskId:         "task_" + strings.ReplaceAll(common.GetUUID(), "-", ""),
+		UpstreamTaskId: upstreamTaskId,
+		Kind:           request.Kind,
+		ModelName:      relayInfo.OriginModelName,
+		UserId:         relayInfo.UserId,
+		TokenId:        relayInfo.TokenId,
+		OrganizationId: relayInfo.OrganizationId,
+		ChannelId:      relayInfo.ChannelId,
+		Group:          relayInfo.Group,
+		Status:         model.AsyncTaskStatusQueued,
+		ModelPrice:     unitPrice,
+		GroupRatio:     groupRatio,
+		Units:          request.Units,
+		HeldQuota:      quota,
+		Input:          string(request.Input),
+		WebhookUrl:     request.WebhookUrl,
+		NextPollAt:     common.GetTimestamp() + int64(max(operation_setting.GetAsyncTaskSetting().PollIntervalSeconds, 1)),
+		Platform:       platform,
+	}
+	if err := task.Insert(); err != nil {
+		// 上游已受理但任务无法落库，不预扣额度，避免无法结算
+		service.ReleaseModelQuota(relayInfo)
+		return nil, service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
+	}
+	// 按预扣额度计入模型额度限制与预算，与消费日志记录的预扣额度一致
+	service.SettleQuotaLimits(relayInfo, quota)
+
+	if quota != 0 {
+		if err := service.PostConsumeQuota(relayInfo, quota, 0, true); err != nil {
+			common.SysError("error consuming token remain quota: " + err.Error())
+		}
+		logContent := fmt.Sprintf("异步任务 %s 预扣，模型固定价格 %.2f，分组倍率 %.2f，预估用量 %.2f", task.TaskId, modelPrice, groupRatio, request.Units)
+		other := map[string]interface{}{
+			"async_task_id": task.TaskId,
+			"model_price":   modelPrice,
+			"group_ratio":   groupRatio,
+			"units":         request.Units,
+		}
+		if priceMultiplier != 1 {
+			logContent += fmt.Sprintf("，价格倍率 %.2f", priceMultiplier)
+			other["price_multiplier"] = priceMultiplier
+		}
+		model.RecordConsumeLog(c, relayInfo.UserId, relayInfo.ChannelId, 0, 0, relayInfo.OriginModelName, c.GetString("token_name"),
+			quota, logContent, relayInfo.TokenId, payerQuota, 0, false, relayInfo.Group, other)
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	return service.AsyncTaskToDto(task), nil
+}
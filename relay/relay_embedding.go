@@ -12,6 +12,7 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/operation_setting"
 )
 
 func getEmbeddingPromptToken(embeddingRequest dto.EmbeddingRequest) int {
@@ -54,6 +55,14 @@ func EmbeddingHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 
 	embeddingRequest.Model = relayInfo.UpstreamModelName
 
+	embeddingCacheKey, cacheHit, openaiErr := lookupEmbeddingCache(c, relayInfo, embeddingRequest)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	if cacheHit {
+		return nil
+	}
+
 	promptToken := getEmbeddingPromptToken(*embeddingRequest)
 	relayInfo.PromptTokens = promptToken
 
@@ -105,7 +114,17 @@ func EmbeddingHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 		}
 	}
 
+	var finishResponseCapture func(*relaycommon.RelayInfo, *dto.Usage, bool) *service.ResponseCacheEntry
+	if embeddingCacheKey != "" {
+		finishResponseCapture = startResponseCapture(c, operation_setting.GetEmbeddingCacheSetting().MaxBodyBytes)
+	}
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	if finishResponseCapture != nil {
+		cachedUsage, _ := usage.(*dto.Usage)
+		if entry := finishResponseCapture(relayInfo, cachedUsage, openaiErr == nil); entry != nil {
+			service.SetEmbeddingCache(embeddingCacheKey, entry)
+		}
+	}
 	if openaiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
@@ -0,0 +1,169 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel/gemini"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateGeminiCachedContent 对话请求引用的上下文缓存必须由当前用户创建、未过期且属于本次选中的渠道
+func validateGeminiCachedContent(info *relaycommon.RelayInfo, cachedContent string) error {
+	cache, err := model.GetGeminiCachedContent(info.UserId, dto.GeminiCachedContentName(cachedContent))
+	if err != nil {
+		return fmt.Errorf("上下文缓存 %s 不存在", cachedContent)
+	}
+	if cache.IsExpired() {
+		return fmt.Errorf("上下文缓存 %s 已过期", cachedContent)
+	}
+	if cache.ChannelId != info.ChannelId {
+		return fmt.Errorf("上下文缓存 %s 不属于当前渠道", cachedContent)
+	}
+	return nil
+}
+
+// limitCachedContentTTL 将 expireTime 换算为 ttl 并限制在配置的最长存活时间内，未指定时使用最长存活时间；
+// 最长存活时间为 0 时不限制
+func limitCachedContentTTL(request map[string]interface{}) error {
+	maxTTL := float64(model_setting.GetGeminiSettings().CachedContentMaxTTLSeconds)
+	ttl := maxTTL
+	expireTime, hasExpireTime := request["expireTime"].(string)
+	if !hasExpireTime {
+		expireTime, hasExpireTime = request["expire_time"].(string)
+	}
+	if hasExpireTime {
+		expireAt, err := time.Parse(time.RFC3339Nano, expireTime)
+		if err != nil {
+			return errors.New("expireTime 格式错误")
+		}
+		ttl = time.Until(expireAt).Seconds()
+	} else if rawTTL, ok := request["ttl"].(string); ok {
+		seconds, err := strconv.ParseFloat(strings.TrimSuffix(rawTTL, "s"), 64)
+		if err != nil {
+			return errors.New("ttl 格式错误，应为如 300s 的秒数")
+		}
+		ttl = seconds
+	} else if maxTTL <= 0 {
+		return nil
+	}
+	if ttl <= 0 {
+		return errors.New("缓存存活时间必须大于 0")
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		return fmt.Errorf("缓存存活时间不能超过 %.0f 秒", maxTTL)
+	}
+	delete(request, "expireTime")
+	delete(request, "expire_time")
+	request["ttl"] = strconv.FormatFloat(ttl, 'f', 3, 64) + "s"
+	return nil
+}
+
+// GeminiCachedContentCreate 在选中的 Gemini 渠道上创建上下文缓存并记录归属，写入缓存的 token 按输入价格 × 缓存写入倍率计费，
+// 未单独配置缓存写入倍率的模型按 1 计；之后引用该缓存的对话请求固定发往该渠道
+func GeminiCachedContentCreate(c *gin.Context) ([]byte, *dto.OpenAIErrorWithStatusCode) {
+	var request map[string]interface{}
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	if err := limitCachedContentTTL(request); err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	relayInfo := relaycommon.GenRelayInfo(c)
+	if relayInfo.ChannelType != common.ChannelTypeGemini {
+		return nil, service.OpenAIErrorWrapperLocal(fmt.Errorf("模型 %s 的渠道不支持上下文缓存", relayInfo.OriginModelName), "channel_not_supported", http.StatusBadRequest)
+	}
+	if err := helper.ModelMappedHelper(c, relayInfo); err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
+	}
+	priceData, err := helper.ModelPriceHelper(c, relayInfo, 0, 0)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "model_price_error", http.StatusInternalServerError)
+	}
+	payerQuota, err := service.GetPayerQuota(relayInfo)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if service.GetPayerAvailableQuota(relayInfo, payerQuota) <= 0 {
+		return nil, service.OpenAIErrorWrapperLocal(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+
+	resp, err := gemini.CreateCachedContent(c, relayInfo, request)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	var cachedContent gemini.CachedContentResponse
+	if err := json.Unmarshal(responseBody, &cachedContent); err != nil || cachedContent.Name == "" {
+		return nil, service.OpenAIErrorWrapper(errors.New("上游未返回缓存名称"), "invalid_upstream_response", http.StatusInternalServerError)
+	}
+
+	cache := &model.GeminiCachedContent{
+		Name:        cachedContent.Name,
+		UserId:      relayInfo.UserId,
+		TokenId:     relayInfo.TokenId,
+		ChannelId:   relayInfo.ChannelId,
+		ModelName:   relayInfo.OriginModelName,
+		DisplayName: cachedContent.DisplayName,
+		TokenCount:  cachedContent.UsageMetadata.TotalTokenCount,
+		ExpireTime:  cachedContent.GetExpireTime(),
+	}
+	if err := cache.Insert(); err != nil {
+		// 无法记录归属时删除上游缓存，避免产生无法引用的缓存
+		if deleteErr := gemini.DeleteCachedContent(relayInfo.BaseUrl, relayInfo.ApiKey, cache.Name); deleteErr != nil {
+			common.SysError("failed to delete gemini cached content: " + deleteErr.Error())
+		}
+		return nil, service.OpenAIErrorWrapper(err, "insert_cached_content_failed", http.StatusInternalServerError)
+	}
+
+	var quota int
+	var logContent string
+	if priceData.UsePrice {
+		quota = int(math.Round(priceData.ModelPrice * common.QuotaPerUnit * priceData.GroupRatio))
+		logContent = fmt.Sprintf("创建上下文缓存 %s，模型价格 %.2f，分组倍率 %.2f", cache.Name, priceData.ModelPrice, priceData.GroupRatio)
+	} else {
+		cacheCreationRatio, ok := operation_setting.GetCreateCacheRatio(relayInfo.OriginModelName)
+		if !ok {
+			cacheCreationRatio = 1
+		}
+		quota = int(math.Round(float64(cache.TokenCount) * priceData.ModelRatio * cacheCreationRatio * priceData.GroupRatio))
+		logContent = fmt.Sprintf("创建上下文缓存 %s，模型倍率 %.2f，缓存写入倍率 %.2f，分组倍率 %.2f", cache.Name, priceData.ModelRatio, cacheCreationRatio, priceData.GroupRatio)
+	}
+	if quota != 0 {
+		if err := service.PostConsumeQuota(relayInfo, quota, 0, true); err != nil {
+			common.SysError("error consuming token remain quota: " + err.Error())
+		}
+		other := map[string]interface{}{
+			"cached_content": cache.Name,
+			"model_ratio":    priceData.ModelRatio,
+			"group_ratio":    priceData.GroupRatio,
+		}
+		model.RecordConsumeLog(c, relayInfo.UserId, relayInfo.ChannelId, cache.TokenCount, 0, relayInfo.OriginModelName, c.GetString("token_name"),
+			quota, logContent, relayInfo.TokenId, payerQuota, 0, false, relayInfo.Group, other)
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	return responseBody, nil
+}
@@ -51,16 +51,26 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	// 预扣
 	groupRatio := setting.GetGroupRatio(relayInfo.Group)
 	ratio := modelPrice * groupRatio
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := service.GetPayerQuota(relayInfo.RelayInfo)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
 	}
 	quota := int(ratio * common.QuotaPerUnit)
-	if userQuota-quota < 0 {
+	if service.GetPayerAvailableQuota(relayInfo.RelayInfo, userQuota)-quota < 0 {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
 	}
+	relayInfo.OriginModelName = modelName
+	if openaiErr := service.PreConsumeQuotaLimits(relayInfo.RelayInfo, quota); openaiErr != nil {
+		taskErr = service.OpenAIErrorToTaskError(openaiErr)
+		return
+	}
+	defer func() {
+		if taskErr != nil {
+			service.ReleaseModelQuota(relayInfo.RelayInfo)
+		}
+	}()
 
 	if relayInfo.OriginTaskID != "" {
 		originTask, exist, err := model.GetByTaskId(relayInfo.UserId, relayInfo.OriginTaskID)
@@ -117,6 +127,7 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 			if err != nil {
 				common.SysError("error consuming token remain quota: " + err.Error())
 			}
+			service.SettleQuotaLimits(relayInfo.RelayInfo, quota)
 			if quota != 0 {
 				tokenName := c.GetString("token_name")
 				logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s", modelPrice, groupRatio, relayInfo.Action)
@@ -0,0 +1,94 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validateVideoGenerationRequest(request *dto.VideoGenerationRequest) error {
+	if request.Model == "" {
+		return errors.New("model 不能为空")
+	}
+	if request.Prompt == "" && request.Image == "" {
+		return errors.New("prompt 与 image 不能同时为空")
+	}
+	if request.Seconds < 0 {
+		return errors.New("seconds 不能为负数")
+	}
+	if maxSeconds := operation_setting.GetVideoSetting().MaxSeconds; maxSeconds > 0 && request.Seconds > maxSeconds {
+		return fmt.Errorf("seconds 不能超过 %d", maxSeconds)
+	}
+	for key := range request.Extra {
+		for _, billingKey := range dto.VideoBillingExtraKeys {
+			if strings.EqualFold(key, billingKey) {
+				return fmt.Errorf("extra 不能包含影响计费的参数 %s", key)
+			}
+		}
+	}
+	switch request.AspectRatio {
+	case "", "16:9", "9:16", "1:1":
+	default:
+		return errors.New("aspect_ratio 仅支持 16:9、9:16 与 1:1")
+	}
+	if request.WebhookUrl != "" {
+		if err := service.ValidateTaskWebhookUrl(request.WebhookUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VideoGenerationSubmit 提交视频生成任务：按模型按秒价格 × 分辨率倍率 × 秒数预扣额度，
+// 根据渠道类型转换为 Sora、Kling、Runway 或通用协议的请求，结束时按上游返回的实际时长结算
+func VideoGenerationSubmit(c *gin.Context) (*dto.AsyncTaskResponse, *dto.TaskError) {
+	var videoRequest dto.VideoGenerationRequest
+	if err := common.UnmarshalBodyReusable(c, &videoRequest); err != nil {
+		return nil, service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	if err := validateVideoGenerationRequest(&videoRequest); err != nil {
+		return nil, service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	videoSetting := operation_setting.GetVideoSetting()
+	if videoRequest.Seconds == 0 {
+		videoRequest.Seconds = max(videoSetting.DefaultSeconds, 1)
+	}
+	if videoRequest.Resolution == "" {
+		videoRequest.Resolution = videoSetting.DefaultResolution
+	}
+	// 计费倍率与上游参数都按小写分辨率查找，在此统一转换一次
+	videoRequest.Resolution = strings.ToLower(strings.TrimSpace(videoRequest.Resolution))
+
+	relayInfo := relaycommon.GenRelayInfo(c)
+	webhookUrl := videoRequest.WebhookUrl
+	// 回调地址仅网关使用，不转发给上游
+	videoRequest.WebhookUrl = ""
+	input, err := json.Marshal(videoRequest)
+	if err != nil {
+		return nil, service.TaskErrorWrapperLocal(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	request := &dto.AsyncTaskRequest{
+		Model:      videoRequest.Model,
+		Kind:       model.AsyncTaskKindVideo,
+		Input:      input,
+		Units:      float64(videoRequest.Seconds),
+		WebhookUrl: webhookUrl,
+	}
+	multiplier := operation_setting.GetVideoResolutionMultiplier(relayInfo.OriginModelName, videoRequest.Resolution)
+	response, taskErr := submitAsyncTask(c, relayInfo, request, GetVideoTaskPlatform(relayInfo.ChannelType), multiplier)
+	if taskErr != nil {
+		return nil, taskErr
+	}
+	response.Object = "video.generation"
+	return response, nil
+}
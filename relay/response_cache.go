@@ -0,0 +1,268 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCacheWriter 在写出响应的同时缓冲完整响应体，超过 limit 后放弃缓存
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *responseCacheWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// lookupResponseCache 返回本次请求的缓存键（未启用缓存时为空），命中时直接写出缓存的响应并记录不扣费的消费日志
+// 缓存键基于注入提示词模板后的请求计算，并按用户、令牌与分组隔离，不同令牌不会共用缓存
+func lookupResponseCache(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (string, bool, *dto.OpenAIErrorWithStatusCode) {
+	// 脱敏后的请求只保留占位符，不同原始内容会得到相同的缓存键
+	if !service.ResponseCacheEnabled(c, relayInfo.OriginModelName, relayInfo.IsStream) || service.GetPIIRedactor(c) != nil {
+		return "", false, nil
+	}
+	body, err := json.Marshal(textRequest)
+	if err != nil {
+		return "", false, nil
+	}
+	key, err := service.ResponseCacheScopedKey(relayInfo, body)
+	if err != nil {
+		return "", false, nil
+	}
+	entry, ok := service.GetResponseCache(key)
+	if !ok {
+		return key, false, nil
+	}
+	if openaiErr := serveCachedResponse(c, relayInfo, entry, "命中响应缓存，不扣费", map[string]interface{}{
+		"response_cache_hit": true,
+	}); openaiErr != nil {
+		return key, false, openaiErr
+	}
+	return key, true, nil
+}
+
+// checkCachedResponseAllowed 命中缓存虽不扣费，仍需通过限流、预算硬上限与额度检查，
+// 避免超限或额度用尽的令牌通过缓存绕过限制
+func checkCachedResponseAllowed(c *gin.Context, relayInfo *relaycommon.RelayInfo, entry *service.ResponseCacheEntry) *dto.OpenAIErrorWithStatusCode {
+	relayInfo.PromptTokens = entry.PromptTokens
+	if !c.GetBool(constant.ContextKeyRateLimitChecked) {
+		if openaiErr := service.CheckTokenRateLimit(c, relayInfo); openaiErr != nil {
+			return openaiErr
+		}
+		c.Set(constant.ContextKeyRateLimitChecked, true)
+	}
+	// 仅检查预算与模型额度是否已用尽，不保留预留
+	if openaiErr := service.PreConsumeQuotaLimits(relayInfo, 0); openaiErr != nil {
+		return openaiErr
+	}
+	service.ReleaseModelQuota(relayInfo)
+	userQuota, err := service.GetPayerQuota(relayInfo)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if service.GetPayerAvailableQuota(relayInfo, userQuota) <= 0 {
+		return service.OpenAIErrorWrapperLocal(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+	return nil
+}
+
+// serveCachedResponse 检查通过后写出缓存的响应，并按缓存时的用量记录不扣费的消费日志
+func serveCachedResponse(c *gin.Context, relayInfo *relaycommon.RelayInfo, entry *service.ResponseCacheEntry, content string, other map[string]interface{}) *dto.OpenAIErrorWithStatusCode {
+	if openaiErr := checkCachedResponseAllowed(c, relayInfo, entry); openaiErr != nil {
+		return openaiErr
+	}
+	c.Header("X-Response-Cache", "HIT")
+	c.Data(http.StatusOK, entry.ContentType, entry.Body)
+
+	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
+	model.RecordConsumeLog(c, relayInfo.UserId, 0, entry.PromptTokens, entry.CompletionTokens, relayInfo.OriginModelName,
+		c.GetString("token_name"), 0, content, relayInfo.TokenId, c.GetInt(constant.ContextKeyUserQuota),
+		int(useTimeSeconds), false, relayInfo.Group, other)
+	model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, 0)
+	service.RecordTokenRateLimitUsage(relayInfo, entry.CompletionTokens)
+	return nil
+}
+
+// lookupSemanticCache 计算提示词向量并在同一分桶内查找语义相近的缓存，向量化费用无论是否命中都会扣除；
+// 返回的分桶键与向量用于未命中时写入缓存，向量化失败时返回 nil 并按正常流程请求上游
+func lookupSemanticCache(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (string, []float64, bool, *dto.OpenAIErrorWithStatusCode) {
+	if relayInfo.RelayMode != relayconstant.RelayModeChatCompletions || !service.SemanticCacheEnabled(c, relayInfo) || service.GetPIIRedactor(c) != nil {
+		return "", nil, false, nil
+	}
+	scope, err := service.SemanticCacheScope(relayInfo, textRequest)
+	if err != nil {
+		return "", nil, false, nil
+	}
+	// 重试时复用首次计算的向量，避免重复扣费
+	vector, _ := c.Value(constant.ContextKeySemanticVector).([]float64)
+	if vector == nil {
+		var promptTokens int
+		vector, promptTokens, err = semanticCacheEmbedding(c, relayInfo, service.SemanticCacheText(textRequest.Messages))
+		if err != nil {
+			common.LogWarn(c, "semantic cache embedding failed: "+err.Error())
+			return "", nil, false, nil
+		}
+		service.ChargeSemanticCacheEmbedding(c, relayInfo, promptTokens)
+		c.Set(constant.ContextKeySemanticVector, vector)
+	}
+	entry, score, ok := service.GetSemanticCache(scope, vector)
+	if !ok {
+		return scope, vector, false, nil
+	}
+	if openaiErr := serveCachedResponse(c, relayInfo, entry, "命中语义缓存，仅扣除向量化费用", map[string]interface{}{
+		"semantic_cache_hit": true,
+		"similarity":         score,
+	}); openaiErr != nil {
+		return scope, vector, false, openaiErr
+	}
+	return scope, vector, true, nil
+}
+
+// semanticCacheEmbedding 按请求的路由标签策略选择向量模型渠道，并通过适配器发起请求，
+// 与普通向量请求一样处理渠道类型、模型映射、代理等渠道设置；返回向量与消耗的 token 数
+func semanticCacheEmbedding(c *gin.Context, relayInfo *relaycommon.RelayInfo, text string) ([]float64, int, error) {
+	embeddingModel := operation_setting.GetSemanticCacheSetting().EmbeddingModel
+	channel, err := middleware.CacheGetRandomSatisfiedChannel(c, relayInfo.Group, embeddingModel, 0, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if channel == nil {
+		return nil, 0, fmt.Errorf("no available channel for embedding model %s", embeddingModel)
+	}
+
+	// 在独立的上下文中完成向量请求，响应写入内存而不是客户端，渠道信息也不会覆盖本次对话请求的上下文
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: "/v1/embeddings"},
+		Header: make(http.Header),
+	}).WithContext(c.Request.Context())
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	for key, value := range c.Keys {
+		ctx.Set(key, value)
+	}
+	middleware.SetupContextForSelectedChannel(ctx, channel, embeddingModel)
+
+	info := relaycommon.GenRelayInfo(ctx)
+	if err = helper.ModelMappedHelper(ctx, info); err != nil {
+		return nil, 0, err
+	}
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return nil, 0, fmt.Errorf("invalid api type: %d", info.ApiType)
+	}
+	adaptor.Init(info)
+	convertedRequest, err := adaptor.ConvertEmbeddingRequest(ctx, info, dto.EmbeddingRequest{Model: info.UpstreamModelName, Input: text})
+	if err != nil {
+		return nil, 0, err
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := adaptor.DoRequest(ctx, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, 0, err
+	}
+	httpResp, _ := resp.(*http.Response)
+	if httpResp != nil && httpResp.StatusCode != http.StatusOK {
+		openaiErr := service.RelayErrorHandler(httpResp, false)
+		return nil, 0, fmt.Errorf("embedding request failed with status code %d: %s", openaiErr.StatusCode, openaiErr.Error.Message)
+	}
+	usage, openaiErr := adaptor.DoResponse(ctx, httpResp, info)
+	if openaiErr != nil {
+		return nil, 0, fmt.Errorf("embedding request failed: %s", openaiErr.Error.Message)
+	}
+	var embeddingResponse dto.OpenAIEmbeddingResponse
+	if err = json.Unmarshal(recorder.Body.Bytes(), &embeddingResponse); err != nil {
+		return nil, 0, err
+	}
+	if len(embeddingResponse.Data) == 0 {
+		return nil, 0, errors.New("embedding response is empty")
+	}
+	promptTokens := embeddingResponse.Usage.PromptTokens
+	if embeddingUsage, ok := usage.(*dto.Usage); ok && embeddingUsage != nil {
+		promptTokens = embeddingUsage.PromptTokens
+	}
+	return embeddingResponse.Data[0].Embedding, promptTokens, nil
+}
+
+// startResponseCapture 替换 c.Writer 以缓冲响应，返回的 finish 恢复原 Writer，请求成功且响应可缓存时返回缓存条目
+func startResponseCapture(c *gin.Context, limit int) func(relayInfo *relaycommon.RelayInfo, usage *dto.Usage, success bool) *service.ResponseCacheEntry {
+	original := c.Writer
+	writer := &responseCacheWriter{ResponseWriter: original, limit: limit}
+	c.Writer = writer
+	return func(relayInfo *relaycommon.RelayInfo, usage *dto.Usage, success bool) *service.ResponseCacheEntry {
+		c.Writer = original
+		if !success || relayInfo.IsStream || writer.overflow || writer.Status() != http.StatusOK || usage == nil {
+			return nil
+		}
+		return &service.ResponseCacheEntry{
+			Body:             writer.body.Bytes(),
+			ContentType:      writer.Header().Get("Content-Type"),
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+		}
+	}
+}
+
+// lookupEmbeddingCache 返回向量请求的缓存键（未启用缓存时为空），命中时直接写出缓存的响应，不再请求上游
+func lookupEmbeddingCache(c *gin.Context, relayInfo *relaycommon.RelayInfo, embeddingRequest *dto.EmbeddingRequest) (string, bool, *dto.OpenAIErrorWithStatusCode) {
+	if relayInfo.RelayMode != relayconstant.RelayModeEmbeddings || !operation_setting.GetEmbeddingCacheSetting().Enabled {
+		return "", false, nil
+	}
+	key, err := service.EmbeddingCacheKey(relayInfo.OriginModelName, embeddingRequest)
+	if err != nil {
+		return "", false, nil
+	}
+	entry, ok := service.GetEmbeddingCache(key)
+	if !ok {
+		return key, false, nil
+	}
+	if openaiErr := serveCachedResponse(c, relayInfo, entry, "命中向量缓存，不扣费", map[string]interface{}{
+		"embedding_cache_hit": true,
+	}); openaiErr != nil {
+		return key, false, openaiErr
+	}
+	return key, true, nil
+}
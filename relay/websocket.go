@@ -1,7 +1,6 @@
 package relay
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -9,6 +8,7 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/operation_setting"
@@ -28,13 +28,12 @@ func WssHelper(c *gin.Context, ws *websocket.Conn) (openaiErr *dto.OpenAIErrorWi
 	modelMapping := c.GetString("model_mapping")
 	//isModelMapped := false
 	if modelMapping != "" && modelMapping != "{}" {
-		modelMap := make(map[string]string)
-		err := json.Unmarshal([]byte(modelMapping), &modelMap)
+		modelMap, err := helper.ParseModelMapping(modelMapping)
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
 		}
-		if modelMap[relayInfo.OriginModelName] != "" {
-			relayInfo.UpstreamModelName = modelMap[relayInfo.OriginModelName]
+		if mappedModel, ok := modelMap.Map(relayInfo.OriginModelName); ok {
+			relayInfo.UpstreamModelName = mappedModel
 			// set upstream model name
 			//isModelMapped = true
 		}
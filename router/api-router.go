@@ -60,6 +60,11 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/amount", controller.RequestAmount)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
+				selfRoute.GET("/packages", controller.GetSelfPackages)
+				selfRoute.GET("/organization", controller.GetSelfOrganization)
+				selfRoute.GET("/organization/members", controller.GetSelfOrganizationMembers)
+				selfRoute.GET("/organization/usage", controller.GetSelfOrganizationUsage)
+				selfRoute.GET("/organization/series", controller.GetSelfOrganizationSeries)
 			}
 
 			adminRoute := userRoute.Group("/")
@@ -70,10 +75,32 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.GET("/:id", controller.GetUser)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
+				adminRoute.POST("/quota/batch", controller.BatchAdjustUserQuota)
 				adminRoute.PUT("/", controller.UpdateUser)
+				adminRoute.PUT("/rate_limit", controller.UpdateUserRateLimit)
+				adminRoute.PUT("/model_quota_limits", controller.UpdateUserModelQuotaLimits)
+				adminRoute.PUT("/credit_limit", controller.UpdateUserCreditLimit)
+				adminRoute.GET("/aging", controller.GetOutstandingAging)
 				adminRoute.DELETE("/:id", controller.DeleteUser)
 			}
 		}
+		pricingConfigRoute := apiRouter.Group("/pricing_config")
+		pricingConfigRoute.Use(middleware.RootAuth())
+		{
+			pricingConfigRoute.GET("/", controller.ListPricingConfigTypes)
+			pricingConfigRoute.GET("/:kind", controller.GetPricingConfig)
+			pricingConfigRoute.PATCH("/:kind", controller.UpsertPricingConfig)
+			pricingConfigRoute.PUT("/:kind", controller.ReplacePricingConfig)
+			pricingConfigRoute.DELETE("/:kind", controller.DeletePricingConfig)
+		}
+		groupConfigRoute := apiRouter.Group("/group_config")
+		groupConfigRoute.Use(middleware.RootAuth())
+		{
+			groupConfigRoute.GET("/", controller.ListGroupConfigs)
+			groupConfigRoute.GET("/:name", controller.GetGroupConfig)
+			groupConfigRoute.PUT("/:name", controller.SaveGroupConfig)
+			groupConfigRoute.DELETE("/:name", controller.DeleteGroupConfig)
+		}
 		optionRoute := apiRouter.Group("/option")
 		optionRoute.Use(middleware.RootAuth())
 		{
@@ -86,11 +113,20 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			channelRoute.GET("/", controller.GetAllChannels)
 			channelRoute.GET("/search", controller.SearchChannels)
+			channelRoute.GET("/export", controller.ExportChannels)
+			channelRoute.POST("/import", controller.ImportChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.GET("/circuit", controller.GetChannelCircuits)
+			channelRoute.GET("/stats", controller.GetChannelModelStats)
+			channelRoute.GET("/concurrency", controller.GetConcurrencyStats)
+			channelRoute.GET("/health", controller.GetChannelHealthSummaries)
+			channelRoute.GET("/health/:id", controller.GetChannelHealth)
+			channelRoute.POST("/probe/:id", controller.ProbeChannel)
+			channelRoute.DELETE("/circuit/:id", controller.ResetChannelCircuit)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -115,6 +151,9 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
+			tokenRoute.PUT("/model_quota_limits", middleware.AdminAuth(), controller.UpdateTokenModelQuotaLimits)
+			tokenRoute.PUT("/admin_setting", middleware.AdminAuth(), controller.UpdateTokenAdminSetting)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
@@ -126,6 +165,67 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		packageRoute := apiRouter.Group("/package")
+		packageRoute.Use(middleware.AdminAuth())
+		{
+			packageRoute.GET("/", controller.GetAllPackages)
+			packageRoute.GET("/:id", controller.GetPackage)
+			packageRoute.POST("/", controller.AddPackage)
+			packageRoute.PUT("/", controller.UpdatePackage)
+			packageRoute.DELETE("/:id", controller.DeletePackage)
+			packageRoute.POST("/grant", controller.GrantPackage)
+			packageRoute.GET("/user/:id", controller.GetUserPackages)
+		}
+		quotaGrantScheduleRoute := apiRouter.Group("/quota_grant_schedule")
+		quotaGrantScheduleRoute.Use(middleware.AdminAuth())
+		{
+			quotaGrantScheduleRoute.GET("/", controller.GetAllQuotaGrantSchedules)
+			quotaGrantScheduleRoute.GET("/:id", controller.GetQuotaGrantSchedule)
+			quotaGrantScheduleRoute.POST("/", controller.AddQuotaGrantSchedule)
+			quotaGrantScheduleRoute.PUT("/", controller.UpdateQuotaGrantSchedule)
+			quotaGrantScheduleRoute.DELETE("/:id", controller.DeleteQuotaGrantSchedule)
+			quotaGrantScheduleRoute.POST("/:id/run", controller.RunQuotaGrantSchedule)
+		}
+		apiRouter.GET("/async_task/", middleware.AdminAuth(), controller.GetAllAsyncTasks)
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.AdminAuth())
+		{
+			organizationRoute.GET("/", controller.GetAllOrganizations)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.POST("/", controller.AddOrganization)
+			organizationRoute.PUT("/", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.POST("/:id/quota", controller.AdjustOrganizationQuota)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/members", controller.AddOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.GET("/:id/usage", controller.GetOrganizationUsage)
+		}
+		apiRouter.GET("/statement/:id", middleware.AdminAuth(), controller.GetUserStatement)
+		analyticsRoute := apiRouter.Group("/analytics")
+		{
+			analyticsRoute.GET("/cost", middleware.AdminAuth(), controller.GetCostBreakdown)
+			analyticsRoute.GET("/self/cost", middleware.UserAuth(), controller.GetSelfCostBreakdown)
+			analyticsRoute.GET("/series", middleware.AdminAuth(), controller.GetUsageSeries)
+			analyticsRoute.GET("/self/series", middleware.UserAuth(), controller.GetSelfUsageSeries)
+		}
+		budgetRoute := apiRouter.Group("/budget")
+		budgetRoute.Use(middleware.AdminAuth())
+		{
+			budgetRoute.GET("/", controller.GetAllBudgets)
+			budgetRoute.GET("/:id", controller.GetBudget)
+			budgetRoute.POST("/", controller.AddBudget)
+			budgetRoute.PUT("/", controller.UpdateBudget)
+			budgetRoute.DELETE("/:id", controller.DeleteBudget)
+		}
+		webhookRoute := apiRouter.Group("/webhook")
+		webhookRoute.Use(middleware.RootAuth())
+		{
+			webhookRoute.POST("/test", controller.TestWebhookEvent)
+			webhookRoute.GET("/dead_letter", controller.GetWebhookDeadLetters)
+			webhookRoute.POST("/dead_letter/:id/retry", controller.RetryWebhookDeadLetter)
+			webhookRoute.DELETE("/dead_letter/:id", controller.DeleteWebhookDeadLetter)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -134,6 +234,8 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		logRoute.GET("/capture/:request_id", middleware.AdminAuth(), controller.GetRequestCaptures)
+		logRoute.POST("/export", middleware.RootAuth(), controller.ExportUsage)
 
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
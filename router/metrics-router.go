@@ -0,0 +1,21 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/middleware"
+)
+
+func SetMetricsRouter(router *gin.Engine) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	// 指标包含用量与渠道信息，未配置访问令牌时不开放接口
+	if constant.MetricsToken == "" {
+		common.SysLog("METRICS_TOKEN is not set, /metrics endpoint is disabled")
+		return
+	}
+	router.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(promhttp.Handler()))
+}
@@ -18,13 +18,41 @@ func SetRelayRouter(router *gin.Engine) {
 		modelsRouter.GET("", controller.ListModels)
 		modelsRouter.GET("/:model", controller.RetrieveModel)
 	}
+	tokenizeRouter := router.Group("/v1/tokenize")
+	tokenizeRouter.Use(middleware.LoadShedding())
+	tokenizeRouter.Use(middleware.TokenAuth())
+	tokenizeRouter.Use(middleware.ModelRequestRateLimit())
+	{
+		tokenizeRouter.POST("", controller.Tokenize)
+	}
+	asyncTaskRouter := router.Group("/v1/tasks")
+	asyncTaskRouter.Use(middleware.TokenAuth())
+	{
+		asyncTaskRouter.GET("", controller.ListAsyncTasks)
+		asyncTaskRouter.GET("/:id", controller.GetAsyncTask)
+	}
+	videoRouter := router.Group("/v1/video/generations")
+	videoRouter.Use(middleware.TokenAuth())
+	{
+		videoRouter.GET("/:id", controller.GetVideoGeneration)
+		videoRouter.GET("/:id/content", controller.GetVideoGenerationContent)
+	}
+	cachedContentRouter := router.Group("/v1/cached_contents")
+	cachedContentRouter.Use(middleware.TokenAuth())
+	{
+		cachedContentRouter.GET("", controller.ListGeminiCachedContents)
+		cachedContentRouter.DELETE("/:id", controller.DeleteGeminiCachedContent)
+	}
 	playgroundRouter := router.Group("/pg")
 	playgroundRouter.Use(middleware.UserAuth())
 	{
 		playgroundRouter.POST("/chat/completions", controller.Playground)
 	}
 	relayV1Router := router.Group("/v1")
+	relayV1Router.Use(middleware.Tracing())
+	relayV1Router.Use(middleware.LoadShedding())
 	relayV1Router.Use(middleware.TokenAuth())
+	relayV1Router.Use(middleware.BodyCapture())
 	relayV1Router.Use(middleware.ModelRequestRateLimit())
 	{
 		// WebSocket 路由
@@ -36,7 +64,10 @@ func SetRelayRouter(router *gin.Engine) {
 		//http router
 		httpRouter := relayV1Router.Group("")
 		httpRouter.Use(middleware.Distribute())
+		httpRouter.Use(middleware.GatewayHeaders())
+		httpRouter.Use(middleware.ResponseCompression())
 		httpRouter.POST("/messages", controller.RelayClaude)
+		httpRouter.POST("/messages/count_tokens", controller.ClaudeCountTokens)
 		httpRouter.POST("/completions", controller.Relay)
 		httpRouter.POST("/chat/completions", controller.Relay)
 		httpRouter.POST("/edits", controller.Relay)
@@ -49,6 +80,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/audio/translations", controller.Relay)
 		httpRouter.POST("/audio/speech", controller.Relay)
 		httpRouter.POST("/responses", controller.Relay)
+		httpRouter.POST("/tasks", controller.SubmitAsyncTask)
+		httpRouter.POST("/video/generations", controller.SubmitVideoGeneration)
+		httpRouter.POST("/cached_contents", controller.CreateGeminiCachedContent)
 		httpRouter.GET("/files", controller.RelayNotImplemented)
 		httpRouter.POST("/files", controller.RelayNotImplemented)
 		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"syscall"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const AsyncTaskWebhookEvent = "async_task.finished"
+
+// AsyncTaskMinUnits 单个任务最少按 1 个单位计费，避免客户端声明极小的用量而上游又不返回实际用量
+const AsyncTaskMinUnits = 1
+
+// AsyncTaskQuota 按次价格 × 分组倍率 × 单位数计算额度，单位数不足 AsyncTaskMinUnits 时按 AsyncTaskMinUnits 计
+func AsyncTaskQuota(modelPrice float64, groupRatio float64, units float64) int {
+	return int(math.Round(modelPrice * groupRatio * max(units, AsyncTaskMinUnits) * common.QuotaPerUnit))
+}
+
+// isPublicIP 回调地址不能是回环、私有、链路本地、未指定或组播地址
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// ValidateTaskWebhookUrl 用户提交的回调地址必须是 http 或 https，且域名解析到的所有地址都是公网地址，避免借回调访问内网
+func ValidateTaskWebhookUrl(rawUrl string) error {
+	if len(rawUrl) > 512 {
+		return errors.New("webhook_url 过长")
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook_url 必须是 http 或 https 地址")
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return errors.New("webhook_url 的域名无法解析")
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return errors.New("webhook_url 不能指向内网、回环或链路本地地址")
+		}
+	}
+	return nil
+}
+
+// taskWebhookHttpClient 投递用户回调的客户端，在连接时再次检查目标地址，防止提交后通过 DNS 重新绑定或重定向指向内网；
+// 不使用代理，否则检查的是代理地址
+var taskWebhookHttpClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return fmt.Errorf("webhook address %s is not allowed", address)
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+func AsyncTaskToDto(task *model.AsyncTask) *dto.AsyncTaskResponse {
+	response := &dto.AsyncTaskResponse{
+		Id:         task.TaskId,
+		Object:     "task",
+		Model:      task.ModelName,
+		Kind:       task.Kind,
+		Status:     task.Status,
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		Units:      task.Units,
+		Quota:      task.Quota,
+		CreatedAt:  task.CreatedAt,
+		FinishedAt: task.FinishedAt,
+	}
+	if !task.IsFinished() {
+		response.Quota = task.HeldQuota
+	}
+	if task.Result != "" {
+		response.Result = json.RawMessage(task.Result)
+	}
+	return response
+}
+
+// asyncTaskPayer 结算在后台进行，按任务记录的用户、组织与令牌构造扣费信息
+func asyncTaskPayer(task *model.AsyncTask) *relaycommon.RelayInfo {
+	info := &relaycommon.RelayInfo{
+		UserId:         task.UserId,
+		TokenId:        task.TokenId,
+		OrganizationId: task.OrganizationId,
+	}
+	if token, err := model.GetTokenById(task.TokenId); err == nil {
+		info.TokenKey = token.Key
+	}
+	return info
+}
+
+// UpdateAsyncTask 根据上游返回的状态更新任务：未结束时推迟下一次轮询，超时或结束时结算额度并回调
+func UpdateAsyncTask(task *model.AsyncTask, upstream *dto.AsyncTaskResponse) {
+	setting := operation_setting.GetAsyncTaskSetting()
+	now := common.GetTimestamp()
+	if upstream.Status != model.AsyncTaskStatusSucceeded && upstream.Status != model.AsyncTaskStatusFailed {
+		if setting.TimeoutSeconds > 0 && now-task.CreatedAt > int64(setting.TimeoutSeconds) {
+			task.Status = model.AsyncTaskStatusFailed
+			task.FailReason = "任务超时"
+			SettleAsyncTask(task)
+			return
+		}
+		task.Status = upstream.Status
+		task.Progress = min(max(upstream.Progress, 0), 99)
+		task.NextPollAt = now + int64(max(setting.PollIntervalSeconds, 1))
+		if err := model.UpdateAsyncTaskProgress(task); err != nil {
+			common.SysError(fmt.Sprintf("failed to update async task %s: %s", task.TaskId, err.Error()))
+		}
+		return
+	}
+	task.Status = upstream.Status
+	task.FailReason = upstream.FailReason
+	if len(upstream.Result) > 0 {
+		task.Result = string(upstream.Result)
+	}
+	if upstream.Units > 0 {
+		task.Units = upstream.Units
+	}
+	SettleAsyncTask(task)
+}
+
+// SettleAsyncTask 结束任务并结算：失败退还全部预扣额度，成功按实际单位数多退少补
+func SettleAsyncTask(task *model.AsyncTask) {
+	task.Progress = 100
+	task.Quota = 0
+	if task.Status == model.AsyncTaskStatusSucceeded {
+		task.Quota = AsyncTaskQuota(task.ModelPrice, task.GroupRatio, task.Units)
+	} else if task.FailReason == "" {
+		task.FailReason = "上游任务执行失败"
+	}
+	claimed, err := model.FinishAsyncTask(task)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to finish async task %s: %s", task.TaskId, err.Error()))
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	delta := task.Quota - task.HeldQuota
+	if delta != 0 {
+		if err := PostConsumeQuota(asyncTaskPayer(task), delta, 0, false); err != nil {
+			common.SysError(fmt.Sprintf("failed to settle async task %s: %s", task.TaskId, err.Error()))
+		}
+		model.UpdateAsyncTaskUsedQuota(task, delta)
+	}
+	switch {
+	case delta > 0:
+		model.RecordAsyncTaskConsumeLog(task, delta, fmt.Sprintf("异步任务 %s 按实际用量 %.2f 结算，补扣 %s", task.TaskId, task.Units, common.LogQuota(delta)),
+			map[string]interface{}{"async_task_id": task.TaskId, "model_price": task.ModelPrice, "group_ratio": task.GroupRatio, "units": task.Units})
+	case delta < 0 && task.Status == model.AsyncTaskStatusFailed:
+		model.RecordLog(task.UserId, model.LogTypeSystem, fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskId, common.LogQuota(-delta)))
+	case delta < 0:
+		model.RecordLog(task.UserId, model.LogTypeSystem, fmt.Sprintf("异步任务 %s 按实际用量 %.2f 结算，退还 %s", task.TaskId, task.Units, common.LogQuota(-delta)))
+	}
+	if task.WebhookUrl != "" {
+		gopool.Go(func() {
+			deliverAsyncTaskWebhook(task)
+		})
+	}
+}
+
+// deliverAsyncTaskWebhook 将结束后的任务状态回调给提交时指定的地址，使用用户配置的 webhook 密钥签名
+func deliverAsyncTaskWebhook(task *model.AsyncTask) {
+	event := dto.WebhookEvent{
+		Id:        common.GetUUID(),
+		Type:      AsyncTaskWebhookEvent,
+		Timestamp: time.Now().Unix(),
+		Data:      map[string]interface{}{"task": AsyncTaskToDto(task)},
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		common.SysError("failed to marshal async task webhook: " + err.Error())
+		return
+	}
+	var secret string
+	if user, err := model.GetUserById(task.UserId, false); err == nil {
+		secret, _ = user.GetSetting()[constant.UserSettingWebhookSecret].(string)
+	}
+	maxRetries := max(operation_setting.GetAsyncTaskSetting().WebhookMaxRetries, 0)
+	for i := 0; i <= maxRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(1<<(i-1)) * time.Second)
+		}
+		if err = postWebhookEvent(taskWebhookHttpClient, task.WebhookUrl, secret, event.Id, event.Type, payload); err == nil {
+			return
+		}
+	}
+	common.SysError(fmt.Sprintf("async task %s webhook to %s failed: %s", task.TaskId, task.WebhookUrl, err.Error()))
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 )
 
@@ -15,6 +16,16 @@ func parseAudio(audioBase64 string, format string) (duration float64, err error)
 	var sampleRate int
 
 	switch format {
+	case "wav":
+		// 按 WAV 头中的字节率计算时长
+		if len(audioData) < 44 || string(audioData[0:4]) != "RIFF" {
+			return 0, fmt.Errorf("invalid wav header")
+		}
+		byteRate := binary.LittleEndian.Uint32(audioData[28:32])
+		if byteRate == 0 {
+			return 0, fmt.Errorf("invalid wav byte rate")
+		}
+		return float64(len(audioData)-44) / float64(byteRate), nil
 	case "pcm16":
 		samplesCount = len(audioData) / 2 // 16位 = 2字节每样本
 		sampleRate = 24000                // 24kHz
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const redactedValue = "[REDACTED]"
+
+// 超过该大小的 JSON 不再逐字段脱敏，仅做正则替换后截断
+const maxRedactJSONBytes = 4 << 20
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]+`),
+}
+
+func redactJSONValue(value any, keys map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if keys[strings.ToLower(key)] {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactJSONValue(item, keys)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSONValue(item, keys)
+		}
+	}
+	return value
+}
+
+// SanitizeCapturedBody 对抓取到的报文脱敏并截断到 maxBytes，返回内容及是否发生截断
+func SanitizeCapturedBody(body []byte, maxBytes int) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+	setting := operation_setting.GetBodyCaptureSetting()
+	text := string(body)
+	if len(body) <= maxRedactJSONBytes && json.Valid(body) {
+		keys := make(map[string]bool, len(setting.RedactKeys))
+		for _, key := range setting.RedactKeys {
+			keys[strings.ToLower(key)] = true
+		}
+		var value any
+		if err := json.Unmarshal(body, &value); err == nil {
+			if data, err := json.Marshal(redactJSONValue(value, keys)); err == nil {
+				text = string(data)
+			}
+		}
+	}
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, redactedValue)
+	}
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text, false
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut], true
+}
+
+// SaveRequestCapture 脱敏后写入抓取记录
+func SaveRequestCapture(capture *model.RequestCapture, requestBody []byte, responseBody []byte, responseTruncated bool) {
+	maxBytes := operation_setting.GetBodyCaptureSetting().MaxBodyBytes
+	var requestTruncated bool
+	capture.RequestBody, requestTruncated = SanitizeCapturedBody(requestBody, maxBytes)
+	var truncated bool
+	capture.ResponseBody, truncated = SanitizeCapturedBody(responseBody, maxBytes)
+	capture.Truncated = requestTruncated || truncated || responseTruncated
+	if err := model.RecordRequestCapture(capture); err != nil {
+		common.SysError("failed to save request capture: " + err.Error())
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 预算消耗缓存的刷新间隔，期间本节点的消耗直接累加到缓存上
+const budgetSpendRefreshInterval = 30 * time.Second
+
+type budgetSpend struct {
+	periodStart int64
+	spent       int64
+	refreshedAt time.Time
+}
+
+var budgetSpends = make(map[int]*budgetSpend)
+var budgetSpendsLock sync.Mutex
+
+// getBudgetSpent 返回预算本周期已消耗的额度，缓存过期或进入新周期时从消费日志重新汇总
+func getBudgetSpent(budget *model.Budget, now time.Time) (int64, int64, error) {
+	start, _ := budget.PeriodRange(now)
+	periodStart := start.Unix()
+	budgetSpendsLock.Lock()
+	spend, ok := budgetSpends[budget.Id]
+	if ok && spend.periodStart == periodStart && now.Sub(spend.refreshedAt) < budgetSpendRefreshInterval {
+		spent := spend.spent
+		budgetSpendsLock.Unlock()
+		return spent, periodStart, nil
+	}
+	budgetSpendsLock.Unlock()
+
+	spent, err := model.SumBudgetConsumedQuota(budget, periodStart)
+	if err != nil {
+		return 0, periodStart, err
+	}
+	budgetSpendsLock.Lock()
+	budgetSpends[budget.Id] = &budgetSpend{periodStart: periodStart, spent: spent, refreshedAt: now}
+	budgetSpendsLock.Unlock()
+	return spent, periodStart, nil
+}
+
+func addBudgetSpent(budget *model.Budget, periodStart int64, quota int) int64 {
+	budgetSpendsLock.Lock()
+	defer budgetSpendsLock.Unlock()
+	spend, ok := budgetSpends[budget.Id]
+	if !ok || spend.periodStart != periodStart {
+		return 0
+	}
+	spend.spent += int64(quota)
+	return spend.spent
+}
+
+func getRequestBudgets(relayInfo *relaycommon.RelayInfo) []*model.Budget {
+	budgets, err := model.GetApplicableBudgets(relayInfo.UserId, relayInfo.TokenId, relayInfo.Group)
+	if err != nil {
+		common.SysError("failed to get budgets: " + err.Error())
+		return nil
+	}
+	return budgets
+}
+
+// CheckBudgetHardCap 检查用户、令牌与分组的预算，任一开启硬上限的预算本周期已用尽时拒绝请求
+func CheckBudgetHardCap(relayInfo *relaycommon.RelayInfo) *dto.OpenAIErrorWithStatusCode {
+	now := time.Now()
+	for _, budget := range getRequestBudgets(relayInfo) {
+		if !budget.HardCap {
+			continue
+		}
+		spent, periodStart, err := getBudgetSpent(budget, now)
+		if err != nil {
+			return OpenAIErrorWrapperLocal(err, "budget_check_failed", http.StatusInternalServerError)
+		}
+		if spent >= int64(budget.Quota) {
+			emitWebhookEventOnce(fmt.Sprintf("%s:%d:%d", dto.WebhookEventBudgetExceeded, budget.Id, periodStart), dto.WebhookEventBudgetExceeded, map[string]interface{}{
+				"budget_id":    budget.Id,
+				"budget_name":  budget.Name,
+				"scope":        budget.Scope,
+				"user_id":      relayInfo.UserId,
+				"spent":        spent,
+				"quota":        budget.Quota,
+				"period_start": periodStart,
+			})
+			return OpenAIErrorWrapperLocal(
+				errors.New(fmt.Sprintf("预算「%s」本周期额度已用尽（已用 %s，上限 %s）", budget.Name,
+					common.FormatQuota(int(spent)), common.FormatQuota(budget.Quota))),
+				"budget_exceeded", http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+// RecordBudgetUsage 请求结算后累加预算消耗，超过提醒阈值时每个周期发送一次提醒
+func RecordBudgetUsage(relayInfo *relaycommon.RelayInfo, quota int) {
+	if quota <= 0 {
+		return
+	}
+	budgets := getRequestBudgets(relayInfo)
+	if len(budgets) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, budget := range budgets {
+		_, periodStart, err := getBudgetSpent(budget, now)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to get spend of budget #%d: %s", budget.Id, err.Error()))
+			continue
+		}
+		spent := addBudgetSpent(budget, periodStart, quota)
+		if budget.AlertThreshold <= 0 || budget.AlertedPeriod == periodStart {
+			continue
+		}
+		if float64(spent) < budget.AlertThreshold*float64(budget.Quota) {
+			continue
+		}
+		budgetCopy := *budget
+		gopool.Go(func() {
+			sendBudgetAlert(&budgetCopy, relayInfo.UserId, periodStart, spent)
+		})
+	}
+}
+
+func sendBudgetAlert(budget *model.Budget, userId int, periodStart int64, spent int64) {
+	marked, err := model.MarkBudgetAlerted(budget.Id, periodStart)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to mark budget #%d alerted: %s", budget.Id, err.Error()))
+		return
+	}
+	if !marked {
+		return
+	}
+	model.InvalidateBudgetCache()
+
+	title := fmt.Sprintf("预算「%s」即将用尽", budget.Name)
+	content := "{{value}}，本周期已使用 {{value}}，预算额度为 {{value}}（{{value}}%）。"
+	percent := fmt.Sprintf("%.1f", float64(spent)*100/float64(budget.Quota))
+	data := dto.NewNotify(dto.NotifyTypeBudgetAlert, title, content,
+		[]interface{}{title, common.FormatQuota(int(spent)), common.FormatQuota(budget.Quota), percent})
+	if budget.WebhookUrl != "" {
+		err = SendWebhookNotify(budget.WebhookUrl, "", data)
+	} else if budget.Scope == model.BudgetScopeGroup {
+		NotifyRootUser(dto.NotifyTypeBudgetAlert, title, fmt.Sprintf("分组 %s 本周期已使用 %s，预算额度为 %s（%s%%）。",
+			budget.Group, common.FormatQuota(int(spent)), common.FormatQuota(budget.Quota), percent))
+		return
+	} else {
+		var user *model.User
+		user, err = model.GetUserById(userId, false)
+		if err == nil {
+			err = NotifyUser(user.Id, user.Email, user.GetSetting(), data)
+		}
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to send alert of budget #%d: %s", budget.Id, err.Error()))
+	}
+}
+
+// GetBudgetSpent 返回预算本周期已消耗的额度
+func GetBudgetSpent(budget *model.Budget) (int64, error) {
+	spent, _, err := getBudgetSpent(budget, time.Now())
+	return spent, err
+}
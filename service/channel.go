@@ -21,6 +21,11 @@ func DisableChannel(channelId int, channelName string, reason string) {
 		subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelName, channelId)
 		content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelName, channelId, reason)
 		NotifyRootUser(formatNotifyType(channelId, common.ChannelStatusAutoDisabled), subject, content)
+		EmitWebhookEvent(dto.WebhookEventChannelDisabled, map[string]interface{}{
+			"channel_id":   channelId,
+			"channel_name": channelName,
+			"reason":       reason,
+		})
 	}
 }
 
@@ -83,6 +88,14 @@ func ShouldDisableChannel(channelType int, err *dto.OpenAIErrorWithStatusCode) b
 	return false
 }
 
+// IsChannelFailure 判断错误是否计入渠道熔断统计，仅统计上游故障（5xx、超时、连接失败）
+func IsChannelFailure(err *dto.OpenAIErrorWithStatusCode) bool {
+	if err == nil || err.LocalError {
+		return false
+	}
+	return err.StatusCode/100 == 5 || err.StatusCode == http.StatusRequestTimeout
+}
+
 func ShouldEnableChannel(err error, openaiWithStatusErr *dto.OpenAIErrorWithStatusCode, status int) bool {
 	if !common.AutomaticEnableChannelEnabled {
 		return false
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const upstreamAcceptEncoding = "gzip, br"
+
+// SetUpstreamAcceptEncoding 向上游声明支持的压缩格式；渠道已配置 Accept-Encoding 时保持不变。
+// 显式设置后 net/http 不再自动解压，需要配合 DecompressUpstreamResponse 使用
+func SetUpstreamAcceptEncoding(header http.Header) {
+	if !operation_setting.GetCompressionSetting().UpstreamEnabled || header.Get("Accept-Encoding") != "" {
+		return
+	}
+	header.Set("Accept-Encoding", upstreamAcceptEncoding)
+}
+
+// lazyGzipReader 首次读取时才解析 gzip 头，避免流式响应在 doRequest 中阻塞到首个数据块到达
+type lazyGzipReader struct {
+	body   io.Reader
+	reader *gzip.Reader
+	err    error
+}
+
+func (r *lazyGzipReader) Read(p []byte) (int, error) {
+	if r.reader == nil && r.err == nil {
+		r.reader, r.err = gzip.NewReader(r.body)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+func (r *lazyGzipReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+// maxBytesReader 解压后的内容超过 remaining 时返回错误，防止压缩炸弹耗尽内存
+type maxBytesReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (r *maxBytesReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, fmt.Errorf("upstream response exceeds %d bytes after decompression", r.limit)
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+type decompressReadCloser struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (r *decompressReadCloser) Close() error {
+	if r.decoder != nil {
+		_ = r.decoder.Close()
+	}
+	return r.body.Close()
+}
+
+// DecompressUpstreamResponse 按 Content-Encoding 解压上游响应体，解压后移除 Content-Encoding 与 Content-Length，
+// 避免转发响应头时与实际内容不符；流式响应按块解压，不会等待完整响应，解压后的大小受 UpstreamMaxDecompressedBytes 限制
+func DecompressUpstreamResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	var reader io.Reader
+	var decoder io.Closer
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gzipReader := &lazyGzipReader{body: resp.Body}
+		reader, decoder = gzipReader, gzipReader
+	case "br":
+		reader = brotli.NewReader(resp.Body)
+	default:
+		return
+	}
+	if limit := operation_setting.GetCompressionSetting().UpstreamMaxDecompressedBytes; limit > 0 {
+		reader = &maxBytesReader{reader: reader, remaining: limit, limit: limit}
+	}
+	resp.Body = &decompressReadCloser{Reader: reader, decoder: decoder, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
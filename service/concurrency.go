@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"one-api/common/limiter"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrChannelQueueFull    = errors.New("渠道并发已满，排队请求过多")
+	ErrChannelQueueTimeout = errors.New("渠道并发已满，排队等待超时")
+	ErrGlobalQueueFull     = errors.New("系统并发已满，排队请求过多")
+	ErrGlobalQueueTimeout  = errors.New("系统并发已满，排队等待超时")
+)
+
+var globalSemaphore = limiter.NewPrioritySemaphore(0)
+var channelSemaphores sync.Map
+
+type ConcurrencyStats struct {
+	ChannelId int `json:"channel_id,omitempty"`
+	InUse     int `json:"in_use"`
+	Waiting   int `json:"waiting"`
+	Limit     int `json:"limit"`
+}
+
+func getChannelSemaphore(channelId int, limit int) *limiter.PrioritySemaphore {
+	if value, ok := channelSemaphores.Load(channelId); ok {
+		semaphore := value.(*limiter.PrioritySemaphore)
+		semaphore.SetLimit(limit)
+		return semaphore
+	}
+	value, _ := channelSemaphores.LoadOrStore(channelId, limiter.NewPrioritySemaphore(limit))
+	semaphore := value.(*limiter.PrioritySemaphore)
+	semaphore.SetLimit(limit)
+	return semaphore
+}
+
+// getChannelMaxConcurrency 读取当前选中渠道的 max_concurrency 设置，未配置时使用默认渠道并发上限
+func getChannelMaxConcurrency(c *gin.Context) int {
+	channelSetting := c.GetStringMap("channel_setting")
+	if v, ok := channelSetting[constant.ChannelSettingMaxConcurrency].(float64); ok && v > 0 {
+		return int(v)
+	}
+	return operation_setting.GetConcurrencySetting().DefaultChannelMaxConcurrency
+}
+
+// acquireSlot 按分组优先级排队获取名额，等待时间与队列长度由 request_queue_setting 控制
+func acquireSlot(c *gin.Context, semaphore *limiter.PrioritySemaphore) error {
+	setting := operation_setting.GetRequestQueueSetting()
+	ctx := c.Request.Context()
+	if setting.MaxWaitSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(setting.MaxWaitSeconds)*time.Second)
+		defer cancel()
+	}
+	priority := operation_setting.GetGroupPriority(c.GetString("group"))
+	return semaphore.Acquire(ctx, priority, setting.MaxQueueSize)
+}
+
+// AcquireGlobalSlot 获取网关全局并发名额，请求结束后需调用返回的 release
+func AcquireGlobalSlot(c *gin.Context) (release func(), err error) {
+	limit := operation_setting.GetConcurrencySetting().GlobalMaxConcurrency
+	globalSemaphore.SetLimit(limit)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	if err := acquireSlot(c, globalSemaphore); err != nil {
+		if errors.Is(err, limiter.ErrQueueFull) {
+			return nil, ErrGlobalQueueFull
+		}
+		return nil, ErrGlobalQueueTimeout
+	}
+	var once sync.Once
+	return func() { once.Do(globalSemaphore.Release) }, nil
+}
+
+// AcquireChannelSlot 获取渠道并发名额，渠道并发已满时按分组优先级排队，请求结束后需调用返回的 release
+func AcquireChannelSlot(c *gin.Context, channelId int) (release func(), err error) {
+	limit := getChannelMaxConcurrency(c)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	semaphore := getChannelSemaphore(channelId, limit)
+	if err := acquireSlot(c, semaphore); err != nil {
+		if errors.Is(err, limiter.ErrQueueFull) {
+			return nil, ErrChannelQueueFull
+		}
+		return nil, ErrChannelQueueTimeout
+	}
+	var once sync.Once
+	return func() { once.Do(semaphore.Release) }, nil
+}
+
+func GetConcurrencyStats() (global ConcurrencyStats, channels []ConcurrencyStats) {
+	global.InUse, global.Waiting, global.Limit = globalSemaphore.Stats()
+	channelSemaphores.Range(func(key, value any) bool {
+		stats := ConcurrencyStats{ChannelId: key.(int)}
+		stats.InUse, stats.Waiting, stats.Limit = value.(*limiter.PrioritySemaphore).Stats()
+		channels = append(channels, stats)
+		return true
+	})
+	return global, channels
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func contextTruncationEnabled(c *gin.Context, group string) bool {
+	if operation_setting.ShouldTruncateContext(group) {
+		return true
+	}
+	enabled, _ := c.GetStringMap(constant.ContextKeyTokenSetting)[constant.TokenSettingContextTruncation].(bool)
+	return enabled
+}
+
+// dropOldestMessage 移除最早的一条非系统消息及紧随其后的工具结果，至少保留最后一条非系统消息，返回被移除的消息
+func dropOldestMessage(request *dto.GeneralOpenAIRequest) []dto.Message {
+	first := -1
+	nonSystem := 0
+	for i, message := range request.Messages {
+		if message.Role == "system" || message.Role == "developer" {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		nonSystem++
+	}
+	if nonSystem <= 1 {
+		return nil
+	}
+	end := first + 1
+	for end < len(request.Messages)-1 && request.Messages[end].Role == "tool" {
+		end++
+	}
+	removed := append([]dto.Message(nil), request.Messages[first:end]...)
+	request.Messages = append(request.Messages[:first], request.Messages[end:]...)
+	return removed
+}
+
+// countDroppedMessageTokens 计算被移除消息的 token 数与其中的音频时长，不计入回复前缀的固定开销；
+// 使用临时的 RelayInfo，避免重复累计音频时长
+func countDroppedMessageTokens(info *relaycommon.RelayInfo, messages []dto.Message, model string, stream bool) (int, float64, error) {
+	scratch := &relaycommon.RelayInfo{ChannelType: info.ChannelType}
+	tokens, err := CountTokenMessages(scratch, messages, model, stream)
+	if err != nil {
+		return 0, 0, err
+	}
+	audioSeconds, _ := scratch.TakeAudioSeconds()
+	return max(tokens-3, 0), audioSeconds, nil
+}
+
+// FitContextWindow 按能力登记表中的 MaxContext 校验 promptTokens 与预留的输出 token，
+// 允许截断时从最早的非系统消息开始移除直至放得下，每条消息只计数一次并从 promptTokens 中扣除，返回截断后的 promptTokens
+func FitContextWindow(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest, promptTokens int) (int, error) {
+	if !operation_setting.GetModelCapabilitySetting().Enabled {
+		return promptTokens, nil
+	}
+	capability, ok := operation_setting.GetModelCapability(info.UpstreamModelName)
+	if !ok || capability.MaxContext <= 0 {
+		return promptTokens, nil
+	}
+	reserved := int(max(request.MaxTokens, request.MaxCompletionTokens))
+	if promptTokens+reserved <= capability.MaxContext {
+		return promptTokens, nil
+	}
+	exceededErr := fmt.Errorf("model %s maximum context length is %d tokens, but %d prompt tokens and %d completion tokens were requested",
+		info.UpstreamModelName, capability.MaxContext, promptTokens, reserved)
+	if reserved >= capability.MaxContext || !contextTruncationEnabled(c, info.Group) {
+		return promptTokens, exceededErr
+	}
+	dropped := 0
+	for promptTokens+reserved > capability.MaxContext {
+		removed := dropOldestMessage(request)
+		if len(removed) == 0 {
+			return promptTokens, exceededErr
+		}
+		dropped += len(removed)
+		tokens, audioSeconds, err := countDroppedMessageTokens(info, removed, request.Model, request.Stream)
+		if err != nil {
+			return promptTokens, err
+		}
+		promptTokens = max(promptTokens-tokens, 0)
+		// 被移除消息中的音频不再发送给上游，不应按音频时长计费
+		if audioSeconds > 0 {
+			info.AddAudioInputSeconds(-audioSeconds)
+		}
+	}
+	info.PromptTokens = promptTokens
+	c.Set(constant.ContextKeyContextTruncated, dropped)
+	c.Header("X-Context-Truncated-Messages", fmt.Sprintf("%d", dropped))
+	return promptTokens, nil
+}
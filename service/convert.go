@@ -176,6 +176,7 @@ func ClaudeErrorToOpenAIError(claudeError *dto.ClaudeErrorWithStatusCode) *dto.O
 	return &dto.OpenAIErrorWithStatusCode{
 		Error:      openAIError,
 		StatusCode: claudeError.StatusCode,
+		LocalError: claudeError.LocalError,
 	}
 }
 
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"one-api/dto"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const defaultDocumentMimeType = "application/pdf"
+
+// 无法解析文档内容（远程文件、file_id 等）时的估算值
+const documentFallbackTokens = 5000
+
+var (
+	pdfPageObjectPattern = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfPageCountPattern  = regexp.MustCompile(`/Type\s*/Pages\b[^>]*?/Count\s+(\d+)`)
+)
+
+// IsRemoteFile file_data 为 http(s) 或 gs:// 地址时返回 true
+func IsRemoteFile(data string) bool {
+	return strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") || strings.HasPrefix(data, "gs://")
+}
+
+// GetFileMimeType 按文件名后缀推断 MIME 类型，无法推断时按 PDF 处理
+func GetFileMimeType(fileName string) string {
+	if ext := filepath.Ext(fileName); ext != "" {
+		if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+			mimeType, _, _ = strings.Cut(mimeType, ";")
+			return mimeType
+		}
+	}
+	return defaultDocumentMimeType
+}
+
+// DecodeFileData 解析 base64 的 file_data，支持 data URL 与不带前缀的 base64，返回 MIME 类型与去掉前缀的 base64 数据；
+// 不带前缀时先按文件名推断类型，再根据内容识别
+func DecodeFileData(file *dto.MessageFile) (string, string, error) {
+	data := file.FileData
+	var mimeType string
+	if strings.HasPrefix(data, "data:") {
+		idx := strings.Index(data, ",")
+		if idx == -1 {
+			return "", "", fmt.Errorf("invalid file data url")
+		}
+		mimeType, _, _ = strings.Cut(data[len("data:"):idx], ";")
+		data = data[idx+1:]
+	}
+	if mimeType == "" && file.FileName != "" && filepath.Ext(file.FileName) != "" {
+		mimeType = GetFileMimeType(file.FileName)
+	}
+	if mimeType == "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to decode base64 file data: %s", err.Error())
+		}
+		mimeType, _, _ = strings.Cut(http.DetectContentType(decoded), ";")
+	}
+	return mimeType, data, nil
+}
+
+// CountPdfPages 统计 PDF 的页数，优先统计页对象，页对象位于压缩的对象流中时读取页树的 /Count，均失败时返回 0
+func CountPdfPages(data []byte) int {
+	if pages := len(pdfPageObjectPattern.FindAll(data, -1)); pages > 0 {
+		return pages
+	}
+	pages := 0
+	for _, match := range pdfPageCountPattern.FindAllSubmatch(data, -1) {
+		if count, err := strconv.Atoi(string(match[1])); err == nil && count > pages {
+			pages = count
+		}
+	}
+	return pages
+}
+
+// getDocumentTokensPerPage 各家对 PDF 的计费方式不同：Gemini 每页固定 258 token，
+// Claude 按文本加页面图片计费，官方估算为每页 1500-3000 token，OpenAI 同样为文本加页面图片
+func getDocumentTokensPerPage(model string) int {
+	switch GetTokenizerFamily(model) {
+	case TokenizerFamilyGemini:
+		return 258
+	case TokenizerFamilyClaude:
+		return 2000
+	}
+	return 1000
+}
+
+// getFileToken 估算文件内容块的 token：PDF 按页数估算，文本类文件按内容分词，其余情况使用固定估算值
+func getFileToken(model string, file *dto.MessageFile) int {
+	if file == nil || file.FileData == "" || IsRemoteFile(file.FileData) {
+		return documentFallbackTokens
+	}
+	mimeType, base64Data, err := DecodeFileData(file)
+	if err != nil {
+		return documentFallbackTokens
+	}
+	decoded, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return documentFallbackTokens
+	}
+	switch {
+	case mimeType == "application/pdf":
+		if pages := CountPdfPages(decoded); pages > 0 {
+			return pages * getDocumentTokensPerPage(model)
+		}
+	case strings.HasPrefix(mimeType, "text/"), mimeType == "application/json":
+		tokens, _ := TokenizeText(string(decoded), model, false)
+		return tokens
+	}
+	return documentFallbackTokens
+}
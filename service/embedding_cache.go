@@ -0,0 +1,43 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"time"
+)
+
+var embeddingMemoryCache = common.NewTTLCache[*ResponseCacheEntry]()
+
+// EmbeddingCacheKey 按模型、输入以及影响输出的 encoding_format、dimensions 计算缓存键
+func EmbeddingCacheKey(modelName string, request *dto.EmbeddingRequest) (string, error) {
+	input, err := json.Marshal(request.Input)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(modelName))
+	hash.Write([]byte{0})
+	hash.Write([]byte(request.EncodingFormat))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strconv.Itoa(request.Dimensions)))
+	hash.Write([]byte{0})
+	hash.Write(input)
+	return "embedding_cache:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func GetEmbeddingCache(key string) (*ResponseCacheEntry, bool) {
+	return getCacheEntry(embeddingMemoryCache, key)
+}
+
+func SetEmbeddingCache(key string, entry *ResponseCacheEntry) {
+	setting := operation_setting.GetEmbeddingCacheSetting()
+	if setting.MaxBodyBytes > 0 && len(entry.Body) > setting.MaxBodyBytes {
+		return
+	}
+	setCacheEntry(embeddingMemoryCache, key, entry, time.Duration(setting.TTLSeconds)*time.Second, setting.MaxEntries)
+}
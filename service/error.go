@@ -2,11 +2,13 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	relaycommon "one-api/relay/common"
 	"strconv"
 	"strings"
 )
@@ -50,6 +52,17 @@ func OpenAIErrorWrapperLocal(err error, code string, statusCode int) *dto.OpenAI
 	return openaiErr
 }
 
+// StreamFailoverError 流式请求在向客户端输出任何内容前失败（首个数据块超时或上游出错）时返回的错误，控制器据此错误码切换渠道重试
+func StreamFailoverError(info *relaycommon.RelayInfo) *dto.OpenAIErrorWithStatusCode {
+	if info.FirstTokenTimedOut {
+		return OpenAIErrorWrapper(errors.New("upstream did not return the first token in time"), "first_token_timeout", http.StatusGatewayTimeout)
+	}
+	if info.StreamFailure != "" {
+		return OpenAIErrorWrapper(fmt.Errorf("upstream stream failed before any content: %s", info.StreamFailure), "stream_failed_before_content", http.StatusBadGateway)
+	}
+	return nil
+}
+
 func ClaudeErrorWrapper(err error, code string, statusCode int) *dto.ClaudeErrorWithStatusCode {
 	text := err.Error()
 	lowerText := strings.ToLower(text)
@@ -137,6 +150,12 @@ func TaskErrorWrapperLocal(err error, code string, statusCode int) *dto.TaskErro
 	return openaiErr
 }
 
+// OpenAIErrorToTaskError 将额度限制等本地检查返回的错误转换为任务接口的错误格式
+func OpenAIErrorToTaskError(openaiErr *dto.OpenAIErrorWithStatusCode) *dto.TaskError {
+	code, _ := openaiErr.Error.Code.(string)
+	return TaskErrorWrapperLocal(errors.New(openaiErr.Error.Message), code, openaiErr.StatusCode)
+}
+
 func TaskErrorWrapper(err error, code string, statusCode int) *dto.TaskError {
 	text := err.Error()
 	lowerText := strings.ToLower(text)
@@ -2,12 +2,21 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"github.com/gorilla/websocket"
 	"golang.org/x/net/proxy"
 	"net"
 	"net/http"
 	"net/url"
 	"one-api/common"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,53 +45,285 @@ func GetImpatientHttpClient() *http.Client {
 	return impatientHTTPClient
 }
 
-// NewProxyHttpClient 创建支持代理的 HTTP 客户端
-func NewProxyHttpClient(proxyURL string) (*http.Client, error) {
-	if proxyURL == "" {
-		return http.DefaultClient, nil
+// HttpClientOptions 渠道级别的 HTTP 客户端配置，相同配置的渠道共用同一个客户端以复用连接
+type HttpClientOptions struct {
+	ProxyURL              string
+	ConnectTimeout        time.Duration
+	ResponseHeaderTimeout time.Duration
+	Timeout               time.Duration
+	TLSClientCert         string
+	TLSClientKey          string
+	TLSCACert             string
+	// 连接池配置，DedicatedChannelId 非 0 时该渠道独占连接池，不与相同配置的其他渠道共用
+	DedicatedChannelId  int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DisableHTTP2        bool
+	DisableKeepAlives   bool
+	TLSSessionCache     bool
+}
+
+// channelHttpClient 缓存的渠道客户端，lastUsed 为最近一次使用的 unix 秒
+type channelHttpClient struct {
+	client   *http.Client
+	lastUsed atomic.Int64
+}
+
+var channelHttpClients = make(map[HttpClientOptions]*channelHttpClient)
+var channelHttpClientsLock sync.RWMutex
+var channelHttpClientsSweptAt time.Time
+
+// channelHttpClientIdleTTL 超过该时长未使用的客户端被清理，避免渠道修改配置后旧客户端及其空闲连接一直留存
+const channelHttpClientIdleTTL = time.Hour
+
+// ChannelProxyDirect 渠道代理设置为该值时不使用全局代理与环境变量中的代理
+const ChannelProxyDirect = "direct"
+
+// ChannelHttpClientOptions 渠道的代理、mTLS 与超时配置
+func ChannelHttpClientOptions(info *relaycommon.RelayInfo) HttpClientOptions {
+	policy := info.GetChannelPolicy()
+	options := HttpClientOptions{
+		ConnectTimeout:        policy.ConnectTimeout,
+		ResponseHeaderTimeout: policy.FirstByteTimeout,
+		Timeout:               policy.TotalTimeout,
+	}
+	if proxyURL, ok := info.ChannelSetting[constant.ChanelSettingProxy].(string); ok {
+		options.ProxyURL = strings.TrimSpace(proxyURL)
 	}
+	options.TLSClientCert, _ = info.ChannelSetting[constant.ChannelSettingTLSClientCert].(string)
+	options.TLSClientKey, _ = info.ChannelSetting[constant.ChannelSettingTLSClientKey].(string)
+	options.TLSCACert, _ = info.ChannelSetting[constant.ChannelSettingTLSCACert].(string)
+	if tuning, ok := info.ChannelSetting[constant.ChannelSettingHTTPClient].(map[string]interface{}); ok {
+		applyHttpClientTuning(&options, tuning, info.ChannelId)
+	}
+	return options
+}
 
-	parsedURL, err := url.Parse(proxyURL)
+// applyHttpClientTuning 解析渠道设置中的 http_client，未配置的项使用全局默认值
+func applyHttpClientTuning(options *HttpClientOptions, tuning map[string]interface{}, channelId int) {
+	if dedicated, ok := tuning["dedicated"].(bool); ok && dedicated {
+		options.DedicatedChannelId = channelId
+	}
+	if maxIdleConns, ok := tuning["max_idle_conns"].(float64); ok && maxIdleConns > 0 {
+		options.MaxIdleConnsPerHost = int(maxIdleConns)
+	}
+	if idleConnTimeout, ok := tuning["idle_conn_timeout"].(float64); ok && idleConnTimeout > 0 {
+		options.IdleConnTimeout = time.Duration(idleConnTimeout * float64(time.Second))
+	}
+	if http2, ok := tuning["http2"].(bool); ok {
+		options.DisableHTTP2 = !http2
+	}
+	if keepAlive, ok := tuning["keep_alive"].(bool); ok {
+		options.DisableKeepAlives = !keepAlive
+	}
+	if sessionCache, ok := tuning["tls_session_cache"].(bool); ok {
+		options.TLSSessionCache = sessionCache
+	}
+}
+
+// GetChannelHttpClient 根据渠道配置获取 HTTP 客户端，渠道未配置代理时使用 RELAY_PROXY，未配置代理和超时时返回全局客户端
+func GetChannelHttpClient(options HttpClientOptions) (*http.Client, error) {
+	if options.ProxyURL == "" {
+		options.ProxyURL = common.RelayProxy
+	}
+	if options == (HttpClientOptions{}) {
+		return httpClient, nil
+	}
+	now := time.Now()
+	channelHttpClientsLock.RLock()
+	cached, ok := channelHttpClients[options]
+	channelHttpClientsLock.RUnlock()
+	if ok {
+		cached.lastUsed.Store(now.Unix())
+		return cached.client, nil
+	}
+	client, err := newChannelHttpClient(options)
 	if err != nil {
 		return nil, err
 	}
+	channelHttpClientsLock.Lock()
+	defer channelHttpClientsLock.Unlock()
+	sweepChannelHttpClients(now)
+	if cached, ok := channelHttpClients[options]; ok {
+		cached.lastUsed.Store(now.Unix())
+		return cached.client, nil
+	}
+	cached = &channelHttpClient{client: client}
+	cached.lastUsed.Store(now.Unix())
+	channelHttpClients[options] = cached
+	return client, nil
+}
 
+// sweepChannelHttpClients 清理长时间未使用的客户端并关闭其空闲连接，调用方需持有锁
+func sweepChannelHttpClients(now time.Time) {
+	if now.Sub(channelHttpClientsSweptAt) < time.Hour {
+		return
+	}
+	channelHttpClientsSweptAt = now
+	for options, cached := range channelHttpClients {
+		if now.Sub(time.Unix(cached.lastUsed.Load(), 0)) > channelHttpClientIdleTTL {
+			delete(channelHttpClients, options)
+			cached.client.CloseIdleConnections()
+		}
+	}
+}
+
+// InvalidateChannelHttpClient 渠道修改或删除后移除其独占的客户端，共用的客户端以完整配置为键，配置变化后自然不再命中，由定期清理回收
+func InvalidateChannelHttpClient(channelId int) {
+	channelHttpClientsLock.Lock()
+	defer channelHttpClientsLock.Unlock()
+	for options, cached := range channelHttpClients {
+		if options.DedicatedChannelId == channelId {
+			delete(channelHttpClients, options)
+			cached.client.CloseIdleConnections()
+		}
+	}
+}
+
+func newChannelHttpClient(options HttpClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if options.ConnectTimeout > 0 {
+		dialer.Timeout = options.ConnectTimeout
+	}
+	transport.DialContext = dialer.DialContext
+	transport.ResponseHeaderTimeout = options.ResponseHeaderTimeout
+	if options.TLSClientCert != "" || options.TLSCACert != "" {
+		tlsConfig, err := newChannelTLSConfig(options)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	configureTransportPool(transport, options)
+
+	if err := configureTransportProxy(transport, dialer, options.ProxyURL); err != nil {
+		return nil, err
+	}
+
+	timeout := options.Timeout
+	if timeout == 0 && common.RelayTimeout != 0 {
+		timeout = time.Duration(common.RelayTimeout) * time.Second
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// configureTransportPool 应用渠道的连接池配置，transport 已是 DefaultTransport 的副本
+func configureTransportPool(transport *http.Transport, options HttpClientOptions) {
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, options.MaxIdleConnsPerHost)
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = options.DisableKeepAlives
+	if options.DisableHTTP2 {
+		// TLSNextProto 非 nil 的空 map 会关闭 HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if options.TLSSessionCache {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+}
+
+// newChannelTLSConfig 自定义 CA 追加到系统 CA 之后，私钥可以是加密后的内容
+func newChannelTLSConfig(options HttpClientOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if options.TLSClientCert != "" {
+		key, err := common.DecryptString(options.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt client key failed: %w", err)
+		}
+		cert, err := tls.X509KeyPair([]byte(options.TLSClientCert), []byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if options.TLSCACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(options.TLSCACert)) {
+			return nil, errors.New("invalid CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// configureTransportProxy 为 transport 设置 http/https/socks5 代理，proxyURL 为 direct 时直连
+func configureTransportProxy(transport *http.Transport, dialer *net.Dialer, proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	if proxyURL == ChannelProxyDirect {
+		transport.Proxy = nil
+		return nil
+	}
+	parsedURL, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
 	switch parsedURL.Scheme {
 	case "http", "https":
-		return &http.Client{
-			Transport: &http.Transport{
-				Proxy: http.ProxyURL(parsedURL),
-			},
-		}, nil
-
-	case "socks5":
-		// 获取认证信息
+		transport.Proxy = http.ProxyURL(parsedURL)
+	case "socks5", "socks5h":
 		var auth *proxy.Auth
 		if parsedURL.User != nil {
 			auth = &proxy.Auth{
-				User:     parsedURL.User.Username(),
-				Password: "",
+				User: parsedURL.User.Username(),
 			}
 			if password, ok := parsedURL.User.Password(); ok {
 				auth.Password = password
 			}
 		}
-
-		// 创建 SOCKS5 代理拨号器
-		dialer, err := proxy.SOCKS5("tcp", parsedURL.Host, auth, proxy.Direct)
+		socksDialer, err := proxy.SOCKS5("tcp", parsedURL.Host, auth, dialer)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if contextDialer, ok := socksDialer.(proxy.ContextDialer); ok {
+				return contextDialer.DialContext(ctx, network, addr)
+			}
+			return socksDialer.Dial(network, addr)
 		}
-
-		return &http.Client{
-			Transport: &http.Transport{
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return dialer.Dial(network, addr)
-				},
-			},
-		}, nil
-
 	default:
-		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsedURL.Scheme)
+		return fmt.Errorf("unsupported proxy scheme: %s", parsedURL.Scheme)
+	}
+	return nil
+}
+
+// GetChannelWebsocketDialer 返回使用渠道代理与 TLS 配置的 websocket 拨号器
+func GetChannelWebsocketDialer(options HttpClientOptions) (*websocket.Dialer, error) {
+	if options.ProxyURL == "" {
+		options.ProxyURL = common.RelayProxy
+	}
+	if options.ProxyURL == "" && options.TLSClientCert == "" && options.TLSCACert == "" {
+		return websocket.DefaultDialer, nil
+	}
+	client, err := GetChannelHttpClient(options)
+	if err != nil {
+		return nil, err
 	}
+	transport := client.Transport.(*http.Transport)
+	return &websocket.Dialer{
+		Proxy:            transport.Proxy,
+		NetDialContext:   transport.DialContext,
+		TLSClientConfig:  transport.TLSClientConfig,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+	}, nil
 }
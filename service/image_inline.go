@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+)
+
+// ErrInvalidImage 远程图片无法下载、不是图片或超过大小限制，属于请求参数错误
+var ErrInvalidImage = errors.New("invalid image")
+
+var inlineImageCache = common.NewTTLCache[*dto.LocalFileData]()
+
+func inlineImageMaxBytes(providerMaxMB int) int64 {
+	maxMB := operation_setting.GetImageInlineSetting().MaxSizeMB
+	if maxMB <= 0 {
+		maxMB = constant.MaxFileDownloadMB
+	}
+	if providerMaxMB > 0 && providerMaxMB < maxMB {
+		maxMB = providerMaxMB
+	}
+	return int64(maxMB) * 1024 * 1024
+}
+
+func checkInlineImageSize(url string, size int64, maxBytes int64) error {
+	if size > maxBytes {
+		return fmt.Errorf("%w: image %s is %.1fMB, exceeds the %dMB limit of the upstream", ErrInvalidImage, url, float64(size)/1024/1024, maxBytes/1024/1024)
+	}
+	return nil
+}
+
+// GetInlineImageFromUrl 下载远程图片并转为 base64，providerMaxMB 为上游的单张图片限制，0 表示不限制；
+// 下载结果按 URL 缓存，命中缓存时仍按当前上游的限制校验
+func GetInlineImageFromUrl(url string, providerMaxMB int) (*dto.LocalFileData, error) {
+	maxBytes := inlineImageMaxBytes(providerMaxMB)
+	if cached, ok := inlineImageCache.Get(url); ok {
+		if err := checkInlineImageSize(url, cached.Size, maxBytes); err != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+	resp, err := DoDownloadRequest(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to download image %s: %s", ErrInvalidImage, url, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: failed to download image %s: HTTP %d", ErrInvalidImage, url, resp.StatusCode)
+	}
+	if resp.ContentLength > 0 {
+		if err := checkInlineImageSize(url, resp.ContentLength, maxBytes); err != nil {
+			return nil, err
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read image %s: %s", ErrInvalidImage, url, err.Error())
+	}
+	if err := checkInlineImageSize(url, int64(len(data)), maxBytes); err != nil {
+		return nil, err
+	}
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mimeType, "image/") {
+		// 部分存储服务返回 application/octet-stream，按内容识别
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("%w: %s is not an image, content type: %s", ErrInvalidImage, url, mimeType)
+	}
+	fileData := &dto.LocalFileData{
+		MimeType:   mimeType,
+		Base64Data: base64.StdEncoding.EncodeToString(data),
+		Url:        url,
+		Size:       int64(len(data)),
+	}
+	setting := operation_setting.GetImageInlineSetting()
+	// 按 base64 数据大小计入缓存总量，超过单张上限的图片不缓存
+	size := int64(len(fileData.Base64Data))
+	if setting.CacheTTLSeconds > 0 && (setting.CacheMaxEntryMB <= 0 || size <= int64(setting.CacheMaxEntryMB)*1024*1024) {
+		inlineImageCache.SetSized(url, fileData, size, time.Duration(setting.CacheTTLSeconds)*time.Second,
+			setting.CacheMaxEntries, int64(setting.CacheMaxMB)*1024*1024)
+	}
+	return fileData, nil
+}
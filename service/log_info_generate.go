@@ -1,8 +1,11 @@
 package service
 
 import (
+	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,14 +19,39 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["cache_tokens"] = cacheTokens
 	other["cache_ratio"] = cacheRatio
 	other["model_price"] = modelPrice
+	if currency := relayInfo.PriceCurrency; modelPrice > 0 && currency != "" && currency != operation_setting.CurrencyUSD {
+		// model_price 为换算后的美元价格，这里记录原始币种价格便于对账，汇率已失效时不记录
+		if currencyPrice, ok := operation_setting.ConvertFromUSD(modelPrice, currency); ok {
+			other["price_currency"] = currency
+			other["currency_model_price"] = currencyPrice
+		}
+	}
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if clientRequestId := ctx.GetString(common.ClientRequestIdKey); clientRequestId != "" {
+		other["client_request_id"] = clientRequestId
+	}
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
+	if relayInfo.IsBatch {
+		other["batch"] = true
+		other["batch_ratio"] = relayInfo.BatchRatio
+	}
+	if relayInfo.ClientDisconnected {
+		// 客户端中途断开，按已输出的内容计费
+		other["client_disconnected"] = true
+	}
 	if relayInfo.IsModelMapped {
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
+	if requestedModel := ctx.GetString(constant.ContextKeyRequestedModel); requestedModel != "" && requestedModel != relayInfo.OriginModelName {
+		// 发生模型降级，日志中的模型为实际使用的模型
+		other["requested_model"] = requestedModel
+	}
+	if truncated := ctx.GetInt(constant.ContextKeyContextTruncated); truncated > 0 {
+		other["context_truncated"] = truncated
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	other["admin_info"] = adminInfo
@@ -0,0 +1,29 @@
+package service
+
+import "one-api/setting/operation_setting"
+
+// ClampMaxTokens 按模型与分组的上限裁剪请求中的输出 token 字段，均未填写时将默认值写入第一个字段，
+// 未配置默认值时写入上限，使省略 max_tokens 的请求同样受上限约束
+func ClampMaxTokens(group string, modelName string, fields ...*uint) {
+	limit := operation_setting.GetMaxTokensLimit(group, modelName)
+	if limit.Max == 0 && limit.Default == 0 {
+		return
+	}
+	omitted := true
+	for _, field := range fields {
+		if *field == 0 {
+			continue
+		}
+		omitted = false
+		if limit.Max > 0 && *field > limit.Max {
+			*field = limit.Max
+		}
+	}
+	if omitted && len(fields) > 0 {
+		value := limit.Default
+		if value == 0 || (limit.Max > 0 && value > limit.Max) {
+			value = limit.Max
+		}
+		*fields[0] = value
+	}
+}
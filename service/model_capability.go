@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+)
+
+var mediaContentModalities = map[string]string{
+	dto.ContentTypeImageURL:   operation_setting.ModalityImage,
+	dto.ContentTypeInputAudio: operation_setting.ModalityAudio,
+	dto.ContentTypeFile:       operation_setting.ModalityFile,
+}
+
+// requestOutputModalities 解析请求中的 modalities，如 ["text", "audio"]
+func requestOutputModalities(request *dto.GeneralOpenAIRequest) []string {
+	items, ok := request.Modalities.([]interface{})
+	if !ok {
+		return nil
+	}
+	modalities := make([]string, 0, len(items))
+	for _, item := range items {
+		if modality, ok := item.(string); ok {
+			modalities = append(modalities, modality)
+		}
+	}
+	return modalities
+}
+
+// stripUnsupportedContent 移除消息中模型不支持的多模态内容，返回被移除的模态
+func stripUnsupportedContent(request *dto.GeneralOpenAIRequest, capability *operation_setting.ModelCapability, adapt bool) (string, bool) {
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		if message.Content == nil || message.IsStringContent() {
+			continue
+		}
+		contents := message.ParseContent()
+		kept := make([]dto.MediaContent, 0, len(contents))
+		for _, content := range contents {
+			if modality, ok := mediaContentModalities[content.Type]; ok && !capability.SupportsInput(modality) {
+				if !adapt {
+					return modality, true
+				}
+				continue
+			}
+			kept = append(kept, content)
+		}
+		if len(kept) != len(contents) {
+			message.SetMediaContent(kept)
+		}
+	}
+	return "", false
+}
+
+// CheckModelCapability 根据能力登记表校验对话请求，reject 模式下返回描述性错误，adapt 模式下移除不支持的内容
+func CheckModelCapability(request *dto.GeneralOpenAIRequest, modelName string) error {
+	setting := operation_setting.GetModelCapabilitySetting()
+	if !setting.Enabled {
+		return nil
+	}
+	capability, ok := operation_setting.GetModelCapability(modelName)
+	if !ok {
+		return nil
+	}
+	adapt := setting.Mode == operation_setting.ModelCapabilityModeAdapt
+	if modality, found := stripUnsupportedContent(request, capability, adapt); found {
+		if modality == operation_setting.ModalityImage {
+			return fmt.Errorf("model %s does not support image input (vision)", modelName)
+		}
+		return fmt.Errorf("model %s does not support %s input", modelName, modality)
+	}
+	if (len(request.Tools) > 0 || request.Functions != nil) && !capability.Tools {
+		if !adapt {
+			return fmt.Errorf("model %s does not support tool calling", modelName)
+		}
+		request.Tools = nil
+		request.ToolChoice = nil
+		request.Functions = nil
+	}
+	if request.ResponseFormat != nil && request.ResponseFormat.Type == "json_schema" && !capability.JsonSchema {
+		if !adapt {
+			return fmt.Errorf("model %s does not support response_format json_schema", modelName)
+		}
+		request.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+	}
+	for _, modality := range requestOutputModalities(request) {
+		if capability.SupportsOutput(modality) {
+			continue
+		}
+		if !adapt {
+			return fmt.Errorf("model %s does not support %s output", modelName, modality)
+		}
+		request.Modalities = nil
+		request.Audio = nil
+		break
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ModelQuotaPeriodDay   = "day"
+	ModelQuotaPeriodMonth = "month"
+)
+
+// ModelQuotaLimit 按模型限制的周期额度，Model 以 * 结尾时匹配同前缀的一组模型
+type ModelQuotaLimit struct {
+	Model  string `json:"model"`
+	Period string `json:"period"`
+	Quota  int    `json:"quota"`
+}
+
+type modelQuotaTarget struct {
+	name    string
+	userId  int
+	tokenId int
+	limit   ModelQuotaLimit
+}
+
+var modelQuotaReserveScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+local requested = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+if used + requested > limit then
+    return {0, used}
+end
+redis.call('INCRBY', KEYS[1], requested)
+return {1, used + requested}
+`)
+
+// modelQuotaCounter 未启用 Redis 时的内存计数器，周期结束后过期清理
+type modelQuotaCounter struct {
+	used     int64
+	expireAt time.Time
+}
+
+var modelQuotaCounters = make(map[string]*modelQuotaCounter)
+var modelQuotaCountersLock sync.Mutex
+var modelQuotaCountersSweptAt time.Time
+
+// sweepModelQuotaCounters 清理已过期的内存计数器，调用方需持有锁
+func sweepModelQuotaCounters(now time.Time) {
+	if now.Sub(modelQuotaCountersSweptAt) < time.Hour {
+		return
+	}
+	modelQuotaCountersSweptAt = now
+	for key, counter := range modelQuotaCounters {
+		if now.After(counter.expireAt) {
+			delete(modelQuotaCounters, key)
+		}
+	}
+}
+
+func ParseModelQuotaLimits(value interface{}) ([]ModelQuotaLimit, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var limits []ModelQuotaLimit
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, err
+	}
+	for _, limit := range limits {
+		if limit.Model == "" || limit.Quota <= 0 {
+			return nil, fmt.Errorf("invalid model quota limit: %+v", limit)
+		}
+		if limit.Period != ModelQuotaPeriodDay && limit.Period != ModelQuotaPeriodMonth {
+			return nil, fmt.Errorf("invalid model quota limit period: %s", limit.Period)
+		}
+	}
+	return limits, nil
+}
+
+func matchModelPattern(pattern string, modelName string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(modelName, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == modelName
+}
+
+func getModelQuotaTargets(relayInfo *relaycommon.RelayInfo) []modelQuotaTarget {
+	var targets []modelQuotaTarget
+	collect := func(name string, userId int, tokenId int, value interface{}) {
+		limits, err := ParseModelQuotaLimits(value)
+		if err != nil {
+			common.SysError("invalid model quota limits: " + err.Error())
+			return
+		}
+		for _, limit := range limits {
+			if matchModelPattern(limit.Model, relayInfo.OriginModelName) {
+				targets = append(targets, modelQuotaTarget{name: name, userId: userId, tokenId: tokenId, limit: limit})
+			}
+		}
+	}
+	collect("令牌", 0, relayInfo.TokenId, relayInfo.TokenSetting[constant.TokenSettingModelQuotaLimits])
+	collect("用户", relayInfo.UserId, 0, relayInfo.UserSetting[constant.UserSettingModelQuotaLimits])
+	return targets
+}
+
+// periodStart 返回周期起始时间与周期结束时间
+func periodStart(period string, now time.Time) (time.Time, time.Time) {
+	if period == ModelQuotaPeriodMonth {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+func (t modelQuotaTarget) counterKey(now time.Time) (string, time.Time, time.Time) {
+	start, end := periodStart(t.limit.Period, now)
+	subject := fmt.Sprintf("user:%d", t.userId)
+	if t.tokenId != 0 {
+		subject = fmt.Sprintf("token:%d", t.tokenId)
+	}
+	return fmt.Sprintf("modelQuota:%s:%s:%s", subject, t.limit.Model, start.Format("20060102")), start, end
+}
+
+// ensureModelQuotaCounter 计数器不存在时用本周期的消费日志初始化，保证重启或 Redis 过期后限制依然准确
+func ensureModelQuotaCounter(t modelQuotaTarget, key string, start time.Time, end time.Time) error {
+	if common.RedisEnabled {
+		exists, err := common.RDB.Exists(context.Background(), key).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 1 {
+			return nil
+		}
+	} else {
+		modelQuotaCountersLock.Lock()
+		_, ok := modelQuotaCounters[key]
+		modelQuotaCountersLock.Unlock()
+		if ok {
+			return nil
+		}
+	}
+	used, err := model.SumModelConsumedQuota(t.userId, t.tokenId, t.limit.Model, start.Unix())
+	if err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		return common.RDB.SetNX(context.Background(), key, used, time.Until(end)+time.Hour).Err()
+	}
+	modelQuotaCountersLock.Lock()
+	defer modelQuotaCountersLock.Unlock()
+	sweepModelQuotaCounters(time.Now())
+	if _, ok := modelQuotaCounters[key]; !ok {
+		modelQuotaCounters[key] = &modelQuotaCounter{used: used, expireAt: end.Add(time.Hour)}
+	}
+	return nil
+}
+
+func reserveModelQuotaCounter(key string, quota int, limit int) (bool, int64, error) {
+	if common.RedisEnabled {
+		values, err := modelQuotaReserveScript.Run(context.Background(), common.RDB, []string{key}, quota, limit).Int64Slice()
+		if err != nil {
+			return false, 0, err
+		}
+		return values[0] == 1, values[1], nil
+	}
+	modelQuotaCountersLock.Lock()
+	defer modelQuotaCountersLock.Unlock()
+	counter, ok := modelQuotaCounters[key]
+	if !ok {
+		return false, 0, errors.New("model quota counter not initialized")
+	}
+	if counter.used+int64(quota) > int64(limit) {
+		return false, counter.used, nil
+	}
+	counter.used += int64(quota)
+	return true, counter.used, nil
+}
+
+func addModelQuotaCounter(key string, delta int) {
+	if delta == 0 {
+		return
+	}
+	if common.RedisEnabled {
+		if err := common.RDB.IncrBy(context.Background(), key, int64(delta)).Err(); err != nil {
+			common.SysError("failed to update model quota counter: " + err.Error())
+		}
+		return
+	}
+	modelQuotaCountersLock.Lock()
+	defer modelQuotaCountersLock.Unlock()
+	if counter, ok := modelQuotaCounters[key]; ok {
+		counter.used += int64(delta)
+	}
+}
+
+// ReserveModelQuota 检查按模型额度限制并原子地预留 quota，超出任一限制时返回错误并撤销已预留的部分
+func ReserveModelQuota(relayInfo *relaycommon.RelayInfo, quota int) *dto.OpenAIErrorWithStatusCode {
+	relayInfo.ModelQuotaReserved = 0
+	relayInfo.ModelQuotaKeys = nil
+	targets := getModelQuotaTargets(relayInfo)
+	if len(targets) == 0 {
+		return nil
+	}
+	// 至少预留 1，保证额度用尽后的请求被拒绝
+	if quota <= 0 {
+		quota = 1
+	}
+	now := time.Now()
+	var reserved []string
+	rollback := func() {
+		for _, key := range reserved {
+			addModelQuotaCounter(key, -quota)
+		}
+	}
+	for _, t := range targets {
+		key, start, end := t.counterKey(now)
+		if err := ensureModelQuotaCounter(t, key, start, end); err != nil {
+			rollback()
+			return OpenAIErrorWrapperLocal(err, "model_quota_check_failed", http.StatusInternalServerError)
+		}
+		ok, used, err := reserveModelQuotaCounter(key, quota, t.limit.Quota)
+		if err != nil {
+			rollback()
+			return OpenAIErrorWrapperLocal(err, "model_quota_check_failed", http.StatusInternalServerError)
+		}
+		if !ok {
+			rollback()
+			periodName := "今日"
+			if t.limit.Period == ModelQuotaPeriodMonth {
+				periodName = "本月"
+			}
+			return OpenAIErrorWrapperLocal(
+				errors.New(fmt.Sprintf("%s在模型 %s 上%s的额度已用尽（已用 %s，上限 %s）", t.name, t.limit.Model, periodName,
+					common.FormatQuota(int(used)), common.FormatQuota(t.limit.Quota))),
+				"model_quota_exceeded", http.StatusTooManyRequests)
+		}
+		reserved = append(reserved, key)
+	}
+	relayInfo.ModelQuotaReserved = quota
+	relayInfo.ModelQuotaKeys = reserved
+	return nil
+}
+
+// SettleModelQuota 请求完成后按实际消耗结算预留的额度，计入预留时所在周期的计数器
+func SettleModelQuota(relayInfo *relaycommon.RelayInfo, quota int) {
+	for _, key := range relayInfo.ModelQuotaKeys {
+		addModelQuotaCounter(key, quota-relayInfo.ModelQuotaReserved)
+	}
+	relayInfo.ModelQuotaReserved = 0
+	relayInfo.ModelQuotaKeys = nil
+}
+
+// ReleaseModelQuota 请求失败时释放预留的额度
+func ReleaseModelQuota(relayInfo *relaycommon.RelayInfo) {
+	if relayInfo.ModelQuotaReserved == 0 {
+		return
+	}
+	SettleModelQuota(relayInfo, 0)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModerationResult 审核结果，会记录到请求日志的 other.moderation 中
+type ModerationResult struct {
+	Provider   string             `json:"provider"`
+	Flagged    bool               `json:"flagged"`
+	Categories map[string]float64 `json:"categories,omitempty"`
+	Violations []string           `json:"violations,omitempty"`
+}
+
+type openAIModerationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+type azureContentSafetyResponse struct {
+	CategoriesAnalysis []struct {
+		Category string  `json:"category"`
+		Severity float64 `json:"severity"`
+	} `json:"categoriesAnalysis"`
+}
+
+type webhookModerationResponse struct {
+	Flagged    bool               `json:"flagged"`
+	Categories map[string]float64 `json:"categories"`
+}
+
+func postModerationRequest(ctx context.Context, url string, headers map[string]string, payload any, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("moderation request failed with status code %d: %s", resp.StatusCode, string(responseBody))
+	}
+	return json.Unmarshal(responseBody, result)
+}
+
+func moderateWithOpenAI(ctx context.Context, setting *operation_setting.ModerationSetting, text string) (*ModerationResult, error) {
+	endpoint := setting.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.openai.com"
+	}
+	var response openAIModerationResponse
+	err := postModerationRequest(ctx, strings.TrimSuffix(endpoint, "/")+"/v1/moderations",
+		map[string]string{"Authorization": "Bearer " + setting.ApiKey},
+		map[string]any{"model": setting.Model, "input": text}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) == 0 {
+		return nil, errors.New("moderation response is empty")
+	}
+	return &ModerationResult{Flagged: response.Results[0].Flagged, Categories: response.Results[0].CategoryScores}, nil
+}
+
+func moderateWithAzure(ctx context.Context, setting *operation_setting.ModerationSetting, text string) (*ModerationResult, error) {
+	if setting.Endpoint == "" {
+		return nil, errors.New("azure content safety endpoint is not configured")
+	}
+	var response azureContentSafetyResponse
+	err := postModerationRequest(ctx, strings.TrimSuffix(setting.Endpoint, "/")+"/contentsafety/text:analyze?api-version=2023-10-01",
+		map[string]string{"Ocp-Apim-Subscription-Key": setting.ApiKey},
+		map[string]any{"text": text}, &response)
+	if err != nil {
+		return nil, err
+	}
+	result := &ModerationResult{Categories: make(map[string]float64)}
+	for _, item := range response.CategoriesAnalysis {
+		result.Categories[item.Category] = item.Severity
+		// 未配置阈值时，严重等级达到中等（4）视为违规
+		if item.Severity >= 4 {
+			result.Flagged = true
+		}
+	}
+	return result, nil
+}
+
+func moderateWithWebhook(ctx context.Context, setting *operation_setting.ModerationSetting, text string, group string, modelName string) (*ModerationResult, error) {
+	if setting.Endpoint == "" {
+		return nil, errors.New("moderation webhook endpoint is not configured")
+	}
+	headers := map[string]string{}
+	if setting.ApiKey != "" {
+		headers["Authorization"] = "Bearer " + setting.ApiKey
+	}
+	var response webhookModerationResponse
+	err := postModerationRequest(ctx, setting.Endpoint, headers,
+		map[string]any{"input": text, "group": group, "model": modelName}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &ModerationResult{Flagged: response.Flagged, Categories: response.Categories}, nil
+}
+
+// ModerateText 调用配置的审核服务，再按分组的类别阈值判定是否拦截
+func ModerateText(text string, group string, modelName string) (*ModerationResult, error) {
+	setting := operation_setting.GetModerationSetting()
+	timeout := time.Duration(setting.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var result *ModerationResult
+	var err error
+	switch setting.Provider {
+	case operation_setting.ModerationProviderAzure:
+		result, err = moderateWithAzure(ctx, setting, text)
+	case operation_setting.ModerationProviderWebhook:
+		result, err = moderateWithWebhook(ctx, setting, text, group, modelName)
+	default:
+		result, err = moderateWithOpenAI(ctx, setting, text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Provider = setting.Provider
+	thresholds := operation_setting.GetModerationThresholds(group)
+	if len(thresholds) == 0 {
+		return result, nil
+	}
+	result.Flagged = false
+	for category, threshold := range thresholds {
+		if score, ok := result.Categories[category]; ok && score >= threshold {
+			result.Violations = append(result.Violations, category)
+		}
+	}
+	sort.Strings(result.Violations)
+	result.Flagged = len(result.Violations) > 0
+	return result, nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+)
+
+// GetPayerQuota 返回本次请求的付费方余额：组织令牌为组织额度池，否则为用户额度
+func GetPayerQuota(relayInfo *relaycommon.RelayInfo) (int, error) {
+	return model.GetPayerQuota(relayInfo.UserId, relayInfo.OrganizationId)
+}
+
+// GetPayerAvailableQuota 付费方可用额度，组织额度池不支持透支
+func GetPayerAvailableQuota(relayInfo *relaycommon.RelayInfo, quota int) int {
+	if relayInfo.OrganizationId != 0 {
+		return quota
+	}
+	return GetUserAvailableQuota(relayInfo.UserSetting, quota)
+}
+
+func DecreasePayerQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	return model.DecreasePayerQuota(relayInfo.UserId, relayInfo.OrganizationId, quota)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// HoldUserPackageAllowance 预扣费时从套餐中该模型的赠送额度预扣 quota，返回预扣成功的部分，结算时通过 ConsumeUserPackages 多退少补；
+// 组织令牌消耗的是组织额度池，不使用个人套餐
+func HoldUserPackageAllowance(relayInfo *relaycommon.RelayInfo, quota int) int {
+	relayInfo.PackageAllowanceHeld = 0
+	if quota <= 0 || relayInfo.OrganizationId != 0 || !model.HasActiveUserPackages(relayInfo.UserId) {
+		return 0
+	}
+	held, err := model.ConsumeUserPackages(relayInfo.UserId, relayInfo.OriginModelName, quota, true)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to hold package allowance of user %d: %s", relayInfo.UserId, err.Error()))
+		return 0
+	}
+	relayInfo.PackageAllowanceHeld = held
+	return held
+}
+
+// ReleaseUserPackageAllowance 请求失败时退还预扣的赠送额度
+func ReleaseUserPackageAllowance(relayInfo *relaycommon.RelayInfo) {
+	held := relayInfo.PackageAllowanceHeld
+	if held <= 0 {
+		return
+	}
+	relayInfo.PackageAllowanceHeld = 0
+	if err := model.RefundUserPackageAllowance(relayInfo.UserId, relayInfo.OriginModelName, held); err != nil {
+		common.SysError(fmt.Sprintf("failed to release package allowance of user %d: %s", relayInfo.UserId, err.Error()))
+	}
+}
+
+// ConsumeUserPackages 结算后按过期时间扣减用户套餐，由模型赠送额度抵扣的部分退还到用户与令牌额度；
+// 预扣的赠送额度多于实际消耗时退还差额，少于实际消耗时继续扣减。组织令牌消耗的是组织额度池，不使用个人套餐
+func ConsumeUserPackages(relayInfo *relaycommon.RelayInfo, quota int) {
+	held := relayInfo.PackageAllowanceHeld
+	relayInfo.PackageAllowanceHeld = 0
+	if quota <= 0 && held <= 0 {
+		return
+	}
+	if held <= 0 && (relayInfo.OrganizationId != 0 || !model.HasActiveUserPackages(relayInfo.UserId)) {
+		return
+	}
+	relayInfoCopy := *relayInfo
+	gopool.Go(func() {
+		covered := min(held, max(quota, 0))
+		if held > quota {
+			if err := model.RefundUserPackageAllowance(relayInfoCopy.UserId, relayInfoCopy.OriginModelName, held-max(quota, 0)); err != nil {
+				common.SysError(fmt.Sprintf("failed to refund package allowance of user %d: %s", relayInfoCopy.UserId, err.Error()))
+			}
+		} else if quota > held {
+			more, err := model.ConsumeUserPackages(relayInfoCopy.UserId, relayInfoCopy.OriginModelName, quota-held, false)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to consume packages of user %d: %s", relayInfoCopy.UserId, err.Error()))
+			}
+			covered += more
+		}
+		if covered <= 0 {
+			return
+		}
+		if err := PostConsumeQuota(&relayInfoCopy, -covered, 0, false); err != nil {
+			common.SysError("error refunding package allowance: " + err.Error())
+		}
+	})
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/helper"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PIIDetector 敏感个人信息检测器，Detect 返回所有匹配的 [start, end) 字节区间
+type PIIDetector interface {
+	Name() string
+	Detect(text string) [][]int
+}
+
+type regexPIIDetector struct {
+	name     string
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+func (d *regexPIIDetector) Name() string {
+	return d.name
+}
+
+func (d *regexPIIDetector) Detect(text string) [][]int {
+	matches := d.pattern.FindAllStringIndex(text, -1)
+	if d.validate == nil {
+		return matches
+	}
+	valid := matches[:0]
+	for _, match := range matches {
+		if d.validate(text[match[0]:match[1]]) {
+			valid = append(valid, match)
+		}
+	}
+	return valid
+}
+
+var piiDetectors = make(map[string]PIIDetector)
+var piiDetectorsLock sync.RWMutex
+
+// RegisterPIIDetector 注册检测器，在 pii_redaction_setting.detectors 中按名称启用
+func RegisterPIIDetector(detector PIIDetector) {
+	piiDetectorsLock.Lock()
+	defer piiDetectorsLock.Unlock()
+	piiDetectors[detector.Name()] = detector
+}
+
+func init() {
+	RegisterPIIDetector(&regexPIIDetector{name: "email", pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)})
+	RegisterPIIDetector(&regexPIIDetector{name: "id_card", pattern: regexp.MustCompile(`\b\d{17}[\dXx]\b`), validate: validChineseIdCard})
+	RegisterPIIDetector(&regexPIIDetector{name: "credit_card", pattern: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), validate: validLuhn})
+	RegisterPIIDetector(&regexPIIDetector{name: "phone", pattern: regexp.MustCompile(`(?:\+86[ \-]?)?\b1[3-9]\d{9}\b|\+[1-9]\d{7,14}\b`)})
+}
+
+// validChineseIdCard 校验 18 位身份证号的校验码
+func validChineseIdCard(id string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checkCodes := "10X98765432"
+	sum := 0
+	for i, weight := range weights {
+		sum += int(id[i]-'0') * weight
+	}
+	return strings.ToUpper(id[17:]) == string(checkCodes[sum%11])
+}
+
+// validLuhn 银行卡号 Luhn 校验，忽略空格与连字符
+func validLuhn(number string) bool {
+	sum := 0
+	digits := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		ch := number[i]
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		d := int(ch - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// PIIRedactor 记录本次请求中占位符与原始内容的对应关系，同一内容使用同一占位符
+type PIIRedactor struct {
+	placeholders map[string]string // 原始内容 -> 占位符
+	originals    map[string]string // 占位符 -> 原始内容
+	counters     map[string]int
+	maxLen       int
+}
+
+func NewPIIRedactor() *PIIRedactor {
+	return &PIIRedactor{
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counters:     make(map[string]int),
+	}
+}
+
+func enabledPIIDetectors() []PIIDetector {
+	setting := operation_setting.GetPIIRedactionSetting()
+	var detectors []PIIDetector
+	piiDetectorsLock.RLock()
+	for _, name := range setting.Detectors {
+		if detector, ok := piiDetectors[name]; ok {
+			detectors = append(detectors, detector)
+		}
+	}
+	piiDetectorsLock.RUnlock()
+	for name, pattern := range setting.CustomPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			common.SysError(fmt.Sprintf("invalid pii pattern %s: %s", name, err.Error()))
+			continue
+		}
+		detectors = append(detectors, &regexPIIDetector{name: name, pattern: re})
+	}
+	return detectors
+}
+
+func (r *PIIRedactor) placeholder(name string, original string) string {
+	if placeholder, ok := r.placeholders[original]; ok {
+		return placeholder
+	}
+	r.counters[name]++
+	placeholder := fmt.Sprintf("[%s_%d]", strings.ToUpper(name), r.counters[name])
+	r.placeholders[original] = placeholder
+	r.originals[placeholder] = original
+	if len(placeholder) > r.maxLen {
+		r.maxLen = len(placeholder)
+	}
+	return placeholder
+}
+
+// Redact 依次使用各检测器替换文本中的敏感信息
+func (r *PIIRedactor) Redact(text string, detectors []PIIDetector) string {
+	for _, detector := range detectors {
+		matches := detector.Detect(text)
+		if len(matches) == 0 {
+			continue
+		}
+		var builder strings.Builder
+		last := 0
+		for _, match := range matches {
+			if match[0] < last {
+				continue
+			}
+			builder.WriteString(text[last:match[0]])
+			builder.WriteString(r.placeholder(detector.Name(), text[match[0]:match[1]]))
+			last = match[1]
+		}
+		builder.WriteString(text[last:])
+		text = builder.String()
+	}
+	return text
+}
+
+func (r *PIIRedactor) HasRedactions() bool {
+	return len(r.originals) > 0
+}
+
+// Restore 将文本中的占位符还原为原始内容，escape 为 true 时按 JSON 字符串转义
+func (r *PIIRedactor) Restore(text string, escape bool) string {
+	if len(r.originals) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	replacements := make([]string, 0, len(r.originals)*2)
+	for placeholder, original := range r.originals {
+		if escape {
+			quoted, _ := json.Marshal(original)
+			original = string(quoted[1 : len(quoted)-1])
+		}
+		replacements = append(replacements, placeholder, original)
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// RedactPIIMessages 对消息中的文本内容脱敏，存在替换时将脱敏映射保存到上下文，返回请求是否被修改
+func RedactPIIMessages(c *gin.Context, messages []dto.Message) bool {
+	detectors := enabledPIIDetectors()
+	if len(detectors) == 0 {
+		return false
+	}
+	redactor := NewPIIRedactor()
+	for i := range messages {
+		message := &messages[i]
+		if message.IsStringContent() {
+			content := message.StringContent()
+			if redacted := redactor.Redact(content, detectors); redacted != content {
+				message.SetStringContent(redacted)
+			}
+			continue
+		}
+		contents := message.ParseContent()
+		changed := false
+		for j := range contents {
+			if contents[j].Type != dto.ContentTypeText || contents[j].Text == "" {
+				continue
+			}
+			if redacted := redactor.Redact(contents[j].Text, detectors); redacted != contents[j].Text {
+				contents[j].Text = redacted
+				changed = true
+			}
+		}
+		if changed {
+			message.SetMediaContent(contents)
+		}
+	}
+	return savePIIRedactor(c, redactor)
+}
+
+// RedactPIIPrompt 对 completions 请求的 prompt 脱敏，仅处理字符串与字符串数组
+func RedactPIIPrompt(c *gin.Context, prompt any) (any, bool) {
+	detectors := enabledPIIDetectors()
+	if len(detectors) == 0 {
+		return prompt, false
+	}
+	redactor := NewPIIRedactor()
+	switch v := prompt.(type) {
+	case string:
+		prompt = redactor.Redact(v, detectors)
+	case []any:
+		for i, item := range v {
+			if text, ok := item.(string); ok {
+				v[i] = redactor.Redact(text, detectors)
+			}
+		}
+	}
+	return prompt, savePIIRedactor(c, redactor)
+}
+
+// RedactPIIClaudeRequest 对 Claude 请求的系统提示词与消息中的文本块脱敏
+func RedactPIIClaudeRequest(c *gin.Context, request *dto.ClaudeRequest) bool {
+	detectors := enabledPIIDetectors()
+	if len(detectors) == 0 {
+		return false
+	}
+	redactor := NewPIIRedactor()
+	redactBlocks := func(blocks []dto.ClaudeMediaMessage) bool {
+		changed := false
+		for i := range blocks {
+			if blocks[i].Type != "text" || blocks[i].GetText() == "" {
+				continue
+			}
+			if redacted := redactor.Redact(blocks[i].GetText(), detectors); redacted != blocks[i].GetText() {
+				blocks[i].SetText(redacted)
+				changed = true
+			}
+		}
+		return changed
+	}
+	if request.IsStringSystem() {
+		request.SetStringSystem(redactor.Redact(request.GetStringSystem(), detectors))
+	} else if request.System != nil {
+		if system := request.ParseSystem(); redactBlocks(system) {
+			request.System = system
+		}
+	}
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		if message.IsStringContent() {
+			message.SetStringContent(redactor.Redact(message.GetStringContent(), detectors))
+			continue
+		}
+		if contents, err := message.ParseContent(); err == nil && redactBlocks(contents) {
+			message.Content = contents
+		}
+	}
+	return savePIIRedactor(c, redactor)
+}
+
+// RedactPIIResponsesRequest 对 Responses 请求的 instructions 与 input 中的文本脱敏
+func RedactPIIResponsesRequest(c *gin.Context, request *dto.OpenAIResponsesRequest) bool {
+	detectors := enabledPIIDetectors()
+	if len(detectors) == 0 {
+		return false
+	}
+	redactor := NewPIIRedactor()
+	var instructions string
+	if json.Unmarshal(request.Instructions, &instructions) == nil {
+		if redacted := redactor.Redact(instructions, detectors); redacted != instructions {
+			request.Instructions, _ = json.Marshal(redacted)
+		}
+	}
+	var input string
+	if json.Unmarshal(request.Input, &input) == nil {
+		if redacted := redactor.Redact(input, detectors); redacted != input {
+			request.Input, _ = json.Marshal(redacted)
+		}
+		return savePIIRedactor(c, redactor)
+	}
+	var items []map[string]any
+	if json.Unmarshal(request.Input, &items) != nil {
+		return savePIIRedactor(c, redactor)
+	}
+	changed := false
+	for _, item := range items {
+		switch content := item["content"].(type) {
+		case string:
+			if redacted := redactor.Redact(content, detectors); redacted != content {
+				item["content"] = redacted
+				changed = true
+			}
+		case []any:
+			for _, part := range content {
+				partMap, ok := part.(map[string]any)
+				if !ok {
+					continue
+				}
+				if text, ok := partMap["text"].(string); ok {
+					if redacted := redactor.Redact(text, detectors); redacted != text {
+						partMap["text"] = redacted
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	if changed {
+		request.Input, _ = json.Marshal(items)
+	}
+	return savePIIRedactor(c, redactor)
+}
+
+// savePIIRedactor 存在替换时将脱敏映射保存到上下文
+func savePIIRedactor(c *gin.Context, redactor *PIIRedactor) bool {
+	if !redactor.HasRedactions() {
+		return false
+	}
+	c.Set(constant.ContextKeyPIIRedactor, redactor)
+	return true
+}
+
+func GetPIIRedactor(c *gin.Context) *PIIRedactor {
+	redactor, _ := c.Value(constant.ContextKeyPIIRedactor).(*PIIRedactor)
+	return redactor
+}
+
+// NewPIIRestoreFilter 流式响应中还原占位符，末尾未闭合的 "[" 之后的内容暂存到下一分片
+func NewPIIRestoreFilter(redactor *PIIRedactor) helper.StreamOutputFilter {
+	return newStreamChunkFilter(func(text []rune, final bool) ([]rune, []rune, bool) {
+		var keep []rune
+		if !final {
+			for i := len(text) - 1; i >= 0 && len(text)-i <= redactor.maxLen; i-- {
+				if text[i] == ']' {
+					break
+				}
+				if text[i] == '[' {
+					keep = text[i:]
+					text = text[:i]
+					break
+				}
+			}
+		}
+		return []rune(redactor.Restore(string(text), false)), keep, false
+	})
+}
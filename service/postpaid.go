@@ -0,0 +1,70 @@
+package service
+
+import (
+	"one-api/constant"
+	"one-api/model"
+	"time"
+)
+
+// GetUserCreditLimit 后付费用户允许透支的额度，未设置时为 0，即预付费用户
+func GetUserCreditLimit(userSetting map[string]interface{}) int {
+	if v, ok := userSetting[constant.UserSettingCreditLimit].(float64); ok && v > 0 {
+		return int(v)
+	}
+	return 0
+}
+
+// GetUserAvailableQuota 用户可用额度，后付费用户可在余额基础上透支至信用额度
+func GetUserAvailableQuota(userSetting map[string]interface{}, userQuota int) int {
+	return userQuota + GetUserCreditLimit(userSetting)
+}
+
+// agingBuckets 账龄区间（天），最后一个区间不设上限
+var agingBuckets = []int{30, 60, 90}
+
+// AgingItem 用户欠款账龄，欠款按先进先出原则视为由最近的消费产生
+type AgingItem struct {
+	UserId      int    `json:"user_id"`
+	Username    string `json:"username"`
+	CreditLimit int    `json:"credit_limit"`
+	Outstanding int64  `json:"outstanding"`
+	// Buckets 依次为 0-30、31-60、61-90、90 天以上的欠款
+	Buckets []int64 `json:"buckets"`
+}
+
+// BuildOutstandingAging 生成所有欠款用户的账龄报表
+func BuildOutstandingAging() ([]*AgingItem, error) {
+	users, err := model.GetUsersWithOutstandingBalance()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	items := make([]*AgingItem, 0, len(users))
+	for _, user := range users {
+		item := &AgingItem{
+			UserId:      user.Id,
+			Username:    user.Username,
+			CreditLimit: GetUserCreditLimit(user.GetSetting()),
+			Outstanding: int64(-user.Quota),
+			Buckets:     make([]int64, len(agingBuckets)+1),
+		}
+		remaining := item.Outstanding
+		end := now
+		for i, days := range agingBuckets {
+			start := now.AddDate(0, 0, -days)
+			consumed, err := model.SumUserConsumedQuota(user.Id, start.Unix(), end.Unix())
+			if err != nil {
+				return nil, err
+			}
+			item.Buckets[i] = min(consumed, remaining)
+			remaining -= item.Buckets[i]
+			end = start
+			if remaining <= 0 {
+				break
+			}
+		}
+		item.Buckets[len(agingBuckets)] = remaining
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// renderPromptTemplate 替换模板变量，内置变量 user_id、username、group、model、date 等可被模板变量覆盖
+func renderPromptTemplate(text string, template *operation_setting.PromptTemplate, c *gin.Context, relayInfo *relaycommon.RelayInfo) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	variables := relayInfo.TemplateVariables(c)
+	for name, value := range template.Variables {
+		variables[name] = value
+	}
+	return relaycommon.RenderTemplate(text, variables)
+}
+
+// ApplyPromptTemplate 将分组与令牌绑定的模板前后缀拼接到首条系统消息中，没有系统消息时插入一条。
+// 分组模板先应用，令牌模板再包裹在外层，因此令牌模板的前缀位于最前、后缀位于最后；返回请求是否被修改
+func ApplyPromptTemplate(c *gin.Context, relayInfo *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	tokenTemplate, _ := relayInfo.TokenSetting[constant.TokenSettingPromptTemplate].(string)
+	applied := false
+	for _, template := range operation_setting.GetPromptTemplates(tokenTemplate, relayInfo.Group) {
+		if applyPromptTemplate(c, relayInfo, request, template) {
+			applied = true
+		}
+	}
+	return applied
+}
+
+func applyPromptTemplate(c *gin.Context, relayInfo *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest, template *operation_setting.PromptTemplate) bool {
+	prefix := renderPromptTemplate(template.SystemPrefix, template, c, relayInfo)
+	suffix := renderPromptTemplate(template.SystemSuffix, template, c, relayInfo)
+	if prefix == "" && suffix == "" {
+		return false
+	}
+	if len(request.Messages) > 0 && request.Messages[0].Role == "system" {
+		parts := []string{prefix, request.Messages[0].StringContent(), suffix}
+		request.Messages[0].SetStringContent(joinNonEmpty(parts, "\n"))
+		return true
+	}
+	systemMessage := dto.Message{Role: "system"}
+	systemMessage.SetStringContent(joinNonEmpty([]string{prefix, suffix}, "\n"))
+	request.Messages = append([]dto.Message{systemMessage}, request.Messages...)
+	return true
+}
+
+func joinNonEmpty(parts []string, sep string) string {
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return strings.Join(result, sep)
+}
@@ -28,11 +28,36 @@ type TokenDetails struct {
 type QuotaInfo struct {
 	InputDetails  TokenDetails
 	OutputDetails TokenDetails
-	ModelName     string
-	UsePrice      bool
-	ModelPrice    float64
-	ModelRatio    float64
-	GroupRatio    float64
+	// InputTokens / OutputTokens 为上游返回的总 token 数，明细缺失时按文本 token 计费
+	InputTokens        int
+	OutputTokens       int
+	InputAudioSeconds  float64
+	OutputAudioSeconds float64
+	ModelName          string
+	UsePrice           bool
+	ModelPrice         float64
+	ModelRatio         float64
+	GroupRatio         float64
+}
+
+func (info QuotaInfo) hasTokenDetails() bool {
+	return info.InputDetails.TextTokens+info.InputDetails.AudioTokens+
+		info.OutputDetails.TextTokens+info.OutputDetails.AudioTokens > 0
+}
+
+// audioMinutePrice 上游未返回 token 明细且本地统计到音频时长时，按分钟价格计费
+func (info QuotaInfo) audioMinutePrice() (operation_setting.AudioMinutePrice, bool) {
+	if info.hasTokenDetails() || info.InputAudioSeconds+info.OutputAudioSeconds <= 0 {
+		return operation_setting.AudioMinutePrice{}, false
+	}
+	return operation_setting.GetAudioMinutePrice(info.ModelName)
+}
+
+// audioMinuteQuota 按输入、输出音频时长与分钟价格计算额度
+func (info QuotaInfo) audioMinuteQuota(price operation_setting.AudioMinutePrice) decimal.Decimal {
+	return decimal.NewFromFloat(info.InputAudioSeconds).Div(decimal.NewFromInt(60)).Mul(decimal.NewFromFloat(price.Input)).
+		Add(decimal.NewFromFloat(info.OutputAudioSeconds).Div(decimal.NewFromInt(60)).Mul(decimal.NewFromFloat(price.Output))).
+		Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Mul(decimal.NewFromFloat(info.GroupRatio))
 }
 
 func calculateAudioQuota(info QuotaInfo) int {
@@ -45,6 +70,15 @@ func calculateAudioQuota(info QuotaInfo) int {
 		return int(quota.IntPart())
 	}
 
+	if price, ok := info.audioMinutePrice(); ok {
+		return int(info.audioMinuteQuota(price).Round(0).IntPart())
+	}
+	if !info.hasTokenDetails() {
+		// 无明细且无法按时长计费，全部按文本 token 计费
+		info.InputDetails.TextTokens = info.InputTokens
+		info.OutputDetails.TextTokens = info.OutputTokens
+	}
+
 	completionRatio := decimal.NewFromFloat(operation_setting.GetCompletionRatio(info.ModelName))
 	audioRatio := decimal.NewFromFloat(operation_setting.GetAudioRatio(info.ModelName))
 	audioCompletionRatio := decimal.NewFromFloat(operation_setting.GetAudioCompletionRatio(info.ModelName))
@@ -78,7 +112,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := GetPayerQuota(relayInfo)
 	if err != nil {
 		return err
 	}
@@ -105,15 +139,19 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   relayInfo.UsePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		InputTokens:        usage.InputTokens,
+		OutputTokens:       usage.OutputTokens,
+		InputAudioSeconds:  usage.InputAudioSeconds,
+		OutputAudioSeconds: usage.OutputAudioSeconds,
+		ModelName:          modelName,
+		UsePrice:           relayInfo.UsePrice,
+		ModelRatio:         modelRatio,
+		GroupRatio:         groupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
 
-	if userQuota < quota {
+	if GetPayerAvailableQuota(relayInfo, userQuota) < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(quota))
 	}
 
@@ -125,7 +163,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if err != nil {
 		return err
 	}
-	common.LogInfo(ctx, "realtime streaming consume quota success, quota: "+fmt.Sprintf("%d", quota))
+	common.LogDebug(ctx, "realtime streaming consume quota success, quota: "+fmt.Sprintf("%d", quota))
 	return nil
 }
 
@@ -154,10 +192,14 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		InputTokens:        usage.InputTokens,
+		OutputTokens:       usage.OutputTokens,
+		InputAudioSeconds:  usage.InputAudioSeconds,
+		OutputAudioSeconds: usage.OutputAudioSeconds,
+		ModelName:          modelName,
+		UsePrice:           usePrice,
+		ModelRatio:         modelRatio,
+		GroupRatio:         groupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -170,6 +212,11 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	} else {
 		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
 	}
+	minutePrice, minuteBilling := quotaInfo.audioMinutePrice()
+	if !usePrice && minuteBilling {
+		logContent = fmt.Sprintf("上游未返回 token 明细，按音频时长计费：输入 %.2f 分钟（$%.4f/分钟），输出 %.2f 分钟（$%.4f/分钟），分组倍率 %.2f",
+			quotaInfo.InputAudioSeconds/60, minutePrice.Input, quotaInfo.OutputAudioSeconds/60, minutePrice.Output, groupRatio)
+	}
 
 	// record all the consume log even if quota is 0
 	if totalTokens == 0 {
@@ -190,6 +237,12 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice)
+	if !usePrice && minuteBilling {
+		other["audio_minute_billing"] = true
+		other["audio_input_seconds"] = quotaInfo.InputAudioSeconds
+		other["audio_output_seconds"] = quotaInfo.OutputAudioSeconds
+	}
+	SettleConsumeQuota(relayInfo, usage.OutputTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, usage.InputTokens, usage.OutputTokens, logModel,
 		tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, relayInfo.Group, other)
 }
@@ -229,11 +282,18 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		calculateQuota = 1
 	}
 
+	// 内置工具按次附加计费，如 Claude 服务端 web search
+	dToolQuota, toolSurcharges := CalculateToolSurcharge(relayInfo, modelName, groupRatio)
+	calculateQuota += dToolQuota.InexactFloat64()
+
 	quota := int(calculateQuota)
 
 	totalTokens := promptTokens + completionTokens
 
 	var logContent string
+	if len(toolSurcharges) > 0 {
+		logContent = ToolSurchargeContent(relayInfo, toolSurcharges)
+	}
 	// record all the consume log even if quota is 0
 	if totalTokens == 0 {
 		// in this case, must be some error happened
@@ -257,85 +317,55 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio, cacheCreationTokens, cacheCreationRatio, modelPrice)
+	SetToolSurchargeOtherInfo(other, toolSurcharges)
+	SettleConsumeQuota(relayInfo, completionTokens, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, promptTokens, completionTokens, modelName,
 		tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, relayInfo.Group, other)
 }
 
-func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
-	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
-
-	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
-	textInputTokens := usage.PromptTokensDetails.TextTokens
-	textOutTokens := usage.CompletionTokenDetails.TextTokens
-
-	audioInputTokens := usage.PromptTokensDetails.AudioTokens
-	audioOutTokens := usage.CompletionTokenDetails.AudioTokens
-
-	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(operation_setting.GetCompletionRatio(relayInfo.OriginModelName))
-	audioRatio := decimal.NewFromFloat(operation_setting.GetAudioRatio(relayInfo.OriginModelName))
-	audioCompletionRatio := decimal.NewFromFloat(operation_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
-
-	modelRatio := priceData.ModelRatio
-	groupRatio := priceData.GroupRatio
-	modelPrice := priceData.ModelPrice
-	usePrice := priceData.UsePrice
-
-	quotaInfo := QuotaInfo{
+// AudioMinuteQuota 上游未返回任何 token 明细且本地统计到音频时长时，按分钟价格计算额度，不适用时返回 false
+func AudioMinuteQuota(modelName string, usage *dto.Usage, inputSeconds float64, outputSeconds float64, groupRatio float64) (decimal.Decimal, bool) {
+	info := QuotaInfo{
 		InputDetails: TokenDetails{
-			TextTokens:  textInputTokens,
-			AudioTokens: audioInputTokens,
+			TextTokens:  usage.PromptTokensDetails.TextTokens,
+			AudioTokens: usage.PromptTokensDetails.AudioTokens,
 		},
 		OutputDetails: TokenDetails{
-			TextTokens:  textOutTokens,
-			AudioTokens: audioOutTokens,
+			TextTokens:  usage.CompletionTokenDetails.TextTokens,
+			AudioTokens: usage.CompletionTokenDetails.AudioTokens,
 		},
-		ModelName:  relayInfo.OriginModelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		InputAudioSeconds:  inputSeconds,
+		OutputAudioSeconds: outputSeconds,
+		ModelName:          modelName,
+		GroupRatio:         groupRatio,
 	}
-
-	quota := calculateAudioQuota(quotaInfo)
-
-	totalTokens := usage.TotalTokens
-	var logContent string
-	if !usePrice {
-		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，音频倍率 %.2f，音频补全倍率 %.2f，分组倍率 %.2f",
-			modelRatio, completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), groupRatio)
-	} else {
-		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
+	price, ok := info.audioMinutePrice()
+	if !ok {
+		return decimal.Zero, false
 	}
+	return info.audioMinuteQuota(price), true
+}
 
-	// record all the consume log even if quota is 0
-	if totalTokens == 0 {
-		// in this case, must be some error happened
-		// we cannot just return, because we may have to return the pre-consumed quota
-		quota = 0
-		logContent += fmt.Sprintf("（可能是上游超时）")
-		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
-			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, relayInfo.OriginModelName, preConsumedQuota))
-	} else {
-		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+// PreConsumeQuotaLimits 预扣额度前检查预算硬上限，并按预估额度预留按模型额度限制；
+// 之后请求失败时需调用 ReleaseModelQuota 释放预留，成功时通过 SettleQuotaLimits 结算
+func PreConsumeQuotaLimits(relayInfo *relaycommon.RelayInfo, quota int) *dto.OpenAIErrorWithStatusCode {
+	if openaiErr := CheckBudgetHardCap(relayInfo); openaiErr != nil {
+		return openaiErr
 	}
+	return ReserveModelQuota(relayInfo, quota)
+}
 
-	quotaDelta := quota - preConsumedQuota
-	if quotaDelta != 0 {
-		err := PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
-		if err != nil {
-			common.LogError(ctx, "error consuming token remain quota: "+err.Error())
-		}
-	}
+// SettleQuotaLimits 按实际消耗结算按模型额度限制，并计入预算
+func SettleQuotaLimits(relayInfo *relaycommon.RelayInfo, quota int) {
+	SettleModelQuota(relayInfo, quota)
+	RecordBudgetUsage(relayInfo, quota)
+}
 
-	logModel := relayInfo.OriginModelName
-	if extraContent != "" {
-		logContent += ", " + extraContent
-	}
-	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
-		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice)
-	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, usage.PromptTokens, usage.CompletionTokens, logModel,
-		tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, relayInfo.Group, other)
+// SettleConsumeQuota 对话类请求结算时记录 TPM 用量、结算额度限制与预算，并扣减用户套餐
+func SettleConsumeQuota(relayInfo *relaycommon.RelayInfo, completionTokens int, quota int) {
+	RecordTokenRateLimitUsage(relayInfo, completionTokens)
+	SettleQuotaLimits(relayInfo, quota)
+	ConsumeUserPackages(relayInfo, quota)
 }
 
 func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
@@ -365,9 +395,9 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = DecreasePayerQuota(relayInfo, quota)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = model.IncreasePayerQuota(relayInfo.UserId, relayInfo.OrganizationId, -quota, false)
 	}
 	if err != nil {
 		return err
@@ -384,7 +414,8 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
-	if sendEmail {
+	// 组织额度池不按个人额度提醒
+	if sendEmail && relayInfo.OrganizationId == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -415,6 +446,11 @@ func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preCon
 			if err != nil {
 				common.SysError(fmt.Sprintf("failed to send quota notify to user %d: %s", relayInfo.UserId, err.Error()))
 			}
+			emitWebhookEventOnce(fmt.Sprintf("%s:%d", dto.WebhookEventUserQuotaLow, relayInfo.UserId), dto.WebhookEventUserQuotaLow, map[string]interface{}{
+				"user_id":   relayInfo.UserId,
+				"quota":     relayInfo.UserQuota - consumeQuota,
+				"threshold": threshold,
+			})
 		}
 	})
 }
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/limiter"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitWindow = time.Minute
+
+type rateLimitSubject struct {
+	name string
+	key  string
+	rpm  int64
+	tpm  int64
+}
+
+func getRateLimitValue(setting map[string]interface{}, key string) int64 {
+	if v, ok := setting[key].(float64); ok && v > 0 {
+		return int64(v)
+	}
+	return 0
+}
+
+// getRateLimitSubjects 返回需要限流的对象，令牌与用户的限制分别生效
+func getRateLimitSubjects(relayInfo *relaycommon.RelayInfo) []rateLimitSubject {
+	var subjects []rateLimitSubject
+	tokenRPM := getRateLimitValue(relayInfo.TokenSetting, constant.TokenSettingRPM)
+	tokenTPM := getRateLimitValue(relayInfo.TokenSetting, constant.TokenSettingTPM)
+	if tokenRPM > 0 || tokenTPM > 0 {
+		subjects = append(subjects, rateLimitSubject{
+			name: "令牌",
+			key:  fmt.Sprintf("token:%d", relayInfo.TokenId),
+			rpm:  tokenRPM,
+			tpm:  tokenTPM,
+		})
+	}
+	userRPM := getRateLimitValue(relayInfo.UserSetting, constant.UserSettingRPM)
+	userTPM := getRateLimitValue(relayInfo.UserSetting, constant.UserSettingTPM)
+	if userRPM > 0 || userTPM > 0 {
+		subjects = append(subjects, rateLimitSubject{
+			name: "用户",
+			key:  fmt.Sprintf("user:%d", relayInfo.UserId),
+			rpm:  userRPM,
+			tpm:  userTPM,
+		})
+	}
+	return subjects
+}
+
+func rpmKey(subject rateLimitSubject) string {
+	return "rateLimit:rpm:" + subject.key
+}
+
+func tpmKey(subject rateLimitSubject) string {
+	return "rateLimit:tpm:" + subject.key
+}
+
+func formatResetDuration(resetAt time.Time) string {
+	d := time.Until(resetAt)
+	if d < 0 {
+		d = 0
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+func setRateLimitHeaders(c *gin.Context, kind string, limit int64, result limiter.WindowResult) {
+	remaining := limit - result.Used
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header("x-ratelimit-limit-"+kind, strconv.FormatInt(limit, 10))
+	c.Header("x-ratelimit-remaining-"+kind, strconv.FormatInt(remaining, 10))
+	c.Header("x-ratelimit-reset-"+kind, formatResetDuration(result.ResetAt))
+}
+
+func rateLimitError(message string) *dto.OpenAIErrorWithStatusCode {
+	return &dto.OpenAIErrorWithStatusCode{
+		Error: dto.OpenAIError{
+			Message: message,
+			Type:    "requests",
+			Code:    "rate_limit_exceeded",
+		},
+		StatusCode: http.StatusTooManyRequests,
+		LocalError: true,
+	}
+}
+
+// CheckTokenRateLimit 按分钟滑动窗口检查令牌与用户的 RPM/TPM 限制，TPM 按本次请求的输入 token 预估，
+// 输出 token 在请求完成后通过 RecordTokenRateLimitUsage 计入窗口。
+// 所有限制在同一次原子操作中检查并记录，并发请求不会同时通过检查，被任一限制拒绝的请求不会消耗其他限制的额度
+func CheckTokenRateLimit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dto.OpenAIErrorWithStatusCode {
+	subjects := getRateLimitSubjects(relayInfo)
+	if len(subjects) == 0 {
+		return nil
+	}
+	cost := int64(relayInfo.PromptTokens)
+	type windowTarget struct {
+		subject rateLimitSubject
+		kind    string
+		limit   int64
+		cost    int64
+	}
+	var targets []windowTarget
+	var requests []limiter.WindowRequest
+	for _, subject := range subjects {
+		if subject.rpm > 0 {
+			targets = append(targets, windowTarget{subject: subject, kind: "requests", limit: subject.rpm, cost: 1})
+			requests = append(requests, limiter.WindowRequest{Key: rpmKey(subject), Limit: subject.rpm, Cost: 1})
+		}
+		if subject.tpm > 0 {
+			targets = append(targets, windowTarget{subject: subject, kind: "tokens", limit: subject.tpm, cost: cost})
+			requests = append(requests, limiter.WindowRequest{Key: tpmKey(subject), Limit: subject.tpm, Cost: cost})
+		}
+	}
+	results, err := limiter.SlidingWindowAllowMulti(context.Background(), requests, rateLimitWindow)
+	if err != nil {
+		common.LogError(c, err.Error())
+		return OpenAIErrorWrapperLocal(err, "rate_limit_check_failed", http.StatusInternalServerError)
+	}
+	for i, result := range results {
+		target := targets[i]
+		setRateLimitHeaders(c, target.kind, target.limit, result)
+		if result.Allowed {
+			continue
+		}
+		if target.kind == "requests" {
+			return rateLimitError(fmt.Sprintf("%s已达到每分钟请求数限制 %d，请在 %s 后重试", target.subject.name, target.limit, formatResetDuration(result.ResetAt)))
+		}
+		return rateLimitError(fmt.Sprintf("%s已达到每分钟 token 数限制 %d，已使用 %d，本次请求 %d，请在 %s 后重试",
+			target.subject.name, target.limit, result.Used, target.cost, formatResetDuration(result.ResetAt)))
+	}
+	return nil
+}
+
+// RecordTokenRateLimitUsage 请求完成后将输出 token 计入 TPM 窗口
+func RecordTokenRateLimitUsage(relayInfo *relaycommon.RelayInfo, completionTokens int) {
+	if completionTokens <= 0 {
+		return
+	}
+	for _, subject := range getRateLimitSubjects(relayInfo) {
+		if subject.tpm <= 0 {
+			continue
+		}
+		if _, err := limiter.SlidingWindowAllow(context.Background(), tpmKey(subject), 0, rateLimitWindow, int64(completionTokens)); err != nil {
+			common.SysError("failed to record tpm usage: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseCacheEntry 缓存的上游响应及其用量，命中时按原用量记录日志但不扣费
+type ResponseCacheEntry struct {
+	Body             []byte `json:"body"`
+	ContentType      string `json:"content_type"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+var responseMemoryCache = common.NewTTLCache[*ResponseCacheEntry]()
+
+// ResponseCacheEnabled 需同时开启全局开关与令牌的 response_cache 设置，且仅用于非流式请求
+func ResponseCacheEnabled(c *gin.Context, modelName string, isStream bool) bool {
+	if isStream || !operation_setting.ShouldCacheResponse(modelName) {
+		return false
+	}
+	enabled, _ := c.GetStringMap(constant.ContextKeyTokenSetting)[constant.TokenSettingResponseCache].(bool)
+	return enabled
+}
+
+// ResponseCacheKey 对请求体做规范化（解析后重新序列化，字段按名称排序）再与模型名一起计算哈希，
+// 使字段顺序、空白不同的相同请求命中同一缓存
+func ResponseCacheKey(modelName string, body []byte) (string, error) {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", err
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(modelName))
+	hash.Write([]byte{0})
+	hash.Write(normalized)
+	return "response_cache:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ResponseCacheScopedKey 在请求哈希前加上用户、令牌与分组，缓存的响应不会跨用户、令牌或分组共享
+func ResponseCacheScopedKey(relayInfo *relaycommon.RelayInfo, body []byte) (string, error) {
+	key, err := ResponseCacheKey(relayInfo.OriginModelName, body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d:%d:%s", key, relayInfo.UserId, relayInfo.TokenId, relayInfo.Group), nil
+}
+
+func GetResponseCache(key string) (*ResponseCacheEntry, bool) {
+	return getCacheEntry(responseMemoryCache, key)
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry) {
+	setting := operation_setting.GetResponseCacheSetting()
+	if setting.MaxBodyBytes > 0 && len(entry.Body) > setting.MaxBodyBytes {
+		return
+	}
+	setCacheEntry(responseMemoryCache, key, entry, time.Duration(setting.TTLSeconds)*time.Second, setting.MaxEntries)
+}
+
+// getCacheEntry 启用 Redis 时从 Redis 读取，否则使用节点内存缓存
+func getCacheEntry(memory *common.TTLCache[*ResponseCacheEntry], key string) (*ResponseCacheEntry, bool) {
+	if !common.RedisEnabled {
+		return memory.Get(key)
+	}
+	data, err := common.RDB.Get(context.Background(), key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	var entry ResponseCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func setCacheEntry(memory *common.TTLCache[*ResponseCacheEntry], key string, entry *ResponseCacheEntry, ttl time.Duration, maxEntries int) {
+	if ttl <= 0 {
+		return
+	}
+	if !common.RedisEnabled {
+		memory.Set(key, entry, ttl, maxEntries)
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		common.SysError("failed to marshal cache entry: " + err.Error())
+		return
+	}
+	if err := common.RDB.Set(context.Background(), key, data, ttl).Err(); err != nil {
+		common.SysError("failed to set cache entry: " + err.Error())
+	}
+}
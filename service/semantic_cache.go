@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type semanticCacheItem struct {
+	vector    []float64
+	norm      float64
+	entry     *ResponseCacheEntry
+	expiresAt time.Time
+}
+
+// 语义缓存需要逐条计算相似度，只保存在节点内存中，按 SemanticCacheScope 分桶
+var semanticCacheItems = make(map[string][]*semanticCacheItem)
+var semanticCacheLock sync.RWMutex
+var semanticCacheSweptAt time.Time
+
+func SemanticCacheEnabled(c *gin.Context, relayInfo *relaycommon.RelayInfo) bool {
+	if relayInfo.IsStream || !operation_setting.ShouldUseSemanticCache(relayInfo.OriginModelName) {
+		return false
+	}
+	enabled, _ := c.GetStringMap(constant.ContextKeyTokenSetting)[constant.TokenSettingSemanticCache].(bool)
+	return enabled
+}
+
+// SemanticCacheScope 返回语义缓存的分桶键：只在同一用户、分组、模型，且除消息外的请求参数（tools、response_format、
+// temperature、max_tokens 等）完全相同的请求之间共享缓存
+func SemanticCacheScope(relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (string, error) {
+	params := *textRequest
+	params.Messages = nil
+	body, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	paramsKey, err := ResponseCacheKey(relayInfo.OriginModelName, body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%s:%s", relayInfo.UserId, relayInfo.Group, paramsKey), nil
+}
+
+// SemanticCacheText 将对话消息拼接为用于向量化的文本，保留角色以区分系统提示词与用户输入
+func SemanticCacheText(messages []dto.Message) string {
+	var builder strings.Builder
+	for _, message := range messages {
+		builder.WriteString(message.Role)
+		builder.WriteString(": ")
+		builder.WriteString(message.StringContent())
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func vectorNorm(vector []float64) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += v * v
+	}
+	return math.Sqrt(sum)
+}
+
+// GetSemanticCache 返回同一分桶内相似度最高且不低于阈值的缓存
+func GetSemanticCache(scope string, vector []float64) (*ResponseCacheEntry, float64, bool) {
+	norm := vectorNorm(vector)
+	if norm == 0 {
+		return nil, 0, false
+	}
+	threshold := operation_setting.GetSemanticCacheSetting().Threshold
+	now := time.Now()
+	var best *semanticCacheItem
+	var bestScore float64
+	semanticCacheLock.RLock()
+	for _, item := range semanticCacheItems[scope] {
+		if now.After(item.expiresAt) || len(item.vector) != len(vector) || item.norm == 0 {
+			continue
+		}
+		var dot float64
+		for i := range vector {
+			dot += vector[i] * item.vector[i]
+		}
+		score := dot / (norm * item.norm)
+		if score > bestScore {
+			best, bestScore = item, score
+		}
+	}
+	semanticCacheLock.RUnlock()
+	if best == nil || bestScore < threshold {
+		return nil, bestScore, false
+	}
+	return best.entry, bestScore, true
+}
+
+// sweepSemanticCache 每小时清理全部分桶中的过期条目并删除空分桶，调用方需持有写锁
+func sweepSemanticCache(now time.Time) {
+	if now.Sub(semanticCacheSweptAt) < time.Hour {
+		return
+	}
+	semanticCacheSweptAt = now
+	for scope, items := range semanticCacheItems {
+		alive := items[:0:0]
+		for _, item := range items {
+			if now.Before(item.expiresAt) {
+				alive = append(alive, item)
+			}
+		}
+		if len(alive) == 0 {
+			delete(semanticCacheItems, scope)
+		} else {
+			semanticCacheItems[scope] = alive
+		}
+	}
+}
+
+func SetSemanticCache(scope string, vector []float64, entry *ResponseCacheEntry) {
+	setting := operation_setting.GetSemanticCacheSetting()
+	ttl := time.Duration(setting.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	semanticCacheLock.Lock()
+	defer semanticCacheLock.Unlock()
+	sweepSemanticCache(now)
+	items := semanticCacheItems[scope][:0:0]
+	for _, item := range semanticCacheItems[scope] {
+		if now.Before(item.expiresAt) {
+			items = append(items, item)
+		}
+	}
+	items = append(items, &semanticCacheItem{vector: vector, norm: vectorNorm(vector), entry: entry, expiresAt: now.Add(ttl)})
+	if setting.MaxEntries > 0 && len(items) > setting.MaxEntries {
+		items = items[len(items)-setting.MaxEntries:]
+	}
+	semanticCacheItems[scope] = items
+}
+
+// ChargeSemanticCacheEmbedding 按向量模型的倍率或价格扣除向量化费用并记录消费日志
+func ChargeSemanticCacheEmbedding(c *gin.Context, relayInfo *relaycommon.RelayInfo, promptTokens int) {
+	embeddingModel := operation_setting.GetSemanticCacheSetting().EmbeddingModel
+	groupRatio := setting.GetGroupRatio(relayInfo.Group)
+	var quota int
+	other := map[string]interface{}{
+		"semantic_cache": true,
+		"group_ratio":    groupRatio,
+	}
+	if modelPrice, ok := operation_setting.GetModelPrice(embeddingModel, false); ok {
+		quota = int(modelPrice * common.QuotaPerUnit * groupRatio)
+		other["model_price"] = modelPrice
+	} else {
+		modelRatio, _ := operation_setting.GetModelRatio(embeddingModel)
+		quota = int(math.Round(float64(promptTokens) * modelRatio * groupRatio))
+		other["model_ratio"] = modelRatio
+	}
+	if quota > 0 {
+		if err := PostConsumeQuota(relayInfo, quota, 0, false); err != nil {
+			common.LogError(c, "failed to charge semantic cache embedding: "+err.Error())
+		}
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+	}
+	model.RecordConsumeLog(c, relayInfo.UserId, 0, promptTokens, 0, embeddingModel, c.GetString("token_name"), quota,
+		"语义缓存向量化", relayInfo.TokenId, relayInfo.UserQuota, 0, false, relayInfo.Group, other)
+}
@@ -1,14 +1,26 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"one-api/dto"
-	"one-api/setting"
 	"strings"
 )
 
-func CheckSensitiveMessages(messages []dto.Message) ([]string, error) {
+func sensitiveError(hits []SensitiveHit) ([]string, error) {
+	words := make([]string, 0, len(hits))
+	var categories []string
+	seen := make(map[string]bool)
+	for _, hit := range hits {
+		words = append(words, hit.Word)
+		if !seen[hit.Category] {
+			seen[hit.Category] = true
+			categories = append(categories, hit.Category)
+		}
+	}
+	return words, fmt.Errorf("sensitive words detected, category: %s", strings.Join(categories, ", "))
+}
+
+func CheckSensitiveMessages(group string, messages []dto.Message) ([]string, error) {
 	if len(messages) == 0 {
 		return nil, nil
 	}
@@ -24,71 +36,64 @@ func CheckSensitiveMessages(messages []dto.Message) ([]string, error) {
 			if m.Text == "" {
 				continue
 			}
-			if ok, words := SensitiveWordContains(m.Text); ok {
-				return words, errors.New("sensitive words detected")
+			if hits := SensitiveSearch(group, m.Text, true); len(hits) > 0 {
+				return sensitiveError(hits)
 			}
 		}
 	}
 	return nil, nil
 }
 
-func CheckSensitiveText(text string) ([]string, error) {
-	if ok, words := SensitiveWordContains(text); ok {
-		return words, errors.New("sensitive words detected")
+func CheckSensitiveText(group string, text string) ([]string, error) {
+	if hits := SensitiveSearch(group, text, true); len(hits) > 0 {
+		return sensitiveError(hits)
 	}
 	return nil, nil
 }
 
-func CheckSensitiveInput(input any) ([]string, error) {
+func CheckSensitiveInput(group string, input any) ([]string, error) {
 	switch v := input.(type) {
 	case string:
-		return CheckSensitiveText(v)
+		return CheckSensitiveText(group, v)
 	case []string:
 		var builder strings.Builder
 		for _, s := range v {
 			builder.WriteString(s)
 		}
-		return CheckSensitiveText(builder.String())
+		return CheckSensitiveText(group, builder.String())
 	}
-	return CheckSensitiveText(fmt.Sprintf("%v", input))
+	return CheckSensitiveText(group, fmt.Sprintf("%v", input))
 }
 
-// SensitiveWordContains 是否包含敏感词，返回是否包含敏感词和敏感词列表
-func SensitiveWordContains(text string) (bool, []string) {
-	if len(setting.SensitiveWords) == 0 {
-		return false, nil
-	}
+// SensitiveSearch 使用分组启用的规则检查文本，返回命中列表
+func SensitiveSearch(group string, text string, stopImmediately bool) []SensitiveHit {
 	if len(text) == 0 {
+		return nil
+	}
+	engine := getSensitiveEngine(group)
+	if engine.empty() {
+		return nil
+	}
+	return engine.Search([]rune(text), stopImmediately)
+}
+
+// SensitiveWordContains 是否包含敏感词，返回是否包含敏感词和敏感词列表
+func SensitiveWordContains(group string, text string) (bool, []string) {
+	hits := SensitiveSearch(group, text, true)
+	if len(hits) == 0 {
 		return false, nil
 	}
-	checkText := strings.ToLower(text)
-	return AcSearch(checkText, setting.SensitiveWords, true)
+	words, _ := sensitiveError(hits)
+	return true, words
 }
 
 // SensitiveWordReplace 敏感词替换，返回是否包含敏感词和替换后的文本
-func SensitiveWordReplace(text string, returnImmediately bool) (bool, []string, string) {
-	if len(setting.SensitiveWords) == 0 {
+func SensitiveWordReplace(group string, text string, returnImmediately bool) (bool, []string, string) {
+	runes := []rune(text)
+	hits := SensitiveSearch(group, text, returnImmediately)
+	if len(hits) == 0 {
 		return false, nil, text
 	}
-	checkText := strings.ToLower(text)
-	m := InitAc(setting.SensitiveWords)
-	hits := m.MultiPatternSearch([]rune(checkText), returnImmediately)
-	if len(hits) > 0 {
-		words := make([]string, 0, len(hits))
-		var builder strings.Builder
-		builder.Grow(len(text))
-		lastPos := 0
-
-		for _, hit := range hits {
-			pos := hit.Pos
-			word := string(hit.Word)
-			builder.WriteString(text[lastPos:pos])
-			builder.WriteString("**###**")
-			lastPos = pos + len(word)
-			words = append(words, word)
-		}
-		builder.WriteString(text[lastPos:])
-		return true, words, builder.String()
-	}
-	return false, nil, text
+	words, _ := sensitiveError(hits)
+	return true, words, string(maskSensitiveHits(runes, hits))
 }
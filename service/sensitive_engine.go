@@ -0,0 +1,162 @@
+package service
+
+import (
+	"one-api/common"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/anknown/ahocorasick"
+)
+
+// 系统设置中的敏感词列表所属的分类
+const sensitiveCategoryDefault = "default"
+
+// SensitiveHit 一次命中，Start、End 为 rune 下标
+type SensitiveHit struct {
+	Word     string
+	Category string
+	Start    int
+	End      int
+}
+
+type sensitiveRegexRule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// sensitiveEngine 将若干分类的词表合并为一个 Aho-Corasick 匹配器，正则规则逐条匹配
+type sensitiveEngine struct {
+	version    int64
+	machine    *goahocorasick.Machine
+	categories map[string]string // 小写敏感词 -> 分类
+	regexes    []sensitiveRegexRule
+	maxWordLen int
+}
+
+// 按启用的分类组合缓存匹配器，分类相同的分组共用同一个
+var sensitiveEngines = make(map[string]*sensitiveEngine)
+var sensitiveEnginesLock sync.Mutex
+
+func buildSensitiveEngine(categories []string, version int64) *sensitiveEngine {
+	engine := &sensitiveEngine{version: version, categories: make(map[string]string)}
+	var words []string
+	addWord := func(word string, category string) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" {
+			return
+		}
+		if _, ok := engine.categories[word]; ok {
+			return
+		}
+		engine.categories[word] = category
+		words = append(words, word)
+		if n := utf8.RuneCountInString(word); n > engine.maxWordLen {
+			engine.maxWordLen = n
+		}
+	}
+	for _, word := range setting.SensitiveWords {
+		addWord(word, sensitiveCategoryDefault)
+	}
+	rules := operation_setting.GetSensitiveRuleSetting()
+	for _, name := range categories {
+		category, ok := rules.Categories[name]
+		if !ok {
+			continue
+		}
+		for _, word := range category.Words {
+			addWord(word, name)
+		}
+		for _, expr := range category.Regex {
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				common.SysError("invalid sensitive regex in category " + name + ": " + err.Error())
+				continue
+			}
+			engine.regexes = append(engine.regexes, sensitiveRegexRule{category: name, pattern: pattern})
+		}
+	}
+	if len(words) > 0 {
+		engine.machine = InitAc(words)
+	}
+	return engine
+}
+
+// getSensitiveEngine 返回分组对应的匹配器，规则变化后首次使用时重建
+func getSensitiveEngine(group string) *sensitiveEngine {
+	categories := operation_setting.GetSensitiveCategories(group)
+	key := strings.Join(categories, ",")
+	version := operation_setting.GetSensitiveRuleVersion()
+	sensitiveEnginesLock.Lock()
+	defer sensitiveEnginesLock.Unlock()
+	if engine, ok := sensitiveEngines[key]; ok && engine.version == version {
+		return engine
+	}
+	engine := buildSensitiveEngine(categories, version)
+	sensitiveEngines[key] = engine
+	return engine
+}
+
+func (e *sensitiveEngine) empty() bool {
+	return e.machine == nil && len(e.regexes) == 0
+}
+
+// Search 返回按起始位置排列的命中，stopImmediately 为 true 时找到一个即返回
+func (e *sensitiveEngine) Search(text []rune, stopImmediately bool) []SensitiveHit {
+	var hits []SensitiveHit
+	if e.machine != nil {
+		lower := []rune(strings.ToLower(string(text)))
+		for _, term := range e.machine.MultiPatternSearch(lower, stopImmediately) {
+			word := string(term.Word)
+			hits = append(hits, SensitiveHit{Word: word, Category: e.categories[word], Start: term.Pos, End: term.Pos + len(term.Word)})
+		}
+		if stopImmediately && len(hits) > 0 {
+			return hits
+		}
+	}
+	if len(e.regexes) == 0 {
+		return hits
+	}
+	str := string(text)
+	for _, rule := range e.regexes {
+		limit := -1
+		if stopImmediately {
+			limit = 1
+		}
+		for _, match := range rule.pattern.FindAllStringIndex(str, limit) {
+			start := utf8.RuneCountInString(str[:match[0]])
+			hits = append(hits, SensitiveHit{
+				Word:     str[match[0]:match[1]],
+				Category: rule.category,
+				Start:    start,
+				End:      start + utf8.RuneCountInString(str[match[0]:match[1]]),
+			})
+		}
+		if stopImmediately && len(hits) > 0 {
+			return hits
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Start < hits[j].Start
+	})
+	return hits
+}
+
+// maskSensitiveHits 将命中的内容替换为掩码，重叠的命中只替换第一个
+func maskSensitiveHits(text []rune, hits []SensitiveHit) []rune {
+	masked := make([]rune, 0, len(text))
+	lastPos := 0
+	for _, hit := range hits {
+		if hit.Start < lastPos {
+			continue
+		}
+		masked = append(masked, text[lastPos:hit.Start]...)
+		masked = append(masked, []rune(sensitiveMask)...)
+		lastPos = hit.End
+	}
+	return append(masked, text[lastPos:]...)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StatementDay struct {
+	Date  string                 `json:"date"`
+	Items []*model.StatementItem `json:"items"`
+}
+
+// Statement 用户某个账单周期（自然月）的消费汇总
+type Statement struct {
+	UserId    int                    `json:"user_id"`
+	Username  string                 `json:"username"`
+	Month     string                 `json:"month"`
+	StartTime int64                  `json:"start_time"`
+	EndTime   int64                  `json:"end_time"`
+	Days      []*StatementDay        `json:"days"`
+	Models    []*model.StatementItem `json:"models"`
+	Total     model.StatementItem    `json:"total"`
+	// Currency 用户的展示币种，ExchangeRate 为 1 美元可兑换的该币种金额
+	Currency     string  `json:"currency"`
+	ExchangeRate float64 `json:"exchange_rate"`
+}
+
+// ParseStatementMonth 解析 2006-01 格式的账单月份，为空时取上一个自然月
+func ParseStatementMonth(month string) (time.Time, error) {
+	if month == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -1, 0), nil
+	}
+	return time.ParseInLocation("2006-01", month, time.Local)
+}
+
+func addStatementItem(target *model.StatementItem, item *model.StatementItem) {
+	target.Requests += item.Requests
+	target.PromptTokens += item.PromptTokens
+	target.CompletionTokens += item.CompletionTokens
+	target.Quota += item.Quota
+}
+
+// BuildUserStatement 按天、按模型汇总用户当月的消费日志
+func BuildUserStatement(userId int, month time.Time) (*Statement, error) {
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, 0)
+	statement := &Statement{
+		UserId:    user.Id,
+		Username:  user.Username,
+		Month:     start.Format("2006-01"),
+		StartTime: start.Unix(),
+		EndTime:   end.Unix(),
+		Total:     model.StatementItem{ModelName: "total"},
+	}
+	userCurrency, _ := user.GetSetting()[constant.UserSettingDisplayCurrency].(string)
+	statement.Currency, statement.ExchangeRate = operation_setting.ResolveDisplayCurrency(userCurrency)
+	buckets, err := model.GetUserStatementBuckets(userId, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, err
+	}
+	// 分桶按时间升序返回，按本地日期合并为每天按模型的明细
+	models := make(map[string]*model.StatementItem)
+	dayItems := make(map[string]*model.StatementItem)
+	var day *StatementDay
+	for _, bucket := range buckets {
+		date := time.Unix(bucket.Bucket, 0).In(time.Local).Format("2006-01-02")
+		if day == nil || day.Date != date {
+			day = &StatementDay{Date: date}
+			statement.Days = append(statement.Days, day)
+			dayItems = make(map[string]*model.StatementItem)
+		}
+		item, ok := dayItems[bucket.ModelName]
+		if !ok {
+			item = &model.StatementItem{ModelName: bucket.ModelName}
+			dayItems[bucket.ModelName] = item
+			day.Items = append(day.Items, item)
+		}
+		addStatementItem(item, &bucket.StatementItem)
+		summary, ok := models[bucket.ModelName]
+		if !ok {
+			summary = &model.StatementItem{ModelName: bucket.ModelName}
+			models[bucket.ModelName] = summary
+			statement.Models = append(statement.Models, summary)
+		}
+		addStatementItem(summary, &bucket.StatementItem)
+		addStatementItem(&statement.Total, &bucket.StatementItem)
+	}
+	for _, day := range statement.Days {
+		sort.Slice(day.Items, func(i, j int) bool { return day.Items[i].ModelName < day.Items[j].ModelName })
+	}
+	sort.Slice(statement.Models, func(i, j int) bool { return statement.Models[i].ModelName < statement.Models[j].ModelName })
+	return statement, nil
+}
+
+// quotaToAmount 将额度换算为美元金额
+func quotaToAmount(quota int64) string {
+	return strconv.FormatFloat(float64(quota)/common.QuotaPerUnit, 'f', 6, 64)
+}
+
+// displayAmount 将额度换算为账单展示币种的金额
+func (statement *Statement) displayAmount(quota int64) string {
+	return strconv.FormatFloat(float64(quota)/common.QuotaPerUnit*statement.ExchangeRate, 'f', 6, 64)
+}
+
+// WriteStatementCSV 导出按天、按模型的账单明细，末尾附按模型汇总与合计
+func WriteStatementCSV(w io.Writer, statement *Statement) error {
+	writer := csv.NewWriter(w)
+	header := []string{"date", "model", "requests", "prompt_tokens", "completion_tokens", "quota", "amount_usd"}
+	// 展示币种不是美元时追加该币种金额列
+	withCurrency := statement.Currency != "" && statement.Currency != operation_setting.CurrencyUSD
+	if withCurrency {
+		header = append(header, "amount_"+strings.ToLower(statement.Currency))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	row := func(date string, item *model.StatementItem) []string {
+		record := []string{date, item.ModelName, strconv.FormatInt(item.Requests, 10), strconv.FormatInt(item.PromptTokens, 10),
+			strconv.FormatInt(item.CompletionTokens, 10), strconv.FormatInt(item.Quota, 10), quotaToAmount(item.Quota)}
+		if withCurrency {
+			record = append(record, statement.displayAmount(item.Quota))
+		}
+		return record
+	}
+	for _, day := range statement.Days {
+		for _, item := range day.Items {
+			if err := writer.Write(row(day.Date, item)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, item := range statement.Models {
+		if err := writer.Write(row(statement.Month, item)); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write(row(statement.Month, &statement.Total)); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteStatementPDF 以表格形式导出账单，模型名称按显示宽度对齐，中文等字符由 PDF 字体渲染
+func WriteStatementPDF(w io.Writer, statement *Statement) error {
+	format := "%-10s  %s  %9s  %12s  %12s  %14s"
+	line := func(date string, item *model.StatementItem) string {
+		modelName := common.PadDisplayWidth(common.TruncateDisplayWidth(item.ModelName, 32), 32)
+		return fmt.Sprintf(format, date, modelName, strconv.FormatInt(item.Requests, 10), strconv.FormatInt(item.PromptTokens, 10),
+			strconv.FormatInt(item.CompletionTokens, 10), statement.displayAmount(item.Quota))
+	}
+	header := fmt.Sprintf(format, "Date", common.PadDisplayWidth("Model", 32), "Requests", "Prompt", "Completion", "Amount ("+statement.Currency+")")
+	separator := strings.Repeat("-", len(header))
+
+	lines := []string{
+		"Statement " + statement.Month,
+		fmt.Sprintf("User: %s (#%d)", statement.Username, statement.UserId),
+		fmt.Sprintf("Period: %s - %s", time.Unix(statement.StartTime, 0).Format("2006-01-02"),
+			time.Unix(statement.EndTime, 0).AddDate(0, 0, -1).Format("2006-01-02")),
+		fmt.Sprintf("Generated at: %s", time.Now().Format("2006-01-02 15:04:05")),
+		"",
+		"Summary by model",
+		header,
+		separator,
+	}
+	for _, item := range statement.Models {
+		lines = append(lines, line(statement.Month, item))
+	}
+	lines = append(lines, separator, line("Total", &statement.Total), "", "Daily details", header, separator)
+	for _, day := range statement.Days {
+		for _, item := range day.Items {
+			lines = append(lines, line(day.Date, item))
+		}
+	}
+	return common.WriteTextPDF(w, lines)
+}
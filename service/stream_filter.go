@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/helper"
+	"one-api/setting"
+	"strings"
+)
+
+const sensitiveMask = "**###**"
+
+// streamTextProcessor 处理单个 choice 累积的文本，返回可立即输出的部分与需要暂存到下一分片的部分，
+// final 为 true 时需全部输出；stop 为 true 时以 content_filter 结束输出
+type streamTextProcessor func(text []rune, final bool) (emit []rune, keep []rune, stop bool)
+
+// streamChunkFilter 按 choice 暂存尚不能确定的尾部内容，与下一个分片拼接后再处理，
+// 避免需要匹配的内容被切分在两个分片之间
+type streamChunkFilter struct {
+	process streamTextProcessor
+	pending map[int][]rune
+	last    *dto.ChatCompletionsStreamResponse
+	stopped bool
+}
+
+func newStreamChunkFilter(process streamTextProcessor) *streamChunkFilter {
+	return &streamChunkFilter{process: process, pending: make(map[int][]rune)}
+}
+
+func (f *streamChunkFilter) Stopped() bool {
+	return f.stopped
+}
+
+func (f *streamChunkFilter) Filter(data string) []string {
+	if f.stopped {
+		return nil
+	}
+	if strings.HasPrefix(data, "[DONE]") {
+		return append(f.flush(), data)
+	}
+	var response dto.ChatCompletionsStreamResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil || len(response.Choices) == 0 {
+		return append(f.flush(), data)
+	}
+	f.last = &response
+	for i := range response.Choices {
+		choice := &response.Choices[i]
+		content := choice.Delta.GetContentString()
+		if content == "" && choice.FinishReason == nil {
+			continue
+		}
+		text := append(f.pending[choice.Index], []rune(content)...)
+		final := choice.FinishReason != nil
+		emit, keep, stop := f.process(text, final)
+		if stop {
+			f.stopped = true
+			return f.terminate(&response)
+		}
+		if len(keep) > 0 {
+			f.pending[choice.Index] = append([]rune(nil), keep...)
+		} else {
+			delete(f.pending, choice.Index)
+		}
+		if len(emit) > 0 || final {
+			choice.Delta.SetContentString(string(emit))
+		} else {
+			choice.Delta.Content = nil
+		}
+	}
+	jsonData, err := json.Marshal(response)
+	if err != nil {
+		return []string{data}
+	}
+	return []string{string(jsonData)}
+}
+
+// flush 输出所有 choice 暂存的内容，在流结束或遇到无法解析的数据时调用
+func (f *streamChunkFilter) flush() []string {
+	if len(f.pending) == 0 || f.last == nil {
+		return nil
+	}
+	var outputs []string
+	for index, text := range f.pending {
+		emit, _, stop := f.process(text, true)
+		if stop {
+			f.stopped = true
+			return f.terminate(f.last)
+		}
+		if len(emit) == 0 {
+			continue
+		}
+		chunk := newStreamChunk(f.last, index)
+		chunk.Choices[0].Delta.SetContentString(string(emit))
+		if jsonData, err := json.Marshal(chunk); err == nil {
+			outputs = append(outputs, string(jsonData))
+		}
+	}
+	f.pending = make(map[int][]rune)
+	return outputs
+}
+
+// terminate 丢弃暂存内容，以 content_filter 结束所有 choice 并发送 [DONE]
+func (f *streamChunkFilter) terminate(response *dto.ChatCompletionsStreamResponse) []string {
+	var outputs []string
+	for i := range response.Choices {
+		chunk := newStreamChunk(response, response.Choices[i].Index)
+		chunk.Choices[0].FinishReason = &constant.FinishReasonContentFilter
+		if jsonData, err := json.Marshal(chunk); err == nil {
+			outputs = append(outputs, string(jsonData))
+		}
+	}
+	f.pending = make(map[int][]rune)
+	return append(outputs, "[DONE]")
+}
+
+func newStreamChunk(template *dto.ChatCompletionsStreamResponse, index int) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:                template.Id,
+		Object:            template.Object,
+		Created:           template.Created,
+		Model:             template.Model,
+		SystemFingerprint: template.SystemFingerprint,
+		Choices:           []dto.ChatCompletionsStreamResponseChoice{{Index: index}},
+	}
+}
+
+// NewStreamSensitiveFilter 分组未启用任何规则时返回 nil；StopOnSensitiveEnabled 为 true 时命中即以 content_filter 结束，否则替换敏感词。
+// 每个 choice 保留末尾不足一个敏感词长度的内容，与下一个分片拼接后再检查；正则规则只在已拼接的内容内匹配
+func NewStreamSensitiveFilter(group string) helper.StreamOutputFilter {
+	engine := getSensitiveEngine(group)
+	if engine.empty() {
+		return nil
+	}
+	holdback := engine.maxWordLen - 1
+	stopOnHit := setting.StopOnSensitiveEnabled
+	return newStreamChunkFilter(func(text []rune, final bool) ([]rune, []rune, bool) {
+		if hits := engine.Search(text, stopOnHit); len(hits) > 0 {
+			if stopOnHit {
+				return nil, nil, true
+			}
+			text = maskSensitiveHits(text, hits)
+		}
+		if final || holdback <= 0 {
+			return text, nil, false
+		}
+		if len(text) <= holdback {
+			return nil, text, false
+		}
+		cut := len(text) - holdback
+		return text[:cut], text[cut:], false
+	})
+}
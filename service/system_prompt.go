@@ -0,0 +1,105 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+)
+
+// EnforceGroupSystemPrompt 按分组配置强制设置系统提示词，需在敏感词检查与 token 计数之前调用，
+// 使强制提示词同样参与检查与计费；返回请求是否被修改，被修改的请求不能再透传原始请求体
+func EnforceGroupSystemPrompt(group string, request *dto.GeneralOpenAIRequest) bool {
+	groupPrompt, ok := operation_setting.GetGroupSystemPrompt(group)
+	if !ok {
+		return false
+	}
+	if groupPrompt.StripClientSystem {
+		messages := make([]dto.Message, 0, len(request.Messages))
+		for _, message := range request.Messages {
+			if message.Role == "system" || message.Role == "developer" {
+				continue
+			}
+			messages = append(messages, message)
+		}
+		request.Messages = messages
+	}
+	if groupPrompt.Prompt == "" {
+		return groupPrompt.StripClientSystem
+	}
+	if len(request.Messages) > 0 && request.Messages[0].Role == "system" {
+		content := groupPrompt.Prompt
+		if groupPrompt.Mode != operation_setting.SystemPromptModeReplace {
+			content = joinNonEmpty([]string{groupPrompt.Prompt, request.Messages[0].StringContent()}, "\n")
+		}
+		request.Messages[0].SetStringContent(content)
+		return true
+	}
+	systemMessage := dto.Message{Role: "system"}
+	systemMessage.SetStringContent(groupPrompt.Prompt)
+	request.Messages = append([]dto.Message{systemMessage}, request.Messages...)
+	return true
+}
+
+// EnforceGroupSystemPromptClaude 对 Claude Messages 请求强制设置系统提示词，Claude 的系统提示词位于 system 字段
+func EnforceGroupSystemPromptClaude(group string, request *dto.ClaudeRequest) bool {
+	groupPrompt, ok := operation_setting.GetGroupSystemPrompt(group)
+	if !ok {
+		return false
+	}
+	if groupPrompt.StripClientSystem {
+		request.System = nil
+	}
+	if groupPrompt.Prompt == "" {
+		return groupPrompt.StripClientSystem
+	}
+	if groupPrompt.Mode == operation_setting.SystemPromptModeReplace || request.System == nil {
+		request.SetStringSystem(groupPrompt.Prompt)
+		return true
+	}
+	if request.IsStringSystem() {
+		request.SetStringSystem(joinNonEmpty([]string{groupPrompt.Prompt, request.GetStringSystem()}, "\n"))
+		return true
+	}
+	prompt := groupPrompt.Prompt
+	system := []dto.ClaudeMediaMessage{{Type: "text", Text: &prompt}}
+	request.System = append(system, request.ParseSystem()...)
+	return true
+}
+
+// EnforceGroupSystemPromptResponses 对 Responses 请求强制设置系统提示词，强制提示词写入 instructions，
+// 移除客户端系统提示词时同时清空 instructions 与 input 中的 system/developer 消息
+func EnforceGroupSystemPromptResponses(group string, request *dto.OpenAIResponsesRequest) bool {
+	groupPrompt, ok := operation_setting.GetGroupSystemPrompt(group)
+	if !ok {
+		return false
+	}
+	if groupPrompt.StripClientSystem {
+		request.Instructions = nil
+		var items []json.RawMessage
+		if err := json.Unmarshal(request.Input, &items); err == nil {
+			kept := make([]json.RawMessage, 0, len(items))
+			for _, item := range items {
+				var message struct {
+					Role string `json:"role"`
+				}
+				_ = json.Unmarshal(item, &message)
+				if message.Role == "system" || message.Role == "developer" {
+					continue
+				}
+				kept = append(kept, item)
+			}
+			request.Input, _ = json.Marshal(kept)
+		}
+	}
+	if groupPrompt.Prompt == "" {
+		return groupPrompt.StripClientSystem
+	}
+	content := groupPrompt.Prompt
+	if groupPrompt.Mode != operation_setting.SystemPromptModeReplace {
+		var instructions string
+		_ = json.Unmarshal(request.Instructions, &instructions)
+		content = joinNonEmpty([]string{groupPrompt.Prompt, instructions}, "\n")
+	}
+	request.Instructions, _ = json.Marshal(content)
+	return true
+}
@@ -35,25 +35,13 @@ func InitTokenEncoders() {
 		common.FatalLog(fmt.Sprintf("failed to get gpt-4o token encoder: %s", err.Error()))
 	}
 	for model, _ := range operation_setting.GetDefaultModelRatioMap() {
-		if strings.HasPrefix(model, "gpt-3.5") {
-			tokenEncoderMap[model] = cl100TokenEncoder
-		} else if strings.HasPrefix(model, "gpt-4") {
-			if strings.HasPrefix(model, "gpt-4o") {
-				tokenEncoderMap[model] = o200kTokenEncoder
-			} else {
-				tokenEncoderMap[model] = defaultTokenEncoder
-			}
-		} else if strings.HasPrefix(model, "o") {
-			tokenEncoderMap[model] = o200kTokenEncoder
-		} else {
-			tokenEncoderMap[model] = defaultTokenEncoder
-		}
+		tokenEncoderMap[model] = getModelDefaultTokenEncoder(model)
 	}
 	common.SysLog("token encoders initialized")
 }
 
 func getModelDefaultTokenEncoder(model string) *tiktoken.Tiktoken {
-	if strings.HasPrefix(model, "gpt-4o") || strings.HasPrefix(model, "chatgpt-4o") || strings.HasPrefix(model, "o1") {
+	if GetTokenizerFamily(model) == TokenizerFamilyO200k {
 		return o200kTokenEncoder
 	}
 	return defaultTokenEncoder
@@ -85,107 +73,192 @@ func getTokenNum(tokenEncoder *tiktoken.Tiktoken, text string) int {
 	return len(tokenEncoder.Encode(text, nil, nil))
 }
 
+const (
+	// Claude 会将长边缩放到 1568 以内，单张图片约 (宽 * 高) / 750 个 token，上限约 1600
+	claudeImageMaxEdge   = 1568
+	claudeImageMaxTokens = 1600
+	// Gemini 每张图片按固定 token 计算
+	geminiImageTokens = 258
+)
+
+// imageTokenFamily 模型名优先，其次按渠道类型判断图片计费规则
+func imageTokenFamily(info *relaycommon.RelayInfo, model string) string {
+	family := GetTokenizerFamily(model)
+	if family == TokenizerFamilyClaude || family == TokenizerFamilyGemini {
+		return family
+	}
+	switch info.ChannelType {
+	case common.ChannelTypeAnthropic:
+		return TokenizerFamilyClaude
+	case common.ChannelTypeGemini, common.ChannelTypeVertexAi:
+		return TokenizerFamilyGemini
+	}
+	return family
+}
+
+// shouldDecodeImage 是否下载/解码图片以获取尺寸
+func shouldDecodeImage(stream bool) bool {
+	return constant.GetMediaToken && (constant.GetMediaTokenNotStream || stream)
+}
+
+func decodeImageConfig(imageUrl *dto.MessageImageUrl) (image.Config, error) {
+	var config image.Config
+	var err error
+	var format string
+	if strings.HasPrefix(imageUrl.Url, "http") {
+		config, format, err = DecodeUrlImageData(imageUrl.Url)
+	} else {
+		common.SysLog(fmt.Sprintf("decoding image"))
+		config, format, _, err = DecodeBase64ImageData(imageUrl.Url)
+	}
+	if err != nil {
+		return config, err
+	}
+	imageUrl.MimeType = format
+	if config.Width == 0 || config.Height == 0 {
+		return config, errors.New(fmt.Sprintf("fail to decode image config: %s", imageUrl.Url))
+	}
+	log.Printf("format: %s, width: %d, height: %d", format, config.Width, config.Height)
+	return config, nil
+}
+
+func getClaudeImageToken(imageUrl *dto.MessageImageUrl, decode bool) (int, error) {
+	if !decode {
+		return claudeImageMaxTokens, nil
+	}
+	config, err := decodeImageConfig(imageUrl)
+	if err != nil {
+		return 0, err
+	}
+	width := float64(config.Width)
+	height := float64(config.Height)
+	if longEdge := math.Max(width, height); longEdge > claudeImageMaxEdge {
+		scale := claudeImageMaxEdge / longEdge
+		width *= scale
+		height *= scale
+	}
+	tokens := int(math.Ceil(width * height / 750))
+	return min(tokens, claudeImageMaxTokens), nil
+}
+
 func getImageToken(info *relaycommon.RelayInfo, imageUrl *dto.MessageImageUrl, model string, stream bool) (int, error) {
 	if imageUrl == nil {
 		return 0, fmt.Errorf("image_url_is_nil")
 	}
-	baseTokens := 85
 	if model == "glm-4v" {
 		return 1047, nil
 	}
-	if imageUrl.Detail == "low" {
-		return baseTokens, nil
-	}
-	if !constant.GetMediaTokenNotStream && !stream {
-		return 3 * baseTokens, nil
-	}
-
-	// 同步One API的图片计费逻辑
-	if imageUrl.Detail == "auto" || imageUrl.Detail == "" {
-		imageUrl.Detail = "high"
+	// SkipRemoteMedia 时远程图片不下载，按未解码的默认值估算
+	decode := shouldDecodeImage(stream) && !(info.SkipRemoteMedia && strings.HasPrefix(imageUrl.Url, "http"))
+	switch imageTokenFamily(info, model) {
+	case TokenizerFamilyGemini:
+		return geminiImageTokens, nil
+	case TokenizerFamilyClaude:
+		return getClaudeImageToken(imageUrl, decode)
 	}
 
+	baseTokens := 85
 	tileTokens := 170
 	if strings.HasPrefix(model, "gpt-4o-mini") {
 		tileTokens = 5667
 		baseTokens = 2833
 	}
-	// 是否统计图片token
-	if !constant.GetMediaToken {
-		return 3 * baseTokens, nil
+	if imageUrl.Detail == "low" {
+		return baseTokens, nil
 	}
-	if info.ChannelType == common.ChannelTypeGemini || info.ChannelType == common.ChannelTypeVertexAi || info.ChannelType == common.ChannelTypeAnthropic {
-		return 3 * baseTokens, nil
+	// 同步One API的图片计费逻辑
+	if imageUrl.Detail == "auto" || imageUrl.Detail == "" {
+		imageUrl.Detail = "high"
 	}
-	var config image.Config
-	var err error
-	var format string
-	if strings.HasPrefix(imageUrl.Url, "http") {
-		config, format, err = DecodeUrlImageData(imageUrl.Url)
-	} else {
-		common.SysLog(fmt.Sprintf("decoding image"))
-		config, format, _, err = DecodeBase64ImageData(imageUrl.Url)
+	// 是否统计图片token
+	if !decode {
+		return 3 * baseTokens, nil
 	}
+	config, err := decodeImageConfig(imageUrl)
 	if err != nil {
 		return 0, err
 	}
-	imageUrl.MimeType = format
 
-	if config.Width == 0 || config.Height == 0 {
-		return 0, errors.New(fmt.Sprintf("fail to decode image config: %s", imageUrl.Url))
+	// 先等比缩放到 2048x2048 以内
+	width := float64(config.Width)
+	height := float64(config.Height)
+	if longEdge := math.Max(width, height); longEdge > 2048 {
+		width = width * 2048 / longEdge
+		height = height * 2048 / longEdge
 	}
-
-	shortSide := config.Width
-	otherSide := config.Height
-	log.Printf("format: %s, width: %d, height: %d", format, config.Width, config.Height)
-	// 缩放倍数
-	scale := 1.0
-	if config.Height < shortSide {
-		shortSide = config.Height
-		otherSide = config.Width
+	// 再将短边缩放到 768
+	if shortEdge := math.Min(width, height); shortEdge > 768 {
+		width = width * 768 / shortEdge
+		height = height * 768 / shortEdge
 	}
-
-	// 将最小变的尺寸缩小到768以下，如果大于768，则缩放到768
-	if shortSide > 768 {
-		scale = float64(shortSide) / 768
-		shortSide = 768
-	}
-	// 将另一边按照相同的比例缩小，向上取整
-	otherSide = int(math.Ceil(float64(otherSide) / scale))
-	log.Printf("shortSide: %d, otherSide: %d, scale: %f", shortSide, otherSide, scale)
 	// 计算图片的token数量(边的长度除以512，向上取整)
-	tiles := (shortSide + 511) / 512 * ((otherSide + 511) / 512)
+	tiles := int(math.Ceil(width/512)) * int(math.Ceil(height/512))
 	log.Printf("tiles: %d", tiles)
 	return tiles*tileTokens + baseTokens, nil
 }
 
+// CountTokenChatRequest 按模型所属的分词器族计数，Claude / Gemini 渠道开启后优先使用官方 count_tokens 接口
 func CountTokenChatRequest(info *relaycommon.RelayInfo, request dto.GeneralOpenAIRequest) (int, error) {
 	tkm := 0
-	msgTokens, err := CountTokenMessages(info, request.Messages, request.Model, request.Stream)
+	msgTokens, ok := countMessageTokensByProvider(info, &request)
+	if !ok {
+		var err error
+		msgTokens, err = CountTokenMessages(info, request.Messages, request.Model, request.Stream)
+		if err != nil {
+			return 0, err
+		}
+	}
+	tkm += msgTokens
+	schemaTokens, err := countRequestSchemaTokens(request)
 	if err != nil {
 		return 0, err
 	}
-	tkm += msgTokens
-	if request.Tools != nil {
-		openaiTools := request.Tools
-		countStr := ""
-		for _, tool := range openaiTools {
-			countStr = tool.Function.Name
-			if tool.Function.Description != "" {
-				countStr += tool.Function.Description
-			}
-			if tool.Function.Parameters != nil {
-				countStr += fmt.Sprintf("%v", tool.Function.Parameters)
-			}
-		}
-		toolTokens, err := CountTokenInput(countStr, request.Model)
+	tkm += schemaTokens
+
+	return tkm, nil
+}
+
+// countRequestSchemaTokens 统计 tools、functions、tool_choice 与 response_format 中的 JSON Schema，
+// 大型函数定义同样会作为输入发送给模型
+func countRequestSchemaTokens(request dto.GeneralOpenAIRequest) (int, error) {
+	var parts []any
+	for _, tool := range request.Tools {
+		parts = append(parts, map[string]any{
+			"name":        tool.Function.Name,
+			"description": tool.Function.Description,
+			"parameters":  tool.Function.Parameters,
+		})
+	}
+	if request.Functions != nil {
+		parts = append(parts, request.Functions)
+	}
+	// 字符串形式的 tool_choice（auto/none/required）可忽略，指定具体函数时计入
+	if _, isString := request.ToolChoice.(string); request.ToolChoice != nil && !isString {
+		parts = append(parts, request.ToolChoice)
+	}
+	if request.ResponseFormat != nil && request.ResponseFormat.JsonSchema != nil {
+		parts = append(parts, request.ResponseFormat.JsonSchema)
+	}
+	if len(parts) == 0 {
+		return 0, nil
+	}
+	var countStr strings.Builder
+	for _, part := range parts {
+		data, err := json.Marshal(part)
 		if err != nil {
 			return 0, err
 		}
+		countStr.Write(data)
+		countStr.WriteByte('\n')
+	}
+	tokens, err := CountTokenInput(countStr.String(), request.Model)
+	if err != nil {
+		return 0, err
+	}
+	tkm := scaleTokensForFamily(request.Model, tokens)
+	if len(request.Tools) > 0 {
 		tkm += 8
-		tkm += toolTokens
 	}
-
 	return tkm, nil
 }
 
@@ -307,11 +380,12 @@ func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent,
 		}
 	case dto.RealtimeEventResponseAudioDelta:
 		// count audio token
-		atk, err := CountAudioTokenOutput(request.Delta, info.OutputAudioFormat)
+		duration, err := getAudioDuration(request.Delta, info.OutputAudioFormat)
 		if err != nil {
 			return 0, 0, fmt.Errorf("error counting audio token: %v", err)
 		}
-		audioToken += atk
+		info.AddAudioOutputSeconds(duration)
+		audioToken += audioOutputDurationToken(duration)
 	case dto.RealtimeEventResponseAudioTranscriptionDelta, dto.RealtimeEventResponseFunctionCallArgumentsDelta:
 		// count text token
 		tkm, err := CountTextToken(request.Delta, model)
@@ -321,11 +395,12 @@ func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent,
 		textToken += tkm
 	case dto.RealtimeEventInputAudioBufferAppend:
 		// count audio token
-		atk, err := CountAudioTokenInput(request.Audio, info.InputAudioFormat)
+		duration, err := getAudioDuration(request.Audio, info.InputAudioFormat)
 		if err != nil {
 			return 0, 0, fmt.Errorf("error counting audio token: %v", err)
 		}
-		audioToken += atk
+		info.AddAudioInputSeconds(duration)
+		audioToken += audioInputDurationToken(duration)
 	case dto.RealtimeEventConversationItemCreated:
 		if request.Item != nil {
 			switch request.Item.Type {
@@ -377,6 +452,7 @@ func CountTokenMessages(info *relaycommon.RelayInfo, messages []dto.Message, mod
 		tokensPerName = 1
 	}
 	tokenNum := 0
+	mediaTokenNum := 0 // 图片等多模态 token 单独累计，不参与分词器系数换算
 	for _, message := range messages {
 		tokenNum += tokensPerMessage
 		tokenNum += getTokenNum(tokenEncoder, message.Role)
@@ -393,15 +469,22 @@ func CountTokenMessages(info *relaycommon.RelayInfo, messages []dto.Message, mod
 					if err != nil {
 						return 0, err
 					}
-					tokenNum += imageTokenNum
+					mediaTokenNum += imageTokenNum
 					log.Printf("image token num: %d", imageTokenNum)
 				} else if m.Type == dto.ContentTypeInputAudio {
-					// TODO: 音频token数量计算
-					tokenNum += 100
+					// 记录音频时长，用于估算音频 token，以及上游未返回音频 token 明细时按分钟计费
+					var duration float64
+					if inputAudio := m.GetInputAudio(); inputAudio != nil && inputAudio.Format == "wav" {
+						if d, err := getAudioDuration(inputAudio.Data, inputAudio.Format); err == nil {
+							duration = d
+							info.AddAudioInputSeconds(duration)
+						}
+					}
+					mediaTokenNum += getInputAudioToken(model, duration)
 				} else if m.Type == dto.ContentTypeFile {
-					tokenNum += 5000
+					mediaTokenNum += getFileToken(model, m.GetFile())
 				} else if m.Type == dto.ContentTypeVideoUrl {
-					tokenNum += 5000
+					mediaTokenNum += 5000
 				} else {
 					tokenNum += getTokenNum(tokenEncoder, m.Text)
 				}
@@ -409,7 +492,7 @@ func CountTokenMessages(info *relaycommon.RelayInfo, messages []dto.Message, mod
 		}
 	}
 	tokenNum += 3 // Every reply is primed with <|start|>assistant<|message|>
-	return tokenNum, nil
+	return scaleTokensForFamily(model, tokenNum) + mediaTokenNum, nil
 }
 
 func CountTokenInput(input any, model string) (int, error) {
@@ -457,26 +540,47 @@ func CountTTSToken(text string, model string) (int, error) {
 	}
 }
 
-func CountAudioTokenInput(audioBase64 string, audioFormat string) (int, error) {
+// getInputAudioToken 按时长估算输入音频 token：Gemini 为每秒 32 个，OpenAI 约每 100ms 一个；无法解析时长时按 100 估算
+func getInputAudioToken(model string, duration float64) int {
+	if duration <= 0 {
+		return 100
+	}
+	tokensPerSecond := 10.0
+	if GetTokenizerFamily(model) == TokenizerFamilyGemini {
+		tokensPerSecond = 32
+	}
+	return int(math.Ceil(duration * tokensPerSecond))
+}
+
+func getAudioDuration(audioBase64 string, audioFormat string) (float64, error) {
 	if audioBase64 == "" {
 		return 0, nil
 	}
-	duration, err := parseAudio(audioBase64, audioFormat)
+	return parseAudio(audioBase64, audioFormat)
+}
+
+func audioInputDurationToken(duration float64) int {
+	return int(duration / 60 * 100 / 0.06)
+}
+
+func audioOutputDurationToken(duration float64) int {
+	return int(duration / 60 * 200 / 0.24)
+}
+
+func CountAudioTokenInput(audioBase64 string, audioFormat string) (int, error) {
+	duration, err := getAudioDuration(audioBase64, audioFormat)
 	if err != nil {
 		return 0, err
 	}
-	return int(duration / 60 * 100 / 0.06), nil
+	return audioInputDurationToken(duration), nil
 }
 
 func CountAudioTokenOutput(audioBase64 string, audioFormat string) (int, error) {
-	if audioBase64 == "" {
-		return 0, nil
-	}
-	duration, err := parseAudio(audioBase64, audioFormat)
+	duration, err := getAudioDuration(audioBase64, audioFormat)
 	if err != nil {
 		return 0, err
 	}
-	return int(duration / 60 * 200 / 0.24), nil
+	return audioOutputDurationToken(duration), nil
 }
 
 //func CountAudioToken(sec float64, audioType string) {
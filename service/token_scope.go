@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"one-api/constant"
+	relayconstant "one-api/relay/constant"
+	"strings"
+)
+
+// tokenScopeEndpoints relay mode 所属的接口类型
+var tokenScopeEndpoints = map[int]string{
+	relayconstant.RelayModeChatCompletions:    "chat",
+	relayconstant.RelayModeCompletions:        "chat",
+	relayconstant.RelayModeEdits:              "chat",
+	relayconstant.RelayModeResponses:          "chat",
+	relayconstant.RelayModeRealtime:           "chat",
+	relayconstant.RelayModeEmbeddings:         "embeddings",
+	relayconstant.RelayModeImagesGenerations:  "images",
+	relayconstant.RelayModeImagesEdits:        "images",
+	relayconstant.RelayModeAudioSpeech:        "audio",
+	relayconstant.RelayModeAudioTranscription: "audio",
+	relayconstant.RelayModeAudioTranslation:   "audio",
+	relayconstant.RelayModeRerank:             "rerank",
+	relayconstant.RelayModeModerations:        "moderations",
+}
+
+// tokenScopeEndpoint 返回请求路径对应的接口类型与 relay mode 名称
+func tokenScopeEndpoint(path string) (string, string) {
+	switch {
+	case strings.Contains(path, "/mj/"):
+		return "midjourney", "midjourney"
+	case strings.HasPrefix(path, "/suno/"):
+		return "suno", "suno"
+	case strings.HasPrefix(path, "/v1/messages"):
+		return "chat", "messages"
+	}
+	relayMode := relayconstant.Path2RelayMode(path)
+	if endpoint, ok := tokenScopeEndpoints[relayMode]; ok {
+		return endpoint, relayconstant.RelayModeName(relayMode)
+	}
+	return "unknown", relayconstant.RelayModeName(relayMode)
+}
+
+func getScopeList(setting map[string]interface{}, key string) ([]string, bool) {
+	value, ok := setting[key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, 0, len(value))
+	for _, item := range value {
+		if s, ok := item.(string); ok && s != "" {
+			list = append(list, s)
+		}
+	}
+	return list, true
+}
+
+// CheckTokenScope 检查令牌是否允许以该方法调用该接口，未配置的维度不做限制；模型限制使用令牌的 ModelLimits（支持前缀匹配）
+func CheckTokenScope(tokenSetting map[string]interface{}, method string, path string) error {
+	if methods, ok := getScopeList(tokenSetting, constant.TokenSettingAllowedMethods); ok {
+		allowed := false
+		for _, m := range methods {
+			if strings.EqualFold(m, method) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("该令牌不允许使用 %s 方法", method)
+		}
+	}
+	if endpoints, ok := getScopeList(tokenSetting, constant.TokenSettingAllowedEndpoints); ok {
+		endpoint, modeName := tokenScopeEndpoint(path)
+		allowed := false
+		for _, e := range endpoints {
+			if e == endpoint || e == modeName {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("该令牌无权访问 %s 接口，允许的接口：%s", endpoint, strings.Join(endpoints, ", "))
+		}
+	}
+	return nil
+}
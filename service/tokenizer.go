@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+)
+
+const (
+	TokenizerFamilyCl100k = "cl100k_base"
+	TokenizerFamilyO200k  = "o200k_base"
+	TokenizerFamilyClaude = "claude"
+	TokenizerFamilyGemini = "gemini"
+)
+
+var o200kModelPrefixes = []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"}
+
+// GetTokenizerFamily 按模型名选择分词器，Claude 与 Gemini 没有公开的本地分词器，使用 cl100k_base 乘以系数近似
+func GetTokenizerFamily(model string) string {
+	for _, prefix := range o200kModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return TokenizerFamilyO200k
+		}
+	}
+	switch {
+	case strings.HasPrefix(model, "claude"):
+		return TokenizerFamilyClaude
+	case strings.HasPrefix(model, "gemini"), strings.HasPrefix(model, "gemma"):
+		return TokenizerFamilyGemini
+	}
+	return TokenizerFamilyCl100k
+}
+
+// scaleTokensForFamily 对近似计数的模型族乘以配置的系数
+func scaleTokensForFamily(model string, tokens int) int {
+	setting := operation_setting.GetTokenizerSetting()
+	ratio := 1.0
+	switch GetTokenizerFamily(model) {
+	case TokenizerFamilyClaude:
+		ratio = setting.ClaudeRatio
+	case TokenizerFamilyGemini:
+		ratio = setting.GeminiRatio
+	}
+	if ratio <= 0 || ratio == 1.0 {
+		return tokens
+	}
+	return int(math.Ceil(float64(tokens) * ratio))
+}
+
+type countTokensMessage struct {
+	role string
+	text string
+}
+
+// plainTextMessages 仅在请求全部为文本内容时返回，包含图片等多模态内容时使用本地估算
+func plainTextMessages(messages []dto.Message) ([]countTokensMessage, bool) {
+	result := make([]countTokensMessage, 0, len(messages))
+	for _, message := range messages {
+		var text strings.Builder
+		for _, content := range message.ParseContent() {
+			if content.Type != dto.ContentTypeText {
+				return nil, false
+			}
+			text.WriteString(content.Text)
+		}
+		result = append(result, countTokensMessage{role: message.Role, text: text.String()})
+	}
+	return result, true
+}
+
+func postCountTokens(info *relaycommon.RelayInfo, requestURL string, header http.Header, payload any, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(operation_setting.GetTokenizerSetting().CountTokensAPITimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	client, err := GetChannelHttpClient(ChannelHttpClientOptions(info))
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("count tokens request failed with status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func countClaudeTokensByAPI(info *relaycommon.RelayInfo, model string, messages []countTokensMessage) (int, error) {
+	var system strings.Builder
+	claudeMessages := make([]map[string]string, 0, len(messages))
+	for _, message := range messages {
+		switch message.role {
+		case "system", "developer":
+			system.WriteString(message.text)
+		case "assistant":
+			claudeMessages = append(claudeMessages, map[string]string{"role": "assistant", "content": message.text})
+		default:
+			claudeMessages = append(claudeMessages, map[string]string{"role": "user", "content": message.text})
+		}
+	}
+	payload := map[string]any{"model": model, "messages": claudeMessages}
+	if system.Len() > 0 {
+		payload["system"] = system.String()
+	}
+	header := http.Header{}
+	header.Set("x-api-key", info.ApiKey)
+	header.Set("anthropic-version", "2023-06-01")
+	var result struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	err := postCountTokens(info, strings.TrimSuffix(info.BaseUrl, "/")+"/v1/messages/count_tokens", header, payload, &result)
+	return result.InputTokens, err
+}
+
+func countGeminiTokensByAPI(info *relaycommon.RelayInfo, model string, messages []countTokensMessage) (int, error) {
+	contents := make([]map[string]any, 0, len(messages))
+	for _, message := range messages {
+		role := "user"
+		if message.role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, map[string]any{
+			"role":  role,
+			"parts": []map[string]string{{"text": message.text}},
+		})
+	}
+	header := http.Header{}
+	header.Set("x-goog-api-key", info.ApiKey)
+	var result struct {
+		TotalTokens int `json:"totalTokens"`
+	}
+	requestURL := fmt.Sprintf("%s/v1beta/models/%s:countTokens", strings.TrimSuffix(info.BaseUrl, "/"), url.PathEscape(model))
+	err := postCountTokens(info, requestURL, header, map[string]any{"contents": contents}, &result)
+	return result.TotalTokens, err
+}
+
+// countMessageTokensByProvider 调用渠道的官方接口计算消息 token，不满足条件或调用失败时返回 false
+func countMessageTokensByProvider(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (int, bool) {
+	if !operation_setting.GetTokenizerSetting().CountTokensAPIEnabled || info == nil || info.ApiKey == "" {
+		return 0, false
+	}
+	family := GetTokenizerFamily(request.Model)
+	var count func(*relaycommon.RelayInfo, string, []countTokensMessage) (int, error)
+	switch {
+	case family == TokenizerFamilyClaude && info.ChannelType == common.ChannelTypeAnthropic:
+		count = countClaudeTokensByAPI
+	case family == TokenizerFamilyGemini && info.ChannelType == common.ChannelTypeGemini:
+		count = countGeminiTokensByAPI
+	default:
+		return 0, false
+	}
+	messages, ok := plainTextMessages(request.Messages)
+	if !ok || len(messages) == 0 {
+		return 0, false
+	}
+	tokens, err := count(info, request.Model, messages)
+	if err != nil || tokens <= 0 {
+		if err != nil {
+			common.SysError(fmt.Sprintf("count tokens by provider api failed, fallback to local estimate: %s", err.Error()))
+		}
+		return 0, false
+	}
+	return tokens, true
+}
+
+// TokenizeText 返回文本的 token 数量，Claude / Gemini 按系数近似；withIds 为 true 时同时返回 token id，
+// 仅 OpenAI 系分词器的 id 有意义，近似计数的模型族不返回 id
+func TokenizeText(text string, model string, withIds bool) (int, []int) {
+	if text == "" {
+		return 0, nil
+	}
+	tokens := getTokenEncoder(model).Encode(text, nil, nil)
+	family := GetTokenizerFamily(model)
+	if family == TokenizerFamilyClaude || family == TokenizerFamilyGemini {
+		return scaleTokensForFamily(model, len(tokens)), nil
+	}
+	if !withIds {
+		return len(tokens), nil
+	}
+	return len(tokens), tokens
+}
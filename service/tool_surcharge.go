@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// ToolSurchargeItem 单个内置工具的按次附加计费明细
+type ToolSurchargeItem struct {
+	ToolName  string  `json:"tool_name"`
+	CallCount int     `json:"call_count"`
+	Price     float64 `json:"price"`
+	Quota     int     `json:"quota"`
+}
+
+// CalculateToolSurcharge 按工具附加价格表计算内置工具调用的配额 (配额 = 每千次价格 * 调用次数 / 1000 * 分组倍率)
+func CalculateToolSurcharge(relayInfo *relaycommon.RelayInfo, modelName string, groupRatio float64) (decimal.Decimal, []ToolSurchargeItem) {
+	total := decimal.Zero
+	if relayInfo.ResponsesUsageInfo == nil {
+		return total, nil
+	}
+	toolNames := make([]string, 0, len(relayInfo.ResponsesUsageInfo.BuiltInTools))
+	for toolName := range relayInfo.ResponsesUsageInfo.BuiltInTools {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	var items []ToolSurchargeItem
+	for _, toolName := range toolNames {
+		tool := relayInfo.ResponsesUsageInfo.BuiltInTools[toolName]
+		if tool.CallCount <= 0 {
+			continue
+		}
+		price := operation_setting.GetToolPricePerThousand(toolName, modelName, tool.SearchContextSize)
+		if price <= 0 {
+			continue
+		}
+		quota := decimal.NewFromFloat(price).
+			Mul(decimal.NewFromInt(int64(tool.CallCount))).
+			Div(decimal.NewFromInt(1000)).Mul(decimal.NewFromFloat(groupRatio)).
+			Mul(decimal.NewFromFloat(common.QuotaPerUnit))
+		total = total.Add(quota)
+		items = append(items, ToolSurchargeItem{
+			ToolName:  toolName,
+			CallCount: tool.CallCount,
+			Price:     price,
+			Quota:     int(quota.Round(0).IntPart()),
+		})
+	}
+	return total, items
+}
+
+// ToolSurchargeContent 生成内置工具计费的日志描述
+func ToolSurchargeContent(relayInfo *relaycommon.RelayInfo, items []ToolSurchargeItem) string {
+	var parts []string
+	for _, item := range items {
+		content := fmt.Sprintf("%s 调用 %d 次", item.ToolName, item.CallCount)
+		if tool, ok := relayInfo.ResponsesUsageInfo.BuiltInTools[item.ToolName]; ok && tool.SearchContextSize != "" {
+			content += fmt.Sprintf("，上下文大小 %s", tool.SearchContextSize)
+		}
+		content += fmt.Sprintf("，调用花费 $%.6f", float64(item.Quota)/common.QuotaPerUnit)
+		parts = append(parts, content)
+	}
+	return strings.Join(parts, "；")
+}
+
+// SetToolSurchargeOtherInfo 将内置工具计费明细写入日志 other 字段，保留 web_search/file_search 的原有字段；
+// 各渠道的联网搜索（OpenAI web search、Gemini grounding、Anthropic web_search、xAI Live Search）统一记录为 web_search 字段
+func SetToolSurchargeOtherInfo(other map[string]interface{}, items []ToolSurchargeItem) {
+	if len(items) == 0 {
+		return
+	}
+	for _, item := range items {
+		switch {
+		case dto.BuildInWebSearchTools[item.ToolName]:
+			other["web_search"] = true
+			other["web_search_tool"] = item.ToolName
+			other["web_search_call_count"] = item.CallCount
+			other["web_search_price"] = item.Price
+		case item.ToolName == dto.BuildInToolFileSearch:
+			other["file_search"] = true
+			other["file_search_call_count"] = item.CallCount
+			other["file_search_price"] = item.Price
+		}
+	}
+	other["tool_surcharges"] = items
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/parquet-go/parquet-go"
+)
+
+// usageLogRow 导出的消费日志行，CSV 列顺序与字段顺序一致
+type usageLogRow struct {
+	Id               int64  `parquet:"id"`
+	CreatedAt        int64  `parquet:"created_at"`
+	UserId           int64  `parquet:"user_id"`
+	Username         string `parquet:"username"`
+	TokenId          int64  `parquet:"token_id"`
+	TokenName        string `parquet:"token_name"`
+	ChannelId        int64  `parquet:"channel_id"`
+	ModelName        string `parquet:"model_name"`
+	Group            string `parquet:"group"`
+	PromptTokens     int64  `parquet:"prompt_tokens"`
+	CompletionTokens int64  `parquet:"completion_tokens"`
+	Quota            int64  `parquet:"quota"`
+	UseTime          int64  `parquet:"use_time"`
+	IsStream         bool   `parquet:"is_stream"`
+	Other            string `parquet:"other"`
+}
+
+var usageLogHeader = []string{"id", "created_at", "user_id", "username", "token_id", "token_name", "channel_id", "model_name",
+	"group", "prompt_tokens", "completion_tokens", "quota", "use_time", "is_stream", "other"}
+
+func (r *usageLogRow) csvRecord() []string {
+	return []string{strconv.FormatInt(r.Id, 10), strconv.FormatInt(r.CreatedAt, 10), strconv.FormatInt(r.UserId, 10), r.Username,
+		strconv.FormatInt(r.TokenId, 10), r.TokenName, strconv.FormatInt(r.ChannelId, 10), r.ModelName, r.Group,
+		strconv.FormatInt(r.PromptTokens, 10), strconv.FormatInt(r.CompletionTokens, 10), strconv.FormatInt(r.Quota, 10),
+		strconv.FormatInt(r.UseTime, 10), strconv.FormatBool(r.IsStream), r.Other}
+}
+
+func newUsageLogRow(log *model.Log) usageLogRow {
+	return usageLogRow{
+		Id:               int64(log.Id),
+		CreatedAt:        log.CreatedAt,
+		UserId:           int64(log.UserId),
+		Username:         log.Username,
+		TokenId:          int64(log.TokenId),
+		TokenName:        log.TokenName,
+		ChannelId:        int64(log.ChannelId),
+		ModelName:        log.ModelName,
+		Group:            log.Group,
+		PromptTokens:     int64(log.PromptTokens),
+		CompletionTokens: int64(log.CompletionTokens),
+		Quota:            int64(log.Quota),
+		UseTime:          int64(log.UseTime),
+		IsStream:         log.IsStream,
+		Other:            log.Other,
+	}
+}
+
+// costStatRow 导出的小时级费用统计
+type costStatRow struct {
+	CreatedAt        int64  `parquet:"created_at"`
+	UserId           int64  `parquet:"user_id"`
+	Username         string `parquet:"username"`
+	TokenId          int64  `parquet:"token_id"`
+	TokenName        string `parquet:"token_name"`
+	ChannelId        int64  `parquet:"channel_id"`
+	ModelName        string `parquet:"model_name"`
+	Count            int64  `parquet:"count"`
+	PromptTokens     int64  `parquet:"prompt_tokens"`
+	CompletionTokens int64  `parquet:"completion_tokens"`
+	Quota            int64  `parquet:"quota"`
+}
+
+var costStatHeader = []string{"created_at", "user_id", "username", "token_id", "token_name", "channel_id", "model_name",
+	"count", "prompt_tokens", "completion_tokens", "quota"}
+
+func (r *costStatRow) csvRecord() []string {
+	return []string{strconv.FormatInt(r.CreatedAt, 10), strconv.FormatInt(r.UserId, 10), r.Username, strconv.FormatInt(r.TokenId, 10),
+		r.TokenName, strconv.FormatInt(r.ChannelId, 10), r.ModelName, strconv.FormatInt(r.Count, 10),
+		strconv.FormatInt(r.PromptTokens, 10), strconv.FormatInt(r.CompletionTokens, 10), strconv.FormatInt(r.Quota, 10)}
+}
+
+// exportWriter 屏蔽 CSV 与 Parquet 的差异
+type exportWriter[T any] struct {
+	csvWriter     *csv.Writer
+	gzipWriter    *gzip.Writer
+	parquetWriter *parquet.GenericWriter[T]
+	record        func(*T) []string
+}
+
+func newExportWriter[T any](w io.Writer, format string, header []string, record func(*T) []string) (*exportWriter[T], error) {
+	if format == operation_setting.UsageExportFormatParquet {
+		return &exportWriter[T]{parquetWriter: parquet.NewGenericWriter[T](w)}, nil
+	}
+	gz := gzip.NewWriter(w)
+	writer := &exportWriter[T]{csvWriter: csv.NewWriter(gz), gzipWriter: gz, record: record}
+	if err := writer.csvWriter.Write(header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *exportWriter[T]) Write(rows []T) error {
+	if w.parquetWriter != nil {
+		_, err := w.parquetWriter.Write(rows)
+		return err
+	}
+	for i := range rows {
+		if err := w.csvWriter.Write(w.record(&rows[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *exportWriter[T]) Close() error {
+	if w.parquetWriter != nil {
+		return w.parquetWriter.Close()
+	}
+	w.csvWriter.Flush()
+	if err := w.csvWriter.Error(); err != nil {
+		return err
+	}
+	return w.gzipWriter.Close()
+}
+
+func usageExportExt(format string) string {
+	if format == operation_setting.UsageExportFormatParquet {
+		return ".parquet"
+	}
+	return ".csv.gz"
+}
+
+func newUsageExportClient(setting *operation_setting.UsageExportSetting) (*minio.Client, error) {
+	if setting.Endpoint == "" || setting.Bucket == "" {
+		return nil, errors.New("usage export endpoint or bucket is not configured")
+	}
+	return minio.New(setting.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(setting.AccessKey, setting.SecretKey, ""),
+		Secure: setting.UseSSL,
+		Region: setting.Region,
+	})
+}
+
+// uploadExportFile 先写入临时文件再上传，避免大文件常驻内存
+func uploadExportFile(ctx context.Context, client *minio.Client, bucket string, objectName string, write func(w io.Writer) (int, error)) (int, error) {
+	file, err := os.CreateTemp("", "usage-export-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+	count, err := write(file)
+	if err != nil {
+		return 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	_, err = client.PutObject(ctx, bucket, objectName, file, info.Size(), minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return count, err
+}
+
+// ExportUsageForDay 将指定自然日的消费日志（及费用统计）导出到对象存储，按 dt=YYYY-MM-DD 分区，重复导出会覆盖同一对象
+func ExportUsageForDay(ctx context.Context, day time.Time) error {
+	setting := operation_setting.GetUsageExportSetting()
+	client, err := newUsageExportClient(setting)
+	if err != nil {
+		return err
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 0, 1)
+	partition := "dt=" + start.Format("2006-01-02")
+	ext := usageExportExt(setting.Format)
+	batchSize := setting.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	logObject := path.Join(setting.Prefix, "consume_logs", partition, "consume_logs"+ext)
+	logCount, err := uploadExportFile(ctx, client, setting.Bucket, logObject, func(w io.Writer) (int, error) {
+		writer, err := newExportWriter[usageLogRow](w, setting.Format, usageLogHeader, (*usageLogRow).csvRecord)
+		if err != nil {
+			return 0, err
+		}
+		count := 0
+		afterId := 0
+		for {
+			logs, err := model.GetConsumeLogsAfterId(start.Unix(), end.Unix(), afterId, batchSize)
+			if err != nil {
+				return 0, err
+			}
+			if len(logs) == 0 {
+				break
+			}
+			rows := make([]usageLogRow, 0, len(logs))
+			for _, log := range logs {
+				rows = append(rows, newUsageLogRow(log))
+			}
+			if err = writer.Write(rows); err != nil {
+				return 0, err
+			}
+			count += len(logs)
+			afterId = logs[len(logs)-1].Id
+			if len(logs) < batchSize {
+				break
+			}
+		}
+		return count, writer.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("export consume logs failed: %w", err)
+	}
+	common.SysLog(fmt.Sprintf("exported %d consume logs to %s/%s", logCount, setting.Bucket, logObject))
+
+	if !setting.IncludeAggregates {
+		return nil
+	}
+	statObject := path.Join(setting.Prefix, "cost_stats", partition, "cost_stats"+ext)
+	statCount, err := uploadExportFile(ctx, client, setting.Bucket, statObject, func(w io.Writer) (int, error) {
+		stats, err := model.GetCostStatsInRange(start.Unix(), end.Unix())
+		if err != nil {
+			return 0, err
+		}
+		writer, err := newExportWriter[costStatRow](w, setting.Format, costStatHeader, (*costStatRow).csvRecord)
+		if err != nil {
+			return 0, err
+		}
+		rows := make([]costStatRow, 0, len(stats))
+		for _, stat := range stats {
+			rows = append(rows, costStatRow{
+				CreatedAt:        stat.CreatedAt,
+				UserId:           int64(stat.UserId),
+				Username:         stat.Username,
+				TokenId:          int64(stat.TokenId),
+				TokenName:        stat.TokenName,
+				ChannelId:        int64(stat.ChannelId),
+				ModelName:        stat.ModelName,
+				Count:            int64(stat.Count),
+				PromptTokens:     int64(stat.PromptTokens),
+				CompletionTokens: int64(stat.CompletionTokens),
+				Quota:            int64(stat.Quota),
+			})
+		}
+		if err = writer.Write(rows); err != nil {
+			return 0, err
+		}
+		return len(rows), writer.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("export cost stats failed: %w", err)
+	}
+	common.SysLog(fmt.Sprintf("exported %d cost stats to %s/%s", statCount, setting.Bucket, statObject))
+	return nil
+}
@@ -1,7 +1,14 @@
 package service
 
 import (
+	"fmt"
+	"math"
+	"one-api/common"
 	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
 )
 
 //func GetPromptTokens(textRequest dto.GeneralOpenAIRequest, relayMode int) (int, error) {
@@ -28,3 +35,44 @@ func ResponseText2Usage(responseText string, modeName string, promptTokens int)
 func ValidUsage(usage *dto.Usage) bool {
 	return usage != nil && (usage.PromptTokens != 0 || usage.CompletionTokens != 0)
 }
+
+// MergeUsageEstimate 以上游返回的 usage 为准，仅补齐缺失的字段：prompt 使用预估值，completion 按输出文本计算
+func MergeUsageEstimate(usage *dto.Usage, responseText string, modelName string, promptTokens int) *dto.Usage {
+	if usage == nil {
+		usage = &dto.Usage{}
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = promptTokens
+	}
+	if usage.CompletionTokens == 0 && responseText != "" {
+		usage.CompletionTokens, _ = CountTextToken(responseText, modelName)
+	}
+	if usage.TotalTokens < usage.PromptTokens+usage.CompletionTokens {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+// ReconcilePromptUsage 比较本地预估与上游返回的 prompt token，偏差超过阈值时记录日志并返回写入消费日志的对账信息
+func ReconcilePromptUsage(c *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) map[string]interface{} {
+	setting := operation_setting.GetUsageReconciliationSetting()
+	if !setting.Enabled || usage == nil || usage.PromptTokens == 0 || relayInfo.PromptTokens == 0 {
+		return nil
+	}
+	if usage.PromptTokens < setting.MinTokens {
+		return nil
+	}
+	estimated := relayInfo.PromptTokens
+	reported := usage.PromptTokens
+	diffPercent := math.Abs(float64(estimated-reported)) / float64(reported) * 100
+	if diffPercent <= setting.ThresholdPercent {
+		return nil
+	}
+	common.LogWarn(c, fmt.Sprintf("prompt token estimate diverges from upstream usage: model=%s, channel=%d, estimated=%d, reported=%d, diff=%.1f%%",
+		relayInfo.UpstreamModelName, relayInfo.ChannelId, estimated, reported, diffPercent))
+	return map[string]interface{}{
+		"estimated_prompt_tokens": estimated,
+		"reported_prompt_tokens":  reported,
+		"diff_percent":            math.Round(diffPercent*10) / 10,
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 同类事件（如同一用户额度不足）在该时间内只发送一次
+const webhookEventDedupeInterval = time.Hour
+
+var webhookEventSentAt sync.Map // dedupe key -> time.Time
+var webhookEventSweptAt time.Time
+var webhookEventSweepLock sync.Mutex
+
+// sweepWebhookEventState 每小时清理已过去重间隔的事件记录与已结束的渠道失败窗口
+func sweepWebhookEventState(now time.Time) {
+	webhookEventSweepLock.Lock()
+	if now.Sub(webhookEventSweptAt) < time.Hour {
+		webhookEventSweepLock.Unlock()
+		return
+	}
+	webhookEventSweptAt = now
+	webhookEventSweepLock.Unlock()
+
+	webhookEventSentAt.Range(func(key, value any) bool {
+		if now.Sub(value.(time.Time)) >= webhookEventDedupeInterval {
+			webhookEventSentAt.Delete(key)
+		}
+		return true
+	})
+	window := time.Duration(operation_setting.GetWebhookEventSetting().ChannelFailureWindowSeconds) * time.Second
+	channelFailureWindowsLock.Lock()
+	for channelId, failures := range channelFailureWindows {
+		if now.Sub(failures.start) > window {
+			delete(channelFailureWindows, channelId)
+		}
+	}
+	channelFailureWindowsLock.Unlock()
+}
+
+// EmitWebhookEvent 异步向订阅该事件的运维 webhook 投递事件
+func EmitWebhookEvent(eventType string, data map[string]interface{}) {
+	endpoints := operation_setting.GetWebhookEndpoints(eventType)
+	if len(endpoints) == 0 {
+		return
+	}
+	event := dto.WebhookEvent{
+		Id:        common.GetUUID(),
+		Type:      eventType,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		common.SysError("failed to marshal webhook event: " + err.Error())
+		return
+	}
+	for _, endpoint := range endpoints {
+		endpoint := endpoint
+		gopool.Go(func() {
+			deliverWebhookEvent(event, endpoint, payload)
+		})
+	}
+}
+
+// emitWebhookEventOnce 按 dedupeKey 去重，避免每个请求都触发同一事件
+func emitWebhookEventOnce(dedupeKey string, eventType string, data map[string]interface{}) {
+	if len(operation_setting.GetWebhookEndpoints(eventType)) == 0 {
+		return
+	}
+	now := time.Now()
+	sweepWebhookEventState(now)
+	if sentAt, ok := webhookEventSentAt.Load(dedupeKey); ok && now.Sub(sentAt.(time.Time)) < webhookEventDedupeInterval {
+		return
+	}
+	webhookEventSentAt.Store(dedupeKey, now)
+	EmitWebhookEvent(eventType, data)
+}
+
+// postWebhookEvent 签名为 HMAC-SHA256(secret, timestamp + "." + body)，接收方可据此校验来源并拒绝重放
+func postWebhookEvent(client *http.Client, url string, secret string, eventId string, eventType string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", eventId)
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	if secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+generateSignature(secret, []byte(timestamp+"."+string(payload))))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func deliverWebhookEvent(event dto.WebhookEvent, endpoint operation_setting.WebhookEndpoint, payload []byte) {
+	maxRetries := operation_setting.GetWebhookEventSetting().MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	var err error
+	attempts := 0
+	for i := 0; i <= maxRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(1<<(i-1)) * time.Second)
+		}
+		attempts++
+		err = postWebhookEvent(GetImpatientHttpClient(), endpoint.Url, endpoint.Secret, event.Id, event.Type, payload)
+		if err == nil {
+			return
+		}
+	}
+	common.SysError(fmt.Sprintf("webhook event %s (%s) to %s failed after %d attempts: %s", event.Id, event.Type, endpoint.Url, attempts, err.Error()))
+	deadLetter := &model.WebhookDeadLetter{
+		EventId:   event.Id,
+		EventType: event.Type,
+		Url:       endpoint.Url,
+		Payload:   string(payload),
+		Attempts:  attempts,
+		LastError: err.Error(),
+	}
+	if err := model.RecordWebhookDeadLetter(deadLetter); err != nil {
+		common.SysError("failed to record webhook dead letter: " + err.Error())
+	}
+}
+
+// RetryWebhookDeadLetter 重新投递死信，成功后删除，失败则累加尝试次数；签名密钥取当前配置中同一 URL 的端点
+func RetryWebhookDeadLetter(deadLetter *model.WebhookDeadLetter) error {
+	var secret string
+	for _, endpoint := range operation_setting.GetWebhookEventSetting().Endpoints {
+		if endpoint.Url == deadLetter.Url {
+			secret = endpoint.Secret
+			break
+		}
+	}
+	err := postWebhookEvent(GetImpatientHttpClient(), deadLetter.Url, secret, deadLetter.EventId, deadLetter.EventType, []byte(deadLetter.Payload))
+	if err == nil {
+		return model.DeleteWebhookDeadLetterById(deadLetter.Id)
+	}
+	deadLetter.Attempts++
+	deadLetter.LastError = err.Error()
+	if updateErr := deadLetter.Update(); updateErr != nil {
+		common.SysError("failed to update webhook dead letter: " + updateErr.Error())
+	}
+	return err
+}
+
+type channelFailureWindow struct {
+	start    time.Time
+	count    int
+	notified bool
+}
+
+var channelFailureWindows = make(map[int]*channelFailureWindow)
+var channelFailureWindowsLock sync.Mutex
+
+// RecordChannelFailureEvent 统计渠道在窗口内的失败次数，达到阈值时每个窗口发送一次事件
+func RecordChannelFailureEvent(channelId int, channelName string, err *dto.OpenAIErrorWithStatusCode) {
+	setting := operation_setting.GetWebhookEventSetting()
+	if !setting.Enabled || setting.ChannelFailureThreshold <= 0 {
+		return
+	}
+	window := time.Duration(setting.ChannelFailureWindowSeconds) * time.Second
+	now := time.Now()
+	sweepWebhookEventState(now)
+	channelFailureWindowsLock.Lock()
+	failures, ok := channelFailureWindows[channelId]
+	if !ok || now.Sub(failures.start) > window {
+		failures = &channelFailureWindow{start: now}
+		channelFailureWindows[channelId] = failures
+	}
+	failures.count++
+	shouldNotify := failures.count >= setting.ChannelFailureThreshold && !failures.notified
+	if shouldNotify {
+		failures.notified = true
+	}
+	count := failures.count
+	channelFailureWindowsLock.Unlock()
+	if !shouldNotify {
+		return
+	}
+	EmitWebhookEvent(dto.WebhookEventChannelFailures, map[string]interface{}{
+		"channel_id":     channelId,
+		"channel_name":   channelName,
+		"failures":       count,
+		"window_seconds": setting.ChannelFailureWindowSeconds,
+		"status_code":    err.StatusCode,
+		"last_error":     err.Error.Message,
+	})
+}
@@ -17,6 +17,8 @@ type ClaudeSettings struct {
 	DefaultMaxTokens                      map[string]int                 `json:"default_max_tokens"`
 	ThinkingAdapterEnabled                bool                           `json:"thinking_adapter_enabled"`
 	ThinkingAdapterBudgetTokensPercentage float64                        `json:"thinking_adapter_budget_tokens_percentage"`
+	PromptCacheAutoEnabled                bool                           `json:"prompt_cache_auto_enabled"`
+	PromptCacheMinTokens                  int                            `json:"prompt_cache_min_tokens"`
 }
 
 // 默认配置
@@ -27,6 +29,8 @@ var defaultClaudeSettings = ClaudeSettings{
 		"default": 8192,
 	},
 	ThinkingAdapterBudgetTokensPercentage: 0.8,
+	// Anthropic 要求可缓存前缀至少 1024 token（Haiku 为 2048）
+	PromptCacheMinTokens: 1024,
 }
 
 // 全局实例
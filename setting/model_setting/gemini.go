@@ -11,6 +11,8 @@ type GeminiSettings struct {
 	SupportedImagineModels                []string          `json:"supported_imagine_models"`
 	ThinkingAdapterEnabled                bool              `json:"thinking_adapter_enabled"`
 	ThinkingAdapterBudgetTokensPercentage float64           `json:"thinking_adapter_budget_tokens_percentage"`
+	// CachedContentMaxTTLSeconds 通过网关创建的上下文缓存的最长存活时间，缓存存储只在创建时计费一次，需限制存活时间
+	CachedContentMaxTTLSeconds int `json:"cached_content_max_ttl_seconds"`
 }
 
 // 默认配置
@@ -29,6 +31,7 @@ var defaultGeminiSettings = GeminiSettings{
 	},
 	ThinkingAdapterEnabled:                false,
 	ThinkingAdapterBudgetTokensPercentage: 0.6,
+	CachedContentMaxTTLSeconds:            3600,
 }
 
 // 全局实例
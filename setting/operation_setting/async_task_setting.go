@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type AsyncTaskSetting struct {
+	// PollIntervalSeconds 轮询上游任务状态的间隔
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+	// TimeoutSeconds 任务提交后超过该时间仍未结束则判定失败并退还额度
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// WebhookMaxRetries 回调失败后的重试次数，重试间隔按 2 的幂次递增
+	WebhookMaxRetries int `json:"webhook_max_retries"`
+}
+
+// 默认配置
+var asyncTaskSetting = AsyncTaskSetting{
+	PollIntervalSeconds: 15,
+	TimeoutSeconds:      24 * 3600,
+	WebhookMaxRetries:   3,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("async_task_setting", &asyncTaskSetting)
+}
+
+func GetAsyncTaskSetting() *AsyncTaskSetting {
+	return &asyncTaskSetting
+}
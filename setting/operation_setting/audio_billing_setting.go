@@ -0,0 +1,53 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// AudioMinutePrice 每分钟音频的价格（美元）
+type AudioMinutePrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+type AudioBillingSetting struct {
+	// MinutePrices 模型（或模型前缀）-> 每分钟音频价格，上游未返回文本/音频 token 明细时按音频时长计费
+	MinutePrices map[string]AudioMinutePrice `json:"minute_prices"`
+}
+
+// 默认配置，参考 https://platform.openai.com/docs/pricing 的音频分钟估算价格
+var audioBillingSetting = AudioBillingSetting{
+	MinutePrices: map[string]AudioMinutePrice{
+		"gpt-4o-realtime-preview":      {Input: 0.06, Output: 0.24},
+		"gpt-4o-mini-realtime-preview": {Input: 0.006, Output: 0.024},
+		"gpt-4o-audio-preview":         {Input: 0.024, Output: 0.096},
+		"gpt-4o-mini-audio-preview":    {Input: 0.006, Output: 0.024},
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audio_billing_setting", &audioBillingSetting)
+}
+
+func GetAudioBillingSetting() *AudioBillingSetting {
+	return &audioBillingSetting
+}
+
+// GetAudioMinutePrice 优先精确匹配模型名，否则取最长的前缀匹配，以覆盖带日期后缀的模型
+func GetAudioMinutePrice(modelName string) (AudioMinutePrice, bool) {
+	if price, ok := audioBillingSetting.MinutePrices[modelName]; ok {
+		return price, true
+	}
+	var matched string
+	for prefix := range audioBillingSetting.MinutePrices {
+		if strings.HasPrefix(modelName, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	if matched == "" {
+		return AudioMinutePrice{}, false
+	}
+	return audioBillingSetting.MinutePrices[matched], true
+}
@@ -0,0 +1,60 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"sync"
+)
+
+// defaultBatchRatio 批处理请求的折扣倍率，OpenAI 与 Anthropic 的 Batch API 均为五折
+var defaultBatchRatio = map[string]float64{
+	"gpt-4o":                     0.5,
+	"gpt-4o-mini":                0.5,
+	"gpt-4.1":                    0.5,
+	"gpt-4.1-mini":               0.5,
+	"gpt-4.1-nano":               0.5,
+	"o1":                         0.5,
+	"o3":                         0.5,
+	"o3-mini":                    0.5,
+	"o4-mini":                    0.5,
+	"text-embedding-3-small":     0.5,
+	"text-embedding-3-large":     0.5,
+	"claude-3-5-haiku-20241022":  0.5,
+	"claude-3-5-sonnet-20241022": 0.5,
+	"claude-3-7-sonnet-20250219": 0.5,
+	"claude-sonnet-4-20250514":   0.5,
+	"claude-opus-4-20250514":     0.5,
+}
+
+var batchRatioMap map[string]float64
+var batchRatioMapMutex sync.RWMutex
+
+// BatchRatio2JSONString converts the batch ratio map to a JSON string
+func BatchRatio2JSONString() string {
+	batchRatioMapMutex.RLock()
+	defer batchRatioMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(batchRatioMap)
+	if err != nil {
+		common.SysError("error marshalling batch ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// UpdateBatchRatioByJSONString updates the batch ratio map from a JSON string
+func UpdateBatchRatioByJSONString(jsonStr string) error {
+	batchRatioMapMutex.Lock()
+	defer batchRatioMapMutex.Unlock()
+	batchRatioMap = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &batchRatioMap)
+}
+
+// GetBatchRatio returns the discount ratio applied to batch requests of a model
+func GetBatchRatio(name string) (float64, bool) {
+	batchRatioMapMutex.RLock()
+	defer batchRatioMapMutex.RUnlock()
+	ratio, ok := batchRatioMap[name]
+	if !ok {
+		return 1, false // Default to 1 if not found
+	}
+	return ratio, true
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type BodyCaptureSetting struct {
+	Enabled bool `json:"enabled"`
+	// MaxBodyBytes 请求体与响应体各自保留的最大字节数，超出部分截断
+	MaxBodyBytes int `json:"max_body_bytes"`
+	// RetentionHours 抓取记录保留小时数
+	RetentionHours int `json:"retention_hours"`
+	// RedactKeys 需要脱敏的 JSON 字段名（不区分大小写），请求头中的鉴权信息始终脱敏
+	RedactKeys []string `json:"redact_keys"`
+	// UserIds 非空时仅抓取这些用户的请求，便于针对单个用户排查
+	UserIds []int `json:"user_ids"`
+}
+
+// 默认配置
+var bodyCaptureSetting = BodyCaptureSetting{
+	Enabled:        false,
+	MaxBodyBytes:   64 * 1024,
+	RetentionHours: 24,
+	RedactKeys:     []string{"api_key", "apikey", "key", "secret", "client_secret", "password", "access_token", "refresh_token", "authorization"},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("body_capture_setting", &bodyCaptureSetting)
+}
+
+func GetBodyCaptureSetting() *BodyCaptureSetting {
+	return &bodyCaptureSetting
+}
+
+// ShouldCaptureBody 判断是否需要抓取指定用户的请求
+func ShouldCaptureBody(userId int) bool {
+	if !bodyCaptureSetting.Enabled {
+		return false
+	}
+	if len(bodyCaptureSetting.UserIds) == 0 {
+		return true
+	}
+	for _, id := range bodyCaptureSetting.UserIds {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
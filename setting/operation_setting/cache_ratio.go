@@ -36,6 +36,13 @@ var defaultCacheRatio = map[string]float64{
 	"claude-3-5-sonnet-20241022":          0.1,
 	"claude-3-7-sonnet-20250219":          0.1,
 	"claude-3-7-sonnet-20250219-thinking": 0.1,
+	"claude-sonnet-4-20250514":            0.1,
+	"claude-opus-4-20250514":              0.1,
+	"gemini-1.5-pro":                      0.25,
+	"gemini-1.5-flash":                    0.25,
+	"gemini-2.0-flash":                    0.25,
+	"gemini-2.5-pro":                      0.25,
+	"gemini-2.5-flash":                    0.25,
 }
 
 var defaultCreateCacheRatio = map[string]float64{
@@ -47,6 +54,8 @@ var defaultCreateCacheRatio = map[string]float64{
 	"claude-3-5-sonnet-20241022":          1.25,
 	"claude-3-7-sonnet-20250219":          1.25,
 	"claude-3-7-sonnet-20250219-thinking": 1.25,
+	"claude-sonnet-4-20250514":            1.25,
+	"claude-opus-4-20250514":              1.25,
 }
 
 //var defaultCreateCacheRatio = map[string]float64{}
@@ -91,8 +100,33 @@ func GetCacheRatio(name string) (float64, bool) {
 	return ratio, true
 }
 
+var createCacheRatioMap map[string]float64
+var createCacheRatioMapMutex sync.RWMutex
+
+// CreateCacheRatio2JSONString converts the cache write ratio map to a JSON string
+func CreateCacheRatio2JSONString() string {
+	createCacheRatioMapMutex.RLock()
+	defer createCacheRatioMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(createCacheRatioMap)
+	if err != nil {
+		common.SysError("error marshalling create cache ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// UpdateCreateCacheRatioByJSONString updates the cache write ratio map from a JSON string
+func UpdateCreateCacheRatioByJSONString(jsonStr string) error {
+	createCacheRatioMapMutex.Lock()
+	defer createCacheRatioMapMutex.Unlock()
+	createCacheRatioMap = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &createCacheRatioMap)
+}
+
+// GetCreateCacheRatio returns the cache write ratio for a model, relative to the model input price
 func GetCreateCacheRatio(name string) (float64, bool) {
-	ratio, ok := defaultCreateCacheRatio[name]
+	createCacheRatioMapMutex.RLock()
+	defer createCacheRatioMapMutex.RUnlock()
+	ratio, ok := createCacheRatioMap[name]
 	if !ok {
 		return 1.25, false // Default to 1.25 if not found
 	}
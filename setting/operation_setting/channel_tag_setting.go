@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ChannelTagSetting struct {
+	// GroupRequiredTags 分组 -> 必需的渠道路由标签，该分组的请求只使用同时具有这些标签的渠道
+	GroupRequiredTags map[string][]string `json:"group_required_tags"`
+	// GroupPreferredTags 分组 -> 优先的渠道路由标签，优先使用具有其中任一标签的渠道
+	GroupPreferredTags map[string][]string `json:"group_preferred_tags"`
+}
+
+// 默认配置
+var channelTagSetting = ChannelTagSetting{
+	GroupRequiredTags:  map[string][]string{},
+	GroupPreferredTags: map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_tag_setting", &channelTagSetting)
+}
+
+func GetChannelTagSetting() *ChannelTagSetting {
+	return &channelTagSetting
+}
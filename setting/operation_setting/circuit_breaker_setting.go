@@ -0,0 +1,36 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CircuitBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// WindowSeconds 统计错误率的滑动窗口长度
+	WindowSeconds int `json:"window_seconds"`
+	// MinRequests 窗口内请求数达到该值才会计算错误率，避免少量请求误熔断
+	MinRequests int `json:"min_requests"`
+	// ErrorRateThreshold 窗口内错误率达到该值（0-1）时熔断
+	ErrorRateThreshold float64 `json:"error_rate_threshold"`
+	// CooldownSeconds 熔断后首次探测的等待时间，探测失败时按倍数退避
+	CooldownSeconds int `json:"cooldown_seconds"`
+	// MaxCooldownSeconds 退避等待时间上限
+	MaxCooldownSeconds int `json:"max_cooldown_seconds"`
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:            false,
+	WindowSeconds:      60,
+	MinRequests:        10,
+	ErrorRateThreshold: 0.5,
+	CooldownSeconds:    30,
+	MaxCooldownSeconds: 600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
@@ -0,0 +1,32 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CompressionSetting struct {
+	// UpstreamEnabled 向上游声明支持 gzip / br，并自动解压上游响应（包括 SSE）
+	UpstreamEnabled bool `json:"upstream_enabled"`
+	// ResponseEnabled 客户端支持 gzip 时压缩较大的非流式响应
+	ResponseEnabled bool `json:"response_enabled"`
+	// ResponseMinBytes 响应体达到该大小才压缩
+	ResponseMinBytes int `json:"response_min_bytes"`
+	// UpstreamMaxDecompressedBytes 上游响应解压后的最大字节数，超过时中止读取，0 表示不限制
+	UpstreamMaxDecompressedBytes int64 `json:"upstream_max_decompressed_bytes"`
+}
+
+// 默认配置
+var compressionSetting = CompressionSetting{
+	UpstreamEnabled:  true,
+	ResponseEnabled:  false,
+	ResponseMinBytes: 64 << 10,
+
+	UpstreamMaxDecompressedBytes: 256 << 20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("compression_setting", &compressionSetting)
+}
+
+func GetCompressionSetting() *CompressionSetting {
+	return &compressionSetting
+}
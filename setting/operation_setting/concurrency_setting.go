@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ConcurrencySetting struct {
+	// GlobalMaxConcurrency 网关同时处理的最大中继请求数，0 表示不限制
+	GlobalMaxConcurrency int `json:"global_max_concurrency"`
+	// DefaultChannelMaxConcurrency 未在渠道设置中配置 max_concurrency 时的渠道并发上限，0 表示不限制
+	DefaultChannelMaxConcurrency int `json:"default_channel_max_concurrency"`
+}
+
+// 默认配置
+var concurrencySetting = ConcurrencySetting{
+	GlobalMaxConcurrency:         0,
+	DefaultChannelMaxConcurrency: 0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("concurrency_setting", &concurrencySetting)
+}
+
+func GetConcurrencySetting() *ConcurrencySetting {
+	return &concurrencySetting
+}
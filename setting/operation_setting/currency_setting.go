@@ -0,0 +1,156 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/setting/config"
+	"strings"
+	"sync"
+)
+
+const CurrencyUSD = "USD"
+
+type CurrencySetting struct {
+	// DefaultDisplayCurrency 用户未设置展示币种时使用的币种
+	DefaultDisplayCurrency string `json:"default_display_currency"`
+}
+
+// 默认配置
+var currencySetting = CurrencySetting{
+	DefaultDisplayCurrency: CurrencyUSD,
+}
+
+// defaultExchangeRates 各币种汇率，表示 1 美元可兑换的该币种金额
+var defaultExchangeRates = map[string]float64{
+	"USD": 1,
+	"CNY": 7.3,
+	"EUR": 0.92,
+}
+
+// exchangeRateMap 各币种汇率，modelPriceCurrencyMap 按次计费模型价格所用的币种，未配置的模型按美元计价
+var exchangeRateMap = defaultExchangeRates
+var modelPriceCurrencyMap = map[string]string{}
+var currencyMapMutex sync.RWMutex
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("currency_setting", &currencySetting)
+}
+
+func GetCurrencySetting() *CurrencySetting {
+	return &currencySetting
+}
+
+func ExchangeRates2JSONString() string {
+	currencyMapMutex.RLock()
+	defer currencyMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(exchangeRateMap)
+	if err != nil {
+		common.SysError("error marshalling exchange rates: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateExchangeRatesByJSONString(jsonStr string) error {
+	rates := make(map[string]float64)
+	if err := json.Unmarshal([]byte(jsonStr), &rates); err != nil {
+		return err
+	}
+	normalized := make(map[string]float64, len(rates))
+	for currency, rate := range rates {
+		normalized[strings.ToUpper(currency)] = rate
+	}
+	currencyMapMutex.Lock()
+	defer currencyMapMutex.Unlock()
+	exchangeRateMap = normalized
+	return nil
+}
+
+func ModelPriceCurrencies2JSONString() string {
+	currencyMapMutex.RLock()
+	defer currencyMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(modelPriceCurrencyMap)
+	if err != nil {
+		common.SysError("error marshalling model price currencies: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelPriceCurrenciesByJSONString(jsonStr string) error {
+	currencies := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &currencies); err != nil {
+		return err
+	}
+	currencyMapMutex.Lock()
+	defer currencyMapMutex.Unlock()
+	modelPriceCurrencyMap = currencies
+	return nil
+}
+
+// GetExchangeRates 返回汇率表的副本
+func GetExchangeRates() map[string]float64 {
+	currencyMapMutex.RLock()
+	defer currencyMapMutex.RUnlock()
+	rates := make(map[string]float64, len(exchangeRateMap))
+	for currency, rate := range exchangeRateMap {
+		rates[currency] = rate
+	}
+	return rates
+}
+
+// GetExchangeRate 返回 1 美元可兑换的 currency 金额，未知币种或汇率无效时返回 false
+func GetExchangeRate(currency string) (float64, bool) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == CurrencyUSD {
+		return 1, true
+	}
+	currencyMapMutex.RLock()
+	rate, ok := exchangeRateMap[currency]
+	currencyMapMutex.RUnlock()
+	if !ok || rate <= 0 {
+		return 0, false
+	}
+	return rate, true
+}
+
+// GetModelPriceCurrency 返回模型价格配置所用的币种
+func GetModelPriceCurrency(modelName string) string {
+	currencyMapMutex.RLock()
+	currency, ok := modelPriceCurrencyMap[modelName]
+	currencyMapMutex.RUnlock()
+	if ok && currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return CurrencyUSD
+}
+
+// ConvertToUSD 将 currency 计价的金额换算为美元，币种汇率未配置时返回 false
+func ConvertToUSD(amount float64, currency string) (float64, bool) {
+	rate, ok := GetExchangeRate(currency)
+	if !ok {
+		return 0, false
+	}
+	return amount / rate, true
+}
+
+// ConvertFromUSD 将美元金额换算为 currency 计价，币种汇率未配置时返回 false
+func ConvertFromUSD(amount float64, currency string) (float64, bool) {
+	rate, ok := GetExchangeRate(currency)
+	if !ok {
+		return 0, false
+	}
+	return amount * rate, true
+}
+
+// ResolveDisplayCurrency 返回用户实际使用的展示币种，未设置或汇率未配置时依次回退到默认币种与美元
+func ResolveDisplayCurrency(userCurrency string) (string, float64) {
+	for _, currency := range []string{userCurrency, currencySetting.DefaultDisplayCurrency} {
+		if currency == "" {
+			continue
+		}
+		if rate, ok := GetExchangeRate(currency); ok {
+			return strings.ToUpper(currency), rate
+		}
+	}
+	return CurrencyUSD, 1
+}
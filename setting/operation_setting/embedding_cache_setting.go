@@ -0,0 +1,30 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type EmbeddingCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	// TTLSeconds 缓存有效期，文档向量通常长期不变，默认缓存一天
+	TTLSeconds int `json:"ttl_seconds"`
+	// MaxEntries 未启用 Redis 时内存缓存的最大条数
+	MaxEntries int `json:"max_entries"`
+	// MaxBodyBytes 超过该大小的响应不缓存
+	MaxBodyBytes int `json:"max_body_bytes"`
+}
+
+// 默认配置
+var embeddingCacheSetting = EmbeddingCacheSetting{
+	Enabled:      false,
+	TTLSeconds:   86400,
+	MaxEntries:   10000,
+	MaxBodyBytes: 4 << 20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("embedding_cache_setting", &embeddingCacheSetting)
+}
+
+func GetEmbeddingCacheSetting() *EmbeddingCacheSetting {
+	return &embeddingCacheSetting
+}
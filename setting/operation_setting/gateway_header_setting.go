@@ -0,0 +1,37 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type GatewayHeaderSetting struct {
+	// Enabled 在响应中返回 X-Gateway-Channel-Id、X-Gateway-Upstream-Latency、X-Gateway-Cost 等调试响应头
+	Enabled bool `json:"enabled"`
+	// AdminTokens 管理员的令牌是否返回这些响应头
+	AdminTokens bool `json:"admin_tokens"`
+	// TrustedTokenIds 额外信任的令牌，普通用户的这些令牌也返回这些响应头
+	TrustedTokenIds []int `json:"trusted_token_ids"`
+}
+
+// 默认配置
+var gatewayHeaderSetting = GatewayHeaderSetting{
+	Enabled:     false,
+	AdminTokens: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("gateway_header_setting", &gatewayHeaderSetting)
+}
+
+func GetGatewayHeaderSetting() *GatewayHeaderSetting {
+	return &gatewayHeaderSetting
+}
+
+// IsTrustedGatewayHeaderToken 判断令牌是否在额外信任的令牌列表中
+func IsTrustedGatewayHeaderToken(tokenId int) bool {
+	for _, id := range gatewayHeaderSetting.TrustedTokenIds {
+		if id == tokenId {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,10 @@ type GeneralSetting struct {
 	DocsLink            string `json:"docs_link"`
 	PingIntervalEnabled bool   `json:"ping_interval_enabled"`
 	PingIntervalSeconds int    `json:"ping_interval_seconds"`
+	// 等待上游响应头期间也发送保活，发送后失败的请求不再重试其他渠道
+	PingBeforeResponseEnabled bool `json:"ping_before_response_enabled"`
+	// OpenAI 兼容上游无需转换时直接转发流式数据，不逐块解析
+	StreamPassthroughEnabled bool `json:"stream_passthrough_enabled"`
 }
 
 // 默认配置
@@ -13,6 +17,8 @@ var generalSetting = GeneralSetting{
 	DocsLink:            "https://docs.newapi.pro",
 	PingIntervalEnabled: false,
 	PingIntervalSeconds: 60,
+
+	StreamPassthroughEnabled: true,
 }
 
 func init() {
@@ -0,0 +1,58 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"one-api/setting/config"
+	"strings"
+)
+
+type GroupModelSetting struct {
+	// AllowedModels 分组 -> 允许使用的模型，支持以 * 结尾的前缀匹配；未配置的分组不限制
+	AllowedModels map[string][]string `json:"allowed_models"`
+}
+
+// 默认配置
+var groupModelSetting = GroupModelSetting{
+	AllowedModels: map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_model_setting", &groupModelSetting)
+}
+
+func GetGroupModelSetting() *GroupModelSetting {
+	return &groupModelSetting
+}
+
+func GroupAllowedModels2JSONString() string {
+	jsonBytes, _ := json.Marshal(groupModelSetting.AllowedModels)
+	return string(jsonBytes)
+}
+
+func UpdateGroupAllowedModelsByJSONString(jsonStr string) error {
+	allowedModels := make(map[string][]string)
+	if err := json.Unmarshal([]byte(jsonStr), &allowedModels); err != nil {
+		return err
+	}
+	groupModelSetting.AllowedModels = allowedModels
+	return nil
+}
+
+// IsGroupModelAllowed 分组未配置允许模型时不限制
+func IsGroupModelAllowed(group string, modelName string) bool {
+	models, ok := groupModelSetting.AllowedModels[group]
+	if !ok {
+		return true
+	}
+	for _, pattern := range models {
+		if prefix, found := strings.CutSuffix(pattern, "*"); found {
+			if strings.HasPrefix(modelName, prefix) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type HealthCheckSetting struct {
+	Enabled bool `json:"enabled"`
+	// IntervalSeconds 两轮健康检查之间的间隔
+	IntervalSeconds int `json:"interval_seconds"`
+	// RetentionDays 健康检查记录保留天数
+	RetentionDays int `json:"retention_days"`
+}
+
+// 默认配置
+var healthCheckSetting = HealthCheckSetting{
+	Enabled:         false,
+	IntervalSeconds: 300,
+	RetentionDays:   7,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("health_check_setting", &healthCheckSetting)
+}
+
+func GetHealthCheckSetting() *HealthCheckSetting {
+	return &healthCheckSetting
+}
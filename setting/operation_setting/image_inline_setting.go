@@ -0,0 +1,35 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ImageInlineSetting 上游仅支持内联 base64 图片时（Claude、Gemini 等），网关下载远程图片的配置
+type ImageInlineSetting struct {
+	// MaxSizeMB 单张图片的最大大小，0 表示使用 MAX_FILE_DOWNLOAD_MB；上游自身的限制更小时以上游为准
+	MaxSizeMB int `json:"max_size_mb"`
+	// CacheTTLSeconds 已下载图片的内存缓存时间，多轮对话中重复发送同一图片时避免重复下载，0 表示不缓存
+	CacheTTLSeconds int `json:"cache_ttl_seconds"`
+	// CacheMaxEntries 内存缓存的最大图片数
+	CacheMaxEntries int `json:"cache_max_entries"`
+	// CacheMaxMB 内存缓存中图片 base64 数据的总大小上限，写满时淘汰已有图片
+	CacheMaxMB int `json:"cache_max_mb"`
+	// CacheMaxEntryMB 单张图片 base64 数据超过该大小时不缓存
+	CacheMaxEntryMB int `json:"cache_max_entry_mb"`
+}
+
+// 默认配置
+var imageInlineSetting = ImageInlineSetting{
+	MaxSizeMB:       0,
+	CacheTTLSeconds: 600,
+	CacheMaxEntries: 200,
+	CacheMaxMB:      256,
+	CacheMaxEntryMB: 8,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("image_inline_setting", &imageInlineSetting)
+}
+
+func GetImageInlineSetting() *ImageInlineSetting {
+	return &imageInlineSetting
+}
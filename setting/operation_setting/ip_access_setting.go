@@ -0,0 +1,22 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type IpAccessSetting struct {
+	// DenyList 全局拒绝访问中继接口的 IP 或 CIDR，优先于令牌白名单
+	DenyList []string `json:"deny_list"`
+}
+
+// 默认配置
+var ipAccessSetting = IpAccessSetting{
+	DenyList: []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ip_access_setting", &ipAccessSetting)
+}
+
+func GetIpAccessSetting() *IpAccessSetting {
+	return &ipAccessSetting
+}
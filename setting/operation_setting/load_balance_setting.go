@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type LoadBalanceSetting struct {
+	// LatencyAwareEnabled 选择渠道时结合渠道+模型的 p95 延迟和错误率调整权重
+	LatencyAwareEnabled bool `json:"latency_aware_enabled"`
+	// MinSamples 样本数不足时按配置权重选择
+	MinSamples int `json:"min_samples"`
+}
+
+// 默认配置
+var loadBalanceSetting = LoadBalanceSetting{
+	LatencyAwareEnabled: false,
+	MinSamples:          20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("load_balance_setting", &loadBalanceSetting)
+}
+
+func GetLoadBalanceSetting() *LoadBalanceSetting {
+	return &loadBalanceSetting
+}
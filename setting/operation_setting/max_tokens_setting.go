@@ -0,0 +1,66 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// MaxTokensLimit Max 为 max_tokens 上限，Default 为客户端未填写时使用的值，0 表示不限制 / 不填充
+type MaxTokensLimit struct {
+	Max     uint `json:"max"`
+	Default uint `json:"default"`
+}
+
+type MaxTokensSetting struct {
+	// Models 模型名 -> 限制，支持 * 结尾的前缀匹配，精确匹配优先
+	Models map[string]MaxTokensLimit `json:"models"`
+	// Groups 分组 -> 限制，与模型限制同时存在时取较小的上限，默认值以模型为准
+	Groups map[string]MaxTokensLimit `json:"groups"`
+}
+
+// 默认配置
+var maxTokensSetting = MaxTokensSetting{
+	Models: map[string]MaxTokensLimit{},
+	Groups: map[string]MaxTokensLimit{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("max_tokens_setting", &maxTokensSetting)
+}
+
+func GetMaxTokensSetting() *MaxTokensSetting {
+	return &maxTokensSetting
+}
+
+func getModelMaxTokensLimit(modelName string) MaxTokensLimit {
+	if limit, ok := maxTokensSetting.Models[modelName]; ok {
+		return limit
+	}
+	var matched MaxTokensLimit
+	matchedLen := -1
+	for pattern, limit := range maxTokensSetting.Models {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(modelName, prefix) && len(prefix) > matchedLen {
+			matched = limit
+			matchedLen = len(prefix)
+		}
+	}
+	return matched
+}
+
+// GetMaxTokensLimit 合并模型与分组的限制
+func GetMaxTokensLimit(group string, modelName string) MaxTokensLimit {
+	limit := getModelMaxTokensLimit(modelName)
+	groupLimit := maxTokensSetting.Groups[group]
+	if groupLimit.Max > 0 && (limit.Max == 0 || groupLimit.Max < limit.Max) {
+		limit.Max = groupLimit.Max
+	}
+	if limit.Default == 0 {
+		limit.Default = groupLimit.Default
+	}
+	if limit.Max > 0 && limit.Default > limit.Max {
+		limit.Default = limit.Max
+	}
+	return limit
+}
@@ -2,6 +2,7 @@ package operation_setting
 
 import (
 	"encoding/json"
+	"fmt"
 	"one-api/common"
 	"strings"
 	"sync"
@@ -281,6 +282,16 @@ func InitRatioSettings() {
 	cacheRatioMap = defaultCacheRatio
 	cacheRatioMapMutex.Unlock()
 
+	// Initialize createCacheRatioMap
+	createCacheRatioMapMutex.Lock()
+	createCacheRatioMap = defaultCreateCacheRatio
+	createCacheRatioMapMutex.Unlock()
+
+	// Initialize batchRatioMap
+	batchRatioMapMutex.Lock()
+	batchRatioMap = defaultBatchRatio
+	batchRatioMapMutex.Unlock()
+
 	// initialize imageRatioMap
 	imageRatioMapMutex.Lock()
 	imageRatioMap = defaultImageRatio
@@ -312,7 +323,7 @@ func UpdateModelPriceByJSONString(jsonStr string) error {
 	return json.Unmarshal([]byte(jsonStr), &modelPriceMap)
 }
 
-// GetModelPrice 返回模型的价格，如果模型不存在则返回-1，false
+// GetModelPrice 返回模型以美元计的价格，如果模型不存在则返回-1，false
 func GetModelPrice(name string, printErr bool) (float64, bool) {
 	modelPriceMapMutex.RLock()
 	defer modelPriceMapMutex.RUnlock()
@@ -330,7 +341,14 @@ func GetModelPrice(name string, printErr bool) (float64, bool) {
 		}
 		return -1, false
 	}
-	return price, true
+	// 价格可按其他币种配置，统一换算为美元后再计算额度；币种汇率未配置时视为价格不可用，不按 1:1 计费
+	currency := GetModelPriceCurrency(name)
+	usdPrice, ok := ConvertToUSD(price, currency)
+	if !ok {
+		common.SysError(fmt.Sprintf("exchange rate of %s not found, model price of %s is ignored", currency, name))
+		return -1, false
+	}
+	return usdPrice, true
 }
 
 func UpdateModelRatioByJSONString(jsonStr string) error {
@@ -525,6 +543,20 @@ func GetAudioRatio(name string) float64 {
 			return 20
 		}
 	}
+	if strings.HasPrefix(name, "gemini") {
+		// Gemini 音频输入相对文本输入的价格
+		switch {
+		case strings.HasPrefix(name, "gemini-2.5-flash-lite"):
+			return 0.3 / 0.1
+		case strings.HasPrefix(name, "gemini-2.5-flash"):
+			return 1.0 / 0.3
+		case strings.HasPrefix(name, "gemini-2.0-flash-lite"):
+			return 1
+		case strings.HasPrefix(name, "gemini-2.0-flash"):
+			return 0.7 / 0.1
+		}
+		return 1
+	}
 	if strings.Contains(name, "-audio") {
 		if strings.HasPrefix(name, "gpt-4o-audio-preview") {
 			return 40 / 2.5
@@ -0,0 +1,186 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+const (
+	ModelCapabilityModeReject = "reject"
+	ModelCapabilityModeAdapt  = "adapt"
+)
+
+const (
+	ModalityText  = "text"
+	ModalityImage = "image"
+	ModalityAudio = "audio"
+	ModalityFile  = "file"
+)
+
+// ModelCapability 模型能力描述，MaxContext 为 0 表示不限制
+type ModelCapability struct {
+	MaxContext       int      `json:"max_context"`
+	Tools            bool     `json:"tools"`
+	JsonSchema       bool     `json:"json_schema"`
+	InputModalities  []string `json:"input_modalities"`
+	OutputModalities []string `json:"output_modalities"`
+}
+
+func (m *ModelCapability) SupportsInput(modality string) bool {
+	for _, item := range m.InputModalities {
+		if item == modality {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ModelCapability) SupportsOutput(modality string) bool {
+	for _, item := range m.OutputModalities {
+		if item == modality {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ModelCapability) SupportsVision() bool {
+	return m.SupportsInput(ModalityImage)
+}
+
+type ModelCapabilitySetting struct {
+	Enabled bool `json:"enabled"`
+	// Mode reject 直接拒绝不支持的请求；adapt 移除不支持的内容（图片、工具等）后继续请求
+	Mode string `json:"mode"`
+	// Models 模型名 -> 能力，支持 * 结尾的前缀匹配，未登记的模型不做校验
+	Models map[string]ModelCapability `json:"models"`
+	// TruncationGroups 超出上下文时自动截断最早的非系统消息的分组，令牌设置中的 context_truncation 同样可以开启
+	TruncationGroups []string `json:"truncation_groups"`
+}
+
+var gpt4oCapability = ModelCapability{
+	MaxContext:       128000,
+	Tools:            true,
+	JsonSchema:       true,
+	InputModalities:  []string{ModalityText, ModalityImage},
+	OutputModalities: []string{ModalityText},
+}
+
+var gpt41Capability = ModelCapability{
+	MaxContext:       1047576,
+	Tools:            true,
+	JsonSchema:       true,
+	InputModalities:  []string{ModalityText, ModalityImage},
+	OutputModalities: []string{ModalityText},
+}
+
+var gpt35TurboCapability = ModelCapability{
+	MaxContext:       16385,
+	Tools:            true,
+	InputModalities:  []string{ModalityText},
+	OutputModalities: []string{ModalityText},
+}
+
+// 默认配置
+var modelCapabilitySetting = ModelCapabilitySetting{
+	Enabled: false,
+	Mode:    ModelCapabilityModeReject,
+	Models: map[string]ModelCapability{
+		// 前缀以 - 结尾，避免匹配到同名前缀的其他模型；音频与实时模型的能力不同，单独登记，按最长前缀优先匹配
+		"gpt-4o":   gpt4oCapability,
+		"gpt-4o-*": gpt4oCapability,
+		"gpt-4o-audio-preview*": {
+			MaxContext:       128000,
+			Tools:            true,
+			InputModalities:  []string{ModalityText, ModalityAudio},
+			OutputModalities: []string{ModalityText, ModalityAudio},
+		},
+		"gpt-4o-mini-audio-preview*": {
+			MaxContext:       128000,
+			Tools:            true,
+			InputModalities:  []string{ModalityText, ModalityAudio},
+			OutputModalities: []string{ModalityText, ModalityAudio},
+		},
+		"gpt-4o-realtime-preview*": {
+			MaxContext:       128000,
+			Tools:            true,
+			InputModalities:  []string{ModalityText, ModalityAudio},
+			OutputModalities: []string{ModalityText, ModalityAudio},
+		},
+		"gpt-4o-mini-realtime-preview*": {
+			MaxContext:       128000,
+			Tools:            true,
+			InputModalities:  []string{ModalityText, ModalityAudio},
+			OutputModalities: []string{ModalityText, ModalityAudio},
+		},
+		"gpt-4o-search-preview*": {
+			MaxContext:       128000,
+			JsonSchema:       true,
+			InputModalities:  []string{ModalityText},
+			OutputModalities: []string{ModalityText},
+		},
+		"gpt-4o-mini-search-preview*": {
+			MaxContext:       128000,
+			JsonSchema:       true,
+			InputModalities:  []string{ModalityText},
+			OutputModalities: []string{ModalityText},
+		},
+		"gpt-4.1":         gpt41Capability,
+		"gpt-4.1-*":       gpt41Capability,
+		"gpt-3.5-turbo":   gpt35TurboCapability,
+		"gpt-3.5-turbo-*": gpt35TurboCapability,
+		"gpt-3.5-turbo-instruct*": {
+			MaxContext:       4096,
+			InputModalities:  []string{ModalityText},
+			OutputModalities: []string{ModalityText},
+		},
+		"deepseek-chat": {
+			MaxContext:       65536,
+			Tools:            true,
+			InputModalities:  []string{ModalityText},
+			OutputModalities: []string{ModalityText},
+		},
+		"deepseek-reasoner": {
+			MaxContext:       65536,
+			InputModalities:  []string{ModalityText},
+			OutputModalities: []string{ModalityText},
+		},
+	},
+	TruncationGroups: []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_capability_setting", &modelCapabilitySetting)
+}
+
+func GetModelCapabilitySetting() *ModelCapabilitySetting {
+	return &modelCapabilitySetting
+}
+
+func ShouldTruncateContext(group string) bool {
+	for _, item := range modelCapabilitySetting.TruncationGroups {
+		if item == group {
+			return true
+		}
+	}
+	return false
+}
+
+// GetModelCapability 精确匹配优先，其次取最长的前缀匹配
+func GetModelCapability(modelName string) (*ModelCapability, bool) {
+	if capability, ok := modelCapabilitySetting.Models[modelName]; ok {
+		return &capability, true
+	}
+	var matched *ModelCapability
+	matchedLen := -1
+	for pattern, capability := range modelCapabilitySetting.Models {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(modelName, prefix) && len(prefix) > matchedLen {
+			capability := capability
+			matched = &capability
+			matchedLen = len(prefix)
+		}
+	}
+	return matched, matched != nil
+}
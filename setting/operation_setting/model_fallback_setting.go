@@ -0,0 +1,81 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/setting/config"
+)
+
+type ModelFallbackSetting struct {
+	Enabled bool `json:"enabled"`
+	// Chains 模型 -> 按顺序尝试的降级模型，如 {"gpt-4o": ["gpt-4o-mini", "claude-3-5-haiku"]}
+	Chains map[string][]string `json:"chains"`
+}
+
+// MaxModelFallbackSwitches 单个请求最多切换降级模型的次数
+const MaxModelFallbackSwitches = 5
+
+// 默认配置
+var modelFallbackSetting = ModelFallbackSetting{
+	Enabled: false,
+	Chains:  map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_fallback_setting", &modelFallbackSetting)
+}
+
+func GetModelFallbackSetting() *ModelFallbackSetting {
+	return &modelFallbackSetting
+}
+
+// GetModelFallbacks 返回模型的降级链，未启用或未配置时返回 nil
+func GetModelFallbacks(modelName string) []string {
+	if !modelFallbackSetting.Enabled {
+		return nil
+	}
+	return modelFallbackSetting.Chains[modelName]
+}
+
+// CheckModelFallbackChains 校验降级链：链中不能包含模型自身或重复的模型，链之间不能形成循环
+func CheckModelFallbackChains(jsonStr string) error {
+	chains := make(map[string][]string)
+	if err := json.Unmarshal([]byte(jsonStr), &chains); err != nil {
+		return err
+	}
+	for modelName, fallbacks := range chains {
+		seen := map[string]bool{modelName: true}
+		for _, fallback := range fallbacks {
+			if seen[fallback] {
+				return fmt.Errorf("模型 %s 的降级链中 %s 重复或指向自身", modelName, fallback)
+			}
+			seen[fallback] = true
+		}
+	}
+	// 0 未访问，1 访问中，2 已完成
+	state := make(map[string]int, len(chains))
+	var visit func(modelName string) error
+	visit = func(modelName string) error {
+		switch state[modelName] {
+		case 1:
+			return fmt.Errorf("模型 %s 的降级链形成循环", modelName)
+		case 2:
+			return nil
+		}
+		state[modelName] = 1
+		for _, fallback := range chains[modelName] {
+			if err := visit(fallback); err != nil {
+				return err
+			}
+		}
+		state[modelName] = 2
+		return nil
+	}
+	for modelName := range chains {
+		if err := visit(modelName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
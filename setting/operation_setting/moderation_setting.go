@@ -0,0 +1,73 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ModerationProviderOpenAI  = "openai"
+	ModerationProviderAzure   = "azure"
+	ModerationProviderWebhook = "webhook"
+)
+
+type ModerationSetting struct {
+	Enabled bool `json:"enabled"`
+	// Provider openai、azure 或 webhook
+	Provider string `json:"provider"`
+	// Endpoint openai 为 API 地址（默认官方地址），azure 为 Content Safety 资源地址，webhook 为回调地址
+	Endpoint string `json:"endpoint"`
+	ApiKey   string `json:"api_key"`
+	// Model openai 审核模型
+	Model          string `json:"model"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// FailOpen 审核服务不可用时是否放行请求
+	FailOpen bool `json:"fail_open"`
+	// Groups 非空时仅审核这些分组的请求
+	Groups []string `json:"groups"`
+	// Thresholds 类别 -> 分数阈值，分数达到阈值即拦截；为空时以审核服务返回的 flagged 为准
+	// openai 分数范围 0-1，azure 为严重等级 0-7
+	Thresholds map[string]float64 `json:"thresholds"`
+	// GroupThresholds 分组 -> 类别阈值，覆盖 Thresholds
+	GroupThresholds map[string]map[string]float64 `json:"group_thresholds"`
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	Enabled:         false,
+	Provider:        ModerationProviderOpenAI,
+	Model:           "omni-moderation-latest",
+	TimeoutSeconds:  10,
+	FailOpen:        true,
+	Thresholds:      map[string]float64{},
+	GroupThresholds: map[string]map[string]float64{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+// ShouldModerate 判断指定分组的请求是否需要审核
+func ShouldModerate(group string) bool {
+	if !moderationSetting.Enabled {
+		return false
+	}
+	if len(moderationSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range moderationSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+func GetModerationThresholds(group string) map[string]float64 {
+	if thresholds, ok := moderationSetting.GroupThresholds[group]; ok {
+		return thresholds
+	}
+	return moderationSetting.Thresholds
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type MultipartSetting struct {
+	// MaxMemoryBytes 解析 multipart 请求时文件内容保存在内存中的上限，超出部分写入临时文件
+	MaxMemoryBytes int `json:"max_memory_bytes"`
+	// MaxAudioBytes 音频转写、翻译请求体的大小上限，0 表示不限制
+	MaxAudioBytes int `json:"max_audio_bytes"`
+	// MaxImageBytes 图片编辑请求体的大小上限，0 表示不限制
+	MaxImageBytes int `json:"max_image_bytes"`
+	// MaxFileBytes 其余 multipart 请求体的大小上限，0 表示不限制
+	MaxFileBytes int `json:"max_file_bytes"`
+}
+
+// 默认配置
+var multipartSetting = MultipartSetting{
+	MaxMemoryBytes: 8 << 20,
+	MaxAudioBytes:  100 << 20,
+	MaxImageBytes:  50 << 20,
+	MaxFileBytes:   100 << 20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("multipart_setting", &multipartSetting)
+}
+
+func GetMultipartSetting() *MultipartSetting {
+	return &multipartSetting
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type PIIRedactionSetting struct {
+	Enabled bool `json:"enabled"`
+	// Groups 非空时仅对这些分组的请求脱敏
+	Groups []string `json:"groups"`
+	// Detectors 启用的检测器，内置 email、phone、id_card、credit_card，也可以是代码中注册的其他检测器
+	Detectors []string `json:"detectors"`
+	// CustomPatterns 自定义检测器名 -> 正则表达式，名称会用作占位符前缀
+	CustomPatterns map[string]string `json:"custom_patterns"`
+	// RestoreResponse 是否将响应中的占位符还原为原始内容
+	RestoreResponse bool `json:"restore_response"`
+}
+
+// 默认配置
+var piiRedactionSetting = PIIRedactionSetting{
+	Enabled:         false,
+	Detectors:       []string{"email", "phone", "id_card", "credit_card"},
+	CustomPatterns:  map[string]string{},
+	RestoreResponse: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("pii_redaction_setting", &piiRedactionSetting)
+}
+
+func GetPIIRedactionSetting() *PIIRedactionSetting {
+	return &piiRedactionSetting
+}
+
+// ShouldRedactPII 判断指定分组的请求是否需要脱敏
+func ShouldRedactPII(group string) bool {
+	if !piiRedactionSetting.Enabled {
+		return false
+	}
+	if len(piiRedactionSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range piiRedactionSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
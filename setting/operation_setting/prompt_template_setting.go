@@ -0,0 +1,53 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// PromptTemplate 注入到对话请求系统提示词中的模板，前后缀中的 {{name}} 会被替换为变量值
+type PromptTemplate struct {
+	SystemPrefix string            `json:"system_prefix"`
+	SystemSuffix string            `json:"system_suffix"`
+	Variables    map[string]string `json:"variables"`
+}
+
+type PromptTemplateSetting struct {
+	// Templates 模板名 -> 模板
+	Templates map[string]PromptTemplate `json:"templates"`
+	// GroupTemplates 分组 -> 模板名，先于令牌设置中的 prompt_template 应用
+	GroupTemplates map[string]string `json:"group_templates"`
+}
+
+// 默认配置
+var promptTemplateSetting = PromptTemplateSetting{
+	Templates:      map[string]PromptTemplate{},
+	GroupTemplates: map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("prompt_template_setting", &promptTemplateSetting)
+}
+
+func GetPromptTemplateSetting() *PromptTemplateSetting {
+	return &promptTemplateSetting
+}
+
+// HasPromptTemplate 判断模板是否存在
+func HasPromptTemplate(name string) bool {
+	_, ok := promptTemplateSetting.Templates[name]
+	return ok
+}
+
+// GetPromptTemplates 返回需要依次应用的模板：先应用分组模板，再应用令牌指定的模板，两者相同时只应用一次
+func GetPromptTemplates(tokenTemplate string, group string) []*PromptTemplate {
+	templates := make([]*PromptTemplate, 0, 2)
+	groupTemplate := promptTemplateSetting.GroupTemplates[group]
+	for _, name := range []string{groupTemplate, tokenTemplate} {
+		if name == "" || (name == groupTemplate && len(templates) > 0) {
+			continue
+		}
+		if template, ok := promptTemplateSetting.Templates[name]; ok {
+			templates = append(templates, &template)
+		}
+	}
+	return templates
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type RequestQueueSetting struct {
+	// GroupPriorities 分组优先级，数值越大越优先获得并发名额，未配置的分组为 0
+	GroupPriorities map[string]int `json:"group_priorities"`
+	// MaxQueueSize 每个渠道的最大排队请求数，0 表示不限制
+	MaxQueueSize int `json:"max_queue_size"`
+	// MaxWaitSeconds 排队最长等待时间，超时返回 429
+	MaxWaitSeconds int `json:"max_wait_seconds"`
+}
+
+// 默认配置
+var requestQueueSetting = RequestQueueSetting{
+	GroupPriorities: map[string]int{
+		"default": 0,
+		"vip":     10,
+		"svip":    20,
+	},
+	MaxQueueSize:   100,
+	MaxWaitSeconds: 30,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("request_queue_setting", &requestQueueSetting)
+}
+
+func GetRequestQueueSetting() *RequestQueueSetting {
+	return &requestQueueSetting
+}
+
+func GetGroupPriority(group string) int {
+	return requestQueueSetting.GroupPriorities[group]
+}
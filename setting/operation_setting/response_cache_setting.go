@@ -0,0 +1,49 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ResponseCacheSetting struct {
+	// Enabled 总开关，开启后仍需在令牌设置中启用 response_cache 才会生效
+	Enabled bool `json:"enabled"`
+	// TTLSeconds 缓存有效期
+	TTLSeconds int `json:"ttl_seconds"`
+	// MaxBodyBytes 超过该大小的响应不缓存
+	MaxBodyBytes int `json:"max_body_bytes"`
+	// MaxEntries 未启用 Redis 时内存缓存的最大条数
+	MaxEntries int `json:"max_entries"`
+	// Models 非空时仅缓存这些模型的请求
+	Models []string `json:"models"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:      false,
+	TTLSeconds:   300,
+	MaxBodyBytes: 1 << 20,
+	MaxEntries:   10000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// ShouldCacheResponse 判断指定模型的请求是否可以使用响应缓存
+func ShouldCacheResponse(modelName string) bool {
+	if !responseCacheSetting.Enabled {
+		return false
+	}
+	if len(responseCacheSetting.Models) == 0 {
+		return true
+	}
+	for _, m := range responseCacheSetting.Models {
+		if m == modelName {
+			return true
+		}
+	}
+	return false
+}
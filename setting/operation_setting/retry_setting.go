@@ -0,0 +1,32 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type RetrySetting struct {
+	// TimeoutRetryEnabled 上游返回 504/524 超时时是否切换渠道重试（可能导致上游重复计费，默认关闭）
+	TimeoutRetryEnabled bool `json:"timeout_retry_enabled"`
+	// ExcludeFailedChannel 重试时排除本次请求中已失败的渠道
+	ExcludeFailedChannel bool `json:"exclude_failed_channel"`
+	// FirstTokenTimeoutSeconds 流式请求等待首个数据块的超时，超时且尚未向客户端输出时切换渠道重试，0 表示不限制；
+	// 渠道设置 first_token_timeout 优先
+	FirstTokenTimeoutSeconds int `json:"first_token_timeout_seconds"`
+	// StreamFailoverEnabled 流式请求在首个内容前缓存输出，上游在此之前出错时客户端无感知地切换渠道重试；
+	// 开启保活时，发送保活后即结束缓存
+	StreamFailoverEnabled bool `json:"stream_failover_enabled"`
+}
+
+// 默认配置
+var retrySetting = RetrySetting{
+	TimeoutRetryEnabled:   false,
+	ExcludeFailedChannel:  true,
+	StreamFailoverEnabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("retry_setting", &retrySetting)
+}
+
+func GetRetrySetting() *RetrySetting {
+	return &retrySetting
+}
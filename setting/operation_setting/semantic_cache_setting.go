@@ -0,0 +1,52 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type SemanticCacheSetting struct {
+	// Enabled 总开关，开启后仍需在令牌设置中启用 semantic_cache 才会生效
+	Enabled bool `json:"enabled"`
+	// EmbeddingModel 用于计算提示词向量的模型，需有可用的 OpenAI 兼容渠道，向量化费用按该模型计费
+	EmbeddingModel string `json:"embedding_model"`
+	// Threshold 余弦相似度达到该值时视为命中
+	Threshold float64 `json:"threshold"`
+	// TTLSeconds 缓存有效期
+	TTLSeconds int `json:"ttl_seconds"`
+	// MaxEntries 每个模型保留的最大条数，超出后淘汰最早写入的条目
+	MaxEntries int `json:"max_entries"`
+	// Models 非空时仅对这些模型的对话请求启用
+	Models []string `json:"models"`
+}
+
+// 默认配置
+var semanticCacheSetting = SemanticCacheSetting{
+	Enabled:        false,
+	EmbeddingModel: "text-embedding-3-small",
+	Threshold:      0.95,
+	TTLSeconds:     3600,
+	MaxEntries:     1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("semantic_cache_setting", &semanticCacheSetting)
+}
+
+func GetSemanticCacheSetting() *SemanticCacheSetting {
+	return &semanticCacheSetting
+}
+
+// ShouldUseSemanticCache 判断指定模型的对话请求是否可以使用语义缓存
+func ShouldUseSemanticCache(modelName string) bool {
+	if !semanticCacheSetting.Enabled || semanticCacheSetting.EmbeddingModel == "" {
+		return false
+	}
+	if len(semanticCacheSetting.Models) == 0 {
+		return true
+	}
+	for _, m := range semanticCacheSetting.Models {
+		if m == modelName {
+			return true
+		}
+	}
+	return false
+}
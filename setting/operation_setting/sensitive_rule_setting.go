@@ -0,0 +1,70 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"sort"
+	"sync/atomic"
+)
+
+// SensitiveCategory 一个敏感词分类，Words 为不区分大小写的词表，Regex 为正则规则
+type SensitiveCategory struct {
+	Words []string `json:"words"`
+	Regex []string `json:"regex"`
+}
+
+type SensitiveRuleSetting struct {
+	Categories map[string]SensitiveCategory `json:"categories"`
+	// DefaultCategories 未单独配置的分组启用的分类，为空表示启用全部分类
+	DefaultCategories []string `json:"default_categories"`
+	// GroupCategories 分组 -> 启用的分类，系统设置中的敏感词列表始终对所有分组生效
+	GroupCategories map[string][]string `json:"group_categories"`
+}
+
+// 默认配置
+var sensitiveRuleSetting = SensitiveRuleSetting{
+	Categories: map[string]SensitiveCategory{
+		"politics": {Words: []string{}, Regex: []string{}},
+		"violence": {Words: []string{}, Regex: []string{}},
+		"pii":      {Words: []string{}, Regex: []string{}},
+	},
+	DefaultCategories: []string{},
+	GroupCategories:   map[string][]string{},
+}
+
+// sensitiveRuleVersion 敏感词或规则变化时递增，用于使缓存的匹配器失效
+var sensitiveRuleVersion atomic.Int64
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("sensitive_rule_setting", &sensitiveRuleSetting)
+}
+
+func GetSensitiveRuleSetting() *SensitiveRuleSetting {
+	return &sensitiveRuleSetting
+}
+
+func NotifySensitiveRulesChanged() {
+	sensitiveRuleVersion.Add(1)
+}
+
+func GetSensitiveRuleVersion() int64 {
+	return sensitiveRuleVersion.Load()
+}
+
+// GetSensitiveCategories 返回分组启用的分类（已排序）
+func GetSensitiveCategories(group string) []string {
+	categories, ok := sensitiveRuleSetting.GroupCategories[group]
+	if !ok {
+		categories = sensitiveRuleSetting.DefaultCategories
+	}
+	if len(categories) == 0 {
+		categories = make([]string, 0, len(sensitiveRuleSetting.Categories))
+		for name := range sensitiveRuleSetting.Categories {
+			categories = append(categories, name)
+		}
+	} else {
+		categories = append([]string(nil), categories...)
+	}
+	sort.Strings(categories)
+	return categories
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ServiceTierSetting struct {
+	// Multipliers service_tier -> 价格倍率，按上游响应中实际使用的 service_tier 计费，
+	// 未配置的 service_tier（包括 default 与 auto）倍率为 1
+	Multipliers map[string]float64 `json:"multipliers"`
+}
+
+// 默认配置
+var serviceTierSetting = ServiceTierSetting{
+	Multipliers: map[string]float64{
+		"flex":     0.5,
+		"priority": 2,
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("service_tier_setting", &serviceTierSetting)
+}
+
+func GetServiceTierSetting() *ServiceTierSetting {
+	return &serviceTierSetting
+}
+
+// GetServiceTierMultiplier 返回 service_tier 的价格倍率
+func GetServiceTierMultiplier(tier string) float64 {
+	if multiplier, ok := serviceTierSetting.Multipliers[tier]; ok && multiplier > 0 {
+		return multiplier
+	}
+	return 1
+}
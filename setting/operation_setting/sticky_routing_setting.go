@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type StickyRoutingSetting struct {
+	// Enabled 同一会话的请求优先路由到上次成功的渠道，提高上游提示词缓存命中率
+	Enabled bool `json:"enabled"`
+	// TTLSeconds 会话与渠道绑定关系的有效期，每次命中时续期
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+// 默认配置
+var stickyRoutingSetting = StickyRoutingSetting{
+	Enabled:    false,
+	TTLSeconds: 3600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("sticky_routing_setting", &stickyRoutingSetting)
+}
+
+func GetStickyRoutingSetting() *StickyRoutingSetting {
+	return &stickyRoutingSetting
+}
@@ -0,0 +1,43 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	SystemPromptModePrepend = "prepend" // 拼接在客户端系统提示词之前
+	SystemPromptModeReplace = "replace" // 替换客户端的首条系统提示词
+)
+
+type GroupSystemPrompt struct {
+	Prompt string `json:"prompt"`
+	// Mode prepend 或 replace，默认 prepend
+	Mode string `json:"mode"`
+	// StripClientSystem 为 true 时先移除客户端传入的全部 system/developer 消息
+	StripClientSystem bool `json:"strip_client_system"`
+}
+
+type SystemPromptSetting struct {
+	// Groups 分组 -> 强制系统提示词
+	Groups map[string]GroupSystemPrompt `json:"groups"`
+}
+
+// 默认配置
+var systemPromptSetting = SystemPromptSetting{
+	Groups: map[string]GroupSystemPrompt{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("system_prompt_setting", &systemPromptSetting)
+}
+
+func GetSystemPromptSetting() *SystemPromptSetting {
+	return &systemPromptSetting
+}
+
+func GetGroupSystemPrompt(group string) (*GroupSystemPrompt, bool) {
+	prompt, ok := systemPromptSetting.Groups[group]
+	if !ok {
+		return nil, false
+	}
+	return &prompt, true
+}
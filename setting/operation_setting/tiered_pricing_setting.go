@@ -0,0 +1,49 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"sort"
+)
+
+// PriceTier 提示词 token 数超过 MinPromptTokens 时生效的倍率，CompletionRatio 为 0 时沿用模型默认补全倍率
+type PriceTier struct {
+	MinPromptTokens int     `json:"min_prompt_tokens"`
+	ModelRatio      float64 `json:"model_ratio"`
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+}
+
+type TieredPricingSetting struct {
+	// ModelTiers 按模型配置的长上下文阶梯价格，如 Gemini 提示词超过 200k 时价格翻倍
+	ModelTiers map[string][]PriceTier `json:"model_tiers"`
+}
+
+// 默认配置
+var tieredPricingSetting = TieredPricingSetting{
+	ModelTiers: map[string][]PriceTier{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tiered_pricing_setting", &tieredPricingSetting)
+}
+
+func GetTieredPricingSetting() *TieredPricingSetting {
+	return &tieredPricingSetting
+}
+
+// GetPriceTier 返回与提示词 token 数匹配的阈值最高的阶梯，未命中时返回 false
+func GetPriceTier(modelName string, promptTokens int) (PriceTier, bool) {
+	tiers := tieredPricingSetting.ModelTiers[modelName]
+	if len(tiers) == 0 {
+		return PriceTier{}, false
+	}
+	sorted := make([]PriceTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinPromptTokens > sorted[j].MinPromptTokens })
+	for _, tier := range sorted {
+		if promptTokens > tier.MinPromptTokens && tier.ModelRatio > 0 {
+			return tier, true
+		}
+	}
+	return PriceTier{}, false
+}
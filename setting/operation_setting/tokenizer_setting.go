@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type TokenizerSetting struct {
+	// ClaudeRatio Claude 模型以 cl100k_base 计数后乘以该系数近似其分词器
+	ClaudeRatio float64 `json:"claude_ratio"`
+	// GeminiRatio Gemini 模型以 cl100k_base 计数后乘以该系数近似其分词器
+	GeminiRatio float64 `json:"gemini_ratio"`
+	// CountTokensAPIEnabled Anthropic / Gemini 渠道调用官方 count_tokens 接口计算纯文本请求的输入 token，会增加一次上游请求
+	CountTokensAPIEnabled bool `json:"count_tokens_api_enabled"`
+	// CountTokensAPITimeoutMs 调用 count_tokens 接口的超时时间，超时后回退到本地估算
+	CountTokensAPITimeoutMs int `json:"count_tokens_api_timeout_ms"`
+}
+
+// 默认配置
+var tokenizerSetting = TokenizerSetting{
+	ClaudeRatio:             1.15,
+	GeminiRatio:             1.0,
+	CountTokensAPIEnabled:   false,
+	CountTokensAPITimeoutMs: 2000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tokenizer_setting", &tokenizerSetting)
+}
+
+func GetTokenizerSetting() *TokenizerSetting {
+	return &tokenizerSetting
+}
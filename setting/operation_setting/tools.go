@@ -1,6 +1,9 @@
 package operation_setting
 
-import "strings"
+import (
+	"one-api/setting/config"
+	"strings"
+)
 
 const (
 	// Web search
@@ -53,5 +56,43 @@ func GetWebSearchPricePerThousand(modelName string, contextSize string) float64
 }
 
 func GetFileSearchPricePerThousand() float64 {
-	return FileSearchPrice
+	return GetToolPricePerThousand("file_search", "", "")
+}
+
+type ToolSurchargeSetting struct {
+	// ToolPrices 工具名 -> 每千次调用价格（美元），web_search_preview 未配置时按模型与上下文大小取官方价格
+	ToolPrices map[string]float64 `json:"tool_prices"`
+}
+
+// 默认配置，价格为 0 的工具不额外计费（如 computer_use、image_generation 以 token 计费）
+var toolSurchargeSetting = ToolSurchargeSetting{
+	ToolPrices: map[string]float64{
+		"file_search":          FileSearchPrice,
+		"code_interpreter":     30,
+		"computer_use_preview": 0,
+		"image_generation":     0,
+		"google_search":        35,
+		"claude_web_search":    10,
+		"xai_live_search":      25, // 按使用的搜索来源数计费
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tool_surcharge_setting", &toolSurchargeSetting)
+}
+
+func GetToolSurchargeSetting() *ToolSurchargeSetting {
+	return &toolSurchargeSetting
+}
+
+// GetToolPricePerThousand 返回内置工具每千次调用的价格（美元）
+func GetToolPricePerThousand(toolName string, modelName string, contextSize string) float64 {
+	if price, ok := toolSurchargeSetting.ToolPrices[toolName]; ok {
+		return price
+	}
+	if toolName == "web_search_preview" {
+		return GetWebSearchPricePerThousand(modelName, contextSize)
+	}
+	return 0
 }
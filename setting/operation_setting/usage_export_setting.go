@@ -0,0 +1,49 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	UsageExportFormatCSV     = "csv"
+	UsageExportFormatParquet = "parquet"
+)
+
+type UsageExportSetting struct {
+	Enabled bool `json:"enabled"`
+	// Format 导出格式，csv（gzip 压缩）或 parquet
+	Format string `json:"format"`
+	// Endpoint S3 兼容存储地址，不含协议，如 s3.amazonaws.com、minio.local:9000
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	UseSSL    bool   `json:"use_ssl"`
+	// Prefix 对象键前缀，文件按 {prefix}/consume_logs/dt=YYYY-MM-DD/ 分区
+	Prefix string `json:"prefix"`
+	// RunAtHour 每天在该小时（本地时区）导出前一天的数据
+	RunAtHour int `json:"run_at_hour"`
+	// IncludeAggregates 同时导出按小时预聚合的费用统计
+	IncludeAggregates bool `json:"include_aggregates"`
+	// BatchSize 每批从数据库读取的日志条数
+	BatchSize int `json:"batch_size"`
+}
+
+// 默认配置
+var usageExportSetting = UsageExportSetting{
+	Enabled:           false,
+	Format:            UsageExportFormatCSV,
+	UseSSL:            true,
+	Prefix:            "one-api",
+	RunAtHour:         2,
+	IncludeAggregates: true,
+	BatchSize:         1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("usage_export_setting", &usageExportSetting)
+}
+
+func GetUsageExportSetting() *UsageExportSetting {
+	return &usageExportSetting
+}
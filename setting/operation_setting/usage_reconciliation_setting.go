@@ -0,0 +1,27 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type UsageReconciliationSetting struct {
+	Enabled bool `json:"enabled"`
+	// ThresholdPercent 本地预估的 prompt token 与上游返回的用量相差超过该百分比时记录对账日志
+	ThresholdPercent float64 `json:"threshold_percent"`
+	// MinTokens 上游返回的 prompt token 少于该值时不做对账，避免短请求的误报
+	MinTokens int `json:"min_tokens"`
+}
+
+// 默认配置
+var usageReconciliationSetting = UsageReconciliationSetting{
+	Enabled:          true,
+	ThresholdPercent: 20,
+	MinTokens:        100,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("usage_reconciliation_setting", &usageReconciliationSetting)
+}
+
+func GetUsageReconciliationSetting() *UsageReconciliationSetting {
+	return &usageReconciliationSetting
+}
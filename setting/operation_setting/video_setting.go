@@ -0,0 +1,56 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+type VideoSetting struct {
+	// DefaultSeconds 请求未指定时长时使用的视频秒数，同时用于预扣额度
+	DefaultSeconds int `json:"default_seconds"`
+	// MaxSeconds 单次请求允许的最大视频秒数
+	MaxSeconds int `json:"max_seconds"`
+	// DefaultResolution 请求未指定分辨率时使用的分辨率
+	DefaultResolution string `json:"default_resolution"`
+	// ResolutionMultipliers 模型 -> 分辨率 -> 价格倍率，模型按秒价格乘以倍率计费，未配置的分辨率倍率为 1；
+	// 模型名为 "*" 的配置对所有未单独配置的模型生效
+	ResolutionMultipliers map[string]map[string]float64 `json:"resolution_multipliers"`
+}
+
+// 默认配置
+var videoSetting = VideoSetting{
+	DefaultSeconds:    5,
+	MaxSeconds:        60,
+	DefaultResolution: "720p",
+	ResolutionMultipliers: map[string]map[string]float64{
+		"*": {
+			"480p":  0.5,
+			"720p":  1,
+			"1080p": 2,
+		},
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("video_setting", &videoSetting)
+}
+
+func GetVideoSetting() *VideoSetting {
+	return &videoSetting
+}
+
+// GetVideoResolutionMultiplier 返回模型在指定分辨率下的价格倍率
+func GetVideoResolutionMultiplier(modelName string, resolution string) float64 {
+	multipliers, ok := videoSetting.ResolutionMultipliers[modelName]
+	if !ok {
+		multipliers = videoSetting.ResolutionMultipliers["*"]
+	}
+	for key, multiplier := range multipliers {
+		// 配置中的分辨率可能含大写，如 1080P
+		if strings.EqualFold(key, resolution) && multiplier > 0 {
+			return multiplier
+		}
+	}
+	return 1
+}
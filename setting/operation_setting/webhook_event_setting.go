@@ -0,0 +1,62 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type WebhookEndpoint struct {
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+	// Events 订阅的事件类型，为空时订阅全部事件
+	Events []string `json:"events"`
+}
+
+type WebhookEventSetting struct {
+	Enabled   bool              `json:"enabled"`
+	Endpoints []WebhookEndpoint `json:"endpoints"`
+	// MaxRetries 投递失败后的重试次数，重试间隔按 2 的幂次递增，全部失败后写入死信
+	MaxRetries int `json:"max_retries"`
+	// ChannelFailureThreshold 渠道在窗口内请求失败达到该次数时发送 channel.request_failures 事件，0 表示关闭
+	ChannelFailureThreshold int `json:"channel_failure_threshold"`
+	// ChannelFailureWindowSeconds 渠道失败计数窗口
+	ChannelFailureWindowSeconds int `json:"channel_failure_window_seconds"`
+}
+
+// 默认配置
+var webhookEventSetting = WebhookEventSetting{
+	Enabled:                     false,
+	MaxRetries:                  3,
+	ChannelFailureThreshold:     10,
+	ChannelFailureWindowSeconds: 300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("webhook_event_setting", &webhookEventSetting)
+}
+
+func GetWebhookEventSetting() *WebhookEventSetting {
+	return &webhookEventSetting
+}
+
+// GetWebhookEndpoints 返回订阅了指定事件的端点
+func GetWebhookEndpoints(eventType string) []WebhookEndpoint {
+	if !webhookEventSetting.Enabled {
+		return nil
+	}
+	var endpoints []WebhookEndpoint
+	for _, endpoint := range webhookEventSetting.Endpoints {
+		if endpoint.Url == "" {
+			continue
+		}
+		if len(endpoint.Events) == 0 {
+			endpoints = append(endpoints, endpoint)
+			continue
+		}
+		for _, event := range endpoint.Events {
+			if event == eventType || event == "*" {
+				endpoints = append(endpoints, endpoint)
+				break
+			}
+		}
+	}
+	return endpoints
+}
@@ -0,0 +1,41 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	// WorkerPoolRejectPolicyReject 协程池饱和时新的转发请求直接返回 503 与 Retry-After
+	WorkerPoolRejectPolicyReject = "reject"
+	// WorkerPoolRejectPolicyQueue 协程池饱和时继续排队，不拒绝请求
+	WorkerPoolRejectPolicyQueue = "queue"
+)
+
+type WorkerPoolSetting struct {
+	// RelayPoolSize 转发协程池（读取上游流式响应等）的协程上限，0 表示不限制
+	RelayPoolSize int `json:"relay_pool_size"`
+	// BackgroundPoolSize 后台任务（写日志、更新额度等）协程池的协程上限，0 表示使用默认值 10000
+	BackgroundPoolSize int `json:"background_pool_size"`
+	// QueueLength 转发协程全部占满后允许排队的任务数，超过即视为饱和
+	QueueLength int `json:"queue_length"`
+	// RejectPolicy 饱和时的处理方式：reject 或 queue
+	RejectPolicy string `json:"reject_policy"`
+	// RetryAfterSeconds 拒绝请求时 Retry-After 响应头的秒数
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// 默认配置
+var workerPoolSetting = WorkerPoolSetting{
+	RelayPoolSize:      0,
+	BackgroundPoolSize: 0,
+	QueueLength:        100,
+	RejectPolicy:       WorkerPoolRejectPolicyReject,
+	RetryAfterSeconds:  5,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("worker_pool_setting", &workerPoolSetting)
+}
+
+func GetWorkerPoolSetting() *WorkerPoolSetting {
+	return &workerPoolSetting
+}
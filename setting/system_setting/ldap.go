@@ -0,0 +1,52 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type LDAPSettings struct {
+	Enabled bool `json:"enabled"`
+	// Url 如 ldap://ldap.example.com:389 或 ldaps://ldap.example.com:636
+	Url                string `json:"url"`
+	StartTLS           bool   `json:"start_tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	// BindDN、BindPassword 用于查找用户与定期同步的服务账号
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn"`
+	// UserFilter 查找用户的过滤器，%s 会被替换为转义后的用户名
+	UserFilter           string `json:"user_filter"`
+	UsernameAttribute    string `json:"username_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	// GroupAttribute 用户所属分组的属性，值为分组 DN 时同时按 DN 与其 CN 匹配映射
+	GroupAttribute string            `json:"group_attribute"`
+	RoleMapping    map[string]string `json:"role_mapping"`
+	GroupMapping   map[string]string `json:"group_mapping"`
+	// AutoRegister 首次登录成功时自动创建本地用户
+	AutoRegister bool `json:"auto_register"`
+	// SyncIntervalMinutes 定期同步用户状态、角色与分组的间隔，0 表示不同步；目录中已不存在的用户会被禁用
+	SyncIntervalMinutes int `json:"sync_interval_minutes"`
+	TimeoutSeconds      int `json:"timeout_seconds"`
+}
+
+// 默认配置
+var defaultLDAPSettings = LDAPSettings{
+	UserFilter:           "(uid=%s)",
+	UsernameAttribute:    "uid",
+	EmailAttribute:       "mail",
+	DisplayNameAttribute: "cn",
+	GroupAttribute:       "memberOf",
+	RoleMapping:          map[string]string{},
+	GroupMapping:         map[string]string{},
+	AutoRegister:         true,
+	SyncIntervalMinutes:  60,
+	TimeoutSeconds:       10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ldap", &defaultLDAPSettings)
+}
+
+func GetLDAPSettings() *LDAPSettings {
+	return &defaultLDAPSettings
+}
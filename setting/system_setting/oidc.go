@@ -10,10 +10,23 @@ type OIDCSettings struct {
 	AuthorizationEndpoint string `json:"authorization_endpoint"`
 	TokenEndpoint         string `json:"token_endpoint"`
 	UserInfoEndpoint      string `json:"user_info_endpoint"`
+	// GroupsClaim 用户信息或 ID Token 中表示 IdP 分组的字段，Keycloak/Auth0 一般为 groups，Azure AD 可使用 roles
+	GroupsClaim string `json:"groups_claim"`
+	// RoleMapping IdP 分组 -> 系统角色（common、admin），匹配多个时取最高
+	RoleMapping map[string]string `json:"role_mapping"`
+	// GroupMapping IdP 分组 -> 用户分组，按 IdP 返回的分组顺序取第一个匹配
+	GroupMapping map[string]string `json:"group_mapping"`
+	// SyncOnLogin 已有用户每次登录时按映射同步分组，配置了角色映射时角色总是在登录时同步
+	SyncOnLogin bool `json:"sync_on_login"`
 }
 
 // 默认配置
-var defaultOIDCSettings = OIDCSettings{}
+var defaultOIDCSettings = OIDCSettings{
+	GroupsClaim:  "groups",
+	RoleMapping:  map[string]string{},
+	GroupMapping: map[string]string{},
+	SyncOnLogin:  true,
+}
 
 func init() {
 	// 注册到全局配置管理器